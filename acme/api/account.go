@@ -2,9 +2,11 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/go-chi/chi"
+	"go.step.sm/crypto/jose"
 
 	"github.com/smallstep/certificates/acme"
 	"github.com/smallstep/certificates/api/render"
@@ -200,6 +202,153 @@ func (h *Handler) GetOrUpdateAccount(w http.ResponseWriter, r *http.Request) {
 	render.JSON(w, acc)
 }
 
+// KeyChangeRequest represents the payload of the inner JWS of a key-change
+// request.
+type KeyChangeRequest struct {
+	Account string           `json:"account"`
+	OldKey  *jose.JSONWebKey `json:"oldKey"`
+}
+
+// Validate validates a key-change request body.
+func (k *KeyChangeRequest) Validate() error {
+	switch {
+	case k.Account == "":
+		return acme.NewError(acme.ErrorMalformedType, "account cannot be empty")
+	case k.OldKey == nil:
+		return acme.NewError(acme.ErrorMalformedType, "oldKey cannot be empty")
+	default:
+		return nil
+	}
+}
+
+// KeyChange is the handler resource for rolling over the key of an ACME
+// account as described in RFC 8555, section 7.3.5. The outer JWS is signed with
+// the current account key, and its payload is another JWS signed with the new
+// key.
+func (h *Handler) KeyChange(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	acc, err := accountFromContext(ctx)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	jws, err := jwsFromContext(ctx)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	payload, err := payloadFromContext(ctx)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	inner, err := jose.ParseJWS(string(payload.value))
+	if err != nil {
+		render.Error(w, acme.WrapError(acme.ErrorMalformedType, err, "failed to parse inner JWS from key-change payload"))
+		return
+	}
+	if len(inner.Signatures) != 1 {
+		render.Error(w, acme.NewError(acme.ErrorMalformedType, "inner JWS must contain exactly one signature"))
+		return
+	}
+
+	// The inner JWS must be signed with the new key, given in a jwk and not
+	// in a kid, must have the same url as the outer JWS, and it must not have
+	// a nonce.
+	outerHdr, innerHdr := jws.Signatures[0].Protected, inner.Signatures[0].Protected
+	newKey := innerHdr.JSONWebKey
+	if newKey == nil || !newKey.Valid() {
+		render.Error(w, acme.NewError(acme.ErrorMalformedType, "inner JWS must contain a valid jwk"))
+		return
+	}
+	if innerHdr.KeyID != "" {
+		render.Error(w, acme.NewError(acme.ErrorMalformedType, "inner JWS must not contain a kid"))
+		return
+	}
+	if err := validateJWSAlgorithm(innerHdr); err != nil {
+		render.Error(w, err)
+		return
+	}
+	if innerHdr.Nonce != "" {
+		render.Error(w, acme.NewError(acme.ErrorMalformedType, "inner JWS must not contain a nonce"))
+		return
+	}
+	if innerURL, ok := innerHdr.ExtraHeaders["url"].(string); !ok || innerURL != outerHdr.ExtraHeaders["url"] {
+		render.Error(w, acme.NewError(acme.ErrorMalformedType, "url header in inner JWS does not match the outer JWS"))
+		return
+	}
+	innerPayload, err := inner.Verify(newKey)
+	if err != nil {
+		render.Error(w, acme.WrapError(acme.ErrorMalformedType, err, "error verifying inner jws"))
+		return
+	}
+
+	var kcr KeyChangeRequest
+	if err := json.Unmarshal(innerPayload, &kcr); err != nil {
+		render.Error(w, acme.WrapError(acme.ErrorMalformedType, err,
+			"failed to unmarshal key-change request payload"))
+		return
+	}
+	if err := kcr.Validate(); err != nil {
+		render.Error(w, err)
+		return
+	}
+	if kcr.Account != outerHdr.KeyID {
+		render.Error(w, acme.NewError(acme.ErrorUnauthorizedType,
+			"account '%s' in key-change request does not match the kid '%s'", kcr.Account, outerHdr.KeyID))
+		return
+	}
+
+	// The old key must be the current account key.
+	accKid, err := acme.KeyToID(acc.Key)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	oldKid, err := acme.KeyToID(kcr.OldKey)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	if oldKid != accKid {
+		render.Error(w, acme.NewError(acme.ErrorUnauthorizedType, "oldKey does not match the current account key"))
+		return
+	}
+
+	// The new key cannot be bound to any account.
+	newKey.KeyID, err = acme.KeyToID(newKey)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	existing, err := h.db.GetAccountByKeyID(ctx, newKey.KeyID)
+	switch {
+	case errors.Is(err, acme.ErrNotFound):
+		// Key not in use
+	case err != nil:
+		render.Error(w, acme.WrapErrorISE(err, "error retrieving account by key"))
+		return
+	default:
+		acmeErr := acme.NewError(acme.ErrorMalformedType, "new key is already in use by another account")
+		acmeErr.Status = http.StatusConflict
+		w.Header().Set("Location", h.linker.GetLink(ctx, AccountLinkType, existing.ID))
+		render.Error(w, acmeErr)
+		return
+	}
+
+	if err := h.db.UpdateAccountKey(ctx, acc.ID, newKey); err != nil {
+		render.Error(w, acme.WrapErrorISE(err, "error updating account key"))
+		return
+	}
+	acc.Key = newKey
+
+	h.linker.LinkAccount(ctx, acc)
+
+	w.Header().Set("Location", h.linker.GetLink(ctx, AccountLinkType, acc.ID))
+	render.JSON(w, acc)
+}
+
 func logOrdersByAccount(w http.ResponseWriter, oids []string) {
 	if rl, ok := w.(logging.ResponseLogger); ok {
 		m := map[string]interface{}{
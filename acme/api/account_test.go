@@ -993,3 +993,340 @@ func TestHandler_GetOrUpdateAccount(t *testing.T) {
 		})
 	}
 }
+
+func TestKeyChangeRequest_Validate(t *testing.T) {
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	assert.FatalError(t, err)
+	pub := jwk.Public()
+
+	type test struct {
+		kcr *KeyChangeRequest
+		err *acme.Error
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/no-account": func(t *testing.T) test {
+			return test{
+				kcr: &KeyChangeRequest{OldKey: &pub},
+				err: acme.NewError(acme.ErrorMalformedType, "account cannot be empty"),
+			}
+		},
+		"fail/no-oldKey": func(t *testing.T) test {
+			return test{
+				kcr: &KeyChangeRequest{Account: "https://ca.smallstep.com/acme/account/accID"},
+				err: acme.NewError(acme.ErrorMalformedType, "oldKey cannot be empty"),
+			}
+		},
+		"ok": func(t *testing.T) test {
+			return test{
+				kcr: &KeyChangeRequest{Account: "https://ca.smallstep.com/acme/account/accID", OldKey: &pub},
+			}
+		},
+	}
+	for name, run := range tests {
+		tc := run(t)
+		t.Run(name, func(t *testing.T) {
+			if err := tc.kcr.Validate(); err != nil {
+				if assert.NotNil(t, tc.err) {
+					ae, ok := err.(*acme.Error)
+					assert.True(t, ok)
+					assert.HasPrefix(t, ae.Error(), tc.err.Error())
+					assert.Equals(t, ae.StatusCode(), tc.err.StatusCode())
+					assert.Equals(t, ae.Type, tc.err.Type)
+				}
+			} else {
+				assert.Nil(t, tc.err)
+			}
+		})
+	}
+}
+
+func TestHandler_KeyChange(t *testing.T) {
+	accID := "accountID"
+	prov := newProv()
+	escProvName := url.PathEscape(prov.GetName())
+	baseURL := &url.URL{Scheme: "https", Host: "test.ca.smallstep.com"}
+	accURL := fmt.Sprintf("%s/acme/%s/account/%s", baseURL.String(), escProvName, accID)
+	keyChangeURL := fmt.Sprintf("%s/acme/%s/key-change", baseURL.String(), escProvName)
+
+	oldJWK, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	assert.FatalError(t, err)
+	oldPub := oldJWK.Public()
+	newJWK, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	assert.FatalError(t, err)
+	newPub := newJWK.Public()
+	newKid, err := acme.KeyToID(&newPub)
+	assert.FatalError(t, err)
+
+	sign := func(t *testing.T, jwk *jose.JSONWebKey, embedJWK bool, headers map[jose.HeaderKey]interface{}, payload []byte) *jose.JSONWebSignature {
+		t.Helper()
+		so := &jose.SignerOptions{
+			ExtraHeaders: headers,
+			EmbedJWK:     embedJWK,
+		}
+		signer, err := jose.NewSigner(jose.SigningKey{
+			Algorithm: jose.SignatureAlgorithm(jwk.Algorithm),
+			Key:       jwk.Key,
+		}, so)
+		assert.FatalError(t, err)
+		jws, err := signer.Sign(payload)
+		assert.FatalError(t, err)
+		raw, err := jws.CompactSerialize()
+		assert.FatalError(t, err)
+		parsed, err := jose.ParseJWS(raw)
+		assert.FatalError(t, err)
+		return parsed
+	}
+
+	// newContext creates the context with the outer JWS signed by the old key,
+	// and the given inner JWS as the payload.
+	newContext := func(t *testing.T, inner *jose.JSONWebSignature) context.Context {
+		t.Helper()
+		var payload []byte
+		if inner != nil {
+			raw, err := inner.CompactSerialize()
+			assert.FatalError(t, err)
+			payload = []byte(raw)
+		} else {
+			payload = []byte("foo")
+		}
+		outer := sign(t, oldJWK, false, map[jose.HeaderKey]interface{}{
+			"kid": accURL,
+			"url": keyChangeURL,
+		}, payload)
+		acc := &acme.Account{
+			ID:     accID,
+			Key:    &oldPub,
+			Status: acme.StatusValid,
+		}
+		ctx := context.WithValue(context.Background(), provisionerContextKey, prov)
+		ctx = context.WithValue(ctx, baseURLContextKey, baseURL)
+		ctx = context.WithValue(ctx, accContextKey, acc)
+		ctx = context.WithValue(ctx, jwsContextKey, outer)
+		ctx = context.WithValue(ctx, payloadContextKey, &payloadInfo{value: payload})
+		return ctx
+	}
+	newInner := func(t *testing.T, kcr *KeyChangeRequest, headers map[jose.HeaderKey]interface{}) *jose.JSONWebSignature {
+		t.Helper()
+		b, err := json.Marshal(kcr)
+		assert.FatalError(t, err)
+		if headers == nil {
+			headers = map[jose.HeaderKey]interface{}{"url": keyChangeURL}
+		}
+		return sign(t, newJWK, true, headers, b)
+	}
+	validRequest := &KeyChangeRequest{Account: accURL, OldKey: &oldPub}
+
+	type test struct {
+		db         acme.DB
+		ctx        context.Context
+		statusCode int
+		location   string
+		err        *acme.Error
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/no-account": func(t *testing.T) test {
+			return test{
+				ctx:        context.Background(),
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorAccountDoesNotExistType, "account not in context"),
+			}
+		},
+		"fail/no-jws": func(t *testing.T) test {
+			ctx := context.WithValue(context.Background(), accContextKey, &acme.Account{ID: accID})
+			return test{
+				ctx:        ctx,
+				statusCode: 500,
+				err:        acme.NewErrorISE("jws expected in request context"),
+			}
+		},
+		"fail/no-payload": func(t *testing.T) test {
+			ctx := newContext(t, nil)
+			ctx = context.WithValue(ctx, payloadContextKey, nil)
+			return test{
+				ctx:        ctx,
+				statusCode: 500,
+				err:        acme.NewErrorISE("payload expected in request context"),
+			}
+		},
+		"fail/parse-inner-jws": func(t *testing.T) test {
+			return test{
+				ctx:        newContext(t, nil),
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorMalformedType, "failed to parse inner JWS from key-change payload"),
+			}
+		},
+		"fail/inner-jws-no-jwk": func(t *testing.T) test {
+			b, err := json.Marshal(validRequest)
+			assert.FatalError(t, err)
+			inner := sign(t, newJWK, false, map[jose.HeaderKey]interface{}{"url": keyChangeURL}, b)
+			return test{
+				ctx:        newContext(t, inner),
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorMalformedType, "inner JWS must contain a valid jwk"),
+			}
+		},
+		"fail/inner-jws-kid": func(t *testing.T) test {
+			inner := newInner(t, validRequest, map[jose.HeaderKey]interface{}{
+				"url": keyChangeURL,
+				"kid": accURL,
+			})
+			return test{
+				ctx:        newContext(t, inner),
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorMalformedType, "inner JWS must not contain a kid"),
+			}
+		},
+		"fail/inner-jws-nonce": func(t *testing.T) test {
+			inner := newInner(t, validRequest, map[jose.HeaderKey]interface{}{
+				"url":   keyChangeURL,
+				"nonce": "a-nonce",
+			})
+			return test{
+				ctx:        newContext(t, inner),
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorMalformedType, "inner JWS must not contain a nonce"),
+			}
+		},
+		"fail/inner-jws-url": func(t *testing.T) test {
+			inner := newInner(t, validRequest, map[jose.HeaderKey]interface{}{
+				"url": accURL,
+			})
+			return test{
+				ctx:        newContext(t, inner),
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorMalformedType, "url header in inner JWS does not match the outer JWS"),
+			}
+		},
+		"fail/unmarshal-inner-payload": func(t *testing.T) test {
+			inner := sign(t, newJWK, true, map[jose.HeaderKey]interface{}{"url": keyChangeURL}, []byte("foo"))
+			return test{
+				ctx:        newContext(t, inner),
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorMalformedType, "failed to unmarshal key-change request payload"),
+			}
+		},
+		"fail/validate-inner-payload": func(t *testing.T) test {
+			inner := newInner(t, &KeyChangeRequest{Account: accURL}, nil)
+			return test{
+				ctx:        newContext(t, inner),
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorMalformedType, "oldKey cannot be empty"),
+			}
+		},
+		"fail/account-mismatch": func(t *testing.T) test {
+			inner := newInner(t, &KeyChangeRequest{Account: accURL + "-other", OldKey: &oldPub}, nil)
+			return test{
+				ctx:        newContext(t, inner),
+				statusCode: 401,
+				err:        acme.NewError(acme.ErrorUnauthorizedType, "account in key-change request does not match the kid"),
+			}
+		},
+		"fail/oldKey-mismatch": func(t *testing.T) test {
+			inner := newInner(t, &KeyChangeRequest{Account: accURL, OldKey: &newPub}, nil)
+			return test{
+				ctx:        newContext(t, inner),
+				statusCode: 401,
+				err:        acme.NewError(acme.ErrorUnauthorizedType, "oldKey does not match the current account key"),
+			}
+		},
+		"fail/db.GetAccountByKeyID-error": func(t *testing.T) test {
+			return test{
+				db: &acme.MockDB{
+					MockGetAccountByKeyID: func(ctx context.Context, kid string) (*acme.Account, error) {
+						assert.Equals(t, newKid, kid)
+						return nil, errors.New("force")
+					},
+				},
+				ctx:        newContext(t, newInner(t, validRequest, nil)),
+				statusCode: 500,
+				err:        acme.NewErrorISE("error retrieving account by key: force"),
+			}
+		},
+		"fail/key-in-use": func(t *testing.T) test {
+			return test{
+				db: &acme.MockDB{
+					MockGetAccountByKeyID: func(ctx context.Context, kid string) (*acme.Account, error) {
+						assert.Equals(t, newKid, kid)
+						return &acme.Account{ID: "otherAccountID"}, nil
+					},
+				},
+				ctx:        newContext(t, newInner(t, validRequest, nil)),
+				statusCode: 409,
+				location:   fmt.Sprintf("%s/acme/%s/account/otherAccountID", baseURL.String(), escProvName),
+				err:        acme.NewError(acme.ErrorMalformedType, "new key is already in use by another account"),
+			}
+		},
+		"fail/db.UpdateAccountKey-error": func(t *testing.T) test {
+			return test{
+				db: &acme.MockDB{
+					MockGetAccountByKeyID: func(ctx context.Context, kid string) (*acme.Account, error) {
+						return nil, acme.ErrNotFound
+					},
+					MockUpdateAccountKey: func(ctx context.Context, id string, jwk *jose.JSONWebKey) error {
+						return errors.New("force")
+					},
+				},
+				ctx:        newContext(t, newInner(t, validRequest, nil)),
+				statusCode: 500,
+				err:        acme.NewErrorISE("error updating account key: force"),
+			}
+		},
+		"ok": func(t *testing.T) test {
+			return test{
+				db: &acme.MockDB{
+					MockGetAccountByKeyID: func(ctx context.Context, kid string) (*acme.Account, error) {
+						assert.Equals(t, newKid, kid)
+						return nil, acme.ErrNotFound
+					},
+					MockUpdateAccountKey: func(ctx context.Context, id string, jwk *jose.JSONWebKey) error {
+						assert.Equals(t, accID, id)
+						assert.Equals(t, newKid, jwk.KeyID)
+						kid, err := acme.KeyToID(jwk)
+						assert.FatalError(t, err)
+						assert.Equals(t, newKid, kid)
+						return nil
+					},
+				},
+				ctx:        newContext(t, newInner(t, validRequest, nil)),
+				statusCode: 200,
+				location:   accURL,
+			}
+		},
+	}
+	for name, run := range tests {
+		tc := run(t)
+		t.Run(name, func(t *testing.T) {
+			h := &Handler{db: tc.db, linker: NewLinker("dns", "acme")}
+			req := httptest.NewRequest("POST", "/foo/bar", nil)
+			req = req.WithContext(tc.ctx)
+			w := httptest.NewRecorder()
+			h.KeyChange(w, req)
+			res := w.Result()
+
+			assert.Equals(t, res.StatusCode, tc.statusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if tc.location != "" {
+				assert.Equals(t, res.Header["Location"], []string{tc.location})
+			}
+
+			if res.StatusCode >= 400 && assert.NotNil(t, tc.err) {
+				var ae acme.Error
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &ae))
+
+				assert.Equals(t, ae.Type, tc.err.Type)
+				assert.Equals(t, ae.Detail, tc.err.Detail)
+				assert.Equals(t, res.Header["Content-Type"], []string{"application/problem+json"})
+			} else {
+				var acc acme.Account
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &acc))
+				assert.Equals(t, acc.Status, acme.StatusValid)
+				assert.Equals(t, acc.OrdersURL, accURL+"/orders")
+				assert.Equals(t, res.Header["Content-Type"], []string{"application/json"})
+			}
+		})
+	}
+}
@@ -2,13 +2,13 @@ package api
 
 import (
 	"context"
-	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
-	"net"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi"
@@ -17,6 +17,7 @@ import (
 	"github.com/smallstep/certificates/api"
 	"github.com/smallstep/certificates/api/render"
 	"github.com/smallstep/certificates/authority/provisioner"
+	"go.step.sm/crypto/jose"
 )
 
 func link(url, typ string) string {
@@ -46,6 +47,9 @@ type Handler struct {
 	ca                       acme.CertificateAuthority
 	linker                   Linker
 	validateChallengeOptions *acme.ValidateChallengeOptions
+	challengeOptions         sync.Map
+	validator                *acme.Validator
+	meter                    acme.Meter
 	prerequisitesChecker     func(ctx context.Context) (bool, error)
 }
 
@@ -67,22 +71,17 @@ type HandlerOptions struct {
 	// PrerequisitesChecker checks if all prerequisites for serving ACME are
 	// met by the CA configuration.
 	PrerequisitesChecker func(ctx context.Context) (bool, error)
+	// Validator validates the challenges in the background. By default the
+	// process-wide validator is used, so the in-flight validations survive a
+	// reload of the CA.
+	Validator *acme.Validator
+	// Meter, if set, gathers metrics about the finalized orders and the
+	// validated challenges.
+	Meter acme.Meter
 }
 
 // NewHandler returns a new ACME API handler.
 func NewHandler(ops HandlerOptions) api.RouterHandler {
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
-	}
-	client := http.Client{
-		Timeout:   30 * time.Second,
-		Transport: transport,
-	}
-	dialer := &net.Dialer{
-		Timeout: 30 * time.Second,
-	}
 	prerequisitesChecker := func(ctx context.Context) (bool, error) {
 		// by default all prerequisites are met
 		return true, nil
@@ -90,19 +89,19 @@ func NewHandler(ops HandlerOptions) api.RouterHandler {
 	if ops.PrerequisitesChecker != nil {
 		prerequisitesChecker = ops.PrerequisitesChecker
 	}
+	validator := ops.Validator
+	if validator == nil {
+		validator = acme.DefaultValidator()
+	}
 	return &Handler{
-		ca:       ops.CA,
-		db:       ops.DB,
-		backdate: ops.Backdate,
-		linker:   NewLinker(ops.DNS, ops.Prefix),
-		validateChallengeOptions: &acme.ValidateChallengeOptions{
-			HTTPGet:   client.Get,
-			LookupTxt: net.LookupTXT,
-			TLSDial: func(network, addr string, config *tls.Config) (*tls.Conn, error) {
-				return tls.DialWithDialer(dialer, network, addr, config)
-			},
-		},
-		prerequisitesChecker: prerequisitesChecker,
+		ca:                       ops.CA,
+		db:                       ops.DB,
+		backdate:                 ops.Backdate,
+		linker:                   NewLinker(ops.DNS, ops.Prefix),
+		validateChallengeOptions: acme.NewValidateChallengeOptions(nil),
+		validator:                validator,
+		meter:                    ops.Meter,
+		prerequisitesChecker:     prerequisitesChecker,
 	}
 }
 
@@ -114,6 +113,7 @@ func (h *Handler) Route(r api.Router) {
 	r.MethodFunc("HEAD", getPath(NewNonceLinkType, "{provisionerID}"), h.baseURLFromRequest(h.lookupProvisioner(h.checkPrerequisites(h.addNonce(h.addDirLink(h.GetNonce))))))
 	r.MethodFunc("GET", getPath(DirectoryLinkType, "{provisionerID}"), h.baseURLFromRequest(h.lookupProvisioner(h.checkPrerequisites(h.GetDirectory))))
 	r.MethodFunc("HEAD", getPath(DirectoryLinkType, "{provisionerID}"), h.baseURLFromRequest(h.lookupProvisioner(h.checkPrerequisites(h.GetDirectory))))
+	r.MethodFunc("GET", getPath(RenewalInfoLinkType, "{provisionerID}", "{certID}"), h.baseURLFromRequest(h.lookupProvisioner(h.checkPrerequisites(h.GetRenewalInfo))))
 
 	validatingMiddleware := func(next nextHTTP) nextHTTP {
 		return h.baseURLFromRequest(h.lookupProvisioner(h.checkPrerequisites(h.addNonce(h.addDirLink(h.verifyContentType(h.parseJWS(h.validateJWS(next))))))))
@@ -130,7 +130,7 @@ func (h *Handler) Route(r api.Router) {
 
 	r.MethodFunc("POST", getPath(NewAccountLinkType, "{provisionerID}"), extractPayloadByJWK(h.NewAccount))
 	r.MethodFunc("POST", getPath(AccountLinkType, "{provisionerID}", "{accID}"), extractPayloadByKid(h.GetOrUpdateAccount))
-	r.MethodFunc("POST", getPath(KeyChangeLinkType, "{provisionerID}", "{accID}"), extractPayloadByKid(h.NotImplemented))
+	r.MethodFunc("POST", getPath(KeyChangeLinkType, "{provisionerID}", "{accID}"), extractPayloadByKid(h.KeyChange))
 	r.MethodFunc("POST", getPath(NewOrderLinkType, "{provisionerID}"), extractPayloadByKid(h.NewOrder))
 	r.MethodFunc("POST", getPath(OrderLinkType, "{provisionerID}", "{ordID}"), extractPayloadByKid(h.isPostAsGet(h.GetOrder)))
 	r.MethodFunc("POST", getPath(OrdersByAccountLinkType, "{provisionerID}", "{accID}"), extractPayloadByKid(h.isPostAsGet(h.GetOrdersByAccountID)))
@@ -160,12 +160,13 @@ type Meta struct {
 
 // Directory represents an ACME directory for configuring clients.
 type Directory struct {
-	NewNonce   string `json:"newNonce"`
-	NewAccount string `json:"newAccount"`
-	NewOrder   string `json:"newOrder"`
-	RevokeCert string `json:"revokeCert"`
-	KeyChange  string `json:"keyChange"`
-	Meta       Meta   `json:"meta"`
+	NewNonce    string `json:"newNonce"`
+	NewAccount  string `json:"newAccount"`
+	NewOrder    string `json:"newOrder"`
+	RevokeCert  string `json:"revokeCert"`
+	KeyChange   string `json:"keyChange"`
+	RenewalInfo string `json:"renewalInfo"`
+	Meta        Meta   `json:"meta"`
 }
 
 // ToLog enables response logging for the Directory type.
@@ -188,11 +189,12 @@ func (h *Handler) GetDirectory(w http.ResponseWriter, r *http.Request) {
 	}
 
 	render.JSON(w, &Directory{
-		NewNonce:   h.linker.GetLink(ctx, NewNonceLinkType),
-		NewAccount: h.linker.GetLink(ctx, NewAccountLinkType),
-		NewOrder:   h.linker.GetLink(ctx, NewOrderLinkType),
-		RevokeCert: h.linker.GetLink(ctx, RevokeCertLinkType),
-		KeyChange:  h.linker.GetLink(ctx, KeyChangeLinkType),
+		NewNonce:    h.linker.GetLink(ctx, NewNonceLinkType),
+		NewAccount:  h.linker.GetLink(ctx, NewAccountLinkType),
+		NewOrder:    h.linker.GetLink(ctx, NewOrderLinkType),
+		RevokeCert:  h.linker.GetLink(ctx, RevokeCertLinkType),
+		KeyChange:   h.linker.GetLink(ctx, KeyChangeLinkType),
+		RenewalInfo: h.linker.GetLink(ctx, RenewalInfoLinkType),
 		Meta: Meta{
 			ExternalAccountRequired: acmeProv.RequireEAB,
 		},
@@ -244,7 +246,7 @@ func (h *Handler) GetChallenge(w http.ResponseWriter, r *http.Request) {
 	}
 	// Just verify that the payload was set, since we're not strictly adhering
 	// to ACME V2 spec for reasons specified below.
-	_, err = payloadFromContext(ctx)
+	payload, err := payloadFromContext(ctx)
 	if err != nil {
 		render.Error(w, err)
 		return
@@ -254,7 +256,8 @@ func (h *Handler) GetChallenge(w http.ResponseWriter, r *http.Request) {
 	// that the payload is an empty JSON block ({}). However, older ACME clients
 	// still send a vestigial body (rather than an empty JSON block) and
 	// strict enforcement would render these clients broken. For the time being
-	// we'll just ignore the body.
+	// we'll just ignore the body, except for the device-attest-01 challenge
+	// that sends the attestation in it.
 
 	azID := chi.URLParam(r, "authzID")
 	ch, err := h.db.GetChallenge(ctx, chi.URLParam(r, "chID"), azID)
@@ -273,18 +276,112 @@ func (h *Handler) GetChallenge(w http.ResponseWriter, r *http.Request) {
 		render.Error(w, err)
 		return
 	}
-	if err = ch.Validate(ctx, h.db, jwk, h.validateChallengeOptions); err != nil {
-		render.Error(w, acme.WrapErrorISE(err, "error validating challenge"))
+	prov, err := provisionerFromContext(ctx)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	if !prov.IsChallengeEnabled(ctx, provisioner.ACMEChallenge(ch.Type)) {
+		render.Error(w, acme.NewError(acme.ErrorMalformedType,
+			"challenge type %s is not enabled in provisioner %s", ch.Type, prov.GetName()))
+		return
+	}
+	// Provisioners can configure their own resolvers, timeouts and retries.
+	vo := h.getValidateChallengeOptions(prov)
+	// The device-attest-01 challenge is validated with the payload of the
+	// request, the other challenges are validated in the background if a
+	// validator is available.
+	if h.validator == nil || ch.Type == acme.DEVICEATTEST01 {
+		if err = ch.Validate(ctx, h.db, jwk, vo, prov, payload.value); err != nil {
+			render.Error(w, acme.WrapErrorISE(err, "error validating challenge"))
+			return
+		}
+		if ch.Status == acme.StatusValid || ch.Status == acme.StatusInvalid {
+			h.challengeValidated(prov.GetName())(ch)
+		}
+	} else if err = h.validateAsync(ctx, ch, jwk, vo, prov.GetName()); err != nil {
+		render.Error(w, err)
 		return
 	}
 
 	h.linker.LinkChallenge(ctx, ch, azID)
 
+	if ch.Status == acme.StatusProcessing {
+		w.Header().Set("Retry-After", strconv.Itoa(int(vo.RetryAfter().Seconds())))
+	}
 	w.Header().Add("Link", link(h.linker.GetLink(ctx, AuthzLinkType, azID), "up"))
 	w.Header().Set("Location", h.linker.GetLink(ctx, ChallengeLinkType, azID, ch.ID))
 	render.JSON(w, ch)
 }
 
+// challengeOptionsEntry are the validate options created for the challenge
+// options of a provisioner.
+type challengeOptionsEntry struct {
+	options *provisioner.ACMEChallengeOptions
+	vo      *acme.ValidateChallengeOptions
+}
+
+// getValidateChallengeOptions returns the options used to validate the
+// challenges of the given provisioner. The options, with the http.Client and
+// DNS resolver they use, are created once for each provisioner, and replaced
+// when the provisioner is reloaded with new challenge options.
+func (h *Handler) getValidateChallengeOptions(prov acme.Provisioner) *acme.ValidateChallengeOptions {
+	o := prov.GetChallengeOptions()
+	if o == nil {
+		return h.validateChallengeOptions
+	}
+	id := prov.GetID()
+	if v, ok := h.challengeOptions.Load(id); ok {
+		if e := v.(*challengeOptionsEntry); e.options == o {
+			return e.vo
+		}
+	}
+	e := &challengeOptionsEntry{
+		options: o,
+		vo:      acme.NewValidateChallengeOptions(o),
+	}
+	h.challengeOptions.Store(id, e)
+	return e.vo
+}
+
+// validateAsync moves a pending challenge to the processing status and queues
+// its validation. Processing challenges that are not being validated, e.g.
+// after a restart of the CA, are queued again.
+func (h *Handler) validateAsync(ctx context.Context, ch *acme.Challenge, jwk *jose.JSONWebKey, vo *acme.ValidateChallengeOptions, provName string) error {
+	switch {
+	case ch.Status == acme.StatusPending:
+	case ch.Status == acme.StatusProcessing && !h.validator.IsValidating(ch.ID):
+	default:
+		return nil
+	}
+
+	// Validations are not retried after the authorization expires.
+	az, err := h.db.GetAuthorization(ctx, ch.AuthorizationID)
+	if err != nil {
+		return acme.WrapErrorISE(err, "error retrieving authorization")
+	}
+
+	ch.Status = acme.StatusProcessing
+	if err := h.db.UpdateChallenge(ctx, ch); err != nil {
+		return acme.WrapErrorISE(err, "error updating challenge")
+	}
+
+	// The validator owns its own copy of the challenge.
+	chCopy := *ch
+	h.validator.Validate(h.db, &chCopy, jwk, vo, az.ExpiresAt, h.challengeValidated(provName))
+	return nil
+}
+
+// challengeValidated returns a function that reports the result of a
+// challenge validation to the meter, if any.
+func (h *Handler) challengeValidated(provName string) func(*acme.Challenge) {
+	return func(ch *acme.Challenge) {
+		if h.meter != nil {
+			h.meter.ChallengeValidated(provName, string(ch.Type), string(ch.Status))
+		}
+	}
+}
+
 // GetCertificate ACME api for retrieving a Certificate.
 func (h *Handler) GetCertificate(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
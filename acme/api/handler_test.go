@@ -91,11 +91,12 @@ func TestHandler_GetDirectory(t *testing.T) {
 			ctx := context.WithValue(context.Background(), provisionerContextKey, prov)
 			ctx = context.WithValue(ctx, baseURLContextKey, baseURL)
 			expDir := Directory{
-				NewNonce:   fmt.Sprintf("%s/acme/%s/new-nonce", baseURL.String(), provName),
-				NewAccount: fmt.Sprintf("%s/acme/%s/new-account", baseURL.String(), provName),
-				NewOrder:   fmt.Sprintf("%s/acme/%s/new-order", baseURL.String(), provName),
-				RevokeCert: fmt.Sprintf("%s/acme/%s/revoke-cert", baseURL.String(), provName),
-				KeyChange:  fmt.Sprintf("%s/acme/%s/key-change", baseURL.String(), provName),
+				NewNonce:    fmt.Sprintf("%s/acme/%s/new-nonce", baseURL.String(), provName),
+				NewAccount:  fmt.Sprintf("%s/acme/%s/new-account", baseURL.String(), provName),
+				NewOrder:    fmt.Sprintf("%s/acme/%s/new-order", baseURL.String(), provName),
+				RevokeCert:  fmt.Sprintf("%s/acme/%s/revoke-cert", baseURL.String(), provName),
+				KeyChange:   fmt.Sprintf("%s/acme/%s/key-change", baseURL.String(), provName),
+				RenewalInfo: fmt.Sprintf("%s/acme/%s/renewal-info", baseURL.String(), provName),
 			}
 			return test{
 				ctx:        ctx,
@@ -111,11 +112,12 @@ func TestHandler_GetDirectory(t *testing.T) {
 			ctx := context.WithValue(context.Background(), provisionerContextKey, prov)
 			ctx = context.WithValue(ctx, baseURLContextKey, baseURL)
 			expDir := Directory{
-				NewNonce:   fmt.Sprintf("%s/acme/%s/new-nonce", baseURL.String(), provName),
-				NewAccount: fmt.Sprintf("%s/acme/%s/new-account", baseURL.String(), provName),
-				NewOrder:   fmt.Sprintf("%s/acme/%s/new-order", baseURL.String(), provName),
-				RevokeCert: fmt.Sprintf("%s/acme/%s/revoke-cert", baseURL.String(), provName),
-				KeyChange:  fmt.Sprintf("%s/acme/%s/key-change", baseURL.String(), provName),
+				NewNonce:    fmt.Sprintf("%s/acme/%s/new-nonce", baseURL.String(), provName),
+				NewAccount:  fmt.Sprintf("%s/acme/%s/new-account", baseURL.String(), provName),
+				NewOrder:    fmt.Sprintf("%s/acme/%s/new-order", baseURL.String(), provName),
+				RevokeCert:  fmt.Sprintf("%s/acme/%s/revoke-cert", baseURL.String(), provName),
+				KeyChange:   fmt.Sprintf("%s/acme/%s/key-change", baseURL.String(), provName),
+				RenewalInfo: fmt.Sprintf("%s/acme/%s/renewal-info", baseURL.String(), provName),
 				Meta: Meta{
 					ExternalAccountRequired: true,
 				},
@@ -492,9 +494,11 @@ func TestHandler_GetChallenge(t *testing.T) {
 	type test struct {
 		db         acme.DB
 		vco        *acme.ValidateChallengeOptions
+		validator  *acme.Validator
 		ctx        context.Context
 		statusCode int
 		ch         *acme.Challenge
+		retryAfter []string
 		err        *acme.Error
 	}
 	var tests = map[string]func(t *testing.T) test{
@@ -649,6 +653,34 @@ func TestHandler_GetChallenge(t *testing.T) {
 				err:        acme.NewErrorISE("force"),
 			}
 		},
+		"fail/challenge-not-enabled": func(t *testing.T) test {
+			acc := &acme.Account{ID: "accID"}
+			p := newACMEProv(t)
+			p.Challenges = []provisioner.ACMEChallenge{provisioner.DNS_01}
+			ctx := context.WithValue(context.Background(), provisionerContextKey, p)
+			ctx = context.WithValue(ctx, accContextKey, acc)
+			ctx = context.WithValue(ctx, payloadContextKey, &payloadInfo{isEmptyJSON: true})
+			_jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+			assert.FatalError(t, err)
+			_pub := _jwk.Public()
+			ctx = context.WithValue(ctx, jwkContextKey, &_pub)
+			ctx = context.WithValue(ctx, chi.RouteCtxKey, chiCtx)
+			return test{
+				db: &acme.MockDB{
+					MockGetChallenge: func(ctx context.Context, chID, azID string) (*acme.Challenge, error) {
+						return &acme.Challenge{
+							Status:    acme.StatusPending,
+							Type:      acme.HTTP01,
+							AccountID: "accID",
+						}, nil
+					},
+				},
+				ctx:        ctx,
+				statusCode: 400,
+				err: acme.NewError(acme.ErrorMalformedType,
+					"challenge type http-01 is not enabled in provisioner %s", p.GetName()),
+			}
+		},
 		"ok": func(t *testing.T) test {
 			acc := &acme.Account{ID: "accID"}
 			ctx := context.WithValue(context.Background(), provisionerContextKey, prov)
@@ -699,11 +731,167 @@ func TestHandler_GetChallenge(t *testing.T) {
 				statusCode: 200,
 			}
 		},
+		"fail/async-get-authorization-error": func(t *testing.T) test {
+			acc := &acme.Account{ID: "accID"}
+			ctx := context.WithValue(context.Background(), provisionerContextKey, prov)
+			ctx = context.WithValue(ctx, accContextKey, acc)
+			ctx = context.WithValue(ctx, payloadContextKey, &payloadInfo{isEmptyJSON: true})
+			_jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+			assert.FatalError(t, err)
+			_pub := _jwk.Public()
+			ctx = context.WithValue(ctx, jwkContextKey, &_pub)
+			ctx = context.WithValue(ctx, chi.RouteCtxKey, chiCtx)
+			return test{
+				db: &acme.MockDB{
+					MockGetChallenge: func(ctx context.Context, chID, azID string) (*acme.Challenge, error) {
+						return &acme.Challenge{
+							ID:        "chID",
+							Status:    acme.StatusPending,
+							Type:      acme.HTTP01,
+							AccountID: "accID",
+						}, nil
+					},
+					MockGetAuthorization: func(ctx context.Context, id string) (*acme.Authorization, error) {
+						assert.Equals(t, id, "authzID")
+						return nil, errors.New("force")
+					},
+				},
+				validator:  acme.NewValidator(1),
+				ctx:        ctx,
+				statusCode: 500,
+				err:        acme.NewErrorISE("error retrieving authorization: force"),
+			}
+		},
+		"fail/async-update-challenge-error": func(t *testing.T) test {
+			acc := &acme.Account{ID: "accID"}
+			ctx := context.WithValue(context.Background(), provisionerContextKey, prov)
+			ctx = context.WithValue(ctx, accContextKey, acc)
+			ctx = context.WithValue(ctx, payloadContextKey, &payloadInfo{isEmptyJSON: true})
+			_jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+			assert.FatalError(t, err)
+			_pub := _jwk.Public()
+			ctx = context.WithValue(ctx, jwkContextKey, &_pub)
+			ctx = context.WithValue(ctx, chi.RouteCtxKey, chiCtx)
+			return test{
+				db: &acme.MockDB{
+					MockGetChallenge: func(ctx context.Context, chID, azID string) (*acme.Challenge, error) {
+						return &acme.Challenge{
+							ID:        "chID",
+							Status:    acme.StatusPending,
+							Type:      acme.HTTP01,
+							AccountID: "accID",
+						}, nil
+					},
+					MockGetAuthorization: func(ctx context.Context, id string) (*acme.Authorization, error) {
+						return &acme.Authorization{ID: "authzID", ExpiresAt: time.Now().Add(time.Hour)}, nil
+					},
+					MockUpdateChallenge: func(ctx context.Context, ch *acme.Challenge) error {
+						assert.Equals(t, ch.Status, acme.StatusProcessing)
+						return errors.New("force")
+					},
+				},
+				validator:  acme.NewValidator(1),
+				ctx:        ctx,
+				statusCode: 500,
+				err:        acme.NewErrorISE("error updating challenge: force"),
+			}
+		},
+		"ok/async": func(t *testing.T) test {
+			acc := &acme.Account{ID: "accID"}
+			ctx := context.WithValue(context.Background(), provisionerContextKey, prov)
+			ctx = context.WithValue(ctx, accContextKey, acc)
+			ctx = context.WithValue(ctx, payloadContextKey, &payloadInfo{isEmptyJSON: true})
+			_jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+			assert.FatalError(t, err)
+			_pub := _jwk.Public()
+			ctx = context.WithValue(ctx, jwkContextKey, &_pub)
+			ctx = context.WithValue(ctx, baseURLContextKey, baseURL)
+			ctx = context.WithValue(ctx, chi.RouteCtxKey, chiCtx)
+			// A stopped validator keeps the validation queued.
+			v := acme.NewValidator(1)
+			v.Stop()
+			return test{
+				db: &acme.MockDB{
+					MockGetChallenge: func(ctx context.Context, chID, azID string) (*acme.Challenge, error) {
+						return &acme.Challenge{
+							ID:        "chID",
+							Status:    acme.StatusPending,
+							Type:      acme.HTTP01,
+							AccountID: "accID",
+						}, nil
+					},
+					MockGetAuthorization: func(ctx context.Context, id string) (*acme.Authorization, error) {
+						return &acme.Authorization{ID: "authzID", ExpiresAt: time.Now().Add(time.Hour)}, nil
+					},
+					MockUpdateChallenge: func(ctx context.Context, ch *acme.Challenge) error {
+						assert.Equals(t, ch.ID, "chID")
+						assert.Equals(t, ch.Status, acme.StatusProcessing)
+						assert.Equals(t, ch.AuthorizationID, "authzID")
+						return nil
+					},
+				},
+				ch: &acme.Challenge{
+					ID:              "chID",
+					Status:          acme.StatusProcessing,
+					AuthorizationID: "authzID",
+					Type:            acme.HTTP01,
+					AccountID:       "accID",
+					URL:             u,
+				},
+				vco: &acme.ValidateChallengeOptions{
+					RetryInterval: 10 * time.Second,
+				},
+				validator:  v,
+				ctx:        ctx,
+				statusCode: 200,
+				retryAfter: []string{"10"},
+			}
+		},
+		"ok/async-already-processing": func(t *testing.T) test {
+			acc := &acme.Account{ID: "accID"}
+			ctx := context.WithValue(context.Background(), provisionerContextKey, prov)
+			ctx = context.WithValue(ctx, accContextKey, acc)
+			ctx = context.WithValue(ctx, payloadContextKey, &payloadInfo{isEmptyJSON: true})
+			_jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+			assert.FatalError(t, err)
+			_pub := _jwk.Public()
+			ctx = context.WithValue(ctx, jwkContextKey, &_pub)
+			ctx = context.WithValue(ctx, baseURLContextKey, baseURL)
+			ctx = context.WithValue(ctx, chi.RouteCtxKey, chiCtx)
+			v := acme.NewValidator(1)
+			v.Stop()
+			assert.True(t, v.Validate(&acme.MockDB{}, &acme.Challenge{ID: "chID"}, &_pub, nil, time.Time{}))
+			return test{
+				db: &acme.MockDB{
+					MockGetChallenge: func(ctx context.Context, chID, azID string) (*acme.Challenge, error) {
+						return &acme.Challenge{
+							ID:        "chID",
+							Status:    acme.StatusProcessing,
+							Type:      acme.HTTP01,
+							AccountID: "accID",
+						}, nil
+					},
+				},
+				ch: &acme.Challenge{
+					ID:              "chID",
+					Status:          acme.StatusProcessing,
+					AuthorizationID: "authzID",
+					Type:            acme.HTTP01,
+					AccountID:       "accID",
+					URL:             u,
+				},
+				vco:        &acme.ValidateChallengeOptions{},
+				validator:  v,
+				ctx:        ctx,
+				statusCode: 200,
+				retryAfter: []string{"5"},
+			}
+		},
 	}
 	for name, run := range tests {
 		tc := run(t)
 		t.Run(name, func(t *testing.T) {
-			h := &Handler{db: tc.db, linker: NewLinker("dns", "acme"), validateChallengeOptions: tc.vco}
+			h := &Handler{db: tc.db, linker: NewLinker("dns", "acme"), validateChallengeOptions: tc.vco, validator: tc.validator}
 			req := httptest.NewRequest("GET", u, nil)
 			req = req.WithContext(tc.ctx)
 			w := httptest.NewRecorder()
@@ -731,8 +919,47 @@ func TestHandler_GetChallenge(t *testing.T) {
 				assert.Equals(t, bytes.TrimSpace(body), expB)
 				assert.Equals(t, res.Header["Link"], []string{fmt.Sprintf("<%s/acme/%s/authz/%s>;rel=\"up\"", baseURL, provName, "authzID")})
 				assert.Equals(t, res.Header["Location"], []string{u})
+				assert.Equals(t, res.Header["Retry-After"], tc.retryAfter)
 				assert.Equals(t, res.Header["Content-Type"], []string{"application/json"})
 			}
 		})
 	}
 }
+
+func TestHandler_getValidateChallengeOptions(t *testing.T) {
+	defaults := acme.NewValidateChallengeOptions(nil)
+	o1 := &provisioner.ACMEChallengeOptions{HTTP01Port: 8080}
+	o2 := &provisioner.ACMEChallengeOptions{HTTP01Port: 8081}
+	o3 := &provisioner.ACMEChallengeOptions{HTTP01Port: 8082}
+	newProvisioner := func(id string, o *provisioner.ACMEChallengeOptions) acme.Provisioner {
+		return &acme.MockProvisioner{
+			MgetID:               func() string { return id },
+			MgetChallengeOptions: func() *provisioner.ACMEChallengeOptions { return o },
+		}
+	}
+	h := &Handler{validateChallengeOptions: defaults}
+
+	assert.True(t, defaults == h.getValidateChallengeOptions(newProvisioner("prov-1", nil)))
+
+	vo1 := h.getValidateChallengeOptions(newProvisioner("prov-1", o1))
+	assert.Equals(t, 8080, vo1.HTTP01Port)
+	assert.True(t, vo1 == h.getValidateChallengeOptions(newProvisioner("prov-1", o1)))
+
+	vo3 := h.getValidateChallengeOptions(newProvisioner("prov-2", o3))
+	assert.Equals(t, 8082, vo3.HTTP01Port)
+	assert.True(t, vo1 != vo3)
+
+	// A reload of the provisioner replaces its options.
+	vo2 := h.getValidateChallengeOptions(newProvisioner("prov-1", o2))
+	assert.Equals(t, 8081, vo2.HTTP01Port)
+	assert.True(t, vo1 != vo2)
+	assert.True(t, vo2 == h.getValidateChallengeOptions(newProvisioner("prov-1", o2)))
+	assert.True(t, vo3 == h.getValidateChallengeOptions(newProvisioner("prov-2", o3)))
+
+	var n int
+	h.challengeOptions.Range(func(_, _ interface{}) bool {
+		n++
+		return true
+	})
+	assert.Equals(t, 2, n)
+}
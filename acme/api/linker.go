@@ -59,6 +59,13 @@ func (l *linker) GetUnescapedPathSuffix(typ LinkType, provisionerName string, in
 		return fmt.Sprintf("/%s/%s/%s/orders", provisionerName, AccountLinkType, inputs[0])
 	case FinalizeLinkType:
 		return fmt.Sprintf("/%s/%s/%s/finalize", provisionerName, OrderLinkType, inputs[0])
+	case RenewalInfoLinkType:
+		// The directory exposes the base URL, the ARI certificate ID is
+		// appended by the clients.
+		if len(inputs) == 0 {
+			return fmt.Sprintf("/%s/%s", provisionerName, typ)
+		}
+		return fmt.Sprintf("/%s/%s/%s", provisionerName, typ, inputs[0])
 	default:
 		return ""
 	}
@@ -127,6 +134,8 @@ const (
 	RevokeCertLinkType
 	// KeyChangeLinkType key rollover
 	KeyChangeLinkType
+	// RenewalInfoLinkType renewal information
+	RenewalInfoLinkType
 )
 
 func (l LinkType) String() string {
@@ -155,6 +164,8 @@ func (l LinkType) String() string {
 		return "revoke-cert"
 	case KeyChangeLinkType:
 		return "key-change"
+	case RenewalInfoLinkType:
+		return "renewal-info"
 	default:
 		return fmt.Sprintf("unexpected LinkType '%d'", int(l))
 	}
@@ -16,6 +16,7 @@ import (
 
 	"github.com/smallstep/certificates/acme"
 	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority/provisioner"
 )
 
 // NewOrderRequest represents the body for a NewOrder request.
@@ -31,12 +32,20 @@ func (n *NewOrderRequest) Validate() error {
 		return acme.NewError(acme.ErrorMalformedType, "identifiers list cannot be empty")
 	}
 	for _, id := range n.Identifiers {
-		if !(id.Type == acme.DNS || id.Type == acme.IP) {
+		if !(id.Type == acme.DNS || id.Type == acme.IP || id.Type == acme.PermanentIdentifier) {
 			return acme.NewError(acme.ErrorMalformedType, "identifier type unsupported: %s", id.Type)
 		}
 		if id.Type == acme.IP && net.ParseIP(id.Value) == nil {
 			return acme.NewError(acme.ErrorMalformedType, "invalid IP address: %s", id.Value)
 		}
+		if id.Type == acme.PermanentIdentifier {
+			if id.Value == "" {
+				return acme.NewError(acme.ErrorMalformedType, "permanent identifier cannot be empty")
+			}
+			if len(n.Identifiers) > 1 {
+				return acme.NewError(acme.ErrorMalformedType, "permanent identifier cannot be combined with other identifiers")
+			}
+		}
 	}
 	return nil
 }
@@ -97,6 +106,16 @@ func (h *Handler) NewOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Permanent identifiers can only be validated using the device-attest-01
+	// challenge, and it requires the attestation roots.
+	if nor.Identifiers[0].Type == acme.PermanentIdentifier {
+		if _, ok := prov.GetAttestationRoots(); !ok {
+			render.Error(w, acme.NewError(acme.ErrorRejectedIdentifierType,
+				"provisioner %s does not support permanent identifiers", prov.GetName()))
+			return
+		}
+	}
+
 	now := clock.Now()
 	// New order.
 	o := &acme.Order{
@@ -156,9 +175,23 @@ func (h *Handler) newAuthorization(ctx context.Context, az *acme.Authorization)
 		}
 	}
 
-	chTypes := challengeTypes(az)
+	prov, err := provisionerFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Use only the challenges enabled in the provisioner.
+	var chTypes []acme.ChallengeType
+	for _, typ := range challengeTypes(az) {
+		if prov.IsChallengeEnabled(ctx, provisioner.ACMEChallenge(typ)) {
+			chTypes = append(chTypes, typ)
+		}
+	}
+	if len(chTypes) == 0 {
+		return acme.NewError(acme.ErrorRejectedIdentifierType,
+			"provisioner %s does not have any challenge enabled for identifier %s", prov.GetName(), az.Identifier.Value)
+	}
 
-	var err error
 	az.Token, err = randutil.Alphanumeric(32)
 	if err != nil {
 		return acme.WrapErrorISE(err, "error generating random alphanumeric ID")
@@ -266,7 +299,11 @@ func (h *Handler) FinalizeOrder(w http.ResponseWriter, r *http.Request) {
 			"provisioner '%s' does not own order '%s'", prov.GetID(), o.ID))
 		return
 	}
-	if err = o.Finalize(ctx, h.db, fr.csr, h.ca, prov); err != nil {
+	err = o.Finalize(ctx, h.db, fr.csr, h.ca, prov)
+	if h.meter != nil {
+		h.meter.OrderFinalized(prov.GetName(), err)
+	}
+	if err != nil {
 		render.Error(w, acme.WrapErrorISE(err, "error finalizing order"))
 		return
 	}
@@ -291,6 +328,8 @@ func challengeTypes(az *acme.Authorization) []acme.ChallengeType {
 		if !az.Wildcard {
 			chTypes = append(chTypes, []acme.ChallengeType{acme.HTTP01, acme.TLSALPN01}...)
 		}
+	case acme.PermanentIdentifier:
+		chTypes = []acme.ChallengeType{acme.DEVICEATTEST01}
 	default:
 		chTypes = []acme.ChallengeType{}
 	}
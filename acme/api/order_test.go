@@ -18,6 +18,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/smallstep/assert"
 	"github.com/smallstep/certificates/acme"
+	"github.com/smallstep/certificates/authority/provisioner"
 	"go.step.sm/crypto/pemutil"
 )
 
@@ -61,6 +62,42 @@ func TestNewOrderRequest_Validate(t *testing.T) {
 				err: acme.NewError(acme.ErrorMalformedType, "invalid IP address: %s", "192.168.42.1000"),
 			}
 		},
+		"fail/empty-permanent-identifier": func(t *testing.T) test {
+			return test{
+				nor: &NewOrderRequest{
+					Identifiers: []acme.Identifier{
+						{Type: "permanent-identifier", Value: ""},
+					},
+				},
+				err: acme.NewError(acme.ErrorMalformedType, "permanent identifier cannot be empty"),
+			}
+		},
+		"fail/combined-permanent-identifier": func(t *testing.T) test {
+			return test{
+				nor: &NewOrderRequest{
+					Identifiers: []acme.Identifier{
+						{Type: "dns", Value: "example.com"},
+						{Type: "permanent-identifier", Value: "12345678"},
+					},
+				},
+				err: acme.NewError(acme.ErrorMalformedType, "permanent identifier cannot be combined with other identifiers"),
+			}
+		},
+		"ok/permanent-identifier": func(t *testing.T) test {
+			nbf := time.Now().UTC().Add(time.Minute)
+			naf := time.Now().UTC().Add(5 * time.Minute)
+			return test{
+				nor: &NewOrderRequest{
+					Identifiers: []acme.Identifier{
+						{Type: "permanent-identifier", Value: "12345678"},
+					},
+					NotAfter:  naf,
+					NotBefore: nbf,
+				},
+				nbf: nbf,
+				naf: naf,
+			}
+		},
 		"ok": func(t *testing.T) test {
 			nbf := time.Now().UTC().Add(time.Minute)
 			naf := time.Now().UTC().Add(5 * time.Minute)
@@ -457,9 +494,10 @@ func TestHandler_GetOrder(t *testing.T) {
 
 func TestHandler_newAuthorization(t *testing.T) {
 	type test struct {
-		az  *acme.Authorization
-		db  acme.DB
-		err *acme.Error
+		az   *acme.Authorization
+		prov acme.Provisioner
+		db   acme.DB
+		err  *acme.Error
 	}
 	var tests = map[string]func(t *testing.T) test{
 		"fail/error-db.CreateChallenge": func(t *testing.T) test {
@@ -592,6 +630,54 @@ func TestHandler_newAuthorization(t *testing.T) {
 				az: az,
 			}
 		},
+		"fail/no-challenges-enabled": func(t *testing.T) test {
+			az := &acme.Authorization{
+				AccountID: "accID",
+				Identifier: acme.Identifier{
+					Type:  "dns",
+					Value: "*.zap.internal",
+				},
+			}
+			p := newACMEProv(t)
+			p.Challenges = []provisioner.ACMEChallenge{provisioner.HTTP_01, provisioner.TLS_ALPN_01}
+			return test{
+				prov: p,
+				db:   &acme.MockDB{},
+				az:   az,
+				err: acme.NewError(acme.ErrorRejectedIdentifierType,
+					"provisioner %s does not have any challenge enabled for identifier zap.internal", p.GetName()),
+			}
+		},
+		"ok/challenges-enabled": func(t *testing.T) test {
+			az := &acme.Authorization{
+				AccountID: "accID",
+				Identifier: acme.Identifier{
+					Type:  "dns",
+					Value: "zap.internal",
+				},
+				Status:    acme.StatusPending,
+				ExpiresAt: clock.Now(),
+			}
+			p := newACMEProv(t)
+			p.Challenges = []provisioner.ACMEChallenge{provisioner.DNS_01}
+			var ch1 **acme.Challenge
+			return test{
+				prov: p,
+				db: &acme.MockDB{
+					MockCreateChallenge: func(ctx context.Context, ch *acme.Challenge) error {
+						ch.ID = "dns"
+						assert.Equals(t, ch.Type, acme.DNS01)
+						ch1 = &ch
+						return nil
+					},
+					MockCreateAuthorization: func(ctx context.Context, _az *acme.Authorization) error {
+						assert.Equals(t, _az.Challenges, []*acme.Challenge{*ch1})
+						return nil
+					},
+				},
+				az: az,
+			}
+		},
 		"ok/wildcard": func(t *testing.T) test {
 			az := &acme.Authorization{
 				AccountID: "accID",
@@ -636,8 +722,12 @@ func TestHandler_newAuthorization(t *testing.T) {
 	for name, run := range tests {
 		t.Run(name, func(t *testing.T) {
 			tc := run(t)
+			if tc.prov == nil {
+				tc.prov = newProv()
+			}
+			ctx := context.WithValue(context.Background(), provisionerContextKey, tc.prov)
 			h := &Handler{db: tc.db}
-			if err := h.newAuthorization(context.Background(), tc.az); err != nil {
+			if err := h.newAuthorization(ctx, tc.az); err != nil {
 				if assert.NotNil(t, tc.err) {
 					switch k := err.(type) {
 					case *acme.Error:
@@ -756,6 +846,24 @@ func TestHandler_NewOrder(t *testing.T) {
 				err:        acme.NewError(acme.ErrorMalformedType, "identifiers list cannot be empty"),
 			}
 		},
+		"fail/permanent-identifier-not-supported": func(t *testing.T) test {
+			acc := &acme.Account{ID: "accID"}
+			fr := &NewOrderRequest{
+				Identifiers: []acme.Identifier{
+					{Type: "permanent-identifier", Value: "12345678"},
+				},
+			}
+			b, err := json.Marshal(fr)
+			assert.FatalError(t, err)
+			ctx := context.WithValue(context.Background(), provisionerContextKey, prov)
+			ctx = context.WithValue(ctx, accContextKey, acc)
+			ctx = context.WithValue(ctx, payloadContextKey, &payloadInfo{value: b})
+			return test{
+				ctx:        ctx,
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorRejectedIdentifierType, "provisioner %s does not support permanent identifiers", prov.GetName()),
+			}
+		},
 		"fail/error-h.newAuthorization": func(t *testing.T) test {
 			acc := &acme.Account{ID: "accID"}
 			fr := &NewOrderRequest{
@@ -1700,6 +1808,16 @@ func TestHandler_challengeTypes(t *testing.T) {
 			},
 			want: []acme.ChallengeType{acme.HTTP01, acme.TLSALPN01},
 		},
+		{
+			name: "ok/permanent-identifier",
+			args: args{
+				az: &acme.Authorization{
+					Identifier: acme.Identifier{Type: "permanent-identifier", Value: "12345678"},
+					Wildcard:   false,
+				},
+			},
+			want: []acme.ChallengeType{acme.DEVICEATTEST01},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/certificates/acme"
+	"github.com/smallstep/certificates/api/render"
+)
+
+// renewalInfoRetryAfter is the time clients should wait before requesting
+// the renewal information of a certificate again.
+const renewalInfoRetryAfter = 6 * time.Hour
+
+// SuggestedWindow is the time window in which a client should renew a
+// certificate.
+type SuggestedWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// RenewalInfo is the ACME Renewal Information (ARI) resource defined in
+// draft-ietf-acme-ari.
+type RenewalInfo struct {
+	SuggestedWindow SuggestedWindow `json:"suggestedWindow"`
+}
+
+// ToLog enables response logging for the RenewalInfo type.
+func (ri *RenewalInfo) ToLog() (interface{}, error) {
+	b, err := json.Marshal(ri)
+	if err != nil {
+		return nil, acme.WrapErrorISE(err, "error marshaling renewal info for logging")
+	}
+	return string(b), nil
+}
+
+// parseRenewalInfoCertID parses an ARI certificate identifier and returns the
+// authority key identifier and the serial number of the certificate. The
+// identifier is composed by the base64url encoded key identifier and the
+// base64url encoded serial number, without padding and separated by a dot.
+func parseRenewalInfoCertID(certID string) ([]byte, *big.Int, error) {
+	parts := strings.Split(certID, ".")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, nil, errors.Errorf("certificate identifier %s is not valid", certID)
+	}
+	aki, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error decoding authority key identifier")
+	}
+	sn, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error decoding serial number")
+	}
+	// The serial number is the DER encoded integer without the tag and length,
+	// a positive number will never have the most significant bit set.
+	if sn[0]&0x80 != 0 {
+		return nil, nil, errors.New("serial number must be a positive number")
+	}
+	return aki, new(big.Int).SetBytes(sn), nil
+}
+
+// suggestedWindow returns the renewal window for the given certificate. By
+// default, the window starts when two thirds of the validity period have
+// elapsed and ends halfway through the remaining time. If the certificate must
+// be renewed now, the window will be in the past, so clients renew it
+// immediately.
+func suggestedWindow(cert *x509.Certificate, renewNow bool, now time.Time) SuggestedWindow {
+	if renewNow {
+		return SuggestedWindow{
+			Start: now.Add(-time.Hour),
+			End:   now,
+		}
+	}
+	lifetime := cert.NotAfter.Sub(cert.NotBefore)
+	return SuggestedWindow{
+		Start: cert.NotAfter.Add(-lifetime / 3).UTC().Truncate(time.Second),
+		End:   cert.NotAfter.Add(-lifetime / 6).UTC().Truncate(time.Second),
+	}
+}
+
+// GetRenewalInfo is the ACME resource returning the suggested renewal window of
+// a certificate. The window will be in the past if the certificate has been
+// revoked, or if it has been issued before the renewEarlyBefore time
+// configured in the provisioner.
+func (h *Handler) GetRenewalInfo(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	acmeProv, err := acmeProvisionerFromContext(ctx)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	aki, serialNumber, err := parseRenewalInfoCertID(chi.URLParam(r, "certID"))
+	if err != nil {
+		render.Error(w, acme.WrapError(acme.ErrorMalformedType, err, "error parsing certificate identifier"))
+		return
+	}
+
+	serial := serialNumber.String()
+	dbCert, err := h.db.GetCertificateBySerial(ctx, serial)
+	if err != nil {
+		if acmeErr, ok := err.(*acme.Error); ok {
+			acmeErr.Status = http.StatusNotFound
+			render.Error(w, acmeErr)
+		} else {
+			render.Error(w, acme.WrapErrorISE(err, "error retrieving certificate by serial"))
+		}
+		return
+	}
+	if !bytes.Equal(dbCert.Leaf.AuthorityKeyId, aki) {
+		acmeErr := acme.NewError(acme.ErrorMalformedType, "certificate with serial %s not found", serial)
+		acmeErr.Status = http.StatusNotFound
+		render.Error(w, acmeErr)
+		return
+	}
+
+	isRevoked, err := h.ca.IsRevoked(serial)
+	if err != nil {
+		render.Error(w, acme.WrapErrorISE(err, "error retrieving revocation status of certificate"))
+		return
+	}
+	renewNow := isRevoked ||
+		(acmeProv.RenewEarlyBefore != nil && dbCert.Leaf.NotBefore.Before(*acmeProv.RenewEarlyBefore))
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(renewalInfoRetryAfter.Seconds())))
+	render.JSON(w, &RenewalInfo{
+		SuggestedWindow: suggestedWindow(dbCert.Leaf, renewNow, clock.Now()),
+	})
+}
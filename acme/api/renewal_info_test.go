@@ -0,0 +1,316 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/acme"
+	"go.step.sm/crypto/pemutil"
+)
+
+func renewalInfoCertID(cert *x509.Certificate) string {
+	return base64.RawURLEncoding.EncodeToString(cert.AuthorityKeyId) + "." +
+		base64.RawURLEncoding.EncodeToString(cert.SerialNumber.Bytes())
+}
+
+func Test_parseRenewalInfoCertID(t *testing.T) {
+	type args struct {
+		certID string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantAKI []byte
+		wantSN  *big.Int
+		wantErr bool
+	}{
+		{"ok", args{"aGVsbG8.AQI"}, []byte("hello"), big.NewInt(258), false},
+		{"ok/leading zero", args{"aGVsbG8.AIA"}, []byte("hello"), big.NewInt(128), false},
+		{"fail/empty", args{""}, nil, nil, true},
+		{"fail/no dot", args{"aGVsbG8"}, nil, nil, true},
+		{"fail/too many dots", args{"aGVsbG8.AQI.AQI"}, nil, nil, true},
+		{"fail/empty aki", args{".AQI"}, nil, nil, true},
+		{"fail/empty serial", args{"aGVsbG8."}, nil, nil, true},
+		{"fail/aki", args{"aGVsbG8=.AQI"}, nil, nil, true},
+		{"fail/serial", args{"aGVsbG8.AQI="}, nil, nil, true},
+		{"fail/negative serial", args{"aGVsbG8.gA"}, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aki, sn, err := parseRenewalInfoCertID(tt.args.certID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseRenewalInfoCertID() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(aki, tt.wantAKI) {
+				t.Errorf("parseRenewalInfoCertID() aki = %v, want %v", aki, tt.wantAKI)
+			}
+			if !reflect.DeepEqual(sn, tt.wantSN) {
+				t.Errorf("parseRenewalInfoCertID() serial = %v, want %v", sn, tt.wantSN)
+			}
+		})
+	}
+}
+
+func Test_suggestedWindow(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	cert := &x509.Certificate{
+		NotBefore: now.Add(-24 * time.Hour),
+		NotAfter:  now.Add(24 * time.Hour),
+	}
+	type args struct {
+		cert     *x509.Certificate
+		renewNow bool
+		now      time.Time
+	}
+	tests := []struct {
+		name string
+		args args
+		want SuggestedWindow
+	}{
+		{"ok", args{cert, false, now}, SuggestedWindow{
+			Start: now.Add(8 * time.Hour),
+			End:   now.Add(16 * time.Hour),
+		}},
+		{"ok/renew now", args{cert, true, now}, SuggestedWindow{
+			Start: now.Add(-time.Hour),
+			End:   now,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := suggestedWindow(tt.args.cert, tt.args.renewNow, tt.args.now); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("suggestedWindow() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_GetRenewalInfo(t *testing.T) {
+	leaf, err := pemutil.ReadCertificate("../../authority/testdata/certs/foo.crt")
+	assert.FatalError(t, err)
+
+	certID := renewalInfoCertID(leaf)
+	serial := leaf.SerialNumber.String()
+	lifetime := leaf.NotAfter.Sub(leaf.NotBefore)
+
+	prov := newProv()
+	provName := url.PathEscape(prov.GetName())
+	baseURL := &url.URL{Scheme: "https", Host: "test.ca.smallstep.com"}
+	u := fmt.Sprintf("%s/acme/%s/renewal-info/%s", baseURL.String(), provName, certID)
+
+	chiContext := func(certID string) context.Context {
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("certID", certID)
+		ctx := context.WithValue(context.Background(), provisionerContextKey, prov)
+		return context.WithValue(ctx, chi.RouteCtxKey, chiCtx)
+	}
+	mockGetCertificateBySerial := func(t *testing.T) func(ctx context.Context, serial string) (*acme.Certificate, error) {
+		return func(ctx context.Context, sn string) (*acme.Certificate, error) {
+			assert.Equals(t, sn, serial)
+			return &acme.Certificate{ID: "certID", Leaf: leaf}, nil
+		}
+	}
+
+	type test struct {
+		db         acme.DB
+		ca         acme.CertificateAuthority
+		ctx        context.Context
+		statusCode int
+		window     *SuggestedWindow
+		renewNow   bool
+		err        *acme.Error
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/no-provisioner": func(t *testing.T) test {
+			return test{
+				db:         &acme.MockDB{},
+				ca:         &mockCA{},
+				ctx:        context.Background(),
+				statusCode: 500,
+				err:        acme.NewErrorISE("provisioner expected in request context"),
+			}
+		},
+		"fail/parse-cert-id": func(t *testing.T) test {
+			return test{
+				db:         &acme.MockDB{},
+				ca:         &mockCA{},
+				ctx:        chiContext("not-valid"),
+				statusCode: 400,
+				err:        acme.NewError(acme.ErrorMalformedType, "error parsing certificate identifier"),
+			}
+		},
+		"fail/db.GetCertificateBySerial-not-found": func(t *testing.T) test {
+			return test{
+				db: &acme.MockDB{
+					MockGetCertificateBySerial: func(ctx context.Context, serial string) (*acme.Certificate, error) {
+						return nil, acme.NewError(acme.ErrorMalformedType, "certificate with serial %s not found", serial)
+					},
+				},
+				ca:         &mockCA{},
+				ctx:        chiContext(certID),
+				statusCode: 404,
+				err:        acme.NewError(acme.ErrorMalformedType, "certificate not found"),
+			}
+		},
+		"fail/db.GetCertificateBySerial-error": func(t *testing.T) test {
+			return test{
+				db: &acme.MockDB{
+					MockGetCertificateBySerial: func(ctx context.Context, serial string) (*acme.Certificate, error) {
+						return nil, errors.New("force")
+					},
+				},
+				ca:         &mockCA{},
+				ctx:        chiContext(certID),
+				statusCode: 500,
+				err:        acme.NewErrorISE("error retrieving certificate by serial"),
+			}
+		},
+		"fail/aki-mismatch": func(t *testing.T) test {
+			certID := base64.RawURLEncoding.EncodeToString([]byte("foo")) + "." +
+				base64.RawURLEncoding.EncodeToString(leaf.SerialNumber.Bytes())
+			return test{
+				db: &acme.MockDB{
+					MockGetCertificateBySerial: mockGetCertificateBySerial(t),
+				},
+				ca:         &mockCA{},
+				ctx:        chiContext(certID),
+				statusCode: 404,
+				err:        acme.NewError(acme.ErrorMalformedType, "certificate not found"),
+			}
+		},
+		"fail/ca.IsRevoked-error": func(t *testing.T) test {
+			return test{
+				db: &acme.MockDB{
+					MockGetCertificateBySerial: mockGetCertificateBySerial(t),
+				},
+				ca: &mockCA{
+					MockIsRevoked: func(sn string) (bool, error) {
+						return false, errors.New("force")
+					},
+				},
+				ctx:        chiContext(certID),
+				statusCode: 500,
+				err:        acme.NewErrorISE("error retrieving revocation status of certificate"),
+			}
+		},
+		"ok": func(t *testing.T) test {
+			return test{
+				db: &acme.MockDB{
+					MockGetCertificateBySerial: mockGetCertificateBySerial(t),
+				},
+				ca:         &mockCA{},
+				ctx:        chiContext(certID),
+				statusCode: 200,
+				window: &SuggestedWindow{
+					Start: leaf.NotAfter.Add(-lifetime / 3).UTC(),
+					End:   leaf.NotAfter.Add(-lifetime / 6).UTC(),
+				},
+			}
+		},
+		"ok/revoked": func(t *testing.T) test {
+			return test{
+				db: &acme.MockDB{
+					MockGetCertificateBySerial: mockGetCertificateBySerial(t),
+				},
+				ca: &mockCA{
+					MockIsRevoked: func(sn string) (bool, error) {
+						assert.Equals(t, sn, serial)
+						return true, nil
+					},
+				},
+				ctx:        chiContext(certID),
+				statusCode: 200,
+				renewNow:   true,
+			}
+		},
+		"ok/renew-early": func(t *testing.T) test {
+			acmeProv := newACMEProv(t)
+			renewEarlyBefore := leaf.NotBefore.Add(time.Minute)
+			acmeProv.RenewEarlyBefore = &renewEarlyBefore
+			ctx := context.WithValue(chiContext(certID), provisionerContextKey, acmeProv)
+			return test{
+				db: &acme.MockDB{
+					MockGetCertificateBySerial: mockGetCertificateBySerial(t),
+				},
+				ca:         &mockCA{},
+				ctx:        ctx,
+				statusCode: 200,
+				renewNow:   true,
+			}
+		},
+		"ok/renew-early-issued-after": func(t *testing.T) test {
+			acmeProv := newACMEProv(t)
+			renewEarlyBefore := leaf.NotBefore.Add(-time.Minute)
+			acmeProv.RenewEarlyBefore = &renewEarlyBefore
+			ctx := context.WithValue(chiContext(certID), provisionerContextKey, acmeProv)
+			return test{
+				db: &acme.MockDB{
+					MockGetCertificateBySerial: mockGetCertificateBySerial(t),
+				},
+				ca:         &mockCA{},
+				ctx:        ctx,
+				statusCode: 200,
+				window: &SuggestedWindow{
+					Start: leaf.NotAfter.Add(-lifetime / 3).UTC(),
+					End:   leaf.NotAfter.Add(-lifetime / 6).UTC(),
+				},
+			}
+		},
+	}
+	for name, run := range tests {
+		tc := run(t)
+		t.Run(name, func(t *testing.T) {
+			h := &Handler{db: tc.db, ca: tc.ca}
+			req := httptest.NewRequest("GET", u, nil)
+			req = req.WithContext(tc.ctx)
+			w := httptest.NewRecorder()
+			h.GetRenewalInfo(w, req)
+			res := w.Result()
+
+			assert.Equals(t, res.StatusCode, tc.statusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 && assert.NotNil(t, tc.err) {
+				var ae acme.Error
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &ae))
+
+				assert.Equals(t, ae.Type, tc.err.Type)
+				assert.Equals(t, ae.Detail, tc.err.Detail)
+				assert.Equals(t, ae.Identifier, tc.err.Identifier)
+				assert.Equals(t, ae.Subproblems, tc.err.Subproblems)
+				assert.Equals(t, res.Header["Content-Type"], []string{"application/problem+json"})
+			} else {
+				var ri RenewalInfo
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &ri))
+				if tc.renewNow {
+					assert.True(t, ri.SuggestedWindow.Start.Before(ri.SuggestedWindow.End))
+					assert.False(t, ri.SuggestedWindow.End.After(time.Now()))
+				} else {
+					assert.Equals(t, ri.SuggestedWindow.Start, tc.window.Start)
+					assert.Equals(t, ri.SuggestedWindow.End, tc.window.End)
+				}
+				assert.Equals(t, res.Header["Retry-After"], []string{strconv.Itoa(int(renewalInfoRetryAfter.Seconds()))})
+				assert.Equals(t, res.Header["Content-Type"], []string{"application/json"})
+			}
+		})
+	}
+}
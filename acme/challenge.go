@@ -3,9 +3,13 @@ package acme
 import (
 	"context"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/subtle"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/asn1"
 	"encoding/base64"
 	"encoding/hex"
@@ -17,10 +21,14 @@ import (
 	"net/http"
 	"net/url"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/fxamacker/cbor/v2"
 	"go.step.sm/crypto/jose"
+
+	"github.com/smallstep/certificates/authority/provisioner"
 )
 
 type ChallengeType string
@@ -32,6 +40,8 @@ const (
 	DNS01 ChallengeType = "dns-01"
 	// TLSALPN01 is the tls-alpn-01 ACME challenge type
 	TLSALPN01 ChallengeType = "tls-alpn-01"
+	// DEVICEATTEST01 is the device-attest-01 ACME challenge type
+	DEVICEATTEST01 ChallengeType = "device-attest-01"
 )
 
 // Challenge represents an ACME response Challenge type.
@@ -46,6 +56,7 @@ type Challenge struct {
 	ValidatedAt     string        `json:"validated,omitempty"`
 	URL             string        `json:"url"`
 	Error           *Error        `json:"error,omitempty"`
+	Payload         []byte        `json:"-"`
 }
 
 // ToLog enables response logging.
@@ -60,12 +71,31 @@ func (ch *Challenge) ToLog() (interface{}, error) {
 // Validate attempts to validate the challenge. Stores changes to the Challenge
 // type using the DB interface.
 // satisfactorily validated, the 'status' and 'validated' attributes are
-// updated.
-func (ch *Challenge) Validate(ctx context.Context, db DB, jwk *jose.JSONWebKey, vo *ValidateChallengeOptions) error {
+// updated. The provisioner and the request payload are only used by the
+// device-attest-01 challenge. If the validation fails with a recoverable error,
+// like a connection error, it will be retried using the configured retry
+// policy.
+func (ch *Challenge) Validate(ctx context.Context, db DB, jwk *jose.JSONWebKey, vo *ValidateChallengeOptions, p Provisioner, payload []byte) error {
 	// If already valid or invalid then return without performing validation.
 	if ch.Status != StatusPending {
 		return nil
 	}
+	attempts := vo.maxAttempts()
+	for i := 1; ; i++ {
+		err := ch.validate(ctx, db, jwk, vo, p, payload)
+		// Only pending challenges with an error can be retried.
+		if err != nil || ch.Status != StatusPending || ch.Error == nil || i >= attempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(vo.RetryAfter()):
+		}
+	}
+}
+
+func (ch *Challenge) validate(ctx context.Context, db DB, jwk *jose.JSONWebKey, vo *ValidateChallengeOptions, p Provisioner, payload []byte) error {
 	switch ch.Type {
 	case HTTP01:
 		return http01Validate(ctx, ch, db, jwk, vo)
@@ -73,13 +103,19 @@ func (ch *Challenge) Validate(ctx context.Context, db DB, jwk *jose.JSONWebKey,
 		return dns01Validate(ctx, ch, db, jwk, vo)
 	case TLSALPN01:
 		return tlsalpn01Validate(ctx, ch, db, jwk, vo)
+	case DEVICEATTEST01:
+		return deviceAttest01Validate(ctx, ch, db, jwk, p, payload)
 	default:
 		return NewErrorISE("unexpected challenge type '%s'", ch.Type)
 	}
 }
 
 func http01Validate(ctx context.Context, ch *Challenge, db DB, jwk *jose.JSONWebKey, vo *ValidateChallengeOptions) error {
-	u := &url.URL{Scheme: "http", Host: http01ChallengeHost(ch.Value), Path: fmt.Sprintf("/.well-known/acme-challenge/%s", ch.Token)}
+	host := http01ChallengeHost(ch.Value)
+	if vo.HTTP01Port > 0 {
+		host = net.JoinHostPort(ch.Value, strconv.Itoa(vo.HTTP01Port))
+	}
+	u := &url.URL{Scheme: "http", Host: host, Path: fmt.Sprintf("/.well-known/acme-challenge/%s", ch.Token)}
 
 	resp, err := vo.HTTPGet(u.String())
 	if err != nil {
@@ -295,6 +331,348 @@ func dns01Validate(ctx context.Context, ch *Challenge, db DB, jwk *jose.JSONWebK
 	return nil
 }
 
+// deviceAttestPayload is the payload sent by the clients to validate a
+// device-attest-01 challenge.
+type deviceAttestPayload struct {
+	AttObj string `json:"attObj"`
+	Error  string `json:"error"`
+}
+
+// attestationObject is the WebAuthn-style attestation object sent in the
+// device-attest-01 challenge. The statement depends on the format.
+type attestationObject struct {
+	Format       string          `cbor:"fmt"`
+	AttStatement cbor.RawMessage `cbor:"attStmt"`
+}
+
+// attestationData is the information extracted from a verified attestation
+// statement.
+type attestationData struct {
+	PermanentIdentifiers []string
+	PublicKey            crypto.PublicKey
+}
+
+func deviceAttest01Validate(ctx context.Context, ch *Challenge, db DB, jwk *jose.JSONWebKey, p Provisioner, payload []byte) error {
+	var dap deviceAttestPayload
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &dap); err != nil {
+			return WrapError(ErrorMalformedType, err, "error unmarshaling payload")
+		}
+	}
+
+	// A request without an attestation, a POST-as-GET for example, does not
+	// change the status of the challenge.
+	if dap.AttObj == "" && dap.Error == "" {
+		return nil
+	}
+	if dap.Error != "" {
+		return storeError(ctx, db, ch, true, NewError(ErrorRejectedIdentifierType,
+			"payload contained error: %s", dap.Error))
+	}
+
+	attObj, err := base64.RawURLEncoding.DecodeString(dap.AttObj)
+	if err != nil {
+		return storeError(ctx, db, ch, true, WrapError(ErrorBadAttestationStatementType, err,
+			"error base64url decoding attObj"))
+	}
+
+	keyAuth, err := KeyAuthorization(ch.Token, jwk)
+	if err != nil {
+		return err
+	}
+
+	data, err := verifyAttestationObject(attObj, p, keyAuth)
+	if err != nil {
+		return storeError(ctx, db, ch, true, WrapError(ErrorBadAttestationStatementType, err,
+			"error verifying attestation statement"))
+	}
+
+	var found bool
+	for _, id := range data.PermanentIdentifiers {
+		if id == ch.Value {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return storeError(ctx, db, ch, true, NewError(ErrorRejectedIdentifierType,
+			"permanent identifier does not match; expected %s, but got %v", ch.Value, data.PermanentIdentifiers))
+	}
+
+	// Update and store the challenge. The attestation object is kept to
+	// validate the key in the certificate request.
+	ch.Status = StatusValid
+	ch.Error = nil
+	ch.ValidatedAt = clock.Now().Format(time.RFC3339)
+	ch.Payload = attObj
+
+	if err = db.UpdateChallenge(ctx, ch); err != nil {
+		return WrapErrorISE(err, "error updating challenge")
+	}
+	return nil
+}
+
+// verifyAttestationObject decodes and verifies the attestation object using the
+// formats and roots configured in the provisioner.
+func verifyAttestationObject(attObj []byte, p Provisioner, keyAuth string) (*attestationData, error) {
+	var att attestationObject
+	if err := cbor.Unmarshal(attObj, &att); err != nil {
+		return nil, fmt.Errorf("error unmarshaling attestation object: %w", err)
+	}
+
+	format := provisioner.ACMEAttestationFormat(att.Format)
+	if !p.IsAttestationFormatEnabled(format) {
+		return nil, fmt.Errorf("attestation format %q is not enabled", att.Format)
+	}
+	roots, ok := p.GetAttestationRoots()
+	if !ok {
+		return nil, errors.New("attestation roots are not configured")
+	}
+
+	switch format {
+	case provisioner.APPLE:
+		return doAppleAttestationFormat(att.AttStatement, roots, keyAuth)
+	case provisioner.STEP:
+		return doStepAttestationFormat(att.AttStatement, roots, keyAuth)
+	case provisioner.TPM:
+		return doTPMAttestationFormat(att.AttStatement, roots, keyAuth)
+	default:
+		return nil, fmt.Errorf("unsupported attestation format %q", att.Format)
+	}
+}
+
+// attestedPublicKey returns the public key of a previously verified
+// attestation object.
+func attestedPublicKey(attObj []byte) (crypto.PublicKey, error) {
+	var att attestationObject
+	if err := cbor.Unmarshal(attObj, &att); err != nil {
+		return nil, fmt.Errorf("error unmarshaling attestation object: %w", err)
+	}
+
+	switch provisioner.ACMEAttestationFormat(att.Format) {
+	case provisioner.APPLE, provisioner.STEP:
+		var stmt struct {
+			X5C [][]byte `cbor:"x5c"`
+		}
+		if err := cbor.Unmarshal(att.AttStatement, &stmt); err != nil {
+			return nil, fmt.Errorf("error unmarshaling attestation statement: %w", err)
+		}
+		certs, err := parseX5C(stmt.X5C)
+		if err != nil {
+			return nil, err
+		}
+		return certs[0].PublicKey, nil
+	case provisioner.TPM:
+		var stmt tpmAttestationStatement
+		if err := cbor.Unmarshal(att.AttStatement, &stmt); err != nil {
+			return nil, fmt.Errorf("error unmarshaling attestation statement: %w", err)
+		}
+		pub, err := parseTPMPublic(stmt.PubArea)
+		if err != nil {
+			return nil, err
+		}
+		return pub.key, nil
+	default:
+		return nil, fmt.Errorf("unsupported attestation format %q", att.Format)
+	}
+}
+
+var (
+	oidAppleSerialNumber           = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 8, 9, 1}
+	oidAppleUniqueDeviceIdentifier = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 8, 9, 2}
+	oidAppleNonce                  = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 8, 11, 1}
+	oidYubicoSerialNumber          = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 41482, 3, 7}
+)
+
+// appleAttestationStatement is the statement used by Apple devices with
+// Managed Device Attestation.
+type appleAttestationStatement struct {
+	X5C [][]byte `cbor:"x5c"`
+}
+
+// doAppleAttestationFormat verifies an Apple attestation statement. The
+// permanent identifiers are the serial number and the UDID of the device, and
+// the nonce must be the SHA-256 of the key authorization, so the attestation
+// cannot be replayed in other challenges.
+func doAppleAttestationFormat(stmt cbor.RawMessage, roots *x509.CertPool, keyAuth string) (*attestationData, error) {
+	var att appleAttestationStatement
+	if err := cbor.Unmarshal(stmt, &att); err != nil {
+		return nil, fmt.Errorf("error unmarshaling attestation statement: %w", err)
+	}
+
+	certs, err := parseX5C(att.X5C)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := verifyX5C(certs, roots)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce []byte
+	data := &attestationData{PublicKey: leaf.PublicKey}
+	for _, ext := range leaf.Extensions {
+		switch {
+		case ext.Id.Equal(oidAppleSerialNumber), ext.Id.Equal(oidAppleUniqueDeviceIdentifier):
+			data.PermanentIdentifiers = append(data.PermanentIdentifiers, string(ext.Value))
+		case ext.Id.Equal(oidAppleNonce):
+			nonce = ext.Value
+		}
+	}
+
+	if len(nonce) == 0 {
+		return nil, errors.New("attestation nonce is missing")
+	}
+	sum := sha256.Sum256([]byte(keyAuth))
+	if subtle.ConstantTimeCompare(nonce, sum[:]) != 1 {
+		return nil, errors.New("attestation nonce does not match")
+	}
+
+	return data, nil
+}
+
+// stepAttestationStatement is the statement used by YubiKeys. The x5c contains
+// the PIV slot attestation certificate and the device attestation certificate.
+type stepAttestationStatement struct {
+	Alg int64    `cbor:"alg"`
+	Sig []byte   `cbor:"sig"`
+	X5C [][]byte `cbor:"x5c"`
+}
+
+// doStepAttestationFormat verifies a YubiKey PIV attestation statement. The
+// attested key must sign the key authorization, and the permanent identifier
+// is the serial number of the YubiKey.
+func doStepAttestationFormat(stmt cbor.RawMessage, roots *x509.CertPool, keyAuth string) (*attestationData, error) {
+	var att stepAttestationStatement
+	if err := cbor.Unmarshal(stmt, &att); err != nil {
+		return nil, fmt.Errorf("error unmarshaling attestation statement: %w", err)
+	}
+
+	certs, err := parseX5C(att.X5C)
+	if err != nil {
+		return nil, err
+	}
+	// The attestation certificate in some YubiKeys does not encode the basic
+	// constraints extension.
+	for _, cert := range certs[1:] {
+		if !cert.BasicConstraintsValid {
+			cert.BasicConstraintsValid = true
+			cert.IsCA = true
+		}
+	}
+	leaf, err := verifyX5C(certs, roots)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyCOSESignature(leaf.PublicKey, att.Alg, []byte(keyAuth), att.Sig); err != nil {
+		return nil, err
+	}
+
+	data := &attestationData{PublicKey: leaf.PublicKey}
+	for _, ext := range leaf.Extensions {
+		if ext.Id.Equal(oidYubicoSerialNumber) {
+			var serialNumber int64
+			if _, err := asn1.Unmarshal(ext.Value, &serialNumber); err != nil {
+				return nil, fmt.Errorf("error parsing serial number: %w", err)
+			}
+			data.PermanentIdentifiers = append(data.PermanentIdentifiers, strconv.FormatInt(serialNumber, 10))
+		}
+	}
+
+	return data, nil
+}
+
+// parseX5C parses the DER encoded certificates in an attestation statement.
+func parseX5C(x5c [][]byte) ([]*x509.Certificate, error) {
+	if len(x5c) == 0 {
+		return nil, errors.New("x5c cannot be empty")
+	}
+	certs := make([]*x509.Certificate, len(x5c))
+	for i, der := range x5c {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing x5c certificate: %w", err)
+		}
+		certs[i] = cert
+	}
+	return certs, nil
+}
+
+// verifyX5C verifies the first certificate using the rest of them as
+// intermediates, and returns the verified certificate.
+func verifyX5C(certs []*x509.Certificate, roots *x509.CertPool) (*x509.Certificate, error) {
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   time.Now().Truncate(time.Second),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return nil, fmt.Errorf("error verifying x5c certificate: %w", err)
+	}
+	return certs[0], nil
+}
+
+// COSE algorithm identifiers supported in attestation statements.
+const (
+	coseAlgES256 int64 = -7
+	coseAlgEdDSA int64 = -8
+	coseAlgES384 int64 = -35
+	coseAlgPS256 int64 = -37
+	coseAlgRS256 int64 = -257
+	coseAlgRS1   int64 = -65535
+)
+
+// verifyCOSESignature verifies the signature of the data using the public key
+// and the given COSE algorithm.
+func verifyCOSESignature(pub crypto.PublicKey, alg int64, data, sig []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case coseAlgES256, coseAlgPS256, coseAlgRS256:
+		hash = crypto.SHA256
+	case coseAlgES384:
+		hash = crypto.SHA384
+	case coseAlgRS1:
+		hash = crypto.SHA1
+	case coseAlgEdDSA:
+		hash = crypto.Hash(0)
+	default:
+		return fmt.Errorf("unsupported signature algorithm %d", alg)
+	}
+
+	var digest []byte
+	if hash != crypto.Hash(0) {
+		h := hash.New()
+		h.Write(data)
+		digest = h.Sum(nil)
+	}
+
+	var ok bool
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		ok = (alg == coseAlgES256 || alg == coseAlgES384) && ecdsa.VerifyASN1(k, digest, sig)
+	case *rsa.PublicKey:
+		switch alg {
+		case coseAlgRS256, coseAlgRS1:
+			ok = rsa.VerifyPKCS1v15(k, hash, digest, sig) == nil
+		case coseAlgPS256:
+			ok = rsa.VerifyPSS(k, hash, digest, sig, nil) == nil
+		}
+	case ed25519.PublicKey:
+		ok = alg == coseAlgEdDSA && ed25519.Verify(k, data, sig)
+	default:
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+	if !ok {
+		return errors.New("error verifying attestation signature")
+	}
+	return nil
+}
+
 // serverName determines the SNI HostName to set based on an acme.Challenge
 // for TLS-ALPN-01 challenges RFC8738 states that, if HostName is an IP, it
 // should be the ARPA address https://datatracker.ietf.org/doc/html/rfc8738#section-6.
@@ -381,9 +759,125 @@ type httpGetter func(string) (*http.Response, error)
 type lookupTxt func(string) ([]string, error)
 type tlsDialer func(network, addr string, config *tls.Config) (*tls.Conn, error)
 
+const (
+	defaultValidationTimeout       = 30 * time.Second
+	defaultValidationAttempts      = 5
+	defaultValidationRetryInterval = 5 * time.Second
+)
+
 // ValidateChallengeOptions are ACME challenge validator functions.
 type ValidateChallengeOptions struct {
 	HTTPGet   httpGetter
 	LookupTxt lookupTxt
 	TLSDial   tlsDialer
+	// HTTP01Port overrides the port used in the http-01 challenge.
+	HTTP01Port int
+	// MaxAttempts is the number of times a challenge is validated if the
+	// validation fails with a recoverable error.
+	MaxAttempts int
+	// RetryInterval is the time between two validation attempts. Background
+	// validations double it after each attempt.
+	RetryInterval time.Duration
+}
+
+// NewValidateChallengeOptions returns the validator functions configured with
+// the given provisioner options. If the options are nil, the defaults will be
+// used: the system DNS resolver, the port 80 for http-01, a timeout of 30s and
+// up to 5 validation attempts. The functions share an http.Client, so the
+// returned options are meant to be reused.
+func NewValidateChallengeOptions(o *provisioner.ACMEChallengeOptions) *ValidateChallengeOptions {
+	timeout := defaultValidationTimeout
+	vo := &ValidateChallengeOptions{
+		MaxAttempts:   defaultValidationAttempts,
+		RetryInterval: defaultValidationRetryInterval,
+	}
+	if o != nil {
+		if d := o.Timeout.Value(); d > 0 {
+			timeout = d
+		}
+		if d := o.RetryInterval.Value(); d > 0 {
+			vo.RetryInterval = d
+		}
+		if o.MaxAttempts > 0 {
+			vo.MaxAttempts = o.MaxAttempts
+		}
+		vo.HTTP01Port = o.HTTP01Port
+	}
+
+	resolver := newResolver(o.GetDNSResolvers(), o != nil && o.DNSOverTCP)
+	dialer := &net.Dialer{
+		Timeout:  timeout,
+		Resolver: resolver,
+	}
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:       dialer.DialContext,
+			DisableKeepAlives: true,
+			TLSClientConfig: &tls.Config{
+				// The http-01 challenge can be redirected to an https server
+				// with any certificate.
+				InsecureSkipVerify: true,
+			},
+		},
+	}
+
+	vo.HTTPGet = client.Get
+	vo.LookupTxt = func(name string) ([]string, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return resolver.LookupTXT(ctx, name)
+	}
+	vo.TLSDial = func(network, addr string, config *tls.Config) (*tls.Conn, error) {
+		return tls.DialWithDialer(dialer, network, addr, config)
+	}
+	return vo
+}
+
+// newResolver returns a DNS resolver that sends the queries to the given
+// servers, in order, or to the system ones if no servers are provided. If
+// overTCP is true, the queries will use TCP instead of UDP.
+func newResolver(servers []string, overTCP bool) *net.Resolver {
+	if len(servers) == 0 && !overTCP {
+		return net.DefaultResolver
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			if overTCP {
+				network = "tcp"
+			}
+			addrs := servers
+			if len(addrs) == 0 {
+				addrs = []string{address}
+			}
+			var (
+				d    net.Dialer
+				conn net.Conn
+				err  error
+			)
+			for _, addr := range addrs {
+				if conn, err = d.DialContext(ctx, network, addr); err == nil {
+					return conn, nil
+				}
+			}
+			return nil, err
+		},
+	}
+}
+
+// maxAttempts returns the number of validation attempts, at least 1.
+func (vo *ValidateChallengeOptions) maxAttempts() int {
+	if vo == nil || vo.MaxAttempts < 1 {
+		return 1
+	}
+	return vo.MaxAttempts
+}
+
+// RetryAfter returns the time to wait before the next validation attempt.
+func (vo *ValidateChallengeOptions) RetryAfter() time.Duration {
+	if vo == nil || vo.RetryInterval <= 0 {
+		return defaultValidationRetryInterval
+	}
+	return vo.RetryInterval
 }
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"context"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
@@ -13,6 +15,7 @@ import (
 	"encoding/asn1"
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -20,13 +23,16 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/fxamacker/cbor/v2"
 	"go.step.sm/crypto/jose"
 
 	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority/provisioner"
 )
 
 func Test_storeError(t *testing.T) {
@@ -228,12 +234,14 @@ func TestKeyAuthorization(t *testing.T) {
 
 func TestChallenge_Validate(t *testing.T) {
 	type test struct {
-		ch  *Challenge
-		vo  *ValidateChallengeOptions
-		jwk *jose.JSONWebKey
-		db  DB
-		srv *httptest.Server
-		err *Error
+		ch      *Challenge
+		vo      *ValidateChallengeOptions
+		jwk     *jose.JSONWebKey
+		db      DB
+		srv     *httptest.Server
+		prov    Provisioner
+		payload []byte
+		err     *Error
 	}
 	tests := map[string]func(t *testing.T) test{
 		"ok/already-valid": func(t *testing.T) test {
@@ -333,6 +341,84 @@ func TestChallenge_Validate(t *testing.T) {
 				},
 			}
 		},
+		"ok/http-01-retry": func(t *testing.T) test {
+			ch := &Challenge{
+				ID:     "chID",
+				Status: StatusPending,
+				Type:   "http-01",
+				Token:  "token",
+				Value:  "zap.internal",
+			}
+
+			jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+			assert.FatalError(t, err)
+			expKeyAuth, err := KeyAuthorization(ch.Token, jwk)
+			assert.FatalError(t, err)
+
+			var attempts, updates int
+			return test{
+				ch:  ch,
+				jwk: jwk,
+				vo: &ValidateChallengeOptions{
+					MaxAttempts:   3,
+					RetryInterval: time.Millisecond,
+					HTTPGet: func(url string) (*http.Response, error) {
+						attempts++
+						if attempts < 3 {
+							return nil, errors.New("force")
+						}
+						return &http.Response{
+							Body: io.NopCloser(bytes.NewBufferString(expKeyAuth)),
+						}, nil
+					},
+				},
+				db: &MockDB{
+					MockUpdateChallenge: func(ctx context.Context, updch *Challenge) error {
+						updates++
+						assert.Equals(t, updch.ID, ch.ID)
+						if updates < 3 {
+							assert.Equals(t, updch.Status, StatusPending)
+							assert.Equals(t, updch.Error.Type, NewError(ErrorConnectionType, "force").Type)
+						} else {
+							assert.Equals(t, attempts, 3)
+							assert.Equals(t, updch.Status, StatusValid)
+							assert.Equals(t, updch.Error, nil)
+						}
+						return nil
+					},
+				},
+			}
+		},
+		"ok/http-01-retry-exhausted": func(t *testing.T) test {
+			ch := &Challenge{
+				ID:     "chID",
+				Status: StatusPending,
+				Type:   "http-01",
+				Token:  "token",
+				Value:  "zap.internal",
+			}
+
+			var attempts int
+			return test{
+				ch: ch,
+				vo: &ValidateChallengeOptions{
+					MaxAttempts:   2,
+					RetryInterval: time.Millisecond,
+					HTTPGet: func(url string) (*http.Response, error) {
+						attempts++
+						assert.True(t, attempts <= 2)
+						return nil, errors.New("force")
+					},
+				},
+				db: &MockDB{
+					MockUpdateChallenge: func(ctx context.Context, updch *Challenge) error {
+						assert.Equals(t, updch.Status, StatusPending)
+						assert.Equals(t, updch.Error.Type, NewError(ErrorConnectionType, "force").Type)
+						return nil
+					},
+				},
+			}
+		},
 		"fail/dns-01": func(t *testing.T) test {
 			ch := &Challenge{
 				ID:     "chID",
@@ -484,6 +570,42 @@ func TestChallenge_Validate(t *testing.T) {
 				jwk: jwk,
 			}
 		},
+		"ok/device-attest-01": func(t *testing.T) test {
+			ch := &Challenge{
+				ID:     "chID",
+				Token:  "token",
+				Type:   "device-attest-01",
+				Status: StatusPending,
+				Value:  "12345678",
+			}
+
+			jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+			assert.FatalError(t, err)
+			keyAuth, err := KeyAuthorization(ch.Token, jwk)
+			assert.FatalError(t, err)
+
+			roots, attObj, _ := newStepAttestationObject(t, 12345678, keyAuth)
+			payload, err := json.Marshal(deviceAttestPayload{
+				AttObj: base64.RawURLEncoding.EncodeToString(attObj),
+			})
+			assert.FatalError(t, err)
+
+			return test{
+				ch:      ch,
+				jwk:     jwk,
+				prov:    &MockProvisioner{Mret1: roots},
+				payload: payload,
+				db: &MockDB{
+					MockUpdateChallenge: func(ctx context.Context, updch *Challenge) error {
+						assert.Equals(t, updch.ID, ch.ID)
+						assert.Equals(t, updch.Status, StatusValid)
+						assert.Equals(t, updch.Payload, attObj)
+						assert.Equals(t, updch.Error, nil)
+						return nil
+					},
+				},
+			}
+		},
 	}
 	for name, run := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -493,7 +615,7 @@ func TestChallenge_Validate(t *testing.T) {
 				defer tc.srv.Close()
 			}
 
-			if err := tc.ch.Validate(context.Background(), tc.db, tc.jwk, tc.vo); err != nil {
+			if err := tc.ch.Validate(context.Background(), tc.db, tc.jwk, tc.vo, tc.prov, tc.payload); err != nil {
 				if assert.NotNil(t, tc.err) {
 					switch k := err.(type) {
 					case *Error:
@@ -598,6 +720,33 @@ func TestHTTP01Validate(t *testing.T) {
 				},
 			}
 		},
+		"ok/http-get-error-port": func(t *testing.T) test {
+			ch := &Challenge{
+				ID:     "chID",
+				Token:  "token",
+				Value:  "2001:db8::1",
+				Status: StatusPending,
+			}
+
+			return test{
+				ch: ch,
+				vo: &ValidateChallengeOptions{
+					HTTP01Port: 8080,
+					HTTPGet: func(url string) (*http.Response, error) {
+						assert.Equals(t, url, "http://[2001:db8::1]:8080/.well-known/acme-challenge/token")
+						return nil, errors.New("force")
+					},
+				},
+				db: &MockDB{
+					MockUpdateChallenge: func(ctx context.Context, updch *Challenge) error {
+						assert.Equals(t, updch.Status, StatusPending)
+						err := NewError(ErrorConnectionType, "error doing http GET for url http://[2001:db8::1]:8080/.well-known/acme-challenge/%s: force", ch.Token)
+						assert.HasPrefix(t, updch.Error.Err.Error(), err.Err.Error())
+						return nil
+					},
+				},
+			}
+		},
 		"fail/http-get->=400-store-error": func(t *testing.T) test {
 			ch := &Challenge{
 				ID:     "chID",
@@ -2382,3 +2531,415 @@ func Test_http01ChallengeHost(t *testing.T) {
 		})
 	}
 }
+
+func newAttestationCA(t *testing.T) (*x509.Certificate, crypto.Signer) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Attestation Root CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	assert.FatalError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.FatalError(t, err)
+	return cert, key
+}
+
+func newAttestationLeaf(t *testing.T, ca *x509.Certificate, caKey crypto.Signer, tmpl *x509.Certificate) (*x509.Certificate, crypto.Signer) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	tmpl.SerialNumber = big.NewInt(2)
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, key.Public(), caKey)
+	assert.FatalError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.FatalError(t, err)
+	return cert, key
+}
+
+func newStepAttestationObject(t *testing.T, serialNumber int64, keyAuth string) (*x509.CertPool, []byte, crypto.Signer) {
+	t.Helper()
+	ca, caKey := newAttestationCA(t)
+	sn, err := asn1.Marshal(serialNumber)
+	assert.FatalError(t, err)
+	leaf, key := newAttestationLeaf(t, ca, caKey, &x509.Certificate{
+		Subject:         pkix.Name{CommonName: "YubiKey PIV Attestation 9a"},
+		ExtraExtensions: []pkix.Extension{{Id: oidYubicoSerialNumber, Value: sn}},
+	})
+
+	sum := sha256.Sum256([]byte(keyAuth))
+	sig, err := key.Sign(rand.Reader, sum[:], crypto.SHA256)
+	assert.FatalError(t, err)
+
+	attObj, err := cbor.Marshal(map[string]interface{}{
+		"fmt": "step",
+		"attStmt": map[string]interface{}{
+			"alg": coseAlgES256,
+			"sig": sig,
+			"x5c": [][]byte{leaf.Raw, ca.Raw},
+		},
+	})
+	assert.FatalError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	return roots, attObj, key
+}
+
+func newAppleAttestationObject(t *testing.T, serialNumber, udid string, nonce []byte) (*x509.CertPool, []byte, crypto.Signer) {
+	t.Helper()
+	ca, caKey := newAttestationCA(t)
+	exts := []pkix.Extension{
+		{Id: oidAppleSerialNumber, Value: []byte(serialNumber)},
+		{Id: oidAppleUniqueDeviceIdentifier, Value: []byte(udid)},
+	}
+	if nonce != nil {
+		exts = append(exts, pkix.Extension{Id: oidAppleNonce, Value: nonce})
+	}
+	leaf, key := newAttestationLeaf(t, ca, caKey, &x509.Certificate{
+		Subject:         pkix.Name{CommonName: "Apple Managed Device Attestation"},
+		ExtraExtensions: exts,
+	})
+
+	attObj, err := cbor.Marshal(map[string]interface{}{
+		"fmt": "apple",
+		"attStmt": map[string]interface{}{
+			"x5c": [][]byte{leaf.Raw, ca.Raw},
+		},
+	})
+	assert.FatalError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	return roots, attObj, key
+}
+
+func Test_deviceAttest01Validate(t *testing.T) {
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	assert.FatalError(t, err)
+	keyAuth, err := KeyAuthorization("token", jwk)
+	assert.FatalError(t, err)
+
+	newChallenge := func() *Challenge {
+		return &Challenge{
+			ID:     "chID",
+			Token:  "token",
+			Type:   "device-attest-01",
+			Status: StatusPending,
+			Value:  "12345678",
+		}
+	}
+	newPayload := func(t *testing.T, attObj []byte) []byte {
+		b, err := json.Marshal(deviceAttestPayload{
+			AttObj: base64.RawURLEncoding.EncodeToString(attObj),
+		})
+		assert.FatalError(t, err)
+		return b
+	}
+	mockStoreError := func(t *testing.T, ch *Challenge, err *Error) *MockDB {
+		return &MockDB{
+			MockUpdateChallenge: func(ctx context.Context, updch *Challenge) error {
+				assert.Equals(t, updch.ID, ch.ID)
+				assert.Equals(t, updch.Status, StatusInvalid)
+				assert.Equals(t, updch.Error.Type, err.Type)
+				assert.Equals(t, updch.Error.Detail, err.Detail)
+				assert.Equals(t, updch.Payload, []byte(nil))
+				return nil
+			},
+		}
+	}
+
+	type test struct {
+		ch      *Challenge
+		db      DB
+		prov    Provisioner
+		payload []byte
+		err     *Error
+	}
+	tests := map[string]func(t *testing.T) test{
+		"ok/no-payload": func(t *testing.T) test {
+			return test{
+				ch: newChallenge(),
+				db: &MockDB{},
+			}
+		},
+		"fail/unmarshal-payload": func(t *testing.T) test {
+			return test{
+				ch:      newChallenge(),
+				db:      &MockDB{},
+				payload: []byte("{not json"),
+				err:     NewError(ErrorMalformedType, "error unmarshaling payload"),
+			}
+		},
+		"ok/payload-error": func(t *testing.T) test {
+			ch := newChallenge()
+			return test{
+				ch:      ch,
+				db:      mockStoreError(t, ch, NewError(ErrorRejectedIdentifierType, "payload contained error: foo")),
+				payload: []byte(`{"error":"foo"}`),
+			}
+		},
+		"ok/bad-base64": func(t *testing.T) test {
+			ch := newChallenge()
+			return test{
+				ch:      ch,
+				db:      mockStoreError(t, ch, NewError(ErrorBadAttestationStatementType, "")),
+				payload: []byte(`{"attObj":"?!"}`),
+			}
+		},
+		"ok/format-not-enabled": func(t *testing.T) test {
+			ch := newChallenge()
+			roots, attObj, _ := newStepAttestationObject(t, 12345678, keyAuth)
+			return test{
+				ch: ch,
+				db: mockStoreError(t, ch, NewError(ErrorBadAttestationStatementType, "")),
+				prov: &MockProvisioner{
+					Mret1: roots,
+					MisAttestationFormatEnabled: func(format provisioner.ACMEAttestationFormat) bool {
+						assert.Equals(t, format, provisioner.STEP)
+						return false
+					},
+				},
+				payload: newPayload(t, attObj),
+			}
+		},
+		"ok/no-roots": func(t *testing.T) test {
+			ch := newChallenge()
+			_, attObj, _ := newStepAttestationObject(t, 12345678, keyAuth)
+			return test{
+				ch:      ch,
+				db:      mockStoreError(t, ch, NewError(ErrorBadAttestationStatementType, "")),
+				prov:    &MockProvisioner{},
+				payload: newPayload(t, attObj),
+			}
+		},
+		"ok/step-untrusted-root": func(t *testing.T) test {
+			ch := newChallenge()
+			roots, _, _ := newStepAttestationObject(t, 12345678, keyAuth)
+			_, attObj, _ := newStepAttestationObject(t, 12345678, keyAuth)
+			return test{
+				ch:      ch,
+				db:      mockStoreError(t, ch, NewError(ErrorBadAttestationStatementType, "")),
+				prov:    &MockProvisioner{Mret1: roots},
+				payload: newPayload(t, attObj),
+			}
+		},
+		"ok/step-bad-signature": func(t *testing.T) test {
+			ch := newChallenge()
+			roots, attObj, _ := newStepAttestationObject(t, 12345678, "foo")
+			return test{
+				ch:      ch,
+				db:      mockStoreError(t, ch, NewError(ErrorBadAttestationStatementType, "")),
+				prov:    &MockProvisioner{Mret1: roots},
+				payload: newPayload(t, attObj),
+			}
+		},
+		"ok/step-identifier-mismatch": func(t *testing.T) test {
+			ch := newChallenge()
+			roots, attObj, _ := newStepAttestationObject(t, 87654321, keyAuth)
+			return test{
+				ch: ch,
+				db: mockStoreError(t, ch, NewError(ErrorRejectedIdentifierType,
+					"permanent identifier does not match; expected 12345678, but got [87654321]")),
+				prov:    &MockProvisioner{Mret1: roots},
+				payload: newPayload(t, attObj),
+			}
+		},
+		"ok/step": func(t *testing.T) test {
+			ch := newChallenge()
+			roots, attObj, _ := newStepAttestationObject(t, 12345678, keyAuth)
+			return test{
+				ch: ch,
+				db: &MockDB{
+					MockUpdateChallenge: func(ctx context.Context, updch *Challenge) error {
+						assert.Equals(t, updch.Status, StatusValid)
+						assert.Equals(t, updch.Payload, attObj)
+						assert.Equals(t, updch.Error, nil)
+						return nil
+					},
+				},
+				prov:    &MockProvisioner{Mret1: roots},
+				payload: newPayload(t, attObj),
+			}
+		},
+		"ok/apple-nonce-mismatch": func(t *testing.T) test {
+			ch := newChallenge()
+			roots, attObj, _ := newAppleAttestationObject(t, "12345678", "udid", []byte("foo"))
+			return test{
+				ch:      ch,
+				db:      mockStoreError(t, ch, NewError(ErrorBadAttestationStatementType, "")),
+				prov:    &MockProvisioner{Mret1: roots},
+				payload: newPayload(t, attObj),
+			}
+		},
+		"ok/apple-missing-nonce": func(t *testing.T) test {
+			ch := newChallenge()
+			roots, attObj, _ := newAppleAttestationObject(t, "12345678", "udid", nil)
+			return test{
+				ch:      ch,
+				db:      mockStoreError(t, ch, NewError(ErrorBadAttestationStatementType, "")),
+				prov:    &MockProvisioner{Mret1: roots},
+				payload: newPayload(t, attObj),
+			}
+		},
+		"ok/apple-token-nonce": func(t *testing.T) test {
+			ch := newChallenge()
+			nonce := sha256.Sum256([]byte(ch.Token))
+			roots, attObj, _ := newAppleAttestationObject(t, "12345678", "udid", nonce[:])
+			return test{
+				ch:      ch,
+				db:      mockStoreError(t, ch, NewError(ErrorBadAttestationStatementType, "")),
+				prov:    &MockProvisioner{Mret1: roots},
+				payload: newPayload(t, attObj),
+			}
+		},
+		"ok/apple": func(t *testing.T) test {
+			ch := newChallenge()
+			nonce := sha256.Sum256([]byte(keyAuth))
+			roots, attObj, _ := newAppleAttestationObject(t, "12345678", "udid", nonce[:])
+			return test{
+				ch: ch,
+				db: &MockDB{
+					MockUpdateChallenge: func(ctx context.Context, updch *Challenge) error {
+						assert.Equals(t, updch.Status, StatusValid)
+						assert.Equals(t, updch.Payload, attObj)
+						return nil
+					},
+				},
+				prov:    &MockProvisioner{Mret1: roots},
+				payload: newPayload(t, attObj),
+			}
+		},
+		"fail/db.UpdateChallenge-error": func(t *testing.T) test {
+			ch := newChallenge()
+			roots, attObj, _ := newStepAttestationObject(t, 12345678, keyAuth)
+			return test{
+				ch: ch,
+				db: &MockDB{
+					MockUpdateChallenge: func(ctx context.Context, updch *Challenge) error {
+						return errors.New("force")
+					},
+				},
+				prov:    &MockProvisioner{Mret1: roots},
+				payload: newPayload(t, attObj),
+				err:     NewErrorISE("error updating challenge: force"),
+			}
+		},
+	}
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			tc := run(t)
+			if err := deviceAttest01Validate(context.Background(), tc.ch, tc.db, jwk, tc.prov, tc.payload); err != nil {
+				if assert.NotNil(t, tc.err) {
+					switch k := err.(type) {
+					case *Error:
+						assert.Equals(t, k.Type, tc.err.Type)
+						assert.Equals(t, k.Detail, tc.err.Detail)
+						assert.Equals(t, k.Status, tc.err.Status)
+					default:
+						assert.FatalError(t, errors.New("unexpected error type"))
+					}
+				}
+			} else {
+				assert.Nil(t, tc.err)
+			}
+		})
+	}
+}
+
+func Test_attestedPublicKey(t *testing.T) {
+	_, stepObj, stepKey := newStepAttestationObject(t, 12345678, "keyAuth")
+	_, appleObj, appleKey := newAppleAttestationObject(t, "12345678", "udid", nil)
+	badObj, err := cbor.Marshal(map[string]interface{}{"fmt": "foo"})
+	assert.FatalError(t, err)
+
+	tests := []struct {
+		name    string
+		attObj  []byte
+		want    crypto.PublicKey
+		wantErr bool
+	}{
+		{"ok/step", stepObj, stepKey.Public(), false},
+		{"ok/apple", appleObj, appleKey.Public(), false},
+		{"fail/format", badObj, nil, true},
+		{"fail/cbor", []byte("foo"), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := attestedPublicKey(tt.attObj)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("attestedPublicKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("attestedPublicKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewValidateChallengeOptions(t *testing.T) {
+	vo := NewValidateChallengeOptions(nil)
+	assert.NotNil(t, vo.HTTPGet)
+	assert.NotNil(t, vo.LookupTxt)
+	assert.NotNil(t, vo.TLSDial)
+	assert.Equals(t, vo.HTTP01Port, 0)
+	assert.Equals(t, vo.maxAttempts(), defaultValidationAttempts)
+	assert.Equals(t, vo.RetryAfter(), defaultValidationRetryInterval)
+
+	vo = NewValidateChallengeOptions(&provisioner.ACMEChallengeOptions{
+		HTTP01Port:    8080,
+		MaxAttempts:   3,
+		RetryInterval: &provisioner.Duration{Duration: time.Second},
+	})
+	assert.Equals(t, vo.HTTP01Port, 8080)
+	assert.Equals(t, vo.maxAttempts(), 3)
+	assert.Equals(t, vo.RetryAfter(), time.Second)
+
+	var nilOptions *ValidateChallengeOptions
+	assert.Equals(t, nilOptions.maxAttempts(), 1)
+	assert.Equals(t, nilOptions.RetryAfter(), defaultValidationRetryInterval)
+}
+
+func Test_newResolver(t *testing.T) {
+	assert.Equals(t, newResolver(nil, false), net.DefaultResolver)
+
+	// The resolver must send the queries over TCP to the configured server.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.FatalError(t, err)
+	defer ln.Close()
+	accepted := make(chan struct{}, 1)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			select {
+			case accepted <- struct{}{}:
+			default:
+			}
+			conn.Close()
+		}
+	}()
+
+	r := newResolver([]string{ln.Addr().String()}, true)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = r.LookupTXT(ctx, "_acme-challenge.zap.internal")
+	assert.Error(t, err)
+	select {
+	case <-accepted:
+	default:
+		t.Error("resolver did not connect to the configured server")
+	}
+}
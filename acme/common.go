@@ -11,7 +11,7 @@ import (
 
 // CertificateAuthority is the interface implemented by a CA authority.
 type CertificateAuthority interface {
-	Sign(cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]*x509.Certificate, error)
+	SignWithContext(ctx context.Context, cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]*x509.Certificate, error)
 	IsRevoked(sn string) (bool, error)
 	Revoke(context.Context, *authority.RevokeOptions) error
 	LoadProvisionerByName(string) (provisioner.Interface, error)
@@ -36,18 +36,26 @@ type Provisioner interface {
 	GetName() string
 	DefaultTLSCertDuration() time.Duration
 	GetOptions() *provisioner.Options
+	IsChallengeEnabled(ctx context.Context, challenge provisioner.ACMEChallenge) bool
+	GetChallengeOptions() *provisioner.ACMEChallengeOptions
+	IsAttestationFormatEnabled(format provisioner.ACMEAttestationFormat) bool
+	GetAttestationRoots() (*x509.CertPool, bool)
 }
 
 // MockProvisioner for testing
 type MockProvisioner struct {
-	Mret1                   interface{}
-	Merr                    error
-	MgetID                  func() string
-	MgetName                func() string
-	MauthorizeSign          func(ctx context.Context, ott string) ([]provisioner.SignOption, error)
-	MauthorizeRevoke        func(ctx context.Context, token string) error
-	MdefaultTLSCertDuration func() time.Duration
-	MgetOptions             func() *provisioner.Options
+	Mret1                       interface{}
+	Merr                        error
+	MgetID                      func() string
+	MgetName                    func() string
+	MauthorizeSign              func(ctx context.Context, ott string) ([]provisioner.SignOption, error)
+	MauthorizeRevoke            func(ctx context.Context, token string) error
+	MdefaultTLSCertDuration     func() time.Duration
+	MgetOptions                 func() *provisioner.Options
+	MisChallengeEnabled         func(ctx context.Context, challenge provisioner.ACMEChallenge) bool
+	MgetChallengeOptions        func() *provisioner.ACMEChallengeOptions
+	MisAttestationFormatEnabled func(format provisioner.ACMEAttestationFormat) bool
+	MgetAttestationRoots        func() (*x509.CertPool, bool)
 }
 
 // GetName mock
@@ -97,3 +105,37 @@ func (m *MockProvisioner) GetID() string {
 	}
 	return m.Mret1.(string)
 }
+
+// IsChallengeEnabled mock
+func (m *MockProvisioner) IsChallengeEnabled(ctx context.Context, challenge provisioner.ACMEChallenge) bool {
+	if m.MisChallengeEnabled != nil {
+		return m.MisChallengeEnabled(ctx, challenge)
+	}
+	return m.Merr == nil
+}
+
+// GetChallengeOptions mock
+func (m *MockProvisioner) GetChallengeOptions() *provisioner.ACMEChallengeOptions {
+	if m.MgetChallengeOptions != nil {
+		return m.MgetChallengeOptions()
+	}
+	o, _ := m.Mret1.(*provisioner.ACMEChallengeOptions)
+	return o
+}
+
+// IsAttestationFormatEnabled mock
+func (m *MockProvisioner) IsAttestationFormatEnabled(format provisioner.ACMEAttestationFormat) bool {
+	if m.MisAttestationFormatEnabled != nil {
+		return m.MisAttestationFormatEnabled(format)
+	}
+	return m.Merr == nil
+}
+
+// GetAttestationRoots mock
+func (m *MockProvisioner) GetAttestationRoots() (*x509.CertPool, bool) {
+	if m.MgetAttestationRoots != nil {
+		return m.MgetAttestationRoots()
+	}
+	roots, ok := m.Mret1.(*x509.CertPool)
+	return roots, ok && roots != nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/pkg/errors"
+	"go.step.sm/crypto/jose"
 )
 
 // ErrNotFound is an error that should be used by the acme.DB interface to
@@ -18,6 +19,7 @@ type DB interface {
 	GetAccount(ctx context.Context, id string) (*Account, error)
 	GetAccountByKeyID(ctx context.Context, kid string) (*Account, error)
 	UpdateAccount(ctx context.Context, acc *Account) error
+	UpdateAccountKey(ctx context.Context, accountID string, jwk *jose.JSONWebKey) error
 
 	CreateExternalAccountKey(ctx context.Context, provisionerID, reference string) (*ExternalAccountKey, error)
 	GetExternalAccountKey(ctx context.Context, provisionerID, keyID string) (*ExternalAccountKey, error)
@@ -55,6 +57,7 @@ type MockDB struct {
 	MockGetAccount        func(ctx context.Context, id string) (*Account, error)
 	MockGetAccountByKeyID func(ctx context.Context, kid string) (*Account, error)
 	MockUpdateAccount     func(ctx context.Context, acc *Account) error
+	MockUpdateAccountKey  func(ctx context.Context, accountID string, jwk *jose.JSONWebKey) error
 
 	MockCreateExternalAccountKey         func(ctx context.Context, provisionerID, reference string) (*ExternalAccountKey, error)
 	MockGetExternalAccountKey            func(ctx context.Context, provisionerID, keyID string) (*ExternalAccountKey, error)
@@ -128,6 +131,16 @@ func (m *MockDB) UpdateAccount(ctx context.Context, acc *Account) error {
 	return m.MockError
 }
 
+// UpdateAccountKey mock
+func (m *MockDB) UpdateAccountKey(ctx context.Context, accountID string, jwk *jose.JSONWebKey) error {
+	if m.MockUpdateAccountKey != nil {
+		return m.MockUpdateAccountKey(ctx, accountID, jwk)
+	} else if m.MockError != nil {
+		return m.MockError
+	}
+	return m.MockError
+}
+
 // CreateExternalAccountKey mock
 func (m *MockDB) CreateExternalAccountKey(ctx context.Context, provisionerID, reference string) (*ExternalAccountKey, error) {
 	if m.MockCreateExternalAccountKey != nil {
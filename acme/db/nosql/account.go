@@ -134,3 +134,52 @@ func (db *DB) UpdateAccount(ctx context.Context, acc *acme.Account) error {
 
 	return db.save(ctx, old.ID, nu, old, "account", accountTable)
 }
+
+// UpdateAccountKey implements the AcmeDB.UpdateAccountKey interface. The new
+// key-id index is reserved, then the account is updated and the old key-id
+// index is removed. The compare-and-swap operations of the database do not
+// abort a transaction if they fail, so the steps are done in order, and the
+// previous ones are undone if one fails, leaving the account and both key-id
+// indexes unchanged.
+func (db *DB) UpdateAccountKey(ctx context.Context, accountID string, jwk *jose.JSONWebKey) error {
+	old, err := db.getDBAccount(ctx, accountID)
+	if err != nil {
+		return err
+	}
+
+	oldKid, err := acme.KeyToID(old.Key)
+	if err != nil {
+		return err
+	}
+	newKid, err := acme.KeyToID(jwk)
+	if err != nil {
+		return err
+	}
+	newKidB := []byte(newKid)
+
+	nu := old.clone()
+	nu.Key = jwk
+
+	// Reserve the new jwkID -> acme account ID index
+	_, swapped, err := db.db.CmpAndSwap(accountByKeyIDTable, newKidB, nil, []byte(accountID))
+	switch {
+	case err != nil:
+		return errors.Wrap(err, "error storing keyID to accountID index")
+	case !swapped:
+		return errors.Errorf("key-id to account-id index already exists")
+	}
+
+	if err := db.save(ctx, accountID, nu, old, "account", accountTable); err != nil {
+		db.db.Del(accountByKeyIDTable, newKidB)
+		return err
+	}
+
+	if err := db.db.Del(accountByKeyIDTable, []byte(oldKid)); err != nil {
+		// Restore the old key, the account is still reachable with it.
+		if db.save(ctx, accountID, old, nu, "account", accountTable) == nil {
+			db.db.Del(accountByKeyIDTable, newKidB)
+		}
+		return errors.Wrapf(err, "error deleting keyID to accountID index for key %s", oldKid)
+	}
+	return nil
+}
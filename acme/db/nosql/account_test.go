@@ -1,6 +1,7 @@
 package nosql
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"testing"
@@ -696,3 +697,214 @@ func TestDB_UpdateAccount(t *testing.T) {
 		})
 	}
 }
+
+func TestDB_UpdateAccountKey(t *testing.T) {
+	accID := "accID"
+	now := clock.Now()
+	oldJWK, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	assert.FatalError(t, err)
+	oldKid, err := acme.KeyToID(oldJWK)
+	assert.FatalError(t, err)
+	newJWK, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	assert.FatalError(t, err)
+	newKid, err := acme.KeyToID(newJWK)
+	assert.FatalError(t, err)
+	dbacc := &dbAccount{
+		ID:        accID,
+		Status:    acme.StatusValid,
+		CreatedAt: now,
+		Contact:   []string{"foo", "bar"},
+		Key:       oldJWK,
+	}
+	b, err := json.Marshal(dbacc)
+	assert.FatalError(t, err)
+
+	// mockDB returns a database with the account and its key-id index, the
+	// given functions can force errors on the operations.
+	type store struct {
+		accounts map[string][]byte
+		kids     map[string]string
+	}
+	mockDB := func(st *store, cas func(bucket []byte) error, del func(key []byte) error) *db.MockNoSQLDB {
+		return &db.MockNoSQLDB{
+			MGet: func(bucket, key []byte) ([]byte, error) {
+				assert.Equals(t, bucket, accountTable)
+				if v, ok := st.accounts[string(key)]; ok {
+					return v, nil
+				}
+				return nil, nosqldb.ErrNotFound
+			},
+			MCmpAndSwap: func(bucket, key, old, nu []byte) ([]byte, bool, error) {
+				if cas != nil {
+					if err := cas(bucket); err != nil {
+						return nil, false, err
+					}
+				}
+				switch string(bucket) {
+				case string(accountTable):
+					if !bytes.Equal(st.accounts[string(key)], old) {
+						return st.accounts[string(key)], false, nil
+					}
+					st.accounts[string(key)] = nu
+				case string(accountByKeyIDTable):
+					if v, ok := st.kids[string(key)]; ok || old != nil {
+						return []byte(v), false, nil
+					}
+					st.kids[string(key)] = string(nu)
+				default:
+					return nil, false, errors.Errorf("unexpected bucket %s", string(bucket))
+				}
+				return nu, true, nil
+			},
+			MDel: func(bucket, key []byte) error {
+				assert.Equals(t, bucket, accountByKeyIDTable)
+				if del != nil {
+					if err := del(key); err != nil {
+						return err
+					}
+				}
+				delete(st.kids, string(key))
+				return nil
+			},
+		}
+	}
+
+	type test struct {
+		st       *store
+		db       nosql.DB
+		err      error
+		wantKids map[string]string
+		wantAcc  []byte
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/db.Get-error": func(t *testing.T) test {
+			return test{
+				db: &db.MockNoSQLDB{
+					MGet: func(bucket, key []byte) ([]byte, error) {
+						assert.Equals(t, bucket, accountTable)
+						assert.Equals(t, string(key), accID)
+						return nil, errors.New("force")
+					},
+				},
+				err: errors.New("error loading account accID: force"),
+			}
+		},
+		"fail/keyID-cmpAndSwap-error": func(t *testing.T) test {
+			st := &store{
+				accounts: map[string][]byte{accID: b},
+				kids:     map[string]string{oldKid: accID},
+			}
+			return test{
+				st: st,
+				db: mockDB(st, func(bucket []byte) error {
+					return errors.New("force")
+				}, nil),
+				err:      errors.New("error storing keyID to accountID index: force"),
+				wantKids: map[string]string{oldKid: accID},
+				wantAcc:  b,
+			}
+		},
+		"fail/keyID-exists": func(t *testing.T) test {
+			st := &store{
+				accounts: map[string][]byte{accID: b},
+				kids:     map[string]string{oldKid: accID, newKid: "otherAccID"},
+			}
+			return test{
+				st:       st,
+				db:       mockDB(st, nil, nil),
+				err:      errors.New("key-id to account-id index already exists"),
+				wantKids: map[string]string{oldKid: accID, newKid: "otherAccID"},
+				wantAcc:  b,
+			}
+		},
+		"fail/account-not-swapped": func(t *testing.T) test {
+			st := &store{
+				accounts: map[string][]byte{accID: b},
+				kids:     map[string]string{oldKid: accID},
+			}
+			// The account changes after it is read.
+			changed := []byte(`{"id":"accID","status":"deactivated"}`)
+			return test{
+				st: st,
+				db: mockDB(st, func(bucket []byte) error {
+					if string(bucket) == string(accountTable) {
+						st.accounts[accID] = changed
+					}
+					return nil
+				}, nil),
+				err:      errors.New("error saving acme account; changed since last read"),
+				wantKids: map[string]string{oldKid: accID},
+				wantAcc:  changed,
+			}
+		},
+		"fail/account-cmpAndSwap-error": func(t *testing.T) test {
+			st := &store{
+				accounts: map[string][]byte{accID: b},
+				kids:     map[string]string{oldKid: accID},
+			}
+			return test{
+				st: st,
+				db: mockDB(st, func(bucket []byte) error {
+					if string(bucket) == string(accountTable) {
+						return errors.New("force")
+					}
+					return nil
+				}, nil),
+				err:      errors.New("error saving acme account: force"),
+				wantKids: map[string]string{oldKid: accID},
+				wantAcc:  b,
+			}
+		},
+		"fail/delete-old-keyID-error": func(t *testing.T) test {
+			st := &store{
+				accounts: map[string][]byte{accID: b},
+				kids:     map[string]string{oldKid: accID},
+			}
+			return test{
+				st: st,
+				db: mockDB(st, nil, func(key []byte) error {
+					if string(key) == oldKid {
+						return errors.New("force")
+					}
+					return nil
+				}),
+				err:      errors.Errorf("error deleting keyID to accountID index for key %s: force", oldKid),
+				wantKids: map[string]string{oldKid: accID},
+				wantAcc:  b,
+			}
+		},
+		"ok": func(t *testing.T) test {
+			st := &store{
+				accounts: map[string][]byte{accID: b},
+				kids:     map[string]string{oldKid: accID},
+			}
+			nu := dbacc.clone()
+			nu.Key = newJWK
+			nb, err := json.Marshal(nu)
+			assert.FatalError(t, err)
+			return test{
+				st:       st,
+				db:       mockDB(st, nil, nil),
+				wantKids: map[string]string{newKid: accID},
+				wantAcc:  nb,
+			}
+		},
+	}
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			tc := run(t)
+			d := DB{db: tc.db}
+			if err := d.UpdateAccountKey(context.Background(), accID, newJWK); err != nil {
+				if assert.NotNil(t, tc.err) {
+					assert.HasPrefix(t, err.Error(), tc.err.Error())
+				}
+			} else {
+				assert.Nil(t, tc.err)
+			}
+			if tc.st != nil {
+				assert.Equals(t, tc.wantKids, tc.st.kids)
+				assert.Equals(t, tc.wantAcc, tc.st.accounts[accID])
+			}
+		})
+	}
+}
@@ -20,6 +20,7 @@ type dbChallenge struct {
 	ValidatedAt string             `json:"validatedAt"`
 	CreatedAt   time.Time          `json:"createdAt"`
 	Error       *acme.Error        `json:"error"`
+	Payload     []byte             `json:"payload,omitempty"`
 }
 
 func (dbc *dbChallenge) clone() *dbChallenge {
@@ -81,6 +82,7 @@ func (db *DB) GetChallenge(ctx context.Context, id, authzID string) (*acme.Chall
 		Token:       dbch.Token,
 		Error:       dbch.Error,
 		ValidatedAt: dbch.ValidatedAt,
+		Payload:     dbch.Payload,
 	}
 	return ch, nil
 }
@@ -98,6 +100,7 @@ func (db *DB) UpdateChallenge(ctx context.Context, ch *acme.Challenge) error {
 	nu.Status = ch.Status
 	nu.Error = ch.Error
 	nu.ValidatedAt = ch.ValidatedAt
+	nu.Payload = ch.Payload
 
 	return db.save(ctx, old.ID, nu, old, "challenge", challengeTable)
 }
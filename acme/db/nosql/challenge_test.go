@@ -400,6 +400,7 @@ func TestDB_UpdateChallenge(t *testing.T) {
 				Status:      acme.StatusValid,
 				ValidatedAt: "foobar",
 				Error:       acme.NewError(acme.ErrorMalformedType, "malformed"),
+				Payload:     []byte("payload"),
 			}
 			return test{
 				ch: updCh,
@@ -429,6 +430,7 @@ func TestDB_UpdateChallenge(t *testing.T) {
 						assert.Equals(t, dbNew.Status, acme.StatusValid)
 						assert.Equals(t, dbNew.ValidatedAt, "foobar")
 						assert.Equals(t, dbNew.Error.Error(), acme.NewError(acme.ErrorMalformedType, "malformed").Error())
+						assert.Equals(t, dbNew.Payload, []byte("payload"))
 						return nu, true, nil
 					},
 				},
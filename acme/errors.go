@@ -63,6 +63,8 @@ const (
 	ErrorUserActionRequiredType
 	// ErrorNotImplementedType operation is not implemented
 	ErrorNotImplementedType
+	// ErrorBadAttestationStatementType attestation statement cannot be verified
+	ErrorBadAttestationStatementType
 )
 
 // String returns the string representation of the acme problem type,
@@ -117,6 +119,8 @@ func (ap ProblemType) String() string {
 		return "userActionRequired"
 	case ErrorNotImplementedType:
 		return "notImplemented"
+	case ErrorBadAttestationStatementType:
+		return "badAttestationStatement"
 	default:
 		return fmt.Sprintf("unsupported type ACME error type '%d'", int(ap))
 	}
@@ -257,6 +261,11 @@ var (
 			details: "Visit the “instance” URL and take actions specified there",
 			status:  400,
 		},
+		ErrorBadAttestationStatementType: {
+			typ:     officialACMEPrefix + ErrorBadAttestationStatementType.String(),
+			details: "Attestation statement cannot be verified",
+			status:  400,
+		},
 		ErrorServerInternalType: errorServerInternalMetadata,
 	}
 )
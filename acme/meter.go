@@ -0,0 +1,10 @@
+package acme
+
+// Meter is the interface used to gather metrics about the ACME operations.
+type Meter interface {
+	// OrderFinalized is called after each attempt to finalize an order.
+	OrderFinalized(provisioner string, err error)
+	// ChallengeValidated is called when a challenge reaches the valid or
+	// invalid status.
+	ChallengeValidated(provisioner, typ, status string)
+}
@@ -3,6 +3,7 @@ package acme
 import (
 	"bytes"
 	"context"
+	"crypto"
 	"crypto/x509"
 	"encoding/json"
 	"net"
@@ -21,6 +22,9 @@ const (
 	IP IdentifierType = "ip"
 	// DNS is the ACME dns identifier type
 	DNS IdentifierType = "dns"
+	// PermanentIdentifier is the ACME permanent-identifier identifier type
+	// defined in RFC 4043.
+	PermanentIdentifier IdentifierType = "permanent-identifier"
 )
 
 // Identifier encodes the type that an order pertains to.
@@ -142,13 +146,43 @@ func (o *Order) Finalize(ctx context.Context, db DB, csr *x509.CertificateReques
 		return NewErrorISE("unexpected status %s for order %s", o.Status, o.ID)
 	}
 
-	// canonicalize the CSR to allow for comparison
-	csr = canonicalize(csr)
+	// Template data
+	data := x509util.NewTemplateData()
 
-	// retrieve the requested SANs for the Order
-	sans, err := o.sans(csr)
-	if err != nil {
-		return err
+	// Custom sign options passed to authority.Sign
+	var extraOptions []provisioner.SignOption
+
+	if permanentIdentifier := o.permanentIdentifier(); permanentIdentifier != "" {
+		// The certificate request must use the attested key, the permanent
+		// identifier will be used as the subject and as the only SAN.
+		if err := o.validateAttestedKey(ctx, db, csr); err != nil {
+			return err
+		}
+		ext, err := permanentIdentifierExtension(permanentIdentifier)
+		if err != nil {
+			return WrapErrorISE(err, "error creating permanent identifier extension")
+		}
+		data.SetCommonName(permanentIdentifier)
+		extraOptions = append(extraOptions, provisioner.CertificateEnforcerFunc(func(cert *x509.Certificate) error {
+			cert.DNSNames = nil
+			cert.EmailAddresses = nil
+			cert.IPAddresses = nil
+			cert.URIs = nil
+			cert.ExtraExtensions = append(cert.ExtraExtensions, ext)
+			return nil
+		}))
+	} else {
+		// canonicalize the CSR to allow for comparison
+		csr = canonicalize(csr)
+
+		// retrieve the requested SANs for the Order
+		sans, err := o.sans(csr)
+		if err != nil {
+			return err
+		}
+
+		data.SetCommonName(csr.Subject.CommonName)
+		data.Set(x509util.SANsKey, sans)
 	}
 
 	// Get authorizations from the ACME provisioner.
@@ -158,19 +192,15 @@ func (o *Order) Finalize(ctx context.Context, db DB, csr *x509.CertificateReques
 		return WrapErrorISE(err, "error retrieving authorization options from ACME provisioner")
 	}
 
-	// Template data
-	data := x509util.NewTemplateData()
-	data.SetCommonName(csr.Subject.CommonName)
-	data.Set(x509util.SANsKey, sans)
-
 	templateOptions, err := provisioner.TemplateOptions(p.GetOptions(), data)
 	if err != nil {
 		return WrapErrorISE(err, "error creating template options from ACME provisioner")
 	}
 	signOps = append(signOps, templateOptions)
+	signOps = append(signOps, extraOptions...)
 
 	// Sign a new certificate.
-	certChain, err := auth.Sign(csr, provisioner.SignOptions{
+	certChain, err := auth.SignWithContext(ctx, csr, provisioner.SignOptions{
 		NotBefore: provisioner.NewTimeDuration(o.NotBefore),
 		NotAfter:  provisioner.NewTimeDuration(o.NotAfter),
 	}, signOps...)
@@ -196,6 +226,42 @@ func (o *Order) Finalize(ctx context.Context, db DB, csr *x509.CertificateReques
 	return nil
 }
 
+// permanentIdentifier returns the value of the permanent-identifier identifier
+// of the order, or an empty string if it does not have one.
+func (o *Order) permanentIdentifier() string {
+	for _, id := range o.Identifiers {
+		if id.Type == PermanentIdentifier {
+			return id.Value
+		}
+	}
+	return ""
+}
+
+// validateAttestedKey checks that the public key in the certificate request is
+// the key attested in the device-attest-01 challenge of the order.
+func (o *Order) validateAttestedKey(ctx context.Context, db DB, csr *x509.CertificateRequest) error {
+	for _, azID := range o.AuthorizationIDs {
+		az, err := db.GetAuthorization(ctx, azID)
+		if err != nil {
+			return WrapErrorISE(err, "error retrieving authorization %s", azID)
+		}
+		for _, ch := range az.Challenges {
+			if ch.Type != DEVICEATTEST01 || ch.Status != StatusValid {
+				continue
+			}
+			attestedKey, err := attestedPublicKey(ch.Payload)
+			if err != nil {
+				return WrapErrorISE(err, "error retrieving attested key from challenge %s", ch.ID)
+			}
+			if k, ok := csr.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); !ok || !k.Equal(attestedKey) {
+				return NewError(ErrorBadCSRType, "CSR public key does not match the attested key")
+			}
+			return nil
+		}
+	}
+	return NewError(ErrorBadCSRType, "order %s does not have a valid device-attest-01 challenge", o.ID)
+}
+
 func (o *Order) sans(csr *x509.CertificateRequest) ([]x509util.SubjectAlternativeName, error) {
 
 	var sans []x509util.SubjectAlternativeName
@@ -2,6 +2,9 @@ package acme
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/json"
@@ -273,7 +276,7 @@ type mockSignAuth struct {
 	err                   error
 }
 
-func (m *mockSignAuth) Sign(csr *x509.CertificateRequest, signOpts provisioner.SignOptions, extraOpts ...provisioner.SignOption) ([]*x509.Certificate, error) {
+func (m *mockSignAuth) SignWithContext(ctx context.Context, csr *x509.CertificateRequest, signOpts provisioner.SignOptions, extraOpts ...provisioner.SignOption) ([]*x509.Certificate, error) {
 	if m.sign != nil {
 		return m.sign(csr, signOpts, extraOpts...)
 	} else if m.err != nil {
@@ -609,6 +612,146 @@ func TestOrder_Finalize(t *testing.T) {
 				err: NewErrorISE("error updating order oID: force"),
 			}
 		},
+		"fail/permanent-identifier-key-mismatch": func(t *testing.T) test {
+			now := clock.Now()
+			o := &Order{
+				ID:               "oID",
+				AccountID:        "accID",
+				Status:           StatusReady,
+				ExpiresAt:        now.Add(5 * time.Minute),
+				AuthorizationIDs: []string{"a"},
+				Identifiers: []Identifier{
+					{Type: "permanent-identifier", Value: "12345678"},
+				},
+			}
+			_, attObj, _ := newStepAttestationObject(t, 12345678, "keyAuth")
+			key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			assert.FatalError(t, err)
+			csr := &x509.CertificateRequest{
+				PublicKey: key.Public(),
+			}
+			return test{
+				o:   o,
+				csr: csr,
+				db: &MockDB{
+					MockGetAuthorization: func(ctx context.Context, id string) (*Authorization, error) {
+						assert.Equals(t, id, "a")
+						return &Authorization{
+							ID: "a",
+							Challenges: []*Challenge{
+								{ID: "chID", Type: DEVICEATTEST01, Status: StatusValid, Payload: attObj},
+							},
+						}, nil
+					},
+				},
+				err: NewError(ErrorBadCSRType, "CSR public key does not match the attested key"),
+			}
+		},
+		"fail/permanent-identifier-no-valid-challenge": func(t *testing.T) test {
+			now := clock.Now()
+			o := &Order{
+				ID:               "oID",
+				AccountID:        "accID",
+				Status:           StatusReady,
+				ExpiresAt:        now.Add(5 * time.Minute),
+				AuthorizationIDs: []string{"a"},
+				Identifiers: []Identifier{
+					{Type: "permanent-identifier", Value: "12345678"},
+				},
+			}
+			return test{
+				o:   o,
+				csr: &x509.CertificateRequest{},
+				db: &MockDB{
+					MockGetAuthorization: func(ctx context.Context, id string) (*Authorization, error) {
+						return &Authorization{
+							ID: "a",
+							Challenges: []*Challenge{
+								{ID: "chID", Type: DEVICEATTEST01, Status: StatusPending},
+							},
+						}, nil
+					},
+				},
+				err: NewError(ErrorBadCSRType, "order oID does not have a valid device-attest-01 challenge"),
+			}
+		},
+		"ok/new-cert-permanent-identifier": func(t *testing.T) test {
+			now := clock.Now()
+			o := &Order{
+				ID:               "oID",
+				AccountID:        "accID",
+				Status:           StatusReady,
+				ExpiresAt:        now.Add(5 * time.Minute),
+				AuthorizationIDs: []string{"a"},
+				Identifiers: []Identifier{
+					{Type: "permanent-identifier", Value: "12345678"},
+				},
+			}
+			_, attObj, key := newStepAttestationObject(t, 12345678, "keyAuth")
+			csr := &x509.CertificateRequest{
+				Subject: pkix.Name{
+					CommonName: "foo.internal",
+				},
+				DNSNames:  []string{"foo.internal"},
+				PublicKey: key.Public(),
+			}
+			ext, err := permanentIdentifierExtension("12345678")
+			assert.FatalError(t, err)
+
+			foo := &x509.Certificate{Subject: pkix.Name{CommonName: "foo"}}
+			bar := &x509.Certificate{Subject: pkix.Name{CommonName: "bar"}}
+			baz := &x509.Certificate{Subject: pkix.Name{CommonName: "baz"}}
+
+			return test{
+				o:   o,
+				csr: csr,
+				prov: &MockProvisioner{
+					MauthorizeSign: func(ctx context.Context, token string) ([]provisioner.SignOption, error) {
+						return nil, nil
+					},
+					MgetOptions: func() *provisioner.Options {
+						return nil
+					},
+				},
+				ca: &mockSignAuth{
+					sign: func(_csr *x509.CertificateRequest, signOpts provisioner.SignOptions, extraOpts ...provisioner.SignOption) ([]*x509.Certificate, error) {
+						assert.Equals(t, _csr, csr)
+						var enforced bool
+						for _, op := range extraOpts {
+							if enforcer, ok := op.(provisioner.CertificateEnforcer); ok {
+								cert := &x509.Certificate{DNSNames: []string{"foo.internal"}}
+								assert.FatalError(t, enforcer.Enforce(cert))
+								assert.Equals(t, cert.DNSNames, []string(nil))
+								assert.Equals(t, cert.ExtraExtensions, []pkix.Extension{ext})
+								enforced = true
+							}
+						}
+						assert.True(t, enforced)
+						return []*x509.Certificate{foo, bar, baz}, nil
+					},
+				},
+				db: &MockDB{
+					MockGetAuthorization: func(ctx context.Context, id string) (*Authorization, error) {
+						return &Authorization{
+							ID: "a",
+							Challenges: []*Challenge{
+								{ID: "chID", Type: DEVICEATTEST01, Status: StatusValid, Payload: attObj},
+							},
+						}, nil
+					},
+					MockCreateCertificate: func(ctx context.Context, cert *Certificate) error {
+						cert.ID = "certID"
+						assert.Equals(t, cert.Leaf, foo)
+						return nil
+					},
+					MockUpdateOrder: func(ctx context.Context, updo *Order) error {
+						assert.Equals(t, updo.CertificateID, "certID")
+						assert.Equals(t, updo.Status, StatusValid)
+						return nil
+					},
+				},
+			}
+		},
 		"ok/new-cert-dns": func(t *testing.T) test {
 			now := clock.Now()
 			o := &Order{
@@ -0,0 +1,89 @@
+package acme
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+)
+
+var (
+	oidSubjectAlternativeName = asn1.ObjectIdentifier{2, 5, 29, 17}
+	oidPermanentIdentifier    = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 8, 3}
+)
+
+// permanentIdentifier is the PermanentIdentifier other name defined in RFC
+// 4043.
+type permanentIdentifier struct {
+	IdentifierValue string                `asn1:"utf8,optional"`
+	Assigner        asn1.ObjectIdentifier `asn1:"optional"`
+}
+
+// otherName is the OtherName form of a GeneralName defined in RFC 5280. The
+// value is explicitly tagged with [0].
+type otherName struct {
+	TypeID asn1.ObjectIdentifier
+	Value  asn1.RawValue
+}
+
+// permanentIdentifierExtension returns a subject alternative name extension
+// with the given permanent identifier.
+func permanentIdentifierExtension(value string) (pkix.Extension, error) {
+	pi, err := asn1.Marshal(permanentIdentifier{IdentifierValue: value})
+	if err != nil {
+		return pkix.Extension{}, fmt.Errorf("error marshaling permanent identifier: %w", err)
+	}
+	on, err := asn1.MarshalWithParams(otherName{
+		TypeID: oidPermanentIdentifier,
+		Value: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      pi,
+		},
+	}, "tag:0")
+	if err != nil {
+		return pkix.Extension{}, fmt.Errorf("error marshaling other name: %w", err)
+	}
+	san, err := asn1.Marshal([]asn1.RawValue{{FullBytes: on}})
+	if err != nil {
+		return pkix.Extension{}, fmt.Errorf("error marshaling subject alternative name: %w", err)
+	}
+	return pkix.Extension{
+		Id:    oidSubjectAlternativeName,
+		Value: san,
+	}, nil
+}
+
+// permanentIdentifiers returns the permanent identifiers in the subject
+// alternative name extension of the given certificate.
+func permanentIdentifiers(cert *x509.Certificate) ([]string, error) {
+	var ids []string
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidSubjectAlternativeName) {
+			continue
+		}
+		var names []asn1.RawValue
+		if _, err := asn1.Unmarshal(ext.Value, &names); err != nil {
+			return nil, fmt.Errorf("error parsing subject alternative name: %w", err)
+		}
+		for _, name := range names {
+			if name.Class != asn1.ClassContextSpecific || name.Tag != 0 {
+				continue
+			}
+			var on otherName
+			if _, err := asn1.UnmarshalWithParams(name.FullBytes, &on, "tag:0"); err != nil {
+				return nil, fmt.Errorf("error parsing other name: %w", err)
+			}
+			if !on.TypeID.Equal(oidPermanentIdentifier) {
+				continue
+			}
+			var pi permanentIdentifier
+			if _, err := asn1.Unmarshal(on.Value.Bytes, &pi); err != nil {
+				return nil, fmt.Errorf("error parsing permanent identifier: %w", err)
+			}
+			ids = append(ids, pi.IdentifierValue)
+		}
+	}
+	return ids, nil
+}
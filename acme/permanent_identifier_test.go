@@ -0,0 +1,59 @@
+package acme
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+)
+
+func Test_permanentIdentifiers(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+
+	newCert := func(t *testing.T, tmpl *x509.Certificate) *x509.Certificate {
+		tmpl.SerialNumber = big.NewInt(1)
+		tmpl.NotBefore = time.Now()
+		tmpl.NotAfter = time.Now().Add(time.Hour)
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+		assert.FatalError(t, err)
+		cert, err := x509.ParseCertificate(der)
+		assert.FatalError(t, err)
+		return cert
+	}
+
+	ext, err := permanentIdentifierExtension("12345678")
+	assert.FatalError(t, err)
+	assert.Equals(t, ext.Id, oidSubjectAlternativeName)
+
+	tests := []struct {
+		name    string
+		cert    *x509.Certificate
+		want    []string
+		wantErr bool
+	}{
+		{"ok", newCert(t, &x509.Certificate{ExtraExtensions: []pkix.Extension{ext}}), []string{"12345678"}, false},
+		{"ok/dns", newCert(t, &x509.Certificate{DNSNames: []string{"foo.example.com"}}), nil, false},
+		{"ok/no-san", newCert(t, &x509.Certificate{}), nil, false},
+		{"fail/san", &x509.Certificate{Extensions: []pkix.Extension{{Id: oidSubjectAlternativeName, Value: []byte("foo")}}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := permanentIdentifiers(tt.cert)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("permanentIdentifiers() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("permanentIdentifiers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
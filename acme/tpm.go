@@ -0,0 +1,296 @@
+package acme
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// TPM 2.0 constants used to decode the attestation structures, see the TPM 2.0
+// Library, Part 2: Structures.
+const (
+	tpmGeneratedValue  uint32 = 0xff544347
+	tpmSTAttestCertify uint16 = 0x8017
+
+	tpmAlgRSA    uint16 = 0x0001
+	tpmAlgSHA1   uint16 = 0x0004
+	tpmAlgSHA256 uint16 = 0x000B
+	tpmAlgSHA384 uint16 = 0x000C
+	tpmAlgSHA512 uint16 = 0x000D
+	tpmAlgNull   uint16 = 0x0010
+	tpmAlgECDAA  uint16 = 0x001A
+	tpmAlgECC    uint16 = 0x0023
+
+	tpmECCNistP256 uint16 = 0x0003
+	tpmECCNistP384 uint16 = 0x0004
+	tpmECCNistP521 uint16 = 0x0005
+
+	tpmaObjectFixedTPM uint32 = 0x00000002
+)
+
+// tpmAttestationStatement is the WebAuthn statement used by devices with a
+// TPM 2.0. The x5c contains the attestation key (AK) certificate and its
+// intermediates.
+type tpmAttestationStatement struct {
+	Ver      string   `cbor:"ver"`
+	Alg      int64    `cbor:"alg"`
+	X5C      [][]byte `cbor:"x5c"`
+	Sig      []byte   `cbor:"sig"`
+	CertInfo []byte   `cbor:"certInfo"`
+	PubArea  []byte   `cbor:"pubArea"`
+}
+
+// doTPMAttestationFormat verifies a TPM attestation statement. The certInfo
+// must be signed by the attestation key, its extra data must be the SHA-256 of
+// the key authorization, and it must certify the key in pubArea. The permanent
+// identifiers are the ones in the subject alternative names of the AK
+// certificate.
+func doTPMAttestationFormat(stmt cbor.RawMessage, roots *x509.CertPool, keyAuth string) (*attestationData, error) {
+	var att tpmAttestationStatement
+	if err := cbor.Unmarshal(stmt, &att); err != nil {
+		return nil, fmt.Errorf("error unmarshaling attestation statement: %w", err)
+	}
+	if att.Ver != "2.0" {
+		return nil, fmt.Errorf("unsupported tpm version %q", att.Ver)
+	}
+
+	certs, err := parseX5C(att.X5C)
+	if err != nil {
+		return nil, err
+	}
+	akCert, err := verifyX5C(certs, roots)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyCOSESignature(akCert.PublicKey, att.Alg, att.CertInfo, att.Sig); err != nil {
+		return nil, err
+	}
+
+	info, err := parseTPMAttestCertify(att.CertInfo)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(keyAuth))
+	if subtle.ConstantTimeCompare(info.extraData, sum[:]) != 1 {
+		return nil, errors.New("tpm extra data does not match the key authorization")
+	}
+
+	pub, err := parseTPMPublic(att.PubArea)
+	if err != nil {
+		return nil, err
+	}
+	if pub.attributes&tpmaObjectFixedTPM == 0 {
+		return nil, errors.New("tpm key is not bound to the tpm")
+	}
+	name, err := tpmName(pub.nameAlg, att.PubArea)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(info.name, name) {
+		return nil, errors.New("tpm certified name does not match the public area")
+	}
+
+	ids, err := permanentIdentifiers(akCert)
+	if err != nil {
+		return nil, err
+	}
+
+	return &attestationData{
+		PermanentIdentifiers: ids,
+		PublicKey:            pub.key,
+	}, nil
+}
+
+// tpmAttestCertify contains the fields used from a TPMS_ATTEST structure with
+// the TPM_ST_ATTEST_CERTIFY type.
+type tpmAttestCertify struct {
+	extraData []byte
+	name      []byte
+}
+
+// tpmPublic contains the fields used from a TPMT_PUBLIC structure.
+type tpmPublic struct {
+	nameAlg    uint16
+	attributes uint32
+	key        crypto.PublicKey
+}
+
+// tpmReader decodes the big-endian TPM structures. The first error is kept
+// and all the subsequent reads will return zero values.
+type tpmReader struct {
+	b   []byte
+	err error
+}
+
+func (r *tpmReader) read(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.b) < n {
+		r.err = errors.New("unexpected end of tpm structure")
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *tpmReader) uint16() uint16 {
+	if b := r.read(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *tpmReader) uint32() uint32 {
+	if b := r.read(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+// sized reads a TPM2B structure, a buffer prefixed by its 16-bit size.
+func (r *tpmReader) sized() []byte {
+	return r.read(int(r.uint16()))
+}
+
+// parseTPMAttestCertify decodes a TPMS_ATTEST structure of type
+// TPM_ST_ATTEST_CERTIFY.
+func parseTPMAttestCertify(b []byte) (*tpmAttestCertify, error) {
+	r := &tpmReader{b: b}
+	if r.uint32() != tpmGeneratedValue {
+		return nil, errors.New("tpm attestation was not generated by a tpm")
+	}
+	if r.uint16() != tpmSTAttestCertify {
+		return nil, errors.New("tpm attestation is not a certify attestation")
+	}
+	r.sized() // qualifiedSigner
+	info := &tpmAttestCertify{
+		extraData: r.sized(),
+	}
+	r.read(17) // clockInfo
+	r.read(8)  // firmwareVersion
+	info.name = r.sized()
+	r.sized() // qualifiedName
+	if r.err != nil {
+		return nil, fmt.Errorf("error parsing tpm attestation: %w", r.err)
+	}
+	return info, nil
+}
+
+// parseTPMPublic decodes a TPMT_PUBLIC structure with an RSA or an ECC key.
+func parseTPMPublic(b []byte) (*tpmPublic, error) {
+	r := &tpmReader{b: b}
+	typ := r.uint16()
+	pub := &tpmPublic{
+		nameAlg:    r.uint16(),
+		attributes: r.uint32(),
+	}
+	r.sized() // authPolicy
+
+	// TPMT_SYM_DEF_OBJECT, key bits and mode are only present if the
+	// algorithm is not null.
+	if r.uint16() != tpmAlgNull {
+		r.uint16()
+		r.uint16()
+	}
+
+	switch typ {
+	case tpmAlgRSA:
+		if r.uint16() != tpmAlgNull {
+			r.uint16() // scheme hash
+		}
+		r.uint16() // keyBits
+		exponent := r.uint32()
+		if exponent == 0 {
+			exponent = 65537
+		}
+		modulus := r.sized()
+		if r.err == nil {
+			pub.key = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(modulus),
+				E: int(exponent),
+			}
+		}
+	case tpmAlgECC:
+		if scheme := r.uint16(); scheme != tpmAlgNull {
+			r.uint16() // scheme hash
+			if scheme == tpmAlgECDAA {
+				r.uint16() // count
+			}
+		}
+		var curve elliptic.Curve
+		switch id := r.uint16(); id {
+		case tpmECCNistP256:
+			curve = elliptic.P256()
+		case tpmECCNistP384:
+			curve = elliptic.P384()
+		case tpmECCNistP521:
+			curve = elliptic.P521()
+		default:
+			if r.err == nil {
+				return nil, fmt.Errorf("unsupported tpm curve %#04x", id)
+			}
+		}
+		if r.uint16() != tpmAlgNull {
+			r.uint16() // kdf hash
+		}
+		x, y := r.sized(), r.sized()
+		if r.err == nil {
+			key := &ecdsa.PublicKey{
+				Curve: curve,
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			}
+			if !curve.IsOnCurve(key.X, key.Y) {
+				return nil, errors.New("tpm key is not on the curve")
+			}
+			pub.key = key
+		}
+	default:
+		if r.err == nil {
+			return nil, fmt.Errorf("unsupported tpm key type %#04x", typ)
+		}
+	}
+
+	if r.err != nil {
+		return nil, fmt.Errorf("error parsing tpm public area: %w", r.err)
+	}
+	return pub, nil
+}
+
+// tpmName returns the TPM name of an object, the name algorithm followed by the
+// digest of its public area.
+func tpmName(nameAlg uint16, pubArea []byte) ([]byte, error) {
+	var hash crypto.Hash
+	switch nameAlg {
+	case tpmAlgSHA1:
+		hash = crypto.SHA1
+	case tpmAlgSHA256:
+		hash = crypto.SHA256
+	case tpmAlgSHA384:
+		hash = crypto.SHA384
+	case tpmAlgSHA512:
+		hash = crypto.SHA512
+	default:
+		return nil, fmt.Errorf("unsupported tpm name algorithm %#04x", nameAlg)
+	}
+	h := hash.New()
+	h.Write(pubArea)
+
+	name := make([]byte, 2, 2+hash.Size())
+	binary.BigEndian.PutUint16(name, nameAlg)
+	return append(name, h.Sum(nil)...), nil
+}
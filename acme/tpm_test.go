@@ -0,0 +1,202 @@
+package acme
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/smallstep/assert"
+)
+
+type tpmWriter struct {
+	bytes.Buffer
+}
+
+func (w *tpmWriter) uint16(v uint16) *tpmWriter {
+	_ = binary.Write(w, binary.BigEndian, v)
+	return w
+}
+
+func (w *tpmWriter) uint32(v uint32) *tpmWriter {
+	_ = binary.Write(w, binary.BigEndian, v)
+	return w
+}
+
+func (w *tpmWriter) sized(b []byte) *tpmWriter {
+	w.uint16(uint16(len(b)))
+	w.Write(b)
+	return w
+}
+
+// newTPMPublic returns the TPMT_PUBLIC structure of an ECC P-256 key.
+func newTPMPublic(key *ecdsa.PublicKey, attributes uint32) []byte {
+	w := new(tpmWriter)
+	w.uint16(tpmAlgECC).uint16(tpmAlgSHA256).uint32(attributes)
+	w.sized(nil)             // authPolicy
+	w.uint16(tpmAlgNull)     // symmetric
+	w.uint16(tpmAlgNull)     // scheme
+	w.uint16(tpmECCNistP256) // curveID
+	w.uint16(tpmAlgNull)     // kdf
+	w.sized(key.X.FillBytes(make([]byte, 32)))
+	w.sized(key.Y.FillBytes(make([]byte, 32)))
+	return w.Bytes()
+}
+
+// newTPMAttestCertify returns a TPMS_ATTEST structure certifying the given
+// name.
+func newTPMAttestCertify(extraData, name []byte) []byte {
+	w := new(tpmWriter)
+	w.uint32(tpmGeneratedValue).uint16(tpmSTAttestCertify)
+	w.sized(nil) // qualifiedSigner
+	w.sized(extraData)
+	w.Write(make([]byte, 17)) // clockInfo
+	w.Write(make([]byte, 8))  // firmwareVersion
+	w.sized(name)
+	w.sized(nil) // qualifiedName
+	return w.Bytes()
+}
+
+type tpmAttestationOptions struct {
+	keyAuth    string
+	attributes uint32
+	name       []byte
+	ver        string
+}
+
+func newTPMAttestationStatement(t *testing.T, permanentIdentifier string, opts tpmAttestationOptions) (*x509.CertPool, []byte, crypto.PublicKey) {
+	t.Helper()
+	ca, caKey := newAttestationCA(t)
+	san, err := permanentIdentifierExtension(permanentIdentifier)
+	assert.FatalError(t, err)
+	akCert, akKey := newAttestationLeaf(t, ca, caKey, &x509.Certificate{
+		ExtraExtensions: []pkix.Extension{san},
+	})
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	pubArea := newTPMPublic(&key.PublicKey, opts.attributes)
+	name := opts.name
+	if name == nil {
+		name, err = tpmName(tpmAlgSHA256, pubArea)
+		assert.FatalError(t, err)
+	}
+	extraData := sha256.Sum256([]byte(opts.keyAuth))
+	certInfo := newTPMAttestCertify(extraData[:], name)
+	sum := sha256.Sum256(certInfo)
+	sig, err := akKey.Sign(rand.Reader, sum[:], crypto.SHA256)
+	assert.FatalError(t, err)
+
+	stmt, err := cbor.Marshal(tpmAttestationStatement{
+		Ver:      opts.ver,
+		Alg:      coseAlgES256,
+		X5C:      [][]byte{akCert.Raw, ca.Raw},
+		Sig:      sig,
+		CertInfo: certInfo,
+		PubArea:  pubArea,
+	})
+	assert.FatalError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	return roots, stmt, &key.PublicKey
+}
+
+func Test_doTPMAttestationFormat(t *testing.T) {
+	ok := tpmAttestationOptions{keyAuth: "keyAuth", attributes: tpmaObjectFixedTPM, ver: "2.0"}
+	type test struct {
+		roots   *x509.CertPool
+		stmt    []byte
+		pub     crypto.PublicKey
+		wantErr bool
+	}
+	tests := map[string]func(t *testing.T) test{
+		"ok": func(t *testing.T) test {
+			roots, stmt, pub := newTPMAttestationStatement(t, "device-id", ok)
+			return test{roots: roots, stmt: stmt, pub: pub}
+		},
+		"fail/version": func(t *testing.T) test {
+			opts := ok
+			opts.ver = "1.2"
+			roots, stmt, _ := newTPMAttestationStatement(t, "device-id", opts)
+			return test{roots: roots, stmt: stmt, wantErr: true}
+		},
+		"fail/roots": func(t *testing.T) test {
+			roots, _, _ := newTPMAttestationStatement(t, "device-id", ok)
+			_, stmt, _ := newTPMAttestationStatement(t, "device-id", ok)
+			return test{roots: roots, stmt: stmt, wantErr: true}
+		},
+		"fail/key-authorization": func(t *testing.T) test {
+			opts := ok
+			opts.keyAuth = "foo"
+			roots, stmt, _ := newTPMAttestationStatement(t, "device-id", opts)
+			return test{roots: roots, stmt: stmt, wantErr: true}
+		},
+		"fail/fixed-tpm": func(t *testing.T) test {
+			opts := ok
+			opts.attributes = 0
+			roots, stmt, _ := newTPMAttestationStatement(t, "device-id", opts)
+			return test{roots: roots, stmt: stmt, wantErr: true}
+		},
+		"fail/name": func(t *testing.T) test {
+			opts := ok
+			opts.name = []byte{0, 0x0B, 1, 2, 3}
+			roots, stmt, _ := newTPMAttestationStatement(t, "device-id", opts)
+			return test{roots: roots, stmt: stmt, wantErr: true}
+		},
+		"fail/cbor": func(t *testing.T) test {
+			roots, _, _ := newTPMAttestationStatement(t, "device-id", ok)
+			return test{roots: roots, stmt: []byte("foo"), wantErr: true}
+		},
+	}
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			tc := run(t)
+			data, err := doTPMAttestationFormat(tc.stmt, tc.roots, "keyAuth")
+			if (err != nil) != tc.wantErr {
+				t.Errorf("doTPMAttestationFormat() error = %v, wantErr %v", err, tc.wantErr)
+				return
+			}
+			if !tc.wantErr {
+				assert.Equals(t, data.PermanentIdentifiers, []string{"device-id"})
+				assert.Equals(t, data.PublicKey, tc.pub)
+			}
+		})
+	}
+}
+
+func Test_parseTPMPublic(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	pubArea := newTPMPublic(&key.PublicKey, tpmaObjectFixedTPM)
+
+	pub, err := parseTPMPublic(pubArea)
+	assert.FatalError(t, err)
+	assert.Equals(t, pub.nameAlg, tpmAlgSHA256)
+	assert.Equals(t, pub.attributes, tpmaObjectFixedTPM)
+	assert.Equals(t, pub.key, &key.PublicKey)
+
+	_, err = parseTPMPublic(pubArea[:len(pubArea)-1])
+	assert.Error(t, err)
+	_, err = parseTPMPublic([]byte{0, 0x25, 0, 0x0B, 0, 0, 0, 0, 0, 0, 0, 0x10})
+	assert.Error(t, err)
+}
+
+func Test_parseTPMAttestCertify(t *testing.T) {
+	info, err := parseTPMAttestCertify(newTPMAttestCertify([]byte("extra"), []byte("name")))
+	assert.FatalError(t, err)
+	assert.Equals(t, info.extraData, []byte("extra"))
+	assert.Equals(t, info.name, []byte("name"))
+
+	_, err = parseTPMAttestCertify([]byte{0xff, 0x54, 0x43, 0x47, 0x80, 0x14})
+	assert.Error(t, err)
+	_, err = parseTPMAttestCertify([]byte("foo"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,201 @@
+package acme
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"go.step.sm/crypto/jose"
+)
+
+const (
+	// defaultValidationWorkers is the number of workers used by the default
+	// validator.
+	defaultValidationWorkers = 10
+	// maxValidationBackoff is the maximum time between two validation
+	// attempts.
+	maxValidationBackoff = time.Minute
+)
+
+var (
+	defaultValidator     *Validator
+	defaultValidatorOnce sync.Once
+)
+
+// DefaultValidator returns the process-wide challenge validator. The validator
+// is shared by all the ACME handlers, so the in-flight validations survive a
+// CA reload.
+func DefaultValidator() *Validator {
+	defaultValidatorOnce.Do(func() {
+		defaultValidator = NewValidator(defaultValidationWorkers)
+	})
+	return defaultValidator
+}
+
+// Validator is a pool of workers that validates ACME challenges in the
+// background. Challenges are kept in the processing status while they are
+// validated, and the validations that fail with a recoverable error, like a
+// connection error, are retried with an exponential backoff until they
+// succeed, the maximum number of attempts is reached, or the deadline of the
+// order is reached. Challenges that cannot be validated are marked as invalid.
+type Validator struct {
+	workers   int
+	jobs      chan *validationJob
+	done      chan struct{}
+	startOnce sync.Once
+	stopOnce  sync.Once
+	mu        sync.Mutex
+	inflight  map[string]struct{}
+}
+
+type validationJob struct {
+	db       DB
+	ch       *Challenge
+	jwk      *jose.JSONWebKey
+	vo       *ValidateChallengeOptions
+	deadline time.Time
+	done     func(*Challenge)
+	attempt  int
+	backoff  time.Duration
+}
+
+// NewValidator creates a new Validator with the given number of workers. The
+// workers are started with the first validation.
+func NewValidator(workers int) *Validator {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Validator{
+		workers:  workers,
+		jobs:     make(chan *validationJob),
+		done:     make(chan struct{}),
+		inflight: make(map[string]struct{}),
+	}
+}
+
+// Validate queues the validation of the given challenge, that must be in the
+// processing status. The validation will not be retried after the deadline,
+// if set. The done function, if set, is called with the challenge once it
+// reaches the valid or invalid status. It returns false if the challenge is
+// already being validated.
+func (v *Validator) Validate(db DB, ch *Challenge, jwk *jose.JSONWebKey, vo *ValidateChallengeOptions, deadline time.Time, done func(*Challenge)) bool {
+	v.mu.Lock()
+	if _, ok := v.inflight[ch.ID]; ok {
+		v.mu.Unlock()
+		return false
+	}
+	v.inflight[ch.ID] = struct{}{}
+	v.mu.Unlock()
+
+	v.startOnce.Do(func() {
+		for i := 0; i < v.workers; i++ {
+			go v.worker()
+		}
+	})
+
+	v.enqueue(&validationJob{
+		db:       db,
+		ch:       ch,
+		jwk:      jwk,
+		vo:       vo,
+		deadline: deadline,
+		done:     done,
+		backoff:  vo.RetryAfter(),
+	}, 0)
+	return true
+}
+
+// IsValidating returns true if the challenge with the given id is being
+// validated.
+func (v *Validator) IsValidating(chID string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	_, ok := v.inflight[chID]
+	return ok
+}
+
+// Stop stops the workers. Queued and in-flight validations are discarded and
+// their challenges are left in the processing status.
+func (v *Validator) Stop() {
+	v.stopOnce.Do(func() {
+		close(v.done)
+	})
+}
+
+func (v *Validator) worker() {
+	for {
+		select {
+		case <-v.done:
+			return
+		case job := <-v.jobs:
+			v.process(job)
+		}
+	}
+}
+
+// enqueue sends the job to the workers after the given delay.
+func (v *Validator) enqueue(job *validationJob, delay time.Duration) {
+	go func() {
+		if delay > 0 {
+			t := time.NewTimer(delay)
+			defer t.Stop()
+			select {
+			case <-v.done:
+				return
+			case <-t.C:
+			}
+		}
+		select {
+		case <-v.done:
+		case v.jobs <- job:
+		}
+	}()
+}
+
+func (v *Validator) process(job *validationJob) {
+	ctx := context.Background()
+	ch := job.ch
+
+	job.attempt++
+	if err := ch.validate(ctx, job.db, job.jwk, job.vo, nil, nil); err != nil {
+		log.Printf("error validating acme challenge %s: %v", ch.ID, err)
+		v.finish(job)
+		return
+	}
+
+	// Only challenges with a recoverable error are still processing.
+	if ch.Status != StatusProcessing || ch.Error == nil {
+		v.finish(job)
+		return
+	}
+
+	now := clock.Now()
+	if job.attempt >= job.vo.maxAttempts() || (!job.deadline.IsZero() && now.Add(job.backoff).After(job.deadline)) {
+		ch.Status = StatusInvalid
+		if err := job.db.UpdateChallenge(ctx, ch); err != nil {
+			log.Printf("error updating acme challenge %s: %v", ch.ID, err)
+		}
+		v.finish(job)
+		return
+	}
+
+	delay := job.backoff
+	if job.backoff *= 2; job.backoff > maxValidationBackoff {
+		job.backoff = maxValidationBackoff
+	}
+	v.enqueue(job, delay)
+}
+
+func (v *Validator) finish(job *validationJob) {
+	v.mu.Lock()
+	delete(v.inflight, job.ch.ID)
+	v.mu.Unlock()
+
+	if job.done != nil {
+		switch job.ch.Status {
+		case StatusValid, StatusInvalid:
+			job.done(job.ch)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package acme
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"go.step.sm/crypto/jose"
+)
+
+// waitValidation waits until the validator is done with the given challenge.
+func waitValidation(t *testing.T, v *Validator, chID string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for v.IsValidating(chID) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for the validation of %s", chID)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestValidator_Validate(t *testing.T) {
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	assert.FatalError(t, err)
+	keyAuth, err := KeyAuthorization("token", jwk)
+	assert.FatalError(t, err)
+
+	// httpGet fails the given number of times before returning the key
+	// authorization.
+	httpGet := func(failures int, calls *int) func(string) (*http.Response, error) {
+		return func(string) (*http.Response, error) {
+			*calls++
+			if *calls <= failures {
+				return nil, errors.New("force")
+			}
+			return &http.Response{
+				Body: io.NopCloser(bytes.NewBufferString(keyAuth)),
+			}, nil
+		}
+	}
+
+	type test struct {
+		vo        *ValidateChallengeOptions
+		deadline  time.Time
+		calls     *int
+		expCalls  int
+		expStatus Status
+		expErr    *Error
+	}
+	tests := map[string]func(t *testing.T) test{
+		"ok": func(t *testing.T) test {
+			calls := new(int)
+			return test{
+				vo: &ValidateChallengeOptions{
+					HTTPGet:     httpGet(0, calls),
+					MaxAttempts: 5,
+				},
+				calls:     calls,
+				expCalls:  1,
+				expStatus: StatusValid,
+			}
+		},
+		"ok/retry": func(t *testing.T) test {
+			calls := new(int)
+			return test{
+				vo: &ValidateChallengeOptions{
+					HTTPGet:       httpGet(2, calls),
+					MaxAttempts:   5,
+					RetryInterval: time.Millisecond,
+				},
+				deadline:  time.Now().Add(time.Minute),
+				calls:     calls,
+				expCalls:  3,
+				expStatus: StatusValid,
+			}
+		},
+		"fail/max-attempts": func(t *testing.T) test {
+			calls := new(int)
+			return test{
+				vo: &ValidateChallengeOptions{
+					HTTPGet:       httpGet(10, calls),
+					MaxAttempts:   3,
+					RetryInterval: time.Millisecond,
+				},
+				calls:     calls,
+				expCalls:  3,
+				expStatus: StatusInvalid,
+				expErr:    NewError(ErrorConnectionType, "error doing http GET for url http://zap.internal/.well-known/acme-challenge/token: force"),
+			}
+		},
+		"fail/deadline": func(t *testing.T) test {
+			calls := new(int)
+			return test{
+				vo: &ValidateChallengeOptions{
+					HTTPGet:       httpGet(10, calls),
+					MaxAttempts:   5,
+					RetryInterval: time.Hour,
+				},
+				deadline:  time.Now().Add(time.Minute),
+				calls:     calls,
+				expCalls:  1,
+				expStatus: StatusInvalid,
+				expErr:    NewError(ErrorConnectionType, "error doing http GET for url http://zap.internal/.well-known/acme-challenge/token: force"),
+			}
+		},
+	}
+	for name, run := range tests {
+		tc := run(t)
+		t.Run(name, func(t *testing.T) {
+			var mu sync.Mutex
+			var updated *Challenge
+			var doneStatus Status
+			done := func(ch *Challenge) {
+				mu.Lock()
+				defer mu.Unlock()
+				doneStatus = ch.Status
+			}
+			db := &MockDB{
+				MockUpdateChallenge: func(ctx context.Context, ch *Challenge) error {
+					mu.Lock()
+					defer mu.Unlock()
+					c := *ch
+					updated = &c
+					return nil
+				},
+			}
+			ch := &Challenge{
+				ID:     "chID",
+				Type:   HTTP01,
+				Status: StatusProcessing,
+				Token:  "token",
+				Value:  "zap.internal",
+			}
+
+			v := NewValidator(2)
+			defer v.Stop()
+			assert.True(t, v.Validate(db, ch, jwk, tc.vo, tc.deadline, done))
+			waitValidation(t, v, "chID")
+
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equals(t, *tc.calls, tc.expCalls)
+			assert.Equals(t, doneStatus, tc.expStatus)
+			if assert.NotNil(t, updated) {
+				assert.Equals(t, updated.Status, tc.expStatus)
+				if tc.expErr != nil {
+					assert.Equals(t, updated.Error.Type, tc.expErr.Type)
+					assert.Equals(t, updated.Error.Detail, tc.expErr.Detail)
+					assert.Equals(t, updated.Error.Err.Error(), tc.expErr.Err.Error())
+				} else {
+					assert.Nil(t, updated.Error)
+				}
+			}
+		})
+	}
+}
+
+func TestValidator_Validate_inflight(t *testing.T) {
+	v := NewValidator(1)
+	// A stopped validator never processes the queued validations.
+	v.Stop()
+
+	assert.False(t, v.IsValidating("chID"))
+	assert.True(t, v.Validate(&MockDB{}, &Challenge{ID: "chID"}, nil, nil, time.Time{}, nil))
+	assert.True(t, v.IsValidating("chID"))
+	assert.False(t, v.Validate(&MockDB{}, &Challenge{ID: "chID"}, nil, nil, time.Time{}, nil))
+	assert.True(t, v.Validate(&MockDB{}, &Challenge{ID: "otherID"}, nil, nil, time.Time{}, nil))
+}
+
+func TestDefaultValidator(t *testing.T) {
+	v := DefaultValidator()
+	assert.NotNil(t, v)
+	assert.Equals(t, v.workers, defaultValidationWorkers)
+	assert.True(t, v == DefaultValidator())
+}
@@ -40,8 +40,10 @@ type Authority interface {
 	GetTLSOptions() *config.TLSOptions
 	Root(shasum string) (*x509.Certificate, error)
 	Sign(cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]*x509.Certificate, error)
+	SignWithContext(ctx context.Context, cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]*x509.Certificate, error)
 	Renew(peer *x509.Certificate) ([]*x509.Certificate, error)
 	Rekey(peer *x509.Certificate, pk crypto.PublicKey) ([]*x509.Certificate, error)
+	RekeyWithCSR(peer *x509.Certificate, csr *x509.CertificateRequest) ([]*x509.Certificate, error)
 	LoadProvisionerByCertificate(*x509.Certificate) (provisioner.Interface, error)
 	LoadProvisionerByName(string) (provisioner.Interface, error)
 	GetProvisioners(cursor string, limit int) (provisioner.List, string, error)
@@ -49,6 +51,10 @@ type Authority interface {
 	GetEncryptedKey(kid string) (string, error)
 	GetRoots() ([]*x509.Certificate, error)
 	GetFederation() ([]*x509.Certificate, error)
+	GetIntermediates() ([]*x509.Certificate, error)
+	GetCertificateRevocationList() ([]byte, error)
+	GetIssuerCertificateRevocationList(fingerprint string) ([]byte, error)
+	GetOCSPResponse(der []byte) ([]byte, error)
 	Version() authority.Version
 }
 
@@ -228,14 +234,18 @@ type ProvisionerKeyResponse struct {
 	Key string `json:"key"`
 }
 
-// RootsResponse is the response object of the roots request.
+// RootsResponse is the response object of the roots request. During an
+// intermediate rotation it also contains the old and new intermediates.
 type RootsResponse struct {
-	Certificates []Certificate `json:"crts"`
+	Certificates  []Certificate `json:"crts"`
+	Intermediates []Certificate `json:"intermediates,omitempty"`
 }
 
-// FederationResponse is the response object of the federation request.
+// FederationResponse is the response object of the federation request. During
+// an intermediate rotation it also contains the old and new intermediates.
 type FederationResponse struct {
-	Certificates []Certificate `json:"crts"`
+	Certificates  []Certificate `json:"crts"`
+	Intermediates []Certificate `json:"intermediates,omitempty"`
 }
 
 // caHandler is the type used to implement the different CA HTTP endpoints.
@@ -263,6 +273,10 @@ func (h *caHandler) Route(r Router) {
 	r.MethodFunc("GET", "/roots", h.Roots)
 	r.MethodFunc("GET", "/roots.pem", h.RootsPEM)
 	r.MethodFunc("GET", "/federation", h.Federation)
+	r.MethodFunc("GET", "/crl", h.CRL)
+	r.MethodFunc("GET", "/crl.pem", h.CRLPEM)
+	r.MethodFunc("GET", "/ocsp/*", h.OCSP)
+	r.MethodFunc("POST", "/ocsp", h.OCSP)
 	// SSH CA
 	r.MethodFunc("POST", "/ssh/sign", h.SSHSign)
 	r.MethodFunc("POST", "/ssh/renew", h.SSHRenew)
@@ -275,6 +289,7 @@ func (h *caHandler) Route(r Router) {
 	r.MethodFunc("POST", "/ssh/check-host", h.SSHCheckHost)
 	r.MethodFunc("GET", "/ssh/hosts", h.SSHGetHosts)
 	r.MethodFunc("POST", "/ssh/bastion", h.SSHBastion)
+	r.MethodFunc("GET", "/ssh/krl", h.SSHKRL)
 
 	// For compatibility with old code:
 	r.MethodFunc("POST", "/re-sign", h.Renew)
@@ -362,8 +377,15 @@ func (h *caHandler) Roots(w http.ResponseWriter, r *http.Request) {
 		certs[i] = Certificate{roots[i]}
 	}
 
+	intermediates, err := h.getIntermediates()
+	if err != nil {
+		render.Error(w, errs.ForbiddenErr(err, "error getting intermediates"))
+		return
+	}
+
 	render.JSONStatus(w, &RootsResponse{
-		Certificates: certs,
+		Certificates:  certs,
+		Intermediates: intermediates,
 	}, http.StatusCreated)
 }
 
@@ -403,11 +425,32 @@ func (h *caHandler) Federation(w http.ResponseWriter, r *http.Request) {
 		certs[i] = Certificate{federated[i]}
 	}
 
+	intermediates, err := h.getIntermediates()
+	if err != nil {
+		render.Error(w, errs.ForbiddenErr(err, "error getting intermediates"))
+		return
+	}
+
 	render.JSONStatus(w, &FederationResponse{
-		Certificates: certs,
+		Certificates:  certs,
+		Intermediates: intermediates,
 	}, http.StatusCreated)
 }
 
+// getIntermediates returns the intermediates published during an intermediate
+// rotation.
+func (h *caHandler) getIntermediates() ([]Certificate, error) {
+	intermediates, err := h.Authority.GetIntermediates()
+	if err != nil {
+		return nil, err
+	}
+	var certs []Certificate
+	for _, crt := range intermediates {
+		certs = append(certs, Certificate{crt})
+	}
+	return certs, nil
+}
+
 var oidStepProvisioner = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 37476, 9000, 64, 1}
 
 type stepProvisioner struct {
@@ -188,6 +188,10 @@ type mockAuthority struct {
 	getEncryptedKey              func(kid string) (string, error)
 	getRoots                     func() ([]*x509.Certificate, error)
 	getFederation                func() ([]*x509.Certificate, error)
+	getIntermediates             func() ([]*x509.Certificate, error)
+	getCertificateRevocationList func() ([]byte, error)
+	getIssuerCRL                 func(fingerprint string) ([]byte, error)
+	getOCSPResponse              func(der []byte) ([]byte, error)
 	signSSH                      func(ctx context.Context, key ssh.PublicKey, opts provisioner.SignSSHOptions, signOpts ...provisioner.SignOption) (*ssh.Certificate, error)
 	signSSHAddUser               func(ctx context.Context, key ssh.PublicKey, cert *ssh.Certificate) (*ssh.Certificate, error)
 	renewSSH                     func(ctx context.Context, cert *ssh.Certificate) (*ssh.Certificate, error)
@@ -198,6 +202,7 @@ type mockAuthority struct {
 	getSSHConfig                 func(ctx context.Context, typ string, data map[string]string) ([]templates.Output, error)
 	checkSSHHost                 func(ctx context.Context, principal, token string) (bool, error)
 	getSSHBastion                func(ctx context.Context, user string, hostname string) (*authority.Bastion, error)
+	getSSHRevocationList         func(ctx context.Context) ([]byte, error)
 	version                      func() authority.Version
 }
 
@@ -241,6 +246,10 @@ func (m *mockAuthority) Sign(cr *x509.CertificateRequest, opts provisioner.SignO
 	return []*x509.Certificate{m.ret1.(*x509.Certificate), m.ret2.(*x509.Certificate)}, m.err
 }
 
+func (m *mockAuthority) SignWithContext(ctx context.Context, cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]*x509.Certificate, error) {
+	return m.Sign(cr, opts, signOpts...)
+}
+
 func (m *mockAuthority) Renew(cert *x509.Certificate) ([]*x509.Certificate, error) {
 	if m.renew != nil {
 		return m.renew(cert)
@@ -255,6 +264,10 @@ func (m *mockAuthority) Rekey(oldcert *x509.Certificate, pk crypto.PublicKey) ([
 	return []*x509.Certificate{m.ret1.(*x509.Certificate), m.ret2.(*x509.Certificate)}, m.err
 }
 
+func (m *mockAuthority) RekeyWithCSR(oldcert *x509.Certificate, csr *x509.CertificateRequest) ([]*x509.Certificate, error) {
+	return m.Rekey(oldcert, csr.PublicKey)
+}
+
 func (m *mockAuthority) GetProvisioners(nextCursor string, limit int) (provisioner.List, string, error) {
 	if m.getProvisioners != nil {
 		return m.getProvisioners(nextCursor, limit)
@@ -304,6 +317,34 @@ func (m *mockAuthority) GetFederation() ([]*x509.Certificate, error) {
 	return m.ret1.([]*x509.Certificate), m.err
 }
 
+func (m *mockAuthority) GetIntermediates() ([]*x509.Certificate, error) {
+	if m.getIntermediates != nil {
+		return m.getIntermediates()
+	}
+	return nil, nil
+}
+
+func (m *mockAuthority) GetCertificateRevocationList() ([]byte, error) {
+	if m.getCertificateRevocationList != nil {
+		return m.getCertificateRevocationList()
+	}
+	return m.ret1.([]byte), m.err
+}
+
+func (m *mockAuthority) GetIssuerCertificateRevocationList(fingerprint string) ([]byte, error) {
+	if m.getIssuerCRL != nil {
+		return m.getIssuerCRL(fingerprint)
+	}
+	return m.ret1.([]byte), m.err
+}
+
+func (m *mockAuthority) GetOCSPResponse(der []byte) ([]byte, error) {
+	if m.getOCSPResponse != nil {
+		return m.getOCSPResponse(der)
+	}
+	return m.ret1.([]byte), m.err
+}
+
 func (m *mockAuthority) SignSSH(ctx context.Context, key ssh.PublicKey, opts provisioner.SignSSHOptions, signOpts ...provisioner.SignOption) (*ssh.Certificate, error) {
 	if m.signSSH != nil {
 		return m.signSSH(ctx, key, opts, signOpts...)
@@ -374,6 +415,13 @@ func (m *mockAuthority) GetSSHBastion(ctx context.Context, user, hostname string
 	return m.ret1.(*authority.Bastion), m.err
 }
 
+func (m *mockAuthority) GetSSHRevocationList(ctx context.Context) ([]byte, error) {
+	if m.getSSHRevocationList != nil {
+		return m.getSSHRevocationList(ctx)
+	}
+	return m.ret1.([]byte), m.err
+}
+
 func (m *mockAuthority) Version() authority.Version {
 	if m.version != nil {
 		return m.version()
@@ -1384,6 +1432,55 @@ func Test_caHandler_RootsPEM(t *testing.T) {
 	}
 }
 
+func Test_caHandler_Roots_intermediates(t *testing.T) {
+	root := parseCertificate(rootPEM)
+	intermediate := parseCertificate(certPEM)
+	tests := []struct {
+		name             string
+		getIntermediates func() ([]*x509.Certificate, error)
+		statusCode       int
+		expected         []byte
+	}{
+		{"ok", func() ([]*x509.Certificate, error) {
+			return []*x509.Certificate{intermediate}, nil
+		}, http.StatusCreated, []byte(`{"crts":["` + strings.ReplaceAll(rootPEM, "\n", `\n`) + `\n"],"intermediates":["` + strings.ReplaceAll(certPEM, "\n", `\n`) + `\n"]}`)},
+		{"fail", func() ([]*x509.Certificate, error) {
+			return nil, fmt.Errorf("an error")
+		}, http.StatusForbidden, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(&mockAuthority{
+				getRoots: func() ([]*x509.Certificate, error) {
+					return []*x509.Certificate{root}, nil
+				},
+				getFederation: func() ([]*x509.Certificate, error) {
+					return []*x509.Certificate{root}, nil
+				},
+				getIntermediates: tt.getIntermediates,
+			}).(*caHandler)
+
+			for _, fn := range []http.HandlerFunc{h.Roots, h.Federation} {
+				req := httptest.NewRequest("GET", "http://example.com/roots", nil)
+				w := httptest.NewRecorder()
+				fn(w, req)
+				res := w.Result()
+				if res.StatusCode != tt.statusCode {
+					t.Errorf("caHandler StatusCode = %d, wants %d", res.StatusCode, tt.statusCode)
+				}
+				body, err := io.ReadAll(res.Body)
+				res.Body.Close()
+				if err != nil {
+					t.Errorf("caHandler unexpected error = %v", err)
+				}
+				if tt.statusCode < http.StatusBadRequest && !bytes.Equal(bytes.TrimSpace(body), tt.expected) {
+					t.Errorf("caHandler Body = %s, wants %s", body, tt.expected)
+				}
+			}
+		})
+	}
+}
+
 func Test_caHandler_Federation(t *testing.T) {
 	cs := &tls.ConnectionState{
 		PeerCertificates: []*x509.Certificate{parseCertificate(certPEM)},
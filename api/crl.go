@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/pem"
+	"net/http"
+
+	"github.com/smallstep/certificates/api/log"
+	"github.com/smallstep/certificates/api/render"
+)
+
+// getCRL returns the CRL of the intermediate with the fingerprint in the
+// issuer query parameter, or the current CRL if it's not set.
+func (h *caHandler) getCRL(r *http.Request) ([]byte, error) {
+	if issuer := r.URL.Query().Get("issuer"); issuer != "" {
+		return h.Authority.GetIssuerCertificateRevocationList(issuer)
+	}
+	return h.Authority.GetCertificateRevocationList()
+}
+
+// CRL is an HTTP handler that returns the current CRL in DER format.
+func (h *caHandler) CRL(w http.ResponseWriter, r *http.Request) {
+	crlBytes, err := h.getCRL(r)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pkix-crl")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"crl.der\"")
+	if _, err := w.Write(crlBytes); err != nil {
+		log.Error(w, err)
+	}
+}
+
+// CRLPEM is an HTTP handler that returns the current CRL in PEM format.
+func (h *caHandler) CRLPEM(w http.ResponseWriter, r *http.Request) {
+	crlBytes, err := h.getCRL(r)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"crl.pem\"")
+	block := pem.EncodeToMemory(&pem.Block{
+		Type:  "X509 CRL",
+		Bytes: crlBytes,
+	})
+	if _, err := w.Write(block); err != nil {
+		log.Error(w, err)
+	}
+}
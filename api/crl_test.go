@@ -0,0 +1,97 @@
+package api
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/smallstep/certificates/errs"
+)
+
+func Test_caHandler_CRL(t *testing.T) {
+	crlBytes := []byte("a-der-encoded-crl")
+	tests := []struct {
+		name        string
+		pem         bool
+		crl         []byte
+		err         error
+		statusCode  int
+		contentType string
+		expected    []byte
+	}{
+		{"ok", false, crlBytes, nil, http.StatusOK, "application/pkix-crl", crlBytes},
+		{"ok pem", true, crlBytes, nil, http.StatusOK, "application/x-pem-file", pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crlBytes})},
+		{"fail disabled", false, nil, errs.NotFound("crl not enabled"), http.StatusNotFound, "", nil},
+		{"fail pem disabled", true, nil, errs.NotFound("crl not enabled"), http.StatusNotFound, "", nil},
+		{"fail", false, nil, errors.New("an error"), http.StatusInternalServerError, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(&mockAuthority{ret1: tt.crl, err: tt.err}).(*caHandler)
+			w := httptest.NewRecorder()
+			if tt.pem {
+				h.CRLPEM(w, httptest.NewRequest("GET", "http://example.com/crl.pem", nil))
+			} else {
+				h.CRL(w, httptest.NewRequest("GET", "http://example.com/crl", nil))
+			}
+			res := w.Result()
+
+			if res.StatusCode != tt.statusCode {
+				t.Errorf("caHandler.CRL StatusCode = %d, wants %d", res.StatusCode, tt.statusCode)
+			}
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			if err != nil {
+				t.Errorf("caHandler.CRL unexpected error = %v", err)
+			}
+			if tt.statusCode < http.StatusBadRequest {
+				if ct := res.Header.Get("Content-Type"); ct != tt.contentType {
+					t.Errorf("caHandler.CRL Content-Type = %s, wants %s", ct, tt.contentType)
+				}
+				if !bytes.Equal(body, tt.expected) {
+					t.Errorf("caHandler.CRL Body = %s, wants %s", body, tt.expected)
+				}
+			}
+		})
+	}
+}
+
+func Test_caHandler_CRL_issuer(t *testing.T) {
+	crlBytes := []byte("a-der-encoded-crl")
+	h := New(&mockAuthority{
+		getCertificateRevocationList: func() ([]byte, error) {
+			t.Error("GetCertificateRevocationList should not be called")
+			return nil, nil
+		},
+		getIssuerCRL: func(fingerprint string) ([]byte, error) {
+			if fingerprint != "abcdef" {
+				return nil, errs.NotFound("issuer not found")
+			}
+			return crlBytes, nil
+		},
+	}).(*caHandler)
+
+	w := httptest.NewRecorder()
+	h.CRL(w, httptest.NewRequest("GET", "http://example.com/crl?issuer=abcdef", nil))
+	res := w.Result()
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatalf("caHandler.CRL unexpected error = %v", err)
+	}
+	if res.StatusCode != http.StatusOK || !bytes.Equal(body, crlBytes) {
+		t.Errorf("caHandler.CRL = %d %s, wants %d %s", res.StatusCode, body, http.StatusOK, crlBytes)
+	}
+
+	w = httptest.NewRecorder()
+	h.CRLPEM(w, httptest.NewRequest("GET", "http://example.com/crl.pem?issuer=012345", nil))
+	if res := w.Result(); res.StatusCode != http.StatusNotFound {
+		t.Errorf("caHandler.CRLPEM StatusCode = %d, wants %d", res.StatusCode, http.StatusNotFound)
+	}
+}
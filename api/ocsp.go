@@ -0,0 +1,61 @@
+package api
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi"
+
+	"github.com/smallstep/certificates/api/log"
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/errs"
+)
+
+// maxOCSPRequestSize is the maximum size of an OCSP request sent using POST.
+const maxOCSPRequestSize = 64 * 1024
+
+// RouteOCSP adds the OCSP responder endpoints to the given router. OCSP clients
+// usually send requests over plain HTTP, so this is used to serve the OCSP
+// responder on the insecure address.
+func RouteOCSP(r Router, auth Authority) {
+	h := &caHandler{
+		Authority: auth,
+	}
+	r.MethodFunc("GET", "/ocsp/*", h.OCSP)
+	r.MethodFunc("POST", "/ocsp", h.OCSP)
+}
+
+// OCSP is an HTTP handler that implements an OCSP responder as described in
+// RFC 6960. The DER encoded request can be sent in the body of a POST request,
+// or base64 encoded in the path of a GET request.
+func (h *caHandler) OCSP(w http.ResponseWriter, r *http.Request) {
+	var (
+		der []byte
+		err error
+	)
+	if r.Method == http.MethodGet {
+		var encoded string
+		if encoded, err = url.PathUnescape(chi.URLParam(r, "*")); err == nil {
+			der, err = base64.StdEncoding.DecodeString(encoded)
+		}
+	} else {
+		der, err = io.ReadAll(io.LimitReader(r.Body, maxOCSPRequestSize))
+	}
+	if err != nil {
+		render.Error(w, errs.BadRequestErr(err, "error reading ocsp request"))
+		return
+	}
+
+	resp, err := h.Authority.GetOCSPResponse(der)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	if _, err := w.Write(resp); err != nil {
+		log.Error(w, err)
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/smallstep/certificates/errs"
+)
+
+func Test_caHandler_OCSP(t *testing.T) {
+	// The request does not need to be valid, the authority parses it.
+	ocspReq := []byte{0x30, 0x03, 0x02, 0x01, 0x01, 0xfb, 0xff}
+	ocspResp := []byte("a-der-encoded-ocsp-response")
+
+	getRequest := func(path string) *http.Request {
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("*", path)
+		req := httptest.NewRequest("GET", "http://example.com/ocsp/"+path, nil)
+		return req.WithContext(context.WithValue(context.Background(), chi.RouteCtxKey, chiCtx))
+	}
+	postRequest := func(body []byte) *http.Request {
+		req := httptest.NewRequest("POST", "http://example.com/ocsp", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/ocsp-request")
+		return req
+	}
+
+	tests := []struct {
+		name       string
+		req        *http.Request
+		wantReq    []byte
+		resp       []byte
+		err        error
+		statusCode int
+		expected   []byte
+	}{
+		{"ok GET", getRequest(base64.StdEncoding.EncodeToString(ocspReq)), ocspReq, ocspResp, nil, http.StatusOK, ocspResp},
+		{"ok GET escaped", getRequest(url.PathEscape(base64.StdEncoding.EncodeToString(ocspReq))), ocspReq, ocspResp, nil, http.StatusOK, ocspResp},
+		{"ok POST", postRequest(ocspReq), ocspReq, ocspResp, nil, http.StatusOK, ocspResp},
+		{"ok malformed", postRequest([]byte("foo")), []byte("foo"), ocsp.MalformedRequestErrorResponse, nil, http.StatusOK, ocsp.MalformedRequestErrorResponse},
+		{"fail GET bad base64", getRequest("not*base64"), nil, nil, nil, http.StatusBadRequest, nil},
+		{"fail disabled", postRequest(ocspReq), ocspReq, nil, errs.NotFound("ocsp responder is not enabled"), http.StatusNotFound, nil},
+		{"fail", postRequest(ocspReq), ocspReq, nil, errors.New("an error"), http.StatusInternalServerError, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(&mockAuthority{
+				getOCSPResponse: func(der []byte) ([]byte, error) {
+					if !bytes.Equal(der, tt.wantReq) {
+						t.Errorf("caHandler.OCSP request = %x, wants %x", der, tt.wantReq)
+					}
+					return tt.resp, tt.err
+				},
+			}).(*caHandler)
+			w := httptest.NewRecorder()
+			h.OCSP(w, tt.req)
+			res := w.Result()
+
+			if res.StatusCode != tt.statusCode {
+				t.Errorf("caHandler.OCSP StatusCode = %d, wants %d", res.StatusCode, tt.statusCode)
+			}
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			if err != nil {
+				t.Errorf("caHandler.OCSP unexpected error = %v", err)
+			}
+			if tt.statusCode == http.StatusOK {
+				if ct := res.Header.Get("Content-Type"); ct != "application/ocsp-response" {
+					t.Errorf("caHandler.OCSP Content-Type = %s, wants application/ocsp-response", ct)
+				}
+				if !bytes.Equal(body, tt.expected) {
+					t.Errorf("caHandler.OCSP Body = %x, wants %x", body, tt.expected)
+				}
+			}
+		})
+	}
+}
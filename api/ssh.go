@@ -11,6 +11,7 @@ import (
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/ssh"
 
+	"github.com/smallstep/certificates/api/log"
 	"github.com/smallstep/certificates/api/read"
 	"github.com/smallstep/certificates/api/render"
 	"github.com/smallstep/certificates/authority"
@@ -32,6 +33,7 @@ type SSHAuthority interface {
 	CheckSSHHost(ctx context.Context, principal string, token string) (bool, error)
 	GetSSHHosts(ctx context.Context, cert *x509.Certificate) ([]config.Host, error)
 	GetSSHBastion(ctx context.Context, user string, hostname string) (*config.Bastion, error)
+	GetSSHRevocationList(ctx context.Context) ([]byte, error)
 }
 
 // SSHSignRequest is the request body of an SSH certificate request.
@@ -327,7 +329,7 @@ func (h *caHandler) SSHSign(w http.ResponseWriter, r *http.Request) {
 			NotAfter:  time.Unix(int64(cert.ValidBefore), 0),
 		})
 
-		certChain, err := h.Authority.Sign(cr, provisioner.SignOptions{}, signOpts...)
+		certChain, err := h.Authority.SignWithContext(ctx, cr, provisioner.SignOptions{}, signOpts...)
 		if err != nil {
 			render.Error(w, errs.ForbiddenErr(err, "error signing identity certificate"))
 			return
@@ -464,6 +466,22 @@ func (h *caHandler) SSHGetHosts(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// SSHKRL is the HTTP handler that returns the OpenSSH key revocation list
+// (KRL) with the revoked SSH certificates.
+func (h *caHandler) SSHKRL(w http.ResponseWriter, r *http.Request) {
+	krl, err := h.Authority.GetSSHRevocationList(r.Context())
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"revoked_keys\"")
+	if _, err := w.Write(krl); err != nil {
+		log.Error(w, err)
+	}
+}
+
 // SSHBastion provides returns the bastion configured if any.
 func (h *caHandler) SSHBastion(w http.ResponseWriter, r *http.Request) {
 	var body SSHBastionRequest
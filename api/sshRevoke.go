@@ -21,6 +21,7 @@ type SSHRevokeResponse struct {
 // SSHRevokeRequest is the request body for a revocation request.
 type SSHRevokeRequest struct {
 	Serial     string `json:"serial"`
+	KeyID      string `json:"keyID,omitempty"`
 	OTT        string `json:"ott"`
 	ReasonCode int    `json:"reasonCode"`
 	Reason     string `json:"reason"`
@@ -62,6 +63,7 @@ func (h *caHandler) SSHRevoke(w http.ResponseWriter, r *http.Request) {
 
 	opts := &authority.RevokeOptions{
 		Serial:      body.Serial,
+		KeyID:       body.KeyID,
 		Reason:      body.Reason,
 		ReasonCode:  body.ReasonCode,
 		PassiveOnly: body.Passive,
@@ -90,6 +92,7 @@ func logSSHRevoke(w http.ResponseWriter, ri *authority.RevokeOptions) {
 	if rl, ok := w.(logging.ResponseLogger); ok {
 		rl.WithFields(map[string]interface{}{
 			"serial":      ri.Serial,
+			"keyID":       ri.KeyID,
 			"reasonCode":  ri.ReasonCode,
 			"reason":      ri.Reason,
 			"passiveOnly": ri.PassiveOnly,
@@ -23,6 +23,7 @@ import (
 	"github.com/smallstep/assert"
 	"github.com/smallstep/certificates/authority"
 	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/errs"
 	"github.com/smallstep/certificates/logging"
 	"github.com/smallstep/certificates/templates"
 )
@@ -461,6 +462,51 @@ func Test_caHandler_SSHFederation(t *testing.T) {
 	}
 }
 
+func Test_caHandler_SSHKRL(t *testing.T) {
+	krl := []byte("SSHKRL\n\x00")
+
+	tests := []struct {
+		name       string
+		krl        []byte
+		krlErr     error
+		statusCode int
+	}{
+		{"ok", krl, nil, http.StatusOK},
+		{"not found", nil, errs.NotFound("ssh is not configured"), http.StatusNotFound},
+		{"error", nil, fmt.Errorf("an error"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(&mockAuthority{
+				getSSHRevocationList: func(ctx context.Context) ([]byte, error) {
+					return tt.krl, tt.krlErr
+				},
+			}).(*caHandler)
+
+			req := httptest.NewRequest("GET", "http://example.com/ssh/krl", http.NoBody)
+			w := httptest.NewRecorder()
+			h.SSHKRL(logging.NewResponseLogger(w), req)
+			res := w.Result()
+
+			if res.StatusCode != tt.statusCode {
+				t.Errorf("caHandler.SSHKRL StatusCode = %d, wants %d", res.StatusCode, tt.statusCode)
+			}
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			if err != nil {
+				t.Errorf("caHandler.SSHKRL unexpected error = %v", err)
+			}
+			if tt.statusCode < http.StatusBadRequest {
+				assert.Equals(t, "application/octet-stream", res.Header.Get("Content-Type"))
+				if !bytes.Equal(body, tt.krl) {
+					t.Errorf("caHandler.SSHKRL Body = %x, wants %x", body, tt.krl)
+				}
+			}
+		})
+	}
+}
+
 func Test_caHandler_SSHConfig(t *testing.T) {
 	userOutput := []templates.Output{
 		{Name: "config.tpl", Type: templates.File, Comment: "#", Path: "ssh/config", Content: []byte("UserKnownHostsFile /home/user/.step/ssh/known_hosts")},
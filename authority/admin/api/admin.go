@@ -2,17 +2,23 @@ package api
 
 import (
 	"context"
+	"crypto/x509"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi"
 
 	"go.step.sm/linkedca"
+	"golang.org/x/crypto/ssh"
 
 	"github.com/smallstep/certificates/api"
 	"github.com/smallstep/certificates/api/read"
 	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority"
 	"github.com/smallstep/certificates/authority/admin"
 	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/db"
+	"github.com/smallstep/certificates/policy"
 )
 
 type adminAuthority interface {
@@ -29,6 +35,21 @@ type adminAuthority interface {
 	LoadProvisionerByID(id string) (provisioner.Interface, error)
 	UpdateProvisioner(ctx context.Context, nu *linkedca.Provisioner) error
 	RemoveProvisioner(ctx context.Context, id string) error
+	GetCertificates(opts db.ListCertificatesOptions) ([]*db.CertificateInfo, string, error)
+	CreateSCEPChallenge(ctx context.Context, provisionerName, subject string, lifetime time.Duration) (string, *db.SCEPChallenge, error)
+	GetSCEPRequests(ctx context.Context, provisionerName string) ([]*db.SCEPRequest, error)
+	ReviewSCEPRequest(ctx context.Context, provisionerName, id string, approve bool) (*db.SCEPRequest, error)
+	GetAuthorityPolicy(ctx context.Context) (*policy.Options, error)
+	CreateAuthorityPolicy(ctx context.Context, pol *policy.Options) error
+	UpdateAuthorityPolicy(ctx context.Context, pol *policy.Options) error
+	RemoveAuthorityPolicy(ctx context.Context) error
+	GetProvisionerPolicy(ctx context.Context, provisionerName string) (*policy.Options, error)
+	CreateProvisionerPolicy(ctx context.Context, provisionerName string, pol *policy.Options) error
+	UpdateProvisionerPolicy(ctx context.Context, provisionerName string, pol *policy.Options) error
+	RemoveProvisionerPolicy(ctx context.Context, provisionerName string) error
+	EvaluateX509Policy(provisionerName string, cert *x509.Certificate) ([]authority.PolicyEvaluation, error)
+	EvaluateSSHPolicy(provisionerName string, cert *ssh.Certificate) ([]authority.PolicyEvaluation, error)
+	RotateIntermediate(ctx context.Context, req *authority.RotateIntermediateRequest) (*authority.RotateIntermediateResponse, error)
 }
 
 // CreateAdminRequest represents the body for a CreateAdmin request.
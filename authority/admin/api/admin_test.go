@@ -3,6 +3,7 @@ package api
 import (
 	"bytes"
 	"context"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"io"
@@ -15,9 +16,13 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority"
 	"github.com/smallstep/certificates/authority/admin"
 	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/db"
+	"github.com/smallstep/certificates/policy"
 	"go.step.sm/linkedca"
+	"golang.org/x/crypto/ssh"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -37,6 +42,23 @@ type mockAdminAuthority struct {
 	MockLoadProvisionerByID   func(id string) (provisioner.Interface, error)
 	MockUpdateProvisioner     func(ctx context.Context, nu *linkedca.Provisioner) error
 	MockRemoveProvisioner     func(ctx context.Context, id string) error
+	MockGetCertificates       func(opts db.ListCertificatesOptions) ([]*db.CertificateInfo, string, error)
+	MockCreateSCEPChallenge   func(ctx context.Context, provisionerName, subject string, lifetime time.Duration) (string, *db.SCEPChallenge, error)
+	MockGetSCEPRequests       func(ctx context.Context, provisionerName string) ([]*db.SCEPRequest, error)
+	MockReviewSCEPRequest     func(ctx context.Context, provisionerName, id string, approve bool) (*db.SCEPRequest, error)
+
+	MockGetAuthorityPolicy      func(ctx context.Context) (*policy.Options, error)
+	MockCreateAuthorityPolicy   func(ctx context.Context, pol *policy.Options) error
+	MockUpdateAuthorityPolicy   func(ctx context.Context, pol *policy.Options) error
+	MockRemoveAuthorityPolicy   func(ctx context.Context) error
+	MockGetProvisionerPolicy    func(ctx context.Context, provisionerName string) (*policy.Options, error)
+	MockCreateProvisionerPolicy func(ctx context.Context, provisionerName string, pol *policy.Options) error
+	MockUpdateProvisionerPolicy func(ctx context.Context, provisionerName string, pol *policy.Options) error
+	MockRemoveProvisionerPolicy func(ctx context.Context, provisionerName string) error
+	MockEvaluateX509Policy      func(provisionerName string, cert *x509.Certificate) ([]authority.PolicyEvaluation, error)
+	MockEvaluateSSHPolicy       func(provisionerName string, cert *ssh.Certificate) ([]authority.PolicyEvaluation, error)
+
+	MockRotateIntermediate func(ctx context.Context, req *authority.RotateIntermediateRequest) (*authority.RotateIntermediateResponse, error)
 }
 
 func (m *mockAdminAuthority) IsAdminAPIEnabled() bool {
@@ -130,6 +152,111 @@ func (m *mockAdminAuthority) RemoveProvisioner(ctx context.Context, id string) e
 	return m.MockErr
 }
 
+func (m *mockAdminAuthority) GetCertificates(opts db.ListCertificatesOptions) ([]*db.CertificateInfo, string, error) {
+	if m.MockGetCertificates != nil {
+		return m.MockGetCertificates(opts)
+	}
+	return m.MockRet1.([]*db.CertificateInfo), m.MockRet2.(string), m.MockErr
+}
+
+func (m *mockAdminAuthority) CreateSCEPChallenge(ctx context.Context, provisionerName, subject string, lifetime time.Duration) (string, *db.SCEPChallenge, error) {
+	if m.MockCreateSCEPChallenge != nil {
+		return m.MockCreateSCEPChallenge(ctx, provisionerName, subject, lifetime)
+	}
+	return m.MockRet1.(string), m.MockRet2.(*db.SCEPChallenge), m.MockErr
+}
+
+func (m *mockAdminAuthority) GetSCEPRequests(ctx context.Context, provisionerName string) ([]*db.SCEPRequest, error) {
+	if m.MockGetSCEPRequests != nil {
+		return m.MockGetSCEPRequests(ctx, provisionerName)
+	}
+	return m.MockRet1.([]*db.SCEPRequest), m.MockErr
+}
+
+func (m *mockAdminAuthority) ReviewSCEPRequest(ctx context.Context, provisionerName, id string, approve bool) (*db.SCEPRequest, error) {
+	if m.MockReviewSCEPRequest != nil {
+		return m.MockReviewSCEPRequest(ctx, provisionerName, id, approve)
+	}
+	return m.MockRet1.(*db.SCEPRequest), m.MockErr
+}
+
+func (m *mockAdminAuthority) GetAuthorityPolicy(ctx context.Context) (*policy.Options, error) {
+	if m.MockGetAuthorityPolicy != nil {
+		return m.MockGetAuthorityPolicy(ctx)
+	}
+	return m.MockRet1.(*policy.Options), m.MockErr
+}
+
+func (m *mockAdminAuthority) CreateAuthorityPolicy(ctx context.Context, pol *policy.Options) error {
+	if m.MockCreateAuthorityPolicy != nil {
+		return m.MockCreateAuthorityPolicy(ctx, pol)
+	}
+	return m.MockErr
+}
+
+func (m *mockAdminAuthority) UpdateAuthorityPolicy(ctx context.Context, pol *policy.Options) error {
+	if m.MockUpdateAuthorityPolicy != nil {
+		return m.MockUpdateAuthorityPolicy(ctx, pol)
+	}
+	return m.MockErr
+}
+
+func (m *mockAdminAuthority) RemoveAuthorityPolicy(ctx context.Context) error {
+	if m.MockRemoveAuthorityPolicy != nil {
+		return m.MockRemoveAuthorityPolicy(ctx)
+	}
+	return m.MockErr
+}
+
+func (m *mockAdminAuthority) GetProvisionerPolicy(ctx context.Context, provisionerName string) (*policy.Options, error) {
+	if m.MockGetProvisionerPolicy != nil {
+		return m.MockGetProvisionerPolicy(ctx, provisionerName)
+	}
+	return m.MockRet1.(*policy.Options), m.MockErr
+}
+
+func (m *mockAdminAuthority) CreateProvisionerPolicy(ctx context.Context, provisionerName string, pol *policy.Options) error {
+	if m.MockCreateProvisionerPolicy != nil {
+		return m.MockCreateProvisionerPolicy(ctx, provisionerName, pol)
+	}
+	return m.MockErr
+}
+
+func (m *mockAdminAuthority) UpdateProvisionerPolicy(ctx context.Context, provisionerName string, pol *policy.Options) error {
+	if m.MockUpdateProvisionerPolicy != nil {
+		return m.MockUpdateProvisionerPolicy(ctx, provisionerName, pol)
+	}
+	return m.MockErr
+}
+
+func (m *mockAdminAuthority) RemoveProvisionerPolicy(ctx context.Context, provisionerName string) error {
+	if m.MockRemoveProvisionerPolicy != nil {
+		return m.MockRemoveProvisionerPolicy(ctx, provisionerName)
+	}
+	return m.MockErr
+}
+
+func (m *mockAdminAuthority) EvaluateX509Policy(provisionerName string, cert *x509.Certificate) ([]authority.PolicyEvaluation, error) {
+	if m.MockEvaluateX509Policy != nil {
+		return m.MockEvaluateX509Policy(provisionerName, cert)
+	}
+	return m.MockRet1.([]authority.PolicyEvaluation), m.MockErr
+}
+
+func (m *mockAdminAuthority) EvaluateSSHPolicy(provisionerName string, cert *ssh.Certificate) ([]authority.PolicyEvaluation, error) {
+	if m.MockEvaluateSSHPolicy != nil {
+		return m.MockEvaluateSSHPolicy(provisionerName, cert)
+	}
+	return m.MockRet1.([]authority.PolicyEvaluation), m.MockErr
+}
+
+func (m *mockAdminAuthority) RotateIntermediate(ctx context.Context, req *authority.RotateIntermediateRequest) (*authority.RotateIntermediateResponse, error) {
+	if m.MockRotateIntermediate != nil {
+		return m.MockRotateIntermediate(ctx, req)
+	}
+	return m.MockRet1.(*authority.RotateIntermediateResponse), m.MockErr
+}
+
 func TestCreateAdminRequest_Validate(t *testing.T) {
 	type fields struct {
 		Subject     string
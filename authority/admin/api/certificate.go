@@ -0,0 +1,138 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/smallstep/certificates/api"
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/db"
+)
+
+// CertificateInfo is the representation of an issued certificate in the admin
+// API.
+type CertificateInfo struct {
+	SerialNumber string              `json:"serialNumber"`
+	Subject      string              `json:"subject"`
+	SANs         []string            `json:"sans,omitempty"`
+	NotBefore    time.Time           `json:"notBefore"`
+	NotAfter     time.Time           `json:"notAfter"`
+	Provisioner  *db.ProvisionerData `json:"provisioner,omitempty"`
+	Revoked      bool                `json:"revoked"`
+	RevokedAt    *time.Time          `json:"revokedAt,omitempty"`
+	Certificate  api.Certificate     `json:"certificate"`
+}
+
+// GetCertificatesResponse is the type for GET /admin/certificates responses.
+type GetCertificatesResponse struct {
+	Certificates []*CertificateInfo `json:"certificates"`
+	NextCursor   string             `json:"nextCursor"`
+}
+
+func newCertificateInfo(ci *db.CertificateInfo) *CertificateInfo {
+	crt := ci.Certificate
+	info := &CertificateInfo{
+		SerialNumber: crt.SerialNumber.String(),
+		Subject:      crt.Subject.String(),
+		NotBefore:    crt.NotBefore,
+		NotAfter:     crt.NotAfter,
+		Provisioner:  ci.Provisioner,
+		Certificate:  api.NewCertificate(crt),
+	}
+	info.SANs = append(info.SANs, crt.DNSNames...)
+	for _, ip := range crt.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+	info.SANs = append(info.SANs, crt.EmailAddresses...)
+	for _, u := range crt.URIs {
+		info.SANs = append(info.SANs, u.String())
+	}
+	if rci := ci.RevocationInfo; rci != nil {
+		info.Revoked = true
+		if !rci.RevokedAt.IsZero() {
+			revokedAt := rci.RevokedAt
+			info.RevokedAt = &revokedAt
+		}
+	}
+	return info
+}
+
+// parseCertificateTime parses a time in RFC 3339 format, or a duration
+// relative to now, e.g. "168h" for a week from now.
+func parseCertificateTime(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, errors.Errorf("%s is not a valid time or duration", s)
+	}
+	return now.Add(d), nil
+}
+
+// parseListCertificatesOptions reads the filters and the pagination
+// parameters from the query string.
+func parseListCertificatesOptions(r *http.Request) (db.ListCertificatesOptions, error) {
+	var opts db.ListCertificatesOptions
+	cursor, limit, err := api.ParseCursor(r)
+	if err != nil {
+		return opts, admin.WrapError(admin.ErrorBadRequestType, err,
+			"error parsing cursor and limit from query params")
+	}
+	opts.Cursor = cursor
+	opts.Limit = limit
+
+	q := r.URL.Query()
+	opts.Provisioner = q.Get("provisioner")
+	opts.Subject = q.Get("subject")
+
+	now := time.Now()
+	if v := q.Get("expiresAfter"); v != "" {
+		if opts.ExpiresAfter, err = parseCertificateTime(v, now); err != nil {
+			return opts, admin.WrapError(admin.ErrorBadRequestType, err, "error parsing expiresAfter")
+		}
+	}
+	if v := q.Get("expiresBefore"); v != "" {
+		if opts.ExpiresBefore, err = parseCertificateTime(v, now); err != nil {
+			return opts, admin.WrapError(admin.ErrorBadRequestType, err, "error parsing expiresBefore")
+		}
+	}
+	if v := q.Get("revoked"); v != "" {
+		revoked, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, admin.WrapError(admin.ErrorBadRequestType, err, "error parsing revoked")
+		}
+		opts.Revoked = &revoked
+	}
+	return opts, nil
+}
+
+// GetCertificates returns a segment of the certificates issued by the
+// authority. The certificates can be filtered by provisioner, subject or
+// subject alternative name, expiration window and revocation status.
+func (h *Handler) GetCertificates(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseListCertificatesOptions(r)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	certs, nextCursor, err := h.auth.GetCertificates(opts)
+	if err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error retrieving paginated certificates"))
+		return
+	}
+
+	resp := &GetCertificatesResponse{
+		Certificates: make([]*CertificateInfo, len(certs)),
+		NextCursor:   nextCursor,
+	}
+	for i, ci := range certs {
+		resp.Certificates[i] = newCertificateInfo(ci)
+	}
+	render.JSON(w, resp)
+}
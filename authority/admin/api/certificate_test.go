@@ -0,0 +1,263 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/api"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/db"
+)
+
+func newTestCertificate(t *testing.T) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	now := time.Now().Truncate(time.Second)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1234),
+		Subject:      pkix.Name{CommonName: "foo.internal"},
+		DNSNames:     []string{"foo.internal"},
+		IPAddresses:  []net.IP{net.ParseIP("10.0.0.1")},
+		NotBefore:    now,
+		NotAfter:     now.Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	assert.FatalError(t, err)
+	crt, err := x509.ParseCertificate(der)
+	assert.FatalError(t, err)
+	return crt
+}
+
+func Test_parseCertificateTime(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		s       string
+		want    time.Time
+		wantErr bool
+	}{
+		{"ok/rfc3339", "2022-05-01T00:00:00Z", time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC), false},
+		{"ok/duration", "168h", now.Add(7 * 24 * time.Hour), false},
+		{"ok/negative-duration", "-1h", now.Add(-time.Hour), false},
+		{"fail", "next-week", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCertificateTime(tt.s, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseCertificateTime() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseCertificateTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_GetCertificates(t *testing.T) {
+	crt := newTestCertificate(t)
+	revokedAt := time.Now().Truncate(time.Second).UTC()
+	prov := &db.ProvisionerData{ID: "provID", Name: "jwk", Type: "JWK"}
+
+	type test struct {
+		ctx        context.Context
+		auth       adminAuthority
+		target     string
+		statusCode int
+		err        *admin.Error
+		resp       *GetCertificatesResponse
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/parse-cursor": func(t *testing.T) test {
+			return test{
+				ctx:        context.Background(),
+				target:     "/foo?limit=A",
+				statusCode: 400,
+				err: &admin.Error{
+					Type:    admin.ErrorBadRequestType.String(),
+					Detail:  "bad request",
+					Message: "error parsing cursor and limit from query params: limit 'A' is not an integer: strconv.Atoi: parsing \"A\": invalid syntax",
+				},
+			}
+		},
+		"fail/parse-expiresAfter": func(t *testing.T) test {
+			return test{
+				ctx:        context.Background(),
+				target:     "/foo?expiresAfter=tomorrow",
+				statusCode: 400,
+				err: &admin.Error{
+					Type:    admin.ErrorBadRequestType.String(),
+					Detail:  "bad request",
+					Message: "error parsing expiresAfter: tomorrow is not a valid time or duration",
+				},
+			}
+		},
+		"fail/parse-expiresBefore": func(t *testing.T) test {
+			return test{
+				ctx:        context.Background(),
+				target:     "/foo?expiresBefore=tomorrow",
+				statusCode: 400,
+				err: &admin.Error{
+					Type:    admin.ErrorBadRequestType.String(),
+					Detail:  "bad request",
+					Message: "error parsing expiresBefore: tomorrow is not a valid time or duration",
+				},
+			}
+		},
+		"fail/parse-revoked": func(t *testing.T) test {
+			return test{
+				ctx:        context.Background(),
+				target:     "/foo?revoked=maybe",
+				statusCode: 400,
+				err: &admin.Error{
+					Type:    admin.ErrorBadRequestType.String(),
+					Detail:  "bad request",
+					Message: "error parsing revoked: strconv.ParseBool: parsing \"maybe\": invalid syntax",
+				},
+			}
+		},
+		"fail/auth.GetCertificates": func(t *testing.T) test {
+			return test{
+				ctx: context.Background(),
+				auth: &mockAdminAuthority{
+					MockGetCertificates: func(opts db.ListCertificatesOptions) ([]*db.CertificateInfo, string, error) {
+						return nil, "", errors.New("force")
+					},
+				},
+				target:     "/foo",
+				statusCode: 500,
+				err: &admin.Error{
+					Type:    admin.ErrorServerInternalType.String(),
+					Detail:  "the server experienced an internal error",
+					Message: "error retrieving paginated certificates: force",
+				},
+			}
+		},
+		"fail/not-implemented": func(t *testing.T) test {
+			return test{
+				ctx: context.Background(),
+				auth: &mockAdminAuthority{
+					MockGetCertificates: func(opts db.ListCertificatesOptions) ([]*db.CertificateInfo, string, error) {
+						return nil, "", admin.NewError(admin.ErrorNotImplementedType, "the database does not support listing certificates")
+					},
+				},
+				target:     "/foo",
+				statusCode: 501,
+				err: &admin.Error{
+					Type:    admin.ErrorNotImplementedType.String(),
+					Detail:  "not implemented",
+					Message: "error retrieving paginated certificates: the database does not support listing certificates",
+				},
+			}
+		},
+		"ok": func(t *testing.T) test {
+			return test{
+				ctx: context.Background(),
+				auth: &mockAdminAuthority{
+					MockGetCertificates: func(opts db.ListCertificatesOptions) ([]*db.CertificateInfo, string, error) {
+						assert.Equals(t, "cursor", opts.Cursor)
+						assert.Equals(t, 10, opts.Limit)
+						assert.Equals(t, "jwk", opts.Provisioner)
+						assert.Equals(t, "foo", opts.Subject)
+						assert.True(t, opts.ExpiresAfter.Equal(time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)))
+						assert.True(t, opts.ExpiresBefore.After(time.Now().Add(167*time.Hour)))
+						if assert.NotNil(t, opts.Revoked) {
+							assert.True(t, *opts.Revoked)
+						}
+						return []*db.CertificateInfo{{
+							Certificate: crt,
+							Provisioner: prov,
+							RevocationInfo: &db.RevokedCertificateInfo{
+								Serial:    "1234",
+								RevokedAt: revokedAt,
+							},
+						}}, "nextCursorValue", nil
+					},
+				},
+				target:     "/foo?cursor=cursor&limit=10&provisioner=jwk&subject=foo&expiresAfter=2022-05-01T00:00:00Z&expiresBefore=168h&revoked=true",
+				statusCode: 200,
+				resp: &GetCertificatesResponse{
+					Certificates: []*CertificateInfo{{
+						SerialNumber: "1234",
+						Subject:      "CN=foo.internal",
+						SANs:         []string{"foo.internal", "10.0.0.1"},
+						NotBefore:    crt.NotBefore,
+						NotAfter:     crt.NotAfter,
+						Provisioner:  prov,
+						Revoked:      true,
+						RevokedAt:    &revokedAt,
+						Certificate:  api.NewCertificate(crt),
+					}},
+					NextCursor: "nextCursorValue",
+				},
+			}
+		},
+		"ok/empty": func(t *testing.T) test {
+			return test{
+				ctx: context.Background(),
+				auth: &mockAdminAuthority{
+					MockGetCertificates: func(opts db.ListCertificatesOptions) ([]*db.CertificateInfo, string, error) {
+						assert.Equals(t, db.ListCertificatesOptions{}, opts)
+						return nil, "", nil
+					},
+				},
+				target:     "/foo",
+				statusCode: 200,
+				resp: &GetCertificatesResponse{
+					Certificates: []*CertificateInfo{},
+				},
+			}
+		},
+	}
+	for name, prep := range tests {
+		tc := prep(t)
+		t.Run(name, func(t *testing.T) {
+			h := &Handler{
+				auth: tc.auth,
+			}
+
+			req := httptest.NewRequest("GET", tc.target, nil).WithContext(tc.ctx)
+			w := httptest.NewRecorder()
+			h.GetCertificates(w, req)
+			res := w.Result()
+
+			assert.Equals(t, tc.statusCode, res.StatusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 {
+				adminErr := admin.Error{}
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+
+				assert.Equals(t, tc.err.Type, adminErr.Type)
+				assert.Equals(t, tc.err.Message, adminErr.Message)
+				assert.Equals(t, tc.err.Detail, adminErr.Detail)
+				assert.Equals(t, []string{"application/json"}, res.Header["Content-Type"])
+				return
+			}
+
+			expected, err := json.Marshal(tc.resp)
+			assert.FatalError(t, err)
+			assert.Equals(t, expected, bytes.TrimSpace(body))
+			assert.Equals(t, []string{"application/json"}, res.Header["Content-Type"])
+		})
+	}
+}
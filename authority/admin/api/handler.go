@@ -48,6 +48,32 @@ func (h *Handler) Route(r api.Router) {
 	r.MethodFunc("PATCH", "/admins/{id}", authnz(h.UpdateAdmin))
 	r.MethodFunc("DELETE", "/admins/{id}", authnz(h.DeleteAdmin))
 
+	// Certificates
+	r.MethodFunc("GET", "/certificates", authnz(h.GetCertificates))
+
+	// Authority policy
+	r.MethodFunc("GET", "/policy", authnz(h.GetAuthorityPolicy))
+	r.MethodFunc("POST", "/policy", authnz(h.CreateAuthorityPolicy))
+	r.MethodFunc("PUT", "/policy", authnz(h.UpdateAuthorityPolicy))
+	r.MethodFunc("DELETE", "/policy", authnz(h.DeleteAuthorityPolicy))
+	r.MethodFunc("POST", "/policy/evaluate", authnz(h.EvaluatePolicy))
+
+	// Provisioner policies
+	r.MethodFunc("GET", "/provisioners/{name}/policy", authnz(h.GetProvisionerPolicy))
+	r.MethodFunc("POST", "/provisioners/{name}/policy", authnz(h.CreateProvisionerPolicy))
+	r.MethodFunc("PUT", "/provisioners/{name}/policy", authnz(h.UpdateProvisionerPolicy))
+	r.MethodFunc("DELETE", "/provisioners/{name}/policy", authnz(h.DeleteProvisionerPolicy))
+
+	// Intermediates
+	r.MethodFunc("POST", "/intermediates/rotate", authnz(h.RotateIntermediate))
+
+	// SCEP one-time challenges
+	r.MethodFunc("POST", "/scep/challenges/{provisionerName}", authnz(h.CreateSCEPChallenge))
+
+	// SCEP requests that require approval
+	r.MethodFunc("GET", "/scep/requests/{provisionerName}", authnz(h.GetSCEPRequests))
+	r.MethodFunc("PATCH", "/scep/requests/{provisionerName}/{id}", authnz(h.ReviewSCEPRequest))
+
 	// ACME External Account Binding Keys
 	r.MethodFunc("GET", "/acme/eab/{provisionerName}/{reference}", authnz(requireEABEnabled(h.acmeResponder.GetExternalAccountKeys)))
 	r.MethodFunc("GET", "/acme/eab/{provisionerName}", authnz(requireEABEnabled(h.acmeResponder.GetExternalAccountKeys)))
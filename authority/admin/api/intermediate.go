@@ -0,0 +1,99 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/smallstep/certificates/api"
+	"github.com/smallstep/certificates/api/read"
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority"
+	"github.com/smallstep/certificates/authority/admin"
+)
+
+// RotateIntermediateRequest is the type for POST /admin/intermediates/rotate
+// requests.
+type RotateIntermediateRequest struct {
+	// RootKey is the name of the root key used to sign the new intermediate,
+	// a file or a KMS URI.
+	RootKey string `json:"rootKey"`
+	// RootPassword is the password used to decrypt the root key.
+	RootPassword string `json:"rootPassword,omitempty"`
+	// Lifetime is the lifetime of the new intermediate, e.g. "87600h". If
+	// it's empty the lifetime of the current intermediate is used.
+	Lifetime string `json:"lifetime,omitempty"`
+	// GracePeriod is the time the new intermediate is published before it's
+	// used to sign certificates, e.g. "24h".
+	GracePeriod string `json:"gracePeriod,omitempty"`
+}
+
+// Validate validates a rotate-intermediate request body.
+func (r *RotateIntermediateRequest) Validate() error {
+	if r.RootKey == "" {
+		return admin.NewError(admin.ErrorBadRequestType, "rootKey cannot be empty")
+	}
+	return nil
+}
+
+// RotateIntermediateResponse is the type for POST /admin/intermediates/rotate
+// responses.
+type RotateIntermediateResponse struct {
+	Certificate      api.Certificate   `json:"crt"`
+	CertificateChain []api.Certificate `json:"certChain"`
+	KeyName          string            `json:"keyName,omitempty"`
+	CutoverAt        time.Time         `json:"cutoverAt"`
+}
+
+// RotateIntermediate creates a new intermediate certificate signed by the given
+// root key and schedules its use after the grace period.
+func (h *Handler) RotateIntermediate(w http.ResponseWriter, r *http.Request) {
+	var body RotateIntermediateRequest
+	if err := read.JSON(r.Body, &body); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error reading request body"))
+		return
+	}
+	if err := body.Validate(); err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	var lifetime, gracePeriod time.Duration
+	if body.Lifetime != "" {
+		d, err := time.ParseDuration(body.Lifetime)
+		if err != nil {
+			render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error parsing lifetime"))
+			return
+		}
+		lifetime = d
+	}
+	if body.GracePeriod != "" {
+		d, err := time.ParseDuration(body.GracePeriod)
+		if err != nil {
+			render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error parsing gracePeriod"))
+			return
+		}
+		gracePeriod = d
+	}
+
+	resp, err := h.auth.RotateIntermediate(r.Context(), &authority.RotateIntermediateRequest{
+		RootKey:      body.RootKey,
+		RootPassword: []byte(body.RootPassword),
+		Lifetime:     lifetime,
+		GracePeriod:  gracePeriod,
+	})
+	if err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error rotating intermediate"))
+		return
+	}
+
+	chain := make([]api.Certificate, len(resp.CertificateChain))
+	for i, crt := range resp.CertificateChain {
+		chain[i] = api.NewCertificate(crt)
+	}
+	render.JSONStatus(w, &RotateIntermediateResponse{
+		Certificate:      api.NewCertificate(resp.Certificate),
+		CertificateChain: chain,
+		KeyName:          resp.KeyName,
+		CutoverAt:        resp.CutoverAt,
+	}, http.StatusCreated)
+}
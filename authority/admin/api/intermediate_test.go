@@ -0,0 +1,198 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/api"
+	"github.com/smallstep/certificates/authority"
+	"github.com/smallstep/certificates/authority/admin"
+)
+
+func TestHandler_RotateIntermediate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	newCert := func(t *testing.T, cn string) *x509.Certificate {
+		tmpl := &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: cn},
+			NotBefore:             time.Now(),
+			NotAfter:              time.Now().Add(time.Hour),
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+		assert.FatalError(t, err)
+		crt, err := x509.ParseCertificate(der)
+		assert.FatalError(t, err)
+		return crt
+	}
+	cutoverAt := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+
+	type test struct {
+		auth       adminAuthority
+		body       []byte
+		statusCode int
+		err        *admin.Error
+		resp       *RotateIntermediateResponse
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/read.JSON": func(t *testing.T) test {
+			return test{
+				body:       []byte("{!?}"),
+				statusCode: 400,
+				err: &admin.Error{
+					Type:    admin.ErrorBadRequestType.String(),
+					Detail:  "bad request",
+					Message: "error reading request body: error decoding json: invalid character '!' looking for beginning of object key string",
+				},
+			}
+		},
+		"fail/validate": func(t *testing.T) test {
+			return test{
+				body:       []byte(`{}`),
+				statusCode: 400,
+				err: &admin.Error{
+					Type:    admin.ErrorBadRequestType.String(),
+					Detail:  "bad request",
+					Message: "rootKey cannot be empty",
+				},
+			}
+		},
+		"fail/parse-lifetime": func(t *testing.T) test {
+			return test{
+				body:       []byte(`{"rootKey":"root_ca_key","lifetime":"forever"}`),
+				statusCode: 400,
+				err: &admin.Error{
+					Type:    admin.ErrorBadRequestType.String(),
+					Detail:  "bad request",
+					Message: "error parsing lifetime: time: invalid duration \"forever\"",
+				},
+			}
+		},
+		"fail/parse-gracePeriod": func(t *testing.T) test {
+			return test{
+				body:       []byte(`{"rootKey":"root_ca_key","gracePeriod":"tomorrow"}`),
+				statusCode: 400,
+				err: &admin.Error{
+					Type:    admin.ErrorBadRequestType.String(),
+					Detail:  "bad request",
+					Message: "error parsing gracePeriod: time: invalid duration \"tomorrow\"",
+				},
+			}
+		},
+		"fail/auth.RotateIntermediate": func(t *testing.T) test {
+			return test{
+				auth: &mockAdminAuthority{
+					MockRotateIntermediate: func(ctx context.Context, req *authority.RotateIntermediateRequest) (*authority.RotateIntermediateResponse, error) {
+						return nil, errors.New("force")
+					},
+				},
+				body:       []byte(`{"rootKey":"root_ca_key"}`),
+				statusCode: 500,
+				err: &admin.Error{
+					Type:    admin.ErrorServerInternalType.String(),
+					Detail:  "the server experienced an internal error",
+					Message: "error rotating intermediate: force",
+				},
+			}
+		},
+		"fail/rotation-in-progress": func(t *testing.T) test {
+			return test{
+				auth: &mockAdminAuthority{
+					MockRotateIntermediate: func(ctx context.Context, req *authority.RotateIntermediateRequest) (*authority.RotateIntermediateResponse, error) {
+						return nil, admin.NewError(admin.ErrorBadRequestType, "an intermediate rotation is already in progress")
+					},
+				},
+				body:       []byte(`{"rootKey":"root_ca_key"}`),
+				statusCode: 400,
+				err: &admin.Error{
+					Type:    admin.ErrorBadRequestType.String(),
+					Detail:  "bad request",
+					Message: "error rotating intermediate: an intermediate rotation is already in progress",
+				},
+			}
+		},
+		"ok": func(t *testing.T) test {
+			intermediate, parent := newCert(t, "Intermediate"), newCert(t, "Parent")
+			return test{
+				auth: &mockAdminAuthority{
+					MockRotateIntermediate: func(ctx context.Context, req *authority.RotateIntermediateRequest) (*authority.RotateIntermediateResponse, error) {
+						assert.Equals(t, &authority.RotateIntermediateRequest{
+							RootKey:      "root_ca_key",
+							RootPassword: []byte("pass"),
+							Lifetime:     24 * time.Hour,
+							GracePeriod:  time.Hour,
+						}, req)
+						return &authority.RotateIntermediateResponse{
+							Certificate:      intermediate,
+							CertificateChain: []*x509.Certificate{parent},
+							KeyName:          "pkcs11:id=7331;object=intermediate-key",
+							PrivateKey:       key,
+							CutoverAt:        cutoverAt,
+						}, nil
+					},
+				},
+				body:       []byte(`{"rootKey":"root_ca_key","rootPassword":"pass","lifetime":"24h","gracePeriod":"1h"}`),
+				statusCode: 201,
+				resp: &RotateIntermediateResponse{
+					Certificate:      api.NewCertificate(intermediate),
+					CertificateChain: []api.Certificate{api.NewCertificate(parent)},
+					KeyName:          "pkcs11:id=7331;object=intermediate-key",
+					CutoverAt:        cutoverAt,
+				},
+			}
+		},
+	}
+	for name, prep := range tests {
+		tc := prep(t)
+		t.Run(name, func(t *testing.T) {
+			h := &Handler{
+				auth: tc.auth,
+			}
+
+			req := httptest.NewRequest("POST", "/foo", bytes.NewReader(tc.body))
+			w := httptest.NewRecorder()
+			h.RotateIntermediate(w, req)
+			res := w.Result()
+
+			assert.Equals(t, tc.statusCode, res.StatusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 {
+				adminErr := admin.Error{}
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+
+				assert.Equals(t, tc.err.Type, adminErr.Type)
+				assert.Equals(t, tc.err.Message, adminErr.Message)
+				assert.Equals(t, tc.err.Detail, adminErr.Detail)
+				assert.Equals(t, []string{"application/json"}, res.Header["Content-Type"])
+				return
+			}
+
+			// The private key is never returned.
+			assert.False(t, bytes.Contains(body, []byte("PRIVATE KEY")))
+
+			expected, err := json.Marshal(tc.resp)
+			assert.FatalError(t, err)
+			assert.Equals(t, expected, bytes.TrimSpace(body))
+			assert.Equals(t, []string{"application/json"}, res.Header["Content-Type"])
+		})
+	}
+}
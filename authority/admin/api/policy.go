@@ -0,0 +1,228 @@
+package api
+
+import (
+	"crypto/x509"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"go.step.sm/crypto/x509util"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/smallstep/certificates/api"
+	"github.com/smallstep/certificates/api/read"
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/policy"
+)
+
+// Certificate types supported by the POST /admin/policy/evaluate requests.
+const (
+	PolicyTypeX509    = "x509"
+	PolicyTypeSSHUser = "sshUser"
+	PolicyTypeSSHHost = "sshHost"
+)
+
+// GetAuthorityPolicy returns the authority policy stored in the admin
+// database.
+func (h *Handler) GetAuthorityPolicy(w http.ResponseWriter, r *http.Request) {
+	pol, err := h.auth.GetAuthorityPolicy(r.Context())
+	if err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error retrieving authority policy"))
+		return
+	}
+	render.JSON(w, pol)
+}
+
+// CreateAuthorityPolicy creates the authority policy.
+func (h *Handler) CreateAuthorityPolicy(w http.ResponseWriter, r *http.Request) {
+	var pol = new(policy.Options)
+	if err := read.JSON(r.Body, pol); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error reading request body"))
+		return
+	}
+
+	if err := h.auth.CreateAuthorityPolicy(r.Context(), pol); err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error creating authority policy"))
+		return
+	}
+	render.JSONStatus(w, pol, http.StatusCreated)
+}
+
+// UpdateAuthorityPolicy replaces the authority policy.
+func (h *Handler) UpdateAuthorityPolicy(w http.ResponseWriter, r *http.Request) {
+	var pol = new(policy.Options)
+	if err := read.JSON(r.Body, pol); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error reading request body"))
+		return
+	}
+
+	if err := h.auth.UpdateAuthorityPolicy(r.Context(), pol); err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error updating authority policy"))
+		return
+	}
+	render.JSON(w, pol)
+}
+
+// DeleteAuthorityPolicy deletes the authority policy.
+func (h *Handler) DeleteAuthorityPolicy(w http.ResponseWriter, r *http.Request) {
+	if err := h.auth.RemoveAuthorityPolicy(r.Context()); err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error deleting authority policy"))
+		return
+	}
+	render.JSON(w, &DeleteResponse{Status: "ok"})
+}
+
+// GetProvisionerPolicy returns the policy of a provisioner stored in the
+// admin database.
+func (h *Handler) GetProvisionerPolicy(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	pol, err := h.auth.GetProvisionerPolicy(r.Context(), name)
+	if err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error retrieving policy of provisioner %s", name))
+		return
+	}
+	render.JSON(w, pol)
+}
+
+// CreateProvisionerPolicy creates the policy of a provisioner.
+func (h *Handler) CreateProvisionerPolicy(w http.ResponseWriter, r *http.Request) {
+	var pol = new(policy.Options)
+	if err := read.JSON(r.Body, pol); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error reading request body"))
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	if err := h.auth.CreateProvisionerPolicy(r.Context(), name, pol); err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error creating policy of provisioner %s", name))
+		return
+	}
+	render.JSONStatus(w, pol, http.StatusCreated)
+}
+
+// UpdateProvisionerPolicy replaces the policy of a provisioner.
+func (h *Handler) UpdateProvisionerPolicy(w http.ResponseWriter, r *http.Request) {
+	var pol = new(policy.Options)
+	if err := read.JSON(r.Body, pol); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error reading request body"))
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	if err := h.auth.UpdateProvisionerPolicy(r.Context(), name, pol); err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error updating policy of provisioner %s", name))
+		return
+	}
+	render.JSON(w, pol)
+}
+
+// DeleteProvisionerPolicy deletes the policy of a provisioner.
+func (h *Handler) DeleteProvisionerPolicy(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if err := h.auth.RemoveProvisionerPolicy(r.Context(), name); err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error deleting policy of provisioner %s", name))
+		return
+	}
+	render.JSON(w, &DeleteResponse{Status: "ok"})
+}
+
+// EvaluatePolicyRequest is the type for POST /admin/policy/evaluate requests.
+// The names to evaluate are taken from the CSR or from the list of names.
+type EvaluatePolicyRequest struct {
+	// Provisioner is the name of the provisioner whose policy is evaluated
+	// together with the authority policy. It is optional.
+	Provisioner string `json:"provisioner,omitempty"`
+	// Type is the type of certificate, x509 (default), sshUser or sshHost.
+	Type string                  `json:"type,omitempty"`
+	CSR  *api.CertificateRequest `json:"csr,omitempty"`
+	// Names are the subject alternative names of an X.509 certificate or the
+	// principals of an SSH certificate.
+	Names []string `json:"names,omitempty"`
+}
+
+// Validate validates an evaluate policy request body.
+func (req *EvaluatePolicyRequest) Validate() error {
+	hasCSR := req.CSR != nil && req.CSR.CertificateRequest != nil
+	switch req.Type {
+	case "", PolicyTypeX509:
+		if hasCSR == (len(req.Names) > 0) {
+			return admin.NewError(admin.ErrorBadRequestType, "either csr or names must be set")
+		}
+	case PolicyTypeSSHUser, PolicyTypeSSHHost:
+		if hasCSR {
+			return admin.NewError(admin.ErrorBadRequestType, "csr cannot be used with %s policies", req.Type)
+		}
+	default:
+		return admin.NewError(admin.ErrorBadRequestType, "unsupported policy type %s", req.Type)
+	}
+	return nil
+}
+
+// EvaluatePolicyResponse is the type for POST /admin/policy/evaluate
+// responses.
+type EvaluatePolicyResponse struct {
+	// Allowed is true if all the names are allowed by the policies.
+	Allowed     bool                         `json:"allowed"`
+	Evaluations []authority.PolicyEvaluation `json:"evaluations"`
+}
+
+// EvaluatePolicy evaluates the names in a CSR, or a list of names, against
+// the current authority and provisioner policies and returns the rules that
+// matched them. It does not sign anything.
+func (h *Handler) EvaluatePolicy(w http.ResponseWriter, r *http.Request) {
+	var body EvaluatePolicyRequest
+	if err := read.JSON(r.Body, &body); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error reading request body"))
+		return
+	}
+	if err := body.Validate(); err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	var (
+		evals []authority.PolicyEvaluation
+		err   error
+	)
+	switch body.Type {
+	case PolicyTypeSSHUser, PolicyTypeSSHHost:
+		cert := &ssh.Certificate{
+			CertType:        ssh.UserCert,
+			ValidPrincipals: body.Names,
+		}
+		if body.Type == PolicyTypeSSHHost {
+			cert.CertType = ssh.HostCert
+		}
+		evals, err = h.auth.EvaluateSSHPolicy(body.Provisioner, cert)
+	default:
+		cert := new(x509.Certificate)
+		if body.CSR != nil && body.CSR.CertificateRequest != nil {
+			csr := body.CSR.CertificateRequest
+			cert.Subject = csr.Subject
+			cert.DNSNames = csr.DNSNames
+			cert.IPAddresses = csr.IPAddresses
+			cert.EmailAddresses = csr.EmailAddresses
+			cert.URIs = csr.URIs
+		} else {
+			cert.DNSNames, cert.IPAddresses, cert.EmailAddresses, cert.URIs = x509util.SplitSANs(body.Names)
+		}
+		evals, err = h.auth.EvaluateX509Policy(body.Provisioner, cert)
+	}
+	if err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error evaluating policy"))
+		return
+	}
+
+	resp := &EvaluatePolicyResponse{
+		Allowed:     true,
+		Evaluations: evals,
+	}
+	for _, ev := range evals {
+		if !ev.Allowed {
+			resp.Allowed = false
+			break
+		}
+	}
+	render.JSON(w, resp)
+}
@@ -0,0 +1,347 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/smallstep/assert"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/smallstep/certificates/api"
+	"github.com/smallstep/certificates/authority"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/policy"
+)
+
+type policyHandlerTest struct {
+	auth       adminAuthority
+	body       []byte
+	statusCode int
+	err        *admin.Error
+	resp       interface{}
+}
+
+func runPolicyHandlerTest(t *testing.T, tc policyHandlerTest, method string, handler func(h *Handler) http.HandlerFunc) {
+	t.Helper()
+	h := &Handler{
+		auth: tc.auth,
+	}
+
+	chiCtx := chi.NewRouteContext()
+	chiCtx.URLParams.Add("name", "prov")
+	ctx := context.WithValue(context.Background(), chi.RouteCtxKey, chiCtx)
+	req := httptest.NewRequest(method, "/foo", bytes.NewReader(tc.body)).WithContext(ctx)
+	w := httptest.NewRecorder()
+	handler(h)(w, req)
+	res := w.Result()
+
+	assert.Equals(t, tc.statusCode, res.StatusCode)
+
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.FatalError(t, err)
+
+	if res.StatusCode >= 400 {
+		adminErr := admin.Error{}
+		assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+
+		assert.Equals(t, tc.err.Type, adminErr.Type)
+		assert.Equals(t, tc.err.Message, adminErr.Message)
+		assert.Equals(t, tc.err.Detail, adminErr.Detail)
+		assert.Equals(t, []string{"application/json"}, res.Header["Content-Type"])
+		return
+	}
+
+	expected, err := json.Marshal(tc.resp)
+	assert.FatalError(t, err)
+	assert.Equals(t, expected, bytes.TrimSpace(body))
+	assert.Equals(t, []string{"application/json"}, res.Header["Content-Type"])
+}
+
+func TestHandler_CreateAuthorityPolicy(t *testing.T) {
+	pol := &policy.Options{X509: &policy.X509Options{
+		Allow: &policy.X509NameOptions{DNSDomains: []string{"*.example.com"}},
+	}}
+	var tests = map[string]func(t *testing.T) policyHandlerTest{
+		"fail/read.JSON": func(t *testing.T) policyHandlerTest {
+			return policyHandlerTest{
+				body:       []byte("{!?}"),
+				statusCode: 400,
+				err: &admin.Error{
+					Type:    admin.ErrorBadRequestType.String(),
+					Detail:  "bad request",
+					Message: "error reading request body: error decoding json: invalid character '!' looking for beginning of object key string",
+				},
+			}
+		},
+		"fail/auth.CreateAuthorityPolicy": func(t *testing.T) policyHandlerTest {
+			return policyHandlerTest{
+				auth: &mockAdminAuthority{
+					MockCreateAuthorityPolicy: func(ctx context.Context, pol *policy.Options) error {
+						return errors.New("force")
+					},
+				},
+				body:       []byte(`{}`),
+				statusCode: 500,
+				err: &admin.Error{
+					Type:    admin.ErrorServerInternalType.String(),
+					Detail:  "the server experienced an internal error",
+					Message: "error creating authority policy: force",
+				},
+			}
+		},
+		"fail/invalid-policy": func(t *testing.T) policyHandlerTest {
+			return policyHandlerTest{
+				auth: &mockAdminAuthority{
+					MockCreateAuthorityPolicy: func(ctx context.Context, pol *policy.Options) error {
+						return admin.NewError(admin.ErrorBadRequestType, "invalid authority policy")
+					},
+				},
+				body:       []byte(`{"x509":{"allow":{"ip":["foo"]}}}`),
+				statusCode: 400,
+				err: &admin.Error{
+					Type:    admin.ErrorBadRequestType.String(),
+					Detail:  "bad request",
+					Message: "error creating authority policy: invalid authority policy",
+				},
+			}
+		},
+		"ok": func(t *testing.T) policyHandlerTest {
+			return policyHandlerTest{
+				auth: &mockAdminAuthority{
+					MockCreateAuthorityPolicy: func(ctx context.Context, got *policy.Options) error {
+						assert.Equals(t, pol, got)
+						return nil
+					},
+				},
+				body:       []byte(`{"x509":{"allow":{"dns":["*.example.com"]}}}`),
+				statusCode: 201,
+				resp:       pol,
+			}
+		},
+	}
+	for name, prep := range tests {
+		tc := prep(t)
+		t.Run(name, func(t *testing.T) {
+			runPolicyHandlerTest(t, tc, "POST", func(h *Handler) http.HandlerFunc { return h.CreateAuthorityPolicy })
+		})
+	}
+}
+
+func TestHandler_GetProvisionerPolicy(t *testing.T) {
+	pol := &policy.Options{SSH: &policy.SSHOptions{
+		Host: &policy.SSHCertificateOptions{Deny: &policy.SSHNameOptions{IPRanges: []string{"10.0.0.0/8"}}},
+	}}
+	var tests = map[string]func(t *testing.T) policyHandlerTest{
+		"fail/not-found": func(t *testing.T) policyHandlerTest {
+			return policyHandlerTest{
+				auth: &mockAdminAuthority{
+					MockGetProvisionerPolicy: func(ctx context.Context, provisionerName string) (*policy.Options, error) {
+						return nil, admin.NewError(admin.ErrorNotFoundType, "provisioner policy provID not found")
+					},
+				},
+				statusCode: 404,
+				err: &admin.Error{
+					Type:    admin.ErrorNotFoundType.String(),
+					Detail:  "resource not found",
+					Message: "error retrieving policy of provisioner prov: provisioner policy provID not found",
+				},
+			}
+		},
+		"ok": func(t *testing.T) policyHandlerTest {
+			return policyHandlerTest{
+				auth: &mockAdminAuthority{
+					MockGetProvisionerPolicy: func(ctx context.Context, provisionerName string) (*policy.Options, error) {
+						assert.Equals(t, "prov", provisionerName)
+						return pol, nil
+					},
+				},
+				statusCode: 200,
+				resp:       pol,
+			}
+		},
+	}
+	for name, prep := range tests {
+		tc := prep(t)
+		t.Run(name, func(t *testing.T) {
+			runPolicyHandlerTest(t, tc, "GET", func(h *Handler) http.HandlerFunc { return h.GetProvisionerPolicy })
+		})
+	}
+}
+
+func TestHandler_DeleteProvisionerPolicy(t *testing.T) {
+	var tests = map[string]func(t *testing.T) policyHandlerTest{
+		"fail/auth.RemoveProvisionerPolicy": func(t *testing.T) policyHandlerTest {
+			return policyHandlerTest{
+				auth: &mockAdminAuthority{
+					MockRemoveProvisionerPolicy: func(ctx context.Context, provisionerName string) error {
+						return errors.New("force")
+					},
+				},
+				statusCode: 500,
+				err: &admin.Error{
+					Type:    admin.ErrorServerInternalType.String(),
+					Detail:  "the server experienced an internal error",
+					Message: "error deleting policy of provisioner prov: force",
+				},
+			}
+		},
+		"ok": func(t *testing.T) policyHandlerTest {
+			return policyHandlerTest{
+				auth: &mockAdminAuthority{
+					MockRemoveProvisionerPolicy: func(ctx context.Context, provisionerName string) error {
+						assert.Equals(t, "prov", provisionerName)
+						return nil
+					},
+				},
+				statusCode: 200,
+				resp:       &DeleteResponse{Status: "ok"},
+			}
+		},
+	}
+	for name, prep := range tests {
+		tc := prep(t)
+		t.Run(name, func(t *testing.T) {
+			runPolicyHandlerTest(t, tc, "DELETE", func(h *Handler) http.HandlerFunc { return h.DeleteProvisionerPolicy })
+		})
+	}
+}
+
+func TestHandler_EvaluatePolicy(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "foo.example.com"},
+		DNSNames: []string{"foo.example.com"},
+	}, priv)
+	assert.FatalError(t, err)
+	csr, err := x509.ParseCertificateRequest(der)
+	assert.FatalError(t, err)
+	csrBody, err := json.Marshal(&EvaluatePolicyRequest{
+		Provisioner: "prov",
+		CSR:         &api.CertificateRequest{CertificateRequest: csr},
+	})
+	assert.FatalError(t, err)
+
+	evals := []authority.PolicyEvaluation{
+		{Policy: "authority", Evaluation: policy.Evaluation{NameType: policy.DNSNameType, Name: "foo.example.com", Allowed: true, Rule: "*.example.com"}},
+		{Policy: "provisioner", Evaluation: policy.Evaluation{NameType: policy.IPNameType, Name: "10.0.0.1", Rule: "10.0.0.0/8", Error: `ip name "10.0.0.1" is denied by the rule "10.0.0.0/8"`}},
+	}
+
+	var tests = map[string]func(t *testing.T) policyHandlerTest{
+		"fail/read.JSON": func(t *testing.T) policyHandlerTest {
+			return policyHandlerTest{
+				body:       []byte("{!?}"),
+				statusCode: 400,
+				err: &admin.Error{
+					Type:    admin.ErrorBadRequestType.String(),
+					Detail:  "bad request",
+					Message: "error reading request body: error decoding json: invalid character '!' looking for beginning of object key string",
+				},
+			}
+		},
+		"fail/validate-empty": func(t *testing.T) policyHandlerTest {
+			return policyHandlerTest{
+				body:       []byte(`{}`),
+				statusCode: 400,
+				err: &admin.Error{
+					Type:    admin.ErrorBadRequestType.String(),
+					Detail:  "bad request",
+					Message: "either csr or names must be set",
+				},
+			}
+		},
+		"fail/validate-type": func(t *testing.T) policyHandlerTest {
+			return policyHandlerTest{
+				body:       []byte(`{"type":"foo","names":["foo"]}`),
+				statusCode: 400,
+				err: &admin.Error{
+					Type:    admin.ErrorBadRequestType.String(),
+					Detail:  "bad request",
+					Message: "unsupported policy type foo",
+				},
+			}
+		},
+		"fail/auth.EvaluateX509Policy": func(t *testing.T) policyHandlerTest {
+			return policyHandlerTest{
+				auth: &mockAdminAuthority{
+					MockEvaluateX509Policy: func(provisionerName string, cert *x509.Certificate) ([]authority.PolicyEvaluation, error) {
+						return nil, admin.NewError(admin.ErrorNotFoundType, "provisioner foo not found")
+					},
+				},
+				body:       []byte(`{"provisioner":"foo","names":["foo.example.com"]}`),
+				statusCode: 404,
+				err: &admin.Error{
+					Type:    admin.ErrorNotFoundType.String(),
+					Detail:  "resource not found",
+					Message: "error evaluating policy: provisioner foo not found",
+				},
+			}
+		},
+		"ok/names": func(t *testing.T) policyHandlerTest {
+			return policyHandlerTest{
+				auth: &mockAdminAuthority{
+					MockEvaluateX509Policy: func(provisionerName string, cert *x509.Certificate) ([]authority.PolicyEvaluation, error) {
+						assert.Equals(t, "", provisionerName)
+						assert.Equals(t, []string{"foo.example.com"}, cert.DNSNames)
+						assert.Equals(t, []net.IP{net.ParseIP("10.0.0.1")}, cert.IPAddresses)
+						assert.Equals(t, []string{"jane@example.com"}, cert.EmailAddresses)
+						assert.Len(t, 1, cert.URIs)
+						return evals, nil
+					},
+				},
+				body:       []byte(`{"names":["foo.example.com","10.0.0.1","jane@example.com","spiffe://example.com/foo"]}`),
+				statusCode: 200,
+				resp:       &EvaluatePolicyResponse{Allowed: false, Evaluations: evals},
+			}
+		},
+		"ok/csr": func(t *testing.T) policyHandlerTest {
+			return policyHandlerTest{
+				auth: &mockAdminAuthority{
+					MockEvaluateX509Policy: func(provisionerName string, cert *x509.Certificate) ([]authority.PolicyEvaluation, error) {
+						assert.Equals(t, "prov", provisionerName)
+						assert.Equals(t, "foo.example.com", cert.Subject.CommonName)
+						assert.Equals(t, []string{"foo.example.com"}, cert.DNSNames)
+						return evals[:1], nil
+					},
+				},
+				body:       csrBody,
+				statusCode: 200,
+				resp:       &EvaluatePolicyResponse{Allowed: true, Evaluations: evals[:1]},
+			}
+		},
+		"ok/ssh": func(t *testing.T) policyHandlerTest {
+			return policyHandlerTest{
+				auth: &mockAdminAuthority{
+					MockEvaluateSSHPolicy: func(provisionerName string, cert *ssh.Certificate) ([]authority.PolicyEvaluation, error) {
+						assert.Equals(t, uint32(ssh.HostCert), cert.CertType)
+						assert.Equals(t, []string{"foo.example.com"}, cert.ValidPrincipals)
+						return evals[:1], nil
+					},
+				},
+				body:       []byte(`{"type":"sshHost","names":["foo.example.com"]}`),
+				statusCode: 200,
+				resp:       &EvaluatePolicyResponse{Allowed: true, Evaluations: evals[:1]},
+			}
+		},
+	}
+	for name, prep := range tests {
+		tc := prep(t)
+		t.Run(name, func(t *testing.T) {
+			runPolicyHandlerTest(t, tc, "POST", func(h *Handler) http.HandlerFunc { return h.EvaluatePolicy })
+		})
+	}
+}
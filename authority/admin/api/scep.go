@@ -0,0 +1,129 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/smallstep/certificates/api/read"
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/db"
+)
+
+// CreateSCEPChallengeRequest is the type for POST /admin/scep/challenges
+// requests.
+type CreateSCEPChallengeRequest struct {
+	// Subject is the common name the CSR must have to use the challenge. If
+	// it's empty, the challenge can be used with any subject.
+	Subject string `json:"subject,omitempty"`
+	// Duration is the lifetime of the challenge, e.g. "24h".
+	Duration string `json:"duration,omitempty"`
+}
+
+// CreateSCEPChallengeResponse is the type for POST /admin/scep/challenges
+// responses.
+type CreateSCEPChallengeResponse struct {
+	Challenge   string    `json:"challenge"`
+	Provisioner string    `json:"provisioner"`
+	Subject     string    `json:"subject,omitempty"`
+	ExpiresAt   time.Time `json:"expiresAt"`
+}
+
+// CreateSCEPChallenge creates a one-time challenge for a SCEP provisioner.
+func (h *Handler) CreateSCEPChallenge(w http.ResponseWriter, r *http.Request) {
+	var body CreateSCEPChallengeRequest
+	if err := read.JSON(r.Body, &body); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error reading request body"))
+		return
+	}
+
+	var lifetime time.Duration
+	if body.Duration != "" {
+		d, err := time.ParseDuration(body.Duration)
+		if err != nil {
+			render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error parsing duration"))
+			return
+		}
+		lifetime = d
+	}
+
+	provName := chi.URLParam(r, "provisionerName")
+	challenge, sc, err := h.auth.CreateSCEPChallenge(r.Context(), provName, body.Subject, lifetime)
+	if err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error creating scep challenge"))
+		return
+	}
+
+	render.JSONStatus(w, &CreateSCEPChallengeResponse{
+		Challenge:   challenge,
+		Provisioner: sc.Provisioner,
+		Subject:     sc.Subject,
+		ExpiresAt:   sc.ExpiresAt,
+	}, http.StatusCreated)
+}
+
+// GetSCEPRequestsResponse is the type for GET /admin/scep/requests responses.
+type GetSCEPRequestsResponse struct {
+	Requests []*db.SCEPRequest `json:"requests"`
+}
+
+// GetSCEPRequests returns the SCEP requests of a provisioner that requires
+// approval.
+func (h *Handler) GetSCEPRequests(w http.ResponseWriter, r *http.Request) {
+	provName := chi.URLParam(r, "provisionerName")
+	reqs, err := h.auth.GetSCEPRequests(r.Context(), provName)
+	if err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error getting scep requests"))
+		return
+	}
+	if reqs == nil {
+		reqs = []*db.SCEPRequest{}
+	}
+
+	render.JSON(w, &GetSCEPRequestsResponse{
+		Requests: reqs,
+	})
+}
+
+// ReviewSCEPRequestRequest is the type for PATCH /admin/scep/requests
+// requests.
+type ReviewSCEPRequestRequest struct {
+	// Status is the new status of the request, "approved" or "rejected".
+	Status string `json:"status"`
+}
+
+// Validate validates a review SCEP request body.
+func (rsr *ReviewSCEPRequestRequest) Validate() error {
+	switch rsr.Status {
+	case db.SCEPRequestApproved, db.SCEPRequestRejected:
+		return nil
+	default:
+		return admin.NewError(admin.ErrorBadRequestType, "status must be %s or %s",
+			db.SCEPRequestApproved, db.SCEPRequestRejected)
+	}
+}
+
+// ReviewSCEPRequest approves or rejects a pending SCEP request.
+func (h *Handler) ReviewSCEPRequest(w http.ResponseWriter, r *http.Request) {
+	var body ReviewSCEPRequestRequest
+	if err := read.JSON(r.Body, &body); err != nil {
+		render.Error(w, admin.WrapError(admin.ErrorBadRequestType, err, "error reading request body"))
+		return
+	}
+	if err := body.Validate(); err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	provName := chi.URLParam(r, "provisionerName")
+	id := chi.URLParam(r, "id")
+	req, err := h.auth.ReviewSCEPRequest(r.Context(), provName, id, body.Status == db.SCEPRequestApproved)
+	if err != nil {
+		render.Error(w, admin.WrapErrorISE(err, "error reviewing scep request"))
+		return
+	}
+
+	render.JSON(w, req)
+}
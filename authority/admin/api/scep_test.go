@@ -0,0 +1,360 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/db"
+)
+
+func TestHandler_CreateSCEPChallenge(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+
+	type test struct {
+		auth       adminAuthority
+		body       []byte
+		statusCode int
+		err        *admin.Error
+		resp       *CreateSCEPChallengeResponse
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/read.JSON": func(t *testing.T) test {
+			return test{
+				body:       []byte("{!?}"),
+				statusCode: 400,
+				err: &admin.Error{
+					Type:    admin.ErrorBadRequestType.String(),
+					Detail:  "bad request",
+					Message: "error reading request body: error decoding json: invalid character '!' looking for beginning of object key string",
+				},
+			}
+		},
+		"fail/parse-duration": func(t *testing.T) test {
+			return test{
+				body:       []byte(`{"duration":"tomorrow"}`),
+				statusCode: 400,
+				err: &admin.Error{
+					Type:    admin.ErrorBadRequestType.String(),
+					Detail:  "bad request",
+					Message: "error parsing duration: time: invalid duration \"tomorrow\"",
+				},
+			}
+		},
+		"fail/auth.CreateSCEPChallenge": func(t *testing.T) test {
+			return test{
+				auth: &mockAdminAuthority{
+					MockCreateSCEPChallenge: func(ctx context.Context, provisionerName, subject string, lifetime time.Duration) (string, *db.SCEPChallenge, error) {
+						return "", nil, errors.New("force")
+					},
+				},
+				body:       []byte(`{}`),
+				statusCode: 500,
+				err: &admin.Error{
+					Type:    admin.ErrorServerInternalType.String(),
+					Detail:  "the server experienced an internal error",
+					Message: "error creating scep challenge: force",
+				},
+			}
+		},
+		"fail/bad-provisioner": func(t *testing.T) test {
+			return test{
+				auth: &mockAdminAuthority{
+					MockCreateSCEPChallenge: func(ctx context.Context, provisionerName, subject string, lifetime time.Duration) (string, *db.SCEPChallenge, error) {
+						return "", nil, admin.NewError(admin.ErrorBadRequestType, "provisioner scep is not a SCEP provisioner with one-time challenges")
+					},
+				},
+				body:       []byte(`{}`),
+				statusCode: 400,
+				err: &admin.Error{
+					Type:    admin.ErrorBadRequestType.String(),
+					Detail:  "bad request",
+					Message: "error creating scep challenge: provisioner scep is not a SCEP provisioner with one-time challenges",
+				},
+			}
+		},
+		"ok": func(t *testing.T) test {
+			return test{
+				auth: &mockAdminAuthority{
+					MockCreateSCEPChallenge: func(ctx context.Context, provisionerName, subject string, lifetime time.Duration) (string, *db.SCEPChallenge, error) {
+						assert.Equals(t, "scep", provisionerName)
+						assert.Equals(t, "device-1", subject)
+						assert.Equals(t, time.Hour, lifetime)
+						return "the-challenge", &db.SCEPChallenge{
+							Provisioner: "scep",
+							Subject:     "device-1",
+							ExpiresAt:   expiresAt,
+						}, nil
+					},
+				},
+				body:       []byte(`{"subject":"device-1","duration":"1h"}`),
+				statusCode: 201,
+				resp: &CreateSCEPChallengeResponse{
+					Challenge:   "the-challenge",
+					Provisioner: "scep",
+					Subject:     "device-1",
+					ExpiresAt:   expiresAt,
+				},
+			}
+		},
+	}
+	for name, prep := range tests {
+		tc := prep(t)
+		t.Run(name, func(t *testing.T) {
+			h := &Handler{
+				auth: tc.auth,
+			}
+
+			chiCtx := chi.NewRouteContext()
+			chiCtx.URLParams.Add("provisionerName", "scep")
+			ctx := context.WithValue(context.Background(), chi.RouteCtxKey, chiCtx)
+			req := httptest.NewRequest("POST", "/foo", bytes.NewReader(tc.body)).WithContext(ctx)
+			w := httptest.NewRecorder()
+			h.CreateSCEPChallenge(w, req)
+			res := w.Result()
+
+			assert.Equals(t, tc.statusCode, res.StatusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 {
+				adminErr := admin.Error{}
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+
+				assert.Equals(t, tc.err.Type, adminErr.Type)
+				assert.Equals(t, tc.err.Message, adminErr.Message)
+				assert.Equals(t, tc.err.Detail, adminErr.Detail)
+				assert.Equals(t, []string{"application/json"}, res.Header["Content-Type"])
+				return
+			}
+
+			expected, err := json.Marshal(tc.resp)
+			assert.FatalError(t, err)
+			assert.Equals(t, expected, bytes.TrimSpace(body))
+			assert.Equals(t, []string{"application/json"}, res.Header["Content-Type"])
+		})
+	}
+}
+
+func TestHandler_GetSCEPRequests(t *testing.T) {
+	createdAt := time.Now().Truncate(time.Second).UTC()
+
+	type test struct {
+		auth       adminAuthority
+		statusCode int
+		err        *admin.Error
+		resp       *GetSCEPRequestsResponse
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/auth.GetSCEPRequests": func(t *testing.T) test {
+			return test{
+				auth: &mockAdminAuthority{
+					MockGetSCEPRequests: func(ctx context.Context, provisionerName string) ([]*db.SCEPRequest, error) {
+						return nil, errors.New("force")
+					},
+				},
+				statusCode: 500,
+				err: &admin.Error{
+					Type:    admin.ErrorServerInternalType.String(),
+					Detail:  "the server experienced an internal error",
+					Message: "error getting scep requests: force",
+				},
+			}
+		},
+		"ok/empty": func(t *testing.T) test {
+			return test{
+				auth: &mockAdminAuthority{
+					MockGetSCEPRequests: func(ctx context.Context, provisionerName string) ([]*db.SCEPRequest, error) {
+						return nil, nil
+					},
+				},
+				statusCode: 200,
+				resp: &GetSCEPRequestsResponse{
+					Requests: []*db.SCEPRequest{},
+				},
+			}
+		},
+		"ok": func(t *testing.T) test {
+			reqs := []*db.SCEPRequest{
+				{ID: "tx-1", Provisioner: "scep", Subject: "device-1", CSR: []byte("csr"), Status: db.SCEPRequestPending, CreatedAt: createdAt, UpdatedAt: createdAt},
+			}
+			return test{
+				auth: &mockAdminAuthority{
+					MockGetSCEPRequests: func(ctx context.Context, provisionerName string) ([]*db.SCEPRequest, error) {
+						assert.Equals(t, "scep", provisionerName)
+						return reqs, nil
+					},
+				},
+				statusCode: 200,
+				resp: &GetSCEPRequestsResponse{
+					Requests: reqs,
+				},
+			}
+		},
+	}
+	for name, prep := range tests {
+		tc := prep(t)
+		t.Run(name, func(t *testing.T) {
+			h := &Handler{
+				auth: tc.auth,
+			}
+
+			chiCtx := chi.NewRouteContext()
+			chiCtx.URLParams.Add("provisionerName", "scep")
+			ctx := context.WithValue(context.Background(), chi.RouteCtxKey, chiCtx)
+			req := httptest.NewRequest("GET", "/foo", nil).WithContext(ctx)
+			w := httptest.NewRecorder()
+			h.GetSCEPRequests(w, req)
+			res := w.Result()
+
+			assert.Equals(t, tc.statusCode, res.StatusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 {
+				adminErr := admin.Error{}
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+
+				assert.Equals(t, tc.err.Type, adminErr.Type)
+				assert.Equals(t, tc.err.Message, adminErr.Message)
+				assert.Equals(t, tc.err.Detail, adminErr.Detail)
+				assert.Equals(t, []string{"application/json"}, res.Header["Content-Type"])
+				return
+			}
+
+			expected, err := json.Marshal(tc.resp)
+			assert.FatalError(t, err)
+			assert.Equals(t, expected, bytes.TrimSpace(body))
+			assert.Equals(t, []string{"application/json"}, res.Header["Content-Type"])
+		})
+	}
+}
+
+func TestHandler_ReviewSCEPRequest(t *testing.T) {
+	type test struct {
+		auth       adminAuthority
+		body       []byte
+		statusCode int
+		err        *admin.Error
+		resp       *db.SCEPRequest
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/read.JSON": func(t *testing.T) test {
+			return test{
+				body:       []byte("{!?}"),
+				statusCode: 400,
+				err: &admin.Error{
+					Type:    admin.ErrorBadRequestType.String(),
+					Detail:  "bad request",
+					Message: "error reading request body: error decoding json: invalid character '!' looking for beginning of object key string",
+				},
+			}
+		},
+		"fail/validate": func(t *testing.T) test {
+			return test{
+				body:       []byte(`{"status":"issued"}`),
+				statusCode: 400,
+				err: &admin.Error{
+					Type:    admin.ErrorBadRequestType.String(),
+					Detail:  "bad request",
+					Message: "status must be approved or rejected",
+				},
+			}
+		},
+		"fail/not-found": func(t *testing.T) test {
+			return test{
+				auth: &mockAdminAuthority{
+					MockReviewSCEPRequest: func(ctx context.Context, provisionerName, id string, approve bool) (*db.SCEPRequest, error) {
+						return nil, admin.NewError(admin.ErrorNotFoundType, "scep request %s not found", id)
+					},
+				},
+				body:       []byte(`{"status":"approved"}`),
+				statusCode: 404,
+				err: &admin.Error{
+					Type:    admin.ErrorNotFoundType.String(),
+					Detail:  "resource not found",
+					Message: "error reviewing scep request: scep request tx-1 not found",
+				},
+			}
+		},
+		"ok/approved": func(t *testing.T) test {
+			return test{
+				auth: &mockAdminAuthority{
+					MockReviewSCEPRequest: func(ctx context.Context, provisionerName, id string, approve bool) (*db.SCEPRequest, error) {
+						assert.Equals(t, "scep", provisionerName)
+						assert.Equals(t, "tx-1", id)
+						assert.True(t, approve)
+						return &db.SCEPRequest{ID: id, Provisioner: provisionerName, Status: db.SCEPRequestApproved}, nil
+					},
+				},
+				body:       []byte(`{"status":"approved"}`),
+				statusCode: 200,
+				resp:       &db.SCEPRequest{ID: "tx-1", Provisioner: "scep", Status: db.SCEPRequestApproved},
+			}
+		},
+		"ok/rejected": func(t *testing.T) test {
+			return test{
+				auth: &mockAdminAuthority{
+					MockReviewSCEPRequest: func(ctx context.Context, provisionerName, id string, approve bool) (*db.SCEPRequest, error) {
+						assert.False(t, approve)
+						return &db.SCEPRequest{ID: id, Provisioner: provisionerName, Status: db.SCEPRequestRejected}, nil
+					},
+				},
+				body:       []byte(`{"status":"rejected"}`),
+				statusCode: 200,
+				resp:       &db.SCEPRequest{ID: "tx-1", Provisioner: "scep", Status: db.SCEPRequestRejected},
+			}
+		},
+	}
+	for name, prep := range tests {
+		tc := prep(t)
+		t.Run(name, func(t *testing.T) {
+			h := &Handler{
+				auth: tc.auth,
+			}
+
+			chiCtx := chi.NewRouteContext()
+			chiCtx.URLParams.Add("provisionerName", "scep")
+			chiCtx.URLParams.Add("id", "tx-1")
+			ctx := context.WithValue(context.Background(), chi.RouteCtxKey, chiCtx)
+			req := httptest.NewRequest("PATCH", "/foo", bytes.NewReader(tc.body)).WithContext(ctx)
+			w := httptest.NewRecorder()
+			h.ReviewSCEPRequest(w, req)
+			res := w.Result()
+
+			assert.Equals(t, tc.statusCode, res.StatusCode)
+
+			body, err := io.ReadAll(res.Body)
+			res.Body.Close()
+			assert.FatalError(t, err)
+
+			if res.StatusCode >= 400 {
+				adminErr := admin.Error{}
+				assert.FatalError(t, json.Unmarshal(bytes.TrimSpace(body), &adminErr))
+
+				assert.Equals(t, tc.err.Type, adminErr.Type)
+				assert.Equals(t, tc.err.Message, adminErr.Message)
+				assert.Equals(t, tc.err.Detail, adminErr.Detail)
+				assert.Equals(t, []string{"application/json"}, res.Header["Content-Type"])
+				return
+			}
+
+			expected, err := json.Marshal(tc.resp)
+			assert.FatalError(t, err)
+			assert.Equals(t, expected, bytes.TrimSpace(body))
+			assert.Equals(t, []string{"application/json"}, res.Header["Content-Type"])
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/policy"
 	"go.step.sm/linkedca"
 )
 
@@ -69,6 +70,16 @@ type DB interface {
 	GetAdmins(ctx context.Context) ([]*linkedca.Admin, error)
 	UpdateAdmin(ctx context.Context, admin *linkedca.Admin) error
 	DeleteAdmin(ctx context.Context, id string) error
+
+	CreateAuthorityPolicy(ctx context.Context, pol *policy.Options) error
+	GetAuthorityPolicy(ctx context.Context) (*policy.Options, error)
+	UpdateAuthorityPolicy(ctx context.Context, pol *policy.Options) error
+	DeleteAuthorityPolicy(ctx context.Context) error
+
+	CreateProvisionerPolicy(ctx context.Context, provisionerID string, pol *policy.Options) error
+	GetProvisionerPolicy(ctx context.Context, provisionerID string) (*policy.Options, error)
+	UpdateProvisionerPolicy(ctx context.Context, provisionerID string, pol *policy.Options) error
+	DeleteProvisionerPolicy(ctx context.Context, provisionerID string) error
 }
 
 // MockDB is an implementation of the DB interface that should only be used as
@@ -86,6 +97,16 @@ type MockDB struct {
 	MockUpdateAdmin func(ctx context.Context, adm *linkedca.Admin) error
 	MockDeleteAdmin func(ctx context.Context, id string) error
 
+	MockCreateAuthorityPolicy func(ctx context.Context, pol *policy.Options) error
+	MockGetAuthorityPolicy    func(ctx context.Context) (*policy.Options, error)
+	MockUpdateAuthorityPolicy func(ctx context.Context, pol *policy.Options) error
+	MockDeleteAuthorityPolicy func(ctx context.Context) error
+
+	MockCreateProvisionerPolicy func(ctx context.Context, provisionerID string, pol *policy.Options) error
+	MockGetProvisionerPolicy    func(ctx context.Context, provisionerID string) (*policy.Options, error)
+	MockUpdateProvisionerPolicy func(ctx context.Context, provisionerID string, pol *policy.Options) error
+	MockDeleteProvisionerPolicy func(ctx context.Context, provisionerID string) error
+
 	MockError error
 	MockRet1  interface{}
 }
@@ -179,3 +200,73 @@ func (m *MockDB) DeleteAdmin(ctx context.Context, id string) error {
 	}
 	return m.MockError
 }
+
+// CreateAuthorityPolicy mock
+func (m *MockDB) CreateAuthorityPolicy(ctx context.Context, pol *policy.Options) error {
+	if m.MockCreateAuthorityPolicy != nil {
+		return m.MockCreateAuthorityPolicy(ctx, pol)
+	}
+	return m.MockError
+}
+
+// GetAuthorityPolicy mock
+func (m *MockDB) GetAuthorityPolicy(ctx context.Context) (*policy.Options, error) {
+	if m.MockGetAuthorityPolicy != nil {
+		return m.MockGetAuthorityPolicy(ctx)
+	} else if m.MockError != nil {
+		return nil, m.MockError
+	}
+	pol, _ := m.MockRet1.(*policy.Options)
+	return pol, m.MockError
+}
+
+// UpdateAuthorityPolicy mock
+func (m *MockDB) UpdateAuthorityPolicy(ctx context.Context, pol *policy.Options) error {
+	if m.MockUpdateAuthorityPolicy != nil {
+		return m.MockUpdateAuthorityPolicy(ctx, pol)
+	}
+	return m.MockError
+}
+
+// DeleteAuthorityPolicy mock
+func (m *MockDB) DeleteAuthorityPolicy(ctx context.Context) error {
+	if m.MockDeleteAuthorityPolicy != nil {
+		return m.MockDeleteAuthorityPolicy(ctx)
+	}
+	return m.MockError
+}
+
+// CreateProvisionerPolicy mock
+func (m *MockDB) CreateProvisionerPolicy(ctx context.Context, provisionerID string, pol *policy.Options) error {
+	if m.MockCreateProvisionerPolicy != nil {
+		return m.MockCreateProvisionerPolicy(ctx, provisionerID, pol)
+	}
+	return m.MockError
+}
+
+// GetProvisionerPolicy mock
+func (m *MockDB) GetProvisionerPolicy(ctx context.Context, provisionerID string) (*policy.Options, error) {
+	if m.MockGetProvisionerPolicy != nil {
+		return m.MockGetProvisionerPolicy(ctx, provisionerID)
+	} else if m.MockError != nil {
+		return nil, m.MockError
+	}
+	pol, _ := m.MockRet1.(*policy.Options)
+	return pol, m.MockError
+}
+
+// UpdateProvisionerPolicy mock
+func (m *MockDB) UpdateProvisionerPolicy(ctx context.Context, provisionerID string, pol *policy.Options) error {
+	if m.MockUpdateProvisionerPolicy != nil {
+		return m.MockUpdateProvisionerPolicy(ctx, provisionerID, pol)
+	}
+	return m.MockError
+}
+
+// DeleteProvisionerPolicy mock
+func (m *MockDB) DeleteProvisionerPolicy(ctx context.Context, provisionerID string) error {
+	if m.MockDeleteProvisionerPolicy != nil {
+		return m.MockDeleteProvisionerPolicy(ctx, provisionerID)
+	}
+	return m.MockError
+}
@@ -11,8 +11,10 @@ import (
 )
 
 var (
-	adminsTable       = []byte("admins")
-	provisionersTable = []byte("provisioners")
+	adminsTable              = []byte("admins")
+	provisionersTable        = []byte("provisioners")
+	authorityPoliciesTable   = []byte("authority_policies")
+	provisionerPoliciesTable = []byte("provisioner_policies")
 )
 
 // DB is a struct that implements the AdminDB interface.
@@ -23,7 +25,7 @@ type DB struct {
 
 // New configures and returns a new Authority DB backend implemented using a nosql DB.
 func New(db nosqlDB.DB, authorityID string) (*DB, error) {
-	tables := [][]byte{adminsTable, provisionersTable}
+	tables := [][]byte{adminsTable, provisionersTable, authorityPoliciesTable, provisionerPoliciesTable}
 	for _, b := range tables {
 		if err := db.CreateTable(b); err != nil {
 			return nil, errors.Wrapf(err, "error creating table %s",
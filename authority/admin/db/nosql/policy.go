@@ -0,0 +1,142 @@
+package nosql
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/policy"
+	"github.com/smallstep/nosql"
+)
+
+// dbPolicy is the database representation of the policy of an authority or a
+// provisioner.
+type dbPolicy struct {
+	ID          string          `json:"id"`
+	AuthorityID string          `json:"authorityID"`
+	Policy      *policy.Options `json:"policy"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+}
+
+func (dbp *dbPolicy) clone() *dbPolicy {
+	u := *dbp
+	return &u
+}
+
+func (db *DB) getDBPolicy(ctx context.Context, table []byte, typ, id string) (*dbPolicy, error) {
+	data, err := db.db.Get(table, []byte(id))
+	if nosql.IsErrNotFound(err) {
+		return nil, admin.NewError(admin.ErrorNotFoundType, "%s policy %s not found", typ, id)
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "error loading %s policy %s", typ, id)
+	}
+	var dbp = new(dbPolicy)
+	if err := json.Unmarshal(data, dbp); err != nil {
+		return nil, errors.Wrapf(err, "error unmarshaling %s policy %s into dbPolicy", typ, id)
+	}
+	if dbp.AuthorityID != db.authorityID {
+		return nil, admin.NewError(admin.ErrorAuthorityMismatchType,
+			"%s policy %s is not owned by authority %s", typ, id, db.authorityID)
+	}
+	return dbp, nil
+}
+
+func (db *DB) createPolicy(ctx context.Context, table []byte, typ, id string, pol *policy.Options) error {
+	if _, err := db.getDBPolicy(ctx, table, typ, id); err == nil {
+		return admin.NewError(admin.ErrorBadRequestType, "%s policy %s already exists", typ, id)
+	} else if e, ok := err.(*admin.Error); !ok || !e.IsType(admin.ErrorNotFoundType) {
+		return err
+	}
+
+	now := clock.Now()
+	dbp := &dbPolicy{
+		ID:          id,
+		AuthorityID: db.authorityID,
+		Policy:      pol,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := db.save(ctx, id, dbp, nil, typ+" policy", table); err != nil {
+		return admin.WrapErrorISE(err, "error creating %s policy %s", typ, id)
+	}
+	return nil
+}
+
+func (db *DB) updatePolicy(ctx context.Context, table []byte, typ, id string, pol *policy.Options) error {
+	old, err := db.getDBPolicy(ctx, table, typ, id)
+	if err != nil {
+		return err
+	}
+
+	nu := old.clone()
+	nu.Policy = pol
+	nu.UpdatedAt = clock.Now()
+
+	return db.save(ctx, id, nu, old, typ+" policy", table)
+}
+
+func (db *DB) deletePolicy(ctx context.Context, table []byte, typ, id string) error {
+	if _, err := db.getDBPolicy(ctx, table, typ, id); err != nil {
+		return err
+	}
+	if err := db.db.Del(table, []byte(id)); err != nil {
+		return errors.Wrapf(err, "error deleting %s policy %s", typ, id)
+	}
+	return nil
+}
+
+// CreateAuthorityPolicy stores the policy of the authority to the database.
+func (db *DB) CreateAuthorityPolicy(ctx context.Context, pol *policy.Options) error {
+	return db.createPolicy(ctx, authorityPoliciesTable, "authority", db.authorityID, pol)
+}
+
+// GetAuthorityPolicy retrieves and unmarshals the policy of the authority from
+// the database.
+func (db *DB) GetAuthorityPolicy(ctx context.Context) (*policy.Options, error) {
+	dbp, err := db.getDBPolicy(ctx, authorityPoliciesTable, "authority", db.authorityID)
+	if err != nil {
+		return nil, err
+	}
+	return dbp.Policy, nil
+}
+
+// UpdateAuthorityPolicy saves an updated policy of the authority to the
+// database.
+func (db *DB) UpdateAuthorityPolicy(ctx context.Context, pol *policy.Options) error {
+	return db.updatePolicy(ctx, authorityPoliciesTable, "authority", db.authorityID, pol)
+}
+
+// DeleteAuthorityPolicy deletes the policy of the authority from the database.
+func (db *DB) DeleteAuthorityPolicy(ctx context.Context) error {
+	return db.deletePolicy(ctx, authorityPoliciesTable, "authority", db.authorityID)
+}
+
+// CreateProvisionerPolicy stores the policy of a provisioner to the database.
+func (db *DB) CreateProvisionerPolicy(ctx context.Context, provisionerID string, pol *policy.Options) error {
+	return db.createPolicy(ctx, provisionerPoliciesTable, "provisioner", provisionerID, pol)
+}
+
+// GetProvisionerPolicy retrieves and unmarshals the policy of a provisioner
+// from the database.
+func (db *DB) GetProvisionerPolicy(ctx context.Context, provisionerID string) (*policy.Options, error) {
+	dbp, err := db.getDBPolicy(ctx, provisionerPoliciesTable, "provisioner", provisionerID)
+	if err != nil {
+		return nil, err
+	}
+	return dbp.Policy, nil
+}
+
+// UpdateProvisionerPolicy saves an updated policy of a provisioner to the
+// database.
+func (db *DB) UpdateProvisionerPolicy(ctx context.Context, provisionerID string, pol *policy.Options) error {
+	return db.updatePolicy(ctx, provisionerPoliciesTable, "provisioner", provisionerID, pol)
+}
+
+// DeleteProvisionerPolicy deletes the policy of a provisioner from the
+// database.
+func (db *DB) DeleteProvisionerPolicy(ctx context.Context, provisionerID string) error {
+	return db.deletePolicy(ctx, provisionerPoliciesTable, "provisioner", provisionerID)
+}
@@ -0,0 +1,342 @@
+package nosql
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/db"
+	"github.com/smallstep/certificates/policy"
+	"github.com/smallstep/nosql"
+	nosqldb "github.com/smallstep/nosql/database"
+)
+
+func mustDBPolicy(t *testing.T, authorityID string, pol *policy.Options) []byte {
+	t.Helper()
+	b, err := json.Marshal(&dbPolicy{
+		ID:          "id",
+		AuthorityID: authorityID,
+		Policy:      pol,
+		CreatedAt:   clock.Now(),
+		UpdatedAt:   clock.Now(),
+	})
+	assert.FatalError(t, err)
+	return b
+}
+
+func assertAdminError(t *testing.T, err error, wantErr error, wantType admin.ProblemType) {
+	t.Helper()
+	if k, ok := err.(*admin.Error); ok && wantType != admin.ErrorServerInternalType {
+		assert.True(t, k.IsType(wantType))
+		assert.Equals(t, k.Err.Error(), wantErr.Error())
+		return
+	}
+	assert.HasPrefix(t, err.Error(), wantErr.Error())
+}
+
+func TestDB_GetAuthorityPolicy(t *testing.T) {
+	pol := &policy.Options{X509: &policy.X509Options{
+		Allow: &policy.X509NameOptions{DNSDomains: []string{"*.example.com"}},
+	}}
+	type test struct {
+		db      nosql.DB
+		want    *policy.Options
+		err     error
+		errType admin.ProblemType
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/not-found": func(t *testing.T) test {
+			return test{
+				db: &db.MockNoSQLDB{
+					MGet: func(bucket, key []byte) ([]byte, error) {
+						assert.Equals(t, bucket, authorityPoliciesTable)
+						assert.Equals(t, string(key), admin.DefaultAuthorityID)
+						return nil, nosqldb.ErrNotFound
+					},
+				},
+				err:     errors.New("authority policy 00000000-0000-0000-0000-000000000000 not found"),
+				errType: admin.ErrorNotFoundType,
+			}
+		},
+		"fail/db.Get-error": func(t *testing.T) test {
+			return test{
+				db: &db.MockNoSQLDB{
+					MGet: func(bucket, key []byte) ([]byte, error) {
+						return nil, errors.New("force")
+					},
+				},
+				err:     errors.New("error loading authority policy 00000000-0000-0000-0000-000000000000: force"),
+				errType: admin.ErrorServerInternalType,
+			}
+		},
+		"fail/unmarshal-error": func(t *testing.T) test {
+			return test{
+				db: &db.MockNoSQLDB{
+					MGet: func(bucket, key []byte) ([]byte, error) {
+						return []byte("foo"), nil
+					},
+				},
+				err:     errors.New("error unmarshaling authority policy 00000000-0000-0000-0000-000000000000 into dbPolicy"),
+				errType: admin.ErrorServerInternalType,
+			}
+		},
+		"fail/authority-mismatch": func(t *testing.T) test {
+			return test{
+				db: &db.MockNoSQLDB{
+					MGet: func(bucket, key []byte) ([]byte, error) {
+						return mustDBPolicy(t, "foo", pol), nil
+					},
+				},
+				err:     errors.New("authority policy 00000000-0000-0000-0000-000000000000 is not owned by authority 00000000-0000-0000-0000-000000000000"),
+				errType: admin.ErrorAuthorityMismatchType,
+			}
+		},
+		"ok": func(t *testing.T) test {
+			return test{
+				db: &db.MockNoSQLDB{
+					MGet: func(bucket, key []byte) ([]byte, error) {
+						return mustDBPolicy(t, admin.DefaultAuthorityID, pol), nil
+					},
+				},
+				want: pol,
+			}
+		},
+	}
+	for name, run := range tests {
+		tc := run(t)
+		t.Run(name, func(t *testing.T) {
+			d := DB{db: tc.db, authorityID: admin.DefaultAuthorityID}
+			got, err := d.GetAuthorityPolicy(context.Background())
+			if err != nil {
+				if assert.NotNil(t, tc.err) {
+					assertAdminError(t, err, tc.err, tc.errType)
+				}
+				return
+			}
+			assert.Nil(t, tc.err)
+			assert.Equals(t, tc.want, got)
+		})
+	}
+}
+
+func TestDB_CreateProvisionerPolicy(t *testing.T) {
+	pol := &policy.Options{SSH: &policy.SSHOptions{
+		User: &policy.SSHCertificateOptions{Allow: &policy.SSHNameOptions{Principals: []string{"jane"}}},
+	}}
+	type test struct {
+		db      nosql.DB
+		err     error
+		errType admin.ProblemType
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/already-exists": func(t *testing.T) test {
+			return test{
+				db: &db.MockNoSQLDB{
+					MGet: func(bucket, key []byte) ([]byte, error) {
+						assert.Equals(t, bucket, provisionerPoliciesTable)
+						assert.Equals(t, string(key), "provID")
+						return mustDBPolicy(t, admin.DefaultAuthorityID, pol), nil
+					},
+				},
+				err:     errors.New("provisioner policy provID already exists"),
+				errType: admin.ErrorBadRequestType,
+			}
+		},
+		"fail/db.Get-error": func(t *testing.T) test {
+			return test{
+				db: &db.MockNoSQLDB{
+					MGet: func(bucket, key []byte) ([]byte, error) {
+						return nil, errors.New("force")
+					},
+				},
+				err:     errors.New("error loading provisioner policy provID: force"),
+				errType: admin.ErrorServerInternalType,
+			}
+		},
+		"fail/save-error": func(t *testing.T) test {
+			return test{
+				db: &db.MockNoSQLDB{
+					MGet: func(bucket, key []byte) ([]byte, error) {
+						return nil, nosqldb.ErrNotFound
+					},
+					MCmpAndSwap: func(bucket, key, old, nu []byte) ([]byte, bool, error) {
+						return nil, false, errors.New("force")
+					},
+				},
+				err:     errors.New("error creating provisioner policy provID: error saving authority provisioner policy: force"),
+				errType: admin.ErrorServerInternalType,
+			}
+		},
+		"ok": func(t *testing.T) test {
+			return test{
+				db: &db.MockNoSQLDB{
+					MGet: func(bucket, key []byte) ([]byte, error) {
+						return nil, nosqldb.ErrNotFound
+					},
+					MCmpAndSwap: func(bucket, key, old, nu []byte) ([]byte, bool, error) {
+						assert.Equals(t, bucket, provisionerPoliciesTable)
+						assert.Equals(t, string(key), "provID")
+						assert.Nil(t, old)
+
+						var dbp dbPolicy
+						assert.FatalError(t, json.Unmarshal(nu, &dbp))
+						assert.Equals(t, "provID", dbp.ID)
+						assert.Equals(t, admin.DefaultAuthorityID, dbp.AuthorityID)
+						assert.Equals(t, pol, dbp.Policy)
+						assert.False(t, dbp.CreatedAt.IsZero())
+						return nu, true, nil
+					},
+				},
+			}
+		},
+	}
+	for name, run := range tests {
+		tc := run(t)
+		t.Run(name, func(t *testing.T) {
+			d := DB{db: tc.db, authorityID: admin.DefaultAuthorityID}
+			if err := d.CreateProvisionerPolicy(context.Background(), "provID", pol); err != nil {
+				if assert.NotNil(t, tc.err) {
+					assertAdminError(t, err, tc.err, tc.errType)
+				}
+				return
+			}
+			assert.Nil(t, tc.err)
+		})
+	}
+}
+
+func TestDB_UpdateAuthorityPolicy(t *testing.T) {
+	old := &policy.Options{X509: &policy.X509Options{AllowWildcardNames: true}}
+	nu := &policy.Options{X509: &policy.X509Options{
+		Deny: &policy.X509NameOptions{DNSDomains: []string{"*.example.com"}},
+	}}
+	type test struct {
+		db      nosql.DB
+		err     error
+		errType admin.ProblemType
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/not-found": func(t *testing.T) test {
+			return test{
+				db: &db.MockNoSQLDB{
+					MGet: func(bucket, key []byte) ([]byte, error) {
+						return nil, nosqldb.ErrNotFound
+					},
+				},
+				err:     errors.New("authority policy 00000000-0000-0000-0000-000000000000 not found"),
+				errType: admin.ErrorNotFoundType,
+			}
+		},
+		"fail/save-error": func(t *testing.T) test {
+			return test{
+				db: &db.MockNoSQLDB{
+					MGet: func(bucket, key []byte) ([]byte, error) {
+						return mustDBPolicy(t, admin.DefaultAuthorityID, old), nil
+					},
+					MCmpAndSwap: func(bucket, key, old, nu []byte) ([]byte, bool, error) {
+						return nil, false, errors.New("force")
+					},
+				},
+				err:     errors.New("error saving authority authority policy: force"),
+				errType: admin.ErrorServerInternalType,
+			}
+		},
+		"ok": func(t *testing.T) test {
+			return test{
+				db: &db.MockNoSQLDB{
+					MGet: func(bucket, key []byte) ([]byte, error) {
+						return mustDBPolicy(t, admin.DefaultAuthorityID, old), nil
+					},
+					MCmpAndSwap: func(bucket, key, oldB, nuB []byte) ([]byte, bool, error) {
+						assert.Equals(t, bucket, authorityPoliciesTable)
+						assert.Equals(t, string(key), admin.DefaultAuthorityID)
+
+						var dbp dbPolicy
+						assert.FatalError(t, json.Unmarshal(nuB, &dbp))
+						assert.Equals(t, nu, dbp.Policy)
+						return nuB, true, nil
+					},
+				},
+			}
+		},
+	}
+	for name, run := range tests {
+		tc := run(t)
+		t.Run(name, func(t *testing.T) {
+			d := DB{db: tc.db, authorityID: admin.DefaultAuthorityID}
+			if err := d.UpdateAuthorityPolicy(context.Background(), nu); err != nil {
+				if assert.NotNil(t, tc.err) {
+					assertAdminError(t, err, tc.err, tc.errType)
+				}
+				return
+			}
+			assert.Nil(t, tc.err)
+		})
+	}
+}
+
+func TestDB_DeleteProvisionerPolicy(t *testing.T) {
+	pol := &policy.Options{}
+	type test struct {
+		db      nosql.DB
+		err     error
+		errType admin.ProblemType
+	}
+	var tests = map[string]func(t *testing.T) test{
+		"fail/not-found": func(t *testing.T) test {
+			return test{
+				db: &db.MockNoSQLDB{
+					MGet: func(bucket, key []byte) ([]byte, error) {
+						return nil, nosqldb.ErrNotFound
+					},
+				},
+				err:     errors.New("provisioner policy provID not found"),
+				errType: admin.ErrorNotFoundType,
+			}
+		},
+		"fail/db.Del-error": func(t *testing.T) test {
+			return test{
+				db: &db.MockNoSQLDB{
+					MGet: func(bucket, key []byte) ([]byte, error) {
+						return mustDBPolicy(t, admin.DefaultAuthorityID, pol), nil
+					},
+					MDel: func(bucket, key []byte) error {
+						return errors.New("force")
+					},
+				},
+				err:     errors.New("error deleting provisioner policy provID: force"),
+				errType: admin.ErrorServerInternalType,
+			}
+		},
+		"ok": func(t *testing.T) test {
+			return test{
+				db: &db.MockNoSQLDB{
+					MGet: func(bucket, key []byte) ([]byte, error) {
+						return mustDBPolicy(t, admin.DefaultAuthorityID, pol), nil
+					},
+					MDel: func(bucket, key []byte) error {
+						assert.Equals(t, bucket, provisionerPoliciesTable)
+						assert.Equals(t, string(key), "provID")
+						return nil
+					},
+				},
+			}
+		},
+	}
+	for name, run := range tests {
+		tc := run(t)
+		t.Run(name, func(t *testing.T) {
+			d := DB{db: tc.db, authorityID: admin.DefaultAuthorityID}
+			if err := d.DeleteProvisionerPolicy(context.Background(), "provID"); err != nil {
+				if assert.NotNil(t, tc.err) {
+					assertAdminError(t, err, tc.err, tc.errType)
+				}
+				return
+			}
+			assert.Nil(t, tc.err)
+		})
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/smallstep/certificates/kms"
 	kmsapi "github.com/smallstep/certificates/kms/apiv1"
 	"github.com/smallstep/certificates/kms/sshagentkms"
+	"github.com/smallstep/certificates/policy"
 	"github.com/smallstep/certificates/scep"
 	"github.com/smallstep/certificates/templates"
 	"github.com/smallstep/nosql"
@@ -34,6 +35,7 @@ import (
 // Authority implements the Certificate Authority internal interface.
 type Authority struct {
 	config        *config.Config
+	configFile    string
 	keyManager    kms.KeyManager
 	provisioners  *provisioner.Collection
 	admins        *administrator.Collection
@@ -52,9 +54,29 @@ type Authority struct {
 	certificates       *sync.Map
 	x509Enforcers      []provisioner.CertificateEnforcer
 
+	// Name policy
+	policyEngine *policy.Engine
+
+	// X509 issuer, used by the default CAS and updated on intermediate
+	// rotations.
+	issuerMutex    sync.RWMutex
+	x509Issuer     *x509Issuer
+	nextX509Issuer *x509Issuer
+	prevX509Issuer *x509Issuer
+	rotationTimer  *time.Timer
+
 	// SCEP CA
 	scepService *scep.Service
 
+	// CRL
+	crlMutex   sync.Mutex
+	crlTicker  *time.Ticker
+	crlStopper chan struct{}
+	prevCRL    []byte
+
+	// OCSP
+	ocspResponder *ocspResponder
+
 	// SSH CA
 	sshHostPassword         []byte
 	sshUserPassword         []byte
@@ -78,6 +100,9 @@ type Authority struct {
 	authorizeSSHRenewFunc provisioner.AuthorizeSSHRenewFunc
 
 	adminMutex sync.RWMutex
+
+	// Metrics
+	meter Meter
 }
 
 type Info struct {
@@ -159,6 +184,9 @@ func (a *Authority) reloadAdminResources(ctx context.Context) error {
 		provList  provisioner.List
 		adminList []*linkedca.Admin
 	)
+	// The authority policy in the admin database takes precedence over the
+	// one in the configuration file.
+	authorityPolicy := a.config.AuthorityConfig.Policy
 	if a.config.AuthorityConfig.EnableAdmin {
 		provs, err := a.adminDB.GetProvisioners(ctx)
 		if err != nil {
@@ -172,11 +200,34 @@ func (a *Authority) reloadAdminResources(ctx context.Context) error {
 		if err != nil {
 			return admin.WrapErrorISE(err, "error getting admins to initialize authority")
 		}
+		pol, err := a.adminDB.GetAuthorityPolicy(ctx)
+		switch {
+		case isPolicyNotFound(err):
+		case err != nil:
+			return admin.WrapErrorISE(err, "error getting authority policy")
+		case pol != nil:
+			authorityPolicy = pol
+		}
+		for _, p := range provList {
+			pol, err := a.adminDB.GetProvisionerPolicy(ctx, p.GetID())
+			switch {
+			case isPolicyNotFound(err):
+			case err != nil:
+				return admin.WrapErrorISE(err, "error getting policy of provisioner %s", p.GetName())
+			case pol != nil:
+				setProvisionerPolicy(p, pol)
+			}
+		}
 	} else {
 		provList = a.config.AuthorityConfig.Provisioners
 		adminList = a.config.AuthorityConfig.Admins
 	}
 
+	policyEngine, err := policy.New(authorityPolicy)
+	if err != nil {
+		return admin.WrapErrorISE(err, "error initializing authority policy")
+	}
+
 	provisionerConfig, err := a.generateProvisionerConfig(ctx)
 	if err != nil {
 		return admin.WrapErrorISE(err, "error generating provisioner config")
@@ -209,6 +260,7 @@ func (a *Authority) reloadAdminResources(ctx context.Context) error {
 	a.provisioners = provClxn
 	a.config.AuthorityConfig.Admins = adminList
 	a.admins = adminClxn
+	a.policyEngine = policyEngine
 	return nil
 }
 
@@ -244,7 +296,11 @@ func (a *Authority) init() error {
 	// Initialize step-ca Database if it's not already initialized with WithDB.
 	// If a.config.DB is nil then a simple, barebones in memory DB will be used.
 	if a.db == nil {
-		if a.db, err = db.New(a.config.DB); err != nil {
+		var dbOpts []db.Option
+		if a.meter != nil {
+			dbOpts = append(dbOpts, db.WithMeter(a.meter))
+		}
+		if a.db, err = db.New(a.config.DB, dbOpts...); err != nil {
 			return err
 		}
 	}
@@ -306,17 +362,16 @@ func (a *Authority) init() error {
 
 		// Read intermediate and create X509 signer for default CAS.
 		if options.Is(casapi.SoftCAS) {
-			options.CertificateChain, err = pemutil.ReadCertificateBundle(a.config.IntermediateCert)
+			// The chain and signer are read on each signature, so the
+			// intermediate can be rotated.
+			a.x509Issuer, err = a.loadX509Issuer(a.config.IntermediateCert, a.config.IntermediateKey)
 			if err != nil {
 				return err
 			}
-			options.Signer, err = a.keyManager.CreateSigner(&kmsapi.CreateSignerRequest{
-				SigningKey: a.config.IntermediateKey,
-				Password:   []byte(a.password),
-			})
-			if err != nil {
+			if err := a.loadIntermediateRotation(); err != nil {
 				return err
 			}
+			options.CertificateSigner = a.getX509Signer
 		}
 
 		a.x509CAService, err = cas.New(context.Background(), options)
@@ -391,6 +446,9 @@ func (a *Authority) init() error {
 			case *sshagentkms.WrappedSSHSigner:
 				a.sshCAHostCertSignKey = s.Sshsigner
 			case crypto.Signer:
+				if a.meter != nil {
+					s = newInstrumentedSigner(s, a.meter)
+				}
 				a.sshCAHostCertSignKey, err = ssh.NewSignerFromSigner(s)
 			default:
 				return errors.Errorf("unsupported signer type %T", signer)
@@ -417,6 +475,9 @@ func (a *Authority) init() error {
 			case *sshagentkms.WrappedSSHSigner:
 				a.sshCAUserCertSignKey = s.Sshsigner
 			case crypto.Signer:
+				if a.meter != nil {
+					s = newInstrumentedSigner(s, a.meter)
+				}
 				a.sshCAUserCertSignKey, err = ssh.NewSignerFromSigner(s)
 			default:
 				return errors.Errorf("unsupported signer type %T", signer)
@@ -514,6 +575,13 @@ func (a *Authority) init() error {
 		// TODO: mimick the x509CAService GetCertificateAuthority here too?
 	}
 
+	// Initialize the OCSP responder if it's enabled.
+	if a.config.OCSP.IsEnabled() && a.ocspResponder == nil {
+		if err := a.initOCSPResponder(); err != nil {
+			return err
+		}
+	}
+
 	if a.config.AuthorityConfig.EnableAdmin {
 		// Initialize step-ca Admin Database if it's not already initialized using
 		// WithAdminDB.
@@ -567,6 +635,12 @@ func (a *Authority) init() error {
 		a.templates.Data["Step"] = tmplVars
 	}
 
+	// Start the CRL generator, it will also make sure that there's a valid
+	// CRL in the database.
+	if err := a.startCRLGenerator(); err != nil {
+		return err
+	}
+
 	// JWT numeric dates are seconds.
 	a.startTime = time.Now().Truncate(time.Second)
 	// Set flag indicating that initialization has been completed, and should
@@ -610,20 +684,36 @@ func (a *Authority) IsAdminAPIEnabled() bool {
 
 // Shutdown safely shuts down any clients, databases, etc. held by the Authority.
 func (a *Authority) Shutdown() error {
+	a.stopCRLGenerator()
+	a.stopIntermediateRotation()
 	if err := a.keyManager.Close(); err != nil {
 		log.Printf("error closing the key manager: %v", err)
 	}
+	a.closeCAService()
 	return a.db.Shutdown()
 }
 
 // CloseForReload closes internal services, to allow a safe reload.
 func (a *Authority) CloseForReload() {
+	a.stopCRLGenerator()
+	a.stopIntermediateRotation()
 	if err := a.keyManager.Close(); err != nil {
 		log.Printf("error closing the key manager: %v", err)
 	}
 	if client, ok := a.adminDB.(*linkedCaClient); ok {
 		client.Stop()
 	}
+	a.closeCAService()
+}
+
+// closeCAService stops the background tasks of the X.509 CAS, like the
+// renewal of the credentials used with an external CA.
+func (a *Authority) closeCAService() {
+	if c, ok := a.x509CAService.(interface{ Close() error }); ok {
+		if err := c.Close(); err != nil {
+			log.Printf("error closing the certificate authority service: %v", err)
+		}
+	}
 }
 
 // IsRevoked returns whether or not a certificate has been
@@ -639,6 +729,59 @@ func (a *Authority) IsRevoked(sn string) (bool, error) {
 	return a.db.IsRevoked(sn)
 }
 
+// startCRLGenerator makes sure that a valid CRL is stored in the database and
+// starts a goroutine that will regenerate the CRL periodically.
+func (a *Authority) startCRLGenerator() error {
+	if !a.config.CRL.IsEnabled() {
+		return nil
+	}
+
+	// The CRL is not generated if the CAS maintains its own.
+	if _, ok := a.x509CAService.(casapi.CertificateAuthorityCRLGetter); ok {
+		return nil
+	}
+
+	// Check that there is a valid CRL in the DB right now. If it doesn't exist
+	// or is expired, generate one now.
+	crlInfo, err := a.db.GetCRL()
+	switch {
+	case err == db.ErrNotImplemented:
+		return errors.New("cannot enable the CRL without a persistence layer configured")
+	case err != nil && !nosql.IsErrNotFound(err):
+		return errors.Wrap(err, "error getting the CRL")
+	case err != nil || crlInfo.ExpiresAt.Before(time.Now().Add(a.config.CRL.CacheDuration.Duration-a.config.CRL.RenewPeriod.Duration)):
+		if err := a.GenerateCertificateRevocationList(); err != nil {
+			return errors.Wrap(err, "error generating the CRL")
+		}
+	}
+
+	a.crlTicker = time.NewTicker(a.config.CRL.RenewPeriod.Duration)
+	a.crlStopper = make(chan struct{}, 1)
+	go func(ticker *time.Ticker, stopper chan struct{}) {
+		for {
+			select {
+			case <-ticker.C:
+				if err := a.GenerateCertificateRevocationList(); err != nil {
+					log.Printf("error regenerating the CRL: %v", err)
+				}
+			case <-stopper:
+				return
+			}
+		}
+	}(a.crlTicker, a.crlStopper)
+
+	return nil
+}
+
+// stopCRLGenerator stops the goroutine started by startCRLGenerator.
+func (a *Authority) stopCRLGenerator() {
+	if a.crlTicker != nil {
+		a.crlTicker.Stop()
+		close(a.crlStopper)
+		a.crlTicker = nil
+	}
+}
+
 // requiresDecrypter returns whether the Authority
 // requires a KMS that provides a crypto.Decrypter
 // Currently this is only required when SCEP is
@@ -14,6 +14,8 @@ import (
 	"github.com/smallstep/certificates/authority/admin"
 	"github.com/smallstep/certificates/authority/provisioner"
 	"github.com/smallstep/certificates/errs"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.step.sm/crypto/jose"
 	"go.step.sm/linkedca"
 	"golang.org/x/crypto/ssh"
@@ -44,7 +46,13 @@ func SkipTokenReuseFromContext(ctx context.Context) bool {
 // authorizeToken parses the token and returns the provisioner used to generate
 // the token. This method enforces the One-Time use policy (tokens can only be
 // used once).
-func (a *Authority) authorizeToken(ctx context.Context, token string) (provisioner.Interface, error) {
+func (a *Authority) authorizeToken(ctx context.Context, token string) (p provisioner.Interface, err error) {
+	ctx, span := tracer.Start(ctx, "authority.authorizeToken")
+	defer func() {
+		span.SetAttributes(provisionerAttributes(p)...)
+		endSpan(span, err)
+	}()
+
 	// Validate payload
 	tok, err := jose.ParseSigned(token)
 	if err != nil {
@@ -69,7 +77,7 @@ func (a *Authority) authorizeToken(ctx context.Context, token string) (provision
 	}
 
 	// This method will also validate the audiences for JWK provisioners.
-	p, ok := a.provisioners.LoadByToken(tok, &claims.Claims)
+	prov, ok := a.provisioners.LoadByToken(tok, &claims.Claims)
 	if !ok {
 		return nil, errs.Unauthorized("authority.authorizeToken: provisioner "+
 			"not found or invalid audience (%s)", strings.Join(claims.Audience, ", "))
@@ -78,12 +86,12 @@ func (a *Authority) authorizeToken(ctx context.Context, token string) (provision
 	// Store the token to protect against reuse unless it's skipped.
 	// If we cannot get a token id from the provisioner, just hash the token.
 	if !SkipTokenReuseFromContext(ctx) {
-		if err := a.UseToken(token, p); err != nil {
+		if err := a.UseToken(token, prov); err != nil {
 			return nil, err
 		}
 	}
 
-	return p, nil
+	return prov, nil
 }
 
 // AuthorizeAdminToken authorize an Admin token.
@@ -201,6 +209,15 @@ func (a *Authority) UseToken(token string, prov provisioner.Interface) error {
 // Authorize grabs the method from the context and authorizes the request by
 // validating the one-time-token.
 func (a *Authority) Authorize(ctx context.Context, token string) ([]provisioner.SignOption, error) {
+	ctx, span := tracer.Start(ctx, "authority.Authorize", trace.WithAttributes(
+		attribute.String("step.method", provisioner.MethodFromContext(ctx).String()),
+	))
+	signOpts, err := a.authorize(ctx, token)
+	endSpan(span, err)
+	return signOpts, err
+}
+
+func (a *Authority) authorize(ctx context.Context, token string) ([]provisioner.SignOption, error) {
 	var opts = []interface{}{errs.WithKeyVal("token", token)}
 
 	switch m := provisioner.MethodFromContext(ctx); m {
@@ -241,7 +258,9 @@ func (a *Authority) authorizeSign(ctx context.Context, token string) ([]provisio
 	if err != nil {
 		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.authorizeSign")
 	}
-	signOpts, err := p.AuthorizeSign(ctx, token)
+	spanCtx, span := tracer.Start(ctx, "provisioner.AuthorizeSign", trace.WithAttributes(provisionerAttributes(p)...))
+	signOpts, err := p.AuthorizeSign(spanCtx, token)
+	endSpan(span, err)
 	if err != nil {
 		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.authorizeSign")
 	}
@@ -338,7 +357,8 @@ func (a *Authority) authorizeSSHSign(ctx context.Context, token string) ([]provi
 	if err != nil {
 		return nil, errs.Wrap(http.StatusUnauthorized, err, "authority.authorizeSSHSign")
 	}
-	return signOpts, nil
+	// Add the provisioner so SignSSH can enforce its policy.
+	return append(signOpts, p), nil
 }
 
 // authorizeSSHRenew authorizes an SSH certificate renewal request, by
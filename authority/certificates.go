@@ -0,0 +1,35 @@
+package authority
+
+import (
+	"crypto/x509"
+
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/db"
+)
+
+type certificateLister interface {
+	ListCertificates(opts db.ListCertificatesOptions) ([]*db.CertificateInfo, string, error)
+}
+
+// GetCertificates returns a page of the X.509 certificates issued by the CA
+// that match the given options, and the cursor of the next page. It returns a
+// not implemented error if the database does not support listing
+// certificates.
+func (a *Authority) GetCertificates(opts db.ListCertificatesOptions) ([]*db.CertificateInfo, string, error) {
+	lister, ok := a.db.(certificateLister)
+	if !ok {
+		return nil, "", admin.NewError(admin.ErrorNotImplementedType,
+			"the database does not support listing certificates")
+	}
+	certs, nextCursor, err := lister.ListCertificates(opts)
+	if err != nil {
+		return nil, "", admin.WrapErrorISE(err, "error listing certificates")
+	}
+	return certs, nextCursor, nil
+}
+
+// GetCertificate returns the X.509 certificate issued by the CA with the given
+// serial number.
+func (a *Authority) GetCertificate(serialNumber string) (*x509.Certificate, error) {
+	return a.db.GetCertificate(serialNumber)
+}
@@ -0,0 +1,64 @@
+package authority
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/db"
+)
+
+func TestAuthority_GetCertificates(t *testing.T) {
+	certs := []*db.CertificateInfo{
+		{Provisioner: &db.ProvisionerData{ID: "id", Name: "name", Type: "JWK"}},
+	}
+	tests := []struct {
+		name       string
+		db         db.AuthDB
+		opts       db.ListCertificatesOptions
+		want       []*db.CertificateInfo
+		wantCursor string
+		wantErr    bool
+		errType    admin.ProblemType
+	}{
+		{"ok", &db.MockAuthDB{
+			MListCertificates: func(opts db.ListCertificatesOptions) ([]*db.CertificateInfo, string, error) {
+				if opts.Provisioner != "name" || opts.Cursor != "1" || opts.Limit != 10 {
+					t.Errorf("unexpected options %v", opts)
+				}
+				return certs, "2", nil
+			},
+		}, db.ListCertificatesOptions{Provisioner: "name", Cursor: "1", Limit: 10}, certs, "2", false, 0},
+		{"fail/not-implemented", &db.SimpleDB{}, db.ListCertificatesOptions{}, nil, "", true, admin.ErrorNotImplementedType},
+		{"fail/error", &db.MockAuthDB{
+			MListCertificates: func(opts db.ListCertificatesOptions) ([]*db.CertificateInfo, string, error) {
+				return nil, "", errors.New("force")
+			},
+		}, db.ListCertificatesOptions{}, nil, "", true, admin.ErrorServerInternalType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := testAuthority(t)
+			a.db = tt.db
+			got, cursor, err := a.GetCertificates(tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Authority.GetCertificates() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				var adminErr *admin.Error
+				if !errors.As(err, &adminErr) || !adminErr.IsType(tt.errType) {
+					t.Errorf("Authority.GetCertificates() error type = %v, want %v", err, tt.errType)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Authority.GetCertificates() = %v, want %v", got, tt.want)
+			}
+			if cursor != tt.wantCursor {
+				t.Errorf("Authority.GetCertificates() cursor = %v, want %v", cursor, tt.wantCursor)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"net/url"
 	"os"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 	cas "github.com/smallstep/certificates/cas/apiv1"
 	"github.com/smallstep/certificates/db"
 	kms "github.com/smallstep/certificates/kms/apiv1"
+	"github.com/smallstep/certificates/policy"
 	"github.com/smallstep/certificates/templates"
 	"go.step.sm/linkedca"
 )
@@ -32,6 +34,11 @@ var (
 	// DefaultEnableSSHCA enable SSH CA features per provisioner or globally
 	// for all provisioners.
 	DefaultEnableSSHCA = false
+	// DefaultCRLCacheDuration is the default cache duration for the CRL.
+	DefaultCRLCacheDuration = 24 * time.Hour
+	// DefaultOCSPResponseDuration is the default validity of an OCSP response,
+	// the time between the thisUpdate and nextUpdate fields.
+	DefaultOCSPResponseDuration = 1 * time.Hour
 	// GlobalProvisionerClaims default claims for the Authority. Can be overridden
 	// by provisioner specific claims.
 	GlobalProvisionerClaims = provisioner.Claims{
@@ -69,6 +76,110 @@ type Config struct {
 	Password         string               `json:"password,omitempty"`
 	Templates        *templates.Templates `json:"templates,omitempty"`
 	CommonName       string               `json:"commonName,omitempty"`
+	CRL              *CRLConfig           `json:"crl,omitempty"`
+	OCSP             *OCSPConfig          `json:"ocsp,omitempty"`
+}
+
+// CRLConfig represents config options for CRL generation.
+type CRLConfig struct {
+	Enabled           bool                  `json:"enabled"`
+	CacheDuration     *provisioner.Duration `json:"cacheDuration,omitempty"`
+	RenewPeriod       *provisioner.Duration `json:"renewPeriod,omitempty"`
+	DistributionPoint string                `json:"distributionPoint,omitempty"`
+}
+
+// IsEnabled returns if the CRL is enabled.
+func (c *CRLConfig) IsEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+// Validate validates the CRL configuration and sets the default values for
+// the cache duration and the renew period.
+func (c *CRLConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+
+	if c.CacheDuration == nil || c.CacheDuration.Duration == 0 {
+		c.CacheDuration = &provisioner.Duration{
+			Duration: DefaultCRLCacheDuration,
+		}
+	} else if c.CacheDuration.Duration < 0 {
+		return errors.New("crl.cacheDuration must be greater than or equal to 0")
+	}
+
+	// By default the CRL will be regenerated when two thirds of the cache
+	// duration have passed.
+	if c.RenewPeriod == nil || c.RenewPeriod.Duration == 0 {
+		c.RenewPeriod = &provisioner.Duration{
+			Duration: c.CacheDuration.Duration * 2 / 3,
+		}
+	} else if c.RenewPeriod.Duration < 0 {
+		return errors.New("crl.renewPeriod must be greater than or equal to 0")
+	} else if c.RenewPeriod.Duration > c.CacheDuration.Duration {
+		return errors.New("crl.renewPeriod cannot be longer than crl.cacheDuration")
+	}
+
+	if c.DistributionPoint != "" {
+		if u, err := url.Parse(c.DistributionPoint); err != nil || u.Scheme == "" || u.Host == "" {
+			return errors.Errorf("crl.distributionPoint %s is not a valid url", c.DistributionPoint)
+		}
+	}
+
+	return nil
+}
+
+// OCSPConfig represents config options for the OCSP responder. By default,
+// OCSP responses are signed by the intermediate key, if Certificate and Key
+// are set, the responses will be signed by that delegated OCSP responder.
+type OCSPConfig struct {
+	Enabled          bool                  `json:"enabled"`
+	URL              string                `json:"url,omitempty"`
+	Certificate      string                `json:"crt,omitempty"`
+	Key              string                `json:"key,omitempty"`
+	ResponseDuration *provisioner.Duration `json:"responseDuration,omitempty"`
+}
+
+// IsEnabled returns if the OCSP responder is enabled.
+func (c *OCSPConfig) IsEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+// IsDelegated returns if the OCSP responses are signed by a delegated OCSP
+// responder certificate.
+func (c *OCSPConfig) IsDelegated() bool {
+	return c != nil && c.Certificate != ""
+}
+
+// Validate validates the OCSP configuration and sets the default value for the
+// response duration.
+func (c *OCSPConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+
+	if c.ResponseDuration == nil || c.ResponseDuration.Duration == 0 {
+		c.ResponseDuration = &provisioner.Duration{
+			Duration: DefaultOCSPResponseDuration,
+		}
+	} else if c.ResponseDuration.Duration < 0 {
+		return errors.New("ocsp.responseDuration must be greater than or equal to 0")
+	}
+
+	switch {
+	case c.Certificate != "" && c.Key == "":
+		return errors.New("ocsp.key cannot be empty if ocsp.crt is set")
+	case c.Certificate == "" && c.Key != "":
+		return errors.New("ocsp.crt cannot be empty if ocsp.key is set")
+	}
+
+	if c.URL != "" {
+		if u, err := url.Parse(c.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			return errors.Errorf("ocsp.url %s is not a valid url", c.URL)
+		}
+	}
+
+	return nil
 }
 
 // ASN1DN contains ASN1.DN attributes that are used in Subject and Issuer
@@ -98,6 +209,7 @@ type AuthConfig struct {
 	DisableIssuedAtCheck bool                  `json:"disableIssuedAtCheck,omitempty"`
 	Backdate             *provisioner.Duration `json:"backdate,omitempty"`
 	EnableAdmin          bool                  `json:"enableAdmin,omitempty"`
+	Policy               *policy.Options       `json:"policy,omitempty"`
 }
 
 // init initializes the required fields in the AuthConfig if they are not
@@ -140,6 +252,10 @@ func (c *AuthConfig) Validate(audiences provisioner.Audiences) error {
 		return errors.New("authority.backdate cannot be less than 0")
 	}
 
+	if err := c.Policy.Validate(); err != nil {
+		return errors.Wrap(err, "authority.policy is not valid")
+	}
+
 	return nil
 }
 
@@ -261,6 +377,19 @@ func (c *Config) Validate() error {
 		return err
 	}
 
+	// Validate crl config: nil is ok
+	if err := c.CRL.Validate(); err != nil {
+		return err
+	}
+
+	// Validate ocsp config: nil is ok
+	if err := c.OCSP.Validate(); err != nil {
+		return err
+	}
+	if c.OCSP.IsEnabled() && c.OCSP.URL == "" {
+		c.OCSP.URL = c.ocspURL()
+	}
+
 	return c.AuthorityConfig.Validate(c.GetAudiences())
 }
 
@@ -320,6 +449,22 @@ func (c *Config) Audience(path string) []string {
 	return audiences
 }
 
+// ocspURL returns the default url of the OCSP responder. OCSP is usually
+// requested over plain HTTP, so the insecure address is used if available.
+func (c *Config) ocspURL() string {
+	scheme, address := "https", c.Address
+	if c.InsecureAddress != "" {
+		scheme, address = "http", c.InsecureAddress
+	}
+	u := scheme + "://" + toHostname(c.DNSNames[0])
+	if _, port, err := net.SplitHostPort(address); err == nil && port != "" {
+		if (scheme == "https" && port != "443") || (scheme == "http" && port != "80") {
+			u += ":" + port
+		}
+	}
+	return u + "/ocsp"
+}
+
 func toHostname(name string) string {
 	// ensure an IPv6 address is represented with square brackets when used as hostname
 	if ip := net.ParseIP(name); ip != nil && ip.To4() == nil {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/assert"
@@ -353,3 +354,107 @@ func TestConfig_Audience(t *testing.T) {
 		})
 	}
 }
+
+func TestCRLConfig_Validate(t *testing.T) {
+	hours := func(n int) *provisioner.Duration {
+		return &provisioner.Duration{Duration: time.Duration(n) * time.Hour}
+	}
+	tests := []struct {
+		name              string
+		crl               *CRLConfig
+		wantCacheDuration *provisioner.Duration
+		wantRenewPeriod   *provisioner.Duration
+		wantErr           bool
+	}{
+		{"ok nil", nil, nil, nil, false},
+		{"ok defaults", &CRLConfig{Enabled: true}, hours(24), hours(16), false},
+		{"ok cacheDuration", &CRLConfig{Enabled: true, CacheDuration: hours(6)}, hours(6), hours(4), false},
+		{"ok renewPeriod", &CRLConfig{Enabled: true, CacheDuration: hours(6), RenewPeriod: hours(1)}, hours(6), hours(1), false},
+		{"ok distributionPoint", &CRLConfig{Enabled: true, DistributionPoint: "https://ca.example.com/crl"}, hours(24), hours(16), false},
+		{"fail cacheDuration", &CRLConfig{Enabled: true, CacheDuration: hours(-1)}, nil, nil, true},
+		{"fail renewPeriod", &CRLConfig{Enabled: true, RenewPeriod: hours(-1)}, nil, nil, true},
+		{"fail renewPeriod too long", &CRLConfig{Enabled: true, CacheDuration: hours(1), RenewPeriod: hours(2)}, nil, nil, true},
+		{"fail distributionPoint", &CRLConfig{Enabled: true, DistributionPoint: "/crl"}, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.crl.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("CRLConfig.Validate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.crl == nil || tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(tt.crl.CacheDuration, tt.wantCacheDuration) {
+				t.Errorf("CRLConfig.CacheDuration = %v, want %v", tt.crl.CacheDuration, tt.wantCacheDuration)
+			}
+			if !reflect.DeepEqual(tt.crl.RenewPeriod, tt.wantRenewPeriod) {
+				t.Errorf("CRLConfig.RenewPeriod = %v, want %v", tt.crl.RenewPeriod, tt.wantRenewPeriod)
+			}
+		})
+	}
+}
+
+func TestOCSPConfig_Validate(t *testing.T) {
+	hours := func(n int) *provisioner.Duration {
+		return &provisioner.Duration{Duration: time.Duration(n) * time.Hour}
+	}
+	tests := []struct {
+		name                 string
+		ocsp                 *OCSPConfig
+		wantResponseDuration *provisioner.Duration
+		wantErr              bool
+	}{
+		{"ok nil", nil, nil, false},
+		{"ok defaults", &OCSPConfig{Enabled: true}, hours(1), false},
+		{"ok responseDuration", &OCSPConfig{Enabled: true, ResponseDuration: hours(4)}, hours(4), false},
+		{"ok delegated", &OCSPConfig{Enabled: true, Certificate: "ocsp.crt", Key: "ocsp.key"}, hours(1), false},
+		{"ok url", &OCSPConfig{Enabled: true, URL: "http://ca.example.com/ocsp"}, hours(1), false},
+		{"fail responseDuration", &OCSPConfig{Enabled: true, ResponseDuration: hours(-1)}, nil, true},
+		{"fail crt", &OCSPConfig{Enabled: true, Key: "ocsp.key"}, nil, true},
+		{"fail key", &OCSPConfig{Enabled: true, Certificate: "ocsp.crt"}, nil, true},
+		{"fail url", &OCSPConfig{Enabled: true, URL: "/ocsp"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.ocsp.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("OCSPConfig.Validate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.ocsp == nil || tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(tt.ocsp.ResponseDuration, tt.wantResponseDuration) {
+				t.Errorf("OCSPConfig.ResponseDuration = %v, want %v", tt.ocsp.ResponseDuration, tt.wantResponseDuration)
+			}
+		})
+	}
+}
+
+func TestConfig_ocspURL(t *testing.T) {
+	tests := []struct {
+		name            string
+		dnsNames        []string
+		address         string
+		insecureAddress string
+		want            string
+	}{
+		{"ok", []string{"ca.example.com"}, ":443", "", "https://ca.example.com/ocsp"},
+		{"ok port", []string{"ca.example.com"}, ":9000", "", "https://ca.example.com:9000/ocsp"},
+		{"ok insecure", []string{"ca.example.com"}, ":443", ":80", "http://ca.example.com/ocsp"},
+		{"ok insecure port", []string{"ca.example.com"}, ":443", ":8080", "http://ca.example.com:8080/ocsp"},
+		{"ok ipv6", []string{"::1"}, ":9000", "", "https://[::1]:9000/ocsp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{
+				DNSNames:        tt.dnsNames,
+				Address:         tt.address,
+				InsecureAddress: tt.insecureAddress,
+			}
+			if got := c.ocspURL(); got != tt.want {
+				t.Errorf("Config.ocspURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
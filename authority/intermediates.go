@@ -0,0 +1,676 @@
+package authority
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/cas"
+	casapi "github.com/smallstep/certificates/cas/apiv1"
+	kmsapi "github.com/smallstep/certificates/kms/apiv1"
+	"go.step.sm/crypto/pemutil"
+)
+
+// x509Issuer contains the certificate chain and the signer used by the default
+// CAS to sign X.509 certificates.
+type x509Issuer struct {
+	chain  []*x509.Certificate
+	signer crypto.Signer
+}
+
+// intermediateRotation is the state of an intermediate rotation. It's stored
+// next to the intermediate certificate, so a restart or a reload during the
+// grace period keeps the new intermediate pending, and the previous
+// intermediate is kept after the cutover.
+type intermediateRotation struct {
+	// NextKey is the path or the KMS name of the key of the new intermediate,
+	// its certificate is stored with the .next suffix.
+	NextKey   string    `json:"nextKey,omitempty"`
+	CutoverAt time.Time `json:"cutoverAt,omitempty"`
+	// PrevKey is the path or the KMS name of the key of the previous
+	// intermediate, its certificate is stored with the .prev suffix.
+	PrevKey string `json:"prevKey,omitempty"`
+}
+
+const (
+	rotationStateSuffix = ".rotation"
+	nextSuffix          = ".next"
+	prevSuffix          = ".prev"
+)
+
+// cutoverRetryInterval is the time to wait before retrying a failed cutover.
+const cutoverRetryInterval = time.Minute
+
+// RotateIntermediateRequest is the request used to rotate the intermediate
+// certificate used to sign X.509 certificates.
+type RotateIntermediateRequest struct {
+	// Template is the template of the new intermediate certificate. If it's not
+	// set, the subject and the constraints of the current intermediate are
+	// used.
+	Template *x509.Certificate
+	// Lifetime is the lifetime of the new intermediate, if it's not set the
+	// lifetime of the current intermediate is used.
+	Lifetime time.Duration
+	// CreateKey is the request used to create the new key with the KMS. If it's
+	// not set, an ECDSA P-256 key will be created.
+	CreateKey *kmsapi.CreateKeyRequest
+	// RootKey is the key of the root certificate, it is used to sign the new
+	// intermediate.
+	RootKey string
+	// RootPassword is the password used to decrypt the root key.
+	RootPassword []byte
+	// Creator is the upstream CAS used to create the new intermediate if a
+	// root key is not provided.
+	Creator casapi.CertificateAuthorityCreator
+	// Parent is the name of the parent certificate authority in the upstream
+	// CAS.
+	Parent string
+	// GracePeriod is the time the new intermediate is published, along with
+	// the current one, before it's used to sign certificates. If it's zero,
+	// the new intermediate is used immediately.
+	GracePeriod time.Duration
+}
+
+// RotateIntermediateResponse is the response of an intermediate rotation. It
+// contains the new intermediate and the name of the new key.
+type RotateIntermediateResponse struct {
+	Certificate      *x509.Certificate
+	CertificateChain []*x509.Certificate
+	KeyName          string
+	PrivateKey       crypto.PrivateKey
+	CutoverAt        time.Time
+}
+
+// RotateIntermediate creates a new intermediate certificate, with a new key
+// generated by the configured key manager, signed by the root or by the
+// upstream CAS. During the grace period both the current and the new
+// intermediates are published, after it, the new intermediate is used to sign
+// certificates. The old intermediate is still published until it expires, so
+// the certificates issued by it can be renewed.
+//
+// The new intermediate is stored next to the configured certificate and key
+// paths until the cutover, then it replaces them, and the previous ones are
+// kept. If the key is kept by a KMS, its name is written to the configuration
+// file at the cutover.
+//
+// The rotation is only supported if the authority is using the default CAS.
+func (a *Authority) RotateIntermediate(ctx context.Context, req *RotateIntermediateRequest) (*RotateIntermediateResponse, error) {
+	resp, err := a.rotateIntermediate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if req.GracePeriod <= 0 {
+		if err := a.cutoverIntermediate(); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+func (a *Authority) rotateIntermediate(ctx context.Context, req *RotateIntermediateRequest) (*RotateIntermediateResponse, error) {
+	a.issuerMutex.Lock()
+	defer a.issuerMutex.Unlock()
+
+	switch {
+	case a.x509Issuer == nil:
+		return nil, admin.NewError(admin.ErrorBadRequestType, "intermediate rotation is only supported by the default CAS")
+	case a.nextX509Issuer != nil:
+		return nil, admin.NewError(admin.ErrorBadRequestType, "an intermediate rotation is already in progress")
+	case req.RootKey == "" && req.Creator == nil:
+		return nil, admin.NewError(admin.ErrorBadRequestType, "rotateIntermediateRequest 'rootKey' or 'creator' is required")
+	}
+
+	current := a.x509Issuer.chain[0]
+	template := req.Template
+	if template == nil {
+		template = &x509.Certificate{
+			Subject:               current.Subject,
+			KeyUsage:              current.KeyUsage,
+			ExtKeyUsage:           current.ExtKeyUsage,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+			MaxPathLen:            current.MaxPathLen,
+			MaxPathLenZero:        current.MaxPathLenZero,
+			PermittedDNSDomains:   current.PermittedDNSDomains,
+			ExcludedDNSDomains:    current.ExcludedDNSDomains,
+			PermittedIPRanges:     current.PermittedIPRanges,
+			ExcludedIPRanges:      current.ExcludedIPRanges,
+			PermittedURIDomains:   current.PermittedURIDomains,
+			ExcludedURIDomains:    current.ExcludedURIDomains,
+		}
+	}
+	lifetime := req.Lifetime
+	if lifetime == 0 {
+		lifetime = current.NotAfter.Sub(current.NotBefore)
+	}
+	if lifetime <= 0 {
+		return nil, errors.New("rotateIntermediateRequest 'lifetime' cannot be 0")
+	}
+
+	// The parent is the root that signed the current intermediate.
+	root := a.rootX509Certs[0]
+	for _, crt := range a.rootX509Certs {
+		if current.CheckSignatureFrom(crt) == nil {
+			root = crt
+			break
+		}
+	}
+	parent := &casapi.CreateCertificateAuthorityResponse{
+		Name:        req.Parent,
+		Certificate: root,
+	}
+
+	creator := req.Creator
+	if req.RootKey != "" {
+		signer, err := a.keyManager.CreateSigner(&kmsapi.CreateSignerRequest{
+			SigningKey: req.RootKey,
+			Password:   req.RootPassword,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "error loading root key")
+		}
+		if !publicKeyEqual(signer.Public(), root.PublicKey) {
+			return nil, admin.NewError(admin.ErrorBadRequestType, "root key does not match the root certificate")
+		}
+		parent.Signer = signer
+		if creator, err = cas.NewCreator(ctx, casapi.Options{
+			Type:       casapi.SoftCAS,
+			KeyManager: a.keyManager,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := creator.CreateCertificateAuthority(&casapi.CreateCertificateAuthorityRequest{
+		Name:      template.Subject.CommonName,
+		Type:      casapi.IntermediateCA,
+		Template:  template,
+		Lifetime:  lifetime,
+		Backdate:  a.config.AuthorityConfig.Backdate.Duration,
+		Parent:    parent,
+		CreateKey: req.CreateKey,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating intermediate certificate")
+	}
+
+	// Upstream CAS might only return the name of the key.
+	signer := resp.Signer
+	if signer == nil {
+		if resp.KeyName == "" {
+			return nil, errors.New("error creating intermediate certificate: signer is not available")
+		}
+		if signer, err = a.keyManager.CreateSigner(&kmsapi.CreateSignerRequest{
+			SigningKey: resp.KeyName,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if a.meter != nil {
+		signer = newInstrumentedSigner(signer, a.meter)
+	}
+
+	// The issuing chain does not include the roots.
+	chain := []*x509.Certificate{resp.Certificate}
+	for _, crt := range resp.CertificateChain {
+		if !a.isRoot(crt) {
+			chain = append(chain, crt)
+		}
+	}
+
+	// Persist the new intermediate before it can be used, so it's not lost if
+	// the CA is restarted.
+	cutoverAt := time.Now().Add(req.GracePeriod)
+	if err := a.persistNextIntermediate(chain, resp, cutoverAt); err != nil {
+		return nil, err
+	}
+
+	a.nextX509Issuer = &x509Issuer{
+		chain:  chain,
+		signer: signer,
+	}
+	if req.GracePeriod > 0 {
+		a.scheduleCutover(req.GracePeriod)
+	}
+
+	return &RotateIntermediateResponse{
+		Certificate:      resp.Certificate,
+		CertificateChain: chain[1:],
+		KeyName:          resp.KeyName,
+		PrivateKey:       resp.PrivateKey,
+		CutoverAt:        cutoverAt,
+	}, nil
+}
+
+// loadX509Issuer reads the certificate chain and creates the signer of an
+// intermediate.
+func (a *Authority) loadX509Issuer(certFile, key string) (*x509Issuer, error) {
+	chain, err := pemutil.ReadCertificateBundle(certFile)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := a.keyManager.CreateSigner(&kmsapi.CreateSignerRequest{
+		SigningKey: key,
+		Password:   []byte(a.password),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if a.meter != nil {
+		signer = newInstrumentedSigner(signer, a.meter)
+	}
+	return &x509Issuer{
+		chain:  chain,
+		signer: signer,
+	}, nil
+}
+
+// loadIntermediateRotation loads the state of an intermediate rotation. A
+// cutover that was due while the CA was stopped is completed, a pending one is
+// scheduled, and the previous intermediate is loaded until it expires.
+func (a *Authority) loadIntermediateRotation() error {
+	if a.config.IntermediateCert == "" {
+		return nil
+	}
+	st, err := a.readIntermediateRotation()
+	if err != nil {
+		return err
+	}
+
+	if st.NextKey != "" && !time.Now().Before(st.CutoverAt) {
+		if err := a.persistCutover(); err != nil {
+			return err
+		}
+		if a.x509Issuer, err = a.loadX509Issuer(a.config.IntermediateCert, a.config.IntermediateKey); err != nil {
+			return err
+		}
+		if st, err = a.readIntermediateRotation(); err != nil {
+			return err
+		}
+	}
+
+	if st.PrevKey != "" {
+		prev, err := a.loadX509Issuer(a.config.IntermediateCert+prevSuffix, st.PrevKey)
+		if err != nil {
+			return errors.Wrap(err, "error loading the previous intermediate")
+		}
+		if time.Now().Before(prev.chain[0].NotAfter) {
+			a.prevX509Issuer = prev
+		}
+	}
+
+	if st.NextKey != "" {
+		next, err := a.loadX509Issuer(a.config.IntermediateCert+nextSuffix, st.NextKey)
+		if err != nil {
+			return errors.Wrap(err, "error loading the new intermediate")
+		}
+		a.nextX509Issuer = next
+		a.scheduleCutover(time.Until(st.CutoverAt))
+	}
+	return nil
+}
+
+// readIntermediateRotation reads the state of the intermediate rotation. It
+// returns an empty state if there is no rotation.
+func (a *Authority) readIntermediateRotation() (*intermediateRotation, error) {
+	filename := a.config.IntermediateCert + rotationStateSuffix
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &intermediateRotation{}, nil
+		}
+		return nil, errors.Wrapf(err, "error reading %s", filename)
+	}
+	st := new(intermediateRotation)
+	if err := json.Unmarshal(b, st); err != nil {
+		return nil, errors.Wrapf(err, "error parsing %s", filename)
+	}
+	return st, nil
+}
+
+// writeIntermediateRotation writes the state of the intermediate rotation.
+func (a *Authority) writeIntermediateRotation(st *intermediateRotation) error {
+	filename := a.config.IntermediateCert + rotationStateSuffix
+	b, err := json.MarshalIndent(st, "", "\t")
+	if err != nil {
+		return errors.Wrapf(err, "error marshaling %s", filename)
+	}
+	return errors.Wrapf(writeFile(filename, append(b, '\n'), 0600), "error writing %s", filename)
+}
+
+// persistNextIntermediate stores the new intermediate certificate and key, with
+// the .next suffix, and the time of the cutover. If the key has been created
+// by a KMS, only its name is stored. The embedded authority, without configured
+// paths, keeps the intermediate only in memory.
+func (a *Authority) persistNextIntermediate(chain []*x509.Certificate, resp *casapi.CreateCertificateAuthorityResponse, cutoverAt time.Time) error {
+	if a.config.IntermediateCert == "" {
+		return nil
+	}
+	st, err := a.readIntermediateRotation()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case resp.PrivateKey != nil:
+		if a.config.IntermediateKey == "" {
+			return errors.New("error persisting intermediate key: key path is not configured")
+		}
+		var opts []pemutil.Options
+		if len(a.password) > 0 {
+			opts = append(opts, pemutil.WithPassword(a.password))
+		}
+		block, err := pemutil.Serialize(resp.PrivateKey, opts...)
+		if err != nil {
+			return errors.Wrap(err, "error serializing intermediate key")
+		}
+		st.NextKey = a.config.IntermediateKey + nextSuffix
+		if err := writeFile(st.NextKey, pem.EncodeToMemory(block), 0600); err != nil {
+			return errors.Wrap(err, "error writing intermediate key")
+		}
+	case resp.KeyName != "":
+		if a.configFile == "" {
+			return errors.New("error persisting intermediate key: configuration file is not available")
+		}
+		st.NextKey = resp.KeyName
+	default:
+		return errors.New("error persisting intermediate key: key is not available")
+	}
+
+	var buf bytes.Buffer
+	for _, crt := range chain {
+		if err := pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw}); err != nil {
+			return errors.Wrap(err, "error encoding intermediate certificate")
+		}
+	}
+	if err := writeFile(a.config.IntermediateCert+nextSuffix, buf.Bytes(), 0600); err != nil {
+		return errors.Wrap(err, "error writing intermediate certificate")
+	}
+
+	st.CutoverAt = cutoverAt
+	return a.writeIntermediateRotation(st)
+}
+
+// persistCutover replaces the configured intermediate certificate and key with
+// the new ones. The current certificate and key are kept with the .prev
+// suffix, or the name of the key if it's kept by a KMS, so they can be used
+// to answer for the certificates they issued. If it's interrupted, it can be
+// run again.
+func (a *Authority) persistCutover() error {
+	if a.config.IntermediateCert == "" {
+		return nil
+	}
+	st, err := a.readIntermediateRotation()
+	if err != nil || st.NextKey == "" {
+		return err
+	}
+
+	next, err := os.ReadFile(a.config.IntermediateCert + nextSuffix)
+	if err != nil {
+		return errors.Wrap(err, "error reading intermediate certificate")
+	}
+	current, err := os.ReadFile(a.config.IntermediateCert)
+	if err != nil {
+		return errors.Wrap(err, "error reading intermediate certificate")
+	}
+
+	// Keep the current intermediate, unless a previous run replaced it.
+	if !bytes.Equal(current, next) {
+		if err := writeFile(a.config.IntermediateCert+prevSuffix, current, 0600); err != nil {
+			return errors.Wrap(err, "error writing intermediate certificate")
+		}
+		st.PrevKey = a.config.IntermediateKey
+		if fi, err := os.Stat(a.config.IntermediateKey); err == nil && fi.Mode().IsRegular() {
+			b, err := os.ReadFile(a.config.IntermediateKey)
+			if err != nil {
+				return errors.Wrap(err, "error reading intermediate key")
+			}
+			st.PrevKey = a.config.IntermediateKey + prevSuffix
+			if err := writeFile(st.PrevKey, b, 0600); err != nil {
+				return errors.Wrap(err, "error writing intermediate key")
+			}
+		}
+		if err := a.writeIntermediateRotation(st); err != nil {
+			return err
+		}
+		if err := writeFile(a.config.IntermediateCert, next, 0600); err != nil {
+			return errors.Wrap(err, "error writing intermediate certificate")
+		}
+	}
+
+	switch st.NextKey {
+	case a.config.IntermediateKey:
+		// Nothing to do, the key was already replaced.
+	case a.config.IntermediateKey + nextSuffix:
+		if err := os.Rename(st.NextKey, a.config.IntermediateKey); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "error writing intermediate key")
+		}
+	default:
+		if err := updateConfigFile(a.configFile, "key", st.NextKey); err != nil {
+			return errors.Wrap(err, "error persisting intermediate key")
+		}
+		a.config.IntermediateKey = st.NextKey
+	}
+
+	if err := os.Remove(a.config.IntermediateCert + nextSuffix); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "error removing intermediate certificate")
+	}
+	st.NextKey = ""
+	st.CutoverAt = time.Time{}
+	return a.writeIntermediateRotation(st)
+}
+
+// updateConfigFile sets the value of a top level property in the given
+// configuration file. Only the value is replaced, so the order and the format
+// of the rest of the file are kept.
+func updateConfigFile(filename, key string, value interface{}) error {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return errors.Wrapf(err, "error reading %s", filename)
+	}
+	v, err := json.Marshal(value)
+	if err != nil {
+		return errors.Wrapf(err, "error marshaling %s", filename)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return errors.Errorf("error parsing %s: not a json object", filename)
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return errors.Wrapf(err, "error parsing %s", filename)
+		}
+		start := int(dec.InputOffset())
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return errors.Wrapf(err, "error parsing %s", filename)
+		}
+		if tok != key {
+			continue
+		}
+		// Skip the separator between the name and the value.
+		end := int(dec.InputOffset())
+		start += bytes.IndexFunc(b[start:end], func(r rune) bool {
+			return !strings.ContainsRune(" \t\r\n:", r)
+		})
+		data := append(append(append([]byte{}, b[:start]...), v...), b[end:]...)
+		return writeFile(filename, data, 0600)
+	}
+
+	// Add the property at the beginning of the object.
+	i := bytes.IndexByte(b, '{') + 1
+	prop := append([]byte("\n\t\""+key+"\": "), v...)
+	if len(bytes.TrimSpace(b[i:])) > 1 {
+		prop = append(prop, ',')
+	}
+	data := append(append(append([]byte{}, b[:i]...), prop...), b[i:]...)
+	return writeFile(filename, data, 0600)
+}
+
+// writeFile writes the data to a temporary file and renames it to the given
+// filename, so a failure does not leave a partially written file.
+func writeFile(filename string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
+
+// GetIntermediates returns the intermediate certificates published during an
+// intermediate rotation: the current and the new ones during the grace period
+// and, after it, the current and the previous ones until the previous one
+// expires. If there is no rotation it returns an empty list.
+// This method implements the Authority interface.
+func (a *Authority) GetIntermediates() ([]*x509.Certificate, error) {
+	a.issuerMutex.RLock()
+	defer a.issuerMutex.RUnlock()
+
+	if a.x509Issuer == nil {
+		return nil, nil
+	}
+
+	var chains [][]*x509.Certificate
+	if a.nextX509Issuer != nil {
+		chains = append(chains, a.nextX509Issuer.chain)
+	}
+	if a.prevX509Issuer != nil && time.Now().Before(a.prevX509Issuer.chain[0].NotAfter) {
+		chains = append(chains, a.prevX509Issuer.chain)
+	}
+	if len(chains) == 0 {
+		return nil, nil
+	}
+	chains = append([][]*x509.Certificate{a.x509Issuer.chain}, chains...)
+
+	var intermediates []*x509.Certificate
+	for _, chain := range chains {
+		for _, crt := range chain {
+			if !containsCertificate(intermediates, crt) {
+				intermediates = append(intermediates, crt)
+			}
+		}
+	}
+	return intermediates, nil
+}
+
+// GetX509IssuerChain returns the certificate chain of the intermediate used to
+// sign X.509 certificates, without the roots. It's the current intermediate
+// during a rotation.
+func (a *Authority) GetX509IssuerChain() ([]*x509.Certificate, error) {
+	a.issuerMutex.RLock()
+	defer a.issuerMutex.RUnlock()
+
+	if a.x509Issuer != nil {
+		return a.x509Issuer.chain, nil
+	}
+	if a.config.IntermediateCert == "" {
+		return nil, errors.New("the intermediate certificate is not available")
+	}
+	return pemutil.ReadCertificateBundle(a.config.IntermediateCert)
+}
+
+// getX509Signer returns the certificate chain and signer used by the default
+// CAS. It's used as the CertificateSigner of the CAS, so a rotated intermediate
+// is used without re-initializing the CAS.
+func (a *Authority) getX509Signer() ([]*x509.Certificate, crypto.Signer, error) {
+	a.issuerMutex.RLock()
+	defer a.issuerMutex.RUnlock()
+	return a.x509Issuer.chain, a.x509Issuer.signer, nil
+}
+
+// cutoverIntermediate replaces the current intermediate with the new one. The
+// new intermediate is written to the configured paths first, if it fails, the
+// current intermediate is kept and the cutover is retried later. After the
+// cutover the CRL is signed by the new intermediate.
+func (a *Authority) cutoverIntermediate() error {
+	a.issuerMutex.Lock()
+	if a.nextX509Issuer == nil {
+		a.issuerMutex.Unlock()
+		return nil
+	}
+	if a.rotationTimer != nil {
+		a.rotationTimer.Stop()
+		a.rotationTimer = nil
+	}
+	if err := a.persistCutover(); err != nil {
+		a.scheduleCutover(cutoverRetryInterval)
+		a.issuerMutex.Unlock()
+		return err
+	}
+	a.prevX509Issuer = a.x509Issuer
+	a.x509Issuer = a.nextX509Issuer
+	a.nextX509Issuer = nil
+	a.issuerMutex.Unlock()
+
+	if a.config.CRL.IsEnabled() {
+		if err := a.GenerateCertificateRevocationList(); err != nil {
+			log.Printf("error generating the CRL after the intermediate cutover: %v", err)
+		}
+	}
+	return nil
+}
+
+// scheduleCutover schedules the cutover of the new intermediate, the issuer
+// mutex must be held.
+func (a *Authority) scheduleCutover(d time.Duration) {
+	a.rotationTimer = time.AfterFunc(d, func() {
+		if err := a.cutoverIntermediate(); err != nil {
+			log.Printf("error replacing the intermediate: %v", err)
+		}
+	})
+}
+
+// stopIntermediateRotation stops a pending cutover.
+func (a *Authority) stopIntermediateRotation() {
+	a.issuerMutex.Lock()
+	defer a.issuerMutex.Unlock()
+	if a.rotationTimer != nil {
+		a.rotationTimer.Stop()
+		a.rotationTimer = nil
+	}
+}
+
+// isRoot returns true if the given certificate is one of the roots.
+func (a *Authority) isRoot(crt *x509.Certificate) bool {
+	return containsCertificate(a.rootX509Certs, crt)
+}
+
+func containsCertificate(certs []*x509.Certificate, crt *x509.Certificate) bool {
+	for _, c := range certs {
+		if bytes.Equal(c.Raw, crt.Raw) {
+			return true
+		}
+	}
+	return false
+}
+
+func publicKeyEqual(a, b crypto.PublicKey) bool {
+	if k, ok := a.(interface{ Equal(crypto.PublicKey) bool }); ok {
+		return k.Equal(b)
+	}
+	return false
+}
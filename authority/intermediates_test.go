@@ -0,0 +1,421 @@
+package authority
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority/provisioner"
+	casapi "github.com/smallstep/certificates/cas/apiv1"
+	"go.step.sm/crypto/pemutil"
+	"go.step.sm/crypto/x509util"
+)
+
+type mockCertificateAuthorityCreator struct {
+	createCertificateAuthority func(req *casapi.CreateCertificateAuthorityRequest) (*casapi.CreateCertificateAuthorityResponse, error)
+}
+
+func (m *mockCertificateAuthorityCreator) CreateCertificateAuthority(req *casapi.CreateCertificateAuthorityRequest) (*casapi.CreateCertificateAuthorityResponse, error) {
+	return m.createCertificateAuthority(req)
+}
+
+func testRotationAuthority(t *testing.T, rootCert *x509.Certificate, rootSigner crypto.Signer) (*Authority, string) {
+	t.Helper()
+	intSigner, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	now := time.Now()
+	intCert, err := x509util.CreateCertificate(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: "TestIntermediateCA"},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}, rootCert, intSigner.Public(), rootSigner)
+	assert.FatalError(t, err)
+
+	block, err := pemutil.Serialize(rootSigner, pemutil.WithPassword([]byte("pass")))
+	assert.FatalError(t, err)
+	rootKey := filepath.Join(t.TempDir(), "root_ca_key")
+	assert.FatalError(t, os.WriteFile(rootKey, pem.EncodeToMemory(block), 0600))
+
+	a, err := NewEmbedded(WithX509RootCerts(rootCert), WithX509Signer(intCert, intSigner))
+	assert.FatalError(t, err)
+	return a, rootKey
+}
+
+func testRotationSign(t *testing.T, a *Authority) []*x509.Certificate {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	cr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		DNSNames: []string{"foo.bar.zar"},
+	}, priv)
+	assert.FatalError(t, err)
+	csr, err := x509.ParseCertificateRequest(cr)
+	assert.FatalError(t, err)
+	chain, err := a.Sign(csr, provisioner.SignOptions{})
+	assert.FatalError(t, err)
+	return chain
+}
+
+func TestAuthority_RotateIntermediate(t *testing.T) {
+	root, rootSigner := generateRootCertificate(t)
+	a, rootKey := testRotationAuthority(t, root, rootSigner)
+	oldIntermediate := a.x509Issuer.chain[0]
+
+	// Certificate issued by the old intermediate.
+	oldChain := testRotationSign(t, a)
+	assert.Equals(t, oldIntermediate, oldChain[1])
+
+	intermediates, err := a.GetIntermediates()
+	assert.FatalError(t, err)
+	assert.Len(t, 0, intermediates)
+
+	resp, err := a.RotateIntermediate(context.Background(), &RotateIntermediateRequest{
+		RootKey:      rootKey,
+		RootPassword: []byte("pass"),
+		GracePeriod:  time.Hour,
+	})
+	assert.FatalError(t, err)
+	assert.FatalError(t, resp.Certificate.CheckSignatureFrom(root))
+	assert.Equals(t, oldIntermediate.Subject.CommonName, resp.Certificate.Subject.CommonName)
+	assert.Len(t, 0, resp.CertificateChain)
+	assert.NotNil(t, resp.PrivateKey)
+
+	// During the grace period both intermediates are published, and the old
+	// one is used to sign.
+	intermediates, err = a.GetIntermediates()
+	assert.FatalError(t, err)
+	assert.Equals(t, []*x509.Certificate{oldIntermediate, resp.Certificate}, intermediates)
+	chain := testRotationSign(t, a)
+	assert.Equals(t, oldIntermediate, chain[1])
+
+	// Only one rotation is allowed at the same time.
+	_, err = a.RotateIntermediate(context.Background(), &RotateIntermediateRequest{
+		RootKey:      rootKey,
+		RootPassword: []byte("pass"),
+	})
+	assert.Error(t, err)
+
+	// After the cutover the new intermediate is used.
+	assert.FatalError(t, a.cutoverIntermediate())
+	chain = testRotationSign(t, a)
+	assert.Equals(t, resp.Certificate, chain[1])
+	assert.FatalError(t, chain[0].CheckSignatureFrom(resp.Certificate))
+	intermediates, err = a.GetIntermediates()
+	assert.FatalError(t, err)
+	assert.Equals(t, []*x509.Certificate{resp.Certificate, oldIntermediate}, intermediates)
+
+	// Certificates issued by the old intermediate can be renewed.
+	renewed, err := a.Renew(oldChain[0])
+	assert.FatalError(t, err)
+	assert.Equals(t, resp.Certificate, renewed[1])
+	assert.Equals(t, oldChain[0].DNSNames, renewed[0].DNSNames)
+}
+
+func TestAuthority_RotateIntermediate_creator(t *testing.T) {
+	root, rootSigner := generateRootCertificate(t)
+	newIntermediate, newSigner := generateIntermidiateCertificate(t, root, rootSigner)
+
+	tests := []struct {
+		name    string
+		req     *RotateIntermediateRequest
+		wantErr bool
+	}{
+		{"ok", &RotateIntermediateRequest{
+			Parent: "projects/p/locations/l/caPools/c/certificateAuthorities/root",
+			Creator: &mockCertificateAuthorityCreator{
+				createCertificateAuthority: func(req *casapi.CreateCertificateAuthorityRequest) (*casapi.CreateCertificateAuthorityResponse, error) {
+					assert.Equals(t, casapi.IntermediateCA, req.Type)
+					assert.Equals(t, "projects/p/locations/l/caPools/c/certificateAuthorities/root", req.Parent.Name)
+					assert.Equals(t, root, req.Parent.Certificate)
+					return &casapi.CreateCertificateAuthorityResponse{
+						Certificate:      newIntermediate,
+						CertificateChain: []*x509.Certificate{root},
+						Signer:           newSigner,
+					}, nil
+				},
+			},
+		}, false},
+		{"fail creator", &RotateIntermediateRequest{
+			Creator: &mockCertificateAuthorityCreator{
+				createCertificateAuthority: func(req *casapi.CreateCertificateAuthorityRequest) (*casapi.CreateCertificateAuthorityResponse, error) {
+					return nil, os.ErrNotExist
+				},
+			},
+		}, true},
+		{"fail signer", &RotateIntermediateRequest{
+			Creator: &mockCertificateAuthorityCreator{
+				createCertificateAuthority: func(req *casapi.CreateCertificateAuthorityRequest) (*casapi.CreateCertificateAuthorityResponse, error) {
+					return &casapi.CreateCertificateAuthorityResponse{
+						Certificate: newIntermediate,
+					}, nil
+				},
+			},
+		}, true},
+		{"fail root key", &RotateIntermediateRequest{
+			RootKey:      "testdata/secrets/intermediate_ca_key",
+			RootPassword: []byte("pass"),
+		}, true},
+		{"fail missing root key and creator", &RotateIntermediateRequest{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := testRotationAuthority(t, root, rootSigner)
+			got, err := a.RotateIntermediate(context.Background(), tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Authority.RotateIntermediate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			assert.Equals(t, newIntermediate, got.Certificate)
+			assert.Len(t, 0, got.CertificateChain)
+			chain := testRotationSign(t, a)
+			assert.Equals(t, []*x509.Certificate{newIntermediate}, chain[1:])
+		})
+	}
+}
+
+func TestAuthority_RotateIntermediate_notSupported(t *testing.T) {
+	root, rootSigner := generateRootCertificate(t)
+	a, rootKey := testRotationAuthority(t, root, rootSigner)
+	a.x509Issuer = nil
+	_, err := a.RotateIntermediate(context.Background(), &RotateIntermediateRequest{
+		RootKey:      rootKey,
+		RootPassword: []byte("pass"),
+	})
+	assert.Error(t, err)
+
+	intermediates, err := a.GetIntermediates()
+	assert.FatalError(t, err)
+	assert.Len(t, 0, intermediates)
+}
+
+func TestAuthority_RotateIntermediate_persist(t *testing.T) {
+	root, rootSigner := generateRootCertificate(t)
+	newIntermediate, newSigner := generateIntermidiateCertificate(t, root, rootSigner)
+	kmsCreator := &mockCertificateAuthorityCreator{
+		createCertificateAuthority: func(req *casapi.CreateCertificateAuthorityRequest) (*casapi.CreateCertificateAuthorityResponse, error) {
+			return &casapi.CreateCertificateAuthorityResponse{
+				Certificate:      newIntermediate,
+				CertificateChain: []*x509.Certificate{root},
+				KeyName:          "pkcs11:id=7331;object=intermediate-key",
+				Signer:           newSigner,
+			}, nil
+		},
+	}
+
+	// writeIntermediate writes the current intermediate to the configured
+	// paths.
+	writeIntermediate := func(t *testing.T, a *Authority, dir string) {
+		t.Helper()
+		a.config.IntermediateCert = filepath.Join(dir, "intermediate_ca.crt")
+		assert.FatalError(t, os.WriteFile(a.config.IntermediateCert, pem.EncodeToMemory(&pem.Block{
+			Type: "CERTIFICATE", Bytes: a.x509Issuer.chain[0].Raw,
+		}), 0600))
+		if a.config.IntermediateKey == "" {
+			a.config.IntermediateKey = filepath.Join(dir, "intermediate_ca_key")
+			block, err := pemutil.Serialize(a.x509Issuer.signer, pemutil.WithPassword(a.password))
+			assert.FatalError(t, err)
+			assert.FatalError(t, os.WriteFile(a.config.IntermediateKey, pem.EncodeToMemory(block), 0600))
+		}
+	}
+
+	// reload simulates a restart of the CA with the same configuration.
+	reload := func(t *testing.T, a *Authority) *Authority {
+		t.Helper()
+		b, _ := testRotationAuthority(t, root, rootSigner)
+		b.config.IntermediateCert = a.config.IntermediateCert
+		b.config.IntermediateKey = a.config.IntermediateKey
+		b.password = a.password
+		var err error
+		b.x509Issuer, err = b.loadX509Issuer(b.config.IntermediateCert, b.config.IntermediateKey)
+		assert.FatalError(t, err)
+		assert.FatalError(t, b.loadIntermediateRotation())
+		t.Cleanup(b.stopIntermediateRotation)
+		return b
+	}
+
+	readKey := func(t *testing.T, filename string) crypto.PublicKey {
+		t.Helper()
+		key, err := pemutil.Read(filename, pemutil.WithPassword([]byte("pass")))
+		assert.FatalError(t, err)
+		return key.(crypto.Signer).Public()
+	}
+
+	t.Run("ok/file", func(t *testing.T) {
+		a, rootKey := testRotationAuthority(t, root, rootSigner)
+		t.Cleanup(a.stopIntermediateRotation)
+		a.password = []byte("pass")
+		writeIntermediate(t, a, t.TempDir())
+		oldIntermediate := a.x509Issuer.chain[0]
+		oldKey := a.x509Issuer.signer.Public()
+		crtFile, keyFile := a.config.IntermediateCert, a.config.IntermediateKey
+
+		resp, err := a.RotateIntermediate(context.Background(), &RotateIntermediateRequest{
+			RootKey:      rootKey,
+			RootPassword: []byte("pass"),
+			GracePeriod:  time.Hour,
+		})
+		assert.FatalError(t, err)
+
+		// The configured files are not modified before the cutover.
+		certs, err := pemutil.ReadCertificateBundle(crtFile)
+		assert.FatalError(t, err)
+		assert.Equals(t, []*x509.Certificate{oldIntermediate}, certs)
+		assert.True(t, publicKeyEqual(oldKey, readKey(t, keyFile)))
+		certs, err = pemutil.ReadCertificateBundle(crtFile + ".next")
+		assert.FatalError(t, err)
+		assert.Equals(t, []*x509.Certificate{resp.Certificate}, certs)
+		assert.True(t, publicKeyEqual(resp.Certificate.PublicKey, readKey(t, keyFile+".next")))
+		st, err := os.Stat(keyFile + ".next")
+		assert.FatalError(t, err)
+		assert.Equals(t, os.FileMode(0600), st.Mode().Perm())
+
+		// A restart during the grace period keeps the rotation pending.
+		b := reload(t, a)
+		assert.Equals(t, oldIntermediate, b.x509Issuer.chain[0])
+		intermediates, err := b.GetIntermediates()
+		assert.FatalError(t, err)
+		assert.Equals(t, []*x509.Certificate{oldIntermediate, resp.Certificate}, intermediates)
+		chain := testRotationSign(t, b)
+		assert.Equals(t, oldIntermediate, chain[1])
+
+		// A restart after the grace period completes the cutover and keeps the
+		// previous intermediate.
+		rotation, err := a.readIntermediateRotation()
+		assert.FatalError(t, err)
+		rotation.CutoverAt = time.Now().Add(-time.Minute)
+		assert.FatalError(t, a.writeIntermediateRotation(rotation))
+		c := reload(t, a)
+		assert.Nil(t, c.nextX509Issuer)
+		chain = testRotationSign(t, c)
+		assert.Equals(t, resp.Certificate, chain[1])
+		intermediates, err = c.GetIntermediates()
+		assert.FatalError(t, err)
+		assert.Equals(t, []*x509.Certificate{resp.Certificate, oldIntermediate}, intermediates)
+		assert.True(t, publicKeyEqual(oldKey, c.prevX509Issuer.signer.Public()))
+
+		certs, err = pemutil.ReadCertificateBundle(crtFile)
+		assert.FatalError(t, err)
+		assert.Equals(t, []*x509.Certificate{resp.Certificate}, certs)
+		assert.True(t, publicKeyEqual(resp.Certificate.PublicKey, readKey(t, keyFile)))
+		certs, err = pemutil.ReadCertificateBundle(crtFile + ".prev")
+		assert.FatalError(t, err)
+		assert.Equals(t, []*x509.Certificate{oldIntermediate}, certs)
+		assert.True(t, publicKeyEqual(oldKey, readKey(t, keyFile+".prev")))
+		_, err = os.Stat(crtFile + ".next")
+		assert.True(t, os.IsNotExist(err))
+		_, err = os.Stat(keyFile + ".next")
+		assert.True(t, os.IsNotExist(err))
+
+		// A second restart keeps the same state.
+		d := reload(t, a)
+		assert.Equals(t, resp.Certificate, d.x509Issuer.chain[0])
+		assert.Equals(t, oldIntermediate, d.prevX509Issuer.chain[0])
+	})
+
+	t.Run("ok/kms", func(t *testing.T) {
+		a, _ := testRotationAuthority(t, root, rootSigner)
+		t.Cleanup(a.stopIntermediateRotation)
+		dir := t.TempDir()
+		a.configFile = filepath.Join(dir, "ca.json")
+		caJSON := []byte(`{"crt":"intermediate_ca.crt","key":"pkcs11:object=old-key","address":":443"}`)
+		assert.FatalError(t, os.WriteFile(a.configFile, caJSON, 0600))
+		a.config.IntermediateKey = "pkcs11:object=old-key"
+		writeIntermediate(t, a, dir)
+		oldIntermediate := a.x509Issuer.chain[0]
+
+		_, err := a.RotateIntermediate(context.Background(), &RotateIntermediateRequest{
+			Creator:     kmsCreator,
+			GracePeriod: time.Hour,
+		})
+		assert.FatalError(t, err)
+
+		// The configuration is not modified before the cutover.
+		b, err := os.ReadFile(a.configFile)
+		assert.FatalError(t, err)
+		assert.Equals(t, caJSON, b)
+		assert.Equals(t, "pkcs11:object=old-key", a.config.IntermediateKey)
+		rotation, err := a.readIntermediateRotation()
+		assert.FatalError(t, err)
+		assert.Equals(t, "pkcs11:id=7331;object=intermediate-key", rotation.NextKey)
+
+		assert.FatalError(t, a.cutoverIntermediate())
+		certs, err := pemutil.ReadCertificateBundle(a.config.IntermediateCert)
+		assert.FatalError(t, err)
+		assert.Equals(t, []*x509.Certificate{newIntermediate}, certs)
+		certs, err = pemutil.ReadCertificateBundle(a.config.IntermediateCert + ".prev")
+		assert.FatalError(t, err)
+		assert.Equals(t, []*x509.Certificate{oldIntermediate}, certs)
+		assert.Equals(t, "pkcs11:id=7331;object=intermediate-key", a.config.IntermediateKey)
+		rotation, err = a.readIntermediateRotation()
+		assert.FatalError(t, err)
+		assert.Equals(t, &intermediateRotation{PrevKey: "pkcs11:object=old-key"}, rotation)
+
+		// Only the key is modified, the order of the properties is kept.
+		b, err = os.ReadFile(a.configFile)
+		assert.FatalError(t, err)
+		assert.Equals(t, `{"crt":"intermediate_ca.crt","key":"pkcs11:id=7331;object=intermediate-key","address":":443"}`, string(b))
+	})
+
+	t.Run("fail/cutover", func(t *testing.T) {
+		a, rootKey := testRotationAuthority(t, root, rootSigner)
+		t.Cleanup(a.stopIntermediateRotation)
+		a.password = []byte("pass")
+		writeIntermediate(t, a, t.TempDir())
+		oldIntermediate := a.x509Issuer.chain[0]
+
+		_, err := a.RotateIntermediate(context.Background(), &RotateIntermediateRequest{
+			RootKey:      rootKey,
+			RootPassword: []byte("pass"),
+			GracePeriod:  time.Hour,
+		})
+		assert.FatalError(t, err)
+		assert.FatalError(t, os.Remove(a.config.IntermediateCert+".next"))
+
+		// The current intermediate is kept and the cutover is retried later.
+		assert.Error(t, a.cutoverIntermediate())
+		chain := testRotationSign(t, a)
+		assert.Equals(t, oldIntermediate, chain[1])
+		assert.NotNil(t, a.nextX509Issuer)
+		assert.NotNil(t, a.rotationTimer)
+	})
+
+	t.Run("fail/kms-without-config-file", func(t *testing.T) {
+		a, _ := testRotationAuthority(t, root, rootSigner)
+		oldIntermediate := a.x509Issuer.chain[0]
+		a.config.IntermediateCert = filepath.Join(t.TempDir(), "intermediate_ca.crt")
+		a.config.IntermediateKey = "pkcs11:object=old-key"
+
+		_, err := a.RotateIntermediate(context.Background(), &RotateIntermediateRequest{
+			Creator: kmsCreator,
+		})
+		assert.Error(t, err)
+
+		// The rotation is aborted.
+		_, err = os.Stat(a.config.IntermediateCert + ".next")
+		assert.True(t, os.IsNotExist(err))
+		_, err = os.Stat(a.config.IntermediateCert + ".rotation")
+		assert.True(t, os.IsNotExist(err))
+		intermediates, err := a.GetIntermediates()
+		assert.FatalError(t, err)
+		assert.Len(t, 0, intermediates)
+		chain := testRotationSign(t, a)
+		assert.Equals(t, oldIntermediate, chain[1])
+	})
+}
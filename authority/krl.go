@@ -0,0 +1,156 @@
+package authority
+
+import (
+	"context"
+	"encoding/binary"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/smallstep/certificates/db"
+	"github.com/smallstep/certificates/errs"
+)
+
+// OpenSSH key revocation list constants, see
+// https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.krl
+const (
+	krlMagic                 uint64 = 0x5353484b524c0a00
+	krlFormatVersion         uint32 = 1
+	krlSectionCertificates   byte   = 1
+	krlSectionCertSerialList byte   = 0x20
+	krlSectionCertKeyID      byte   = 0x23
+)
+
+// GetSSHRevocationList returns an OpenSSH key revocation list (KRL) with the
+// revoked SSH certificates that have not expired yet. Hosts can use it in the
+// RevokedKeys directive of sshd_config.
+func (a *Authority) GetSSHRevocationList(context.Context) ([]byte, error) {
+	if a.sshCAUserCertSignKey == nil && a.sshCAHostCertSignKey == nil {
+		return nil, errs.NotFound("authority.GetSSHRevocationList; ssh is not configured")
+	}
+
+	revokedCerts, err := a.db.GetRevokedSSHCertificates()
+	switch {
+	case errors.Is(err, db.ErrNotImplemented):
+		// Without a persistence layer SSH certificates cannot be revoked.
+		revokedCerts = nil
+	case err != nil:
+		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.GetSSHRevocationList")
+	}
+
+	var caKeys []ssh.PublicKey
+	if a.sshCAUserCertSignKey != nil {
+		caKeys = append(caKeys, a.sshCAUserCertSignKey.PublicKey())
+	}
+	if a.sshCAHostCertSignKey != nil {
+		caKeys = append(caKeys, a.sshCAHostCertSignKey.PublicKey())
+	}
+
+	now := time.Now().UTC()
+	var serials []uint64
+	var keyIDs []string
+	for _, rci := range revokedCerts {
+		// Expired certificates are not accepted by sshd.
+		if !rci.ExpiresAt.IsZero() && rci.ExpiresAt.Before(now) {
+			continue
+		}
+		if sn, err := strconv.ParseUint(rci.Serial, 10, 64); err == nil && sn != 0 {
+			serials = append(serials, sn)
+		}
+		if rci.KeyID != "" {
+			keyIDs = append(keyIDs, rci.KeyID)
+		}
+	}
+
+	return marshalKRL(uint64(now.Unix()), now, caKeys, serials, keyIDs), nil
+}
+
+// marshalKRL encodes an OpenSSH KRL revoking the given serial numbers and key
+// ids for each of the given CA keys. The serial numbers of the certificates
+// issued by the CA are random, so the same list is used with all of them.
+func marshalKRL(version uint64, generatedAt time.Time, caKeys []ssh.PublicKey, serials []uint64, keyIDs []string) []byte {
+	serials = uniqueSerials(serials)
+	keyIDs = uniqueKeyIDs(keyIDs)
+
+	var b []byte
+	b = appendKRLUint64(b, krlMagic)
+	b = appendKRLUint32(b, krlFormatVersion)
+	b = appendKRLUint64(b, version)
+	b = appendKRLUint64(b, uint64(generatedAt.Unix()))
+	b = appendKRLUint64(b, 0)                 // flags
+	b = appendKRLString(b, nil)               // reserved
+	b = appendKRLString(b, []byte("step-ca")) // comment
+
+	if len(serials) == 0 && len(keyIDs) == 0 {
+		return b
+	}
+
+	for _, key := range caKeys {
+		var section []byte
+		section = appendKRLString(section, key.Marshal())
+		section = appendKRLString(section, nil) // reserved
+		if len(serials) > 0 {
+			var list []byte
+			for _, sn := range serials {
+				list = appendKRLUint64(list, sn)
+			}
+			section = append(section, krlSectionCertSerialList)
+			section = appendKRLString(section, list)
+		}
+		if len(keyIDs) > 0 {
+			var list []byte
+			for _, id := range keyIDs {
+				list = appendKRLString(list, []byte(id))
+			}
+			section = append(section, krlSectionCertKeyID)
+			section = appendKRLString(section, list)
+		}
+		b = append(b, krlSectionCertificates)
+		b = appendKRLString(b, section)
+	}
+
+	return b
+}
+
+func appendKRLUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendKRLUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendKRLString(b, s []byte) []byte {
+	b = appendKRLUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+func uniqueSerials(serials []uint64) []uint64 {
+	sort.Slice(serials, func(i, j int) bool { return serials[i] < serials[j] })
+	var ret []uint64
+	for i, sn := range serials {
+		if i == 0 || sn != serials[i-1] {
+			ret = append(ret, sn)
+		}
+	}
+	return ret
+}
+
+func uniqueKeyIDs(keyIDs []string) []string {
+	sort.Strings(keyIDs)
+	var ret []string
+	for i, id := range keyIDs {
+		if i == 0 || id != keyIDs[i-1] {
+			ret = append(ret, id)
+		}
+	}
+	return ret
+}
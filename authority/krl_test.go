@@ -0,0 +1,130 @@
+package authority
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/db"
+)
+
+func Test_marshalKRL(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	caKey, err := ssh.NewPublicKey(key.Public())
+	assert.FatalError(t, err)
+	now := time.Unix(1600000000, 0)
+
+	header := []byte{
+		0x53, 0x53, 0x48, 0x4b, 0x52, 0x4c, 0x0a, 0x00, // magic
+		0x00, 0x00, 0x00, 0x01, // format version
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, // krl version
+		0x00, 0x00, 0x00, 0x00, 0x5f, 0x5e, 0x10, 0x00, // generated date
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // flags
+		0x00, 0x00, 0x00, 0x00, // reserved
+		0x00, 0x00, 0x00, 0x07, 's', 't', 'e', 'p', '-', 'c', 'a', // comment
+	}
+
+	var section []byte
+	section = appendKRLString(section, caKey.Marshal())
+	section = appendKRLString(section, nil)
+	section = append(section, 0x20)
+	section = appendKRLString(section, []byte{
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02,
+	})
+	section = append(section, 0x23)
+	section = appendKRLString(section, []byte{0x00, 0x00, 0x00, 0x03, 'f', 'o', 'o'})
+	certificates := appendKRLString([]byte{0x01}, section)
+
+	type args struct {
+		caKeys  []ssh.PublicKey
+		serials []uint64
+		keyIDs  []string
+	}
+	tests := []struct {
+		name string
+		args args
+		want []byte
+	}{
+		{"ok", args{[]ssh.PublicKey{caKey}, []uint64{2, 1, 2}, []string{"foo", "foo"}}, append(append([]byte{}, header...), certificates...)},
+		{"ok empty", args{[]ssh.PublicKey{caKey}, nil, nil}, header},
+		{"ok no keys", args{nil, []uint64{1}, []string{"foo"}}, header},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := marshalKRL(2, now, tt.args.caKeys, tt.args.serials, tt.args.keyIDs)
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("marshalKRL() = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthority_GetSSHRevocationList(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	signer, err := ssh.NewSignerFromSigner(key)
+	assert.FatalError(t, err)
+	now := time.Now().UTC()
+
+	revoked := []db.RevokedCertificateInfo{
+		{Serial: "1", RevokedAt: now},
+		{Serial: "2", KeyID: "foo", RevokedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{Serial: "3", KeyID: "bar", RevokedAt: now, ExpiresAt: now.Add(-time.Hour)},
+		{Serial: "not-a-number", RevokedAt: now},
+	}
+
+	type fields struct {
+		db         db.AuthDB
+		userSigner ssh.Signer
+		hostSigner ssh.Signer
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		want    []byte
+		wantErr bool
+	}{
+		{"ok", fields{&db.MockAuthDB{
+			MGetRevokedSSHCertificates: func() ([]db.RevokedCertificateInfo, error) {
+				return revoked, nil
+			},
+		}, signer, nil}, marshalKRL(0, now, []ssh.PublicKey{signer.PublicKey()}, []uint64{1, 2}, []string{"foo"}), false},
+		{"ok not implemented", fields{&db.MockAuthDB{
+			Err: db.ErrNotImplemented,
+		}, nil, signer}, marshalKRL(0, now, []ssh.PublicKey{signer.PublicKey()}, nil, nil), false},
+		{"fail db", fields{&db.MockAuthDB{
+			Err: errors.New("force"),
+		}, signer, signer}, nil, true},
+		{"fail disabled", fields{&db.MockAuthDB{}, nil, nil}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := testAuthority(t, WithDatabase(tt.fields.db))
+			a.sshCAUserCertSignKey = tt.fields.userSigner
+			a.sshCAHostCertSignKey = tt.fields.hostSigner
+
+			got, err := a.GetSSHRevocationList(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Authority.GetSSHRevocationList() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			// Skip the version and generation date.
+			if !bytes.Equal(got[:12], tt.want[:12]) || !bytes.Equal(got[28:], tt.want[28:]) {
+				t.Errorf("Authority.GetSSHRevocationList() = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
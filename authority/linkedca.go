@@ -15,8 +15,10 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/authority/admin"
 	"github.com/smallstep/certificates/authority/provisioner"
 	"github.com/smallstep/certificates/db"
+	"github.com/smallstep/certificates/policy"
 	"go.step.sm/crypto/jose"
 	"go.step.sm/crypto/keyutil"
 	"go.step.sm/crypto/tlsutil"
@@ -235,6 +237,44 @@ func (c *linkedCaClient) DeleteAdmin(ctx context.Context, id string) error {
 	return errors.Wrap(err, "error deleting admin")
 }
 
+// errLinkedCAPolicy is the error returned by the policy methods, the policies
+// of a linked CA are managed in the linked CA and cannot be changed locally.
+func errLinkedCAPolicy() error {
+	return admin.NewError(admin.ErrorNotImplementedType, "policies are not supported by linked authorities")
+}
+
+func (c *linkedCaClient) CreateAuthorityPolicy(ctx context.Context, pol *policy.Options) error {
+	return errLinkedCAPolicy()
+}
+
+func (c *linkedCaClient) GetAuthorityPolicy(ctx context.Context) (*policy.Options, error) {
+	return nil, errLinkedCAPolicy()
+}
+
+func (c *linkedCaClient) UpdateAuthorityPolicy(ctx context.Context, pol *policy.Options) error {
+	return errLinkedCAPolicy()
+}
+
+func (c *linkedCaClient) DeleteAuthorityPolicy(ctx context.Context) error {
+	return errLinkedCAPolicy()
+}
+
+func (c *linkedCaClient) CreateProvisionerPolicy(ctx context.Context, provisionerID string, pol *policy.Options) error {
+	return errLinkedCAPolicy()
+}
+
+func (c *linkedCaClient) GetProvisionerPolicy(ctx context.Context, provisionerID string) (*policy.Options, error) {
+	return nil, errLinkedCAPolicy()
+}
+
+func (c *linkedCaClient) UpdateProvisionerPolicy(ctx context.Context, provisionerID string, pol *policy.Options) error {
+	return errLinkedCAPolicy()
+}
+
+func (c *linkedCaClient) DeleteProvisionerPolicy(ctx context.Context, provisionerID string) error {
+	return errLinkedCAPolicy()
+}
+
 func (c *linkedCaClient) GetCertificateData(serial string) (*db.CertificateData, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
@@ -0,0 +1,55 @@
+package authority
+
+import (
+	"crypto"
+	"crypto/x509"
+	"io"
+	"time"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+	casapi "github.com/smallstep/certificates/cas/apiv1"
+	"github.com/smallstep/certificates/db"
+)
+
+// Meter is the interface used to gather metrics about the operations of the
+// authority. The provisioner can be nil if it cannot be determined.
+type Meter interface {
+	db.Meter
+	// X509Signed is called whenever an X.509 certificate is signed.
+	X509Signed(provisioner.Interface, error)
+	// X509Renewed is called whenever an X.509 certificate is renewed or
+	// rekeyed.
+	X509Renewed(provisioner.Interface, error)
+	// X509Revoked is called whenever an X.509 certificate is revoked.
+	X509Revoked(provisioner.Interface, error)
+	// KMSSigned is called after each signature made with a key in the KMS.
+	KMSSigned(d time.Duration, err error)
+}
+
+// instrumentedSigner is a crypto.Signer that reports the signatures to a
+// meter.
+type instrumentedSigner struct {
+	crypto.Signer
+	meter Meter
+}
+
+func newInstrumentedSigner(s crypto.Signer, m Meter) crypto.Signer {
+	return &instrumentedSigner{Signer: s, meter: m}
+}
+
+// Sign implements the crypto.Signer interface.
+func (s *instrumentedSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	start := time.Now()
+	sig, err := s.Signer.Sign(rand, digest, opts)
+	s.meter.KMSSigned(time.Since(start), err)
+	return sig, err
+}
+
+// SignatureAlgorithm implements the casapi.SignatureAlgorithmGetter interface,
+// returning the signature algorithm of the wrapped signer if it has one.
+func (s *instrumentedSigner) SignatureAlgorithm() x509.SignatureAlgorithm {
+	if sa, ok := s.Signer.(casapi.SignatureAlgorithmGetter); ok {
+		return sa.SignatureAlgorithm()
+	}
+	return x509.UnknownSignatureAlgorithm
+}
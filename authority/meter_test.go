@@ -0,0 +1,130 @@
+package authority
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"go.step.sm/crypto/jose"
+	"go.step.sm/crypto/keyutil"
+)
+
+type meterEvent struct {
+	op          string
+	provisioner string
+	failed      bool
+}
+
+type mockMeter struct {
+	mu        sync.Mutex
+	events    []meterEvent
+	kmsSigned int
+	kmsErrors int
+}
+
+func (m *mockMeter) record(op string, p provisioner.Interface, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var name string
+	if p != nil {
+		name = p.GetName()
+	}
+	m.events = append(m.events, meterEvent{op: op, provisioner: name, failed: err != nil})
+}
+
+func (m *mockMeter) X509Signed(p provisioner.Interface, err error)  { m.record("sign", p, err) }
+func (m *mockMeter) X509Renewed(p provisioner.Interface, err error) { m.record("renew", p, err) }
+func (m *mockMeter) X509Revoked(p provisioner.Interface, err error) { m.record("revoke", p, err) }
+func (m *mockMeter) DBOperation(op, table string, d time.Duration, err error) {
+}
+
+func (m *mockMeter) KMSSigned(d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.kmsSigned++
+	if err != nil {
+		m.kmsErrors++
+	}
+}
+
+func TestAuthority_Sign_meter(t *testing.T) {
+	_, priv, err := keyutil.GenerateDefaultKeyPair()
+	assert.FatalError(t, err)
+
+	key, err := jose.ReadKey("testdata/secrets/step_cli_key_priv.jwk", jose.WithPassword([]byte("pass")))
+	assert.FatalError(t, err)
+	token, err := generateToken("smallstep test", "step-cli", testAudiences.Sign[0], []string{"test.smallstep.com"}, time.Now(), key)
+	assert.FatalError(t, err)
+
+	m := new(mockMeter)
+	a := testAuthority(t, WithMeter(m))
+	ctx := provisioner.NewContextWithMethod(context.Background(), provisioner.SignMethod)
+	extraOpts, err := a.Authorize(ctx, token)
+	assert.FatalError(t, err)
+
+	nb := time.Now()
+	signOpts := provisioner.SignOptions{
+		NotBefore: provisioner.NewTimeDuration(nb),
+		NotAfter:  provisioner.NewTimeDuration(nb.Add(5 * time.Minute)),
+	}
+
+	// Successful signature.
+	certs, err := a.Sign(getCSR(t, priv), signOpts, extraOpts...)
+	assert.FatalError(t, err)
+	assert.Len(t, 2, certs)
+
+	// Invalid certificate request.
+	csr := getCSR(t, priv)
+	csr.Signature = []byte("foo")
+	_, err = a.Sign(csr, signOpts, extraOpts...)
+	assert.Error(t, err)
+
+	// Renewals are reported with the provisioner in the certificate.
+	_, err = a.Renew(certs[0])
+	assert.FatalError(t, err)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	assert.Equals(t, []meterEvent{
+		{op: "sign", provisioner: "step-cli"},
+		{op: "sign", provisioner: "step-cli", failed: true},
+		{op: "renew", provisioner: "step-cli"},
+	}, m.events)
+	assert.Equals(t, 2, m.kmsSigned)
+	assert.Equals(t, 0, m.kmsErrors)
+}
+
+type mockSigner struct {
+	crypto.Signer
+	err error
+}
+
+func (s *mockSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return []byte("signature"), nil
+}
+
+func TestInstrumentedSigner(t *testing.T) {
+	m := new(mockMeter)
+
+	s := newInstrumentedSigner(&mockSigner{}, m)
+	sig, err := s.Sign(rand.Reader, []byte("digest"), crypto.SHA256)
+	assert.FatalError(t, err)
+	assert.Equals(t, []byte("signature"), sig)
+
+	s = newInstrumentedSigner(&mockSigner{err: errors.New("force")}, m)
+	_, err = s.Sign(rand.Reader, []byte("digest"), crypto.SHA256)
+	assert.Equals(t, errors.New("force"), err)
+
+	assert.Equals(t, 2, m.kmsSigned)
+	assert.Equals(t, 1, m.kmsErrors)
+}
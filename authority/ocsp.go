@@ -0,0 +1,233 @@
+package authority
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/db"
+	"github.com/smallstep/certificates/errs"
+	kmsapi "github.com/smallstep/certificates/kms/apiv1"
+	"github.com/smallstep/nosql/database"
+	"go.step.sm/crypto/pemutil"
+	"golang.org/x/crypto/ocsp"
+)
+
+// ocspResponder contains the certificates and the signer used to create OCSP
+// responses. If a delegated OCSP responder is not configured, the responder
+// certificate and the issuer will be the same certificate.
+type ocspResponder struct {
+	issuer    *x509.Certificate
+	responder *x509.Certificate
+	signer    crypto.Signer
+}
+
+// isIssuer returns true if the issuer name and key hashes in the given request
+// match the ones of the issuer certificate.
+func (r *ocspResponder) isIssuer(req *ocsp.Request) bool {
+	if !req.HashAlgorithm.Available() {
+		return false
+	}
+
+	var publicKeyInfo struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(r.issuer.RawSubjectPublicKeyInfo, &publicKeyInfo); err != nil {
+		return false
+	}
+
+	h := req.HashAlgorithm.New()
+	h.Write(r.issuer.RawSubject)
+	nameHash := h.Sum(nil)
+
+	h.Reset()
+	h.Write(publicKeyInfo.PublicKey.RightAlign())
+	keyHash := h.Sum(nil)
+
+	return bytes.Equal(nameHash, req.IssuerNameHash) && bytes.Equal(keyHash, req.IssuerKeyHash)
+}
+
+// initOCSPResponder loads the certificates and creates the signer used by the
+// OCSP responder. By default the intermediate certificate and key are used, if
+// a delegated responder is configured, its certificate must be issued by the
+// intermediate and it must have the OCSP signing extended key usage.
+//
+// With the default CAS the intermediate can be rotated, so the responses are
+// signed by the issuer of each certificate, see getOCSPResponder.
+func (a *Authority) initOCSPResponder() error {
+	var (
+		err        error
+		chain      []*x509.Certificate
+		signingKey string
+	)
+
+	ocspConfig := a.config.OCSP
+	if !ocspConfig.IsDelegated() && a.x509Issuer != nil {
+		a.ocspResponder = nil
+		return nil
+	}
+	if ocspConfig.IsDelegated() {
+		if chain, err = pemutil.ReadCertificateBundle(ocspConfig.Certificate); err != nil {
+			return err
+		}
+		signingKey = ocspConfig.Key
+	} else {
+		if a.config.IntermediateCert == "" || a.config.IntermediateKey == "" {
+			return errors.New("ocsp.crt and ocsp.key are required if the intermediate is not available")
+		}
+		if chain, err = pemutil.ReadCertificateBundle(a.config.IntermediateCert); err != nil {
+			return err
+		}
+		signingKey = a.config.IntermediateKey
+	}
+
+	r := &ocspResponder{
+		issuer:    chain[0],
+		responder: chain[0],
+	}
+
+	if ocspConfig.IsDelegated() {
+		// The issuer can be part of the delegated responder bundle, if not the
+		// configured intermediate will be used.
+		switch {
+		case len(chain) > 1:
+			r.issuer = chain[1]
+		case a.config.IntermediateCert != "":
+			if r.issuer, err = pemutil.ReadCertificate(a.config.IntermediateCert); err != nil {
+				return err
+			}
+		default:
+			return errors.New("ocsp.crt must include the issuer if the intermediate is not available")
+		}
+
+		if err := r.responder.CheckSignatureFrom(r.issuer); err != nil {
+			return errors.Wrap(err, "ocsp.crt is not signed by the issuer")
+		}
+		var hasOCSPSigning bool
+		for _, eku := range r.responder.ExtKeyUsage {
+			if eku == x509.ExtKeyUsageOCSPSigning {
+				hasOCSPSigning = true
+				break
+			}
+		}
+		if !hasOCSPSigning {
+			return errors.New("ocsp.crt does not have the OCSP signing extended key usage")
+		}
+	}
+
+	if r.signer, err = a.keyManager.CreateSigner(&kmsapi.CreateSignerRequest{
+		SigningKey: signingKey,
+		Password:   []byte(a.password),
+	}); err != nil {
+		return err
+	}
+
+	a.ocspResponder = r
+	return nil
+}
+
+// getOCSPResponder returns the responder for the issuer in the given request.
+// The delegated responder is used for the certificates of its issuer, the
+// current and the previous intermediates of the default CAS for the ones they
+// issued. It returns nil if the certificate was not issued by this authority.
+func (a *Authority) getOCSPResponder(req *ocsp.Request) *ocspResponder {
+	if r := a.ocspResponder; r != nil && r.responder != r.issuer && r.isIssuer(req) {
+		return r
+	}
+
+	a.issuerMutex.RLock()
+	issuers := []*x509Issuer{a.x509Issuer, a.prevX509Issuer}
+	a.issuerMutex.RUnlock()
+	for _, iss := range issuers {
+		if iss == nil {
+			continue
+		}
+		r := &ocspResponder{
+			issuer:    iss.chain[0],
+			responder: iss.chain[0],
+			signer:    iss.signer,
+		}
+		if r.isIssuer(req) {
+			return r
+		}
+	}
+
+	if r := a.ocspResponder; r != nil && r.isIssuer(req) {
+		return r
+	}
+	return nil
+}
+
+// GetOCSPResponse parses the given DER encoded OCSP request and returns a
+// signed DER encoded OCSP response with the status of the requested
+// certificate. Malformed requests, and requests for certificates not issued by
+// this authority, will return the corresponding unsigned error response as
+// defined in RFC 6960.
+func (a *Authority) GetOCSPResponse(der []byte) ([]byte, error) {
+	if !a.config.OCSP.IsEnabled() {
+		return nil, errs.NotFound("authority.GetOCSPResponse; ocsp responder is not enabled")
+	}
+
+	req, err := ocsp.ParseRequest(der)
+	if err != nil {
+		return ocsp.MalformedRequestErrorResponse, nil
+	}
+	responder := a.getOCSPResponder(req)
+	if responder == nil {
+		return ocsp.UnauthorizedErrorResponse, nil
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	template := ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: req.SerialNumber,
+		ThisUpdate:   now,
+		NextUpdate:   now.Add(a.config.OCSP.ResponseDuration.Duration),
+		IssuerHash:   req.HashAlgorithm,
+	}
+	// Delegated responders must include their certificate in the response.
+	if responder.responder != responder.issuer {
+		template.Certificate = responder.responder
+	}
+
+	sn := req.SerialNumber.String()
+	isRevoked, err := a.IsRevoked(sn)
+	if err != nil {
+		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.GetOCSPResponse")
+	}
+
+	if isRevoked {
+		template.Status = ocsp.Revoked
+		rci, err := a.db.GetRevokedCertificate(sn)
+		switch {
+		case err == nil:
+			template.RevokedAt = rci.RevokedAt
+			template.RevocationReason = rci.ReasonCode
+		case err == db.ErrNotImplemented || database.IsErrNotFound(err):
+			// The revocation details are not available, a linked CA for
+			// example, so we can only report when we learned about it.
+			template.RevokedAt = now
+			template.RevocationReason = ocsp.Unspecified
+		default:
+			return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.GetOCSPResponse")
+		}
+	} else if _, err := a.db.GetCertificate(sn); err != nil {
+		// Certificates that are not in the database are reported as unknown.
+		if err != db.ErrNotImplemented && !database.IsErrNotFound(err) {
+			return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.GetOCSPResponse")
+		}
+		template.Status = ocsp.Unknown
+	}
+
+	resp, err := ocsp.CreateResponse(responder.issuer, responder.responder, template, responder.signer)
+	if err != nil {
+		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.GetOCSPResponse; error creating ocsp response")
+	}
+	return resp, nil
+}
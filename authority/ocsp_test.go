@@ -0,0 +1,425 @@
+package authority
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.step.sm/crypto/pemutil"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/db"
+	"github.com/smallstep/nosql/database"
+)
+
+// mustOCSPResponder creates a certificate for a delegated OCSP responder signed
+// by the test intermediate.
+func mustOCSPResponder(t *testing.T, extKeyUsage ...x509.ExtKeyUsage) (*x509.Certificate, crypto.Signer) {
+	t.Helper()
+	issuer, err := pemutil.ReadCertificate("testdata/certs/intermediate_ca.crt")
+	assert.FatalError(t, err)
+	issuerKey, err := pemutil.Read("testdata/secrets/intermediate_ca_key", pemutil.WithPassword([]byte("pass")))
+	assert.FatalError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "OCSP Responder"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  extKeyUsage,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, key.Public(), issuerKey)
+	assert.FatalError(t, err)
+	crt, err := x509.ParseCertificate(der)
+	assert.FatalError(t, err)
+	return crt, key
+}
+
+func TestAuthority_initOCSPResponder(t *testing.T) {
+	issuer, err := pemutil.ReadCertificate("testdata/certs/intermediate_ca.crt")
+	assert.FatalError(t, err)
+
+	writeResponder := func(t *testing.T, withIssuer bool, extKeyUsage ...x509.ExtKeyUsage) (string, string, *x509.Certificate) {
+		crt, key := mustOCSPResponder(t, extKeyUsage...)
+		dir := t.TempDir()
+		crtFile, keyFile := filepath.Join(dir, "ocsp.crt"), filepath.Join(dir, "ocsp.key")
+		b := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: crt.Raw})
+		if withIssuer {
+			b = append(b, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: issuer.Raw})...)
+		}
+		assert.FatalError(t, os.WriteFile(crtFile, b, 0600))
+		block, err := pemutil.Serialize(key)
+		assert.FatalError(t, err)
+		assert.FatalError(t, os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600))
+		return crtFile, keyFile, crt
+	}
+
+	type test struct {
+		ocsp          *config.OCSPConfig
+		intermediate  string
+		defaultCAS    bool
+		wantResponder *x509.Certificate
+		wantErr       bool
+	}
+	tests := map[string]func(t *testing.T) test{
+		"ok/default-cas": func(t *testing.T) test {
+			// The responses are signed by the issuer of the default CAS.
+			return test{
+				ocsp:         &config.OCSPConfig{Enabled: true},
+				intermediate: "testdata/certs/intermediate_ca.crt",
+				defaultCAS:   true,
+			}
+		},
+		"ok/intermediate": func(t *testing.T) test {
+			return test{
+				ocsp:          &config.OCSPConfig{Enabled: true},
+				intermediate:  "testdata/certs/intermediate_ca.crt",
+				wantResponder: issuer,
+			}
+		},
+		"ok/delegated": func(t *testing.T) test {
+			crtFile, keyFile, crt := writeResponder(t, false, x509.ExtKeyUsageOCSPSigning)
+			return test{
+				ocsp:          &config.OCSPConfig{Enabled: true, Certificate: crtFile, Key: keyFile},
+				intermediate:  "testdata/certs/intermediate_ca.crt",
+				wantResponder: crt,
+			}
+		},
+		"ok/delegated-bundle": func(t *testing.T) test {
+			crtFile, keyFile, crt := writeResponder(t, true, x509.ExtKeyUsageOCSPSigning)
+			return test{
+				ocsp:          &config.OCSPConfig{Enabled: true, Certificate: crtFile, Key: keyFile},
+				wantResponder: crt,
+			}
+		},
+		"fail/no-intermediate": func(t *testing.T) test {
+			return test{
+				ocsp:    &config.OCSPConfig{Enabled: true},
+				wantErr: true,
+			}
+		},
+		"fail/delegated-no-issuer": func(t *testing.T) test {
+			crtFile, keyFile, _ := writeResponder(t, false, x509.ExtKeyUsageOCSPSigning)
+			return test{
+				ocsp:    &config.OCSPConfig{Enabled: true, Certificate: crtFile, Key: keyFile},
+				wantErr: true,
+			}
+		},
+		"fail/delegated-no-ocsp-signing": func(t *testing.T) test {
+			crtFile, keyFile, _ := writeResponder(t, true, x509.ExtKeyUsageServerAuth)
+			return test{
+				ocsp:    &config.OCSPConfig{Enabled: true, Certificate: crtFile, Key: keyFile},
+				wantErr: true,
+			}
+		},
+	}
+	for name, f := range tests {
+		t.Run(name, func(t *testing.T) {
+			tc := f(t)
+			a := testAuthority(t)
+			a.config.OCSP = tc.ocsp
+			a.config.IntermediateCert = tc.intermediate
+			if tc.intermediate == "" {
+				a.config.IntermediateKey = ""
+			}
+			if !tc.defaultCAS {
+				a.x509Issuer = nil
+			}
+
+			err := a.initOCSPResponder()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Authority.initOCSPResponder() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if tc.wantResponder == nil {
+				assert.Nil(t, a.ocspResponder)
+				return
+			}
+
+			assert.Equals(t, issuer.Raw, a.ocspResponder.issuer.Raw)
+			assert.Equals(t, tc.wantResponder.Raw, a.ocspResponder.responder.Raw)
+			assert.NotNil(t, a.ocspResponder.signer)
+		})
+	}
+}
+
+func TestAuthority_GetOCSPResponse(t *testing.T) {
+	issuer, err := pemutil.ReadCertificate("testdata/certs/intermediate_ca.crt")
+	assert.FatalError(t, err)
+	root, err := pemutil.ReadCertificate("testdata/certs/root_ca.crt")
+	assert.FatalError(t, err)
+	delegated, delegatedKey := mustOCSPResponder(t, x509.ExtKeyUsageOCSPSigning)
+	rotated, rotatedKey := generateRootCertificate(t)
+
+	ocspConfig := &config.OCSPConfig{
+		Enabled:          true,
+		ResponseDuration: &provisioner.Duration{Duration: time.Hour},
+	}
+	leaf := &x509.Certificate{SerialNumber: big.NewInt(1234)}
+	revokedAt := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+
+	mustRequest := func(t *testing.T, issuer *x509.Certificate) []byte {
+		req, err := ocsp.CreateRequest(leaf, issuer, nil)
+		assert.FatalError(t, err)
+		return req
+	}
+
+	type test struct {
+		ocsp          *config.OCSPConfig
+		db            *db.MockAuthDB
+		delegated     bool
+		rotated       bool
+		issuer        *x509.Certificate
+		req           []byte
+		want          []byte
+		wantStatus    int
+		wantRevokedAt time.Time
+		wantReason    int
+		wantErr       bool
+	}
+	tests := map[string]func(t *testing.T) test{
+		"ok/good": func(t *testing.T) test {
+			return test{
+				ocsp: ocspConfig,
+				db: &db.MockAuthDB{
+					MIsRevoked: func(sn string) (bool, error) {
+						assert.Equals(t, "1234", sn)
+						return false, nil
+					},
+					MGetCertificate: func(sn string) (*x509.Certificate, error) {
+						assert.Equals(t, "1234", sn)
+						return leaf, nil
+					},
+				},
+				req:        mustRequest(t, issuer),
+				wantStatus: ocsp.Good,
+			}
+		},
+		"ok/good-delegated": func(t *testing.T) test {
+			return test{
+				ocsp: ocspConfig,
+				db: &db.MockAuthDB{
+					MIsRevoked: func(sn string) (bool, error) {
+						return false, nil
+					},
+					MGetCertificate: func(sn string) (*x509.Certificate, error) {
+						return leaf, nil
+					},
+				},
+				delegated:  true,
+				req:        mustRequest(t, issuer),
+				wantStatus: ocsp.Good,
+			}
+		},
+		"ok/good-rotated": func(t *testing.T) test {
+			return test{
+				ocsp: ocspConfig,
+				db: &db.MockAuthDB{
+					MIsRevoked: func(sn string) (bool, error) {
+						return false, nil
+					},
+					MGetCertificate: func(sn string) (*x509.Certificate, error) {
+						return leaf, nil
+					},
+				},
+				rotated:    true,
+				issuer:     rotated,
+				req:        mustRequest(t, rotated),
+				wantStatus: ocsp.Good,
+			}
+		},
+		"ok/good-previous-issuer": func(t *testing.T) test {
+			return test{
+				ocsp: ocspConfig,
+				db: &db.MockAuthDB{
+					MIsRevoked: func(sn string) (bool, error) {
+						return false, nil
+					},
+					MGetCertificate: func(sn string) (*x509.Certificate, error) {
+						return leaf, nil
+					},
+				},
+				rotated:    true,
+				req:        mustRequest(t, issuer),
+				wantStatus: ocsp.Good,
+			}
+		},
+		"ok/revoked": func(t *testing.T) test {
+			return test{
+				ocsp: ocspConfig,
+				db: &db.MockAuthDB{
+					MIsRevoked: func(sn string) (bool, error) {
+						return true, nil
+					},
+					MGetRevokedCertificate: func(sn string) (*db.RevokedCertificateInfo, error) {
+						assert.Equals(t, "1234", sn)
+						return &db.RevokedCertificateInfo{
+							Serial:     sn,
+							ReasonCode: ocsp.KeyCompromise,
+							RevokedAt:  revokedAt,
+						}, nil
+					},
+				},
+				req:           mustRequest(t, issuer),
+				wantStatus:    ocsp.Revoked,
+				wantRevokedAt: revokedAt,
+				wantReason:    ocsp.KeyCompromise,
+			}
+		},
+		"ok/unknown": func(t *testing.T) test {
+			return test{
+				ocsp: ocspConfig,
+				db: &db.MockAuthDB{
+					MIsRevoked: func(sn string) (bool, error) {
+						return false, nil
+					},
+					MGetCertificate: func(sn string) (*x509.Certificate, error) {
+						return nil, database.ErrNotFound
+					},
+				},
+				req:        mustRequest(t, issuer),
+				wantStatus: ocsp.Unknown,
+			}
+		},
+		"ok/malformed": func(t *testing.T) test {
+			return test{
+				ocsp: ocspConfig,
+				db:   &db.MockAuthDB{},
+				req:  []byte("not an ocsp request"),
+				want: ocsp.MalformedRequestErrorResponse,
+			}
+		},
+		"ok/unauthorized": func(t *testing.T) test {
+			return test{
+				ocsp: ocspConfig,
+				db:   &db.MockAuthDB{},
+				req:  mustRequest(t, root),
+				want: ocsp.UnauthorizedErrorResponse,
+			}
+		},
+		"fail/disabled": func(t *testing.T) test {
+			return test{
+				ocsp:    nil,
+				db:      &db.MockAuthDB{},
+				req:     mustRequest(t, issuer),
+				wantErr: true,
+			}
+		},
+		"fail/IsRevoked": func(t *testing.T) test {
+			return test{
+				ocsp: ocspConfig,
+				db: &db.MockAuthDB{
+					MIsRevoked: func(sn string) (bool, error) {
+						return false, errors.New("force")
+					},
+				},
+				req:     mustRequest(t, issuer),
+				wantErr: true,
+			}
+		},
+		"fail/GetRevokedCertificate": func(t *testing.T) test {
+			return test{
+				ocsp: ocspConfig,
+				db: &db.MockAuthDB{
+					MIsRevoked: func(sn string) (bool, error) {
+						return true, nil
+					},
+					MGetRevokedCertificate: func(sn string) (*db.RevokedCertificateInfo, error) {
+						return nil, errors.New("force")
+					},
+				},
+				req:     mustRequest(t, issuer),
+				wantErr: true,
+			}
+		},
+		"fail/GetCertificate": func(t *testing.T) test {
+			return test{
+				ocsp: ocspConfig,
+				db: &db.MockAuthDB{
+					MIsRevoked: func(sn string) (bool, error) {
+						return false, nil
+					},
+					MGetCertificate: func(sn string) (*x509.Certificate, error) {
+						return nil, errors.New("force")
+					},
+				},
+				req:     mustRequest(t, issuer),
+				wantErr: true,
+			}
+		},
+	}
+	for name, f := range tests {
+		t.Run(name, func(t *testing.T) {
+			tc := f(t)
+			a := testAuthority(t, WithDatabase(tc.db))
+			a.config.OCSP = tc.ocsp
+			if tc.ocsp.IsEnabled() {
+				assert.FatalError(t, a.initOCSPResponder())
+				if tc.delegated {
+					a.ocspResponder = &ocspResponder{
+						issuer:    issuer,
+						responder: delegated,
+						signer:    delegatedKey,
+					}
+				}
+			}
+			if tc.rotated {
+				a.prevX509Issuer = a.x509Issuer
+				a.x509Issuer = &x509Issuer{
+					chain:  []*x509.Certificate{rotated},
+					signer: rotatedKey,
+				}
+			}
+			if tc.issuer == nil {
+				tc.issuer = issuer
+			}
+
+			got, err := a.GetOCSPResponse(tc.req)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Authority.GetOCSPResponse() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if tc.want != nil {
+				assert.Equals(t, tc.want, got)
+				return
+			}
+
+			resp, err := ocsp.ParseResponseForCert(got, leaf, tc.issuer)
+			assert.FatalError(t, err)
+			assert.Equals(t, tc.wantStatus, resp.Status)
+			assert.Equals(t, leaf.SerialNumber, resp.SerialNumber)
+			assert.Equals(t, time.Hour, resp.NextUpdate.Sub(resp.ThisUpdate))
+			if tc.delegated {
+				if assert.NotNil(t, resp.Certificate) {
+					assert.Equals(t, delegated.Raw, resp.Certificate.Raw)
+				}
+			} else {
+				assert.Nil(t, resp.Certificate)
+			}
+			if tc.wantStatus == ocsp.Revoked {
+				assert.True(t, tc.wantRevokedAt.Equal(resp.RevokedAt))
+				assert.Equals(t, tc.wantReason, resp.RevocationReason)
+			}
+		})
+	}
+}
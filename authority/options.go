@@ -34,10 +34,20 @@ func WithConfig(cfg *config.Config) Option {
 func WithConfigFile(filename string) Option {
 	return func(a *Authority) (err error) {
 		a.config, err = config.LoadConfiguration(filename)
+		a.configFile = filename
 		return
 	}
 }
 
+// WithConfigFilename sets the name of the file the configuration was loaded
+// from. It's used to persist changes like the key of a rotated intermediate.
+func WithConfigFilename(filename string) Option {
+	return func(a *Authority) error {
+		a.configFile = filename
+		return nil
+	}
+}
+
 // WithPassword set the password to decrypt the intermediate key as well as the
 // ssh host and user keys if they are not overridden by other options.
 func WithPassword(password []byte) Option {
@@ -150,10 +160,13 @@ func WithKeyManager(k kms.KeyManager) Option {
 // WithX509Signer defines the signer used to sign X509 certificates.
 func WithX509Signer(crt *x509.Certificate, s crypto.Signer) Option {
 	return func(a *Authority) error {
+		a.x509Issuer = &x509Issuer{
+			chain:  []*x509.Certificate{crt},
+			signer: s,
+		}
 		srv, err := cas.New(context.Background(), casapi.Options{
-			Type:             casapi.SoftCAS,
-			Signer:           s,
-			CertificateChain: []*x509.Certificate{crt},
+			Type:              casapi.SoftCAS,
+			CertificateSigner: a.getX509Signer,
 		})
 		if err != nil {
 			return err
@@ -284,6 +297,15 @@ func WithX509Enforcers(ces ...provisioner.CertificateEnforcer) Option {
 	}
 }
 
+// WithMeter is an option that reports the metrics of the authority operations
+// to the given meter.
+func WithMeter(m Meter) Option {
+	return func(a *Authority) error {
+		a.meter = m
+		return nil
+	}
+}
+
 func readCertificateBundle(pemCerts []byte) ([]*x509.Certificate, error) {
 	var block *pem.Block
 	var certs []*x509.Certificate
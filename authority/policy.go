@@ -0,0 +1,308 @@
+package authority
+
+import (
+	"context"
+	"crypto/x509"
+
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/certificates/policy"
+	"golang.org/x/crypto/ssh"
+)
+
+type provisionerOptionsGetter interface {
+	GetOptions() *provisioner.Options
+}
+
+type provisionerPolicyGetter interface {
+	GetPolicy() *policy.Engine
+}
+
+// getProvisionerPolicyEngine returns the name policy engine of the given
+// provisioner, it returns nil if the provisioner does not have a policy. The
+// engine is created when the provisioner is initialized.
+func getProvisionerPolicyEngine(p provisioner.Interface) *policy.Engine {
+	if g, ok := p.(provisionerPolicyGetter); ok {
+		return g.GetPolicy()
+	}
+	return nil
+}
+
+// getProvisionerPolicy returns the name policy options of the given
+// provisioner.
+func getProvisionerPolicy(p provisioner.Interface) *policy.Options {
+	if o, ok := p.(provisionerOptionsGetter); ok {
+		return o.GetOptions().GetPolicyOptions()
+	}
+	return nil
+}
+
+// setProvisionerPolicy sets the name policy options of the given provisioner,
+// it returns false if the provisioner does not support policies.
+func setProvisionerPolicy(p provisioner.Interface, pol *policy.Options) bool {
+	if o, ok := p.(provisionerOptionsGetter); ok && o.GetOptions() != nil {
+		o.GetOptions().Policy = pol
+		return true
+	}
+	return false
+}
+
+// isPolicyNotFound returns true if the error returned by the admin database
+// indicates that there is no policy.
+func isPolicyNotFound(err error) bool {
+	if e, ok := err.(*admin.Error); ok {
+		return e.IsType(admin.ErrorNotFoundType) || e.IsType(admin.ErrorNotImplementedType)
+	}
+	return false
+}
+
+// isX509CertificateAllowed returns a forbidden error if the names in the given
+// certificate are not allowed by the authority or the provisioner policies.
+func (a *Authority) isX509CertificateAllowed(p provisioner.Interface, cert *x509.Certificate) error {
+	if err := a.policyEngine.IsX509CertificateAllowed(cert); err != nil {
+		return errs.ForbiddenErr(err, "%s", err.Error())
+	}
+	if err := getProvisionerPolicyEngine(p).IsX509CertificateAllowed(cert); err != nil {
+		return errs.ForbiddenErr(err, "%s", err.Error())
+	}
+	return nil
+}
+
+// isSSHCertificateAllowed returns a forbidden error if the principals in the
+// given certificate are not allowed by the authority or the provisioner
+// policies.
+func (a *Authority) isSSHCertificateAllowed(p provisioner.Interface, cert *ssh.Certificate) error {
+	if err := a.policyEngine.IsSSHCertificateAllowed(cert); err != nil {
+		return errs.ForbiddenErr(err, "%s", err.Error())
+	}
+	if err := getProvisionerPolicyEngine(p).IsSSHCertificateAllowed(cert); err != nil {
+		return errs.ForbiddenErr(err, "%s", err.Error())
+	}
+	return nil
+}
+
+// GetAuthorityPolicy returns the authority policy stored in the admin
+// database.
+func (a *Authority) GetAuthorityPolicy(ctx context.Context) (*policy.Options, error) {
+	a.adminMutex.RLock()
+	defer a.adminMutex.RUnlock()
+
+	pol, err := a.adminDB.GetAuthorityPolicy(ctx)
+	if err != nil {
+		return nil, admin.WrapErrorISE(err, "error getting authority policy")
+	}
+	return pol, nil
+}
+
+// CreateAuthorityPolicy stores the authority policy in the admin database and
+// applies it. It takes precedence over the policy in the configuration file.
+func (a *Authority) CreateAuthorityPolicy(ctx context.Context, pol *policy.Options) error {
+	a.adminMutex.Lock()
+	defer a.adminMutex.Unlock()
+
+	if err := pol.Validate(); err != nil {
+		return admin.WrapError(admin.ErrorBadRequestType, err, "invalid authority policy")
+	}
+	if err := a.adminDB.CreateAuthorityPolicy(ctx, pol); err != nil {
+		return admin.WrapErrorISE(err, "error creating authority policy")
+	}
+	if err := a.reloadAdminResources(ctx); err != nil {
+		return admin.WrapErrorISE(err, "error reloading admin resources when creating authority policy")
+	}
+	return nil
+}
+
+// UpdateAuthorityPolicy updates the authority policy in the admin database
+// and applies it.
+func (a *Authority) UpdateAuthorityPolicy(ctx context.Context, pol *policy.Options) error {
+	a.adminMutex.Lock()
+	defer a.adminMutex.Unlock()
+
+	if err := pol.Validate(); err != nil {
+		return admin.WrapError(admin.ErrorBadRequestType, err, "invalid authority policy")
+	}
+	if err := a.adminDB.UpdateAuthorityPolicy(ctx, pol); err != nil {
+		return admin.WrapErrorISE(err, "error updating authority policy")
+	}
+	if err := a.reloadAdminResources(ctx); err != nil {
+		return admin.WrapErrorISE(err, "error reloading admin resources when updating authority policy")
+	}
+	return nil
+}
+
+// RemoveAuthorityPolicy removes the authority policy from the admin database,
+// the policy in the configuration file, if any, is applied again.
+func (a *Authority) RemoveAuthorityPolicy(ctx context.Context) error {
+	a.adminMutex.Lock()
+	defer a.adminMutex.Unlock()
+
+	if err := a.adminDB.DeleteAuthorityPolicy(ctx); err != nil {
+		return admin.WrapErrorISE(err, "error deleting authority policy")
+	}
+	if err := a.reloadAdminResources(ctx); err != nil {
+		return admin.WrapErrorISE(err, "error reloading admin resources when deleting authority policy")
+	}
+	return nil
+}
+
+// loadPolicyProvisioner returns the provisioner with the given name, or an
+// error if it does not exist or does not support policies.
+func (a *Authority) loadPolicyProvisioner(provisionerName string) (provisioner.Interface, error) {
+	p, err := a.LoadProvisionerByName(provisionerName)
+	if err != nil {
+		return nil, admin.WrapError(admin.ErrorNotFoundType, err,
+			"provisioner %s not found", provisionerName)
+	}
+	if _, ok := p.(provisionerOptionsGetter); !ok {
+		return nil, admin.NewError(admin.ErrorBadRequestType,
+			"provisioner %s does not support policies", provisionerName)
+	}
+	return p, nil
+}
+
+// GetProvisionerPolicy returns the policy of a provisioner stored in the
+// admin database.
+func (a *Authority) GetProvisionerPolicy(ctx context.Context, provisionerName string) (*policy.Options, error) {
+	a.adminMutex.RLock()
+	defer a.adminMutex.RUnlock()
+
+	p, err := a.loadPolicyProvisioner(provisionerName)
+	if err != nil {
+		return nil, err
+	}
+	pol, err := a.adminDB.GetProvisionerPolicy(ctx, p.GetID())
+	if err != nil {
+		return nil, admin.WrapErrorISE(err, "error getting policy of provisioner %s", provisionerName)
+	}
+	return pol, nil
+}
+
+// CreateProvisionerPolicy stores the policy of a provisioner in the admin
+// database and applies it.
+func (a *Authority) CreateProvisionerPolicy(ctx context.Context, provisionerName string, pol *policy.Options) error {
+	a.adminMutex.Lock()
+	defer a.adminMutex.Unlock()
+
+	p, err := a.loadPolicyProvisioner(provisionerName)
+	if err != nil {
+		return err
+	}
+	if err := pol.Validate(); err != nil {
+		return admin.WrapError(admin.ErrorBadRequestType, err, "invalid policy for provisioner %s", provisionerName)
+	}
+	if err := a.adminDB.CreateProvisionerPolicy(ctx, p.GetID(), pol); err != nil {
+		return admin.WrapErrorISE(err, "error creating policy of provisioner %s", provisionerName)
+	}
+	if err := a.reloadAdminResources(ctx); err != nil {
+		return admin.WrapErrorISE(err, "error reloading admin resources when creating provisioner policy")
+	}
+	return nil
+}
+
+// UpdateProvisionerPolicy updates the policy of a provisioner in the admin
+// database and applies it.
+func (a *Authority) UpdateProvisionerPolicy(ctx context.Context, provisionerName string, pol *policy.Options) error {
+	a.adminMutex.Lock()
+	defer a.adminMutex.Unlock()
+
+	p, err := a.loadPolicyProvisioner(provisionerName)
+	if err != nil {
+		return err
+	}
+	if err := pol.Validate(); err != nil {
+		return admin.WrapError(admin.ErrorBadRequestType, err, "invalid policy for provisioner %s", provisionerName)
+	}
+	if err := a.adminDB.UpdateProvisionerPolicy(ctx, p.GetID(), pol); err != nil {
+		return admin.WrapErrorISE(err, "error updating policy of provisioner %s", provisionerName)
+	}
+	if err := a.reloadAdminResources(ctx); err != nil {
+		return admin.WrapErrorISE(err, "error reloading admin resources when updating provisioner policy")
+	}
+	return nil
+}
+
+// RemoveProvisionerPolicy removes the policy of a provisioner from the admin
+// database.
+func (a *Authority) RemoveProvisionerPolicy(ctx context.Context, provisionerName string) error {
+	a.adminMutex.Lock()
+	defer a.adminMutex.Unlock()
+
+	p, err := a.loadPolicyProvisioner(provisionerName)
+	if err != nil {
+		return err
+	}
+	if err := a.adminDB.DeleteProvisionerPolicy(ctx, p.GetID()); err != nil {
+		return admin.WrapErrorISE(err, "error deleting policy of provisioner %s", provisionerName)
+	}
+	if err := a.reloadAdminResources(ctx); err != nil {
+		return admin.WrapErrorISE(err, "error reloading admin resources when deleting provisioner policy")
+	}
+	return nil
+}
+
+// PolicyEvaluation is the result of the evaluation of a name against the
+// authority or the provisioner policy.
+type PolicyEvaluation struct {
+	// Policy is either "authority" or "provisioner".
+	Policy string `json:"policy"`
+	policy.Evaluation
+}
+
+// getPolicyEngines returns the policy engines of the authority and the given
+// provisioner. The provisioner engine is nil if the provisioner name is
+// empty.
+func (a *Authority) getPolicyEngines(provisionerName string) (*policy.Engine, *policy.Engine, error) {
+	if provisionerName == "" {
+		return a.policyEngine, nil, nil
+	}
+	p, err := a.LoadProvisionerByName(provisionerName)
+	if err != nil {
+		return nil, nil, admin.WrapError(admin.ErrorNotFoundType, err,
+			"provisioner %s not found", provisionerName)
+	}
+	return a.policyEngine, getProvisionerPolicyEngine(p), nil
+}
+
+func appendPolicyEvaluations(evals []PolicyEvaluation, typ string, res []policy.Evaluation) []PolicyEvaluation {
+	for _, ev := range res {
+		evals = append(evals, PolicyEvaluation{Policy: typ, Evaluation: ev})
+	}
+	return evals
+}
+
+// EvaluateX509Policy evaluates the names in the given certificate against the
+// current authority policy and, if a provisioner name is given, the
+// provisioner policy. Certificates are not signed.
+func (a *Authority) EvaluateX509Policy(provisionerName string, cert *x509.Certificate) ([]PolicyEvaluation, error) {
+	a.adminMutex.RLock()
+	defer a.adminMutex.RUnlock()
+
+	authorityEngine, provisionerEngine, err := a.getPolicyEngines(provisionerName)
+	if err != nil {
+		return nil, err
+	}
+	evals := appendPolicyEvaluations(nil, "authority", authorityEngine.EvaluateX509Certificate(cert))
+	if provisionerName != "" {
+		evals = appendPolicyEvaluations(evals, "provisioner", provisionerEngine.EvaluateX509Certificate(cert))
+	}
+	return evals, nil
+}
+
+// EvaluateSSHPolicy evaluates the principals in the given certificate against
+// the current authority policy and, if a provisioner name is given, the
+// provisioner policy. Certificates are not signed.
+func (a *Authority) EvaluateSSHPolicy(provisionerName string, cert *ssh.Certificate) ([]PolicyEvaluation, error) {
+	a.adminMutex.RLock()
+	defer a.adminMutex.RUnlock()
+
+	authorityEngine, provisionerEngine, err := a.getPolicyEngines(provisionerName)
+	if err != nil {
+		return nil, err
+	}
+	evals := appendPolicyEvaluations(nil, "authority", authorityEngine.EvaluateSSHCertificate(cert))
+	if provisionerName != "" {
+		evals = appendPolicyEvaluations(evals, "provisioner", provisionerEngine.EvaluateSSHCertificate(cert))
+	}
+	return evals, nil
+}
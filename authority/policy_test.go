@@ -0,0 +1,194 @@
+package authority
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority/config"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/policy"
+	"go.step.sm/crypto/jose"
+	"go.step.sm/crypto/sshutil"
+	"golang.org/x/crypto/ssh"
+)
+
+// newPolicyProvisioner returns an initialized JWK provisioner with the given
+// policy.
+func newPolicyProvisioner(t *testing.T, opts *policy.Options) *provisioner.JWK {
+	t.Helper()
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	assert.FatalError(t, err)
+	pub := jwk.Public()
+	p := &provisioner.JWK{
+		Type:    "JWK",
+		Name:    "policy",
+		Key:     &pub,
+		Options: &provisioner.Options{Policy: opts},
+	}
+	assert.FatalError(t, p.Init(provisioner.Config{Claims: config.GlobalProvisionerClaims}))
+	return p
+}
+
+func TestAuthority_Sign_policy(t *testing.T) {
+	root, rootSigner := generateRootCertificate(t)
+	a, _ := testRotationAuthority(t, root, rootSigner)
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	cr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		DNSNames: []string{"foo.teamx.internal"},
+	}, priv)
+	assert.FatalError(t, err)
+	csr, err := x509.ParseCertificateRequest(cr)
+	assert.FatalError(t, err)
+
+	newProvisioner := func(opts *policy.Options) *provisioner.JWK {
+		return newPolicyProvisioner(t, opts)
+	}
+	validity := provisioner.CertificateModifierFunc(func(crt *x509.Certificate, _ provisioner.SignOptions) error {
+		crt.NotBefore = time.Now()
+		crt.NotAfter = crt.NotBefore.Add(time.Hour)
+		return nil
+	})
+	// Simulates a template adding a name to the certificate.
+	addName := func(name string) provisioner.CertificateModifierFunc {
+		return func(crt *x509.Certificate, _ provisioner.SignOptions) error {
+			crt.DNSNames = append(crt.DNSNames, name)
+			return nil
+		}
+	}
+	// Simulates a template adding a raw subject alternative name extension.
+	addSANExtension := func(name string) provisioner.CertificateModifierFunc {
+		return func(crt *x509.Certificate, _ provisioner.SignOptions) error {
+			b, err := asn1.Marshal([]asn1.RawValue{
+				{Class: asn1.ClassContextSpecific, Tag: 2, Bytes: []byte("foo.teamx.internal")},
+				{Class: asn1.ClassContextSpecific, Tag: 2, Bytes: []byte(name)},
+			})
+			if err != nil {
+				return err
+			}
+			crt.DNSNames = append(crt.DNSNames, name)
+			crt.ExtraExtensions = append(crt.ExtraExtensions, pkix.Extension{
+				Id: asn1.ObjectIdentifier{2, 5, 29, 17}, Value: b,
+			})
+			return nil
+		}
+	}
+	allowTeamX := &policy.Options{X509: &policy.X509Options{
+		Allow: &policy.X509NameOptions{DNSDomains: []string{"*.teamx.internal"}},
+	}}
+	denyFoo := &policy.Options{X509: &policy.X509Options{
+		Deny: &policy.X509NameOptions{DNSDomains: []string{"foo.teamx.internal"}},
+	}}
+
+	tests := []struct {
+		name      string
+		authority *policy.Options
+		prov      *provisioner.JWK
+		modifier  provisioner.CertificateModifierFunc
+		wantCode  int
+	}{
+		{"ok", nil, newProvisioner(nil), addName("bar.teamy.internal"), 0},
+		{"ok authority", allowTeamX, newProvisioner(nil), addName("bar.teamx.internal"), 0},
+		{"ok provisioner", nil, newProvisioner(allowTeamX), addName("bar.teamx.internal"), 0},
+		{"fail authority", allowTeamX, newProvisioner(nil), addName("bar.teamy.internal"), http.StatusForbidden},
+		{"fail provisioner", nil, newProvisioner(allowTeamX), addName("bar.teamy.internal"), http.StatusForbidden},
+		{"fail both", allowTeamX, newProvisioner(denyFoo), addName("bar.teamx.internal"), http.StatusForbidden},
+		{"ok san extension", nil, newProvisioner(allowTeamX), addSANExtension("bar.teamx.internal"), 0},
+		{"fail san extension", nil, newProvisioner(allowTeamX), func(crt *x509.Certificate, so provisioner.SignOptions) error {
+			// The name is only in the extension.
+			if err := addSANExtension("bar.teamy.internal")(crt, so); err != nil {
+				return err
+			}
+			crt.DNSNames = crt.DNSNames[:len(crt.DNSNames)-1]
+			crt.DNSNames = append(crt.DNSNames, "bar.teamx.internal")
+			return nil
+		}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := policy.New(tt.authority)
+			assert.FatalError(t, err)
+			a.policyEngine = e
+
+			chain, err := a.Sign(csr, provisioner.SignOptions{}, tt.prov, validity, tt.modifier)
+			if tt.wantCode != 0 {
+				sc, ok := err.(render.StatusCodedError)
+				assert.Fatal(t, ok, "error does not implement StatusCodedError interface")
+				assert.Equals(t, tt.wantCode, sc.StatusCode())
+				return
+			}
+			assert.FatalError(t, err)
+			assert.Len(t, 2, chain[0].DNSNames)
+		})
+	}
+}
+
+func TestAuthority_SignSSH_policy(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	pub, err := ssh.NewPublicKey(key.Public())
+	assert.FatalError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	assert.FatalError(t, err)
+
+	hostTemplate, err := provisioner.TemplateSSHOptions(nil, sshutil.CreateTemplateData(sshutil.HostCert, "key-id", []string{"foo.teamx.internal"}))
+	assert.FatalError(t, err)
+	// Simulates a template adding a principal to the certificate.
+	addPrincipal := sshTestModifier{ValidPrincipals: []string{"foo.teamx.internal", "db.teamx.internal"}}
+
+	newProvisioner := func(opts *policy.Options) *provisioner.JWK {
+		return newPolicyProvisioner(t, opts)
+	}
+	allowTeamX := &policy.Options{SSH: &policy.SSHOptions{
+		Host: &policy.SSHCertificateOptions{Allow: &policy.SSHNameOptions{DNSDomains: []string{"*.teamx.internal"}}},
+	}}
+	denyDB := &policy.Options{SSH: &policy.SSHOptions{
+		Host: &policy.SSHCertificateOptions{Deny: &policy.SSHNameOptions{DNSDomains: []string{"db.teamx.internal"}}},
+	}}
+	denyUsers := &policy.Options{SSH: &policy.SSHOptions{
+		User: &policy.SSHCertificateOptions{Deny: &policy.SSHNameOptions{Principals: []string{"*"}}},
+	}}
+
+	tests := []struct {
+		name      string
+		authority *policy.Options
+		prov      *provisioner.JWK
+		wantCode  int
+	}{
+		{"ok", nil, newProvisioner(nil), 0},
+		{"ok authority", allowTeamX, newProvisioner(nil), 0},
+		{"ok provisioner", denyUsers, newProvisioner(allowTeamX), 0},
+		{"fail authority", denyDB, newProvisioner(nil), http.StatusForbidden},
+		{"fail provisioner", allowTeamX, newProvisioner(denyDB), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := policy.New(tt.authority)
+			assert.FatalError(t, err)
+			a := testAuthority(t)
+			a.sshCAHostCertSignKey = signer
+			a.policyEngine = e
+
+			cert, err := a.SignSSH(context.Background(), pub, provisioner.SignSSHOptions{CertType: "host"}, tt.prov, hostTemplate, addPrincipal)
+			if tt.wantCode != 0 {
+				sc, ok := err.(render.StatusCodedError)
+				assert.Fatal(t, ok, "error does not implement StatusCodedError interface")
+				assert.Equals(t, tt.wantCode, sc.StatusCode())
+				return
+			}
+			assert.FatalError(t, err)
+			assert.Equals(t, []string{"foo.teamx.internal", "db.teamx.internal"}, cert.ValidPrincipals)
+		})
+	}
+}
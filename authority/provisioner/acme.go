@@ -3,11 +3,148 @@ package provisioner
 import (
 	"context"
 	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/policy"
 )
 
+// ACMEChallenge represents the supported ACME challenges.
+type ACMEChallenge string
+
+const (
+	// HTTP_01 is the http-01 ACME challenge.
+	HTTP_01 ACMEChallenge = "http-01"
+	// DNS_01 is the dns-01 ACME challenge.
+	DNS_01 ACMEChallenge = "dns-01"
+	// TLS_ALPN_01 is the tls-alpn-01 ACME challenge.
+	TLS_ALPN_01 ACMEChallenge = "tls-alpn-01"
+	// DEVICE_ATTEST_01 is the device-attest-01 ACME challenge.
+	DEVICE_ATTEST_01 ACMEChallenge = "device-attest-01"
+)
+
+// String returns a normalized version of the challenge.
+func (c ACMEChallenge) String() string {
+	return strings.ToLower(string(c))
+}
+
+// Validate returns an error if the challenge is not a valid one.
+func (c ACMEChallenge) Validate() error {
+	switch ACMEChallenge(c.String()) {
+	case HTTP_01, DNS_01, TLS_ALPN_01, DEVICE_ATTEST_01:
+		return nil
+	default:
+		return errors.Errorf("acme challenge %q is not supported", c)
+	}
+}
+
+// ACMEChallengeOptions configures how the ACME challenges are validated.
+type ACMEChallengeOptions struct {
+	// DNSResolvers is the list of DNS servers, in host or host:port form, used
+	// to resolve the TXT records in the dns-01 challenge. The servers are tried
+	// in order. The system resolver is used if no servers are configured.
+	DNSResolvers []string `json:"dnsResolvers,omitempty"`
+	// DNSOverTCP makes the DNS queries use TCP instead of UDP.
+	DNSOverTCP bool `json:"dnsOverTCP,omitempty"`
+	// HTTP01Port is the port used to validate the http-01 challenge, defaults
+	// to 80.
+	HTTP01Port int `json:"http01Port,omitempty"`
+	// Timeout is the maximum time of a validation attempt, defaults to 30s.
+	Timeout *Duration `json:"timeout,omitempty"`
+	// MaxAttempts is the number of times a challenge is validated before
+	// giving up if the validation fails because of a connection error or a
+	// missing DNS record, defaults to 5.
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+	// RetryInterval is the time to wait before the second validation attempt,
+	// it doubles after each attempt. Defaults to 5s.
+	RetryInterval *Duration `json:"retryInterval,omitempty"`
+}
+
+// Validate validates the challenge options.
+func (o *ACMEChallengeOptions) Validate() error {
+	if o == nil {
+		return nil
+	}
+	for _, s := range o.DNSResolvers {
+		if _, err := dnsResolverAddress(s); err != nil {
+			return err
+		}
+	}
+	switch {
+	case o.HTTP01Port < 0 || o.HTTP01Port > 65535:
+		return errors.Errorf("acme http01Port %d is not valid", o.HTTP01Port)
+	case o.Timeout.Value() < 0:
+		return errors.New("acme challenge timeout cannot be negative")
+	case o.MaxAttempts < 0:
+		return errors.New("acme challenge maxAttempts cannot be negative")
+	case o.RetryInterval.Value() < 0:
+		return errors.New("acme challenge retryInterval cannot be negative")
+	default:
+		return nil
+	}
+}
+
+// GetDNSResolvers returns the configured DNS servers in host:port form.
+func (o *ACMEChallengeOptions) GetDNSResolvers() []string {
+	if o == nil || len(o.DNSResolvers) == 0 {
+		return nil
+	}
+	addrs := make([]string, 0, len(o.DNSResolvers))
+	for _, s := range o.DNSResolvers {
+		if addr, err := dnsResolverAddress(s); err == nil {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// dnsResolverAddress returns the given DNS server in host:port form, using
+// the port 53 if the server does not have one.
+func dnsResolverAddress(s string) (string, error) {
+	if s == "" {
+		return "", errors.New("acme dns resolver cannot be empty")
+	}
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		// Host without a port or an IPv6 address without brackets.
+		host, port = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"), "53"
+	}
+	if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 || host == "" || strings.ContainsAny(host, "[]/") {
+		return "", errors.Errorf("acme dns resolver %q is not valid", s)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// ACMEAttestationFormat is the format of the attestation statement used in the
+// ACME device-attest-01 challenge.
+type ACMEAttestationFormat string
+
+const (
+	// APPLE is the format used by Apple devices using Managed Device
+	// Attestation.
+	APPLE ACMEAttestationFormat = "apple"
+	// STEP is the format used by YubiKeys, the attestation is done using the
+	// PIV attestation certificates.
+	STEP ACMEAttestationFormat = "step"
+	// TPM is the WebAuthn format used by devices with a Trusted Platform
+	// Module.
+	TPM ACMEAttestationFormat = "tpm"
+)
+
+// Validate returns an error if the attestation format is not a valid one.
+func (f ACMEAttestationFormat) Validate() error {
+	switch f {
+	case APPLE, STEP, TPM:
+		return nil
+	default:
+		return errors.Errorf("acme attestation format %q is not supported", f)
+	}
+}
+
 // ACME is the acme provisioner type, an entity that can authorize the ACME
 // provisioning flow.
 type ACME struct {
@@ -20,10 +157,30 @@ type ACME struct {
 	// by clients when creating a new Account. If set to true, the provided
 	// EAB will be verified. If set to false and an EAB is provided, it is
 	// not verified. Defaults to false.
-	RequireEAB bool     `json:"requireEAB,omitempty"`
-	Claims     *Claims  `json:"claims,omitempty"`
-	Options    *Options `json:"options,omitempty"`
-	ctl        *Controller
+	RequireEAB bool `json:"requireEAB,omitempty"`
+	// Challenges contains the enabled challenges for this provisioner. If this
+	// value is not set, all the challenges are enabled. The device-attest-01
+	// challenge also requires the attestation roots.
+	Challenges []ACMEChallenge `json:"challenges,omitempty"`
+	// ChallengeOptions configures the validation of the challenges, like the
+	// DNS resolvers or the number of attempts.
+	ChallengeOptions *ACMEChallengeOptions `json:"challengeOptions,omitempty"`
+	// RenewEarlyBefore makes the ACME Renewal Information (ARI) resource
+	// suggest the immediate renewal of all the certificates issued before this
+	// time. It allows to rotate certificates, after an intermediate change or a
+	// key compromise for example, without having to revoke them.
+	RenewEarlyBefore *time.Time `json:"renewEarlyBefore,omitempty"`
+	// AttestationFormats contains the attestation formats allowed in the
+	// device-attest-01 challenge. All the formats are allowed by default.
+	AttestationFormats []ACMEAttestationFormat `json:"attestationFormats,omitempty"`
+	// AttestationRoots contains a bundle of root certificates in PEM format
+	// used to verify the attestation certificates in the device-attest-01
+	// challenge. The challenge is not available if no roots are configured.
+	AttestationRoots    []byte   `json:"attestationRoots,omitempty"`
+	Claims              *Claims  `json:"claims,omitempty"`
+	Options             *Options `json:"options,omitempty"`
+	ctl                 *Controller
+	attestationRootPool *x509.CertPool
 }
 
 // GetID returns the provisioner unique identifier.
@@ -65,6 +222,11 @@ func (p *ACME) GetOptions() *Options {
 	return p.Options
 }
 
+// GetPolicy returns the name policy engine of the provisioner.
+func (p *ACME) GetPolicy() *policy.Engine {
+	return p.ctl.GetPolicy()
+}
+
 // DefaultTLSCertDuration returns the default TLS cert duration enforced by
 // the provisioner.
 func (p *ACME) DefaultTLSCertDuration() time.Duration {
@@ -80,10 +242,91 @@ func (p *ACME) Init(config Config) (err error) {
 		return errors.New("provisioner name cannot be empty")
 	}
 
+	for _, c := range p.Challenges {
+		if err := c.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := p.ChallengeOptions.Validate(); err != nil {
+		return err
+	}
+
+	for _, f := range p.AttestationFormats {
+		if err := f.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if len(p.AttestationRoots) > 0 {
+		p.attestationRootPool = x509.NewCertPool()
+
+		var (
+			block *pem.Block
+			rest  = p.AttestationRoots
+			count int
+		)
+		for rest != nil {
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return errors.Wrap(err, "error parsing x509 certificate from PEM block")
+			}
+			count++
+			p.attestationRootPool.AddCert(cert)
+		}
+
+		if count == 0 {
+			return errors.Errorf("no x509 certificates found in attestationRoots attribute for provisioner '%s'", p.GetName())
+		}
+	}
+
 	p.ctl, err = NewController(p, p.Claims, config)
 	return
 }
 
+// IsChallengeEnabled returns true if the given challenge is enabled in the
+// provisioner. All the challenges are enabled if none is configured.
+func (p *ACME) IsChallengeEnabled(ctx context.Context, challenge ACMEChallenge) bool {
+	if len(p.Challenges) == 0 {
+		return challenge.Validate() == nil
+	}
+	for _, ch := range p.Challenges {
+		if strings.EqualFold(string(ch), string(challenge)) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetChallengeOptions returns the options used to validate the challenges. It
+// returns nil if the defaults must be used.
+func (p *ACME) GetChallengeOptions() *ACMEChallengeOptions {
+	return p.ChallengeOptions
+}
+
+// IsAttestationFormatEnabled returns true if the given attestation format is
+// allowed in the device-attest-01 challenge.
+func (p *ACME) IsAttestationFormatEnabled(format ACMEAttestationFormat) bool {
+	if len(p.AttestationFormats) == 0 {
+		return format.Validate() == nil
+	}
+	for _, f := range p.AttestationFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAttestationRoots returns the pool of root certificates used to verify the
+// attestation certificates, and false if no roots have been configured.
+func (p *ACME) GetAttestationRoots() (*x509.CertPool, bool) {
+	return p.attestationRootPool, p.attestationRootPool != nil
+}
+
 // AuthorizeSign does not do any validation, because all validation is handled
 // in the ACME protocol. This method returns a list of modifiers / constraints
 // on the resulting certificate.
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -34,6 +36,9 @@ func TestACME_Getters(t *testing.T) {
 }
 
 func TestACME_Init(t *testing.T) {
+	roots, err := os.ReadFile("testdata/certs/root_ca.crt")
+	assert.FatalError(t, err)
+
 	type ProvisionerValidateTest struct {
 		p   *ACME
 		err error
@@ -65,11 +70,78 @@ func TestACME_Init(t *testing.T) {
 				err: errors.New("claims: MinTLSCertDuration must be greater than 0"),
 			}
 		},
+		"fail-bad-challenge": func(t *testing.T) ProvisionerValidateTest {
+			return ProvisionerValidateTest{
+				p:   &ACME{Name: "foo", Type: "bar", Challenges: []ACMEChallenge{DNS_01, "zap"}},
+				err: errors.New(`acme challenge "zap" is not supported`),
+			}
+		},
+		"fail-bad-dns-resolver": func(t *testing.T) ProvisionerValidateTest {
+			return ProvisionerValidateTest{
+				p: &ACME{Name: "foo", Type: "bar", ChallengeOptions: &ACMEChallengeOptions{
+					DNSResolvers: []string{"10.0.0.53", "udp://10.0.0.53"},
+				}},
+				err: errors.New(`acme dns resolver "udp://10.0.0.53" is not valid`),
+			}
+		},
+		"fail-bad-http01-port": func(t *testing.T) ProvisionerValidateTest {
+			return ProvisionerValidateTest{
+				p:   &ACME{Name: "foo", Type: "bar", ChallengeOptions: &ACMEChallengeOptions{HTTP01Port: 65536}},
+				err: errors.New("acme http01Port 65536 is not valid"),
+			}
+		},
+		"fail-bad-timeout": func(t *testing.T) ProvisionerValidateTest {
+			return ProvisionerValidateTest{
+				p:   &ACME{Name: "foo", Type: "bar", ChallengeOptions: &ACMEChallengeOptions{Timeout: &Duration{-time.Second}}},
+				err: errors.New("acme challenge timeout cannot be negative"),
+			}
+		},
+		"fail-bad-max-attempts": func(t *testing.T) ProvisionerValidateTest {
+			return ProvisionerValidateTest{
+				p:   &ACME{Name: "foo", Type: "bar", ChallengeOptions: &ACMEChallengeOptions{MaxAttempts: -1}},
+				err: errors.New("acme challenge maxAttempts cannot be negative"),
+			}
+		},
+		"fail-bad-retry-interval": func(t *testing.T) ProvisionerValidateTest {
+			return ProvisionerValidateTest{
+				p:   &ACME{Name: "foo", Type: "bar", ChallengeOptions: &ACMEChallengeOptions{RetryInterval: &Duration{-time.Second}}},
+				err: errors.New("acme challenge retryInterval cannot be negative"),
+			}
+		},
+		"fail-bad-attestation-format": func(t *testing.T) ProvisionerValidateTest {
+			return ProvisionerValidateTest{
+				p:   &ACME{Name: "foo", Type: "bar", AttestationFormats: []ACMEAttestationFormat{APPLE, "zap"}},
+				err: errors.New(`acme attestation format "zap" is not supported`),
+			}
+		},
+		"fail-bad-attestation-roots": func(t *testing.T) ProvisionerValidateTest {
+			return ProvisionerValidateTest{
+				p:   &ACME{Name: "foo", Type: "bar", AttestationRoots: []byte("foo")},
+				err: errors.New("no x509 certificates found in attestationRoots attribute for provisioner 'foo'"),
+			}
+		},
 		"ok": func(t *testing.T) ProvisionerValidateTest {
 			return ProvisionerValidateTest{
 				p: &ACME{Name: "foo", Type: "bar"},
 			}
 		},
+		"ok-challenges": func(t *testing.T) ProvisionerValidateTest {
+			return ProvisionerValidateTest{
+				p: &ACME{Name: "foo", Type: "bar", Challenges: []ACMEChallenge{DNS_01, "HTTP-01"}, ChallengeOptions: &ACMEChallengeOptions{
+					DNSResolvers:  []string{"10.0.0.53", "10.0.0.54:5353", "2001:db8::53", "[2001:db8::54]:53"},
+					DNSOverTCP:    true,
+					HTTP01Port:    8080,
+					Timeout:       &Duration{10 * time.Second},
+					MaxAttempts:   3,
+					RetryInterval: &Duration{time.Second},
+				}},
+			}
+		},
+		"ok-attestation": func(t *testing.T) ProvisionerValidateTest {
+			return ProvisionerValidateTest{
+				p: &ACME{Name: "foo", Type: "bar", AttestationFormats: []ACMEAttestationFormat{APPLE, STEP, TPM}, AttestationRoots: roots},
+			}
+		},
 	}
 
 	config := Config{
@@ -91,6 +163,72 @@ func TestACME_Init(t *testing.T) {
 	}
 }
 
+func TestACME_IsChallengeEnabled(t *testing.T) {
+	tests := []struct {
+		name      string
+		p         *ACME
+		challenge ACMEChallenge
+		want      bool
+	}{
+		{"ok default", &ACME{}, HTTP_01, true},
+		{"ok enabled", &ACME{Challenges: []ACMEChallenge{DNS_01, TLS_ALPN_01}}, DNS_01, true},
+		{"ok enabled uppercase", &ACME{Challenges: []ACMEChallenge{"DNS-01"}}, DNS_01, true},
+		{"fail default", &ACME{}, "zap", false},
+		{"fail disabled", &ACME{Challenges: []ACMEChallenge{DNS_01, TLS_ALPN_01}}, HTTP_01, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.p.IsChallengeEnabled(context.Background(), tt.challenge); got != tt.want {
+				t.Errorf("ACME.IsChallengeEnabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestACMEChallengeOptions_GetDNSResolvers(t *testing.T) {
+	tests := []struct {
+		name string
+		o    *ACMEChallengeOptions
+		want []string
+	}{
+		{"nil", nil, nil},
+		{"empty", &ACMEChallengeOptions{}, nil},
+		{"ok", &ACMEChallengeOptions{DNSResolvers: []string{
+			"10.0.0.53", "10.0.0.54:5353", "dns.internal", "2001:db8::53", "[2001:db8::54]", "[2001:db8::55]:5353",
+		}}, []string{
+			"10.0.0.53:53", "10.0.0.54:5353", "dns.internal:53", "[2001:db8::53]:53", "[2001:db8::54]:53", "[2001:db8::55]:5353",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.o.GetDNSResolvers(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ACMEChallengeOptions.GetDNSResolvers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestACME_IsAttestationFormatEnabled(t *testing.T) {
+	tests := []struct {
+		name   string
+		p      *ACME
+		format ACMEAttestationFormat
+		want   bool
+	}{
+		{"ok default", &ACME{}, TPM, true},
+		{"ok enabled", &ACME{AttestationFormats: []ACMEAttestationFormat{APPLE, STEP}}, STEP, true},
+		{"fail default", &ACME{}, "zap", false},
+		{"fail disabled", &ACME{AttestationFormats: []ACMEAttestationFormat{APPLE, STEP}}, TPM, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.p.IsAttestationFormatEnabled(tt.format); got != tt.want {
+				t.Errorf("ACME.IsAttestationFormatEnabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestACME_AuthorizeRenew(t *testing.T) {
 	now := time.Now().Truncate(time.Second)
 	type test struct {
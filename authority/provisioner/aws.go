@@ -18,6 +18,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/certificates/policy"
 	"go.step.sm/crypto/jose"
 	"go.step.sm/crypto/sshutil"
 	"go.step.sm/crypto/x509util"
@@ -316,6 +317,16 @@ func (p *AWS) GetEncryptedKey() (kid, key string, ok bool) {
 	return "", "", false
 }
 
+// GetOptions returns the configured provisioner options.
+func (p *AWS) GetOptions() *Options {
+	return p.Options
+}
+
+// GetPolicy returns the name policy engine of the provisioner.
+func (p *AWS) GetPolicy() *policy.Engine {
+	return p.ctl.GetPolicy()
+}
+
 // GetIdentityToken retrieves the identity document and it's signature and
 // generates a token with them.
 func (p *AWS) GetIdentityToken(subject, caURL string) (string, error) {
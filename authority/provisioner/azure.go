@@ -14,6 +14,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/certificates/policy"
 	"go.step.sm/crypto/jose"
 	"go.step.sm/crypto/sshutil"
 	"go.step.sm/crypto/x509util"
@@ -158,6 +159,16 @@ func (p *Azure) GetEncryptedKey() (kid, key string, ok bool) {
 	return "", "", false
 }
 
+// GetOptions returns the configured provisioner options.
+func (p *Azure) GetOptions() *Options {
+	return p.Options
+}
+
+// GetPolicy returns the name policy engine of the provisioner.
+func (p *Azure) GetPolicy() *policy.Engine {
+	return p.ctl.GetPolicy()
+}
+
 // GetIdentityToken retrieves from the metadata service the identity token and
 // returns it.
 func (p *Azure) GetIdentityToken(subject, caURL string) (string, error) {
@@ -9,6 +9,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/certificates/policy"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -21,6 +22,7 @@ type Controller struct {
 	IdentityFunc          GetIdentityFunc
 	AuthorizeRenewFunc    AuthorizeRenewFunc
 	AuthorizeSSHRenewFunc AuthorizeSSHRenewFunc
+	Policy                *policy.Engine
 }
 
 // NewController initializes a new provisioner controller.
@@ -29,6 +31,19 @@ func NewController(p Interface, claims *Claims, config Config) (*Controller, err
 	if err != nil {
 		return nil, err
 	}
+	var engine *policy.Engine
+	if o, ok := p.(interface{ GetOptions() *Options }); ok {
+		if engine, err = policy.New(o.GetOptions().GetPolicyOptions()); err != nil {
+			return nil, errors.Wrap(err, "invalid policy")
+		}
+		ct := o.GetOptions().GetX509Options().GetCertificateTransparencyOptions()
+		if err := ct.Validate(); err != nil {
+			return nil, err
+		}
+		if ct.IsEnabled() && config.DisableCertificateTransparency {
+			return nil, errors.New("certificateTransparency is only supported with the default certificate authority service")
+		}
+	}
 	return &Controller{
 		Interface:             p,
 		Audiences:             &config.Audiences,
@@ -36,9 +51,19 @@ func NewController(p Interface, claims *Claims, config Config) (*Controller, err
 		IdentityFunc:          config.GetIdentityFunc,
 		AuthorizeRenewFunc:    config.AuthorizeRenewFunc,
 		AuthorizeSSHRenewFunc: config.AuthorizeSSHRenewFunc,
+		Policy:                engine,
 	}, nil
 }
 
+// GetPolicy returns the name policy engine of the provisioner, it returns nil
+// if the provisioner does not have a policy.
+func (c *Controller) GetPolicy() *policy.Engine {
+	if c == nil {
+		return nil
+	}
+	return c.Policy
+}
+
 // GetIdentity returns the identity for a given email.
 func (c *Controller) GetIdentity(ctx context.Context, email string) (*Identity, error) {
 	if c.IdentityFunc != nil {
@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/smallstep/certificates/policy"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -31,6 +32,14 @@ func mustDuration(t *testing.T, s string) *Duration {
 }
 
 func TestNewController(t *testing.T) {
+	denyPolicy := &policy.Options{
+		X509: &policy.X509Options{Deny: &policy.X509NameOptions{DNSDomains: []string{"*.example.com"}}},
+	}
+	denyEngine, err := policy.New(denyPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	type args struct {
 		p      Interface
 		claims *Claims
@@ -62,6 +71,15 @@ func TestNewController(t *testing.T) {
 				DisableRenewal: &defaultDisableRenewal,
 			}, globalProvisionerClaims),
 		}, false},
+		{"ok with policy", args{&JWK{Options: &Options{Policy: denyPolicy}}, nil, Config{
+			Claims:    globalProvisionerClaims,
+			Audiences: testAudiences,
+		}}, &Controller{
+			Interface: &JWK{Options: &Options{Policy: denyPolicy}},
+			Audiences: &testAudiences,
+			Claimer:   mustClaimer(t, nil, globalProvisionerClaims),
+			Policy:    denyEngine,
+		}, false},
 		{"fail claimer", args{&JWK{}, &Claims{
 			MinTLSDur: mustDuration(t, "24h"),
 			MaxTLSDur: mustDuration(t, "2h"),
@@ -69,6 +87,25 @@ func TestNewController(t *testing.T) {
 			Claims:    globalProvisionerClaims,
 			Audiences: testAudiences,
 		}}, nil, true},
+		{"fail certificate transparency options", args{&JWK{Options: &Options{X509: &X509Options{
+			CertificateTransparency: &CertificateTransparencyOptions{Logs: []CertificateTransparencyLog{{Key: "key"}}},
+		}}}, nil, Config{
+			Claims:    globalProvisionerClaims,
+			Audiences: testAudiences,
+		}}, nil, true},
+		{"fail certificate transparency disabled", args{&JWK{Options: &Options{X509: &X509Options{
+			CertificateTransparency: &CertificateTransparencyOptions{Logs: []CertificateTransparencyLog{{URL: "https://ct.example.com"}}},
+		}}}, nil, Config{
+			Claims:                         globalProvisionerClaims,
+			Audiences:                      testAudiences,
+			DisableCertificateTransparency: true,
+		}}, nil, true},
+		{"fail policy", args{&JWK{Options: &Options{Policy: &policy.Options{
+			X509: &policy.X509Options{Allow: &policy.X509NameOptions{IPRanges: []string{"10.0.0.0/33"}}},
+		}}}, nil, Config{
+			Claims:    globalProvisionerClaims,
+			Audiences: testAudiences,
+		}}, nil, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -84,6 +121,29 @@ func TestNewController(t *testing.T) {
 	}
 }
 
+func TestController_GetPolicy(t *testing.T) {
+	e, err := policy.New(&policy.Options{SSH: &policy.SSHOptions{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		c    *Controller
+		want *policy.Engine
+	}{
+		{"ok", &Controller{Policy: e}, e},
+		{"ok no policy", &Controller{}, nil},
+		{"ok nil", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.c.GetPolicy(); got != tt.want {
+				t.Errorf("Controller.GetPolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestController_GetIdentity(t *testing.T) {
 	ctx := context.Background()
 	type fields struct {
@@ -0,0 +1,231 @@
+package provisioner
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/certificates/policy"
+	"go.step.sm/crypto/x509util"
+)
+
+// EST is the EST provisioner type, an entity that can authorize the EST
+// (RFC 7030) enrollment flow. Initial enrollments are authenticated with HTTP
+// basic authentication or with a bootstrap client certificate, and
+// re-enrollments with the certificate issued by the CA.
+type EST struct {
+	*base
+	ID      string `json:"-"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	ForceCN bool   `json:"forceCN,omitempty"`
+
+	// Username and Password are the credentials used in the HTTP basic
+	// authentication of the enrollment requests.
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// BootstrapRoots contains a bundle of root certificates in PEM format used
+	// to verify the client certificates of the enrollment requests, e.g. the
+	// certificates installed by the manufacturer of a device.
+	BootstrapRoots []byte `json:"bootstrapRoots,omitempty"`
+
+	// CSRAttributes is the list of OIDs returned by the csrattrs endpoint,
+	// the attributes the clients should include in the CSR.
+	CSRAttributes x509util.MultiObjectIdentifier `json:"csrAttributes,omitempty"`
+
+	// MinimumPublicKeyLength is the minimum length for public keys in CSRs
+	MinimumPublicKeyLength int `json:"minimumPublicKeyLength,omitempty"`
+
+	Options           *Options `json:"options,omitempty"`
+	Claims            *Claims  `json:"claims,omitempty"`
+	secretPassword    string
+	bootstrapRoots    []*x509.Certificate
+	bootstrapRootPool *x509.CertPool
+	ctl               *Controller
+}
+
+// GetID returns the provisioner unique identifier.
+func (p *EST) GetID() string {
+	if p.ID != "" {
+		return p.ID
+	}
+	return p.GetIDForToken()
+}
+
+// GetIDForToken returns an identifier that will be used to load the provisioner
+// from a token.
+func (p *EST) GetIDForToken() string {
+	return "est/" + p.Name
+}
+
+// GetName returns the name of the provisioner.
+func (p *EST) GetName() string {
+	return p.Name
+}
+
+// GetType returns the type of provisioner.
+func (p *EST) GetType() Type {
+	return TypeEST
+}
+
+// GetEncryptedKey returns the base provisioner encrypted key if it's defined.
+func (p *EST) GetEncryptedKey() (string, string, bool) {
+	return "", "", false
+}
+
+// GetTokenID returns the identifier of the token.
+func (p *EST) GetTokenID(ott string) (string, error) {
+	return "", errors.New("est provisioner does not implement GetTokenID")
+}
+
+// GetOptions returns the configured provisioner options.
+func (p *EST) GetOptions() *Options {
+	return p.Options
+}
+
+// GetPolicy returns the name policy engine of the provisioner.
+func (p *EST) GetPolicy() *policy.Engine {
+	return p.ctl.GetPolicy()
+}
+
+// DefaultTLSCertDuration returns the default TLS cert duration enforced by
+// the provisioner.
+func (p *EST) DefaultTLSCertDuration() time.Duration {
+	return p.ctl.Claimer.DefaultTLSCertDuration()
+}
+
+// Init initializes and validates the fields of an EST type.
+func (p *EST) Init(config Config) (err error) {
+	switch {
+	case p.Type == "":
+		return errors.New("provisioner type cannot be empty")
+	case p.Name == "":
+		return errors.New("provisioner name cannot be empty")
+	case p.Username != "" && p.Password == "":
+		return errors.New("provisioner password cannot be empty")
+	case p.Username == "" && p.Password != "":
+		return errors.New("provisioner username cannot be empty")
+	case p.Password == "" && len(p.BootstrapRoots) == 0:
+		return errors.New("provisioner password or bootstrapRoots must be set")
+	}
+
+	if len(p.BootstrapRoots) > 0 {
+		p.bootstrapRoots = nil
+		p.bootstrapRootPool = x509.NewCertPool()
+
+		var (
+			block *pem.Block
+			rest  = p.BootstrapRoots
+		)
+		for rest != nil {
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return errors.Wrap(err, "error parsing x509 certificate from PEM block")
+			}
+			p.bootstrapRoots = append(p.bootstrapRoots, cert)
+			p.bootstrapRootPool.AddCert(cert)
+		}
+
+		// Verify that at least one root was found.
+		if len(p.bootstrapRoots) == 0 {
+			return errors.Errorf("no x509 certificates found in bootstrapRoots attribute for provisioner '%s'", p.GetName())
+		}
+	}
+
+	// Mask the actual password, so it won't be marshaled
+	if p.Password != "" {
+		p.secretPassword = p.Password
+		p.Password = "*** redacted ***"
+	}
+
+	// Default to 2048 bits minimum public key length (for CSRs) if not set
+	if p.MinimumPublicKeyLength == 0 {
+		p.MinimumPublicKeyLength = 2048
+	}
+
+	if p.MinimumPublicKeyLength%8 != 0 {
+		return errors.Errorf("%d bits is not exactly divisible by 8", p.MinimumPublicKeyLength)
+	}
+
+	p.ctl, err = NewController(p, p.Claims, config)
+	return
+}
+
+// AuthorizeSign does not do any verification, because the request is
+// authenticated by the EST handler. This method returns a list of modifiers
+// and constraints on the resulting certificate.
+func (p *EST) AuthorizeSign(ctx context.Context, token string) ([]SignOption, error) {
+	return []SignOption{
+		p,
+		// modifiers / withOptions
+		newProvisionerExtensionOption(TypeEST, p.Name, ""),
+		newForceCNOption(p.ForceCN),
+		profileDefaultDuration(p.ctl.Claimer.DefaultTLSCertDuration()),
+		// validators
+		newPublicKeyMinimumLengthValidator(p.MinimumPublicKeyLength),
+		newValidityValidator(p.ctl.Claimer.MinTLSCertDuration(), p.ctl.Claimer.MaxTLSCertDuration()),
+	}, nil
+}
+
+// AuthorizeRenew returns an error if the given certificate, the client
+// certificate of a re-enrollment, cannot be renewed.
+func (p *EST) AuthorizeRenew(ctx context.Context, cert *x509.Certificate) error {
+	return p.ctl.AuthorizeRenew(ctx, cert)
+}
+
+// AuthorizeBasic returns an error if the given username and password do not
+// match the credentials of the provisioner.
+func (p *EST) AuthorizeBasic(username, password string) error {
+	if p.secretPassword == "" {
+		return errs.Unauthorized("est.AuthorizeBasic; basic authentication is not enabled")
+	}
+	userMatch := subtle.ConstantTimeCompare([]byte(username), []byte(p.Username))
+	passMatch := subtle.ConstantTimeCompare([]byte(password), []byte(p.secretPassword))
+	if userMatch&passMatch != 1 {
+		return errs.Unauthorized("est.AuthorizeBasic; invalid username or password")
+	}
+	return nil
+}
+
+// AuthorizeBootstrap returns an error if the given client certificate is not
+// signed by one of the bootstrap roots of the provisioner.
+func (p *EST) AuthorizeBootstrap(cert *x509.Certificate, intermediates []*x509.Certificate) error {
+	if p.bootstrapRootPool == nil {
+		return errs.Unauthorized("est.AuthorizeBootstrap; bootstrap certificates are not enabled")
+	}
+	pool := x509.NewCertPool()
+	for _, crt := range intermediates {
+		pool.AddCert(crt)
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         p.bootstrapRootPool,
+		Intermediates: pool,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return errs.Wrap(http.StatusUnauthorized, err, "est.AuthorizeBootstrap; error verifying bootstrap certificate")
+	}
+	return nil
+}
+
+// GetBootstrapRoots returns the roots used to verify the bootstrap client
+// certificates.
+func (p *EST) GetBootstrapRoots() []*x509.Certificate {
+	return p.bootstrapRoots
+}
+
+// GetCSRAttributes returns the OIDs of the attributes the clients should
+// include in the CSR.
+func (p *EST) GetCSRAttributes() []asn1.ObjectIdentifier {
+	return p.CSRAttributes
+}
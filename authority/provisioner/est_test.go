@@ -0,0 +1,159 @@
+package provisioner
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/smallstep/assert"
+	"go.step.sm/crypto/minica"
+)
+
+func TestEST_Getters(t *testing.T) {
+	p := &EST{Type: "EST", Name: "est", Password: "password", Username: "user"}
+	assert.FatalError(t, p.Init(Config{Claims: globalProvisionerClaims}))
+	if got := p.GetID(); got != "est/est" {
+		t.Errorf("EST.GetID() = %v, want %v", got, "est/est")
+	}
+	if got := p.GetName(); got != "est" {
+		t.Errorf("EST.GetName() = %v, want %v", got, "est")
+	}
+	if got := p.GetType(); got != TypeEST {
+		t.Errorf("EST.GetType() = %v, want %v", got, TypeEST)
+	}
+	kid, key, ok := p.GetEncryptedKey()
+	if kid != "" || key != "" || ok == true {
+		t.Errorf("EST.GetEncryptedKey() = (%v, %v, %v), want (%v, %v, %v)",
+			kid, key, ok, "", "", false)
+	}
+	assert.Equals(t, "*** redacted ***", p.Password)
+}
+
+func TestEST_Init(t *testing.T) {
+	roots, err := os.ReadFile("testdata/certs/root_ca.crt")
+	assert.FatalError(t, err)
+
+	tests := []struct {
+		name string
+		p    *EST
+		err  error
+	}{
+		{"fail-empty-type", &EST{Name: "foo"}, errors.New("provisioner type cannot be empty")},
+		{"fail-empty-name", &EST{Type: "EST"}, errors.New("provisioner name cannot be empty")},
+		{"fail-empty-password", &EST{Type: "EST", Name: "foo", Username: "user"}, errors.New("provisioner password cannot be empty")},
+		{"fail-empty-username", &EST{Type: "EST", Name: "foo", Password: "password"}, errors.New("provisioner username cannot be empty")},
+		{"fail-no-auth", &EST{Type: "EST", Name: "foo"}, errors.New("provisioner password or bootstrapRoots must be set")},
+		{"fail-bad-roots", &EST{Type: "EST", Name: "foo", BootstrapRoots: []byte("foo")}, errors.New("no x509 certificates found in bootstrapRoots attribute for provisioner 'foo'")},
+		{"fail-key-length", &EST{Type: "EST", Name: "foo", BootstrapRoots: roots, MinimumPublicKeyLength: 2047}, errors.New("2047 bits is not exactly divisible by 8")},
+		{"ok-basic", &EST{Type: "EST", Name: "foo", Username: "user", Password: "password"}, nil},
+		{"ok-bootstrap", &EST{Type: "EST", Name: "foo", BootstrapRoots: roots}, nil},
+		{"ok-csr-attributes", &EST{Type: "EST", Name: "foo", BootstrapRoots: roots, CSRAttributes: []asn1.ObjectIdentifier{{1, 2, 840, 113549, 1, 9, 7}}}, nil},
+	}
+	config := Config{
+		Claims:    globalProvisionerClaims,
+		Audiences: testAudiences,
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.p.Init(config)
+			if tt.err != nil {
+				if assert.Error(t, err) {
+					assert.Equals(t, tt.err.Error(), err.Error())
+				}
+				return
+			}
+			assert.FatalError(t, err)
+		})
+	}
+}
+
+func TestEST_AuthorizeBasic(t *testing.T) {
+	p := &EST{Type: "EST", Name: "est", Username: "user", Password: "password"}
+	assert.FatalError(t, p.Init(Config{Claims: globalProvisionerClaims}))
+
+	roots, err := os.ReadFile("testdata/certs/root_ca.crt")
+	assert.FatalError(t, err)
+	bootstrap := &EST{Type: "EST", Name: "est", BootstrapRoots: roots}
+	assert.FatalError(t, bootstrap.Init(Config{Claims: globalProvisionerClaims}))
+
+	tests := []struct {
+		name     string
+		p        *EST
+		username string
+		password string
+		wantErr  bool
+	}{
+		{"ok", p, "user", "password", false},
+		{"fail password", p, "user", "foo", true},
+		{"fail username", p, "foo", "password", true},
+		{"fail redacted", p, "user", "*** redacted ***", true},
+		{"fail not enabled", bootstrap, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.p.AuthorizeBasic(tt.username, tt.password); (err != nil) != tt.wantErr {
+				t.Errorf("EST.AuthorizeBasic() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEST_AuthorizeBootstrap(t *testing.T) {
+	ca, err := minica.New()
+	assert.FatalError(t, err)
+	other, err := minica.New()
+	assert.FatalError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	sign := func(ca *minica.CA, extKeyUsage x509.ExtKeyUsage) *x509.Certificate {
+		crt, err := ca.Sign(&x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "device-1"},
+			PublicKey:    key.Public(),
+			ExtKeyUsage:  []x509.ExtKeyUsage{extKeyUsage},
+		})
+		assert.FatalError(t, err)
+		return crt
+	}
+	cert := sign(ca, x509.ExtKeyUsageClientAuth)
+
+	p := &EST{Type: "EST", Name: "est", BootstrapRoots: pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: ca.Root.Raw,
+	})}
+	assert.FatalError(t, p.Init(Config{Claims: globalProvisionerClaims}))
+	assert.Equals(t, []*x509.Certificate{ca.Root}, p.GetBootstrapRoots())
+
+	basic := &EST{Type: "EST", Name: "est", Username: "user", Password: "password"}
+	assert.FatalError(t, basic.Init(Config{Claims: globalProvisionerClaims}))
+
+	tests := []struct {
+		name          string
+		p             *EST
+		cert          *x509.Certificate
+		intermediates []*x509.Certificate
+		wantErr       bool
+	}{
+		{"ok", p, cert, []*x509.Certificate{ca.Intermediate}, false},
+		{"fail no intermediate", p, cert, nil, true},
+		{"fail other root", p, sign(other, x509.ExtKeyUsageClientAuth), []*x509.Certificate{other.Intermediate}, true},
+		{"fail server auth", p, sign(ca, x509.ExtKeyUsageServerAuth), []*x509.Certificate{ca.Intermediate}, true},
+		{"fail not enabled", basic, cert, []*x509.Certificate{ca.Intermediate}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.p.AuthorizeBootstrap(tt.cert, tt.intermediates); (err != nil) != tt.wantErr {
+				t.Errorf("EST.AuthorizeBootstrap() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/certificates/policy"
 	"go.step.sm/crypto/jose"
 	"go.step.sm/crypto/sshutil"
 	"go.step.sm/crypto/x509util"
@@ -153,6 +154,16 @@ func (p *GCP) GetEncryptedKey() (kid, key string, ok bool) {
 	return "", "", false
 }
 
+// GetOptions returns the configured provisioner options.
+func (p *GCP) GetOptions() *Options {
+	return p.Options
+}
+
+// GetPolicy returns the name policy engine of the provisioner.
+func (p *GCP) GetPolicy() *policy.Engine {
+	return p.ctl.GetPolicy()
+}
+
 // GetIdentityURL returns the url that generates the GCP token.
 func (p *GCP) GetIdentityURL(audience string) string {
 	// Initialize config if required
@@ -8,6 +8,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/certificates/policy"
 	"go.step.sm/crypto/jose"
 	"go.step.sm/crypto/sshutil"
 	"go.step.sm/crypto/x509util"
@@ -86,6 +87,16 @@ func (p *JWK) GetEncryptedKey() (string, string, bool) {
 	return p.Key.KeyID, p.EncryptedKey, len(p.EncryptedKey) > 0
 }
 
+// GetOptions returns the configured provisioner options.
+func (p *JWK) GetOptions() *Options {
+	return p.Options
+}
+
+// GetPolicy returns the name policy engine of the provisioner.
+func (p *JWK) GetPolicy() *policy.Engine {
+	return p.ctl.GetPolicy()
+}
+
 // Init initializes and validates the fields of a JWK type.
 func (p *JWK) Init(config Config) (err error) {
 	switch {
@@ -11,6 +11,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/certificates/policy"
 	"go.step.sm/crypto/jose"
 	"go.step.sm/crypto/pemutil"
 	"go.step.sm/crypto/sshutil"
@@ -89,6 +90,16 @@ func (p *K8sSA) GetEncryptedKey() (string, string, bool) {
 	return "", "", false
 }
 
+// GetOptions returns the configured provisioner options.
+func (p *K8sSA) GetOptions() *Options {
+	return p.Options
+}
+
+// GetPolicy returns the name policy engine of the provisioner.
+func (p *K8sSA) GetPolicy() *policy.Engine {
+	return p.ctl.GetPolicy()
+}
+
 // Init initializes and validates the fields of a K8sSA type.
 func (p *K8sSA) Init(config Config) (err error) {
 	switch {
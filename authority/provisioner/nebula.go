@@ -11,6 +11,7 @@ import (
 	"github.com/pkg/errors"
 	nebula "github.com/slackhq/nebula/cert"
 	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/certificates/policy"
 	"go.step.sm/crypto/jose"
 	"go.step.sm/crypto/sshutil"
 	"go.step.sm/crypto/x25519"
@@ -112,6 +113,16 @@ func (p *Nebula) GetEncryptedKey() (kid, key string, ok bool) {
 	return "", "", false
 }
 
+// GetOptions returns the configured provisioner options.
+func (p *Nebula) GetOptions() *Options {
+	return p.Options
+}
+
+// GetPolicy returns the name policy engine of the provisioner.
+func (p *Nebula) GetPolicy() *policy.Engine {
+	return p.ctl.GetPolicy()
+}
+
 // AuthorizeSign returns the list of SignOption for a Sign request.
 func (p *Nebula) AuthorizeSign(ctx context.Context, token string) ([]SignOption, error) {
 	crt, claims, err := p.authorizeToken(token, p.ctl.Audiences.Sign)
@@ -13,6 +13,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/certificates/policy"
 	"go.step.sm/crypto/jose"
 	"go.step.sm/crypto/sshutil"
 	"go.step.sm/crypto/x509util"
@@ -151,6 +152,16 @@ func (o *OIDC) GetEncryptedKey() (kid, key string, ok bool) {
 	return "", "", false
 }
 
+// GetOptions returns the configured provisioner options.
+func (o *OIDC) GetOptions() *Options {
+	return o.Options
+}
+
+// GetPolicy returns the name policy engine of the provisioner.
+func (o *OIDC) GetPolicy() *policy.Engine {
+	return o.ctl.GetPolicy()
+}
+
 // Init validates and initializes the OIDC provider.
 func (o *OIDC) Init(config Config) (err error) {
 	switch {
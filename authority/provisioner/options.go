@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/policy"
 	"go.step.sm/crypto/jose"
 	"go.step.sm/crypto/x509util"
 )
@@ -26,6 +27,11 @@ func (fn certificateOptionsFunc) Options(so SignOptions) []x509util.Option {
 type Options struct {
 	X509 *X509Options `json:"x509,omitempty"`
 	SSH  *SSHOptions  `json:"ssh,omitempty"`
+
+	// Policy contains the names allowed and denied in the certificates
+	// issued by the provisioner. It is enforced in addition to the authority
+	// policy.
+	Policy *policy.Options `json:"policy,omitempty"`
 }
 
 // GetX509Options returns the X.509 options.
@@ -44,6 +50,14 @@ func (o *Options) GetSSHOptions() *SSHOptions {
 	return o.SSH
 }
 
+// GetPolicyOptions returns the name policy options.
+func (o *Options) GetPolicyOptions() *policy.Options {
+	if o == nil {
+		return nil
+	}
+	return o.Policy
+}
+
 // X509Options contains specific options for X.509 certificates.
 type X509Options struct {
 	// Template contains a X.509 certificate template. It can be a JSON template
@@ -56,6 +70,75 @@ type X509Options struct {
 	// TemplateData is a JSON object with variables that can be used in custom
 	// templates.
 	TemplateData json.RawMessage `json:"templateData,omitempty"`
+
+	// CertificateTransparency defines the Certificate Transparency logs where
+	// the precertificates are submitted before the final certificate is
+	// issued.
+	CertificateTransparency *CertificateTransparencyOptions `json:"certificateTransparency,omitempty"`
+}
+
+// CertificateTransparencyOptions contains the list of RFC 6962 Certificate
+// Transparency logs used by a provisioner. A precertificate is submitted to all
+// the logs and the signed certificate timestamps (SCTs) returned are embedded
+// in the final certificate.
+type CertificateTransparencyOptions struct {
+	// Logs is the list of CT logs where the precertificates are submitted.
+	Logs []CertificateTransparencyLog `json:"logs"`
+
+	// MinSCTs is the minimum number of SCTs required to issue a certificate.
+	// It defaults to the number of logs.
+	MinSCTs int `json:"minSCTs,omitempty"`
+}
+
+// CertificateTransparencyLog is a Certificate Transparency log.
+type CertificateTransparencyLog struct {
+	// URL is the base URL of the log, e.g. https://ct.example.com/2022.
+	URL string `json:"url"`
+
+	// Key is the base64 encoded DER public key of the log. If it's set, the
+	// SCTs returned by the log are verified.
+	Key string `json:"key,omitempty"`
+}
+
+// GetCertificateTransparencyOptions returns the Certificate Transparency
+// options.
+func (o *X509Options) GetCertificateTransparencyOptions() *CertificateTransparencyOptions {
+	if o == nil {
+		return nil
+	}
+	return o.CertificateTransparency
+}
+
+// IsEnabled returns true if at least one CT log is configured.
+func (o *CertificateTransparencyOptions) IsEnabled() bool {
+	return o != nil && len(o.Logs) > 0
+}
+
+// Validate validates the Certificate Transparency options.
+func (o *CertificateTransparencyOptions) Validate() error {
+	if o == nil {
+		return nil
+	}
+	for _, l := range o.Logs {
+		if l.URL == "" {
+			return errors.New("certificateTransparency log url cannot be empty")
+		}
+	}
+	switch {
+	case o.MinSCTs < 0:
+		return errors.New("certificateTransparency minSCTs cannot be negative")
+	case o.MinSCTs > len(o.Logs):
+		return errors.New("certificateTransparency minSCTs cannot be greater than the number of logs")
+	}
+	return nil
+}
+
+// GetMinSCTs returns the minimum number of SCTs required.
+func (o *CertificateTransparencyOptions) GetMinSCTs() int {
+	if o.MinSCTs > 0 {
+		return o.MinSCTs
+	}
+	return len(o.Logs)
 }
 
 // HasTemplate returns true if a template is defined in the provisioner options.
@@ -98,6 +98,40 @@ func TestProvisionerX509Options_HasTemplate(t *testing.T) {
 	}
 }
 
+func TestCertificateTransparencyOptions_Validate(t *testing.T) {
+	logs := []CertificateTransparencyLog{{URL: "https://ct1.example.com"}, {URL: "https://ct2.example.com"}}
+	tests := []struct {
+		name        string
+		opts        *CertificateTransparencyOptions
+		wantEnabled bool
+		wantMin     int
+		wantErr     bool
+	}{
+		{"ok nil", nil, false, 0, false},
+		{"ok empty", &CertificateTransparencyOptions{}, false, 0, false},
+		{"ok", &CertificateTransparencyOptions{Logs: logs}, true, 2, false},
+		{"ok minSCTs", &CertificateTransparencyOptions{Logs: logs, MinSCTs: 1}, true, 1, false},
+		{"fail url", &CertificateTransparencyOptions{Logs: []CertificateTransparencyLog{{Key: "key"}}}, true, 1, true},
+		{"fail negative minSCTs", &CertificateTransparencyOptions{Logs: logs, MinSCTs: -1}, true, 2, true},
+		{"fail minSCTs", &CertificateTransparencyOptions{Logs: logs, MinSCTs: 3}, true, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("CertificateTransparencyOptions.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := tt.opts.IsEnabled(); got != tt.wantEnabled {
+				t.Errorf("CertificateTransparencyOptions.IsEnabled() = %v, want %v", got, tt.wantEnabled)
+			}
+			if tt.wantEnabled {
+				if got := tt.opts.GetMinSCTs(); got != tt.wantMin {
+					t.Errorf("CertificateTransparencyOptions.GetMinSCTs() = %v, want %v", got, tt.wantMin)
+				}
+			}
+		})
+	}
+}
+
 func TestTemplateOptions(t *testing.T) {
 	csr := parseCertificateRequest(t, "testdata/certs/ecdsa.csr")
 	data := x509util.TemplateData{
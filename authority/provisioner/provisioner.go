@@ -166,6 +166,8 @@ const (
 	TypeSCEP Type = 10
 	// TypeNebula is used to indicate the Nebula provisioners
 	TypeNebula Type = 11
+	// TypeEST is used to indicate the EST provisioners
+	TypeEST Type = 12
 )
 
 // String returns the string representation of the type.
@@ -193,6 +195,8 @@ func (t Type) String() string {
 		return "SCEP"
 	case TypeNebula:
 		return "Nebula"
+	case TypeEST:
+		return "EST"
 	default:
 		return ""
 	}
@@ -222,6 +226,10 @@ type Config struct {
 	// AuthorizeSSHRenewFunc is a function that returns nil if a given SSH
 	// certificate can be renewed.
 	AuthorizeSSHRenewFunc AuthorizeSSHRenewFunc
+	// DisableCertificateTransparency is set if the certificate authority
+	// service cannot issue the precertificates submitted to the Certificate
+	// Transparency logs. Provisioners with CT logs will fail to initialize.
+	DisableCertificateTransparency bool
 }
 
 type provisioner struct {
@@ -269,6 +277,8 @@ func (l *List) UnmarshalJSON(data []byte) error {
 			p = &SCEP{}
 		case "nebula":
 			p = &Nebula{}
+		case "est":
+			p = &EST{}
 		default:
 			// Skip unsupported provisioners. A client using this method may be
 			// compiled with a version of smallstep/certificates that does not
@@ -1,12 +1,62 @@
 package provisioner
 
 import (
+	"bytes"
 	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/certificates/policy"
 )
 
+// SCEP challenge types.
+const (
+	// SCEPChallengeStatic validates the challenge against the static
+	// challenge password of the provisioner. It's the default.
+	SCEPChallengeStatic = "static"
+	// SCEPChallengeOneTime validates the challenge against the one-time
+	// challenges created using the admin API.
+	SCEPChallengeOneTime = "one-time"
+	// SCEPChallengeWebhook validates the challenge and the CSR using an
+	// external webhook.
+	SCEPChallengeWebhook = "webhook"
+)
+
+// defaultSCEPWebhookTimeout is the default timeout of the challenge webhook
+// requests.
+const defaultSCEPWebhookTimeout = 10 * time.Second
+
+// SCEPChallengeWebhookOptions are the options of the webhook used to validate
+// the SCEP challenges, e.g. an MDM endpoint.
+type SCEPChallengeWebhookOptions struct {
+	// URL is the endpoint of the webhook.
+	URL string `json:"url"`
+	// BearerToken is the optional token sent in the Authorization header.
+	BearerToken string `json:"bearerToken,omitempty"`
+	// Timeout is the timeout of the request, it defaults to 10s.
+	Timeout *Duration `json:"timeout,omitempty"`
+}
+
+// SCEPChallengeWebhookRequest is the body of the request sent to the
+// challenge webhook.
+type SCEPChallengeWebhookRequest struct {
+	Provisioner string `json:"provisioner"`
+	Challenge   string `json:"challenge"`
+	CSR         string `json:"csr"`
+}
+
+// SCEPChallengeWebhookResponse is the body of the response expected from the
+// challenge webhook.
+type SCEPChallengeWebhookResponse struct {
+	Allow bool `json:"allow"`
+}
+
 // SCEP is the SCEP provisioner type, an entity that can authorize the
 // SCEP provisioning flow
 type SCEP struct {
@@ -18,10 +68,28 @@ type SCEP struct {
 	ChallengePassword string   `json:"challenge,omitempty"`
 	Capabilities      []string `json:"capabilities,omitempty"`
 
+	// ChallengeType is the method used to validate the challenge password:
+	// "static" (default), "one-time" or "webhook".
+	ChallengeType string `json:"challengeType,omitempty"`
+
+	// ChallengeWebhook is the webhook used to validate the challenge
+	// password and the CSR if the challenge type is "webhook".
+	ChallengeWebhook *SCEPChallengeWebhookOptions `json:"challengeWebhook,omitempty"`
+
 	// IncludeRoot makes the provisioner return the CA root in addition to the
 	// intermediate in the GetCACerts response
 	IncludeRoot bool `json:"includeRoot,omitempty"`
 
+	// RequireApproval queues the enrollment requests authorized with a
+	// challenge until an admin approves them. The clients get a pending
+	// response, and collect the certificate using CertPoll.
+	RequireApproval bool `json:"requireApproval,omitempty"`
+
+	// RenewalWindow is the period before the expiration of a certificate in
+	// which it can be used to authenticate a RenewalReq without a challenge.
+	// If it's not set, a certificate can be used while it's valid.
+	RenewalWindow *Duration `json:"renewalWindow,omitempty"`
+
 	// MinimumPublicKeyLength is the minimum length for public keys in CSRs
 	MinimumPublicKeyLength int `json:"minimumPublicKeyLength,omitempty"`
 
@@ -34,6 +102,7 @@ type SCEP struct {
 	Claims                  *Claims  `json:"claims,omitempty"`
 	secretChallengePassword string
 	encryptionAlgorithm     int
+	webhookClient           *http.Client
 	ctl                     *Controller
 }
 
@@ -76,6 +145,11 @@ func (s *SCEP) GetOptions() *Options {
 	return s.Options
 }
 
+// GetPolicy returns the name policy engine of the provisioner.
+func (s *SCEP) GetPolicy() *policy.Engine {
+	return s.ctl.GetPolicy()
+}
+
 // DefaultTLSCertDuration returns the default TLS cert duration enforced by
 // the provisioner.
 func (s *SCEP) DefaultTLSCertDuration() time.Duration {
@@ -92,6 +166,28 @@ func (s *SCEP) Init(config Config) (err error) {
 		return errors.New("provisioner name cannot be empty")
 	}
 
+	switch s.ChallengeType {
+	case "", SCEPChallengeStatic, SCEPChallengeOneTime:
+	case SCEPChallengeWebhook:
+		if s.ChallengeWebhook == nil || s.ChallengeWebhook.URL == "" {
+			return errors.New("challengeWebhook url cannot be empty")
+		}
+		if _, err := url.ParseRequestURI(s.ChallengeWebhook.URL); err != nil {
+			return errors.Wrap(err, "error parsing challengeWebhook url")
+		}
+		timeout := defaultSCEPWebhookTimeout
+		if s.ChallengeWebhook.Timeout != nil && s.ChallengeWebhook.Timeout.Duration > 0 {
+			timeout = s.ChallengeWebhook.Timeout.Duration
+		}
+		s.webhookClient = &http.Client{Timeout: timeout}
+	default:
+		return errors.Errorf("unsupported challengeType %s", s.ChallengeType)
+	}
+
+	if s.RenewalWindow != nil && s.RenewalWindow.Duration < 0 {
+		return errors.New("renewalWindow cannot be negative")
+	}
+
 	// Mask the actual challenge value, so it won't be marshaled
 	s.secretChallengePassword = s.ChallengePassword
 	s.ChallengePassword = "*** redacted ***"
@@ -132,11 +228,89 @@ func (s *SCEP) AuthorizeSign(ctx context.Context, token string) ([]SignOption, e
 	}, nil
 }
 
+// AuthorizeRenew returns an error if the given certificate, signer of a
+// RenewalReq, cannot be used to authenticate the renewal. The certificate must
+// have been issued by this provisioner, the renewal must be enabled, and the
+// certificate must be valid and in the renewal window.
+func (s *SCEP) AuthorizeRenew(ctx context.Context, cert *x509.Certificate) error {
+	ext, ok := GetProvisionerExtension(cert)
+	if !ok || ext.Type != TypeSCEP || ext.Name != s.Name {
+		return errs.Unauthorized("certificate was not issued by provisioner '%s'", s.Name)
+	}
+	if err := s.ctl.AuthorizeRenew(ctx, cert); err != nil {
+		return err
+	}
+	if s.RenewalWindow != nil && s.RenewalWindow.Duration > 0 {
+		if time.Now().Before(cert.NotAfter.Add(-s.RenewalWindow.Duration)) {
+			return errs.Unauthorized("certificate is not in the renewal window")
+		}
+	}
+	return nil
+}
+
 // GetChallengePassword returns the challenge password
 func (s *SCEP) GetChallengePassword() string {
 	return s.secretChallengePassword
 }
 
+// GetChallengeType returns the method used to validate the challenge
+// password.
+func (s *SCEP) GetChallengeType() string {
+	if s.ChallengeType == "" {
+		return SCEPChallengeStatic
+	}
+	return s.ChallengeType
+}
+
+// ValidateChallengeWebhook sends the challenge and the CSR to the configured
+// webhook, and returns true if the webhook allows the enrollment.
+func (s *SCEP) ValidateChallengeWebhook(ctx context.Context, csr *x509.CertificateRequest, challenge string) (bool, error) {
+	if s.webhookClient == nil {
+		return false, errors.New("scep challenge webhook is not configured")
+	}
+
+	body, err := json.Marshal(SCEPChallengeWebhookRequest{
+		Provisioner: s.Name,
+		Challenge:   challenge,
+		CSR: string(pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE REQUEST",
+			Bytes: csr.Raw,
+		})),
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "error marshaling webhook request")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.ChallengeWebhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, errors.Wrap(err, "error creating webhook request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.ChallengeWebhook.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.ChallengeWebhook.BearerToken)
+	}
+
+	resp, err := s.webhookClient.Do(req)
+	if err != nil {
+		return false, errors.Wrap(err, "error calling webhook")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, errors.Errorf("error calling webhook: status code %d", resp.StatusCode)
+	}
+
+	var whr SCEPChallengeWebhookResponse
+	if err := json.NewDecoder(resp.Body).Decode(&whr); err != nil {
+		return false, errors.Wrap(err, "error decoding webhook response")
+	}
+	return whr.Allow, nil
+}
+
+// ShouldRequireApproval indicates if the enrollment requests must be approved
+// by an admin before the certificate is issued.
+func (s *SCEP) ShouldRequireApproval() bool {
+	return s.RequireApproval
+}
+
 // GetCapabilities returns the CA capabilities
 func (s *SCEP) GetCapabilities() []string {
 	return s.Capabilities
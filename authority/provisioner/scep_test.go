@@ -0,0 +1,124 @@
+package provisioner
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/api/render"
+)
+
+func TestSCEP_AuthorizeRenew(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	newCert := func(t *testing.T, typ Type, name string, notBefore, notAfter time.Time) *x509.Certificate {
+		cert := &x509.Certificate{
+			NotBefore: notBefore,
+			NotAfter:  notAfter,
+		}
+		if name != "" {
+			ext, err := (&Extension{Type: typ, Name: name}).ToExtension()
+			assert.FatalError(t, err)
+			cert.Extensions = []pkix.Extension{ext}
+		}
+		return cert
+	}
+	newSCEP := func(t *testing.T, claims *Claims, renewalWindow *Duration) *SCEP {
+		p := &SCEP{
+			Type:          "SCEP",
+			Name:          "scep",
+			Claims:        claims,
+			RenewalWindow: renewalWindow,
+		}
+		assert.FatalError(t, p.Init(Config{Claims: globalProvisionerClaims, Audiences: testAudiences}))
+		return p
+	}
+
+	disable := true
+	allow := true
+	type test struct {
+		p    *SCEP
+		cert *x509.Certificate
+		err  string
+	}
+	tests := map[string]func(*testing.T) test{
+		"fail/no-extension": func(t *testing.T) test {
+			return test{
+				p:    newSCEP(t, nil, nil),
+				cert: newCert(t, TypeSCEP, "", now, now.Add(time.Hour)),
+				err:  "certificate was not issued by provisioner 'scep'",
+			}
+		},
+		"fail/wrong-provisioner-type": func(t *testing.T) test {
+			return test{
+				p:    newSCEP(t, nil, nil),
+				cert: newCert(t, TypeJWK, "scep", now, now.Add(time.Hour)),
+				err:  "certificate was not issued by provisioner 'scep'",
+			}
+		},
+		"fail/wrong-provisioner-name": func(t *testing.T) test {
+			return test{
+				p:    newSCEP(t, nil, nil),
+				cert: newCert(t, TypeSCEP, "other", now, now.Add(time.Hour)),
+				err:  "certificate was not issued by provisioner 'scep'",
+			}
+		},
+		"fail/renew-disabled": func(t *testing.T) test {
+			return test{
+				p:    newSCEP(t, &Claims{DisableRenewal: &disable}, nil),
+				cert: newCert(t, TypeSCEP, "scep", now, now.Add(time.Hour)),
+				err:  "renew is disabled for provisioner 'scep'",
+			}
+		},
+		"fail/expired": func(t *testing.T) test {
+			return test{
+				p:    newSCEP(t, nil, nil),
+				cert: newCert(t, TypeSCEP, "scep", now.Add(-2*time.Hour), now.Add(-time.Hour)),
+				err:  "certificate has expired",
+			}
+		},
+		"fail/outside-renewal-window": func(t *testing.T) test {
+			return test{
+				p:    newSCEP(t, nil, &Duration{Duration: time.Hour}),
+				cert: newCert(t, TypeSCEP, "scep", now, now.Add(24*time.Hour)),
+				err:  "certificate is not in the renewal window",
+			}
+		},
+		"ok": func(t *testing.T) test {
+			return test{
+				p:    newSCEP(t, nil, nil),
+				cert: newCert(t, TypeSCEP, "scep", now, now.Add(24*time.Hour)),
+			}
+		},
+		"ok/renewal-window": func(t *testing.T) test {
+			return test{
+				p:    newSCEP(t, nil, &Duration{Duration: time.Hour}),
+				cert: newCert(t, TypeSCEP, "scep", now.Add(-time.Hour), now.Add(30*time.Minute)),
+			}
+		},
+		"ok/expired-allowed": func(t *testing.T) test {
+			return test{
+				p:    newSCEP(t, &Claims{AllowRenewalAfterExpiry: &allow}, nil),
+				cert: newCert(t, TypeSCEP, "scep", now.Add(-2*time.Hour), now.Add(-time.Hour)),
+			}
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			tc := tt(t)
+			if err := tc.p.AuthorizeRenew(context.Background(), tc.cert); err != nil {
+				sc, ok := err.(render.StatusCodedError)
+				assert.Fatal(t, ok, "error does not implement StatusCodedError interface")
+				assert.Equals(t, http.StatusUnauthorized, sc.StatusCode())
+				if assert.NotEquals(t, "", tc.err) {
+					assert.HasPrefix(t, err.Error(), tc.err)
+				}
+			} else {
+				assert.Equals(t, "", tc.err)
+			}
+		})
+	}
+}
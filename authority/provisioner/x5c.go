@@ -9,6 +9,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/certificates/policy"
 	"go.step.sm/crypto/jose"
 	"go.step.sm/crypto/sshutil"
 	"go.step.sm/crypto/x509util"
@@ -84,6 +85,16 @@ func (p *X5C) GetEncryptedKey() (string, string, bool) {
 	return "", "", false
 }
 
+// GetOptions returns the configured provisioner options.
+func (p *X5C) GetOptions() *Options {
+	return p.Options
+}
+
+// GetPolicy returns the name policy engine of the provisioner.
+func (p *X5C) GetPolicy() *policy.Engine {
+	return p.ctl.GetPolicy()
+}
+
 // Init initializes and validates the fields of a X5C type.
 func (p *X5C) Init(config Config) (err error) {
 	switch {
@@ -138,9 +138,10 @@ func (a *Authority) generateProvisionerConfig(ctx context.Context) (provisioner.
 			UserKeys: sshKeys.UserKeys,
 			HostKeys: sshKeys.HostKeys,
 		},
-		GetIdentityFunc:       a.getIdentityFunc,
-		AuthorizeRenewFunc:    a.authorizeRenewFunc,
-		AuthorizeSSHRenewFunc: a.authorizeSSHRenewFunc,
+		GetIdentityFunc:                a.getIdentityFunc,
+		AuthorizeRenewFunc:             a.authorizeRenewFunc,
+		AuthorizeSSHRenewFunc:          a.authorizeSSHRenewFunc,
+		DisableCertificateTransparency: !a.isCertificateTransparencySupported(),
 	}, nil
 
 }
@@ -215,6 +216,11 @@ func (a *Authority) UpdateProvisioner(ctx context.Context, nu *linkedca.Provisio
 		return admin.WrapErrorISE(err, "error generating provisioner config")
 	}
 
+	// Keep the policy of the provisioner, it is managed separately.
+	if old, ok := a.provisioners.Load(nu.Id); ok {
+		setProvisionerPolicy(certProv, getProvisionerPolicy(old))
+	}
+
 	if err := certProv.Init(provisionerConfig); err != nil {
 		return admin.WrapErrorISE(err, "error initializing provisioner %s", nu.Name)
 	}
@@ -272,6 +278,10 @@ func (a *Authority) RemoveProvisioner(ctx context.Context, id string) error {
 		}
 		return admin.WrapErrorISE(err, "error deleting provisioner %s", provName)
 	}
+	// Remove the policy of the provisioner, if any.
+	if err := a.adminDB.DeleteProvisionerPolicy(ctx, provID); err != nil && !isPolicyNotFound(err) {
+		return admin.WrapErrorISE(err, "error deleting policy of provisioner %s", provName)
+	}
 	return nil
 }
 
@@ -0,0 +1,198 @@
+package authority
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/db"
+	"go.step.sm/crypto/randutil"
+)
+
+// DefaultSCEPChallengeDuration is the default lifetime of a one-time SCEP
+// challenge.
+const DefaultSCEPChallengeDuration = 24 * time.Hour
+
+type scepChallengeStore interface {
+	StoreSCEPChallenge(id string, sc *db.SCEPChallenge) error
+	UseSCEPChallenge(id string) (*db.SCEPChallenge, error)
+}
+
+// scepChallengeID returns the id used to store a one-time challenge.
+func scepChallengeID(provisionerName, challenge string) string {
+	sum := sha256.Sum256([]byte(provisionerName + "\x00" + challenge))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateSCEPChallenge creates a new one-time challenge for the SCEP provisioner
+// with the given name. If the subject is not empty, the challenge can only be
+// used to enroll a CSR with the same common name. It returns the challenge,
+// that is not stored in the database, and the stored challenge information.
+func (a *Authority) CreateSCEPChallenge(ctx context.Context, provisionerName, subject string, lifetime time.Duration) (string, *db.SCEPChallenge, error) {
+	store, ok := a.db.(scepChallengeStore)
+	if !ok {
+		return "", nil, admin.NewError(admin.ErrorNotImplementedType,
+			"the database does not support SCEP challenges")
+	}
+
+	p, err := a.LoadProvisionerByName(provisionerName)
+	if err != nil {
+		return "", nil, admin.WrapError(admin.ErrorNotFoundType, err,
+			"provisioner %s not found", provisionerName)
+	}
+	sp, ok := p.(*provisioner.SCEP)
+	if !ok || sp.GetChallengeType() != provisioner.SCEPChallengeOneTime {
+		return "", nil, admin.NewError(admin.ErrorBadRequestType,
+			"provisioner %s is not a SCEP provisioner with one-time challenges", provisionerName)
+	}
+
+	switch {
+	case lifetime == 0:
+		lifetime = DefaultSCEPChallengeDuration
+	case lifetime < 0:
+		return "", nil, admin.NewError(admin.ErrorBadRequestType,
+			"challenge lifetime cannot be negative")
+	}
+
+	challenge, err := randutil.Alphanumeric(32)
+	if err != nil {
+		return "", nil, admin.WrapErrorISE(err, "error generating challenge")
+	}
+	now := time.Now().UTC()
+	sc := &db.SCEPChallenge{
+		Provisioner: sp.GetName(),
+		Subject:     subject,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(lifetime),
+	}
+	if err := store.StoreSCEPChallenge(scepChallengeID(sp.GetName(), challenge), sc); err != nil {
+		return "", nil, admin.WrapErrorISE(err, "error storing challenge")
+	}
+	return challenge, sc, nil
+}
+
+// UseSCEPChallenge validates and consumes a one-time challenge of the SCEP
+// provisioner with the given name. The challenge cannot be used again, even
+// if it does not match the given CSR.
+func (a *Authority) UseSCEPChallenge(ctx context.Context, provisionerName, challenge string, csr *x509.CertificateRequest) error {
+	store, ok := a.db.(scepChallengeStore)
+	if !ok {
+		return errors.New("the database does not support SCEP challenges")
+	}
+	if challenge == "" {
+		return errors.New("challenge cannot be empty")
+	}
+
+	sc, err := store.UseSCEPChallenge(scepChallengeID(provisionerName, challenge))
+	if err != nil {
+		return err
+	}
+	switch {
+	case sc.Provisioner != provisionerName:
+		return errors.New("challenge does not belong to the provisioner")
+	case time.Now().After(sc.ExpiresAt):
+		return errors.New("challenge has expired")
+	case sc.Subject != "" && sc.Subject != csr.Subject.CommonName:
+		return errors.Errorf("challenge subject %s does not match the certificate request", sc.Subject)
+	default:
+		return nil
+	}
+}
+
+type scepRequestStore interface {
+	CreateSCEPRequest(req *db.SCEPRequest) error
+	GetSCEPRequest(provisionerName, id string) (*db.SCEPRequest, error)
+	UpdateSCEPRequest(req *db.SCEPRequest, fromStatus string) error
+	ListSCEPRequests(provisionerName string) ([]*db.SCEPRequest, error)
+}
+
+func (a *Authority) getSCEPRequestStore() (scepRequestStore, error) {
+	store, ok := a.db.(scepRequestStore)
+	if !ok {
+		return nil, admin.NewError(admin.ErrorNotImplementedType,
+			"the database does not support SCEP requests")
+	}
+	return store, nil
+}
+
+// CreateSCEPRequest queues a SCEP request for approval.
+func (a *Authority) CreateSCEPRequest(ctx context.Context, req *db.SCEPRequest) error {
+	store, err := a.getSCEPRequestStore()
+	if err != nil {
+		return err
+	}
+	return store.CreateSCEPRequest(req)
+}
+
+// GetSCEPRequest returns the SCEP request with the given provisioner and
+// transaction id.
+func (a *Authority) GetSCEPRequest(ctx context.Context, provisionerName, id string) (*db.SCEPRequest, error) {
+	store, err := a.getSCEPRequestStore()
+	if err != nil {
+		return nil, err
+	}
+	return store.GetSCEPRequest(provisionerName, id)
+}
+
+// UpdateSCEPRequest updates a SCEP request if its current status is the given
+// one.
+func (a *Authority) UpdateSCEPRequest(ctx context.Context, req *db.SCEPRequest, fromStatus string) error {
+	store, err := a.getSCEPRequestStore()
+	if err != nil {
+		return err
+	}
+	return store.UpdateSCEPRequest(req, fromStatus)
+}
+
+// GetSCEPRequests returns the SCEP requests of the given provisioner.
+func (a *Authority) GetSCEPRequests(ctx context.Context, provisionerName string) ([]*db.SCEPRequest, error) {
+	store, err := a.getSCEPRequestStore()
+	if err != nil {
+		return nil, err
+	}
+	reqs, err := store.ListSCEPRequests(provisionerName)
+	if err != nil {
+		return nil, admin.WrapErrorISE(err, "error listing scep requests")
+	}
+	return reqs, nil
+}
+
+// ReviewSCEPRequest approves or rejects a pending SCEP request. An approved
+// request is issued when the client polls for it.
+func (a *Authority) ReviewSCEPRequest(ctx context.Context, provisionerName, id string, approve bool) (*db.SCEPRequest, error) {
+	store, err := a.getSCEPRequestStore()
+	if err != nil {
+		return nil, err
+	}
+	req, err := store.GetSCEPRequest(provisionerName, id)
+	if err != nil {
+		if errors.Is(err, db.ErrSCEPRequestNotFound) {
+			return nil, admin.NewError(admin.ErrorNotFoundType,
+				"scep request %s not found", id)
+		}
+		return nil, admin.WrapErrorISE(err, "error loading scep request %s", id)
+	}
+	if req.Status != db.SCEPRequestPending {
+		return nil, admin.NewError(admin.ErrorBadRequestType,
+			"scep request %s is not pending", id)
+	}
+
+	req.Status = db.SCEPRequestRejected
+	if approve {
+		req.Status = db.SCEPRequestApproved
+	}
+	req.UpdatedAt = time.Now().UTC()
+	if err := store.UpdateSCEPRequest(req, db.SCEPRequestPending); err != nil {
+		if errors.Is(err, db.ErrSCEPRequestNotFound) {
+			return nil, admin.NewError(admin.ErrorBadRequestType,
+				"scep request %s is not pending", id)
+		}
+		return nil, admin.WrapErrorISE(err, "error updating scep request %s", id)
+	}
+	return req, nil
+}
@@ -0,0 +1,181 @@
+package authority
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/db"
+)
+
+func testSCEPChallengeAuthority(t *testing.T) *Authority {
+	t.Helper()
+	challenges := map[string]*db.SCEPChallenge{}
+	a := testAuthority(t, WithDatabase(&db.MockAuthDB{
+		MStoreSCEPChallenge: func(id string, sc *db.SCEPChallenge) error {
+			if _, ok := challenges[id]; ok {
+				return db.ErrAlreadyExists
+			}
+			challenges[id] = sc
+			return nil
+		},
+		MUseSCEPChallenge: func(id string) (*db.SCEPChallenge, error) {
+			sc, ok := challenges[id]
+			if !ok || sc.UsedAt != nil {
+				return nil, db.ErrSCEPChallengeNotFound
+			}
+			now := time.Now()
+			sc.UsedAt = &now
+			return sc, nil
+		},
+	}))
+	assert.FatalError(t, a.provisioners.Store(&provisioner.SCEP{
+		Type:          "SCEP",
+		Name:          "scep",
+		ChallengeType: provisioner.SCEPChallengeOneTime,
+	}))
+	assert.FatalError(t, a.provisioners.Store(&provisioner.SCEP{
+		Type: "SCEP",
+		Name: "scep-static",
+	}))
+	return a
+}
+
+func TestAuthority_CreateSCEPChallenge(t *testing.T) {
+	a := testSCEPChallengeAuthority(t)
+
+	tests := []struct {
+		name            string
+		provisionerName string
+		lifetime        time.Duration
+		wantLifetime    time.Duration
+		wantErr         bool
+	}{
+		{"ok", "scep", time.Hour, time.Hour, false},
+		{"ok default lifetime", "scep", 0, DefaultSCEPChallengeDuration, false},
+		{"fail negative lifetime", "scep", -time.Hour, 0, true},
+		{"fail static", "scep-static", 0, 0, true},
+		{"fail jwk", "Max", 0, 0, true},
+		{"fail not found", "missing", 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			challenge, sc, err := a.CreateSCEPChallenge(context.Background(), tt.provisionerName, "device-1", tt.lifetime)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Authority.CreateSCEPChallenge() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			assert.Len(t, 32, challenge)
+			assert.Equals(t, tt.provisionerName, sc.Provisioner)
+			assert.Equals(t, "device-1", sc.Subject)
+			assert.Equals(t, tt.wantLifetime, sc.ExpiresAt.Sub(sc.CreatedAt))
+		})
+	}
+}
+
+func TestAuthority_CreateSCEPChallenge_notImplemented(t *testing.T) {
+	a := testAuthority(t)
+	_, _, err := a.CreateSCEPChallenge(context.Background(), "scep", "", 0)
+	assert.Error(t, err)
+	assert.Error(t, a.UseSCEPChallenge(context.Background(), "scep", "challenge", &x509.CertificateRequest{}))
+}
+
+func TestAuthority_UseSCEPChallenge(t *testing.T) {
+	a := testSCEPChallengeAuthority(t)
+	csr := &x509.CertificateRequest{Subject: pkix.Name{CommonName: "device-1"}}
+	ctx := context.Background()
+
+	challenge, _, err := a.CreateSCEPChallenge(ctx, "scep", "device-1", time.Hour)
+	assert.FatalError(t, err)
+	anySubject, _, err := a.CreateSCEPChallenge(ctx, "scep", "", time.Hour)
+	assert.FatalError(t, err)
+	otherSubject, _, err := a.CreateSCEPChallenge(ctx, "scep", "device-2", time.Hour)
+	assert.FatalError(t, err)
+	expired, sc, err := a.CreateSCEPChallenge(ctx, "scep", "device-1", time.Hour)
+	assert.FatalError(t, err)
+	sc.ExpiresAt = time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name            string
+		provisionerName string
+		challenge       string
+		wantErr         bool
+	}{
+		{"fail other provisioner", "scep-static", challenge, true},
+		{"ok", "scep", challenge, false},
+		{"fail reused", "scep", challenge, true},
+		{"ok any subject", "scep", anySubject, false},
+		{"fail subject", "scep", otherSubject, true},
+		{"fail expired", "scep", expired, true},
+		{"fail unknown", "scep", "foobar", true},
+		{"fail empty", "scep", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := a.UseSCEPChallenge(ctx, tt.provisionerName, tt.challenge, csr); (err != nil) != tt.wantErr {
+				t.Errorf("Authority.UseSCEPChallenge() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthority_ReviewSCEPRequest(t *testing.T) {
+	requests := map[string]*db.SCEPRequest{
+		"scep/pending":  {ID: "pending", Provisioner: "scep", Status: db.SCEPRequestPending},
+		"scep/rejected": {ID: "rejected", Provisioner: "scep", Status: db.SCEPRequestPending},
+		"scep/issued":   {ID: "issued", Provisioner: "scep", Status: db.SCEPRequestIssued},
+	}
+	a := testAuthority(t, WithDatabase(&db.MockAuthDB{
+		MGetSCEPRequest: func(provisionerName, id string) (*db.SCEPRequest, error) {
+			req, ok := requests[provisionerName+"/"+id]
+			if !ok {
+				return nil, db.ErrSCEPRequestNotFound
+			}
+			v := *req
+			return &v, nil
+		},
+		MUpdateSCEPRequest: func(req *db.SCEPRequest, fromStatus string) error {
+			key := req.Provisioner + "/" + req.ID
+			if requests[key].Status != fromStatus {
+				return db.ErrSCEPRequestNotFound
+			}
+			requests[key] = req
+			return nil
+		},
+	}))
+
+	tests := []struct {
+		name       string
+		id         string
+		approve    bool
+		wantStatus string
+		wantErr    bool
+	}{
+		{"ok approve", "pending", true, db.SCEPRequestApproved, false},
+		{"fail approved", "pending", true, "", true},
+		{"ok reject", "rejected", false, db.SCEPRequestRejected, false},
+		{"fail issued", "issued", true, "", true},
+		{"fail not found", "missing", true, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := a.ReviewSCEPRequest(context.Background(), "scep", tt.id, tt.approve)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Authority.ReviewSCEPRequest() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			assert.Equals(t, tt.wantStatus, got.Status)
+			assert.Equals(t, tt.wantStatus, requests["scep/"+tt.id].Status)
+		})
+	}
+}
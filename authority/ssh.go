@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"crypto/x509"
 	"encoding/binary"
+	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -84,9 +85,27 @@ func (a *Authority) GetSSHConfig(ctx context.Context, typ string, data map[strin
 		}
 	}
 
+	// Add the current KRL to the host templates. If the KRL cannot be
+	// generated, the template with the revoked keys is skipped, and the
+	// default sshd_config template does not set RevokedKeys, as sshd refuses
+	// all the certificates if the file does not exist.
+	var skipRevokedKeys bool
+	if typ == provisioner.SSHHostCert {
+		if krl, err := a.GetSSHRevocationList(ctx); err != nil {
+			log.Printf("error generating the ssh revocation list: %v", err)
+			skipRevokedKeys = true
+		} else {
+			mergedData = withSSHRevocationList(mergedData, krl)
+		}
+	}
+
 	// Render templates
 	output := []templates.Output{}
 	for _, t := range ts {
+		if skipRevokedKeys && strings.EqualFold(t.Name, sshRevokedKeysTemplate) {
+			continue
+		}
+
 		if err := t.Load(); err != nil {
 			return nil, err
 		}
@@ -114,6 +133,28 @@ func (a *Authority) GetSSHConfig(ctx context.Context, typ string, data map[strin
 	return output, nil
 }
 
+// sshRevokedKeysTemplate is the name of the host template with the KRL.
+const sshRevokedKeysTemplate = "revoked_keys.tpl"
+
+// withSSHRevocationList returns a copy of the template data with the given KRL
+// in the Step variables.
+func withSSHRevocationList(data map[string]interface{}, krl []byte) map[string]interface{} {
+	ret := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		ret[k] = v
+	}
+	switch step := data["Step"].(type) {
+	case templates.Step:
+		step.SSH.KRL = krl
+		ret["Step"] = step
+	case *templates.Step:
+		s := *step
+		s.SSH.KRL = krl
+		ret["Step"] = &s
+	}
+	return ret
+}
+
 // GetSSHBastion returns the bastion configuration, for the given pair user,
 // hostname.
 func (a *Authority) GetSSHBastion(ctx context.Context, user, hostname string) (*config.Bastion, error) {
@@ -143,6 +184,7 @@ func (a *Authority) GetSSHBastion(ctx context.Context, user, hostname string) (*
 // SignSSH creates a signed SSH certificate with the given public key and options.
 func (a *Authority) SignSSH(ctx context.Context, key ssh.PublicKey, opts provisioner.SignSSHOptions, signOpts ...provisioner.SignOption) (*ssh.Certificate, error) {
 	var (
+		prov        provisioner.Interface
 		certOptions []sshutil.Option
 		mods        []provisioner.SSHCertModifier
 		validators  []provisioner.SSHCertValidator
@@ -158,6 +200,10 @@ func (a *Authority) SignSSH(ctx context.Context, key ssh.PublicKey, opts provisi
 
 	for _, op := range signOpts {
 		switch o := op.(type) {
+		// capture current provisioner
+		case provisioner.Interface:
+			prov = o
+
 		// add options to NewCertificate
 		case provisioner.SSHCertificateOptions:
 			certOptions = append(certOptions, o.Options(opts)...)
@@ -224,6 +270,12 @@ func (a *Authority) SignSSH(ctx context.Context, key ssh.PublicKey, opts provisi
 		}
 	}
 
+	// Check the name policies after the template and modifiers have been
+	// applied.
+	if err := a.isSSHCertificateAllowed(prov, certTpl); err != nil {
+		return nil, err
+	}
+
 	// Get signer from authority keys
 	var signer ssh.Signer
 	switch certTpl.CertType {
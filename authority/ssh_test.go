@@ -1,6 +1,7 @@
 package authority
 
 import (
+	"bytes"
 	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
@@ -609,6 +610,74 @@ func TestAuthority_GetSSHConfig(t *testing.T) {
 	}
 }
 
+func TestAuthority_GetSSHConfig_revokedKeys(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	signer, err := ssh.NewSignerFromSigner(key)
+	assert.FatalError(t, err)
+	userB64 := base64.StdEncoding.EncodeToString(signer.PublicKey().Marshal())
+
+	tmplConfig := &templates.Templates{
+		SSH: &templates.SSHTemplates{
+			Host: []templates.Template{
+				{Name: "sshd_config.tpl", Type: templates.Snippet, Content: []byte(templates.DefaultSSHTemplateData["sshd_config.tpl"]), Path: "/etc/ssh/sshd_config", Comment: "#", RequiredData: []string{"Certificate", "Key"}},
+				{Name: "ca.tpl", Type: templates.File, TemplatePath: "./testdata/templates/ca.tpl", Path: "/etc/ssh/ca.pub", Comment: "#"},
+				{Name: "revoked_keys.tpl", Type: templates.File, Content: []byte(templates.DefaultSSHTemplateData["revoked_keys.tpl"]), Path: "/etc/ssh/revoked_keys", Comment: "#"},
+			},
+		},
+		Data: map[string]interface{}{
+			"Step": &templates.Step{
+				SSH: templates.StepSSH{
+					UserKey: signer.PublicKey(),
+					HostKey: signer.PublicKey(),
+				},
+			},
+		},
+	}
+	caOutput := templates.Output{Name: "ca.tpl", Type: templates.File, Comment: "#", Path: "/etc/ssh/ca.pub", Content: []byte(signer.PublicKey().Type() + " " + userB64)}
+	sshdConfig := "Match all\n\tTrustedUserCAKeys /etc/ssh/ca.pub\n\tHostCertificate /etc/ssh/host.crt\n\tHostKey /etc/ssh/host.key"
+	sshdOutput := func(content string) templates.Output {
+		return templates.Output{Name: "sshd_config.tpl", Type: templates.Snippet, Comment: "#", Path: "/etc/ssh/sshd_config", Content: []byte(content)}
+	}
+	data := map[string]string{"Certificate": "host.crt", "Key": "host.key"}
+
+	tests := []struct {
+		name    string
+		db      db.AuthDB
+		wantKRL bool
+	}{
+		{"ok", &db.MockAuthDB{
+			MGetRevokedSSHCertificates: func() ([]db.RevokedCertificateInfo, error) {
+				return []db.RevokedCertificateInfo{{Serial: "1", RevokedAt: time.Now()}}, nil
+			},
+		}, true},
+		{"ok skip revoked keys", &db.MockAuthDB{
+			Err: errors.New("force"),
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := testAuthority(t, WithDatabase(tt.db))
+			a.templates = tmplConfig
+			a.sshCAUserCertSignKey = signer
+			a.sshCAHostCertSignKey = signer
+
+			got, err := a.GetSSHConfig(context.Background(), "host", data)
+			assert.FatalError(t, err)
+			if !tt.wantKRL {
+				assert.Equals(t, []templates.Output{sshdOutput(sshdConfig), caOutput}, got)
+				return
+			}
+			if assert.Len(t, 3, got) {
+				assert.Equals(t, sshdOutput(sshdConfig+"\n\tRevokedKeys /etc/ssh/revoked_keys"), got[0])
+				assert.Equals(t, caOutput, got[1])
+				assert.Equals(t, "revoked_keys.tpl", got[2].Name)
+				assert.True(t, bytes.HasPrefix(got[2].Content, []byte("SSHKRL\n\x00")))
+			}
+		})
+	}
+}
+
 func TestAuthority_CheckSSHHost(t *testing.T) {
 	type fields struct {
 		exists bool
@@ -3,13 +3,17 @@ package authority
 import (
 	"context"
 	"crypto"
+	"crypto/rand"
 	"crypto/tls"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/asn1"
 	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
+	"log"
+	"math/big"
 	"net"
 	"net/http"
 	"strings"
@@ -21,6 +25,8 @@ import (
 	casapi "github.com/smallstep/certificates/cas/apiv1"
 	"github.com/smallstep/certificates/db"
 	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/nosql/database"
+	"go.opentelemetry.io/otel/trace"
 	"go.step.sm/crypto/jose"
 	"go.step.sm/crypto/keyutil"
 	"go.step.sm/crypto/pemutil"
@@ -35,6 +41,7 @@ func (a *Authority) GetTLSOptions() *config.TLSOptions {
 
 var oidAuthorityKeyIdentifier = asn1.ObjectIdentifier{2, 5, 29, 35}
 var oidSubjectKeyIdentifier = asn1.ObjectIdentifier{2, 5, 29, 14}
+var oidCRLReasonCode = asn1.ObjectIdentifier{2, 5, 29, 21}
 
 func withDefaultASN1DN(def *config.ASN1DN) provisioner.CertificateModifierFunc {
 	return func(crt *x509.Certificate, opts provisioner.SignOptions) error {
@@ -69,8 +76,56 @@ func withDefaultASN1DN(def *config.ASN1DN) provisioner.CertificateModifierFunc {
 	}
 }
 
+// withCRLDistributionPoint sets the configured CRL distribution point in the
+// certificate if the template has not set one.
+func withCRLDistributionPoint(crl *config.CRLConfig) provisioner.CertificateModifierFunc {
+	return func(crt *x509.Certificate, opts provisioner.SignOptions) error {
+		if crl.IsEnabled() && crl.DistributionPoint != "" && len(crt.CRLDistributionPoints) == 0 {
+			crt.CRLDistributionPoints = []string{crl.DistributionPoint}
+		}
+		return nil
+	}
+}
+
+// withOCSPServer sets the configured OCSP responder url in the authority
+// information access extension of the certificate if the template has not set
+// one.
+func withOCSPServer(ocsp *config.OCSPConfig) provisioner.CertificateModifierFunc {
+	return func(crt *x509.Certificate, opts provisioner.SignOptions) error {
+		if ocsp.IsEnabled() && ocsp.URL != "" && len(crt.OCSPServer) == 0 {
+			crt.OCSPServer = []string{ocsp.URL}
+		}
+		return nil
+	}
+}
+
 // Sign creates a signed certificate from a certificate signing request.
 func (a *Authority) Sign(csr *x509.CertificateRequest, signOpts provisioner.SignOptions, extraOpts ...provisioner.SignOption) ([]*x509.Certificate, error) {
+	return a.SignWithContext(context.Background(), csr, signOpts, extraOpts...)
+}
+
+// SignWithContext creates a signed certificate from a certificate signing
+// request. The context is used to trace the request.
+func (a *Authority) SignWithContext(ctx context.Context, csr *x509.CertificateRequest, signOpts provisioner.SignOptions, extraOpts ...provisioner.SignOption) ([]*x509.Certificate, error) {
+	var prov provisioner.Interface
+	for _, op := range extraOpts {
+		if p, ok := op.(provisioner.Interface); ok {
+			prov = p
+			break
+		}
+	}
+
+	ctx, span := tracer.Start(ctx, "authority.Sign", trace.WithAttributes(provisionerAttributes(prov)...))
+	fullchain, err := a.sign(ctx, csr, signOpts, extraOpts...)
+	endSpan(span, err)
+
+	if a.meter != nil {
+		a.meter.X509Signed(prov, err)
+	}
+	return fullchain, err
+}
+
+func (a *Authority) sign(ctx context.Context, csr *x509.CertificateRequest, signOpts provisioner.SignOptions, extraOpts ...provisioner.SignOption) ([]*x509.Certificate, error) {
 	var (
 		certOptions    []x509util.Option
 		certValidators []provisioner.CertificateValidator
@@ -157,6 +212,22 @@ func (a *Authority) Sign(csr *x509.CertificateRequest, signOpts provisioner.Sign
 		)
 	}
 
+	// Set the CRL distribution point if configured
+	if err := withCRLDistributionPoint(a.config.CRL).Modify(leaf, signOpts); err != nil {
+		return nil, errs.ApplyOptions(
+			errs.ForbiddenErr(err, "error creating certificate"),
+			opts...,
+		)
+	}
+
+	// Set the OCSP responder url if configured
+	if err := withOCSPServer(a.config.OCSP).Modify(leaf, signOpts); err != nil {
+		return nil, errs.ApplyOptions(
+			errs.ForbiddenErr(err, "error creating certificate"),
+			opts...,
+		)
+	}
+
 	for _, m := range certModifiers {
 		if err := m.Modify(leaf, signOpts); err != nil {
 			return nil, errs.ApplyOptions(
@@ -196,20 +267,50 @@ func (a *Authority) Sign(csr *x509.CertificateRequest, signOpts provisioner.Sign
 		}
 	}
 
-	// Sign certificate
+	// Check the name policies after all the templates and modifiers have
+	// been applied.
+	if err := a.isX509CertificateAllowed(prov, leaf); err != nil {
+		return nil, errs.ApplyOptions(err, opts...)
+	}
+
 	lifetime := leaf.NotAfter.Sub(leaf.NotBefore.Add(signOpts.Backdate))
+
+	// Submit the precertificate to the Certificate Transparency logs and
+	// embed the SCTs in the certificate.
+	var precert *x509.Certificate
+	if ctOptions := getCertificateTransparencyOptions(prov); ctOptions.IsEnabled() {
+		ctCtx, span := tracer.Start(ctx, "ct.Submit")
+		precert, err = a.submitPrecertificate(ctCtx, leaf, csr, lifetime, signOpts.Backdate, ctOptions)
+		endSpan(span, err)
+		if err != nil {
+			return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.Sign; error submitting precertificate", opts...)
+		}
+	}
+
+	// Sign certificate
+	casCtx, span := tracer.Start(ctx, "cas.CreateCertificate")
 	resp, err := a.x509CAService.CreateCertificate(&casapi.CreateCertificateRequest{
 		Template: leaf,
 		CSR:      csr,
 		Lifetime: lifetime,
 		Backdate: signOpts.Backdate,
+		Context:  casCtx,
 	})
+	endSpan(span, err)
 	if err != nil {
 		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.Sign; error creating certificate", opts...)
 	}
+	if precert != nil {
+		if err := checkPrecertificate(resp.Certificate, precert); err != nil {
+			return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.Sign; error creating certificate", opts...)
+		}
+	}
 
 	fullchain := append([]*x509.Certificate{resp.Certificate}, resp.CertificateChain...)
-	if err = a.storeCertificate(prov, fullchain); err != nil {
+	_, span = tracer.Start(ctx, "db.StoreCertificate")
+	err = a.storeCertificate(prov, fullchain)
+	endSpan(span, err)
+	if err != nil {
 		if err != db.ErrNotImplemented {
 			return nil, errs.Wrap(http.StatusInternalServerError, err,
 				"authority.Sign; error storing certificate in db", opts...)
@@ -235,6 +336,28 @@ func (a *Authority) Renew(oldCert *x509.Certificate) ([]*x509.Certificate, error
 // 'NotBefore/NotAfter' (the validity duration of the new certificate should be
 // equal to the old one, but starting 'now').
 func (a *Authority) Rekey(oldCert *x509.Certificate, pk crypto.PublicKey) ([]*x509.Certificate, error) {
+	return a.rekeyWithMeter(oldCert, pk, nil)
+}
+
+// RekeyWithCSR is like Rekey, but it also sends the certificate request with
+// the new key to the CAS. Some CAS, like Vault, can only sign certificate
+// requests.
+func (a *Authority) RekeyWithCSR(oldCert *x509.Certificate, csr *x509.CertificateRequest) ([]*x509.Certificate, error) {
+	return a.rekeyWithMeter(oldCert, csr.PublicKey, csr)
+}
+
+func (a *Authority) rekeyWithMeter(oldCert *x509.Certificate, pk crypto.PublicKey, csr *x509.CertificateRequest) ([]*x509.Certificate, error) {
+	fullchain, err := a.rekey(oldCert, pk, csr)
+	if a.meter != nil {
+		// The provisioner is not required, the certificate might have been
+		// issued by a provisioner that does not exist anymore.
+		prov, _ := a.LoadProvisionerByCertificate(oldCert)
+		a.meter.X509Renewed(prov, err)
+	}
+	return fullchain, err
+}
+
+func (a *Authority) rekey(oldCert *x509.Certificate, pk crypto.PublicKey, csr *x509.CertificateRequest) ([]*x509.Certificate, error) {
 	isRekey := (pk != nil)
 	opts := []interface{}{errs.WithKeyVal("serialNumber", oldCert.SerialNumber.String())}
 
@@ -307,6 +430,7 @@ func (a *Authority) Rekey(oldCert *x509.Certificate, pk crypto.PublicKey) ([]*x5
 
 	resp, err := a.x509CAService.RenewCertificate(&casapi.RenewCertificateRequest{
 		Template: newCert,
+		CSR:      csr,
 		Lifetime: lifetime,
 		Backdate: backdate,
 	})
@@ -378,6 +502,7 @@ func (a *Authority) storeRenewedCertificate(oldCert *x509.Certificate, fullchain
 // RevokeOptions are the options for the Revoke API.
 type RevokeOptions struct {
 	Serial      string
+	KeyID       string
 	Reason      string
 	ReasonCode  int
 	PassiveOnly bool
@@ -390,10 +515,10 @@ type RevokeOptions struct {
 // Revoke revokes a certificate.
 //
 // NOTE: Only supports passive revocation - prevent existing certificates from
-// being renewed.
-//
-// TODO: Add OCSP and CRL support.
-func (a *Authority) Revoke(ctx context.Context, revokeOpts *RevokeOptions) error {
+// being renewed. If the CRL is enabled, revoked certificates will also be
+// included in it, and if the OCSP responder is enabled, it will report them as
+// revoked.
+func (a *Authority) Revoke(ctx context.Context, revokeOpts *RevokeOptions) (err error) {
 	opts := []interface{}{
 		errs.WithKeyVal("serialNumber", revokeOpts.Serial),
 		errs.WithKeyVal("reasonCode", revokeOpts.ReasonCode),
@@ -418,10 +543,13 @@ func (a *Authority) Revoke(ctx context.Context, revokeOpts *RevokeOptions) error
 		RevokedAt:  time.Now().UTC(),
 	}
 
-	var (
-		p   provisioner.Interface
-		err error
-	)
+	var p provisioner.Interface
+	if a.meter != nil && provisioner.MethodFromContext(ctx) != provisioner.SSHRevokeMethod {
+		defer func() {
+			a.meter.X509Revoked(p, err)
+		}()
+	}
+
 	// If not mTLS nor ACME, then get the TokenID of the token.
 	if !(revokeOpts.MTLS || revokeOpts.ACME) {
 		token, err := jose.ParseSigned(revokeOpts.OTT)
@@ -458,7 +586,28 @@ func (a *Authority) Revoke(ctx context.Context, revokeOpts *RevokeOptions) error
 	}
 
 	if provisioner.MethodFromContext(ctx) == provisioner.SSHRevokeMethod {
-		err = a.revokeSSH(nil, rci)
+		// The expiration is used to remove expired certificates from the KRL.
+		var revokedCert *ssh.Certificate
+		if g, ok := a.db.(sshCertificateGetter); ok {
+			revokedCert, _ = g.GetSSHCertificate(rci.Serial)
+		}
+		if revokedCert != nil && revokedCert.ValidBefore != ssh.CertTimeInfinity {
+			rci.ExpiresAt = time.Unix(int64(revokedCert.ValidBefore), 0).UTC()
+		}
+
+		// Revoking the key id revokes all the certificates with it, so it must
+		// match the key id of the revoked certificate.
+		if revokeOpts.KeyID != "" {
+			if revokedCert == nil || revokedCert.KeyId != revokeOpts.KeyID {
+				return errs.ApplyOptions(
+					errs.BadRequest("key id '%s' does not match the ssh certificate with serial number '%s'", revokeOpts.KeyID, rci.Serial),
+					opts...,
+				)
+			}
+			rci.KeyID = revokeOpts.KeyID
+		}
+
+		err = a.revokeSSH(revokedCert, rci)
 	} else {
 		// Revoke an X.509 certificate using CAS. If the certificate is not
 		// provided we will try to read it from the db. If the read fails we
@@ -471,6 +620,11 @@ func (a *Authority) Revoke(ctx context.Context, revokeOpts *RevokeOptions) error
 			revokedCert, _ = a.db.GetCertificate(rci.Serial)
 		}
 
+		// The expiration is used to remove expired certificates from the CRL.
+		if revokedCert != nil {
+			rci.ExpiresAt = revokedCert.NotAfter
+		}
+
 		// CAS operation, note that SoftCAS (default) is a noop.
 		// The revoke happens when this is stored in the db.
 		_, err = a.x509CAService.RevokeCertificate(&casapi.RevokeCertificateRequest{
@@ -486,6 +640,16 @@ func (a *Authority) Revoke(ctx context.Context, revokeOpts *RevokeOptions) error
 
 		// Save as revoked in the Db.
 		err = a.revoke(revokedCert, rci)
+
+		// Generate a new CRL so CRL requesters will always get an up-to-date
+		// CRL whenever they request it. The certificate is already revoked,
+		// so a failure is only logged, and the CRL will be updated on the
+		// next regeneration.
+		if err == nil && a.config.CRL.IsEnabled() {
+			if err := a.GenerateCertificateRevocationList(); err != nil {
+				log.Printf("error generating the CRL after revoking %s: %v", rci.Serial, err)
+			}
+		}
 	}
 	switch err {
 	case nil:
@@ -506,18 +670,219 @@ func (a *Authority) revoke(crt *x509.Certificate, rci *db.RevokedCertificateInfo
 	if lca, ok := a.adminDB.(interface {
 		Revoke(*x509.Certificate, *db.RevokedCertificateInfo) error
 	}); ok {
-		return lca.Revoke(crt, rci)
+		if err := lca.Revoke(crt, rci); err != nil {
+			return err
+		}
+		// The CRL is generated from the local revocation table, so a copy of
+		// the revocation is also stored in the db.
+		if err := a.db.Revoke(rci); err != nil && err != db.ErrNotImplemented && err != db.ErrAlreadyExists {
+			log.Printf("error storing the revocation of %s: %v", rci.Serial, err)
+		}
+		return nil
 	}
 	return a.db.Revoke(rci)
 }
 
+type sshCertificateGetter interface {
+	GetSSHCertificate(serial string) (*ssh.Certificate, error)
+}
+
 func (a *Authority) revokeSSH(crt *ssh.Certificate, rci *db.RevokedCertificateInfo) error {
 	if lca, ok := a.adminDB.(interface {
 		RevokeSSH(*ssh.Certificate, *db.RevokedCertificateInfo) error
 	}); ok {
-		return lca.RevokeSSH(crt, rci)
+		if err := lca.RevokeSSH(crt, rci); err != nil {
+			return err
+		}
+		// The KRL is generated from the local revocation table, so a copy of
+		// the revocation is also stored in the db.
+		if err := a.db.RevokeSSH(rci); err != nil && err != db.ErrNotImplemented && err != db.ErrAlreadyExists {
+			log.Printf("error storing the revocation of %s: %v", rci.Serial, err)
+		}
+		return nil
+	}
+	return a.db.RevokeSSH(rci)
+}
+
+// GetCertificateRevocationList will return the currently generated CRL from
+// the DB, or a not implemented error if the underlying AuthDB does not
+// support CRLs.
+func (a *Authority) GetCertificateRevocationList() ([]byte, error) {
+	if !a.config.CRL.IsEnabled() {
+		return nil, errs.NotFound("authority.GetCertificateRevocationList; certificate revocation lists are not enabled")
+	}
+
+	// Serve the CRL of the CAS if it maintains its own.
+	if crlGetter, ok := a.x509CAService.(casapi.CertificateAuthorityCRLGetter); ok {
+		resp, err := crlGetter.GetCertificateRevocationList(&casapi.GetCertificateRevocationListRequest{})
+		if err != nil {
+			return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.GetCertificateRevocationList")
+		}
+		return resp.CRL, nil
+	}
+
+	crlInfo, err := a.db.GetCRL()
+	switch {
+	case err == db.ErrNotImplemented:
+		return nil, errs.NotImplemented("authority.GetCertificateRevocationList; no persistence layer configured")
+	case database.IsErrNotFound(err):
+		// The CRL has not been generated yet.
+		if err := a.GenerateCertificateRevocationList(); err != nil {
+			return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.GetCertificateRevocationList")
+		}
+		if crlInfo, err = a.db.GetCRL(); err != nil {
+			return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.GetCertificateRevocationList")
+		}
+	case err != nil:
+		return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.GetCertificateRevocationList")
+	}
+
+	return crlInfo.DER, nil
+}
+
+// GenerateCertificateRevocationList generates a DER representation of a
+// signed CRL and stores it in the database. The CRL includes all the revoked
+// certificates that have not expired yet.
+func (a *Authority) GenerateCertificateRevocationList() error {
+	if !a.config.CRL.IsEnabled() {
+		return errors.New("cannot generate a CRL because it is disabled")
+	}
+
+	// There is nothing to generate if the CAS maintains its own CRL.
+	if _, ok := a.x509CAService.(casapi.CertificateAuthorityCRLGetter); ok {
+		return nil
+	}
+
+	crlGenerator, ok := a.x509CAService.(casapi.CertificateAuthorityCRLGenerator)
+	if !ok {
+		return errors.New("CA does not support CRL generation")
+	}
+
+	// use a mutex to ensure only one CRL is generated at a time to avoid
+	// concurrency issues
+	a.crlMutex.Lock()
+	defer a.crlMutex.Unlock()
+
+	revokedList, err := a.db.GetRevokedCertificates()
+	if err != nil {
+		return errors.Wrap(err, "error getting revoked certificates")
+	}
+
+	// Number is a monotonically increasing integer (essentially the CRL
+	// version number) that we need to keep track of and increase every time
+	// we generate a new CRL.
+	var crlNumber int64
+	crlInfo, err := a.db.GetCRL()
+	switch {
+	case err == nil:
+		crlNumber = crlInfo.Number + 1
+	case !database.IsErrNotFound(err):
+		return errors.Wrap(err, "error getting current CRL")
+	}
+
+	now := time.Now().Truncate(time.Second).UTC()
+	revokedCertificates := make([]pkix.RevokedCertificate, 0, len(revokedList))
+	for _, rci := range revokedList {
+		// Expired certificates do not need to be part of the CRL.
+		if !rci.ExpiresAt.IsZero() && rci.ExpiresAt.Before(now) {
+			continue
+		}
+		sn, ok := new(big.Int).SetString(rci.Serial, 10)
+		if !ok {
+			return errors.Errorf("error parsing serial number %s", rci.Serial)
+		}
+		rc := pkix.RevokedCertificate{
+			SerialNumber:   sn,
+			RevocationTime: rci.RevokedAt,
+		}
+		// The reason code should be absent if it's unspecified.
+		if rci.ReasonCode > 0 {
+			b, err := asn1.Marshal(asn1.Enumerated(rci.ReasonCode))
+			if err != nil {
+				return errors.Wrap(err, "error marshaling reason code")
+			}
+			rc.Extensions = []pkix.Extension{
+				{Id: oidCRLReasonCode, Value: b},
+			}
+		}
+		revokedCertificates = append(revokedCertificates, rc)
+	}
+
+	cacheDuration := a.config.CRL.CacheDuration.Duration
+	revocationList := &x509.RevocationList{
+		Number:              big.NewInt(crlNumber),
+		ThisUpdate:          now,
+		NextUpdate:          now.Add(cacheDuration),
+		RevokedCertificates: revokedCertificates,
+	}
+
+	resp, err := crlGenerator.CreateCRL(&casapi.CreateCRLRequest{
+		RevocationList: revocationList,
+	})
+	if err != nil {
+		return errors.Wrap(err, "error creating CRL")
+	}
+
+	if err := a.db.StoreCRL(&db.CertificateRevocationListInfo{
+		Number:    crlNumber,
+		ExpiresAt: revocationList.NextUpdate,
+		Duration:  cacheDuration,
+		DER:       resp.CRL,
+	}); err != nil {
+		return errors.Wrap(err, "error storing CRL")
+	}
+
+	// After an intermediate rotation, the certificates issued by the previous
+	// intermediate are checked against a CRL signed by it.
+	a.issuerMutex.RLock()
+	prev := a.prevX509Issuer
+	a.issuerMutex.RUnlock()
+	a.prevCRL = nil
+	if prev != nil {
+		list := *revocationList
+		list.SignatureAlgorithm = x509.UnknownSignatureAlgorithm
+		if sa, ok := prev.signer.(casapi.SignatureAlgorithmGetter); ok {
+			list.SignatureAlgorithm = sa.SignatureAlgorithm()
+		}
+		if a.prevCRL, err = x509.CreateRevocationList(rand.Reader, &list, prev.chain[0], prev.signer); err != nil {
+			return errors.Wrap(err, "error creating CRL of the previous intermediate")
+		}
+	}
+
+	return nil
+}
+
+// GetIssuerCertificateRevocationList returns the CRL of the intermediate with
+// the given SHA-256 fingerprint. After an intermediate rotation, it returns the
+// CRL signed by the previous intermediate, for the certificates it issued.
+func (a *Authority) GetIssuerCertificateRevocationList(fingerprint string) ([]byte, error) {
+	if !a.config.CRL.IsEnabled() {
+		return nil, errs.NotFound("authority.GetIssuerCertificateRevocationList; certificate revocation lists are not enabled")
+	}
+
+	a.issuerMutex.RLock()
+	current, prev := a.x509Issuer, a.prevX509Issuer
+	a.issuerMutex.RUnlock()
+
+	switch {
+	case current == nil || strings.EqualFold(fingerprint, x509util.Fingerprint(current.chain[0])):
+		return a.GetCertificateRevocationList()
+	case prev != nil && strings.EqualFold(fingerprint, x509util.Fingerprint(prev.chain[0])):
+		a.crlMutex.Lock()
+		crl := a.prevCRL
+		a.crlMutex.Unlock()
+		if crl == nil {
+			if err := a.GenerateCertificateRevocationList(); err != nil {
+				return nil, errs.Wrap(http.StatusInternalServerError, err, "authority.GetIssuerCertificateRevocationList")
+			}
+			a.crlMutex.Lock()
+			crl = a.prevCRL
+			a.crlMutex.Unlock()
+		}
+		return crl, nil
+	default:
+		return nil, errs.NotFound("authority.GetIssuerCertificateRevocationList; issuer %s was not found", fingerprint)
 	}
-	return a.db.Revoke(rci)
 }
 
 // GetTLSCertificate creates a new leaf certificate to be used by the CA HTTPS server.
@@ -24,13 +24,17 @@ import (
 	"go.step.sm/crypto/keyutil"
 	"go.step.sm/crypto/pemutil"
 	"go.step.sm/crypto/x509util"
+	"golang.org/x/crypto/ssh"
 
 	"github.com/smallstep/assert"
 	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority/admin"
+	"github.com/smallstep/certificates/authority/config"
 	"github.com/smallstep/certificates/authority/provisioner"
-	"github.com/smallstep/certificates/cas/softcas"
+	casapi "github.com/smallstep/certificates/cas/apiv1"
 	"github.com/smallstep/certificates/db"
 	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/nosql/database"
 )
 
 var (
@@ -58,11 +62,11 @@ func (m *certificateDurationEnforcer) Enforce(cert *x509.Certificate) error {
 }
 
 func getDefaultIssuer(a *Authority) *x509.Certificate {
-	return a.x509CAService.(*softcas.SoftCAS).CertificateChain[len(a.x509CAService.(*softcas.SoftCAS).CertificateChain)-1]
+	return a.x509Issuer.chain[len(a.x509Issuer.chain)-1]
 }
 
 func getDefaultSigner(a *Authority) crypto.Signer {
-	return a.x509CAService.(*softcas.SoftCAS).Signer
+	return a.x509Issuer.signer
 }
 
 func generateCertificate(t *testing.T, commonName string, sans []string, opts ...interface{}) *x509.Certificate {
@@ -301,7 +305,7 @@ func TestAuthority_Sign(t *testing.T) {
 		},
 		"fail create cert": func(t *testing.T) *signTest {
 			_a := testAuthority(t)
-			_a.x509CAService.(*softcas.SoftCAS).Signer = nil
+			_a.x509Issuer.signer = nil
 			csr := getCSR(t, priv)
 			return &signTest{
 				auth:      _a,
@@ -789,7 +793,7 @@ func TestAuthority_Renew(t *testing.T) {
 	tests := map[string]func() (*renewTest, error){
 		"fail/create-cert": func() (*renewTest, error) {
 			_a := testAuthority(t)
-			_a.x509CAService.(*softcas.SoftCAS).Signer = nil
+			_a.x509Issuer.signer = nil
 			return &renewTest{
 				auth: _a,
 				cert: cert,
@@ -828,8 +832,8 @@ func TestAuthority_Renew(t *testing.T) {
 			intCert, intSigner := generateIntermidiateCertificate(t, rootCert, rootSigner)
 
 			_a := testAuthority(t)
-			_a.x509CAService.(*softcas.SoftCAS).CertificateChain = []*x509.Certificate{intCert}
-			_a.x509CAService.(*softcas.SoftCAS).Signer = intSigner
+			_a.x509Issuer.chain = []*x509.Certificate{intCert}
+			_a.x509Issuer.signer = intSigner
 			return &renewTest{
 				auth: _a,
 				cert: cert,
@@ -1013,7 +1017,7 @@ func TestAuthority_Rekey(t *testing.T) {
 	tests := map[string]func() (*renewTest, error){
 		"fail/create-cert": func() (*renewTest, error) {
 			_a := testAuthority(t)
-			_a.x509CAService.(*softcas.SoftCAS).Signer = nil
+			_a.x509Issuer.signer = nil
 			return &renewTest{
 				auth: _a,
 				cert: cert,
@@ -1046,8 +1050,8 @@ func TestAuthority_Rekey(t *testing.T) {
 			intCert, intSigner := generateIntermidiateCertificate(t, rootCert, rootSigner)
 
 			_a := testAuthority(t)
-			_a.x509CAService.(*softcas.SoftCAS).CertificateChain = []*x509.Certificate{intCert}
-			_a.x509CAService.(*softcas.SoftCAS).Signer = intSigner
+			_a.x509Issuer.chain = []*x509.Certificate{intCert}
+			_a.x509Issuer.signer = intSigner
 			return &renewTest{
 				auth: _a,
 				cert: cert,
@@ -1434,6 +1438,136 @@ func TestAuthority_Revoke(t *testing.T) {
 				},
 			}
 		},
+		"ok/mTLS-generate-crl-error": func() test {
+			var revoked bool
+			_a := testAuthority(t, WithDatabase(&db.MockAuthDB{
+				MRevoke: func(rci *db.RevokedCertificateInfo) error {
+					revoked = true
+					return nil
+				},
+				MGetRevokedCertificates: func() ([]db.RevokedCertificateInfo, error) {
+					return nil, errors.New("force")
+				},
+			}))
+			_a.config.CRL = &config.CRLConfig{
+				Enabled:       true,
+				CacheDuration: &provisioner.Duration{Duration: time.Hour},
+			}
+			t.Cleanup(func() {
+				assert.True(t, revoked)
+			})
+
+			crt, err := pemutil.ReadCertificate("./testdata/certs/foo.crt")
+			assert.FatalError(t, err)
+
+			return test{
+				auth: _a,
+				opts: &RevokeOptions{
+					Crt:        crt,
+					Serial:     "102012593071130646873265215610956555026",
+					ReasonCode: reasonCode,
+					Reason:     reason,
+					MTLS:       true,
+				},
+			}
+		},
+		"ok/mTLS-generate-crl": func() test {
+			var storedCRL *db.CertificateRevocationListInfo
+			_a := testAuthority(t, WithDatabase(&db.MockAuthDB{
+				MGetRevokedCertificates: func() ([]db.RevokedCertificateInfo, error) {
+					return []db.RevokedCertificateInfo{
+						{Serial: "102012593071130646873265215610956555026", ReasonCode: reasonCode, RevokedAt: now},
+					}, nil
+				},
+				MGetCRL: func() (*db.CertificateRevocationListInfo, error) {
+					return nil, database.ErrNotFound
+				},
+				MStoreCRL: func(crlInfo *db.CertificateRevocationListInfo) error {
+					storedCRL = crlInfo
+					return nil
+				},
+			}))
+			_a.config.CRL = &config.CRLConfig{
+				Enabled:       true,
+				CacheDuration: &provisioner.Duration{Duration: time.Hour},
+			}
+			t.Cleanup(func() {
+				if assert.NotNil(t, storedCRL) {
+					crl, err := x509.ParseCRL(storedCRL.DER)
+					assert.FatalError(t, err)
+					assert.Len(t, 1, crl.TBSCertList.RevokedCertificates)
+				}
+			})
+
+			crt, err := pemutil.ReadCertificate("./testdata/certs/foo.crt")
+			assert.FatalError(t, err)
+
+			return test{
+				auth: _a,
+				opts: &RevokeOptions{
+					Crt:        crt,
+					Serial:     "102012593071130646873265215610956555026",
+					ReasonCode: reasonCode,
+					Reason:     reason,
+					MTLS:       true,
+				},
+			}
+		},
+		"ok/linkedca-generate-crl": func() test {
+			var revokedLinkedCA, revokedLocal bool
+			var storedCRL *db.CertificateRevocationListInfo
+			_a := testAuthority(t, WithDatabase(&db.MockAuthDB{
+				MRevoke: func(rci *db.RevokedCertificateInfo) error {
+					revokedLocal = true
+					return nil
+				},
+				MGetRevokedCertificates: func() ([]db.RevokedCertificateInfo, error) {
+					return []db.RevokedCertificateInfo{
+						{Serial: "102012593071130646873265215610956555026", ReasonCode: reasonCode, RevokedAt: now},
+					}, nil
+				},
+				MGetCRL: func() (*db.CertificateRevocationListInfo, error) {
+					return nil, database.ErrNotFound
+				},
+				MStoreCRL: func(crlInfo *db.CertificateRevocationListInfo) error {
+					storedCRL = crlInfo
+					return nil
+				},
+			}))
+			_a.adminDB = &mockLinkedCADB{
+				MRevoke: func(crt *x509.Certificate, rci *db.RevokedCertificateInfo) error {
+					revokedLinkedCA = true
+					return nil
+				},
+			}
+			_a.config.CRL = &config.CRLConfig{
+				Enabled:       true,
+				CacheDuration: &provisioner.Duration{Duration: time.Hour},
+			}
+			t.Cleanup(func() {
+				assert.True(t, revokedLinkedCA)
+				assert.True(t, revokedLocal)
+				if assert.NotNil(t, storedCRL) {
+					crl, err := x509.ParseCRL(storedCRL.DER)
+					assert.FatalError(t, err)
+					assert.Len(t, 1, crl.TBSCertList.RevokedCertificates)
+				}
+			})
+
+			crt, err := pemutil.ReadCertificate("./testdata/certs/foo.crt")
+			assert.FatalError(t, err)
+
+			return test{
+				auth: _a,
+				opts: &RevokeOptions{
+					Crt:        crt,
+					Serial:     "102012593071130646873265215610956555026",
+					ReasonCode: reasonCode,
+					Reason:     reason,
+					MTLS:       true,
+				},
+			}
+		},
 		"ok/ACME": func() test {
 			_a := testAuthority(t, WithDatabase(&db.MockAuthDB{}))
 
@@ -1481,3 +1615,321 @@ func TestAuthority_Revoke(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthority_Revoke_ssh(t *testing.T) {
+	now := time.Now().UTC()
+
+	jwk, err := jose.ReadKey("testdata/secrets/step_cli_key_priv.jwk", jose.WithPassword([]byte("pass")))
+	assert.FatalError(t, err)
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: jwk.Key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", jwk.KeyID))
+	assert.FatalError(t, err)
+	raw, err := jwt.Signed(sig).Claims(jwt.Claims{
+		Subject:   "1234",
+		Issuer:    "step-cli",
+		NotBefore: jwt.NewNumericDate(now),
+		Expiry:    jwt.NewNumericDate(now.Add(time.Minute)),
+		Audience:  testAudiences.SSHRevoke,
+		ID:        "44",
+	}).CompactSerialize()
+	assert.FatalError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	signer, err := ssh.NewSignerFromSigner(key)
+	assert.FatalError(t, err)
+	validBefore := now.Add(time.Hour).Truncate(time.Second)
+	cert := &ssh.Certificate{
+		Key:             signer.PublicKey(),
+		Serial:          1234,
+		CertType:        ssh.UserCert,
+		KeyId:           "jane@example.com",
+		ValidPrincipals: []string{"jane"},
+		ValidBefore:     uint64(validBefore.Unix()),
+	}
+	assert.FatalError(t, cert.SignCert(rand.Reader, signer))
+
+	tests := []struct {
+		name     string
+		cert     *ssh.Certificate
+		keyID    string
+		linkedCA bool
+		want     *db.RevokedCertificateInfo
+		code     int
+	}{
+		{"ok", cert, "", false, &db.RevokedCertificateInfo{Serial: "1234", ExpiresAt: validBefore.UTC()}, 0},
+		{"ok key id", cert, "jane@example.com", false, &db.RevokedCertificateInfo{Serial: "1234", KeyID: "jane@example.com", ExpiresAt: validBefore.UTC()}, 0},
+		{"ok not found", nil, "", false, &db.RevokedCertificateInfo{Serial: "1234"}, 0},
+		{"ok linkedca", cert, "", true, &db.RevokedCertificateInfo{Serial: "1234", ExpiresAt: validBefore.UTC()}, 0},
+		{"fail key id", cert, "john@example.com", false, nil, http.StatusBadRequest},
+		{"fail key id not found", nil, "jane@example.com", false, nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got, gotLinkedCA *db.RevokedCertificateInfo
+			a := testAuthority(t, WithDatabase(&db.MockAuthDB{
+				MUseToken: func(id, tok string) (bool, error) {
+					return true, nil
+				},
+				MGetSSHCertificate: func(serial string) (*ssh.Certificate, error) {
+					assert.Equals(t, "1234", serial)
+					if tt.cert == nil {
+						return nil, errors.New("not found")
+					}
+					return tt.cert, nil
+				},
+				MRevokeSSH: func(rci *db.RevokedCertificateInfo) error {
+					got = rci
+					return nil
+				},
+			}))
+			if tt.linkedCA {
+				a.adminDB = &mockLinkedCADB{
+					MRevokeSSH: func(crt *ssh.Certificate, rci *db.RevokedCertificateInfo) error {
+						assert.Equals(t, tt.cert, crt)
+						gotLinkedCA = rci
+						return nil
+					},
+				}
+			}
+
+			ctx := provisioner.NewContextWithMethod(context.Background(), provisioner.SSHRevokeMethod)
+			err := a.Revoke(ctx, &RevokeOptions{
+				Serial:      "1234",
+				KeyID:       tt.keyID,
+				OTT:         raw,
+				PassiveOnly: true,
+			})
+			if tt.code != 0 {
+				if assert.Error(t, err) {
+					sc, ok := err.(render.StatusCodedError)
+					assert.Fatal(t, ok, "error does not implement StatusCodedError interface")
+					assert.Equals(t, tt.code, sc.StatusCode())
+				}
+				assert.Nil(t, got)
+				return
+			}
+			assert.FatalError(t, err)
+			if assert.NotNil(t, got) {
+				assert.Equals(t, tt.want.Serial, got.Serial)
+				assert.Equals(t, tt.want.KeyID, got.KeyID)
+				assert.Equals(t, tt.want.ExpiresAt, got.ExpiresAt)
+			}
+			if tt.linkedCA {
+				assert.Equals(t, got, gotLinkedCA)
+			}
+		})
+	}
+}
+
+func TestAuthority_GenerateCertificateRevocationList(t *testing.T) {
+	now := time.Now().UTC()
+	crlConfig := &config.CRLConfig{
+		Enabled:       true,
+		CacheDuration: &provisioner.Duration{Duration: time.Hour},
+		RenewPeriod:   &provisioner.Duration{Duration: 30 * time.Minute},
+	}
+	revoked := []db.RevokedCertificateInfo{
+		{Serial: "1234", ReasonCode: 1, RevokedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{Serial: "5678", RevokedAt: now},
+		{Serial: "9012", RevokedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour)},
+	}
+
+	type test struct {
+		crl         *config.CRLConfig
+		db          *db.MockAuthDB
+		wantNumber  int64
+		wantSerials []string
+		wantErr     bool
+	}
+	tests := map[string]func(stored **db.CertificateRevocationListInfo) test{
+		"ok/first": func(stored **db.CertificateRevocationListInfo) test {
+			return test{
+				crl: crlConfig,
+				db: &db.MockAuthDB{
+					MGetRevokedCertificates: func() ([]db.RevokedCertificateInfo, error) {
+						return revoked, nil
+					},
+					MGetCRL: func() (*db.CertificateRevocationListInfo, error) {
+						return nil, database.ErrNotFound
+					},
+					MStoreCRL: func(crlInfo *db.CertificateRevocationListInfo) error {
+						*stored = crlInfo
+						return nil
+					},
+				},
+				wantNumber:  0,
+				wantSerials: []string{"1234", "5678"},
+			}
+		},
+		"ok/increment": func(stored **db.CertificateRevocationListInfo) test {
+			return test{
+				crl: crlConfig,
+				db: &db.MockAuthDB{
+					MGetRevokedCertificates: func() ([]db.RevokedCertificateInfo, error) {
+						return nil, nil
+					},
+					MGetCRL: func() (*db.CertificateRevocationListInfo, error) {
+						return &db.CertificateRevocationListInfo{Number: 41}, nil
+					},
+					MStoreCRL: func(crlInfo *db.CertificateRevocationListInfo) error {
+						*stored = crlInfo
+						return nil
+					},
+				},
+				wantNumber:  42,
+				wantSerials: []string{},
+			}
+		},
+		"fail/disabled": func(stored **db.CertificateRevocationListInfo) test {
+			return test{
+				crl:     nil,
+				db:      &db.MockAuthDB{},
+				wantErr: true,
+			}
+		},
+		"fail/GetRevokedCertificates": func(stored **db.CertificateRevocationListInfo) test {
+			return test{
+				crl: crlConfig,
+				db: &db.MockAuthDB{
+					MGetRevokedCertificates: func() ([]db.RevokedCertificateInfo, error) {
+						return nil, errors.New("force")
+					},
+				},
+				wantErr: true,
+			}
+		},
+		"fail/GetCRL": func(stored **db.CertificateRevocationListInfo) test {
+			return test{
+				crl: crlConfig,
+				db: &db.MockAuthDB{
+					MGetRevokedCertificates: func() ([]db.RevokedCertificateInfo, error) {
+						return revoked, nil
+					},
+					MGetCRL: func() (*db.CertificateRevocationListInfo, error) {
+						return nil, errors.New("force")
+					},
+				},
+				wantErr: true,
+			}
+		},
+		"fail/StoreCRL": func(stored **db.CertificateRevocationListInfo) test {
+			return test{
+				crl: crlConfig,
+				db: &db.MockAuthDB{
+					MGetRevokedCertificates: func() ([]db.RevokedCertificateInfo, error) {
+						return revoked, nil
+					},
+					MGetCRL: func() (*db.CertificateRevocationListInfo, error) {
+						return nil, database.ErrNotFound
+					},
+					MStoreCRL: func(crlInfo *db.CertificateRevocationListInfo) error {
+						return errors.New("force")
+					},
+				},
+				wantErr: true,
+			}
+		},
+	}
+	for name, f := range tests {
+		t.Run(name, func(t *testing.T) {
+			var stored *db.CertificateRevocationListInfo
+			tc := f(&stored)
+			a := testAuthority(t, WithDatabase(tc.db))
+			a.config.CRL = tc.crl
+
+			err := a.GenerateCertificateRevocationList()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Authority.GenerateCertificateRevocationList() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+
+			if assert.NotNil(t, stored) {
+				assert.Equals(t, tc.wantNumber, stored.Number)
+				assert.Equals(t, time.Hour, stored.Duration)
+
+				crl, err := x509.ParseCRL(stored.DER)
+				assert.FatalError(t, err)
+				intermediate, err := pemutil.ReadCertificate("testdata/certs/intermediate_ca.crt")
+				assert.FatalError(t, err)
+				assert.FatalError(t, intermediate.CheckCRLSignature(crl))
+
+				serials := []string{}
+				for _, rc := range crl.TBSCertList.RevokedCertificates {
+					serials = append(serials, rc.SerialNumber.String())
+				}
+				assert.Equals(t, tc.wantSerials, serials)
+			}
+		})
+	}
+}
+
+type mockLinkedCADB struct {
+	admin.MockDB
+	MRevoke    func(crt *x509.Certificate, rci *db.RevokedCertificateInfo) error
+	MRevokeSSH func(crt *ssh.Certificate, rci *db.RevokedCertificateInfo) error
+}
+
+func (m *mockLinkedCADB) Revoke(crt *x509.Certificate, rci *db.RevokedCertificateInfo) error {
+	return m.MRevoke(crt, rci)
+}
+
+func (m *mockLinkedCADB) RevokeSSH(crt *ssh.Certificate, rci *db.RevokedCertificateInfo) error {
+	return m.MRevokeSSH(crt, rci)
+}
+
+type mockCRLGetterCAS struct {
+	casapi.CertificateAuthorityService
+	crl []byte
+	err error
+}
+
+func (m *mockCRLGetterCAS) GetCertificateRevocationList(req *casapi.GetCertificateRevocationListRequest) (*casapi.GetCertificateRevocationListResponse, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &casapi.GetCertificateRevocationListResponse{CRL: m.crl}, nil
+}
+
+func TestAuthority_GetCertificateRevocationList_cas(t *testing.T) {
+	crlConfig := &config.CRLConfig{
+		Enabled:       true,
+		CacheDuration: &provisioner.Duration{Duration: time.Hour},
+		RenewPeriod:   &provisioner.Duration{Duration: 30 * time.Minute},
+	}
+
+	tests := map[string]struct {
+		crl     *config.CRLConfig
+		cas     *mockCRLGetterCAS
+		want    []byte
+		wantErr bool
+	}{
+		"ok":            {crlConfig, &mockCRLGetterCAS{crl: []byte("crl")}, []byte("crl"), false},
+		"fail/disabled": {nil, &mockCRLGetterCAS{crl: []byte("crl")}, nil, true},
+		"fail/cas":      {crlConfig, &mockCRLGetterCAS{err: errors.New("force")}, nil, true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := testAuthority(t, WithDatabase(&db.MockAuthDB{
+				MGetCRL: func() (*db.CertificateRevocationListInfo, error) {
+					return nil, errors.New("the CRL must not be read from the database")
+				},
+			}))
+			a.config.CRL = tc.crl
+			a.x509CAService = tc.cas
+
+			got, err := a.GetCertificateRevocationList()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Authority.GetCertificateRevocationList() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			assert.Equals(t, tc.want, got)
+
+			// Generating the CRL is a noop.
+			if tc.crl != nil {
+				assert.FatalError(t, a.GenerateCertificateRevocationList())
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package authority
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+// tracer is the OpenTelemetry tracer used by the authority. The spans are only
+// exported if a tracer provider has been configured, e.g. with the
+// opentelemetry monitoring type.
+var tracer = otel.Tracer("github.com/smallstep/certificates/authority")
+
+// provisionerAttributes returns the span attributes of the given provisioner.
+func provisionerAttributes(p provisioner.Interface) []attribute.KeyValue {
+	if p == nil {
+		return nil
+	}
+	return []attribute.KeyValue{
+		attribute.String("step.provisioner.name", p.GetName()),
+		attribute.String("step.provisioner.type", p.GetType().String()),
+	}
+}
+
+// endSpan records the error, if any, and ends the span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,102 @@
+package authority
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/authority/provisioner"
+	casapi "github.com/smallstep/certificates/cas/apiv1"
+	"github.com/smallstep/certificates/cas/softcas"
+	"github.com/smallstep/certificates/ct"
+)
+
+// getCertificateTransparencyOptions returns the Certificate Transparency
+// options configured in the given provisioner.
+func getCertificateTransparencyOptions(p provisioner.Interface) *provisioner.CertificateTransparencyOptions {
+	if o, ok := p.(interface{ GetOptions() *provisioner.Options }); ok {
+		return o.GetOptions().GetX509Options().GetCertificateTransparencyOptions()
+	}
+	return nil
+}
+
+// isCertificateTransparencySupported returns true if the X.509 certificate
+// authority service can issue the precertificates submitted to the CT logs.
+// Only the default SoftCAS signs the final certificate with the serial number
+// and the issuer of the precertificate.
+func (a *Authority) isCertificateTransparencySupported() bool {
+	_, ok := a.x509CAService.(*softcas.SoftCAS)
+	return ok
+}
+
+// submitPrecertificate signs a precertificate of the given leaf, submits it to
+// the configured Certificate Transparency logs, and adds the SCTs returned to
+// the leaf. The serial number and validity of the precertificate are set in
+// the leaf, so the final certificate matches the precertificate. The options
+// are validated when the provisioner is initialized.
+func (a *Authority) submitPrecertificate(ctx context.Context, leaf *x509.Certificate, csr *x509.CertificateRequest, lifetime, backdate time.Duration, opts *provisioner.CertificateTransparencyOptions) (*x509.Certificate, error) {
+	logs := make([]*ct.Log, len(opts.Logs))
+	for i, l := range opts.Logs {
+		log, err := ct.NewLog(l.URL, l.Key)
+		if err != nil {
+			return nil, err
+		}
+		logs[i] = log
+	}
+
+	// The precertificate is the leaf with the critical poison extension.
+	template := *leaf
+	template.ExtraExtensions = append(append([]pkix.Extension{}, leaf.ExtraExtensions...), ct.PoisonExtension())
+	resp, err := a.x509CAService.CreateCertificate(&casapi.CreateCertificateRequest{
+		Template: &template,
+		CSR:      csr,
+		Lifetime: lifetime,
+		Backdate: backdate,
+		Context:  ctx,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating precertificate")
+	}
+	precert := resp.Certificate
+
+	// Logs require the chain up to one of the accepted roots.
+	chain := append([]*x509.Certificate{precert}, resp.CertificateChain...)
+	if last := chain[len(chain)-1]; !a.isRoot(last) {
+		for _, root := range a.rootX509Certs {
+			if last.CheckSignatureFrom(root) == nil {
+				chain = append(chain, root)
+				break
+			}
+		}
+	}
+
+	scts, err := ct.Submit(ctx, logs, chain, opts.GetMinSCTs())
+	if err != nil {
+		return nil, err
+	}
+	ext, err := ct.SCTListExtension(scts)
+	if err != nil {
+		return nil, err
+	}
+
+	leaf.SerialNumber = precert.SerialNumber
+	leaf.NotBefore = precert.NotBefore
+	leaf.NotAfter = precert.NotAfter
+	leaf.SubjectKeyId = precert.SubjectKeyId
+	leaf.SignatureAlgorithm = template.SignatureAlgorithm
+	leaf.ExtraExtensions = append(leaf.ExtraExtensions, ext)
+	return precert, nil
+}
+
+// checkPrecertificate checks that the final certificate has been signed with
+// the serial number and the issuer of the precertificate submitted to the
+// logs.
+func checkPrecertificate(crt, precert *x509.Certificate) error {
+	if crt.SerialNumber.Cmp(precert.SerialNumber) != 0 || !bytes.Equal(crt.RawIssuer, precert.RawIssuer) {
+		return errors.New("certificate does not match the precertificate submitted to the logs")
+	}
+	return nil
+}
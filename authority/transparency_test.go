@@ -0,0 +1,178 @@
+package authority
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/certificates/authority/provisioner"
+	casapi "github.com/smallstep/certificates/cas/apiv1"
+	"github.com/smallstep/certificates/ct"
+	"go.step.sm/crypto/jose"
+)
+
+// testCTLog returns a local CT log that accepts any precertificate chain, and
+// returns an unverified SCT.
+func testCTLog(t *testing.T, status int) (*httptest.Server, *int32) {
+	t.Helper()
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		var req struct {
+			Chain [][]byte `json:"chain"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Chain) < 2 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		precert, err := x509.ParseCertificate(req.Chain[0])
+		if err != nil || len(precert.Extensions) == 0 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if status != http.StatusOK {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"sct_version": 0,
+			"id":          base64.StdEncoding.EncodeToString(make([]byte, 32)),
+			"timestamp":   time.Now().Unix() * 1000,
+			"extensions":  "",
+			"signature":   base64.StdEncoding.EncodeToString([]byte{4, 3, 0, 2, 0xca, 0xfe}),
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+// newCTProvisioner returns a JWK provisioner with the given Certificate
+// Transparency options, initialized with the configuration of the authority.
+func newCTProvisioner(t *testing.T, a *Authority, opts *provisioner.CertificateTransparencyOptions) (*provisioner.JWK, error) {
+	t.Helper()
+	jwk, err := jose.GenerateJWK("EC", "P-256", "ES256", "sig", "", 0)
+	assert.FatalError(t, err)
+	pub := jwk.Public()
+	p := &provisioner.JWK{
+		Type: "JWK",
+		Name: "ct",
+		Key:  &pub,
+		Options: &provisioner.Options{
+			X509: &provisioner.X509Options{CertificateTransparency: opts},
+		},
+	}
+	config, err := a.generateProvisionerConfig(context.Background())
+	assert.FatalError(t, err)
+	return p, p.Init(config)
+}
+
+func TestAuthority_certificateTransparency_init(t *testing.T) {
+	root, rootSigner := generateRootCertificate(t)
+	logs := []provisioner.CertificateTransparencyLog{{URL: "https://ct.example.com"}}
+
+	tests := []struct {
+		name    string
+		cas     casapi.CertificateAuthorityService
+		opts    *provisioner.CertificateTransparencyOptions
+		wantErr bool
+	}{
+		{"ok", nil, &provisioner.CertificateTransparencyOptions{Logs: logs}, false},
+		{"ok disabled", &mockCRLGetterCAS{}, nil, false},
+		{"fail options", nil, &provisioner.CertificateTransparencyOptions{Logs: logs, MinSCTs: 2}, true},
+		{"fail cas", &mockCRLGetterCAS{}, &provisioner.CertificateTransparencyOptions{Logs: logs}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := testRotationAuthority(t, root, rootSigner)
+			if tt.cas != nil {
+				a.x509CAService = tt.cas
+			}
+			_, err := newCTProvisioner(t, a, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("JWK.Init() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthority_Sign_certificateTransparency(t *testing.T) {
+	root, rootSigner := generateRootCertificate(t)
+	a, _ := testRotationAuthority(t, root, rootSigner)
+	okLog, okCalls := testCTLog(t, http.StatusOK)
+	failLog, _ := testCTLog(t, http.StatusServiceUnavailable)
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	cr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		DNSNames: []string{"foo.bar.zar"},
+	}, priv)
+	assert.FatalError(t, err)
+	csr, err := x509.ParseCertificateRequest(cr)
+	assert.FatalError(t, err)
+
+	newProvisioner := func(opts *provisioner.CertificateTransparencyOptions) *provisioner.JWK {
+		p, err := newCTProvisioner(t, a, opts)
+		assert.FatalError(t, err)
+		return p
+	}
+	validity := provisioner.CertificateModifierFunc(func(crt *x509.Certificate, _ provisioner.SignOptions) error {
+		crt.NotBefore = time.Now()
+		crt.NotAfter = crt.NotBefore.Add(time.Hour)
+		return nil
+	})
+
+	tests := []struct {
+		name     string
+		prov     *provisioner.JWK
+		wantSCTs int
+		wantErr  bool
+	}{
+		{"ok disabled", newProvisioner(nil), 0, false},
+		{"ok", newProvisioner(&provisioner.CertificateTransparencyOptions{
+			Logs: []provisioner.CertificateTransparencyLog{{URL: okLog.URL}, {URL: okLog.URL}},
+		}), 2, false},
+		{"ok min", newProvisioner(&provisioner.CertificateTransparencyOptions{
+			Logs:    []provisioner.CertificateTransparencyLog{{URL: failLog.URL}, {URL: okLog.URL}},
+			MinSCTs: 1,
+		}), 1, false},
+		{"fail log", newProvisioner(&provisioner.CertificateTransparencyOptions{
+			Logs: []provisioner.CertificateTransparencyLog{{URL: failLog.URL}, {URL: okLog.URL}},
+		}), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(okCalls, 0)
+			chain, err := a.Sign(csr, provisioner.SignOptions{}, tt.prov, validity)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Authority.Sign() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+
+			var scts []*ct.SignedCertificateTimestamp
+			for _, ext := range chain[0].Extensions {
+				assert.False(t, ext.Id.Equal(ct.OIDPoison))
+				if ext.Id.Equal(ct.OIDSCTList) {
+					scts, err = ct.ParseSCTList(ext.Value)
+					assert.FatalError(t, err)
+				}
+			}
+			assert.Len(t, tt.wantSCTs, scts)
+			assert.Equals(t, int32(tt.wantSCTs), atomic.LoadInt32(okCalls))
+			assert.FatalError(t, chain[0].CheckSignatureFrom(chain[1]))
+		})
+	}
+}
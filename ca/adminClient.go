@@ -15,6 +15,7 @@ import (
 	adminAPI "github.com/smallstep/certificates/authority/admin/api"
 	"github.com/smallstep/certificates/authority/provisioner"
 	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/certificates/policy"
 	"go.step.sm/cli-utils/token"
 	"go.step.sm/cli-utils/token/provision"
 	"go.step.sm/crypto/jose"
@@ -687,6 +688,333 @@ retry:
 	return nil
 }
 
+// CertificateOption is the type of options passed to the certificate methods.
+type CertificateOption func(o *certificateOptions) error
+
+type certificateOptions struct {
+	cursor        string
+	limit         int
+	provisioner   string
+	subject       string
+	expiresAfter  time.Time
+	expiresBefore time.Time
+	revoked       *bool
+}
+
+func (o *certificateOptions) apply(opts []CertificateOption) (err error) {
+	for _, fn := range opts {
+		if err = fn(o); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (o *certificateOptions) rawQuery() string {
+	v := url.Values{}
+	if len(o.cursor) > 0 {
+		v.Set("cursor", o.cursor)
+	}
+	if o.limit > 0 {
+		v.Set("limit", strconv.Itoa(o.limit))
+	}
+	if len(o.provisioner) > 0 {
+		v.Set("provisioner", o.provisioner)
+	}
+	if len(o.subject) > 0 {
+		v.Set("subject", o.subject)
+	}
+	if !o.expiresAfter.IsZero() {
+		v.Set("expiresAfter", o.expiresAfter.Format(time.RFC3339))
+	}
+	if !o.expiresBefore.IsZero() {
+		v.Set("expiresBefore", o.expiresBefore.Format(time.RFC3339))
+	}
+	if o.revoked != nil {
+		v.Set("revoked", strconv.FormatBool(*o.revoked))
+	}
+	return v.Encode()
+}
+
+// WithCertificateCursor will request the certificates starting with the given
+// cursor.
+func WithCertificateCursor(cursor string) CertificateOption {
+	return func(o *certificateOptions) error {
+		o.cursor = cursor
+		return nil
+	}
+}
+
+// WithCertificateLimit will request the given number of certificates.
+func WithCertificateLimit(limit int) CertificateOption {
+	return func(o *certificateOptions) error {
+		o.limit = limit
+		return nil
+	}
+}
+
+// WithCertificateProvisioner will request the certificates authorized by the
+// provisioner with the given name or id.
+func WithCertificateProvisioner(provisioner string) CertificateOption {
+	return func(o *certificateOptions) error {
+		o.provisioner = provisioner
+		return nil
+	}
+}
+
+// WithCertificateSubject will request the certificates with the given
+// substring in the subject or subject alternative names.
+func WithCertificateSubject(subject string) CertificateOption {
+	return func(o *certificateOptions) error {
+		o.subject = subject
+		return nil
+	}
+}
+
+// WithCertificateExpiration will request the certificates that expire in the
+// given window. A zero time leaves that side of the window open.
+func WithCertificateExpiration(after, before time.Time) CertificateOption {
+	return func(o *certificateOptions) error {
+		if !after.IsZero() && !before.IsZero() && !after.Before(before) {
+			return errors.New("the start of the expiration window must be before the end")
+		}
+		o.expiresAfter = after
+		o.expiresBefore = before
+		return nil
+	}
+}
+
+// WithCertificateRevoked will request the revoked certificates if revoked is
+// true, or the certificates that are not revoked if false.
+func WithCertificateRevoked(revoked bool) CertificateOption {
+	return func(o *certificateOptions) error {
+		o.revoked = &revoked
+		return nil
+	}
+}
+
+// GetCertificatesPaginate returns a page from the GET /admin/certificates
+// request to the CA.
+func (c *AdminClient) GetCertificatesPaginate(opts ...CertificateOption) (*adminAPI.GetCertificatesResponse, error) {
+	var retried bool
+	o := new(certificateOptions)
+	if err := o.apply(opts); err != nil {
+		return nil, err
+	}
+	u := c.endpoint.ResolveReference(&url.URL{
+		Path:     path.Join(adminURLPrefix, "certificates"),
+		RawQuery: o.rawQuery(),
+	})
+	tok, err := c.generateAdminToken(u)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error generating admin token")
+	}
+	req, err := http.NewRequest("GET", u.String(), http.NoBody)
+	if err != nil {
+		return nil, errors.Wrapf(err, "create GET %s request failed", u)
+	}
+	req.Header.Add("Authorization", tok)
+retry:
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "client GET %s failed", u)
+	}
+	if resp.StatusCode >= 400 {
+		if !retried && c.retryOnError(resp) {
+			retried = true
+			goto retry
+		}
+		return nil, readAdminError(resp.Body)
+	}
+	var body = new(adminAPI.GetCertificatesResponse)
+	if err := readJSON(resp.Body, body); err != nil {
+		return nil, errors.Wrapf(err, "error reading %s", u)
+	}
+	return body, nil
+}
+
+// GetCertificates returns all the certificates that match the given options
+// from the GET /admin/certificates request to the CA.
+func (c *AdminClient) GetCertificates(opts ...CertificateOption) ([]*adminAPI.CertificateInfo, error) {
+	var (
+		cursor = ""
+		certs  = []*adminAPI.CertificateInfo{}
+	)
+	for {
+		pageOpts := append(opts[:len(opts):len(opts)], WithCertificateCursor(cursor), WithCertificateLimit(100))
+		resp, err := c.GetCertificatesPaginate(pageOpts...)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, resp.Certificates...)
+		if resp.NextCursor == "" {
+			return certs, nil
+		}
+		cursor = resp.NextCursor
+	}
+}
+
+// GetAuthorityPolicy performs the GET /admin/policy request to the CA.
+func (c *AdminClient) GetAuthorityPolicy() (*policy.Options, error) {
+	var pol = new(policy.Options)
+	if err := c.doPolicyRequest("GET", "policy", nil, pol); err != nil {
+		return nil, err
+	}
+	return pol, nil
+}
+
+// CreateAuthorityPolicy performs the POST /admin/policy request to the CA.
+func (c *AdminClient) CreateAuthorityPolicy(pol *policy.Options) (*policy.Options, error) {
+	var created = new(policy.Options)
+	if err := c.doPolicyRequest("POST", "policy", pol, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// UpdateAuthorityPolicy performs the PUT /admin/policy request to the CA.
+func (c *AdminClient) UpdateAuthorityPolicy(pol *policy.Options) (*policy.Options, error) {
+	var updated = new(policy.Options)
+	if err := c.doPolicyRequest("PUT", "policy", pol, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// RemoveAuthorityPolicy performs the DELETE /admin/policy request to the CA.
+func (c *AdminClient) RemoveAuthorityPolicy() error {
+	return c.doPolicyRequest("DELETE", "policy", nil, nil)
+}
+
+// GetProvisionerPolicy performs the GET /admin/provisioners/{name}/policy
+// request to the CA.
+func (c *AdminClient) GetProvisionerPolicy(provisionerName string) (*policy.Options, error) {
+	var pol = new(policy.Options)
+	if err := c.doPolicyRequest("GET", path.Join("provisioners", provisionerName, "policy"), nil, pol); err != nil {
+		return nil, err
+	}
+	return pol, nil
+}
+
+// CreateProvisionerPolicy performs the POST /admin/provisioners/{name}/policy
+// request to the CA.
+func (c *AdminClient) CreateProvisionerPolicy(provisionerName string, pol *policy.Options) (*policy.Options, error) {
+	var created = new(policy.Options)
+	if err := c.doPolicyRequest("POST", path.Join("provisioners", provisionerName, "policy"), pol, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// UpdateProvisionerPolicy performs the PUT /admin/provisioners/{name}/policy
+// request to the CA.
+func (c *AdminClient) UpdateProvisionerPolicy(provisionerName string, pol *policy.Options) (*policy.Options, error) {
+	var updated = new(policy.Options)
+	if err := c.doPolicyRequest("PUT", path.Join("provisioners", provisionerName, "policy"), pol, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// RemoveProvisionerPolicy performs the DELETE
+// /admin/provisioners/{name}/policy request to the CA.
+func (c *AdminClient) RemoveProvisionerPolicy(provisionerName string) error {
+	return c.doPolicyRequest("DELETE", path.Join("provisioners", provisionerName, "policy"), nil, nil)
+}
+
+// EvaluatePolicy performs the POST /admin/policy/evaluate request to the CA.
+// It returns the rules of the current policies that match the names in the
+// request without signing any certificate.
+func (c *AdminClient) EvaluatePolicy(evalRequest *adminAPI.EvaluatePolicyRequest) (*adminAPI.EvaluatePolicyResponse, error) {
+	var body = new(adminAPI.EvaluatePolicyResponse)
+	if err := c.doPolicyRequest("POST", "policy/evaluate", evalRequest, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// RotateIntermediate performs the POST /admin/intermediates/rotate request to
+// the CA. The new intermediate is used to sign certificates after the grace
+// period in the request.
+func (c *AdminClient) RotateIntermediate(rotateRequest *adminAPI.RotateIntermediateRequest) (*adminAPI.RotateIntermediateResponse, error) {
+	var retried bool
+	body, err := json.Marshal(rotateRequest)
+	if err != nil {
+		return nil, errs.Wrap(http.StatusInternalServerError, err, "error marshaling request")
+	}
+	u := c.endpoint.ResolveReference(&url.URL{Path: path.Join(adminURLPrefix, "intermediates", "rotate")})
+	tok, err := c.generateAdminToken(u)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error generating admin token")
+	}
+	req, err := http.NewRequest("POST", u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrapf(err, "create POST %s request failed", u)
+	}
+	req.Header.Add("Authorization", tok)
+retry:
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "client POST %s failed", u)
+	}
+	if resp.StatusCode >= 400 {
+		if !retried && c.retryOnError(resp) {
+			retried = true
+			goto retry
+		}
+		return nil, readAdminError(resp.Body)
+	}
+	var rotateResponse = new(adminAPI.RotateIntermediateResponse)
+	if err := readJSON(resp.Body, rotateResponse); err != nil {
+		return nil, errors.Wrapf(err, "error reading %s", u)
+	}
+	return rotateResponse, nil
+}
+
+// doPolicyRequest performs a request to the policy endpoints of the admin API.
+// If in is not nil it is sent as the JSON body of the request, and if out is
+// not nil the JSON response is decoded into it.
+func (c *AdminClient) doPolicyRequest(method, p string, in, out interface{}) error {
+	var retried bool
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return errs.Wrap(http.StatusInternalServerError, err, "error marshaling request")
+		}
+	}
+	u := c.endpoint.ResolveReference(&url.URL{Path: path.Join(adminURLPrefix, p)})
+	tok, err := c.generateAdminToken(u)
+	if err != nil {
+		return errors.Wrapf(err, "error generating admin token")
+	}
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrapf(err, "create %s %s request failed", method, u)
+	}
+	req.Header.Add("Authorization", tok)
+retry:
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "client %s %s failed", method, u)
+	}
+	if resp.StatusCode >= 400 {
+		if !retried && c.retryOnError(resp) {
+			retried = true
+			goto retry
+		}
+		return readAdminError(resp.Body)
+	}
+	if out == nil {
+		resp.Body.Close()
+		return nil
+	}
+	if err := readJSON(resp.Body, out); err != nil {
+		return errors.Wrapf(err, "error reading %s", u)
+	}
+	return nil
+}
+
 func readAdminError(r io.ReadCloser) error {
 	// TODO: not all errors can be read (i.e. 404); seems to be a bigger issue
 	defer r.Close()
@@ -1,6 +1,7 @@
 package ca
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
@@ -17,10 +18,13 @@ import (
 	acmeAPI "github.com/smallstep/certificates/acme/api"
 	acmeNoSQL "github.com/smallstep/certificates/acme/db/nosql"
 	"github.com/smallstep/certificates/api"
+	"github.com/smallstep/certificates/api/render"
 	"github.com/smallstep/certificates/authority"
 	adminAPI "github.com/smallstep/certificates/authority/admin/api"
 	"github.com/smallstep/certificates/authority/config"
 	"github.com/smallstep/certificates/db"
+	"github.com/smallstep/certificates/errs"
+	"github.com/smallstep/certificates/est"
 	"github.com/smallstep/certificates/logging"
 	"github.com/smallstep/certificates/monitoring"
 	"github.com/smallstep/certificates/scep"
@@ -119,6 +123,8 @@ type CA struct {
 	config      *config.Config
 	srv         *server.Server
 	insecureSrv *server.Server
+	metricsSrv  *server.Server
+	monitoring  *monitoring.Monitoring
 	opts        *options
 	renewer     *TLSRenewer
 }
@@ -151,13 +157,30 @@ func (ca *CA) Init(cfg *config.Config) (*CA, error) {
 		opts = append(opts, authority.WithDatabase(ca.opts.database))
 	}
 
+	if ca.opts.configFile != "" {
+		opts = append(opts, authority.WithConfigFilename(ca.opts.configFile))
+	}
+
+	// Create the monitoring before the authority, so the meter, if any, can
+	// gather the metrics of the authority.
+	if len(cfg.Monitoring) > 0 {
+		m, err := monitoring.New(cfg.Monitoring)
+		if err != nil {
+			return nil, err
+		}
+		ca.monitoring = m
+		if meter := m.Meter(); meter != nil {
+			opts = append(opts, authority.WithMeter(meter))
+		}
+	}
+
 	auth, err := authority.New(cfg, opts...)
 	if err != nil {
 		return nil, err
 	}
 	ca.auth = auth
 
-	tlsConfig, err := ca.getTLSConfig(auth)
+	tlsConfig, clientCAs, err := ca.getTLSConfig(auth)
 	if err != nil {
 		return nil, err
 	}
@@ -198,13 +221,17 @@ func (ca *CA) Init(cfg *config.Config) (*CA, error) {
 			return nil, errors.Wrap(err, "error configuring ACME DB interface")
 		}
 	}
-	acmeHandler := acmeAPI.NewHandler(acmeAPI.HandlerOptions{
+	acmeOptions := acmeAPI.HandlerOptions{
 		Backdate: *cfg.AuthorityConfig.Backdate,
 		DB:       acmeDB,
 		DNS:      dns,
 		Prefix:   prefix,
 		CA:       auth,
-	})
+	}
+	if ca.monitoring != nil && ca.monitoring.Meter() != nil {
+		acmeOptions.Meter = ca.monitoring.Meter()
+	}
+	acmeHandler := acmeAPI.NewHandler(acmeOptions)
 	mux.Route("/"+prefix, func(r chi.Router) {
 		acmeHandler.Route(r)
 	})
@@ -256,17 +283,35 @@ func (ca *CA) Init(cfg *config.Config) (*CA, error) {
 		})
 	}
 
+	// EST requires HTTPS, so the API is only mounted in the regular mux;
+	// https://tools.ietf.org/html/rfc7030#section-3.2.2
+	estRouterHandler := est.New(auth)
+	mux.Route("/.well-known/est", func(r chi.Router) {
+		estRouterHandler.Route(r)
+	})
+
+	// OCSP clients usually send requests over plain HTTP, so the OCSP
+	// responder is also mounted in the insecure mux. It's always available in
+	// the regular CA api endpoints.
+	if cfg.OCSP.IsEnabled() {
+		api.RouteOCSP(insecureMux, auth)
+	}
+
 	// helpful routine for logging all routes
 	//dumpRoutes(mux)
 
+	// Client certificates are verified after the handshake, so the EST
+	// endpoints can accept bootstrap certificates issued by other roots.
+	handler = verifyClientCertificate(handler, clientCAs)
+
 	// Add monitoring if configured
-	if len(cfg.Monitoring) > 0 {
-		m, err := monitoring.New(cfg.Monitoring)
-		if err != nil {
-			return nil, err
+	if ca.monitoring != nil {
+		handler = ca.monitoring.Middleware(handler)
+		insecureHandler = ca.monitoring.Middleware(insecureHandler)
+		// Serve the metrics, if supported, in their own address.
+		if h := ca.monitoring.Handler(); h != nil {
+			ca.metricsSrv = server.New(ca.monitoring.Address(), h, nil)
 		}
-		handler = m.Middleware(handler)
-		insecureHandler = m.Middleware(insecureHandler)
 	}
 
 	// Add logger if configured
@@ -282,8 +327,8 @@ func (ca *CA) Init(cfg *config.Config) (*CA, error) {
 	ca.srv = server.New(cfg.Address, handler, tlsConfig)
 
 	// only start the insecure server if the insecure address is configured
-	// and, currently, also only when it should serve SCEP endpoints.
-	if ca.shouldServeSCEPEndpoints() && cfg.InsecureAddress != "" {
+	// and, currently, also only when it should serve SCEP or OCSP endpoints.
+	if (ca.shouldServeSCEPEndpoints() || cfg.OCSP.IsEnabled()) && cfg.InsecureAddress != "" {
 		// TODO: instead opt for having a single server.Server but two
 		// http.Servers handling the HTTP and HTTPS handler? The latter
 		// will probably introduce more complexity in terms of graceful
@@ -336,6 +381,14 @@ func (ca *CA) Run() error {
 		}()
 	}
 
+	if ca.metricsSrv != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- ca.metricsSrv.ListenAndServe()
+		}()
+	}
+
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -360,6 +413,16 @@ func (ca *CA) Stop() error {
 	if ca.insecureSrv != nil {
 		insecureShutdownErr = ca.insecureSrv.Shutdown()
 	}
+	if ca.metricsSrv != nil {
+		if err := ca.metricsSrv.Shutdown(); err != nil {
+			log.Printf("error stopping metrics server: %+v\n", err)
+		}
+	}
+	if ca.monitoring != nil {
+		if err := ca.monitoring.Shutdown(context.Background()); err != nil {
+			log.Printf("error stopping monitoring: %+v\n", err)
+		}
+	}
 
 	secureErr := ca.srv.Shutdown()
 
@@ -411,6 +474,13 @@ func (ca *CA) Reload() error {
 		}
 	}
 
+	if ca.metricsSrv != nil && newCA.metricsSrv != nil {
+		if err = ca.metricsSrv.Reload(newCA.metricsSrv); err != nil {
+			logContinue("Reload failed because metrics server could not be replaced.")
+			return errors.Wrap(err, "error reloading metrics server")
+		}
+	}
+
 	if err = ca.srv.Reload(newCA.srv); err != nil {
 		logContinue("Reload failed because server could not be replaced.")
 		return errors.Wrap(err, "error reloading server")
@@ -422,7 +492,13 @@ func (ca *CA) Reload() error {
 	// Do not replace ca.srv
 	ca.renewer.Stop()
 	ca.auth.CloseForReload()
+	if ca.monitoring != nil {
+		if err := ca.monitoring.Shutdown(context.Background()); err != nil {
+			log.Printf("error stopping monitoring: %+v\n", err)
+		}
+	}
 	ca.auth = newCA.auth
+	ca.monitoring = newCA.monitoring
 	ca.config = newCA.config
 	ca.opts = newCA.opts
 	ca.renewer = newCA.renewer
@@ -430,12 +506,12 @@ func (ca *CA) Reload() error {
 }
 
 // getTLSConfig returns a TLSConfig for the CA server with a self-renewing
-// server certificate.
-func (ca *CA) getTLSConfig(auth *authority.Authority) (*tls.Config, error) {
+// server certificate, and the pool used to verify the client certificates.
+func (ca *CA) getTLSConfig(auth *authority.Authority) (*tls.Config, *x509.CertPool, error) {
 	// Create initial TLS certificate
 	tlsCrt, err := auth.GetTLSCertificate()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Start tls renewer with the new certificate.
@@ -446,7 +522,7 @@ func (ca *CA) getTLSConfig(auth *authority.Authority) (*tls.Config, error) {
 
 	ca.renewer, err = NewTLSRenewer(tlsCrt, auth.GetTLSCertificate)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	ca.renewer.Run()
 
@@ -482,16 +558,59 @@ func (ca *CA) getTLSConfig(auth *authority.Authority) (*tls.Config, error) {
 	for _, certBytes := range intermediates {
 		cert, err := x509.ParseCertificate(certBytes)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		certPool.AddCert(cert)
 	}
 
-	// Add support for mutual tls to renew certificates
-	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
-	tlsConfig.ClientCAs = certPool
+	// Add support for mutual tls to renew certificates. The client certificate
+	// is only requested in the handshake, and verified with the certificate
+	// pool by verifyClientCertificate, except in the EST endpoints, that
+	// verify the bootstrap certificates with the roots of the provisioner.
+	// The acceptable CAs are not sent, so the clients do not discard the
+	// bootstrap certificates.
+	tlsConfig.ClientAuth = tls.RequestClientCert
+
+	return tlsConfig, certPool, nil
+}
+
+// verifyClientCertificate verifies the client certificate of the requests to
+// the CA endpoints, like tls.VerifyClientCertIfGiven does in the handshake,
+// and rejects the requests with a certificate that does not chain to the given
+// pool. The requests to the EST endpoints are verified by their handlers.
+func verifyClientCertificate(next http.Handler, roots *x509.CertPool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 || isESTRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, crt := range r.TLS.PeerCertificates[1:] {
+			intermediates.AddCert(crt)
+		}
+		chains, err := r.TLS.PeerCertificates[0].Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})
+		if err != nil {
+			render.Error(w, errs.UnauthorizedErr(err, errs.WithMessage("error verifying client certificate")))
+			return
+		}
+
+		cs := *r.TLS
+		cs.VerifiedChains = chains
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.TLS = &cs
+		next.ServeHTTP(w, r2)
+	})
+}
 
-	return tlsConfig, nil
+// isESTRequest returns true if the request is for the EST endpoints.
+func isESTRequest(r *http.Request) bool {
+	return r.URL.Path == "/.well-known/est" || strings.HasPrefix(r.URL.Path, "/.well-known/est/")
 }
 
 // shouldServeSCEPEndpoints returns if the CA should be
@@ -3,15 +3,19 @@ package ca
 import (
 	"bytes"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha1"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
+	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -674,3 +678,114 @@ func TestCARenew(t *testing.T) {
 		})
 	}
 }
+
+func TestCA_clientCertificate(t *testing.T) {
+	mustCertificate := func(t *testing.T, template, parent *x509.Certificate, pub crypto.PublicKey, signer crypto.Signer) *x509.Certificate {
+		t.Helper()
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, signer)
+		assert.FatalError(t, err)
+		crt, err := x509.ParseCertificate(der)
+		assert.FatalError(t, err)
+		return crt
+	}
+
+	now := time.Now()
+	clientTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "device.example.com"},
+		DNSNames:     []string{"device.example.com"},
+		NotBefore:    now.Add(-time.Minute),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	// Bootstrap certificate issued by a root that is not trusted by the CA.
+	foreignKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	foreignTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Manufacturer Root CA"},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	foreignRoot := mustCertificate(t, foreignTemplate, foreignTemplate, foreignKey.Public(), foreignKey)
+	bootstrapKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	bootstrapCert := mustCertificate(t, clientTemplate, foreignRoot, bootstrapKey.Public(), foreignKey)
+
+	// Certificate issued by the CA.
+	intermediateCert, err := pemutil.ReadCertificate("testdata/secrets/intermediate_ca.crt")
+	assert.FatalError(t, err)
+	intermediateKey, err := pemutil.Read("testdata/secrets/intermediate_ca_key", pemutil.WithPassword([]byte("password")))
+	assert.FatalError(t, err)
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	leafCert := mustCertificate(t, clientTemplate, intermediateCert, leafKey.Public(), intermediateKey.(crypto.Signer))
+
+	config, err := authority.LoadConfiguration("testdata/ca.json")
+	assert.FatalError(t, err)
+	config.AuthorityConfig.Provisioners = append(config.AuthorityConfig.Provisioners, &provisioner.EST{
+		Type:           "EST",
+		Name:           "est",
+		BootstrapRoots: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: foreignRoot.Raw}),
+	})
+	ca, err := New(config)
+	assert.FatalError(t, err)
+
+	srv := httptest.NewUnstartedServer(ca.srv.Handler)
+	srv.TLS = ca.srv.TLSConfig
+	srv.StartTLS()
+	defer srv.Close()
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "device.example.com"},
+		DNSNames: []string{"device.example.com"},
+	}, bootstrapKey)
+	assert.FatalError(t, err)
+
+	tests := []struct {
+		name       string
+		cert       *x509.Certificate
+		key        crypto.Signer
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"ok est bootstrap", bootstrapCert, bootstrapKey, "POST", "/.well-known/est/est/simpleenroll", base64.StdEncoding.EncodeToString(csr), http.StatusOK},
+		{"ok renew", leafCert, leafKey, "POST", "/renew", "", http.StatusCreated},
+		{"ok without certificate", nil, nil, "GET", "/health", "", http.StatusOK},
+		{"fail foreign certificate", bootstrapCert, bootstrapKey, "GET", "/health", "", http.StatusUnauthorized},
+		{"fail renew foreign certificate", bootstrapCert, bootstrapKey, "POST", "/renew", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsConfig := &tls.Config{
+				InsecureSkipVerify: true, //nolint:gosec // test server
+			}
+			if tt.cert != nil {
+				tlsConfig.Certificates = []tls.Certificate{{
+					Certificate: [][]byte{tt.cert.Raw},
+					PrivateKey:  tt.key,
+					Leaf:        tt.cert,
+				}}
+			}
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+
+			req, err := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader(tt.body))
+			assert.FatalError(t, err)
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/pkcs10")
+			}
+			// The handshake succeeds with any client certificate.
+			resp, err := client.Do(req)
+			assert.FatalError(t, err)
+			defer resp.Body.Close()
+			assert.Equals(t, tt.wantStatus, resp.StatusCode)
+		})
+	}
+}
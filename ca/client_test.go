@@ -2,6 +2,7 @@ package ca
 
 import (
 	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
@@ -17,6 +18,9 @@ import (
 	"testing"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"go.step.sm/crypto/x509util"
 	"golang.org/x/crypto/ssh"
 
@@ -396,6 +400,65 @@ func TestClient_Sign(t *testing.T) {
 	}
 }
 
+func TestClient_SignWithContext(t *testing.T) {
+	// Use the W3C trace context propagator.
+	defer otel.SetTextMapPropagator(otel.GetTextMapPropagator())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	traceID, err := trace.TraceIDFromHex("0102030405060708090a0b0c0d0e0f10")
+	assert.FatalError(t, err)
+	spanID, err := trace.SpanIDFromHex("0102030405060708")
+	assert.FatalError(t, err)
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	ok := &api.SignResponse{
+		ServerPEM: api.Certificate{Certificate: parseCertificate(certPEM)},
+		CaPEM:     api.Certificate{Certificate: parseCertificate(rootPEM)},
+		CertChainPEM: []api.Certificate{
+			{Certificate: parseCertificate(certPEM)},
+			{Certificate: parseCertificate(rootPEM)},
+		},
+	}
+	request := &api.SignRequest{
+		CsrPEM: api.CertificateRequest{CertificateRequest: parseCertificateRequest(csrPEM)},
+		OTT:    "the-ott",
+	}
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		traceparent string
+	}{
+		{"ok", ctx, "00-0102030405060708090a0b0c0d0e0f10-0102030405060708-01"},
+		{"ok no trace", context.Background(), ""},
+	}
+
+	srv := httptest.NewServer(nil)
+	defer srv.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewClient(srv.URL, WithTransport(http.DefaultTransport))
+			assert.FatalError(t, err)
+
+			var traceparent string
+			srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				traceparent = req.Header.Get("traceparent")
+				render.JSONStatus(w, ok, 200)
+			})
+
+			got, err := c.SignWithContext(tt.ctx, request)
+			assert.FatalError(t, err)
+			assert.Equals(t, ok, got)
+			assert.Equals(t, tt.traceparent, traceparent)
+		})
+	}
+}
+
 func TestClient_Revoke(t *testing.T) {
 	ok := &api.RevokeResponse{Status: "ok"}
 	request := &api.RevokeRequest{
@@ -2,21 +2,30 @@ package identity
 
 import (
 	"bytes"
+	"context"
 	"crypto"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
+	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/certificates/api"
+	"github.com/smallstep/certificates/kms/apiv1"
+	"github.com/smallstep/certificates/kms/uri"
 	"go.step.sm/cli-utils/step"
 	"go.step.sm/crypto/pemutil"
+
+	// Enable TPM KMS for the identity keys.
+	_ "github.com/smallstep/certificates/kms/tpmkms"
 )
 
 // Type represents the different types of identity files.
@@ -51,8 +60,54 @@ var (
 	DefaultsFile = step.DefaultsFile
 )
 
+// newKMSSigner returns a crypto.Signer with the key in the given KMS URI. The
+// type of the KMS is the scheme of the URI.
+var newKMSSigner = func(name string) (crypto.Signer, error) {
+	u, err := uri.Parse(name)
+	if err != nil {
+		return nil, err
+	}
+	fn, ok := apiv1.LoadKeyManagerNewFunc(apiv1.Type(strings.ToLower(u.Scheme)))
+	if !ok {
+		return nil, errors.Errorf("unsupported kms type '%s'", u.Scheme)
+	}
+	k, err := fn(context.Background(), apiv1.Options{
+		Type: strings.ToLower(u.Scheme),
+	})
+	if err != nil {
+		return nil, err
+	}
+	signer, err := k.CreateSigner(&apiv1.CreateSignerRequest{
+		SigningKey: name,
+	})
+	if err != nil {
+		k.Close()
+		return nil, err
+	}
+	return &kmsSigner{Signer: signer, name: name, km: k}, nil
+}
+
+// kmsSigner is a crypto.Signer with a key stored in a KMS. It keeps the URI of
+// the key, so it can be written in the identity file.
+type kmsSigner struct {
+	crypto.Signer
+	name string
+	km   apiv1.KeyManager
+}
+
+// Close closes the KMS used by the signer.
+func (s *kmsSigner) Close() error {
+	if s.km == nil {
+		return nil
+	}
+	return s.km.Close()
+}
+
 // Identity represents the identity file that can be used to authenticate with
 // the CA.
+//
+// The key can be a PEM file or a key stored in a TPM 2.0, using an URI like
+// "tpmkms:handle=0x81000100" or "tpmkms:blob=/path/to/key.tss".
 type Identity struct {
 	Type        string `json:"type"`
 	Certificate string `json:"crt"`
@@ -63,6 +118,11 @@ type Identity struct {
 	// Root is the CA bundle of root CAs used in TunnelTLS to trust the
 	// certificate of the host.
 	Root string `json:"root,omitempty"`
+
+	// The signer of a key in a KMS is created once, and reused on every
+	// handshake.
+	mu        sync.Mutex
+	kmsSigner crypto.Signer
 }
 
 // LoadIdentity loads an identity present in the given filename.
@@ -103,17 +163,21 @@ func WriteDefaultIdentity(certChain []api.Certificate, key crypto.PrivateKey) er
 		return err
 	}
 
-	// Write key
+	// Write key, keys in a KMS are only referenced by their URI.
 	buf := new(bytes.Buffer)
-	block, err := pemutil.Serialize(key)
-	if err != nil {
-		return err
-	}
-	if err := pem.Encode(buf, block); err != nil {
-		return errors.Wrap(err, "error encoding identity key")
-	}
-	if err := os.WriteFile(keyFilename, buf.Bytes(), 0600); err != nil {
-		return errors.Wrap(err, "error writing identity certificate")
+	if s, ok := key.(*kmsSigner); ok {
+		keyFilename = s.name
+	} else {
+		block, err := pemutil.Serialize(key)
+		if err != nil {
+			return err
+		}
+		if err := pem.Encode(buf, block); err != nil {
+			return errors.Wrap(err, "error encoding identity key")
+		}
+		if err := os.WriteFile(keyFilename, buf.Bytes(), 0600); err != nil {
+			return errors.Wrap(err, "error writing identity certificate")
+		}
 	}
 
 	// Write identity.json
@@ -189,7 +253,7 @@ func (i *Identity) Validate() error {
 		if err := fileExists(i.Certificate); err != nil {
 			return err
 		}
-		return fileExists(i.Key)
+		return keyExists(i.Key)
 	case TunnelTLS:
 		if i.Host == "" {
 			return errors.New("tunnel.host cannot be empty")
@@ -201,7 +265,7 @@ func (i *Identity) Validate() error {
 			if i.Key == "" {
 				return errors.New("tunnel.key cannot be empty")
 			}
-			if err := fileExists(i.Key); err != nil {
+			if err := keyExists(i.Key); err != nil {
 				return err
 			}
 		}
@@ -223,7 +287,7 @@ func (i *Identity) TLSCertificate() (tls.Certificate, error) {
 	case Disabled:
 		return tls.Certificate{}, nil
 	case MutualTLS, TunnelTLS:
-		crt, err := tls.LoadX509KeyPair(i.Certificate, i.Key)
+		crt, err := i.loadX509KeyPair()
 		if err != nil {
 			return fail(errors.Wrap(err, "error creating identity certificate"))
 		}
@@ -250,7 +314,7 @@ func (i *Identity) TLSCertificate() (tls.Certificate, error) {
 // GetClientCertificate property in a tls.Config.
 func (i *Identity) GetClientCertificateFunc() func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
 	return func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
-		crt, err := tls.LoadX509KeyPair(i.Certificate, i.Key)
+		crt, err := i.loadX509KeyPair()
 		if err != nil {
 			return nil, errors.Wrap(err, "error loading identity certificate")
 		}
@@ -258,6 +322,89 @@ func (i *Identity) GetClientCertificateFunc() func(*tls.CertificateRequestInfo)
 	}
 }
 
+// Signer returns the private key of the identity.
+func (i *Identity) Signer() (crypto.Signer, error) {
+	if isKMSKey(i.Key) {
+		return i.getKMSSigner()
+	}
+	key, err := pemutil.Read(i.Key)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.Errorf("error reading %s: key is not a crypto.Signer", i.Key)
+	}
+	return signer, nil
+}
+
+// loadX509KeyPair loads the certificate and the key of the identity. It
+// behaves like tls.LoadX509KeyPair but it also supports keys in a KMS.
+func (i *Identity) loadX509KeyPair() (tls.Certificate, error) {
+	if !isKMSKey(i.Key) {
+		return tls.LoadX509KeyPair(i.Certificate, i.Key)
+	}
+
+	b, err := os.ReadFile(i.Certificate)
+	if err != nil {
+		return tls.Certificate{}, errors.Wrapf(err, "error reading %s", i.Certificate)
+	}
+	var crt tls.Certificate
+	for block, rest := pem.Decode(b); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type == "CERTIFICATE" {
+			crt.Certificate = append(crt.Certificate, block.Bytes)
+		}
+	}
+	if len(crt.Certificate) == 0 {
+		return tls.Certificate{}, errors.Errorf("error reading %s: no certificates found", i.Certificate)
+	}
+	leaf, err := x509.ParseCertificate(crt.Certificate[0])
+	if err != nil {
+		return tls.Certificate{}, errors.Wrapf(err, "error parsing %s", i.Certificate)
+	}
+
+	signer, err := i.getKMSSigner()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if pub, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool }); !ok || !pub.Equal(leaf.PublicKey) {
+		return tls.Certificate{}, errors.New("private key does not match public key")
+	}
+	crt.PrivateKey = signer
+	return crt, nil
+}
+
+// getKMSSigner returns the signer of a key in a KMS, the signer is created on
+// the first call and reused after that.
+func (i *Identity) getKMSSigner() (crypto.Signer, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.kmsSigner == nil {
+		signer, err := newKMSSigner(i.Key)
+		if err != nil {
+			return nil, err
+		}
+		i.kmsSigner = signer
+	}
+	return i.kmsSigner, nil
+}
+
+// Close releases the resources used by the identity, like the KMS used by the
+// signer.
+func (i *Identity) Close() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.kmsSigner == nil {
+		return nil
+	}
+	var err error
+	if c, ok := i.kmsSigner.(io.Closer); ok {
+		err = c.Close()
+	}
+	i.kmsSigner = nil
+	return err
+}
+
 // GetCertPool returns a x509.CertPool if the identity defines a custom root.
 func (i *Identity) GetCertPool() (*x509.CertPool, error) {
 	if i.Root == "" {
@@ -330,6 +477,25 @@ func (i *Identity) Renew(client Renewer) error {
 	}
 }
 
+// isKMSKey returns true if the key is the URI of a key in a registered KMS,
+// like "tpmkms:handle=0x81000100".
+func isKMSKey(key string) bool {
+	u, err := url.Parse(key)
+	if err != nil || u.Scheme == "" {
+		return false
+	}
+	_, ok := apiv1.LoadKeyManagerNewFunc(apiv1.Type(strings.ToLower(u.Scheme)))
+	return ok
+}
+
+// keyExists checks that the key file exists, keys in a KMS are not checked.
+func keyExists(key string) error {
+	if isKMSKey(key) {
+		return nil
+	}
+	return fileExists(key)
+}
+
 func fileExists(filename string) error {
 	info, err := os.Stat(filename)
 	if err != nil {
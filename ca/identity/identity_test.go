@@ -94,6 +94,7 @@ func TestIdentity_Validate(t *testing.T) {
 		{"ok mTLS", fields{"mTLS", "testdata/identity/identity.crt", "testdata/identity/identity_key", "", ""}, false},
 		{"ok tTLS", fields{"tTLS", "testdata/identity/identity.crt", "testdata/identity/identity_key", "tunnel:443", "testdata/certs/root_ca.crt"}, false},
 		{"ok disabled", fields{}, false},
+		{"ok tpmkms", fields{"mTLS", "testdata/identity/identity.crt", "tpmkms:handle=0x81000100", "", ""}, false},
 		{"fail type", fields{"foo", "testdata/identity/identity.crt", "testdata/identity/identity_key", "", ""}, true},
 		{"fail certificate", fields{"mTLS", "", "testdata/identity/identity_key", "", ""}, true},
 		{"fail key", fields{"mTLS", "testdata/identity/identity.crt", "", "", ""}, true},
@@ -165,6 +166,157 @@ func TestIdentity_TLSCertificate(t *testing.T) {
 	}
 }
 
+func TestIdentity_TLSCertificate_kms(t *testing.T) {
+	expected, err := tls.LoadX509KeyPair("testdata/identity/identity.crt", "testdata/identity/identity_key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := pemutil.Read("testdata/identity/identity_key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSigner, err := pemutil.Read("testdata/secrets/server_key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmp := newKMSSigner
+	t.Cleanup(func() {
+		newKMSSigner = tmp
+	})
+
+	tests := []struct {
+		name        string
+		certificate string
+		signer      crypto.Signer
+		signerErr   error
+		wantErr     bool
+	}{
+		{"ok", "testdata/identity/identity.crt", signer.(crypto.Signer), nil, false},
+		{"fail missing certificate", "testdata/identity/missing.crt", signer.(crypto.Signer), nil, true},
+		{"fail no certificate", "testdata/identity/identity_key", signer.(crypto.Signer), nil, true},
+		{"fail signer", "testdata/identity/identity.crt", nil, fmt.Errorf("an error"), true},
+		{"fail key mismatch", "testdata/identity/identity.crt", otherSigner.(crypto.Signer), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newKMSSigner = func(name string) (crypto.Signer, error) {
+				if name != "tpmkms:handle=0x81000100" {
+					t.Errorf("newKMSSigner() name = %s, want tpmkms:handle=0x81000100", name)
+				}
+				return tt.signer, tt.signerErr
+			}
+			i := &Identity{
+				Type:        "mTLS",
+				Certificate: tt.certificate,
+				Key:         "tpmkms:handle=0x81000100",
+			}
+			got, err := i.TLSCertificate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Identity.TLSCertificate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got.Certificate, expected.Certificate) {
+				t.Errorf("Identity.TLSCertificate() = %v, want %v", got.Certificate, expected.Certificate)
+			}
+			if got.PrivateKey != tt.signer {
+				t.Errorf("Identity.TLSCertificate() PrivateKey = %v, want %v", got.PrivateKey, tt.signer)
+			}
+		})
+	}
+}
+
+type closeSigner struct {
+	crypto.Signer
+	closed bool
+}
+
+func (s *closeSigner) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestIdentity_kmsSigner(t *testing.T) {
+	signer, err := pemutil.Read("testdata/identity/identity_key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmp := newKMSSigner
+	t.Cleanup(func() {
+		newKMSSigner = tmp
+	})
+
+	var calls int
+	var last *closeSigner
+	newKMSSigner = func(name string) (crypto.Signer, error) {
+		calls++
+		last = &closeSigner{Signer: signer.(crypto.Signer)}
+		return last, nil
+	}
+
+	i := &Identity{
+		Type:        "mTLS",
+		Certificate: "testdata/identity/identity.crt",
+		Key:         "tpmkms:handle=0x81000100",
+	}
+	if _, err := i.TLSCertificate(); err != nil {
+		t.Fatalf("Identity.TLSCertificate() error = %v", err)
+	}
+	fn := i.GetClientCertificateFunc()
+	for j := 0; j < 3; j++ {
+		if _, err := fn(&tls.CertificateRequestInfo{}); err != nil {
+			t.Fatalf("Identity.GetClientCertificateFunc() error = %v", err)
+		}
+	}
+	got, err := i.Signer()
+	if err != nil {
+		t.Fatalf("Identity.Signer() error = %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("newKMSSigner() calls = %d, want 1", calls)
+	}
+	if got != last {
+		t.Errorf("Identity.Signer() = %v, want %v", got, last)
+	}
+
+	if err := i.Close(); err != nil {
+		t.Fatalf("Identity.Close() error = %v", err)
+	}
+	if !last.closed {
+		t.Error("Identity.Close() did not close the signer")
+	}
+	if _, err := i.Signer(); err != nil {
+		t.Fatalf("Identity.Signer() error = %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("newKMSSigner() calls = %d, want 2", calls)
+	}
+}
+
+func Test_isKMSKey(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		want bool
+	}{
+		{"tpmkms handle", "tpmkms:handle=0x81000100", true},
+		{"tpmkms blob", "tpmkms:blob=/path/to/key.tss", true},
+		{"file", "testdata/identity/identity_key", false},
+		{"unregistered", "foo:bar=zar", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isKMSKey(tt.key); got != tt.want {
+				t.Errorf("isKMSKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func Test_fileExists(t *testing.T) {
 	type args struct {
 		filename string
@@ -232,6 +384,7 @@ func TestWriteDefaultIdentity(t *testing.T) {
 		wantErr bool
 	}{
 		{"ok", func() {}, args{certChain, key}, false},
+		{"ok kms", func() {}, args{certChain, &kmsSigner{Signer: key.(crypto.Signer), name: "tpmkms:handle=0x81000100"}}, false},
 		{"fail mkdir config", func() {
 			configDir = returnInput(filepath.Join(tmpDir, "identity", "identity.crt"))
 			identityDir = returnInput(filepath.Join(tmpDir, "identity"))
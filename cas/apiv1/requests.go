@@ -1,6 +1,7 @@
 package apiv1
 
 import (
+	"context"
 	"crypto"
 	"crypto/x509"
 	"time"
@@ -50,13 +51,16 @@ const (
 	PureEd25519
 )
 
-// CreateCertificateRequest is the request used to sign a new certificate.
+// CreateCertificateRequest is the request used to sign a new certificate. The
+// optional context is used to trace the request, and to propagate the trace to
+// the upstream services.
 type CreateCertificateRequest struct {
 	Template  *x509.Certificate
 	CSR       *x509.CertificateRequest
 	Lifetime  time.Duration
 	Backdate  time.Duration
 	RequestID string
+	Context   context.Context
 }
 
 // CreateCertificateResponse is the response to a create certificate request.
@@ -144,3 +148,23 @@ type CreateCertificateAuthorityResponse struct {
 	PrivateKey       crypto.PrivateKey
 	Signer           crypto.Signer
 }
+
+// CreateCRLRequest is the request to create a Certificate Revocation List.
+type CreateCRLRequest struct {
+	RevocationList *x509.RevocationList
+}
+
+// CreateCRLResponse is the response to a Certificate Revocation List request.
+type CreateCRLResponse struct {
+	CRL []byte // the CRL in DER format
+}
+
+// GetCertificateRevocationListRequest is the request used to get the
+// Certificate Revocation List maintained by a CAS.
+type GetCertificateRevocationListRequest struct{}
+
+// GetCertificateRevocationListResponse is the response that contains the
+// Certificate Revocation List of a CAS.
+type GetCertificateRevocationListResponse struct {
+	CRL []byte // the CRL in DER format
+}
@@ -27,6 +27,19 @@ type CertificateAuthorityCreator interface {
 	CreateCertificateAuthority(req *CreateCertificateAuthorityRequest) (*CreateCertificateAuthorityResponse, error)
 }
 
+// CertificateAuthorityCRLGenerator is an optional interface implemented by a
+// CertificateAuthorityService that has a method to create a CRL.
+type CertificateAuthorityCRLGenerator interface {
+	CreateCRL(req *CreateCRLRequest) (*CreateCRLResponse, error)
+}
+
+// CertificateAuthorityCRLGetter is an optional interface implemented by a
+// CertificateAuthorityService that maintains its own CRL, and has a method to
+// get it.
+type CertificateAuthorityCRLGetter interface {
+	GetCertificateRevocationList(req *GetCertificateRevocationListRequest) (*GetCertificateRevocationListResponse, error)
+}
+
 // SignatureAlgorithmGetter is an optional implementation in a crypto.Signer
 // that returns the SignatureAlgorithm to use.
 type SignatureAlgorithmGetter interface {
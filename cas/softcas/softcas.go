@@ -3,13 +3,17 @@ package softcas
 import (
 	"context"
 	"crypto"
+	"crypto/rand"
 	"crypto/x509"
+	"io"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/certificates/cas/apiv1"
 	"github.com/smallstep/certificates/kms"
 	kmsapi "github.com/smallstep/certificates/kms/apiv1"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
 	"go.step.sm/crypto/x509util"
 )
 
@@ -21,6 +25,10 @@ func init() {
 
 var now = time.Now
 
+// tracer is the OpenTelemetry tracer used to trace the signatures made with the
+// KMS.
+var tracer = otel.Tracer("github.com/smallstep/certificates/cas/softcas")
+
 // SoftCAS implements a Certificate Authority Service using Golang or KMS
 // crypto. This is the default CAS used in step-ca.
 type SoftCAS struct {
@@ -74,6 +82,11 @@ func (c *SoftCAS) CreateCertificate(req *apiv1.CreateCertificateRequest) (*apiv1
 	}
 	req.Template.Issuer = chain[0].Subject
 
+	// Trace the signatures made with the KMS.
+	if req.Context != nil {
+		signer = &tracedSigner{Signer: signer, ctx: req.Context}
+	}
+
 	cert, err := createCertificate(req.Template, chain[0], req.Template.PublicKey, signer)
 	if err != nil {
 		return nil, err
@@ -129,6 +142,32 @@ func (c *SoftCAS) RevokeCertificate(req *apiv1.RevokeCertificateRequest) (*apiv1
 	}, nil
 }
 
+// CreateCRL will create a new CRL based on the RevocationList passed to it
+func (c *SoftCAS) CreateCRL(req *apiv1.CreateCRLRequest) (*apiv1.CreateCRLResponse, error) {
+	if req.RevocationList == nil {
+		return nil, errors.New("createCRLRequest `revocationList` cannot be nil")
+	}
+
+	chain, signer, err := c.getCertSigner()
+	if err != nil {
+		return nil, err
+	}
+
+	// Signers can specify the signature algorithm.
+	if req.RevocationList.SignatureAlgorithm == 0 {
+		if sa, ok := signer.(apiv1.SignatureAlgorithmGetter); ok {
+			req.RevocationList.SignatureAlgorithm = sa.SignatureAlgorithm()
+		}
+	}
+
+	revocationListBytes, err := x509.CreateRevocationList(rand.Reader, req.RevocationList, chain[0], signer)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating revocation list")
+	}
+
+	return &apiv1.CreateCRLResponse{CRL: revocationListBytes}, nil
+}
+
 // CreateCertificateAuthority creates a root or an intermediate certificate.
 func (c *SoftCAS) CreateCertificateAuthority(req *apiv1.CreateCertificateAuthorityRequest) (*apiv1.CreateCertificateAuthorityResponse, error) {
 	switch {
@@ -248,3 +287,30 @@ func createCertificate(template, parent *x509.Certificate, pub crypto.PublicKey,
 	}
 	return x509util.CreateCertificate(template, parent, pub, signer)
 }
+
+// tracedSigner is a crypto.Signer that creates a span for each signature.
+type tracedSigner struct {
+	crypto.Signer
+	ctx context.Context
+}
+
+// Sign implements the crypto.Signer interface.
+func (s *tracedSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	_, span := tracer.Start(s.ctx, "kms.Sign")
+	defer span.End()
+	sig, err := s.Signer.Sign(rand, digest, opts)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return sig, err
+}
+
+// SignatureAlgorithm implements the apiv1.SignatureAlgorithmGetter interface,
+// returning the signature algorithm of the wrapped signer if it has one.
+func (s *tracedSigner) SignatureAlgorithm() x509.SignatureAlgorithm {
+	if sa, ok := s.Signer.(apiv1.SignatureAlgorithmGetter); ok {
+		return sa.SignatureAlgorithm()
+	}
+	return x509.UnknownSignatureAlgorithm
+}
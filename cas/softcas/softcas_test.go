@@ -270,6 +270,7 @@ func TestSoftCAS_CreateCertificate(t *testing.T) {
 
 	saTemplate := *testSignedTemplate
 	saTemplate.SignatureAlgorithm = 0
+	saTemplateWithContext := saTemplate
 	saSigner := &signatureAlgorithmSigner{
 		Signer:    testSigner,
 		algorithm: x509.PureEd25519,
@@ -302,6 +303,12 @@ func TestSoftCAS_CreateCertificate(t *testing.T) {
 			Certificate:      testSignedTemplate,
 			CertificateChain: []*x509.Certificate{testIssuer},
 		}, false},
+		{"ok signature algorithm with context", fields{testIssuer, saSigner, nil}, args{&apiv1.CreateCertificateRequest{
+			Template: &saTemplateWithContext, Lifetime: 24 * time.Hour, Context: context.Background(),
+		}}, &apiv1.CreateCertificateResponse{
+			Certificate:      testSignedTemplate,
+			CertificateChain: []*x509.Certificate{testIssuer},
+		}, false},
 		{"ok with notBefore", fields{testIssuer, testSigner, nil}, args{&apiv1.CreateCertificateRequest{
 			Template: &tmplNotBefore, Lifetime: 24 * time.Hour,
 		}}, &apiv1.CreateCertificateResponse{
@@ -496,6 +503,74 @@ func TestSoftCAS_RevokeCertificate(t *testing.T) {
 	}
 }
 
+func TestSoftCAS_CreateCRL(t *testing.T) {
+	type fields struct {
+		Issuer            *x509.Certificate
+		Signer            crypto.Signer
+		CertificateSigner func() ([]*x509.Certificate, crypto.Signer, error)
+	}
+	type args struct {
+		req *apiv1.CreateCRLRequest
+	}
+	revocationList := func() *x509.RevocationList {
+		return &x509.RevocationList{
+			Number:     big.NewInt(1),
+			ThisUpdate: testNow,
+			NextUpdate: testNow.Add(24 * time.Hour),
+			RevokedCertificates: []pkix.RevokedCertificate{
+				{SerialNumber: big.NewInt(1234), RevocationTime: testNow},
+			},
+		}
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		{"ok", fields{testIssuer, testSigner, nil}, args{&apiv1.CreateCRLRequest{
+			RevocationList: revocationList(),
+		}}, false},
+		{"ok with callback", fields{nil, nil, testCertificateSigner}, args{&apiv1.CreateCRLRequest{
+			RevocationList: revocationList(),
+		}}, false},
+		{"fail with callback", fields{nil, nil, testFailCertificateSigner}, args{&apiv1.CreateCRLRequest{
+			RevocationList: revocationList(),
+		}}, true},
+		{"fail no revocation list", fields{testIssuer, testSigner, nil}, args{&apiv1.CreateCRLRequest{}}, true},
+		{"fail bad signer", fields{testIssuer, &badSigner{}, nil}, args{&apiv1.CreateCRLRequest{
+			RevocationList: revocationList(),
+		}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &SoftCAS{
+				CertificateChain:  []*x509.Certificate{tt.fields.Issuer},
+				Signer:            tt.fields.Signer,
+				CertificateSigner: tt.fields.CertificateSigner,
+			}
+			got, err := c.CreateCRL(tt.args.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SoftCAS.CreateCRL() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			crl, err := x509.ParseCRL(got.CRL)
+			if err != nil {
+				t.Fatalf("x509.ParseCRL() error = %v", err)
+			}
+			if err := testIssuer.CheckCRLSignature(crl); err != nil {
+				t.Errorf("CheckCRLSignature() error = %v", err)
+			}
+			if n := len(crl.TBSCertList.RevokedCertificates); n != 1 {
+				t.Errorf("SoftCAS.CreateCRL() revoked certificates = %d, want 1", n)
+			}
+		})
+	}
+}
+
 func Test_now(t *testing.T) {
 	t0 := time.Now()
 	t1 := now()
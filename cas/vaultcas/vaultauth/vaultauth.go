@@ -0,0 +1,474 @@
+// Package vaultauth implements the authentication methods used to connect to
+// HashiCorp Vault, and the renewal of the Vault token. It is shared by the
+// Vault CAS and the Vault KMS.
+package vaultauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+	auth "github.com/hashicorp/vault/api/auth/approle"
+)
+
+// Supported authentication methods.
+const (
+	AppRoleAuthType    = "approle"
+	KubernetesAuthType = "kubernetes"
+	CertAuthType       = "cert"
+	TokenAuthType      = "token"
+)
+
+// defaultKubernetesTokenPath is the path where Kubernetes mounts the service
+// account token in a pod.
+const defaultKubernetesTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+const (
+	// minLoginBackoff is the time to wait before the first retry of a failed
+	// login.
+	minLoginBackoff = time.Second
+	// maxLoginBackoff is the maximum time between two login attempts.
+	maxLoginBackoff = time.Minute
+)
+
+// Options defines the options used to authenticate with Vault.
+//
+// The AuthType defines the method used to authenticate with Vault, "approle"
+// by default, "kubernetes", "cert" or "token". AppRole uses the RoleID,
+// SecretID, AppRole and IsWrappingToken options, Kubernetes uses the
+// KubernetesRole and KubernetesTokenPath, TLS certificates use the ClientCert,
+// ClientKey and CertRole, and the token auth uses the Token or TokenFile.
+type Options struct {
+	AuthType            string        `json:"authType,omitempty"`
+	AuthMountPath       string        `json:"authMountPath,omitempty"`
+	RoleID              string        `json:"roleID,omitempty"`
+	SecretID            auth.SecretID `json:"secretID,omitempty"`
+	AppRole             string        `json:"appRole,omitempty"`
+	IsWrappingToken     bool          `json:"isWrappingToken,omitempty"`
+	KubernetesRole      string        `json:"kubernetesRole,omitempty"`
+	KubernetesTokenPath string        `json:"kubernetesTokenPath,omitempty"`
+	CertRole            string        `json:"certRole,omitempty"`
+	ClientCert          string        `json:"clientCert,omitempty"`
+	ClientKey           string        `json:"clientKey,omitempty"`
+	Token               string        `json:"token,omitempty"`
+	TokenFile           string        `json:"tokenFile,omitempty"`
+}
+
+// Validate validates the options of the configured auth method and sets the
+// default values.
+func (o *Options) Validate() error {
+	switch o.AuthType {
+	case "", AppRoleAuthType:
+		if o.RoleID == "" {
+			return errors.New("vault auth options must define `roleID`")
+		}
+		if o.SecretID.FromEnv == "" && o.SecretID.FromFile == "" && o.SecretID.FromString == "" {
+			return errors.New("vault auth options must define `secretID` object with one of `FromEnv`, `FromFile` or `FromString`")
+		}
+		if o.AppRole == "" {
+			o.AppRole = "auth/approle"
+		}
+	case KubernetesAuthType:
+		if o.KubernetesRole == "" {
+			return errors.New("vault auth options must define `kubernetesRole`")
+		}
+		if o.KubernetesTokenPath == "" {
+			o.KubernetesTokenPath = defaultKubernetesTokenPath
+		}
+		if o.AuthMountPath == "" {
+			o.AuthMountPath = "kubernetes"
+		}
+	case CertAuthType:
+		if o.ClientCert == "" || o.ClientKey == "" {
+			return errors.New("vault auth options must define `clientCert` and `clientKey`")
+		}
+		if o.AuthMountPath == "" {
+			o.AuthMountPath = "cert"
+		}
+	case TokenAuthType:
+		if o.Token == "" && o.TokenFile == "" {
+			return errors.New("vault auth options must define `token` or `tokenFile`")
+		}
+	default:
+		return fmt.Errorf("vault auth options `authType` %q is not supported", o.AuthType)
+	}
+	return nil
+}
+
+// ConfigureTLS sets in the given configuration the client certificate used by
+// the cert auth method. It does nothing for other auth methods.
+func (o *Options) ConfigureTLS(config *vault.Config) error {
+	if o.AuthType != CertAuthType {
+		return nil
+	}
+	if err := config.ConfigureTLS(&vault.TLSConfig{
+		ClientCert: o.ClientCert,
+		ClientKey:  o.ClientKey,
+	}); err != nil {
+		return fmt.Errorf("unable to configure vault client certificate: %w", err)
+	}
+	return nil
+}
+
+// authType returns the name of the configured auth method.
+func (o *Options) authType() string {
+	if o.AuthType == "" {
+		return AppRoleAuthType
+	}
+	return o.AuthType
+}
+
+// newAuthMethod returns the vault.AuthMethod configured in the given options.
+func newAuthMethod(o *Options) (vault.AuthMethod, error) {
+	switch o.AuthType {
+	case "", AppRoleAuthType:
+		if o.IsWrappingToken {
+			return &wrappedAppRoleAuth{
+				roleID:    o.RoleID,
+				secretID:  o.SecretID,
+				mountPath: o.AppRole,
+			}, nil
+		}
+		appRoleAuth, err := auth.NewAppRoleAuth(o.RoleID, &o.SecretID, auth.WithMountPath(o.AppRole))
+		if err != nil {
+			return nil, fmt.Errorf("unable to initialize AppRole auth method: %w", err)
+		}
+		return appRoleAuth, nil
+	case KubernetesAuthType:
+		return &kubernetesAuth{
+			mountPath: o.AuthMountPath,
+			role:      o.KubernetesRole,
+			tokenPath: o.KubernetesTokenPath,
+		}, nil
+	case CertAuthType:
+		return &certAuth{
+			mountPath: o.AuthMountPath,
+			role:      o.CertRole,
+		}, nil
+	case TokenAuthType:
+		return &tokenAuth{
+			token:     o.Token,
+			tokenFile: o.TokenFile,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported vault authType %q", o.AuthType)
+	}
+}
+
+// kubernetesAuth is a vault.AuthMethod that logs in using the token of the
+// Kubernetes service account of the pod.
+type kubernetesAuth struct {
+	mountPath string
+	role      string
+	tokenPath string
+}
+
+// Login implements the vault.AuthMethod interface. The service account token is
+// read on every login, as Kubernetes rotates projected tokens.
+func (a *kubernetesAuth) Login(ctx context.Context, client *vault.Client) (*vault.Secret, error) {
+	jwt, err := os.ReadFile(a.tokenPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading service account token: %w", err)
+	}
+	secret, err := writeWithContext(ctx, client, "auth/"+a.mountPath+"/login", map[string]interface{}{
+		"role": a.role,
+		"jwt":  strings.TrimSpace(string(jwt)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to log in with kubernetes auth: %w", err)
+	}
+	return secret, nil
+}
+
+// certAuth is a vault.AuthMethod that logs in using the TLS client
+// certificate configured in the client.
+type certAuth struct {
+	mountPath string
+	role      string
+}
+
+// Login implements the vault.AuthMethod interface.
+func (a *certAuth) Login(ctx context.Context, client *vault.Client) (*vault.Secret, error) {
+	data := map[string]interface{}{}
+	if a.role != "" {
+		data["name"] = a.role
+	}
+	secret, err := writeWithContext(ctx, client, "auth/"+a.mountPath+"/login", data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to log in with cert auth: %w", err)
+	}
+	return secret, nil
+}
+
+// wrappedAppRoleAuth is a vault.AuthMethod that logs in with AppRole using a
+// secret ID wrapped in a response-wrapping token. A wrapping token can only be
+// used once, so the secret ID is unwrapped on the first login and reused on the
+// next ones.
+type wrappedAppRoleAuth struct {
+	roleID    string
+	secretID  auth.SecretID
+	mountPath string
+	mu        sync.Mutex
+	method    vault.AuthMethod
+}
+
+// Login implements the vault.AuthMethod interface.
+func (a *wrappedAppRoleAuth) Login(ctx context.Context, client *vault.Client) (*vault.Secret, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.method == nil {
+		secretID, err := a.unwrapSecretID(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		method, err := auth.NewAppRoleAuth(a.roleID, &auth.SecretID{FromString: secretID}, auth.WithMountPath(a.mountPath))
+		if err != nil {
+			return nil, fmt.Errorf("unable to initialize AppRole auth method: %w", err)
+		}
+		a.method = method
+	}
+	return a.method.Login(ctx, client)
+}
+
+// unwrapSecretID reads the wrapping token and returns the secret ID wrapped
+// in it.
+func (a *wrappedAppRoleAuth) unwrapSecretID(ctx context.Context, client *vault.Client) (string, error) {
+	var token string
+	switch {
+	case a.secretID.FromFile != "":
+		b, err := os.ReadFile(a.secretID.FromFile)
+		if err != nil {
+			return "", fmt.Errorf("error reading secret ID: %w", err)
+		}
+		token = string(b)
+	case a.secretID.FromEnv != "":
+		token = os.Getenv(a.secretID.FromEnv)
+	default:
+		token = a.secretID.FromString
+	}
+	if token = strings.TrimSpace(token); token == "" {
+		return "", errors.New("secret ID wrapping token is empty")
+	}
+
+	r := client.NewRequest("PUT", "/v1/sys/wrapping/unwrap")
+	r.ClientToken = token
+	secret, err := doRequest(ctx, client, r)
+	if err != nil {
+		return "", fmt.Errorf("unable to unwrap response wrapping token: %w", err)
+	}
+	if secret == nil || secret.Data == nil {
+		return "", errors.New("unable to unwrap response wrapping token: empty response")
+	}
+	secretID, ok := secret.Data["secret_id"].(string)
+	if !ok || secretID == "" {
+		return "", errors.New("unable to unwrap response wrapping token: secret ID not found")
+	}
+	return secretID, nil
+}
+
+// tokenAuth is a vault.AuthMethod that uses a static or periodic token. The
+// token file, if used, is read on every login, so an external process like the
+// Vault agent can replace an expired token.
+type tokenAuth struct {
+	token     string
+	tokenFile string
+}
+
+// Login implements the vault.AuthMethod interface. It looks up the token to
+// get its lease, so it can be renewed.
+func (a *tokenAuth) Login(ctx context.Context, client *vault.Client) (*vault.Secret, error) {
+	token := a.token
+	if a.tokenFile != "" {
+		b, err := os.ReadFile(a.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading token: %w", err)
+		}
+		token = strings.TrimSpace(string(b))
+	}
+
+	client.SetToken(token)
+	secret, err := client.Auth().Token().LookupSelf()
+	if err != nil {
+		return nil, fmt.Errorf("unable to look up token: %w", err)
+	}
+	renewable, err := secret.TokenIsRenewable()
+	if err != nil {
+		return nil, fmt.Errorf("unable to look up token: %w", err)
+	}
+	ttl, err := secret.TokenTTL()
+	if err != nil {
+		return nil, fmt.Errorf("unable to look up token: %w", err)
+	}
+
+	return &vault.Secret{
+		Auth: &vault.SecretAuth{
+			ClientToken:   token,
+			Renewable:     renewable,
+			LeaseDuration: int(ttl.Seconds()),
+		},
+	}, nil
+}
+
+// writeWithContext writes the data to the given path like
+// client.Logical().Write, but it uses the context in the request, the version
+// of the Vault client used does not have Logical().WriteWithContext.
+func writeWithContext(ctx context.Context, client *vault.Client, path string, data map[string]interface{}) (*vault.Secret, error) {
+	r := client.NewRequest("PUT", "/v1/"+path)
+	if err := r.SetJSONBody(data); err != nil {
+		return nil, err
+	}
+	return doRequest(ctx, client, r)
+}
+
+// doRequest sends the given request and parses the secret in the response.
+func doRequest(ctx context.Context, client *vault.Client, r *vault.Request) (*vault.Secret, error) {
+	resp, err := client.RawRequestWithContext(ctx, r)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return vault.ParseSecret(resp.Body)
+}
+
+// Authenticator logs in to Vault with the configured auth method and keeps the
+// token of the client valid.
+type Authenticator struct {
+	client   *vault.Client
+	method   vault.AuthMethod
+	authType string
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// New logs in to Vault using the given client and options, and starts the
+// renewal of the token in the background. The options must be validated
+// first. Close must be called to stop the renewal.
+func New(ctx context.Context, client *vault.Client, o *Options) (*Authenticator, error) {
+	method, err := newAuthMethod(o)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Authenticator{
+		client:   client,
+		method:   method,
+		authType: o.authType(),
+		done:     make(chan struct{}),
+	}
+
+	authInfo, err := a.login(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the token valid in the background.
+	ctx, a.cancel = context.WithCancel(ctx)
+	go a.renewToken(ctx, authInfo)
+
+	return a, nil
+}
+
+// Close stops the renewal of the Vault token.
+func (a *Authenticator) Close() error {
+	if a.cancel != nil {
+		a.cancel()
+		<-a.done
+	}
+	return nil
+}
+
+// login logs in to Vault with the configured auth method and sets the token of
+// the client.
+func (a *Authenticator) login(ctx context.Context) (*vault.Secret, error) {
+	authInfo, err := a.client.Auth().Login(ctx, a.method)
+	if err != nil {
+		return nil, fmt.Errorf("unable to login to %s auth method: %w", a.authType, err)
+	}
+	if authInfo == nil {
+		return nil, errors.New("no auth info was returned after login")
+	}
+	return authInfo, nil
+}
+
+// renewToken keeps the token of the client valid until the given context is
+// done. The token is renewed while its lease allows it, and a new login is
+// done when it cannot be renewed anymore or it reaches its maximum TTL.
+func (a *Authenticator) renewToken(ctx context.Context, authInfo *vault.Secret) {
+	defer close(a.done)
+	for {
+		if err := a.watchToken(ctx, authInfo); err != nil {
+			log.Printf("error renewing vault token: %v", err)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if authInfo = a.relogin(ctx); authInfo == nil {
+			return
+		}
+	}
+}
+
+// watchToken renews the token in the given secret until it expires or the
+// context is done.
+func (a *Authenticator) watchToken(ctx context.Context, authInfo *vault.Secret) error {
+	ttl, err := authInfo.TokenTTL()
+	if err != nil {
+		return err
+	}
+	// Tokens without a TTL never expire.
+	if ttl == 0 {
+		<-ctx.Done()
+		return nil
+	}
+
+	watcher, err := a.client.NewLifetimeWatcher(&vault.LifetimeWatcherInput{
+		Secret: authInfo,
+	})
+	if err != nil {
+		return err
+	}
+	go watcher.Start()
+	defer watcher.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-watcher.DoneCh():
+			return err
+		case <-watcher.RenewCh():
+		}
+	}
+}
+
+// relogin logs in again, retrying with an exponential backoff until it
+// succeeds. It returns nil if the context is done first.
+func (a *Authenticator) relogin(ctx context.Context) *vault.Secret {
+	backoff := minLoginBackoff
+	for {
+		authInfo, err := a.login(ctx)
+		if err == nil {
+			return authInfo
+		}
+		log.Printf("error renewing vault token: %v", err)
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil
+		case <-t.C:
+		}
+		if backoff *= 2; backoff > maxLoginBackoff {
+			backoff = maxLoginBackoff
+		}
+	}
+}
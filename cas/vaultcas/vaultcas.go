@@ -10,14 +10,18 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
 	"math/big"
+	"net/http"
 	"strings"
 	"time"
 
 	"github.com/smallstep/certificates/cas/apiv1"
+	"github.com/smallstep/certificates/cas/vaultcas/vaultauth"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 
 	vault "github.com/hashicorp/vault/api"
-	auth "github.com/hashicorp/vault/api/auth/approle"
 )
 
 func init() {
@@ -27,17 +31,15 @@ func init() {
 }
 
 // VaultOptions defines the configuration options added using the
-// apiv1.Options.Config field.
+// apiv1.Options.Config field. The options used to authenticate with Vault are
+// defined in vaultauth.Options.
 type VaultOptions struct {
-	PKI             string        `json:"pki,omitempty"`
-	PKIRoleDefault  string        `json:"pkiRoleDefault,omitempty"`
-	PKIRoleRSA      string        `json:"pkiRoleRSA,omitempty"`
-	PKIRoleEC       string        `json:"pkiRoleEC,omitempty"`
-	PKIRoleEd25519  string        `json:"pkiRoleEd25519,omitempty"`
-	RoleID          string        `json:"roleID,omitempty"`
-	SecretID        auth.SecretID `json:"secretID,omitempty"`
-	AppRole         string        `json:"appRole,omitempty"`
-	IsWrappingToken bool          `json:"isWrappingToken,omitempty"`
+	PKI            string `json:"pki,omitempty"`
+	PKIRoleDefault string `json:"pkiRoleDefault,omitempty"`
+	PKIRoleRSA     string `json:"pkiRoleRSA,omitempty"`
+	PKIRoleEC      string `json:"pkiRoleEC,omitempty"`
+	PKIRoleEd25519 string `json:"pkiRoleEd25519,omitempty"`
+	vaultauth.Options
 }
 
 // VaultCAS implements a Certificate Authority Service using Hashicorp Vault.
@@ -45,6 +47,7 @@ type VaultCAS struct {
 	client      *vault.Client
 	config      VaultOptions
 	fingerprint string
+	auth        *vaultauth.Authenticator
 }
 
 type certBundle struct {
@@ -71,46 +74,36 @@ func New(ctx context.Context, opts apiv1.Options) (*VaultCAS, error) {
 
 	config := vault.DefaultConfig()
 	config.Address = opts.CertificateAuthority
+	if err := vc.ConfigureTLS(config); err != nil {
+		return nil, err
+	}
 
 	client, err := vault.NewClient(config)
 	if err != nil {
 		return nil, fmt.Errorf("unable to initialize vault client: %w", err)
 	}
 
-	var appRoleAuth *auth.AppRoleAuth
-	if vc.IsWrappingToken {
-		appRoleAuth, err = auth.NewAppRoleAuth(
-			vc.RoleID,
-			&vc.SecretID,
-			auth.WithWrappingToken(),
-			auth.WithMountPath(vc.AppRole),
-		)
-	} else {
-		appRoleAuth, err = auth.NewAppRoleAuth(
-			vc.RoleID,
-			&vc.SecretID,
-			auth.WithMountPath(vc.AppRole),
-		)
-	}
-	if err != nil {
-		return nil, fmt.Errorf("unable to initialize AppRole auth method: %w", err)
-	}
-
-	authInfo, err := client.Auth().Login(ctx, appRoleAuth)
+	a, err := vaultauth.New(ctx, client, &vc.Options)
 	if err != nil {
-		return nil, fmt.Errorf("unable to login to AppRole auth method: %w", err)
-	}
-	if authInfo == nil {
-		return nil, errors.New("no auth info was returned after login")
+		return nil, err
 	}
 
 	return &VaultCAS{
 		client:      client,
 		config:      *vc,
 		fingerprint: opts.CertificateAuthorityFingerprint,
+		auth:        a,
 	}, nil
 }
 
+// Close stops the renewal of the Vault token.
+func (v *VaultCAS) Close() error {
+	if v.auth != nil {
+		return v.auth.Close()
+	}
+	return nil
+}
+
 // CreateCertificate signs a new certificate using Hashicorp Vault.
 func (v *VaultCAS) CreateCertificate(req *apiv1.CreateCertificateRequest) (*apiv1.CreateCertificateResponse, error) {
 	switch {
@@ -120,7 +113,12 @@ func (v *VaultCAS) CreateCertificate(req *apiv1.CreateCertificateRequest) (*apiv
 		return nil, errors.New("createCertificate `lifetime` cannot be 0")
 	}
 
-	cert, chain, err := v.createCertificate(req.CSR, req.Lifetime)
+	ctx := req.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	cert, chain, err := v.createCertificate(ctx, req.CSR, req.Lifetime)
 	if err != nil {
 		return nil, err
 	}
@@ -165,13 +163,71 @@ func (v *VaultCAS) GetCertificateAuthority(req *apiv1.GetCertificateAuthorityReq
 	}, nil
 }
 
-// RenewCertificate will always return a non-implemented error as renewals
-// are not supported yet.
+// RenewCertificate re-issues a certificate using the PKI role for the key of
+// the certificate request and the lifetime of the original certificate. Vault
+// requires a proof of possession of the key, so the renewals without a
+// certificate request, like the mTLS renewals that keep the same key, are not
+// supported.
 func (v *VaultCAS) RenewCertificate(req *apiv1.RenewCertificateRequest) (*apiv1.RenewCertificateResponse, error) {
-	return nil, apiv1.ErrNotImplemented{Message: "vaultCAS does not support renewals"}
+	switch {
+	case req.CSR == nil:
+		return nil, apiv1.ErrNotImplemented{Message: "vaultCAS does not support renewals without a certificate request"}
+	case req.Lifetime == 0:
+		return nil, errors.New("renewCertificate `lifetime` cannot be 0")
+	}
+	if err := req.CSR.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("renewCertificate `csr` signature is not valid: %w", err)
+	}
+
+	// Vault signs the names in the request, so they must be present in the
+	// certificate being renewed.
+	if req.Template != nil {
+		if err := validateRenewRequest(req.CSR, req.Template); err != nil {
+			return nil, err
+		}
+	}
+
+	cert, chain, err := v.createCertificate(context.Background(), req.CSR, req.Lifetime)
+	if err != nil {
+		return nil, err
+	}
+
+	return &apiv1.RenewCertificateResponse{
+		Certificate:      cert,
+		CertificateChain: chain,
+	}, nil
+}
+
+// GetCertificateRevocationList returns the CRL maintained by the Vault PKI
+// secrets engine.
+func (v *VaultCAS) GetCertificateRevocationList(req *apiv1.GetCertificateRevocationListRequest) (*apiv1.GetCertificateRevocationListResponse, error) {
+	r := v.client.NewRequest(http.MethodGet, "/v1/"+v.config.PKI+"/crl")
+	resp, err := v.client.RawRequestWithContext(context.Background(), r)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading crl: %w", err)
+	}
+
+	crl, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading crl: %w", err)
+	}
+	if len(crl) == 0 {
+		return nil, errors.New("error reading crl: response is empty")
+	}
+	if _, err := x509.ParseDERCRL(crl); err != nil {
+		return nil, fmt.Errorf("error parsing crl: %w", err)
+	}
+
+	return &apiv1.GetCertificateRevocationListResponse{
+		CRL: crl,
+	}, nil
 }
 
-// RevokeCertificate revokes a certificate by serial number.
+// RevokeCertificate revokes a certificate by serial number. The reason and the
+// reason code, if present, are forwarded to Vault.
 func (v *VaultCAS) RevokeCertificate(req *apiv1.RevokeCertificateRequest) (*apiv1.RevokeCertificateResponse, error) {
 	if req.SerialNumber == "" && req.Certificate == nil {
 		return nil, errors.New("revokeCertificate `serialNumber` or `certificate` are required")
@@ -190,6 +246,12 @@ func (v *VaultCAS) RevokeCertificate(req *apiv1.RevokeCertificateRequest) (*apiv
 	vaultReq := map[string]interface{}{
 		"serial_number": formatSerialNumber(sn),
 	}
+	if req.ReasonCode != 0 {
+		vaultReq["reason_code"] = req.ReasonCode
+	}
+	if req.Reason != "" {
+		vaultReq["reason"] = req.Reason
+	}
 	_, err := v.client.Logical().Write(v.config.PKI+"/revoke/", vaultReq)
 	if err != nil {
 		return nil, fmt.Errorf("error revoking certificate: %w", err)
@@ -201,30 +263,41 @@ func (v *VaultCAS) RevokeCertificate(req *apiv1.RevokeCertificateRequest) (*apiv
 	}, nil
 }
 
-func (v *VaultCAS) createCertificate(cr *x509.CertificateRequest, lifetime time.Duration) (*x509.Certificate, []*x509.Certificate, error) {
-	var vaultPKIRole string
+func (v *VaultCAS) createCertificate(ctx context.Context, cr *x509.CertificateRequest, lifetime time.Duration) (*x509.Certificate, []*x509.Certificate, error) {
+	vaultPKIRole, err := v.getRole(cr.PublicKeyAlgorithm)
+	if err != nil {
+		return nil, nil, err
+	}
+	return v.sign(ctx, "/sign/"+vaultPKIRole, cr.Raw, lifetime)
+}
 
-	switch {
-	case cr.PublicKeyAlgorithm == x509.RSA:
-		vaultPKIRole = v.config.PKIRoleRSA
-	case cr.PublicKeyAlgorithm == x509.ECDSA:
-		vaultPKIRole = v.config.PKIRoleEC
-	case cr.PublicKeyAlgorithm == x509.Ed25519:
-		vaultPKIRole = v.config.PKIRoleEd25519
+// getRole returns the PKI role used for the given public key algorithm.
+func (v *VaultCAS) getRole(alg x509.PublicKeyAlgorithm) (string, error) {
+	switch alg {
+	case x509.RSA:
+		return v.config.PKIRoleRSA, nil
+	case x509.ECDSA:
+		return v.config.PKIRoleEC, nil
+	case x509.Ed25519:
+		return v.config.PKIRoleEd25519, nil
 	default:
-		return nil, nil, fmt.Errorf("unsupported public key algorithm %v", cr.PublicKeyAlgorithm)
+		return "", fmt.Errorf("unsupported public key algorithm %v", alg)
 	}
+}
 
+// sign sends the given certificate request to a signing path of the PKI
+// secrets engine, and returns the certificate and the intermediates.
+func (v *VaultCAS) sign(ctx context.Context, path string, csr []byte, lifetime time.Duration) (*x509.Certificate, []*x509.Certificate, error) {
 	vaultReq := map[string]interface{}{
 		"csr": string(pem.EncodeToMemory(&pem.Block{
 			Type:  "CERTIFICATE REQUEST",
-			Bytes: cr.Raw,
+			Bytes: csr,
 		})),
 		"format": "pem_bundle",
 		"ttl":    lifetime.Seconds(),
 	}
 
-	secret, err := v.client.Logical().Write(v.config.PKI+"/sign/"+vaultPKIRole, vaultReq)
+	secret, err := v.clientWithContext(ctx).Logical().Write(v.config.PKI+path, vaultReq)
 	if err != nil {
 		return nil, nil, fmt.Errorf("error signing certificate: %w", err)
 	}
@@ -246,6 +319,58 @@ func (v *VaultCAS) createCertificate(cr *x509.CertificateRequest, lifetime time.
 	return cert.leaf, cert.intermediates, nil
 }
 
+// validateRenewRequest checks that the subject and the subject alternative
+// names of the certificate request are included in the given template.
+func validateRenewRequest(cr *x509.CertificateRequest, tmpl *x509.Certificate) error {
+	if cr.Subject.CommonName != "" && cr.Subject.CommonName != tmpl.Subject.CommonName {
+		return fmt.Errorf("renewCertificate `csr` common name %q does not match the certificate", cr.Subject.CommonName)
+	}
+	for _, name := range cr.DNSNames {
+		if !containsString(tmpl.DNSNames, name) {
+			return fmt.Errorf("renewCertificate `csr` dns name %q does not match the certificate", name)
+		}
+	}
+	for _, email := range cr.EmailAddresses {
+		if !containsString(tmpl.EmailAddresses, email) {
+			return fmt.Errorf("renewCertificate `csr` email address %q does not match the certificate", email)
+		}
+	}
+	for _, ip := range cr.IPAddresses {
+		var found bool
+		for _, tip := range tmpl.IPAddresses {
+			if ip.Equal(tip) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("renewCertificate `csr` ip address %q does not match the certificate", ip)
+		}
+	}
+	for _, u := range cr.URIs {
+		var found bool
+		for _, tu := range tmpl.URIs {
+			if u.String() == tu.String() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("renewCertificate `csr` uri %q does not match the certificate", u)
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
 func loadOptions(config json.RawMessage) (*VaultOptions, error) {
 	var vc *VaultOptions
 
@@ -272,20 +397,8 @@ func loadOptions(config json.RawMessage) (*VaultOptions, error) {
 		vc.PKIRoleEd25519 = vc.PKIRoleDefault
 	}
 
-	if vc.RoleID == "" {
-		return nil, errors.New("vaultCAS config options must define `roleID`")
-	}
-
-	if vc.SecretID.FromEnv == "" && vc.SecretID.FromFile == "" && vc.SecretID.FromString == "" {
-		return nil, errors.New("vaultCAS config options must define `secretID` object with one of `FromEnv`, `FromFile` or `FromString`")
-	}
-
-	if vc.PKI == "" {
-		vc.PKI = "pki" // use default pki vault name
-	}
-
-	if vc.AppRole == "" {
-		vc.AppRole = "auth/approle"
+	if err := vc.Options.Validate(); err != nil {
+		return nil, fmt.Errorf("error validating vaultCAS config: %w", err)
 	}
 
 	return vc, nil
@@ -353,3 +466,14 @@ func formatSerialNumber(sn *big.Int) string {
 	}
 	return ret.String()
 }
+
+// clientWithContext returns a client that propagates the trace in the given
+// context, if any, in the headers of the requests to Vault.
+func (v *VaultCAS) clientWithContext(ctx context.Context) *vault.Client {
+	return v.client.WithRequestCallbacks(func(r *vault.Request) {
+		if r.Headers == nil {
+			r.Headers = make(http.Header)
+		}
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(r.Headers))
+	})
+}
@@ -3,19 +3,28 @@ package vaultcas
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
 	vault "github.com/hashicorp/vault/api"
 	auth "github.com/hashicorp/vault/api/auth/approle"
 	"github.com/smallstep/certificates/cas/apiv1"
+	"github.com/smallstep/certificates/cas/vaultcas/vaultauth"
 	"go.step.sm/crypto/pemutil"
 )
 
@@ -90,6 +99,20 @@ func mustParseCertificateRequest(t *testing.T, pemData string) *x509.Certificate
 	return csr
 }
 
+// mustTamperCertificateRequest returns the given certificate request with an
+// invalid signature.
+func mustTamperCertificateRequest(t *testing.T, pemData string) *x509.CertificateRequest {
+	t.Helper()
+	csr := mustParseCertificateRequest(t, pemData)
+	der := append([]byte{}, csr.Raw...)
+	der[len(der)-1] ^= 0xff
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return csr
+}
+
 func testCAHelper(t *testing.T) (*url.URL, *vault.Client) {
 	t.Helper()
 
@@ -106,17 +129,51 @@ func testCAHelper(t *testing.T) (*url.URL, *vault.Client) {
 					"client_token": "98a4c7ab-b1fe-361b-ba0b-e307aacfd587"
 				  }
 				}`)
-		case r.RequestURI == "/v1/pki/sign/ec":
+		case r.RequestURI == "/v1/auth/kubernetes/login", r.RequestURI == "/v1/auth/cert/login":
 			w.WriteHeader(http.StatusOK)
-			cert := map[string]interface{}{"data": map[string]interface{}{"certificate": testCertificateSigned + "\n" + testRootCertificate}}
-			writeJSON(w, cert)
-			return
-		case r.RequestURI == "/v1/pki/sign/rsa":
+			fmt.Fprintf(w, `{
+				  "auth": {
+					"client_token": "98a4c7ab-b1fe-361b-ba0b-e307aacfd587",
+					"lease_duration": 3600,
+					"renewable": true
+				  }
+				}`)
+		case r.RequestURI == "/v1/auth/token/lookup-self":
+			if r.Header.Get("X-Vault-Token") != "token" {
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprintf(w, `{"errors":["permission denied"]}`)
+				return
+			}
 			w.WriteHeader(http.StatusOK)
-			cert := map[string]interface{}{"data": map[string]interface{}{"certificate": testCertificateSigned + "\n" + testRootCertificate}}
-			writeJSON(w, cert)
-			return
-		case r.RequestURI == "/v1/pki/sign/ed25519":
+			fmt.Fprintf(w, `{
+				  "data": {
+					"ttl": 0,
+					"renewable": false
+				  }
+				}`)
+		case r.RequestURI == "/v1/auth/token/renew-self":
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintf(w, `{
+				  "auth": {
+					"client_token": "98a4c7ab-b1fe-361b-ba0b-e307aacfd587",
+					"lease_duration": 3600,
+					"renewable": true
+				  }
+				}`)
+		case r.RequestURI == "/v1/pki/sign/ec", r.RequestURI == "/v1/pki/sign/rsa", r.RequestURI == "/v1/pki/sign/ed25519":
+			// Vault requires a proof of possession of the key.
+			var m map[string]interface{}
+			if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			csr, _ := m["csr"].(string)
+			cr, err := pemutil.ParseCertificateRequest([]byte(csr))
+			if err != nil || cr.CheckSignature() != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, `{"errors":["certificate request signature is not valid"]}`)
+				return
+			}
 			w.WriteHeader(http.StatusOK)
 			cert := map[string]interface{}{"data": map[string]interface{}{"certificate": testCertificateSigned + "\n" + testRootCertificate}}
 			writeJSON(w, cert)
@@ -129,9 +186,12 @@ func testCAHelper(t *testing.T) (*url.URL, *vault.Client) {
 		case r.RequestURI == "/v1/pki/revoke":
 			buf := new(bytes.Buffer)
 			buf.ReadFrom(r.Body)
-			m := make(map[string]string)
+			m := make(map[string]interface{})
 			json.Unmarshal(buf.Bytes(), &m)
 			switch {
+			case m["serial_number"] == "03-09" && m["reason"] == "key compromised" && m["reason_code"] == float64(1):
+				w.WriteHeader(http.StatusOK)
+				return
 			case m["serial_number"] == "1c-71-6e-18-cc-f4-70-29-5f-75-ee-64-a8-fe-69-ad":
 				w.WriteHeader(http.StatusOK)
 				return
@@ -197,6 +257,182 @@ func TestNew_register(t *testing.T) {
 	}
 }
 
+func TestNew(t *testing.T) {
+	caURL, _ := testCAHelper(t)
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		config  string
+		wantErr bool
+	}{
+		{"ok approle", `{"RoleID": "roleID", "SecretID": {"FromString": "secretID"}}`, false},
+		{"ok kubernetes", `{"AuthType": "kubernetes", "KubernetesRole": "step-ca", "KubernetesTokenPath": "` + tokenPath + `"}`, false},
+		{"ok token", `{"AuthType": "token", "Token": "token"}`, false},
+		{"ok token with TokenFile", `{"AuthType": "token", "TokenFile": "` + tokenPath + `"}`, false},
+		{"fail kubernetes missing token", `{"AuthType": "kubernetes", "KubernetesRole": "step-ca", "KubernetesTokenPath": "testdata/missing"}`, true},
+		{"fail token permission denied", `{"AuthType": "token", "Token": "bad-token"}`, true},
+		{"fail cert missing files", `{"AuthType": "cert", "ClientCert": "testdata/missing.crt", "ClientKey": "testdata/missing.key"}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(context.Background(), apiv1.Options{
+				CertificateAuthority:            caURL.String(),
+				CertificateAuthorityFingerprint: testRootFingerprint,
+				Config:                          json.RawMessage(tt.config),
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				if got.client.Token() == "" {
+					t.Error("New() client token is empty")
+				}
+				if err := got.Close(); err != nil {
+					t.Errorf("VaultCAS.Close() error = %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestVaultCAS_renewToken(t *testing.T) {
+	var mu sync.Mutex
+	var logins int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.RequestURI {
+		case "/v1/auth/kubernetes/login":
+			mu.Lock()
+			logins++
+			mu.Unlock()
+			// A token that expires in one second and cannot be renewed.
+			fmt.Fprintf(w, `{
+				  "auth": {
+					"client_token": "98a4c7ab-b1fe-361b-ba0b-e307aacfd587",
+					"lease_duration": 1,
+					"renewable": false
+				  }
+				}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("token"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := New(context.Background(), apiv1.Options{
+		CertificateAuthority:            srv.URL,
+		CertificateAuthorityFingerprint: testRootFingerprint,
+		Config:                          json.RawMessage(`{"AuthType": "kubernetes", "KubernetesRole": "step-ca", "KubernetesTokenPath": "` + tokenPath + `"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The expired token must be replaced with a new login.
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		mu.Lock()
+		n := logins
+		mu.Unlock()
+		if n >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("VaultCAS.renewToken() did not login again after the token expired")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := v.Close(); err != nil {
+		t.Errorf("VaultCAS.Close() error = %v", err)
+	}
+}
+
+func TestVaultCAS_renewToken_wrappingToken(t *testing.T) {
+	var mu sync.Mutex
+	var logins, unwraps int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.RequestURI {
+		case "/v1/sys/wrapping/unwrap":
+			mu.Lock()
+			unwraps++
+			n := unwraps
+			mu.Unlock()
+			// Wrapping tokens can only be used once.
+			if n > 1 || r.Header.Get("X-Vault-Token") != "wrapping-token" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, `{"errors":["wrapping token is not valid or does not exist"]}`)
+				return
+			}
+			fmt.Fprintf(w, `{"data": {"secret_id": "secretID"}}`)
+		case "/v1/auth/auth/approle/login":
+			var m map[string]interface{}
+			if err := json.NewDecoder(r.Body).Decode(&m); err != nil || m["secret_id"] != "secretID" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, `{"errors":["invalid secret id"]}`)
+				return
+			}
+			mu.Lock()
+			logins++
+			mu.Unlock()
+			// A token that expires in one second and cannot be renewed.
+			fmt.Fprintf(w, `{
+				  "auth": {
+					"client_token": "98a4c7ab-b1fe-361b-ba0b-e307aacfd587",
+					"lease_duration": 1,
+					"renewable": false
+				  }
+				}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	v, err := New(context.Background(), apiv1.Options{
+		CertificateAuthority:            srv.URL,
+		CertificateAuthorityFingerprint: testRootFingerprint,
+		Config:                          json.RawMessage(`{"RoleID": "roleID", "SecretID": {"FromString": "wrapping-token"}, "IsWrappingToken": true}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The new login must reuse the unwrapped secret ID.
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		mu.Lock()
+		n := logins
+		mu.Unlock()
+		if n >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("VaultCAS.renewToken() did not login again after the token expired")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := v.Close(); err != nil {
+		t.Errorf("VaultCAS.Close() error = %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if unwraps != 1 {
+		t.Errorf("VaultCAS.renewToken() unwrapped the secret ID %d times, want 1", unwraps)
+	}
+}
+
 func TestVaultCAS_CreateCertificate(t *testing.T) {
 	_, client := testCAHelper(t)
 
@@ -380,6 +616,14 @@ func TestVaultCAS_RevokeCertificate(t *testing.T) {
 		}}, &apiv1.RevokeCertificateResponse{
 			Certificate: testCrt,
 		}, false},
+		{"ok reason", fields{client, options}, args{&apiv1.RevokeCertificateRequest{
+			SerialNumber: "777",
+			Reason:       "key compromised",
+			ReasonCode:   1,
+		}}, &apiv1.RevokeCertificateResponse{}, false},
+		{"fail reason", fields{client, options}, args{&apiv1.RevokeCertificateRequest{
+			SerialNumber: "777",
+		}}, nil, true},
 		{"fail serial string", fields{client, options}, args{&apiv1.RevokeCertificateRequest{
 			SerialNumber: "fail",
 			Certificate:  nil,
@@ -434,9 +678,44 @@ func TestVaultCAS_RenewCertificate(t *testing.T) {
 		want    *apiv1.RenewCertificateResponse
 		wantErr bool
 	}{
-		{"not implemented", fields{client, options}, args{&apiv1.RenewCertificateRequest{
+		{"ok ec", fields{client, options}, args{&apiv1.RenewCertificateRequest{
 			CSR:      mustParseCertificateRequest(t, testCertificateCsrEc),
 			Lifetime: time.Hour,
+		}}, &apiv1.RenewCertificateResponse{
+			Certificate:      mustParseCertificate(t, testCertificateSigned),
+			CertificateChain: nil,
+		}, false},
+		{"ok rsa", fields{client, options}, args{&apiv1.RenewCertificateRequest{
+			CSR:      mustParseCertificateRequest(t, testCertificateCsrRsa),
+			Lifetime: time.Hour,
+		}}, &apiv1.RenewCertificateResponse{
+			Certificate:      mustParseCertificate(t, testCertificateSigned),
+			CertificateChain: nil,
+		}, false},
+		{"ok with template", fields{client, options}, args{&apiv1.RenewCertificateRequest{
+			Template: &x509.Certificate{Subject: pkix.Name{CommonName: "EC"}, DNSNames: []string{"EC"}},
+			CSR:      mustParseCertificateRequest(t, testCertificateCsrEc),
+			Lifetime: time.Hour,
+		}}, &apiv1.RenewCertificateResponse{
+			Certificate:      mustParseCertificate(t, testCertificateSigned),
+			CertificateChain: nil,
+		}, false},
+		{"fail template names", fields{client, options}, args{&apiv1.RenewCertificateRequest{
+			Template: &x509.Certificate{Subject: pkix.Name{CommonName: "EC"}, DNSNames: []string{"other"}},
+			CSR:      mustParseCertificateRequest(t, testCertificateCsrEc),
+			Lifetime: time.Hour,
+		}}, nil, true},
+		{"fail without CSR", fields{client, options}, args{&apiv1.RenewCertificateRequest{
+			Template: mustParseCertificate(t, testCertificateSigned),
+			Lifetime: time.Hour,
+		}}, nil, true},
+		{"fail CSR signature", fields{client, options}, args{&apiv1.RenewCertificateRequest{
+			CSR:      mustTamperCertificateRequest(t, testCertificateCsrEc),
+			Lifetime: time.Hour,
+		}}, nil, true},
+		{"fail lifetime", fields{client, options}, args{&apiv1.RenewCertificateRequest{
+			CSR:      mustParseCertificateRequest(t, testCertificateCsrEc),
+			Lifetime: 0,
 		}}, nil, true},
 	}
 	for _, tt := range tests {
@@ -457,6 +736,92 @@ func TestVaultCAS_RenewCertificate(t *testing.T) {
 	}
 }
 
+func TestVaultCAS_GetCertificateRevocationList(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Vault Test CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now(),
+		NextUpdate: time.Now().Add(time.Hour),
+		RevokedCertificates: []pkix.RevokedCertificate{
+			{SerialNumber: big.NewInt(123), RevocationTime: time.Now()},
+		},
+	}, issuer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.RequestURI {
+		case "/v1/pki/crl":
+			w.Header().Set("Content-Type", "application/pkix-crl")
+			w.Write(crl)
+		case "/v1/bad/crl":
+			w.Write([]byte("not a crl"))
+		case "/v1/empty/crl":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"errors":["not found"]}`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	config := vault.DefaultConfig()
+	config.Address = srv.URL
+	client, err := vault.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		pki     string
+		want    *apiv1.GetCertificateRevocationListResponse
+		wantErr bool
+	}{
+		{"ok", "pki", &apiv1.GetCertificateRevocationListResponse{CRL: crl}, false},
+		{"fail not found", "missing", nil, true},
+		{"fail parse", "bad", nil, true},
+		{"fail empty", "empty", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &VaultCAS{
+				client: client,
+				config: VaultOptions{PKI: tt.pki},
+			}
+			got, err := v.GetCertificateRevocationList(&apiv1.GetCertificateRevocationListRequest{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VaultCAS.GetCertificateRevocationList() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("VaultCAS.GetCertificateRevocationList() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestVaultCAS_loadOptions(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -468,15 +833,17 @@ func TestVaultCAS_loadOptions(t *testing.T) {
 			"ok mandatory with SecretID FromString",
 			`{"RoleID": "roleID", "SecretID": {"FromString": "secretID"}}`,
 			&VaultOptions{
-				PKI:             "pki",
-				PKIRoleDefault:  "default",
-				PKIRoleRSA:      "default",
-				PKIRoleEC:       "default",
-				PKIRoleEd25519:  "default",
-				RoleID:          "roleID",
-				SecretID:        auth.SecretID{FromString: "secretID"},
-				AppRole:         "auth/approle",
-				IsWrappingToken: false,
+				PKI:            "pki",
+				PKIRoleDefault: "default",
+				PKIRoleRSA:     "default",
+				PKIRoleEC:      "default",
+				PKIRoleEd25519: "default",
+				Options: vaultauth.Options{
+					RoleID:          "roleID",
+					SecretID:        auth.SecretID{FromString: "secretID"},
+					AppRole:         "auth/approle",
+					IsWrappingToken: false,
+				},
 			},
 			false,
 		},
@@ -484,15 +851,17 @@ func TestVaultCAS_loadOptions(t *testing.T) {
 			"ok mandatory with SecretID FromFile",
 			`{"RoleID": "roleID", "SecretID": {"FromFile": "secretID"}}`,
 			&VaultOptions{
-				PKI:             "pki",
-				PKIRoleDefault:  "default",
-				PKIRoleRSA:      "default",
-				PKIRoleEC:       "default",
-				PKIRoleEd25519:  "default",
-				RoleID:          "roleID",
-				SecretID:        auth.SecretID{FromFile: "secretID"},
-				AppRole:         "auth/approle",
-				IsWrappingToken: false,
+				PKI:            "pki",
+				PKIRoleDefault: "default",
+				PKIRoleRSA:     "default",
+				PKIRoleEC:      "default",
+				PKIRoleEd25519: "default",
+				Options: vaultauth.Options{
+					RoleID:          "roleID",
+					SecretID:        auth.SecretID{FromFile: "secretID"},
+					AppRole:         "auth/approle",
+					IsWrappingToken: false,
+				},
 			},
 			false,
 		},
@@ -500,15 +869,17 @@ func TestVaultCAS_loadOptions(t *testing.T) {
 			"ok mandatory with SecretID FromEnv",
 			`{"RoleID": "roleID", "SecretID": {"FromEnv": "secretID"}}`,
 			&VaultOptions{
-				PKI:             "pki",
-				PKIRoleDefault:  "default",
-				PKIRoleRSA:      "default",
-				PKIRoleEC:       "default",
-				PKIRoleEd25519:  "default",
-				RoleID:          "roleID",
-				SecretID:        auth.SecretID{FromEnv: "secretID"},
-				AppRole:         "auth/approle",
-				IsWrappingToken: false,
+				PKI:            "pki",
+				PKIRoleDefault: "default",
+				PKIRoleRSA:     "default",
+				PKIRoleEC:      "default",
+				PKIRoleEd25519: "default",
+				Options: vaultauth.Options{
+					RoleID:          "roleID",
+					SecretID:        auth.SecretID{FromEnv: "secretID"},
+					AppRole:         "auth/approle",
+					IsWrappingToken: false,
+				},
 			},
 			false,
 		},
@@ -516,15 +887,17 @@ func TestVaultCAS_loadOptions(t *testing.T) {
 			"ok mandatory PKIRole PKIRoleEd25519",
 			`{"PKIRoleDefault": "role", "PKIRoleEd25519": "ed25519" , "RoleID": "roleID", "SecretID": {"FromEnv": "secretID"}}`,
 			&VaultOptions{
-				PKI:             "pki",
-				PKIRoleDefault:  "role",
-				PKIRoleRSA:      "role",
-				PKIRoleEC:       "role",
-				PKIRoleEd25519:  "ed25519",
-				RoleID:          "roleID",
-				SecretID:        auth.SecretID{FromEnv: "secretID"},
-				AppRole:         "auth/approle",
-				IsWrappingToken: false,
+				PKI:            "pki",
+				PKIRoleDefault: "role",
+				PKIRoleRSA:     "role",
+				PKIRoleEC:      "role",
+				PKIRoleEd25519: "ed25519",
+				Options: vaultauth.Options{
+					RoleID:          "roleID",
+					SecretID:        auth.SecretID{FromEnv: "secretID"},
+					AppRole:         "auth/approle",
+					IsWrappingToken: false,
+				},
 			},
 			false,
 		},
@@ -532,15 +905,17 @@ func TestVaultCAS_loadOptions(t *testing.T) {
 			"ok mandatory PKIRole PKIRoleEC",
 			`{"PKIRoleDefault": "role", "PKIRoleEC": "ec" , "RoleID": "roleID", "SecretID": {"FromEnv": "secretID"}}`,
 			&VaultOptions{
-				PKI:             "pki",
-				PKIRoleDefault:  "role",
-				PKIRoleRSA:      "role",
-				PKIRoleEC:       "ec",
-				PKIRoleEd25519:  "role",
-				RoleID:          "roleID",
-				SecretID:        auth.SecretID{FromEnv: "secretID"},
-				AppRole:         "auth/approle",
-				IsWrappingToken: false,
+				PKI:            "pki",
+				PKIRoleDefault: "role",
+				PKIRoleRSA:     "role",
+				PKIRoleEC:      "ec",
+				PKIRoleEd25519: "role",
+				Options: vaultauth.Options{
+					RoleID:          "roleID",
+					SecretID:        auth.SecretID{FromEnv: "secretID"},
+					AppRole:         "auth/approle",
+					IsWrappingToken: false,
+				},
 			},
 			false,
 		},
@@ -548,15 +923,17 @@ func TestVaultCAS_loadOptions(t *testing.T) {
 			"ok mandatory PKIRole PKIRoleRSA",
 			`{"PKIRoleDefault": "role", "PKIRoleRSA": "rsa" , "RoleID": "roleID", "SecretID": {"FromEnv": "secretID"}}`,
 			&VaultOptions{
-				PKI:             "pki",
-				PKIRoleDefault:  "role",
-				PKIRoleRSA:      "rsa",
-				PKIRoleEC:       "role",
-				PKIRoleEd25519:  "role",
-				RoleID:          "roleID",
-				SecretID:        auth.SecretID{FromEnv: "secretID"},
-				AppRole:         "auth/approle",
-				IsWrappingToken: false,
+				PKI:            "pki",
+				PKIRoleDefault: "role",
+				PKIRoleRSA:     "rsa",
+				PKIRoleEC:      "role",
+				PKIRoleEd25519: "role",
+				Options: vaultauth.Options{
+					RoleID:          "roleID",
+					SecretID:        auth.SecretID{FromEnv: "secretID"},
+					AppRole:         "auth/approle",
+					IsWrappingToken: false,
+				},
 			},
 			false,
 		},
@@ -564,15 +941,17 @@ func TestVaultCAS_loadOptions(t *testing.T) {
 			"ok mandatory PKIRoleRSA PKIRoleEC PKIRoleEd25519",
 			`{"PKIRoleRSA": "rsa", "PKIRoleEC": "ec", "PKIRoleEd25519": "ed25519", "RoleID": "roleID", "SecretID": {"FromEnv": "secretID"}}`,
 			&VaultOptions{
-				PKI:             "pki",
-				PKIRoleDefault:  "default",
-				PKIRoleRSA:      "rsa",
-				PKIRoleEC:       "ec",
-				PKIRoleEd25519:  "ed25519",
-				RoleID:          "roleID",
-				SecretID:        auth.SecretID{FromEnv: "secretID"},
-				AppRole:         "auth/approle",
-				IsWrappingToken: false,
+				PKI:            "pki",
+				PKIRoleDefault: "default",
+				PKIRoleRSA:     "rsa",
+				PKIRoleEC:      "ec",
+				PKIRoleEd25519: "ed25519",
+				Options: vaultauth.Options{
+					RoleID:          "roleID",
+					SecretID:        auth.SecretID{FromEnv: "secretID"},
+					AppRole:         "auth/approle",
+					IsWrappingToken: false,
+				},
 			},
 			false,
 		},
@@ -580,15 +959,17 @@ func TestVaultCAS_loadOptions(t *testing.T) {
 			"ok mandatory PKIRoleRSA PKIRoleEC PKIRoleEd25519 with useless PKIRoleDefault",
 			`{"PKIRoleDefault": "role", "PKIRoleRSA": "rsa", "PKIRoleEC": "ec", "PKIRoleEd25519": "ed25519", "RoleID": "roleID", "SecretID": {"FromEnv": "secretID"}}`,
 			&VaultOptions{
-				PKI:             "pki",
-				PKIRoleDefault:  "role",
-				PKIRoleRSA:      "rsa",
-				PKIRoleEC:       "ec",
-				PKIRoleEd25519:  "ed25519",
-				RoleID:          "roleID",
-				SecretID:        auth.SecretID{FromEnv: "secretID"},
-				AppRole:         "auth/approle",
-				IsWrappingToken: false,
+				PKI:            "pki",
+				PKIRoleDefault: "role",
+				PKIRoleRSA:     "rsa",
+				PKIRoleEC:      "ec",
+				PKIRoleEd25519: "ed25519",
+				Options: vaultauth.Options{
+					RoleID:          "roleID",
+					SecretID:        auth.SecretID{FromEnv: "secretID"},
+					AppRole:         "auth/approle",
+					IsWrappingToken: false,
+				},
 			},
 			false,
 		},
@@ -596,15 +977,17 @@ func TestVaultCAS_loadOptions(t *testing.T) {
 			"ok mandatory with AppRole",
 			`{"AppRole": "test", "RoleID": "roleID", "SecretID": {"FromString": "secretID"}}`,
 			&VaultOptions{
-				PKI:             "pki",
-				PKIRoleDefault:  "default",
-				PKIRoleRSA:      "default",
-				PKIRoleEC:       "default",
-				PKIRoleEd25519:  "default",
-				RoleID:          "roleID",
-				SecretID:        auth.SecretID{FromString: "secretID"},
-				AppRole:         "test",
-				IsWrappingToken: false,
+				PKI:            "pki",
+				PKIRoleDefault: "default",
+				PKIRoleRSA:     "default",
+				PKIRoleEC:      "default",
+				PKIRoleEd25519: "default",
+				Options: vaultauth.Options{
+					RoleID:          "roleID",
+					SecretID:        auth.SecretID{FromString: "secretID"},
+					AppRole:         "test",
+					IsWrappingToken: false,
+				},
 			},
 			false,
 		},
@@ -612,18 +995,131 @@ func TestVaultCAS_loadOptions(t *testing.T) {
 			"ok mandatory with IsWrappingToken",
 			`{"IsWrappingToken": true, "RoleID": "roleID", "SecretID": {"FromString": "secretID"}}`,
 			&VaultOptions{
-				PKI:             "pki",
-				PKIRoleDefault:  "default",
-				PKIRoleRSA:      "default",
-				PKIRoleEC:       "default",
-				PKIRoleEd25519:  "default",
-				RoleID:          "roleID",
-				SecretID:        auth.SecretID{FromString: "secretID"},
-				AppRole:         "auth/approle",
-				IsWrappingToken: true,
+				PKI:            "pki",
+				PKIRoleDefault: "default",
+				PKIRoleRSA:     "default",
+				PKIRoleEC:      "default",
+				PKIRoleEd25519: "default",
+				Options: vaultauth.Options{
+					RoleID:          "roleID",
+					SecretID:        auth.SecretID{FromString: "secretID"},
+					AppRole:         "auth/approle",
+					IsWrappingToken: true,
+				},
+			},
+			false,
+		},
+		{
+			"ok kubernetes",
+			`{"AuthType": "kubernetes", "KubernetesRole": "step-ca"}`,
+			&VaultOptions{
+				PKI:            "pki",
+				PKIRoleDefault: "default",
+				PKIRoleRSA:     "default",
+				PKIRoleEC:      "default",
+				PKIRoleEd25519: "default",
+				Options: vaultauth.Options{
+					AuthType:            "kubernetes",
+					AuthMountPath:       "kubernetes",
+					KubernetesRole:      "step-ca",
+					KubernetesTokenPath: "/var/run/secrets/kubernetes.io/serviceaccount/token",
+				},
+			},
+			false,
+		},
+		{
+			"ok kubernetes with AuthMountPath and KubernetesTokenPath",
+			`{"AuthType": "kubernetes", "AuthMountPath": "k8s", "KubernetesRole": "step-ca", "KubernetesTokenPath": "/tmp/token"}`,
+			&VaultOptions{
+				PKI:            "pki",
+				PKIRoleDefault: "default",
+				PKIRoleRSA:     "default",
+				PKIRoleEC:      "default",
+				PKIRoleEd25519: "default",
+				Options: vaultauth.Options{
+					AuthType:            "kubernetes",
+					AuthMountPath:       "k8s",
+					KubernetesRole:      "step-ca",
+					KubernetesTokenPath: "/tmp/token",
+				},
+			},
+			false,
+		},
+		{
+			"ok cert",
+			`{"AuthType": "cert", "CertRole": "step-ca", "ClientCert": "cert.pem", "ClientKey": "key.pem"}`,
+			&VaultOptions{
+				PKI:            "pki",
+				PKIRoleDefault: "default",
+				PKIRoleRSA:     "default",
+				PKIRoleEC:      "default",
+				PKIRoleEd25519: "default",
+				Options: vaultauth.Options{
+					AuthType:      "cert",
+					AuthMountPath: "cert",
+					CertRole:      "step-ca",
+					ClientCert:    "cert.pem",
+					ClientKey:     "key.pem",
+				},
+			},
+			false,
+		},
+		{
+			"ok token",
+			`{"AuthType": "token", "Token": "token"}`,
+			&VaultOptions{
+				PKI:            "pki",
+				PKIRoleDefault: "default",
+				PKIRoleRSA:     "default",
+				PKIRoleEC:      "default",
+				PKIRoleEd25519: "default",
+				Options: vaultauth.Options{
+					AuthType: "token",
+					Token:    "token",
+				},
+			},
+			false,
+		},
+		{
+			"ok token with TokenFile",
+			`{"AuthType": "token", "TokenFile": "/tmp/token"}`,
+			&VaultOptions{
+				PKI:            "pki",
+				PKIRoleDefault: "default",
+				PKIRoleRSA:     "default",
+				PKIRoleEC:      "default",
+				PKIRoleEd25519: "default",
+				Options: vaultauth.Options{
+					AuthType:  "token",
+					TokenFile: "/tmp/token",
+				},
 			},
 			false,
 		},
+		{
+			"fail kubernetes missing KubernetesRole",
+			`{"AuthType": "kubernetes"}`,
+			nil,
+			true,
+		},
+		{
+			"fail cert missing ClientKey",
+			`{"AuthType": "cert", "ClientCert": "cert.pem"}`,
+			nil,
+			true,
+		},
+		{
+			"fail token missing Token",
+			`{"AuthType": "token"}`,
+			nil,
+			true,
+		},
+		{
+			"fail unsupported AuthType",
+			`{"AuthType": "userpass"}`,
+			nil,
+			true,
+		},
 		{
 			"fail with SecretID FromFail",
 			`{"RoleID": "roleID", "SecretID": {"FromFail": "secretID"}}`,
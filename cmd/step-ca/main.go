@@ -31,9 +31,11 @@ import (
 	_ "github.com/smallstep/certificates/kms/cloudkms"
 	_ "github.com/smallstep/certificates/kms/softkms"
 	_ "github.com/smallstep/certificates/kms/sshagentkms"
+	_ "github.com/smallstep/certificates/kms/vaultkms"
 
 	// Experimental kms interfaces.
 	_ "github.com/smallstep/certificates/kms/pkcs11"
+	_ "github.com/smallstep/certificates/kms/tpmkms"
 	_ "github.com/smallstep/certificates/kms/yubikey"
 
 	// Enabled cas interfaces.
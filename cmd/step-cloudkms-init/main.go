@@ -15,25 +15,29 @@ import (
 	"strings"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/smallstep/certificates/kms/apiv1"
 	"github.com/smallstep/certificates/kms/cloudkms"
 	"go.step.sm/cli-utils/fileutil"
 	"go.step.sm/cli-utils/ui"
 	"go.step.sm/crypto/pemutil"
 	"golang.org/x/crypto/ssh"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func main() {
 	var credentialsFile string
 	var project, location, ring string
 	var protectionLevelName string
-	var enableSSH bool
+	var enableSSH, force bool
 	flag.StringVar(&credentialsFile, "credentials-file", "", "Path to the `file` containing the Google's Cloud KMS credentials.")
 	flag.StringVar(&project, "project", "", "Google Cloud Project ID.")
 	flag.StringVar(&location, "location", "global", "Cloud KMS location name.")
 	flag.StringVar(&ring, "ring", "pki", "Cloud KMS ring name.")
 	flag.StringVar(&protectionLevelName, "protection-level", "SOFTWARE", "Protection level to use, SOFTWARE or HSM.")
 	flag.BoolVar(&enableSSH, "ssh", false, "Create SSH keys.")
+	flag.BoolVar(&force, "force", false, "Add a new version to the keys if they already exist.")
 	flag.Usage = usage
 	flag.Parse()
 
@@ -73,6 +77,14 @@ func main() {
 		fatal(err)
 	}
 
+	if !force {
+		names := []string{"root", "intermediate"}
+		if enableSSH {
+			names = append(names, "ssh-user-key", "ssh-host-key")
+		}
+		checkKeys(c, project, location, ring, names)
+	}
+
 	if err := createPKI(c, project, location, ring, protectionLevel); err != nil {
 		fatal(err)
 	}
@@ -113,6 +125,33 @@ COPYRIGHT
 	os.Exit(1)
 }
 
+// checkKeys exits if any of the given keys already has an enabled version in
+// the key ring.
+func checkKeys(c *cloudkms.CloudKMS, project, location, keyRing string, names []string) {
+	parent := "projects/" + project + "/locations/" + location + "/keyRings/" + keyRing
+	resp, err := c.ListKeys(&apiv1.ListKeysRequest{
+		Name: parent,
+	})
+	if err != nil {
+		// The key ring will be created if it does not exist.
+		if status.Code(errors.Cause(err)) == codes.NotFound {
+			return
+		}
+		fatal(err)
+	}
+	for _, name := range names {
+		prefix := parent + "/cryptoKeys/" + name + "/"
+		for _, key := range resp.Keys {
+			if strings.HasPrefix(key, prefix) {
+				fmt.Fprintf(os.Stderr, "⚠️  Your key ring already has a key on %s.\n", key)
+				fmt.Fprintln(os.Stderr, "   If you want to add a new version to it, use `--force`.")
+				ui.Reset()
+				os.Exit(1)
+			}
+		}
+	}
+}
+
 func createPKI(c *cloudkms.CloudKMS, project, location, keyRing string, protectionLevel apiv1.ProtectionLevel) error {
 	ui.Println("Creating PKI ...")
 
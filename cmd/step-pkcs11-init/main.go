@@ -190,7 +190,7 @@ func main() {
 		}
 	} else {
 		deleter, ok := k.(interface {
-			DeleteKey(uri string) error
+			DeleteKey(req *apiv1.DeleteKeyRequest) error
 			DeleteCertificate(uri string) error
 		})
 		if ok {
@@ -198,7 +198,7 @@ func main() {
 				if u != "" && !c.NoCerts {
 					// Some HSMs like Nitrokey will overwrite the key with the
 					// certificate label.
-					if err := deleter.DeleteKey(u); err != nil {
+					if err := deleter.DeleteKey(&apiv1.DeleteKeyRequest{Name: u}); err != nil {
 						fatalClose(err, k)
 					}
 					if err := deleter.DeleteCertificate(u); err != nil {
@@ -208,7 +208,7 @@ func main() {
 			}
 			for _, u := range keyUris {
 				if u != "" {
-					if err := deleter.DeleteKey(u); err != nil {
+					if err := deleter.DeleteKey(&apiv1.DeleteKeyRequest{Name: u}); err != nil {
 						fatalClose(err, k)
 					}
 				}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto"
 	"crypto/ecdsa"
@@ -246,11 +247,18 @@ func createPKI(k kms.KeyManager, c Config) error {
 
 		ui.PrintSelected("Root Key", resp.Name)
 		ui.PrintSelected("Root Certificate", "root_ca.crt")
+
+		if ok, err := writeAttestation("root_ca_attestation.crt", resp); err != nil {
+			return err
+		} else if ok {
+			ui.PrintSelected("Root Key Attestation", "root_ca_attestation.crt")
+		}
 	}
 
 	// Intermediate Certificate
 	var keyName string
 	var publicKey crypto.PublicKey
+	var keyResponse *apiv1.CreateKeyResponse
 	if c.RootOnly {
 		priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 		if err != nil {
@@ -279,6 +287,7 @@ func createPKI(k kms.KeyManager, c Config) error {
 		}
 		publicKey = resp.PublicKey
 		keyName = resp.Name
+		keyResponse = resp
 	}
 
 	template := &x509.Certificate{
@@ -329,9 +338,42 @@ func createPKI(k kms.KeyManager, c Config) error {
 
 	ui.PrintSelected("Intermediate Certificate", "intermediate_ca.crt")
 
+	if keyResponse != nil {
+		if ok, err := writeAttestation("intermediate_ca_attestation.crt", keyResponse); err != nil {
+			return err
+		} else if ok {
+			ui.PrintSelected("Intermediate Key Attestation", "intermediate_ca_attestation.crt")
+		}
+	}
+
 	return nil
 }
 
+// writeAttestation writes the attestation certificate of a key generated in
+// the YubiKey, followed by the attestation certificate of the YubiKey, to the
+// given file. The chain can be verified with the Yubico PIV root. It returns
+// false if the YubiKey did not return an attestation.
+func writeAttestation(filename string, resp *apiv1.CreateKeyResponse) (bool, error) {
+	if resp.AttestationCertificate == nil {
+		return false, nil
+	}
+
+	var buf bytes.Buffer
+	for _, crt := range append([]*x509.Certificate{resp.AttestationCertificate}, resp.AttestationChain...) {
+		if err := pem.Encode(&buf, &pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: crt.Raw,
+		}); err != nil {
+			return false, errors.Wrap(err, "error encoding attestation certificate")
+		}
+	}
+	if err := fileutil.WriteFile(filename, buf.Bytes(), 0600); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
 func mustSerialNumber() *big.Int {
 	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
 	sn, err := rand.Int(rand.Reader, serialNumberLimit)
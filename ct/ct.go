@@ -0,0 +1,460 @@
+// Package ct implements the submission of precertificates to RFC 6962
+// Certificate Transparency logs, and the encoding of the signed certificate
+// timestamps (SCTs) returned by the logs.
+package ct
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/cryptobyte"
+	cryptobyte_asn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+var (
+	// OIDPoison is the object identifier of the critical extension that makes a
+	// precertificate unusable.
+	OIDPoison = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}
+	// OIDSCTList is the object identifier of the extension with the list of
+	// SCTs embedded in a certificate.
+	OIDSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+)
+
+// DefaultTimeout is the default timeout used to submit a precertificate to a
+// log.
+const DefaultTimeout = 10 * time.Second
+
+// PoisonExtension returns the critical poison extension added to the
+// precertificates.
+func PoisonExtension() pkix.Extension {
+	return pkix.Extension{
+		Id:       OIDPoison,
+		Critical: true,
+		Value:    asn1.NullBytes,
+	}
+}
+
+// DigitallySigned is the TLS DigitallySigned structure of an SCT.
+type DigitallySigned struct {
+	HashAlgorithm      uint8
+	SignatureAlgorithm uint8
+	Signature          []byte
+}
+
+// SignedCertificateTimestamp is an SCT returned by a log.
+type SignedCertificateTimestamp struct {
+	Version    uint8
+	LogID      [32]byte
+	Timestamp  uint64
+	Extensions []byte
+	Signature  DigitallySigned
+}
+
+// addChainResponse is the JSON response of the add-pre-chain endpoint.
+type addChainResponse struct {
+	SCTVersion uint8  `json:"sct_version"`
+	ID         string `json:"id"`
+	Timestamp  uint64 `json:"timestamp"`
+	Extensions string `json:"extensions"`
+	Signature  string `json:"signature"`
+}
+
+// Log is a Certificate Transparency log.
+type Log struct {
+	URL       string
+	PublicKey crypto.PublicKey
+	Client    *http.Client
+}
+
+// NewLog creates a new log with the given url and the optional base64 DER
+// encoded public key. If the key is set, the SCTs returned by the log are
+// verified.
+func NewLog(url, key string) (*Log, error) {
+	if url == "" {
+		return nil, errors.New("log url cannot be empty")
+	}
+	l := &Log{
+		URL:    strings.TrimSuffix(url, "/"),
+		Client: &http.Client{Timeout: DefaultTimeout},
+	}
+	if key != "" {
+		der, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error decoding key of log %s", url)
+		}
+		if l.PublicKey, err = x509.ParsePKIXPublicKey(der); err != nil {
+			return nil, errors.Wrapf(err, "error parsing key of log %s", url)
+		}
+	}
+	return l, nil
+}
+
+// AddPreChain submits the precertificate chain to the log and returns the SCT.
+// The chain must start with the precertificate followed by its issuer.
+func (l *Log) AddPreChain(ctx context.Context, chain []*x509.Certificate) (*SignedCertificateTimestamp, error) {
+	if len(chain) < 2 {
+		return nil, errors.New("precertificate chain must contain the precertificate and its issuer")
+	}
+
+	var req struct {
+		Chain [][]byte `json:"chain"`
+	}
+	for _, crt := range chain {
+		req.Chain = append(req.Chain, crt.Raw)
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling request")
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, l.URL+"/ct/v1/add-pre-chain", bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error creating request to %s", l.URL)
+	}
+	r.Header.Set("Content-Type", "application/json")
+	client := l.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error submitting precertificate to %s", l.URL)
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading response from %s", l.URL)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("error submitting precertificate to %s: %s", l.URL, strings.TrimSpace(string(b)))
+	}
+
+	var ar addChainResponse
+	if err := json.Unmarshal(b, &ar); err != nil {
+		return nil, errors.Wrapf(err, "error parsing response from %s", l.URL)
+	}
+	sct, err := ar.sct()
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing response from %s", l.URL)
+	}
+
+	if l.PublicKey != nil {
+		if err := sct.VerifyPrecertificate(l.PublicKey, chain[0], chain[1]); err != nil {
+			return nil, errors.Wrapf(err, "error verifying sct from %s", l.URL)
+		}
+	}
+	return sct, nil
+}
+
+func (r *addChainResponse) sct() (*SignedCertificateTimestamp, error) {
+	id, err := base64.StdEncoding.DecodeString(r.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding id")
+	}
+	if len(id) != 32 {
+		return nil, errors.Errorf("invalid log id length %d", len(id))
+	}
+	ext, err := base64.StdEncoding.DecodeString(r.Extensions)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding extensions")
+	}
+	sig, err := base64.StdEncoding.DecodeString(r.Signature)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding signature")
+	}
+
+	sct := &SignedCertificateTimestamp{
+		Version:    r.SCTVersion,
+		Timestamp:  r.Timestamp,
+		Extensions: ext,
+	}
+	copy(sct.LogID[:], id)
+
+	s := cryptobyte.String(sig)
+	var signature cryptobyte.String
+	if !s.ReadUint8(&sct.Signature.HashAlgorithm) ||
+		!s.ReadUint8(&sct.Signature.SignatureAlgorithm) ||
+		!s.ReadUint16LengthPrefixed(&signature) || !s.Empty() {
+		return nil, errors.New("error decoding signature: malformed digitally-signed struct")
+	}
+	sct.Signature.Signature = signature
+	return sct, nil
+}
+
+// Marshal returns the TLS encoding of the SCT.
+func (s *SignedCertificateTimestamp) Marshal() ([]byte, error) {
+	var b cryptobyte.Builder
+	b.AddUint8(s.Version)
+	b.AddBytes(s.LogID[:])
+	addUint64(&b, s.Timestamp)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(s.Extensions)
+	})
+	b.AddUint8(s.Signature.HashAlgorithm)
+	b.AddUint8(s.Signature.SignatureAlgorithm)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(s.Signature.Signature)
+	})
+	return b.Bytes()
+}
+
+// VerifyPrecertificate verifies the signature of the SCT of a precertificate
+// using the public key of the log.
+func (s *SignedCertificateTimestamp) VerifyPrecertificate(pub crypto.PublicKey, precert, issuer *x509.Certificate) error {
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling log key")
+	}
+	if sha256.Sum256(pubDER) != s.LogID {
+		return errors.New("log id does not match the log key")
+	}
+
+	tbs, err := RemoveExtension(precert.RawTBSCertificate, OIDPoison)
+	if err != nil {
+		return err
+	}
+	if len(tbs) >= 1<<24 {
+		return errors.New("tbs certificate is too large")
+	}
+
+	var b cryptobyte.Builder
+	b.AddUint8(s.Version)
+	b.AddUint8(0) // certificate_timestamp
+	addUint64(&b, s.Timestamp)
+	b.AddUint16(1) // precert_entry
+	issuerKeyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+	b.AddBytes(issuerKeyHash[:])
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(tbs)
+	})
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(s.Extensions)
+	})
+	signed, err := b.Bytes()
+	if err != nil {
+		return errors.Wrap(err, "error encoding signed data")
+	}
+
+	// Only SHA-256 is allowed by RFC 6962.
+	if s.Signature.HashAlgorithm != 4 {
+		return errors.Errorf("unsupported hash algorithm %d", s.Signature.HashAlgorithm)
+	}
+	digest := sha256.Sum256(signed)
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		if s.Signature.SignatureAlgorithm != 3 || !ecdsa.VerifyASN1(k, digest[:], s.Signature.Signature) {
+			return errors.New("invalid sct signature")
+		}
+	case *rsa.PublicKey:
+		if s.Signature.SignatureAlgorithm != 1 {
+			return errors.New("invalid sct signature")
+		}
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], s.Signature.Signature); err != nil {
+			return errors.New("invalid sct signature")
+		}
+	default:
+		return errors.Errorf("unsupported log key type %T", pub)
+	}
+	return nil
+}
+
+// SCTListExtension returns the extension with the list of SCTs that is added
+// to the final certificate.
+func SCTListExtension(scts []*SignedCertificateTimestamp) (pkix.Extension, error) {
+	var b cryptobyte.Builder
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, sct := range scts {
+			raw, err := sct.Marshal()
+			if err != nil {
+				b.SetError(err)
+				return
+			}
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddBytes(raw)
+			})
+		}
+	})
+	list, err := b.Bytes()
+	if err != nil {
+		return pkix.Extension{}, errors.Wrap(err, "error encoding sct list")
+	}
+	value, err := asn1.Marshal(list)
+	if err != nil {
+		return pkix.Extension{}, errors.Wrap(err, "error encoding sct list")
+	}
+	return pkix.Extension{
+		Id:    OIDSCTList,
+		Value: value,
+	}, nil
+}
+
+// ParseSCTList parses the value of the SCT list extension.
+func ParseSCTList(value []byte) ([]*SignedCertificateTimestamp, error) {
+	var list []byte
+	if rest, err := asn1.Unmarshal(value, &list); err != nil || len(rest) > 0 {
+		return nil, errors.New("error parsing sct list: malformed octet string")
+	}
+
+	var scts []*SignedCertificateTimestamp
+	s := cryptobyte.String(list)
+	var entries cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&entries) || !s.Empty() {
+		return nil, errors.New("error parsing sct list: malformed list")
+	}
+	for !entries.Empty() {
+		var entry, ext, sig cryptobyte.String
+		var logID []byte
+		sct := new(SignedCertificateTimestamp)
+		if !entries.ReadUint16LengthPrefixed(&entry) ||
+			!entry.ReadUint8(&sct.Version) ||
+			!entry.ReadBytes(&logID, 32) ||
+			!readUint64(&entry, &sct.Timestamp) ||
+			!entry.ReadUint16LengthPrefixed(&ext) ||
+			!entry.ReadUint8(&sct.Signature.HashAlgorithm) ||
+			!entry.ReadUint8(&sct.Signature.SignatureAlgorithm) ||
+			!entry.ReadUint16LengthPrefixed(&sig) || !entry.Empty() {
+			return nil, errors.New("error parsing sct list: malformed sct")
+		}
+		copy(sct.LogID[:], logID)
+		sct.Extensions = ext
+		sct.Signature.Signature = sig
+		scts = append(scts, sct)
+	}
+	return scts, nil
+}
+
+// RemoveExtension returns the given DER encoded TBSCertificate without the
+// extension with the given object identifier. It's used to get the
+// TBSCertificate signed by the logs from a precertificate.
+func RemoveExtension(tbs []byte, oid asn1.ObjectIdentifier) ([]byte, error) {
+	input := cryptobyte.String(tbs)
+	var seq cryptobyte.String
+	if !input.ReadASN1(&seq, cryptobyte_asn1.SEQUENCE) || !input.Empty() {
+		return nil, errors.New("error parsing tbs certificate: malformed sequence")
+	}
+
+	extensionsTag := cryptobyte_asn1.Tag(3).Constructed().ContextSpecific()
+	var b cryptobyte.Builder
+	b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+		for !seq.Empty() {
+			var element cryptobyte.String
+			var tag cryptobyte_asn1.Tag
+			if !seq.ReadAnyASN1Element(&element, &tag) {
+				b.SetError(errors.New("error parsing tbs certificate: malformed element"))
+				return
+			}
+			if tag != extensionsTag {
+				b.AddBytes(element)
+				continue
+			}
+
+			var wrapper, extensions cryptobyte.String
+			if !element.ReadASN1(&wrapper, extensionsTag) || !wrapper.ReadASN1(&extensions, cryptobyte_asn1.SEQUENCE) {
+				b.SetError(errors.New("error parsing tbs certificate: malformed extensions"))
+				return
+			}
+			var kept [][]byte
+			for !extensions.Empty() {
+				var ext, extElement, extContent cryptobyte.String
+				var id asn1.ObjectIdentifier
+				if !extensions.ReadASN1Element(&ext, cryptobyte_asn1.SEQUENCE) {
+					b.SetError(errors.New("error parsing tbs certificate: malformed extension"))
+					return
+				}
+				extElement = ext
+				if !extElement.ReadASN1(&extContent, cryptobyte_asn1.SEQUENCE) || !extContent.ReadASN1ObjectIdentifier(&id) {
+					b.SetError(errors.New("error parsing tbs certificate: malformed extension"))
+					return
+				}
+				if !id.Equal(oid) {
+					kept = append(kept, ext)
+				}
+			}
+			// An empty list of extensions must be omitted.
+			if len(kept) == 0 {
+				continue
+			}
+			b.AddASN1(extensionsTag, func(b *cryptobyte.Builder) {
+				b.AddASN1(cryptobyte_asn1.SEQUENCE, func(b *cryptobyte.Builder) {
+					for _, ext := range kept {
+						b.AddBytes(ext)
+					}
+				})
+			})
+		}
+	})
+	return b.Bytes()
+}
+
+// Submit submits the precertificate chain to all the logs concurrently, and
+// returns the SCTs returned. It fails if less than min logs return an SCT.
+func Submit(ctx context.Context, logs []*Log, chain []*x509.Certificate, min int) ([]*SignedCertificateTimestamp, error) {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		scts   = make([]*SignedCertificateTimestamp, len(logs))
+		errs   []string
+		loaded int
+	)
+	for i, l := range logs {
+		wg.Add(1)
+		go func(i int, l *Log) {
+			defer wg.Done()
+			sct, err := l.AddPreChain(ctx, chain)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err.Error())
+				return
+			}
+			scts[i] = sct
+			loaded++
+		}(i, l)
+	}
+	wg.Wait()
+
+	if loaded < min {
+		return nil, errors.Errorf("error submitting precertificate: got %d SCTs, %d required: %s", loaded, min, strings.Join(errs, "; "))
+	}
+
+	// Keep the order of the logs.
+	result := make([]*SignedCertificateTimestamp, 0, loaded)
+	for _, sct := range scts {
+		if sct != nil {
+			result = append(result, sct)
+		}
+	}
+	return result, nil
+}
+
+// addUint64 appends a big-endian uint64.
+func addUint64(b *cryptobyte.Builder, v uint64) {
+	b.AddUint32(uint32(v >> 32))
+	b.AddUint32(uint32(v))
+}
+
+// readUint64 reads a big-endian uint64.
+func readUint64(s *cryptobyte.String, v *uint64) bool {
+	var hi, lo uint32
+	if !s.ReadUint32(&hi) || !s.ReadUint32(&lo) {
+		return false
+	}
+	*v = uint64(hi)<<32 | uint64(lo)
+	return true
+}
@@ -0,0 +1,340 @@
+package ct
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// testLog is a local CT log that returns valid SCTs for precertificates.
+type testLog struct {
+	*httptest.Server
+	key  *ecdsa.PrivateKey
+	fail bool
+}
+
+func newTestLog(t *testing.T) *testLog {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &testLog{key: key}
+	l.Server = httptest.NewServer(http.HandlerFunc(l.addPreChain))
+	t.Cleanup(l.Close)
+	return l
+}
+
+func (l *testLog) publicKey(t *testing.T) string {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(l.key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(der)
+}
+
+func (l *testLog) addPreChain(w http.ResponseWriter, r *http.Request) {
+	if l.fail || r.Method != http.MethodPost || r.URL.Path != "/ct/v1/add-pre-chain" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Chain [][]byte `json:"chain"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Chain) < 2 {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	precert, err := x509.ParseCertificate(req.Chain[0])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	issuer, err := x509.ParseCertificate(req.Chain[1])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tbs, err := RemoveExtension(precert.RawTBSCertificate, OIDPoison)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	timestamp := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	var b cryptobyte.Builder
+	b.AddUint8(0)
+	b.AddUint8(0)
+	addUint64(&b, timestamp)
+	b.AddUint16(1)
+	issuerKeyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+	b.AddBytes(issuerKeyHash[:])
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(tbs)
+	})
+	b.AddUint16(0)
+	digest := sha256.Sum256(b.BytesOrPanic())
+	sig, err := ecdsa.SignASN1(rand.Reader, l.key, digest[:])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var ds cryptobyte.Builder
+	ds.AddUint8(4)
+	ds.AddUint8(3)
+	ds.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(sig)
+	})
+	der, _ := x509.MarshalPKIXPublicKey(l.key.Public())
+	id := sha256.Sum256(der)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(addChainResponse{
+		SCTVersion: 0,
+		ID:         base64.StdEncoding.EncodeToString(id[:]),
+		Timestamp:  timestamp,
+		Signature:  base64.StdEncoding.EncodeToString(ds.BytesOrPanic()),
+	})
+}
+
+func mustCertificate(t *testing.T, template, parent *x509.Certificate, pub crypto.PublicKey, signer crypto.Signer) *x509.Certificate {
+	t.Helper()
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crt
+}
+
+// testPrecertificate returns a precertificate, the certificate that would be
+// issued without the poison extension, and the issuer.
+func testPrecertificate(t *testing.T) (*x509.Certificate, *x509.Certificate, *x509.Certificate) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := mustCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, &x509.Certificate{Subject: pkix.Name{CommonName: "Test CA"}}, caKey.Public(), caKey)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1234),
+		Subject:      pkix.Name{CommonName: "test.example.com"},
+		DNSNames:     []string{"test.example.com"},
+		NotBefore:    time.Now().Truncate(time.Second),
+		NotAfter:     time.Now().Add(time.Hour).Truncate(time.Second),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		SubjectKeyId: []byte{1, 2, 3, 4},
+	}
+	crt := mustCertificate(t, template, ca, key.Public(), caKey)
+	template.ExtraExtensions = []pkix.Extension{PoisonExtension()}
+	precert := mustCertificate(t, template, ca, key.Public(), caKey)
+	return precert, crt, ca
+}
+
+func TestRemoveExtension(t *testing.T) {
+	precert, crt, ca := testPrecertificate(t)
+
+	tests := []struct {
+		name    string
+		tbs     []byte
+		want    []byte
+		wantErr bool
+	}{
+		{"ok", precert.RawTBSCertificate, crt.RawTBSCertificate, false},
+		{"ok no extension", crt.RawTBSCertificate, crt.RawTBSCertificate, false},
+		{"ok ca", ca.RawTBSCertificate, ca.RawTBSCertificate, false},
+		{"fail", []byte("foo"), nil, true},
+		{"fail trailing data", append(crt.RawTBSCertificate, 0), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RemoveExtension(tt.tbs, OIDPoison)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RemoveExtension() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RemoveExtension() = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewLog(t *testing.T) {
+	l := newTestLog(t)
+	tests := []struct {
+		name    string
+		url     string
+		key     string
+		wantKey bool
+		wantErr bool
+	}{
+		{"ok", l.URL + "/", "", false, false},
+		{"ok with key", l.URL, l.publicKey(t), true, false},
+		{"fail url", "", "", false, true},
+		{"fail key encoding", l.URL, "%%%", false, true},
+		{"fail key", l.URL, "Zm9v", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewLog(tt.url, tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewLog() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if got.URL != l.URL {
+				t.Errorf("NewLog() URL = %s, want %s", got.URL, l.URL)
+			}
+			if (got.PublicKey != nil) != tt.wantKey {
+				t.Errorf("NewLog() PublicKey = %v, wantKey %v", got.PublicKey, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestLog_AddPreChain(t *testing.T) {
+	precert, _, ca := testPrecertificate(t)
+	l := newTestLog(t)
+	other := newTestLog(t)
+	failing := newTestLog(t)
+	failing.fail = true
+
+	newLog := func(url, key string) *Log {
+		lg, err := NewLog(url, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return lg
+	}
+
+	tests := []struct {
+		name    string
+		log     *Log
+		chain   []*x509.Certificate
+		wantErr bool
+	}{
+		{"ok", newLog(l.URL, l.publicKey(t)), []*x509.Certificate{precert, ca}, false},
+		{"ok without key", newLog(l.URL, ""), []*x509.Certificate{precert, ca}, false},
+		{"fail chain", newLog(l.URL, ""), []*x509.Certificate{precert}, true},
+		{"fail log", newLog(failing.URL, ""), []*x509.Certificate{precert, ca}, true},
+		{"fail log key", newLog(l.URL, other.publicKey(t)), []*x509.Certificate{precert, ca}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.log.AddPreChain(context.Background(), tt.chain)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Log.AddPreChain() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && got.Signature.HashAlgorithm != 4 {
+				t.Errorf("Log.AddPreChain() HashAlgorithm = %d, want 4", got.Signature.HashAlgorithm)
+			}
+		})
+	}
+}
+
+func TestSubmit(t *testing.T) {
+	precert, _, ca := testPrecertificate(t)
+	l1, l2 := newTestLog(t), newTestLog(t)
+	failing := newTestLog(t)
+	failing.fail = true
+
+	var logs []*Log
+	for _, l := range []*testLog{l1, failing, l2} {
+		lg, err := NewLog(l.URL, l.publicKey(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		logs = append(logs, lg)
+	}
+
+	tests := []struct {
+		name    string
+		min     int
+		want    int
+		wantErr bool
+	}{
+		{"ok", 2, 2, false},
+		{"ok one", 1, 2, false},
+		{"fail", 3, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Submit(context.Background(), logs, []*x509.Certificate{precert, ca}, tt.min)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Submit() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if len(got) != tt.want {
+				t.Errorf("Submit() got %d SCTs, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestSCTListExtension(t *testing.T) {
+	scts := []*SignedCertificateTimestamp{
+		{Version: 0, LogID: [32]byte{1}, Timestamp: 1234567890123, Signature: DigitallySigned{4, 3, []byte("signature")}},
+		{Version: 0, LogID: [32]byte{2}, Timestamp: 1234567890456, Extensions: []byte("ext"), Signature: DigitallySigned{4, 1, []byte("other signature")}},
+	}
+	ext, err := SCTListExtension(scts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ext.Id.Equal(OIDSCTList) || ext.Critical {
+		t.Errorf("SCTListExtension() = %v, want non-critical %v", ext, OIDSCTList)
+	}
+	got, err := ParseSCTList(ext.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sct := range got {
+		if len(sct.Extensions) == 0 {
+			sct.Extensions = nil
+		}
+	}
+	if !reflect.DeepEqual(got, scts) {
+		t.Errorf("ParseSCTList() = %v, want %v", got, scts)
+	}
+
+	if _, err := ParseSCTList([]byte("foo")); err == nil {
+		t.Error("ParseSCTList() error = nil, want error")
+	}
+}
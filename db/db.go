@@ -1,8 +1,10 @@
 package db
 
 import (
+	"bytes"
 	"crypto/x509"
 	"encoding/json"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -17,19 +19,38 @@ import (
 var (
 	certsTable             = []byte("x509_certs")
 	certsDataTable         = []byte("x509_certs_data")
+	certsIndexTable        = []byte("x509_certs_index")
 	revokedCertsTable      = []byte("revoked_x509_certs")
 	revokedSSHCertsTable   = []byte("revoked_ssh_certs")
+	crlTable               = []byte("x509_crl")
 	usedOTTTable           = []byte("used_ott")
 	sshCertsTable          = []byte("ssh_certs")
 	sshHostsTable          = []byte("ssh_hosts")
 	sshUsersTable          = []byte("ssh_users")
 	sshHostPrincipalsTable = []byte("ssh_host_principals")
+	scepChallengesTable    = []byte("scep_challenges")
+	scepRequestsTable      = []byte("scep_requests")
 )
 
+var crlKey = []byte("crl")
+
+// certsIndexLastKey is the key in the x509_certs_index table with the last
+// index assigned to a certificate. The rest of the keys are the indexes of the
+// certificates, in the order they were stored, and the values their serial
+// numbers.
+var certsIndexLastKey = []byte("last")
+
 // ErrAlreadyExists can be returned if the DB attempts to set a key that has
 // been previously set.
 var ErrAlreadyExists = errors.New("already exists")
 
+// ErrSCEPChallengeNotFound is returned by UseSCEPChallenge if the challenge
+// does not exist or it has already been used.
+var ErrSCEPChallengeNotFound = errors.New("scep challenge not found")
+
+// ErrSCEPRequestNotFound is returned if a SCEP request does not exist.
+var ErrSCEPRequestNotFound = errors.New("scep request not found")
+
 // Config represents the JSON attributes used for configuring a step-ca DB.
 type Config struct {
 	Type       string `json:"type"`
@@ -49,6 +70,11 @@ type AuthDB interface {
 	IsSSHRevoked(sn string) (bool, error)
 	Revoke(rci *RevokedCertificateInfo) error
 	RevokeSSH(rci *RevokedCertificateInfo) error
+	GetRevokedCertificate(sn string) (*RevokedCertificateInfo, error)
+	GetRevokedCertificates() ([]RevokedCertificateInfo, error)
+	GetRevokedSSHCertificates() ([]RevokedCertificateInfo, error)
+	GetCRL() (*CertificateRevocationListInfo, error)
+	StoreCRL(*CertificateRevocationListInfo) error
 	GetCertificate(serialNumber string) (*x509.Certificate, error)
 	StoreCertificate(crt *x509.Certificate) error
 	UseToken(id, tok string) (bool, error)
@@ -65,11 +91,16 @@ type DB struct {
 }
 
 // New returns a new database client that implements the AuthDB interface.
-func New(c *Config) (AuthDB, error) {
+func New(c *Config, dbOpts ...Option) (AuthDB, error) {
 	if c == nil {
 		return newSimpleDB(c)
 	}
 
+	o := new(options)
+	for _, fn := range dbOpts {
+		fn(o)
+	}
+
 	opts := []nosql.Option{nosql.WithDatabase(c.Database),
 		nosql.WithValueDir(c.ValueDir)}
 	if len(c.BadgerFileLoadingMode) > 0 {
@@ -84,7 +115,8 @@ func New(c *Config) (AuthDB, error) {
 	tables := [][]byte{
 		revokedCertsTable, certsTable, usedOTTTable,
 		sshCertsTable, sshHostsTable, sshHostPrincipalsTable, sshUsersTable,
-		revokedSSHCertsTable, certsDataTable,
+		revokedSSHCertsTable, certsDataTable, crlTable, scepChallengesTable,
+		scepRequestsTable, certsIndexTable,
 	}
 	for _, b := range tables {
 		if err := db.CreateTable(b); err != nil {
@@ -93,22 +125,41 @@ func New(c *Config) (AuthDB, error) {
 		}
 	}
 
-	return &DB{db, true}, nil
+	if o.meter != nil {
+		db = &instrumentedDB{DB: db, meter: o.meter}
+	}
+
+	authDB := &DB{db, true}
+	if err := authDB.backfillCertificateIndex(); err != nil {
+		return nil, err
+	}
+	return authDB, nil
 }
 
 // RevokedCertificateInfo contains information regarding the certificate
 // revocation action.
 type RevokedCertificateInfo struct {
 	Serial        string
+	KeyID         string
 	ProvisionerID string
 	ReasonCode    int
 	Reason        string
 	RevokedAt     time.Time
+	ExpiresAt     time.Time
 	TokenID       string
 	MTLS          bool
 	ACME          bool
 }
 
+// CertificateRevocationListInfo contains a CRL in DER format and associated
+// metadata.
+type CertificateRevocationListInfo struct {
+	Number    int64
+	ExpiresAt time.Time
+	Duration  time.Duration
+	DER       []byte
+}
+
 // IsRevoked returns whether or not a certificate with the given identifier
 // has been revoked.
 // In the case of an X509 Certificate the `id` should be the Serial Number of
@@ -191,6 +242,79 @@ func (db *DB) RevokeSSH(rci *RevokedCertificateInfo) error {
 	}
 }
 
+// GetRevokedCertificate returns the revocation information of the X.509
+// certificate with the given serial number.
+func (db *DB) GetRevokedCertificate(sn string) (*RevokedCertificateInfo, error) {
+	b, err := db.Get(revokedCertsTable, []byte(sn))
+	if err != nil {
+		return nil, errors.Wrap(err, "database Get error")
+	}
+	var rci RevokedCertificateInfo
+	if err := json.Unmarshal(b, &rci); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling revoked certificate info")
+	}
+	return &rci, nil
+}
+
+// GetRevokedCertificates gets a list of all revoked X.509 certificates.
+func (db *DB) GetRevokedCertificates() ([]RevokedCertificateInfo, error) {
+	entries, err := db.List(revokedCertsTable)
+	if err != nil {
+		return nil, errors.Wrap(err, "database List error")
+	}
+	revokedCerts := make([]RevokedCertificateInfo, 0, len(entries))
+	for _, e := range entries {
+		var data RevokedCertificateInfo
+		if err := json.Unmarshal(e.Value, &data); err != nil {
+			return nil, errors.Wrap(err, "error unmarshaling revoked certificate info")
+		}
+		revokedCerts = append(revokedCerts, data)
+	}
+	return revokedCerts, nil
+}
+
+// GetRevokedSSHCertificates gets a list of all revoked SSH certificates.
+func (db *DB) GetRevokedSSHCertificates() ([]RevokedCertificateInfo, error) {
+	entries, err := db.List(revokedSSHCertsTable)
+	if err != nil {
+		return nil, errors.Wrap(err, "database List error")
+	}
+	revokedCerts := make([]RevokedCertificateInfo, 0, len(entries))
+	for _, e := range entries {
+		var data RevokedCertificateInfo
+		if err := json.Unmarshal(e.Value, &data); err != nil {
+			return nil, errors.Wrap(err, "error unmarshaling revoked certificate info")
+		}
+		revokedCerts = append(revokedCerts, data)
+	}
+	return revokedCerts, nil
+}
+
+// GetCRL gets the existing CRL from the database.
+func (db *DB) GetCRL() (*CertificateRevocationListInfo, error) {
+	b, err := db.Get(crlTable, crlKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "database Get error")
+	}
+	var crlInfo CertificateRevocationListInfo
+	if err := json.Unmarshal(b, &crlInfo); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling crl info")
+	}
+	return &crlInfo, nil
+}
+
+// StoreCRL stores a CRL overwriting the previous one.
+func (db *DB) StoreCRL(crlInfo *CertificateRevocationListInfo) error {
+	b, err := json.Marshal(crlInfo)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling crl info")
+	}
+	if err := db.Set(crlTable, crlKey, b); err != nil {
+		return errors.Wrap(err, "database Set error")
+	}
+	return nil
+}
+
 // GetCertificate retrieves a certificate by the serial number.
 func (db *DB) GetCertificate(serialNumber string) (*x509.Certificate, error) {
 	asn1Data, err := db.Get(certsTable, []byte(serialNumber))
@@ -219,8 +343,17 @@ func (db *DB) GetCertificateData(serialNumber string) (*CertificateData, error)
 
 // StoreCertificate stores a certificate PEM.
 func (db *DB) StoreCertificate(crt *x509.Certificate) error {
-	if err := db.Set(certsTable, []byte(crt.SerialNumber.String()), crt.Raw); err != nil {
-		return errors.Wrap(err, "database Set error")
+	index, err := db.nextCertificateIndex()
+	if err != nil {
+		return err
+	}
+	serialNumber := []byte(crt.SerialNumber.String())
+	// Add certificate and its index in one transaction.
+	tx := new(database.Tx)
+	tx.Set(certsTable, serialNumber, crt.Raw)
+	tx.Set(certsIndexTable, index, serialNumber)
+	if err := db.Update(tx); err != nil {
+		return errors.Wrap(err, "database Update error")
 	}
 	return nil
 }
@@ -256,16 +389,242 @@ func (db *DB) StoreCertificateChain(p provisioner.Interface, chain ...*x509.Cert
 	if err != nil {
 		return errors.Wrap(err, "error marshaling json")
 	}
-	// Add certificate and certificate data in one transaction.
+	index, err := db.nextCertificateIndex()
+	if err != nil {
+		return err
+	}
+	// Add certificate, certificate data and index in one transaction.
 	tx := new(database.Tx)
 	tx.Set(certsTable, serialNumber, leaf.Raw)
 	tx.Set(certsDataTable, serialNumber, b)
+	tx.Set(certsIndexTable, index, serialNumber)
 	if err := db.Update(tx); err != nil {
 		return errors.Wrap(err, "database Update error")
 	}
 	return nil
 }
 
+// getLastCertificateIndex returns the last index assigned to a certificate
+// and its raw value, nil if no index has been assigned yet.
+func (db *DB) getLastCertificateIndex() (uint64, []byte, error) {
+	b, err := db.Get(certsIndexTable, certsIndexLastKey)
+	if err != nil {
+		if database.IsErrNotFound(err) {
+			return 0, nil, nil
+		}
+		return 0, nil, errors.Wrap(err, "database Get error")
+	}
+	last, err := strconv.ParseUint(string(b), 10, 64)
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "error parsing last certificate index")
+	}
+	return last, b, nil
+}
+
+// nextCertificateIndex reserves the next index in the x509_certs_index table
+// and returns its key.
+func (db *DB) nextCertificateIndex() ([]byte, error) {
+	for {
+		last, old, err := db.getLastCertificateIndex()
+		if err != nil {
+			return nil, err
+		}
+		next := []byte(strconv.FormatUint(last+1, 10))
+		_, swapped, err := db.CmpAndSwap(certsIndexTable, certsIndexLastKey, old, next)
+		if err != nil {
+			return nil, errors.Wrap(err, "database CmpAndSwap error")
+		}
+		if swapped {
+			return next, nil
+		}
+	}
+}
+
+// backfillCertificateIndex adds the certificates stored before the
+// x509_certs_index table existed to the index, sorted by serial number. The
+// last index is only set once all the certificates have been indexed, so an
+// interrupted backfill starts again the next time the database is opened.
+func (db *DB) backfillCertificateIndex() error {
+	_, old, err := db.getLastCertificateIndex()
+	if err != nil || old != nil {
+		return err
+	}
+	entries, err := db.List(certsTable)
+	if err != nil {
+		return errors.Wrap(err, "database List error")
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].Key, entries[j].Key) < 0
+	})
+	for i, e := range entries {
+		key := []byte(strconv.Itoa(i + 1))
+		if err := db.Set(certsIndexTable, key, e.Key); err != nil {
+			return errors.Wrap(err, "database Set error")
+		}
+	}
+	last := []byte(strconv.Itoa(len(entries)))
+	if _, _, err := db.CmpAndSwap(certsIndexTable, certsIndexLastKey, nil, last); err != nil {
+		return errors.Wrap(err, "database CmpAndSwap error")
+	}
+	return nil
+}
+
+const (
+	// DefaultCertificateLimit is the default number of certificates returned
+	// by ListCertificates.
+	DefaultCertificateLimit = 20
+	// MaxCertificateLimit is the maximum number of certificates returned by
+	// ListCertificates.
+	MaxCertificateLimit = 100
+)
+
+// ListCertificatesOptions are the filters and the pagination parameters used
+// to list the X.509 certificates.
+type ListCertificatesOptions struct {
+	// Cursor is the index of the first certificate to return, as returned by
+	// a previous call to ListCertificates.
+	Cursor string
+	// Limit is the maximum number of certificates to return.
+	Limit int
+	// Provisioner matches the id or the name of the provisioner that
+	// authorized the certificate.
+	Provisioner string
+	// Subject is a case-insensitive substring of the subject or the subject
+	// alternative names of the certificate.
+	Subject string
+	// ExpiresAfter and ExpiresBefore define the window in which the
+	// certificates expire, if set.
+	ExpiresAfter  time.Time
+	ExpiresBefore time.Time
+	// Revoked filters the certificates by their revocation status, if set.
+	Revoked *bool
+}
+
+// CertificateInfo contains an X.509 certificate with the provisioner that
+// authorized it and its revocation information, if revoked.
+type CertificateInfo struct {
+	Certificate    *x509.Certificate
+	Provisioner    *ProvisionerData
+	RevocationInfo *RevokedCertificateInfo
+}
+
+func (o *ListCertificatesOptions) match(ci *CertificateInfo) bool {
+	crt := ci.Certificate
+	if o.Provisioner != "" {
+		if ci.Provisioner == nil || (ci.Provisioner.ID != o.Provisioner && ci.Provisioner.Name != o.Provisioner) {
+			return false
+		}
+	}
+	if !o.ExpiresAfter.IsZero() && crt.NotAfter.Before(o.ExpiresAfter) {
+		return false
+	}
+	if !o.ExpiresBefore.IsZero() && !crt.NotAfter.Before(o.ExpiresBefore) {
+		return false
+	}
+	if o.Revoked != nil && *o.Revoked != (ci.RevocationInfo != nil) {
+		return false
+	}
+	if o.Subject != "" {
+		return matchSubject(crt, strings.ToLower(o.Subject))
+	}
+	return true
+}
+
+func matchSubject(crt *x509.Certificate, s string) bool {
+	names := []string{crt.Subject.String()}
+	names = append(names, crt.DNSNames...)
+	names = append(names, crt.EmailAddresses...)
+	for _, ip := range crt.IPAddresses {
+		names = append(names, ip.String())
+	}
+	for _, u := range crt.URIs {
+		names = append(names, u.String())
+	}
+	for _, name := range names {
+		if strings.Contains(strings.ToLower(name), s) {
+			return true
+		}
+	}
+	return false
+}
+
+// ListCertificates returns a page of the X.509 certificates that match the
+// given options, in the order they were stored, and the cursor of the next
+// page. The cursor is empty if there are no more certificates.
+//
+// The certificates are read using the x509_certs_index table, starting at the
+// cursor, so a page only reads the certificates it needs to fill it.
+func (db *DB) ListCertificates(opts ListCertificatesOptions) ([]*CertificateInfo, string, error) {
+	switch {
+	case opts.Limit <= 0:
+		opts.Limit = DefaultCertificateLimit
+	case opts.Limit > MaxCertificateLimit:
+		opts.Limit = MaxCertificateLimit
+	}
+
+	first := uint64(1)
+	if opts.Cursor != "" {
+		var err error
+		if first, err = strconv.ParseUint(opts.Cursor, 10, 64); err != nil || first == 0 {
+			return nil, "", errors.Errorf("invalid cursor %s", opts.Cursor)
+		}
+	}
+	last, _, err := db.getLastCertificateIndex()
+	if err != nil {
+		return nil, "", err
+	}
+
+	certs := []*CertificateInfo{}
+	for i := first; i <= last; i++ {
+		key := []byte(strconv.FormatUint(i, 10))
+		sn, err := db.Get(certsIndexTable, key)
+		if err != nil {
+			// The index was reserved but the certificate was not stored.
+			if database.IsErrNotFound(err) {
+				continue
+			}
+			return nil, "", errors.Wrap(err, "database Get error")
+		}
+		ci, err := db.getCertificateInfo(string(sn))
+		if err != nil {
+			return nil, "", err
+		}
+		if !opts.match(ci) {
+			continue
+		}
+		if len(certs) == opts.Limit {
+			return certs, string(key), nil
+		}
+		certs = append(certs, ci)
+	}
+	return certs, "", nil
+}
+
+// getCertificateInfo returns the certificate with the given serial number,
+// the provisioner that authorized it and its revocation information.
+func (db *DB) getCertificateInfo(sn string) (*CertificateInfo, error) {
+	crt, err := db.GetCertificate(sn)
+	if err != nil {
+		return nil, err
+	}
+	ci := &CertificateInfo{Certificate: crt}
+	data, err := db.GetCertificateData(sn)
+	switch {
+	case err == nil:
+		ci.Provisioner = data.Provisioner
+	case !database.IsErrNotFound(err):
+		return nil, err
+	}
+	rci, err := db.GetRevokedCertificate(sn)
+	switch {
+	case err == nil:
+		ci.RevocationInfo = rci
+	case !database.IsErrNotFound(err):
+		return nil, err
+	}
+	return ci, nil
+}
+
 // UseToken returns true if we were able to successfully store the token for
 // for the first time, false otherwise.
 func (db *DB) UseToken(id, tok string) (bool, error) {
@@ -277,6 +636,187 @@ func (db *DB) UseToken(id, tok string) (bool, error) {
 	return swapped, nil
 }
 
+// SCEPChallenge is a one-time SCEP challenge password. The challenge itself is
+// not stored, the challenges are indexed by a hash of it.
+type SCEPChallenge struct {
+	Provisioner string     `json:"provisioner"`
+	Subject     string     `json:"subject,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	UsedAt      *time.Time `json:"usedAt,omitempty"`
+}
+
+// StoreSCEPChallenge stores a new one-time SCEP challenge with the given id.
+func (db *DB) StoreSCEPChallenge(id string, sc *SCEPChallenge) error {
+	b, err := json.Marshal(sc)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling scep challenge")
+	}
+	_, swapped, err := db.CmpAndSwap(scepChallengesTable, []byte(id), nil, b)
+	switch {
+	case err != nil:
+		return errors.Wrap(err, "database CmpAndSwap error")
+	case !swapped:
+		return ErrAlreadyExists
+	default:
+		return nil
+	}
+}
+
+// UseSCEPChallenge marks the SCEP challenge with the given id as used, and
+// returns it. It returns ErrSCEPChallengeNotFound if the challenge does not
+// exist or if it has already been used.
+func (db *DB) UseSCEPChallenge(id string) (*SCEPChallenge, error) {
+	b, err := db.Get(scepChallengesTable, []byte(id))
+	if err != nil {
+		if database.IsErrNotFound(err) {
+			return nil, ErrSCEPChallengeNotFound
+		}
+		return nil, errors.Wrap(err, "database Get error")
+	}
+	var sc SCEPChallenge
+	if err := json.Unmarshal(b, &sc); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling scep challenge")
+	}
+	if sc.UsedAt != nil {
+		return nil, ErrSCEPChallengeNotFound
+	}
+
+	now := time.Now().UTC()
+	sc.UsedAt = &now
+	nb, err := json.Marshal(sc)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling scep challenge")
+	}
+	_, swapped, err := db.CmpAndSwap(scepChallengesTable, []byte(id), b, nb)
+	switch {
+	case err != nil:
+		return nil, errors.Wrap(err, "database CmpAndSwap error")
+	case !swapped:
+		return nil, ErrSCEPChallengeNotFound
+	default:
+		return &sc, nil
+	}
+}
+
+// The status of the SCEP requests that require approval.
+const (
+	SCEPRequestPending  = "pending"
+	SCEPRequestApproved = "approved"
+	SCEPRequestRejected = "rejected"
+	SCEPRequestIssuing  = "issuing"
+	SCEPRequestIssued   = "issued"
+)
+
+// SCEPRequest is a SCEP certificate request queued for approval. The id is
+// the transaction id of the request.
+type SCEPRequest struct {
+	ID           string    `json:"id"`
+	Provisioner  string    `json:"provisioner"`
+	Subject      string    `json:"subject"`
+	CSR          []byte    `json:"csr"`
+	Status       string    `json:"status"`
+	SerialNumber string    `json:"serialNumber,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+func scepRequestKey(provisionerName, id string) []byte {
+	return []byte(provisionerName + "/" + id)
+}
+
+// CreateSCEPRequest stores a new SCEP request. It returns ErrAlreadyExists if
+// a request with the same provisioner and id already exists.
+func (db *DB) CreateSCEPRequest(req *SCEPRequest) error {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling scep request")
+	}
+	_, swapped, err := db.CmpAndSwap(scepRequestsTable, scepRequestKey(req.Provisioner, req.ID), nil, b)
+	switch {
+	case err != nil:
+		return errors.Wrap(err, "database CmpAndSwap error")
+	case !swapped:
+		return ErrAlreadyExists
+	default:
+		return nil
+	}
+}
+
+// GetSCEPRequest returns the SCEP request with the given provisioner and id.
+func (db *DB) GetSCEPRequest(provisionerName, id string) (*SCEPRequest, error) {
+	b, err := db.Get(scepRequestsTable, scepRequestKey(provisionerName, id))
+	if err != nil {
+		if database.IsErrNotFound(err) {
+			return nil, ErrSCEPRequestNotFound
+		}
+		return nil, errors.Wrap(err, "database Get error")
+	}
+	var req SCEPRequest
+	if err := json.Unmarshal(b, &req); err != nil {
+		return nil, errors.Wrap(err, "error unmarshaling scep request")
+	}
+	return &req, nil
+}
+
+// UpdateSCEPRequest updates a SCEP request only if the stored request has the
+// given status. It returns ErrSCEPRequestNotFound if the request does not
+// exist or if its status has changed.
+func (db *DB) UpdateSCEPRequest(req *SCEPRequest, fromStatus string) error {
+	key := scepRequestKey(req.Provisioner, req.ID)
+	old, err := db.Get(scepRequestsTable, key)
+	if err != nil {
+		if database.IsErrNotFound(err) {
+			return ErrSCEPRequestNotFound
+		}
+		return errors.Wrap(err, "database Get error")
+	}
+	var current SCEPRequest
+	if err := json.Unmarshal(old, &current); err != nil {
+		return errors.Wrap(err, "error unmarshaling scep request")
+	}
+	if current.Status != fromStatus {
+		return ErrSCEPRequestNotFound
+	}
+
+	b, err := json.Marshal(req)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling scep request")
+	}
+	_, swapped, err := db.CmpAndSwap(scepRequestsTable, key, old, b)
+	switch {
+	case err != nil:
+		return errors.Wrap(err, "database CmpAndSwap error")
+	case !swapped:
+		return ErrSCEPRequestNotFound
+	default:
+		return nil
+	}
+}
+
+// ListSCEPRequests returns the SCEP requests of the given provisioner, sorted
+// by creation time.
+func (db *DB) ListSCEPRequests(provisionerName string) ([]*SCEPRequest, error) {
+	entries, err := db.List(scepRequestsTable)
+	if err != nil {
+		return nil, errors.Wrap(err, "database List error")
+	}
+	var reqs []*SCEPRequest
+	for _, e := range entries {
+		var req SCEPRequest
+		if err := json.Unmarshal(e.Value, &req); err != nil {
+			return nil, errors.Wrap(err, "error unmarshaling scep request")
+		}
+		if req.Provisioner == provisionerName {
+			reqs = append(reqs, &req)
+		}
+	}
+	sort.Slice(reqs, func(i, j int) bool {
+		return reqs[i].CreatedAt.Before(reqs[j].CreatedAt)
+	})
+	return reqs, nil
+}
+
 // IsSSHHost returns if a principal is present in the ssh hosts table.
 func (db *DB) IsSSHHost(principal string) (bool, error) {
 	if _, err := db.Get(sshHostsTable, []byte(strings.ToLower(principal))); err != nil {
@@ -321,6 +861,23 @@ func (db *DB) StoreSSHCertificate(crt *ssh.Certificate) error {
 	return nil
 }
 
+// GetSSHCertificate retrieves an SSH certificate by the serial number.
+func (db *DB) GetSSHCertificate(serial string) (*ssh.Certificate, error) {
+	b, err := db.Get(sshCertsTable, []byte(serial))
+	if err != nil {
+		return nil, errors.Wrap(err, "database Get error")
+	}
+	pub, err := ssh.ParsePublicKey(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing ssh certificate with serial number %s", serial)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, errors.Errorf("error parsing ssh certificate with serial number %s: unexpected type %T", serial, pub)
+	}
+	return cert, nil
+}
+
 // GetSSHHostPrincipals gets a list of all valid host principals.
 func (db *DB) GetSSHHostPrincipals() ([]string, error) {
 	entries, err := db.List(sshHostPrincipalsTable)
@@ -353,20 +910,33 @@ func (db *DB) Shutdown() error {
 
 // MockAuthDB mocks the AuthDB interface. //
 type MockAuthDB struct {
-	Err                   error
-	Ret1                  interface{}
-	MIsRevoked            func(string) (bool, error)
-	MIsSSHRevoked         func(string) (bool, error)
-	MRevoke               func(rci *RevokedCertificateInfo) error
-	MRevokeSSH            func(rci *RevokedCertificateInfo) error
-	MGetCertificate       func(serialNumber string) (*x509.Certificate, error)
-	MGetCertificateData   func(serialNumber string) (*CertificateData, error)
-	MStoreCertificate     func(crt *x509.Certificate) error
-	MUseToken             func(id, tok string) (bool, error)
-	MIsSSHHost            func(principal string) (bool, error)
-	MStoreSSHCertificate  func(crt *ssh.Certificate) error
-	MGetSSHHostPrincipals func() ([]string, error)
-	MShutdown             func() error
+	Err                        error
+	Ret1                       interface{}
+	MIsRevoked                 func(string) (bool, error)
+	MIsSSHRevoked              func(string) (bool, error)
+	MRevoke                    func(rci *RevokedCertificateInfo) error
+	MRevokeSSH                 func(rci *RevokedCertificateInfo) error
+	MGetRevokedCertificate     func(sn string) (*RevokedCertificateInfo, error)
+	MGetRevokedCertificates    func() ([]RevokedCertificateInfo, error)
+	MGetRevokedSSHCertificates func() ([]RevokedCertificateInfo, error)
+	MGetCRL                    func() (*CertificateRevocationListInfo, error)
+	MStoreCRL                  func(*CertificateRevocationListInfo) error
+	MGetCertificate            func(serialNumber string) (*x509.Certificate, error)
+	MGetCertificateData        func(serialNumber string) (*CertificateData, error)
+	MListCertificates          func(opts ListCertificatesOptions) ([]*CertificateInfo, string, error)
+	MStoreCertificate          func(crt *x509.Certificate) error
+	MUseToken                  func(id, tok string) (bool, error)
+	MIsSSHHost                 func(principal string) (bool, error)
+	MStoreSSHCertificate       func(crt *ssh.Certificate) error
+	MGetSSHCertificate         func(serial string) (*ssh.Certificate, error)
+	MGetSSHHostPrincipals      func() ([]string, error)
+	MStoreSCEPChallenge        func(id string, sc *SCEPChallenge) error
+	MUseSCEPChallenge          func(id string) (*SCEPChallenge, error)
+	MCreateSCEPRequest         func(req *SCEPRequest) error
+	MGetSCEPRequest            func(provisionerName, id string) (*SCEPRequest, error)
+	MUpdateSCEPRequest         func(req *SCEPRequest, fromStatus string) error
+	MListSCEPRequests          func(provisionerName string) ([]*SCEPRequest, error)
+	MShutdown                  func() error
 }
 
 // IsRevoked mock.
@@ -412,6 +982,58 @@ func (m *MockAuthDB) RevokeSSH(rci *RevokedCertificateInfo) error {
 	return m.Err
 }
 
+// GetRevokedCertificate mock.
+func (m *MockAuthDB) GetRevokedCertificate(sn string) (*RevokedCertificateInfo, error) {
+	if m.MGetRevokedCertificate != nil {
+		return m.MGetRevokedCertificate(sn)
+	}
+	if rci, ok := m.Ret1.(*RevokedCertificateInfo); ok {
+		return rci, m.Err
+	}
+	return nil, m.Err
+}
+
+// GetRevokedCertificates mock.
+func (m *MockAuthDB) GetRevokedCertificates() ([]RevokedCertificateInfo, error) {
+	if m.MGetRevokedCertificates != nil {
+		return m.MGetRevokedCertificates()
+	}
+	if rcis, ok := m.Ret1.([]RevokedCertificateInfo); ok {
+		return rcis, m.Err
+	}
+	return nil, m.Err
+}
+
+// GetRevokedSSHCertificates mock.
+func (m *MockAuthDB) GetRevokedSSHCertificates() ([]RevokedCertificateInfo, error) {
+	if m.MGetRevokedSSHCertificates != nil {
+		return m.MGetRevokedSSHCertificates()
+	}
+	if rcis, ok := m.Ret1.([]RevokedCertificateInfo); ok {
+		return rcis, m.Err
+	}
+	return nil, m.Err
+}
+
+// GetCRL mock.
+func (m *MockAuthDB) GetCRL() (*CertificateRevocationListInfo, error) {
+	if m.MGetCRL != nil {
+		return m.MGetCRL()
+	}
+	if crlInfo, ok := m.Ret1.(*CertificateRevocationListInfo); ok {
+		return crlInfo, m.Err
+	}
+	return nil, m.Err
+}
+
+// StoreCRL mock.
+func (m *MockAuthDB) StoreCRL(crlInfo *CertificateRevocationListInfo) error {
+	if m.MStoreCRL != nil {
+		return m.MStoreCRL(crlInfo)
+	}
+	return m.Err
+}
+
 // GetCertificate mock.
 func (m *MockAuthDB) GetCertificate(serialNumber string) (*x509.Certificate, error) {
 	if m.MGetCertificate != nil {
@@ -431,6 +1053,17 @@ func (m *MockAuthDB) GetCertificateData(serialNumber string) (*CertificateData,
 	return nil, m.Err
 }
 
+// ListCertificates mock.
+func (m *MockAuthDB) ListCertificates(opts ListCertificatesOptions) ([]*CertificateInfo, string, error) {
+	if m.MListCertificates != nil {
+		return m.MListCertificates(opts)
+	}
+	if certs, ok := m.Ret1.([]*CertificateInfo); ok {
+		return certs, "", m.Err
+	}
+	return nil, "", m.Err
+}
+
 // StoreCertificate mock.
 func (m *MockAuthDB) StoreCertificate(crt *x509.Certificate) error {
 	if m.MStoreCertificate != nil {
@@ -439,6 +1072,63 @@ func (m *MockAuthDB) StoreCertificate(crt *x509.Certificate) error {
 	return m.Err
 }
 
+// StoreSCEPChallenge mock.
+func (m *MockAuthDB) StoreSCEPChallenge(id string, sc *SCEPChallenge) error {
+	if m.MStoreSCEPChallenge != nil {
+		return m.MStoreSCEPChallenge(id, sc)
+	}
+	return m.Err
+}
+
+// UseSCEPChallenge mock.
+func (m *MockAuthDB) UseSCEPChallenge(id string) (*SCEPChallenge, error) {
+	if m.MUseSCEPChallenge != nil {
+		return m.MUseSCEPChallenge(id)
+	}
+	if sc, ok := m.Ret1.(*SCEPChallenge); ok {
+		return sc, m.Err
+	}
+	return nil, m.Err
+}
+
+// CreateSCEPRequest mock.
+func (m *MockAuthDB) CreateSCEPRequest(req *SCEPRequest) error {
+	if m.MCreateSCEPRequest != nil {
+		return m.MCreateSCEPRequest(req)
+	}
+	return m.Err
+}
+
+// GetSCEPRequest mock.
+func (m *MockAuthDB) GetSCEPRequest(provisionerName, id string) (*SCEPRequest, error) {
+	if m.MGetSCEPRequest != nil {
+		return m.MGetSCEPRequest(provisionerName, id)
+	}
+	if req, ok := m.Ret1.(*SCEPRequest); ok {
+		return req, m.Err
+	}
+	return nil, m.Err
+}
+
+// UpdateSCEPRequest mock.
+func (m *MockAuthDB) UpdateSCEPRequest(req *SCEPRequest, fromStatus string) error {
+	if m.MUpdateSCEPRequest != nil {
+		return m.MUpdateSCEPRequest(req, fromStatus)
+	}
+	return m.Err
+}
+
+// ListSCEPRequests mock.
+func (m *MockAuthDB) ListSCEPRequests(provisionerName string) ([]*SCEPRequest, error) {
+	if m.MListSCEPRequests != nil {
+		return m.MListSCEPRequests(provisionerName)
+	}
+	if reqs, ok := m.Ret1.([]*SCEPRequest); ok {
+		return reqs, m.Err
+	}
+	return nil, m.Err
+}
+
 // IsSSHHost mock.
 func (m *MockAuthDB) IsSSHHost(principal string) (bool, error) {
 	if m.MIsSSHHost != nil {
@@ -455,6 +1145,17 @@ func (m *MockAuthDB) StoreSSHCertificate(crt *ssh.Certificate) error {
 	return m.Err
 }
 
+// GetSSHCertificate mock.
+func (m *MockAuthDB) GetSSHCertificate(serial string) (*ssh.Certificate, error) {
+	if m.MGetSSHCertificate != nil {
+		return m.MGetSSHCertificate(serial)
+	}
+	if crt, ok := m.Ret1.(*ssh.Certificate); ok {
+		return crt, m.Err
+	}
+	return nil, m.Err
+}
+
 // GetSSHHostPrincipals mock.
 func (m *MockAuthDB) GetSSHHostPrincipals() ([]string, error) {
 	if m.MGetSSHHostPrincipals != nil {
@@ -1,16 +1,23 @@
 package db
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
 	"errors"
 	"math/big"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/smallstep/assert"
 	"github.com/smallstep/certificates/authority/provisioner"
 	"github.com/smallstep/nosql"
 	"github.com/smallstep/nosql/database"
+	"golang.org/x/crypto/ssh"
 )
 
 func TestIsRevoked(t *testing.T) {
@@ -173,6 +180,18 @@ func TestDB_StoreCertificateChain(t *testing.T) {
 	chain := []*x509.Certificate{
 		{Raw: []byte("the certificate"), SerialNumber: big.NewInt(1234)},
 	}
+	getLastIndex := func(bucket, key []byte) ([]byte, error) {
+		assert.Equals(t, []byte("x509_certs_index"), bucket)
+		assert.Equals(t, []byte("last"), key)
+		return []byte("41"), nil
+	}
+	swapLastIndex := func(bucket, key, old, newval []byte) ([]byte, bool, error) {
+		assert.Equals(t, []byte("x509_certs_index"), bucket)
+		assert.Equals(t, []byte("last"), key)
+		assert.Equals(t, []byte("41"), old)
+		assert.Equals(t, []byte("42"), newval)
+		return newval, true, nil
+	}
 	type fields struct {
 		DB   nosql.DB
 		isUp bool
@@ -188,8 +207,10 @@ func TestDB_StoreCertificateChain(t *testing.T) {
 		wantErr bool
 	}{
 		{"ok", fields{&MockNoSQLDB{
+			MGet:        getLastIndex,
+			MCmpAndSwap: swapLastIndex,
 			MUpdate: func(tx *database.Tx) error {
-				if len(tx.Operations) != 2 {
+				if len(tx.Operations) != 3 {
 					t.Fatal("unexpected number of operations")
 				}
 				assert.Equals(t, []byte("x509_certs"), tx.Operations[0].Bucket)
@@ -198,12 +219,17 @@ func TestDB_StoreCertificateChain(t *testing.T) {
 				assert.Equals(t, []byte("x509_certs_data"), tx.Operations[1].Bucket)
 				assert.Equals(t, []byte("1234"), tx.Operations[1].Key)
 				assert.Equals(t, []byte(`{"provisioner":{"id":"some-id","name":"admin","type":"JWK"}}`), tx.Operations[1].Value)
+				assert.Equals(t, []byte("x509_certs_index"), tx.Operations[2].Bucket)
+				assert.Equals(t, []byte("42"), tx.Operations[2].Key)
+				assert.Equals(t, []byte("1234"), tx.Operations[2].Value)
 				return nil
 			},
 		}, true}, args{p, chain}, false},
 		{"ok no provisioner", fields{&MockNoSQLDB{
+			MGet:        getLastIndex,
+			MCmpAndSwap: swapLastIndex,
 			MUpdate: func(tx *database.Tx) error {
-				if len(tx.Operations) != 2 {
+				if len(tx.Operations) != 3 {
 					t.Fatal("unexpected number of operations")
 				}
 				assert.Equals(t, []byte("x509_certs"), tx.Operations[0].Bucket)
@@ -212,10 +238,20 @@ func TestDB_StoreCertificateChain(t *testing.T) {
 				assert.Equals(t, []byte("x509_certs_data"), tx.Operations[1].Bucket)
 				assert.Equals(t, []byte("1234"), tx.Operations[1].Key)
 				assert.Equals(t, []byte(`{}`), tx.Operations[1].Value)
+				assert.Equals(t, []byte("x509_certs_index"), tx.Operations[2].Bucket)
+				assert.Equals(t, []byte("42"), tx.Operations[2].Key)
+				assert.Equals(t, []byte("1234"), tx.Operations[2].Value)
 				return nil
 			},
 		}, true}, args{nil, chain}, false},
+		{"fail next index", fields{&MockNoSQLDB{
+			MGet: func(bucket, key []byte) ([]byte, error) {
+				return nil, errors.New("test error")
+			},
+		}, true}, args{p, chain}, true},
 		{"fail store certificate", fields{&MockNoSQLDB{
+			MGet:        getLastIndex,
+			MCmpAndSwap: swapLastIndex,
 			MUpdate: func(tx *database.Tx) error {
 				return errors.New("test error")
 			},
@@ -234,6 +270,130 @@ func TestDB_StoreCertificateChain(t *testing.T) {
 	}
 }
 
+func TestDB_nextCertificateIndex(t *testing.T) {
+	tests := []struct {
+		name    string
+		db      *MockNoSQLDB
+		want    []byte
+		wantErr bool
+	}{
+		{"ok/first", &MockNoSQLDB{
+			MGet: func(bucket, key []byte) ([]byte, error) {
+				return nil, database.ErrNotFound
+			},
+			MCmpAndSwap: func(bucket, key, old, newval []byte) ([]byte, bool, error) {
+				assert.Equals(t, []byte(nil), old)
+				return newval, true, nil
+			},
+		}, []byte("1"), false},
+		{"ok/retry", func() *MockNoSQLDB {
+			last := "1"
+			return &MockNoSQLDB{
+				MGet: func(bucket, key []byte) ([]byte, error) {
+					return []byte(last), nil
+				},
+				MCmpAndSwap: func(bucket, key, old, newval []byte) ([]byte, bool, error) {
+					// Another certificate takes the index 2 first.
+					if last == "1" {
+						last = "2"
+						return []byte(last), false, nil
+					}
+					return newval, true, nil
+				},
+			}
+		}(), []byte("3"), false},
+		{"fail/get", &MockNoSQLDB{
+			MGet: func(bucket, key []byte) ([]byte, error) {
+				return nil, errors.New("force")
+			},
+		}, nil, true},
+		{"fail/parse", &MockNoSQLDB{
+			MGet: func(bucket, key []byte) ([]byte, error) {
+				return []byte("foo"), nil
+			},
+		}, nil, true},
+		{"fail/cmpAndSwap", &MockNoSQLDB{
+			MGet: func(bucket, key []byte) ([]byte, error) {
+				return []byte("1"), nil
+			},
+			MCmpAndSwap: func(bucket, key, old, newval []byte) ([]byte, bool, error) {
+				return nil, false, errors.New("force")
+			},
+		}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &DB{DB: tt.db, isUp: true}
+			got, err := db.nextCertificateIndex()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DB.nextCertificateIndex() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DB.nextCertificateIndex() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDB_backfillCertificateIndex(t *testing.T) {
+	entries := []*database.Entry{
+		{Key: []byte("3"), Value: []byte("cert 3")},
+		{Key: []byte("1"), Value: []byte("cert 1")},
+		{Key: []byte("2"), Value: []byte("cert 2")},
+	}
+	tests := []struct {
+		name    string
+		last    []byte
+		listErr error
+		setErr  error
+		want    map[string]string
+		wantErr bool
+	}{
+		{"ok", nil, nil, nil, map[string]string{"1": "1", "2": "2", "3": "3", "last": "3"}, false},
+		{"ok/indexed", []byte("3"), nil, nil, map[string]string{}, false},
+		{"fail/list", nil, errors.New("force"), nil, map[string]string{}, true},
+		{"fail/set", nil, nil, errors.New("force"), map[string]string{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := map[string]string{}
+			db := &DB{DB: &MockNoSQLDB{
+				MGet: func(bucket, key []byte) ([]byte, error) {
+					if tt.last == nil {
+						return nil, database.ErrNotFound
+					}
+					return tt.last, nil
+				},
+				MList: func(bucket []byte) ([]*database.Entry, error) {
+					assert.Equals(t, []byte("x509_certs"), bucket)
+					return entries, tt.listErr
+				},
+				MSet: func(bucket, key, value []byte) error {
+					assert.Equals(t, []byte("x509_certs_index"), bucket)
+					if tt.setErr != nil {
+						return tt.setErr
+					}
+					got[string(key)] = string(value)
+					return nil
+				},
+				MCmpAndSwap: func(bucket, key, old, newval []byte) ([]byte, bool, error) {
+					assert.Equals(t, []byte("x509_certs_index"), bucket)
+					assert.Equals(t, []byte(nil), old)
+					got[string(key)] = string(newval)
+					return newval, true, nil
+				},
+			}, isUp: true}
+			if err := db.backfillCertificateIndex(); (err != nil) != tt.wantErr {
+				t.Errorf("DB.backfillCertificateIndex() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DB.backfillCertificateIndex() index = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestDB_GetCertificateData(t *testing.T) {
 	type fields struct {
 		DB   nosql.DB
@@ -293,3 +453,481 @@ func TestDB_GetCertificateData(t *testing.T) {
 		})
 	}
 }
+
+func newListCertificate(t *testing.T, sn int64, cn string, notAfter time.Time) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(sn),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    notAfter.Add(-24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crt
+}
+
+func TestDB_ListCertificates(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	crt1 := newListCertificate(t, 1, "foo.internal", now.Add(time.Hour))
+	crt2 := newListCertificate(t, 2, "bar.internal", now.Add(48*time.Hour))
+	crt3 := newListCertificate(t, 3, "foo.example.com", now.Add(10*24*time.Hour))
+	jwk := &ProvisionerData{ID: "jwk-id", Name: "jwk", Type: "JWK"}
+	acme := &ProvisionerData{ID: "acme-id", Name: "acme", Type: "ACME"}
+	rci := RevokedCertificateInfo{Serial: "2", ReasonCode: 1}
+
+	mustJSON := func(v interface{}) []byte {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	// The index 3 was reserved but the certificate was never stored.
+	tables := map[string]map[string][]byte{
+		"x509_certs_index": {
+			"last": []byte("4"), "1": []byte("1"), "2": []byte("2"), "4": []byte("3"),
+		},
+		"x509_certs": {
+			"1": crt1.Raw, "2": crt2.Raw, "3": crt3.Raw,
+		},
+		"x509_certs_data": {
+			"1": mustJSON(CertificateData{Provisioner: jwk}),
+			"2": mustJSON(CertificateData{Provisioner: acme}),
+			"3": mustJSON(CertificateData{Provisioner: jwk}),
+		},
+		"revoked_x509_certs": {
+			"2": mustJSON(rci),
+		},
+	}
+	mockDB := func(err error) *MockNoSQLDB {
+		return &MockNoSQLDB{
+			MGet: func(bucket, key []byte) ([]byte, error) {
+				if err != nil && string(bucket) == "x509_certs" {
+					return nil, err
+				}
+				if b, ok := tables[string(bucket)][string(key)]; ok {
+					return b, nil
+				}
+				return nil, database.ErrNotFound
+			},
+		}
+	}
+	emptyDB := &MockNoSQLDB{
+		MGet: func(bucket, key []byte) ([]byte, error) {
+			return nil, database.ErrNotFound
+		},
+	}
+	revoked := true
+
+	tests := []struct {
+		name       string
+		db         nosql.DB
+		opts       ListCertificatesOptions
+		want       []string
+		wantCursor string
+		wantErr    bool
+	}{
+		{"ok", mockDB(nil), ListCertificatesOptions{}, []string{"1", "2", "3"}, "", false},
+		{"ok/limit", mockDB(nil), ListCertificatesOptions{Limit: 2}, []string{"1", "2"}, "4", false},
+		{"ok/cursor", mockDB(nil), ListCertificatesOptions{Cursor: "2", Limit: 1}, []string{"2"}, "4", false},
+		{"ok/cursor-last", mockDB(nil), ListCertificatesOptions{Cursor: "4"}, []string{"3"}, "", false},
+		{"ok/provisioner-name", mockDB(nil), ListCertificatesOptions{Provisioner: "jwk"}, []string{"1", "3"}, "", false},
+		{"ok/provisioner-id", mockDB(nil), ListCertificatesOptions{Provisioner: "acme-id"}, []string{"2"}, "", false},
+		{"ok/provisioner-limit", mockDB(nil), ListCertificatesOptions{Provisioner: "jwk", Limit: 1}, []string{"1"}, "4", false},
+		{"ok/subject", mockDB(nil), ListCertificatesOptions{Subject: "FOO"}, []string{"1", "3"}, "", false},
+		{"ok/expiry", mockDB(nil), ListCertificatesOptions{ExpiresAfter: now, ExpiresBefore: now.Add(7 * 24 * time.Hour)}, []string{"1", "2"}, "", false},
+		{"ok/revoked", mockDB(nil), ListCertificatesOptions{Revoked: &revoked}, []string{"2"}, "", false},
+		{"ok/none", mockDB(nil), ListCertificatesOptions{Subject: "zap"}, []string{}, "", false},
+		{"ok/empty", emptyDB, ListCertificatesOptions{}, []string{}, "", false},
+		{"fail/cursor", mockDB(nil), ListCertificatesOptions{Cursor: "foo"}, nil, "", true},
+		{"fail/cursor-zero", mockDB(nil), ListCertificatesOptions{Cursor: "0"}, nil, "", true},
+		{"fail/get", mockDB(errors.New("force")), ListCertificatesOptions{}, nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &DB{DB: tt.db, isUp: true}
+			got, cursor, err := db.ListCertificates(tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DB.ListCertificates() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			serials := []string{}
+			for _, ci := range got {
+				sn := ci.Certificate.SerialNumber.String()
+				serials = append(serials, sn)
+				if ci.Provisioner == nil {
+					t.Errorf("DB.ListCertificates() provisioner of %s is nil", sn)
+				}
+				if (ci.RevocationInfo != nil) != (sn == "2") {
+					t.Errorf("DB.ListCertificates() revocation info of %s = %v", sn, ci.RevocationInfo)
+				}
+			}
+			if !reflect.DeepEqual(serials, tt.want) {
+				t.Errorf("DB.ListCertificates() = %v, want %v", serials, tt.want)
+			}
+			if cursor != tt.wantCursor {
+				t.Errorf("DB.ListCertificates() cursor = %v, want %v", cursor, tt.wantCursor)
+			}
+		})
+	}
+}
+
+func TestDB_UseSCEPChallenge(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	sc := &SCEPChallenge{
+		Provisioner: "scep",
+		Subject:     "device-1",
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(time.Hour),
+	}
+	b, err := json.Marshal(sc)
+	assert.FatalError(t, err)
+	used := *sc
+	used.UsedAt = &now
+	usedBytes, err := json.Marshal(used)
+	assert.FatalError(t, err)
+
+	tests := []struct {
+		name    string
+		db      *DB
+		want    *SCEPChallenge
+		wantErr error
+	}{
+		{"ok", &DB{&MockNoSQLDB{
+			MGet: func(bucket, key []byte) ([]byte, error) {
+				assert.Equals(t, scepChallengesTable, bucket)
+				assert.Equals(t, []byte("id"), key)
+				return b, nil
+			},
+			MCmpAndSwap: func(bucket, key, old, newval []byte) ([]byte, bool, error) {
+				assert.Equals(t, b, old)
+				var v SCEPChallenge
+				assert.FatalError(t, json.Unmarshal(newval, &v))
+				assert.NotNil(t, v.UsedAt)
+				return newval, true, nil
+			},
+		}, true}, sc, nil},
+		{"fail not found", &DB{&MockNoSQLDB{
+			MGet: func(bucket, key []byte) ([]byte, error) {
+				return nil, database.ErrNotFound
+			},
+		}, true}, nil, ErrSCEPChallengeNotFound},
+		{"fail used", &DB{&MockNoSQLDB{
+			MGet: func(bucket, key []byte) ([]byte, error) {
+				return usedBytes, nil
+			},
+		}, true}, nil, ErrSCEPChallengeNotFound},
+		{"fail concurrent use", &DB{&MockNoSQLDB{
+			MGet: func(bucket, key []byte) ([]byte, error) {
+				return b, nil
+			},
+			MCmpAndSwap: func(bucket, key, old, newval []byte) ([]byte, bool, error) {
+				return usedBytes, false, nil
+			},
+		}, true}, nil, ErrSCEPChallengeNotFound},
+		{"fail get", &DB{&MockNoSQLDB{
+			MGet: func(bucket, key []byte) ([]byte, error) {
+				return nil, errors.New("force")
+			},
+		}, true}, nil, errors.New("database Get error: force")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.db.UseSCEPChallenge("id")
+			if tt.wantErr != nil {
+				if assert.Error(t, err) {
+					assert.Equals(t, tt.wantErr.Error(), err.Error())
+				}
+				return
+			}
+			assert.FatalError(t, err)
+			assert.NotNil(t, got.UsedAt)
+			got.UsedAt = nil
+			assert.Equals(t, tt.want, got)
+		})
+	}
+}
+
+func TestDB_StoreSCEPChallenge(t *testing.T) {
+	tests := []struct {
+		name    string
+		db      *DB
+		wantErr error
+	}{
+		{"ok", &DB{&MockNoSQLDB{
+			MCmpAndSwap: func(bucket, key, old, newval []byte) ([]byte, bool, error) {
+				assert.Equals(t, scepChallengesTable, bucket)
+				assert.Nil(t, old)
+				return newval, true, nil
+			},
+		}, true}, nil},
+		{"fail exists", &DB{&MockNoSQLDB{
+			MCmpAndSwap: func(bucket, key, old, newval []byte) ([]byte, bool, error) {
+				return []byte("foo"), false, nil
+			},
+		}, true}, ErrAlreadyExists},
+		{"fail", &DB{&MockNoSQLDB{
+			MCmpAndSwap: func(bucket, key, old, newval []byte) ([]byte, bool, error) {
+				return nil, false, errors.New("force")
+			},
+		}, true}, errors.New("database CmpAndSwap error: force")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.db.StoreSCEPChallenge("id", &SCEPChallenge{Provisioner: "scep"})
+			if tt.wantErr != nil {
+				if assert.Error(t, err) {
+					assert.Equals(t, tt.wantErr.Error(), err.Error())
+				}
+				return
+			}
+			assert.FatalError(t, err)
+		})
+	}
+}
+
+func TestDB_UpdateSCEPRequest(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	pending := &SCEPRequest{
+		ID:          "tx-1",
+		Provisioner: "scep",
+		Subject:     "device-1",
+		Status:      SCEPRequestPending,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	b, err := json.Marshal(pending)
+	assert.FatalError(t, err)
+	approved := *pending
+	approved.Status = SCEPRequestApproved
+
+	tests := []struct {
+		name    string
+		db      *DB
+		wantErr error
+	}{
+		{"ok", &DB{&MockNoSQLDB{
+			MGet: func(bucket, key []byte) ([]byte, error) {
+				assert.Equals(t, scepRequestsTable, bucket)
+				assert.Equals(t, []byte("scep/tx-1"), key)
+				return b, nil
+			},
+			MCmpAndSwap: func(bucket, key, old, newval []byte) ([]byte, bool, error) {
+				assert.Equals(t, b, old)
+				var v SCEPRequest
+				assert.FatalError(t, json.Unmarshal(newval, &v))
+				assert.Equals(t, SCEPRequestApproved, v.Status)
+				return newval, true, nil
+			},
+		}, true}, nil},
+		{"fail not found", &DB{&MockNoSQLDB{
+			MGet: func(bucket, key []byte) ([]byte, error) {
+				return nil, database.ErrNotFound
+			},
+		}, true}, ErrSCEPRequestNotFound},
+		{"fail status", &DB{&MockNoSQLDB{
+			MGet: func(bucket, key []byte) ([]byte, error) {
+				return json.Marshal(approved)
+			},
+		}, true}, ErrSCEPRequestNotFound},
+		{"fail concurrent update", &DB{&MockNoSQLDB{
+			MGet: func(bucket, key []byte) ([]byte, error) {
+				return b, nil
+			},
+			MCmpAndSwap: func(bucket, key, old, newval []byte) ([]byte, bool, error) {
+				return nil, false, nil
+			},
+		}, true}, ErrSCEPRequestNotFound},
+		{"fail get", &DB{&MockNoSQLDB{
+			MGet: func(bucket, key []byte) ([]byte, error) {
+				return nil, errors.New("force")
+			},
+		}, true}, errors.New("database Get error: force")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := approved
+			err := tt.db.UpdateSCEPRequest(&req, SCEPRequestPending)
+			if tt.wantErr != nil {
+				if assert.Error(t, err) {
+					assert.Equals(t, tt.wantErr.Error(), err.Error())
+				}
+				return
+			}
+			assert.FatalError(t, err)
+		})
+	}
+}
+
+func TestDB_ListSCEPRequests(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	first := &SCEPRequest{ID: "tx-1", Provisioner: "scep", Status: SCEPRequestPending, CreatedAt: now, UpdatedAt: now}
+	second := &SCEPRequest{ID: "tx-2", Provisioner: "scep", Status: SCEPRequestIssued, CreatedAt: now.Add(time.Minute), UpdatedAt: now}
+	other := &SCEPRequest{ID: "tx-3", Provisioner: "other", Status: SCEPRequestPending, CreatedAt: now, UpdatedAt: now}
+	entry := func(req *SCEPRequest) *database.Entry {
+		b, err := json.Marshal(req)
+		assert.FatalError(t, err)
+		return &database.Entry{Bucket: scepRequestsTable, Key: scepRequestKey(req.Provisioner, req.ID), Value: b}
+	}
+
+	tests := []struct {
+		name    string
+		db      *DB
+		want    []*SCEPRequest
+		wantErr error
+	}{
+		{"ok", &DB{&MockNoSQLDB{
+			MList: func(bucket []byte) ([]*database.Entry, error) {
+				assert.Equals(t, scepRequestsTable, bucket)
+				return []*database.Entry{entry(second), entry(other), entry(first)}, nil
+			},
+		}, true}, []*SCEPRequest{first, second}, nil},
+		{"ok empty", &DB{&MockNoSQLDB{
+			MList: func(bucket []byte) ([]*database.Entry, error) {
+				return []*database.Entry{entry(other)}, nil
+			},
+		}, true}, nil, nil},
+		{"fail", &DB{&MockNoSQLDB{
+			MList: func(bucket []byte) ([]*database.Entry, error) {
+				return nil, errors.New("force")
+			},
+		}, true}, nil, errors.New("database List error: force")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.db.ListSCEPRequests("scep")
+			if tt.wantErr != nil {
+				if assert.Error(t, err) {
+					assert.Equals(t, tt.wantErr.Error(), err.Error())
+				}
+				return
+			}
+			assert.FatalError(t, err)
+			assert.Equals(t, tt.want, got)
+		})
+	}
+}
+
+func TestDB_GetRevokedSSHCertificates(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	rci := RevokedCertificateInfo{Serial: "1234", KeyID: "jane@example.com", RevokedAt: now, ExpiresAt: now.Add(time.Hour)}
+	b, err := json.Marshal(rci)
+	assert.FatalError(t, err)
+
+	tests := []struct {
+		name    string
+		db      *DB
+		want    []RevokedCertificateInfo
+		wantErr error
+	}{
+		{"ok", &DB{&MockNoSQLDB{
+			MList: func(bucket []byte) ([]*database.Entry, error) {
+				assert.Equals(t, revokedSSHCertsTable, bucket)
+				return []*database.Entry{{Bucket: bucket, Key: []byte("1234"), Value: b}}, nil
+			},
+		}, true}, []RevokedCertificateInfo{rci}, nil},
+		{"ok empty", &DB{&MockNoSQLDB{
+			MList: func(bucket []byte) ([]*database.Entry, error) {
+				return nil, nil
+			},
+		}, true}, []RevokedCertificateInfo{}, nil},
+		{"fail", &DB{&MockNoSQLDB{
+			MList: func(bucket []byte) ([]*database.Entry, error) {
+				return nil, errors.New("force")
+			},
+		}, true}, nil, errors.New("database List error: force")},
+		{"fail unmarshal", &DB{&MockNoSQLDB{
+			MList: func(bucket []byte) ([]*database.Entry, error) {
+				return []*database.Entry{{Bucket: bucket, Key: []byte("1234"), Value: []byte(`{"bad-json"}`)}}, nil
+			},
+		}, true}, nil, errors.New("error unmarshaling revoked certificate info: invalid character '}' after object key")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.db.GetRevokedSSHCertificates()
+			if tt.wantErr != nil {
+				if assert.Error(t, err) {
+					assert.Equals(t, tt.wantErr.Error(), err.Error())
+				}
+				return
+			}
+			assert.FatalError(t, err)
+			assert.Equals(t, tt.want, got)
+		})
+	}
+}
+
+func TestDB_GetSSHCertificate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	assert.FatalError(t, err)
+	crt := &ssh.Certificate{
+		Key:             signer.PublicKey(),
+		Serial:          1234,
+		CertType:        ssh.UserCert,
+		KeyId:           "jane@example.com",
+		ValidPrincipals: []string{"jane"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	assert.FatalError(t, crt.SignCert(rand.Reader, signer))
+
+	tests := []struct {
+		name    string
+		db      *DB
+		want    *ssh.Certificate
+		wantErr bool
+	}{
+		{"ok", &DB{&MockNoSQLDB{
+			MGet: func(bucket, key []byte) ([]byte, error) {
+				assert.Equals(t, sshCertsTable, bucket)
+				assert.Equals(t, []byte("1234"), key)
+				return crt.Marshal(), nil
+			},
+		}, true}, crt, false},
+		{"fail not found", &DB{&MockNoSQLDB{
+			MGet: func(bucket, key []byte) ([]byte, error) {
+				return nil, database.ErrNotFound
+			},
+		}, true}, nil, true},
+		{"fail parse", &DB{&MockNoSQLDB{
+			MGet: func(bucket, key []byte) ([]byte, error) {
+				return []byte("foo"), nil
+			},
+		}, true}, nil, true},
+		{"fail not certificate", &DB{&MockNoSQLDB{
+			MGet: func(bucket, key []byte) ([]byte, error) {
+				return signer.PublicKey().Marshal(), nil
+			},
+		}, true}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.db.GetSSHCertificate("1234")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DB.GetSSHCertificate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.want == nil {
+				assert.Nil(t, got)
+			} else {
+				assert.Equals(t, tt.want.Marshal(), got.Marshal())
+			}
+		})
+	}
+}
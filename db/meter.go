@@ -0,0 +1,99 @@
+package db
+
+import (
+	"time"
+
+	"github.com/smallstep/nosql"
+	"github.com/smallstep/nosql/database"
+)
+
+// Meter is the interface used to gather metrics about the database
+// operations.
+type Meter interface {
+	// DBOperation is called after each database operation with the name of
+	// the operation, the table, the time it took, and the error, if any. Not
+	// found errors are reported as successful operations.
+	DBOperation(op, table string, d time.Duration, err error)
+}
+
+// Option is the type of options passed to New.
+type Option func(o *options)
+
+type options struct {
+	meter Meter
+}
+
+// WithMeter reports the latency and the errors of the database operations to
+// the given meter.
+func WithMeter(m Meter) Option {
+	return func(o *options) {
+		o.meter = m
+	}
+}
+
+// instrumentedDB is a nosql.DB that reports the database operations to a
+// meter.
+type instrumentedDB struct {
+	nosql.DB
+	meter Meter
+}
+
+func (db *instrumentedDB) observe(op string, table []byte, start time.Time, err error) {
+	if nosql.IsErrNotFound(err) {
+		err = nil
+	}
+	db.meter.DBOperation(op, string(table), time.Since(start), err)
+}
+
+// Get implements the nosql.DB interface.
+func (db *instrumentedDB) Get(bucket, key []byte) ([]byte, error) {
+	start := time.Now()
+	ret, err := db.DB.Get(bucket, key)
+	db.observe("get", bucket, start, err)
+	return ret, err
+}
+
+// Set implements the nosql.DB interface.
+func (db *instrumentedDB) Set(bucket, key, value []byte) error {
+	start := time.Now()
+	err := db.DB.Set(bucket, key, value)
+	db.observe("set", bucket, start, err)
+	return err
+}
+
+// CmpAndSwap implements the nosql.DB interface.
+func (db *instrumentedDB) CmpAndSwap(bucket, key, oldValue, newValue []byte) ([]byte, bool, error) {
+	start := time.Now()
+	ret, swapped, err := db.DB.CmpAndSwap(bucket, key, oldValue, newValue)
+	db.observe("cmpAndSwap", bucket, start, err)
+	return ret, swapped, err
+}
+
+// Del implements the nosql.DB interface.
+func (db *instrumentedDB) Del(bucket, key []byte) error {
+	start := time.Now()
+	err := db.DB.Del(bucket, key)
+	db.observe("del", bucket, start, err)
+	return err
+}
+
+// List implements the nosql.DB interface.
+func (db *instrumentedDB) List(bucket []byte) ([]*database.Entry, error) {
+	start := time.Now()
+	entries, err := db.DB.List(bucket)
+	db.observe("list", bucket, start, err)
+	return entries, err
+}
+
+// Update implements the nosql.DB interface. Transactions are reported with
+// the table of the first operation.
+func (db *instrumentedDB) Update(tx *database.Tx) error {
+	var bucket []byte
+	if len(tx.Operations) > 0 {
+		bucket = tx.Operations[0].Bucket
+	}
+	start := time.Now()
+	err := db.DB.Update(tx)
+	db.observe("update", bucket, start, err)
+	return err
+}
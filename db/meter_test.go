@@ -0,0 +1,101 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smallstep/assert"
+	"github.com/smallstep/nosql/database"
+)
+
+type operation struct {
+	op, table string
+	err       error
+}
+
+type mockMeter struct {
+	ops []operation
+}
+
+func (m *mockMeter) DBOperation(op, table string, d time.Duration, err error) {
+	m.ops = append(m.ops, operation{op: op, table: table, err: err})
+}
+
+func TestInstrumentedDB(t *testing.T) {
+	errForce := errors.New("force")
+	type test struct {
+		db    *MockNoSQLDB
+		fn    func(db *instrumentedDB) error
+		expOp operation
+	}
+	tests := map[string]test{
+		"get": {
+			db: &MockNoSQLDB{Ret1: []byte("value")},
+			fn: func(db *instrumentedDB) error {
+				_, err := db.Get(certsTable, []byte("key"))
+				return err
+			},
+			expOp: operation{op: "get", table: "x509_certs"},
+		},
+		"get/not-found": {
+			db: &MockNoSQLDB{Err: database.ErrNotFound},
+			fn: func(db *instrumentedDB) error {
+				_, err := db.Get(certsTable, []byte("key"))
+				return err
+			},
+			expOp: operation{op: "get", table: "x509_certs"},
+		},
+		"set/error": {
+			db: &MockNoSQLDB{Err: errForce},
+			fn: func(db *instrumentedDB) error {
+				return db.Set(revokedCertsTable, []byte("key"), []byte("value"))
+			},
+			expOp: operation{op: "set", table: "revoked_x509_certs", err: errForce},
+		},
+		"cmpAndSwap": {
+			db: &MockNoSQLDB{Ret1: []byte("value"), Ret2: true},
+			fn: func(db *instrumentedDB) error {
+				_, _, err := db.CmpAndSwap(crlTable, []byte("key"), nil, []byte("value"))
+				return err
+			},
+			expOp: operation{op: "cmpAndSwap", table: "x509_crl"},
+		},
+		"del": {
+			db: &MockNoSQLDB{},
+			fn: func(db *instrumentedDB) error {
+				return db.Del(usedOTTTable, []byte("key"))
+			},
+			expOp: operation{op: "del", table: "used_ott"},
+		},
+		"list": {
+			db: &MockNoSQLDB{Ret1: []*database.Entry{}},
+			fn: func(db *instrumentedDB) error {
+				_, err := db.List(certsDataTable)
+				return err
+			},
+			expOp: operation{op: "list", table: "x509_certs_data"},
+		},
+		"update": {
+			db: &MockNoSQLDB{},
+			fn: func(db *instrumentedDB) error {
+				return db.Update(&database.Tx{Operations: []*database.TxEntry{
+					{Bucket: certsTable, Key: []byte("key"), Value: []byte("value"), Cmd: database.Set},
+					{Bucket: certsDataTable, Key: []byte("key"), Value: []byte("value"), Cmd: database.Set},
+				}})
+			},
+			expOp: operation{op: "update", table: "x509_certs"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			m := new(mockMeter)
+			db := &instrumentedDB{DB: tc.db, meter: m}
+			err := tc.fn(db)
+			if tc.expOp.err != nil {
+				assert.Equals(t, tc.expOp.err, err)
+			}
+			assert.Equals(t, []operation{tc.expOp}, m.ops)
+		})
+	}
+}
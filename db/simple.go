@@ -46,6 +46,31 @@ func (s *SimpleDB) RevokeSSH(rci *RevokedCertificateInfo) error {
 	return ErrNotImplemented
 }
 
+// GetRevokedCertificate returns a "NotImplemented" error.
+func (s *SimpleDB) GetRevokedCertificate(sn string) (*RevokedCertificateInfo, error) {
+	return nil, ErrNotImplemented
+}
+
+// GetRevokedCertificates returns a "NotImplemented" error.
+func (s *SimpleDB) GetRevokedCertificates() ([]RevokedCertificateInfo, error) {
+	return nil, ErrNotImplemented
+}
+
+// GetRevokedSSHCertificates returns a "NotImplemented" error.
+func (s *SimpleDB) GetRevokedSSHCertificates() ([]RevokedCertificateInfo, error) {
+	return nil, ErrNotImplemented
+}
+
+// GetCRL returns a "NotImplemented" error.
+func (s *SimpleDB) GetCRL() (*CertificateRevocationListInfo, error) {
+	return nil, ErrNotImplemented
+}
+
+// StoreCRL returns a "NotImplemented" error.
+func (s *SimpleDB) StoreCRL(crlInfo *CertificateRevocationListInfo) error {
+	return ErrNotImplemented
+}
+
 // GetCertificate returns a "NotImplemented" error.
 func (s *SimpleDB) GetCertificate(serialNumber string) (*x509.Certificate, error) {
 	return nil, ErrNotImplemented
@@ -0,0 +1,394 @@
+// Package est implements an Enrollment over Secure Transport (RFC 7030) HTTP
+// server.
+package est
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"go.mozilla.org/pkcs7"
+	"go.step.sm/crypto/x509util"
+
+	"github.com/smallstep/certificates/api"
+	"github.com/smallstep/certificates/api/render"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/errs"
+)
+
+const maxPayloadSize = 2 << 20
+
+// Authority is the interface implemented by the CA authority used by the EST
+// handler.
+type Authority interface {
+	LoadProvisionerByName(string) (provisioner.Interface, error)
+	SignWithContext(ctx context.Context, cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]*x509.Certificate, error)
+	GetRoots() ([]*x509.Certificate, error)
+	GetX509IssuerChain() ([]*x509.Certificate, error)
+	IsRevoked(sn string) (bool, error)
+}
+
+type provisionerKey struct{}
+
+// handler is the EST request handler.
+type handler struct {
+	auth Authority
+}
+
+// New returns a new EST API router.
+func New(auth Authority) api.RouterHandler {
+	return &handler{
+		auth: auth,
+	}
+}
+
+// Route traffic and implement the Router interface.
+func (h *handler) Route(r api.Router) {
+	r.MethodFunc(http.MethodGet, "/{provisionerName}/cacerts", h.lookupProvisioner(h.CACerts))
+	r.MethodFunc(http.MethodPost, "/{provisionerName}/simpleenroll", h.lookupProvisioner(h.SimpleEnroll))
+	r.MethodFunc(http.MethodPost, "/{provisionerName}/simplereenroll", h.lookupProvisioner(h.SimpleReenroll))
+	r.MethodFunc(http.MethodGet, "/{provisionerName}/csrattrs", h.lookupProvisioner(h.CSRAttrs))
+}
+
+// lookupProvisioner loads the provisioner associated with the request.
+// Responds 404 if the provisioner does not exist.
+func (h *handler) lookupProvisioner(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "provisionerName")
+		provisionerName, err := url.PathUnescape(name)
+		if err != nil {
+			render.Error(w, errs.BadRequestErr(err, "error url unescaping provisioner name '%s'", name))
+			return
+		}
+
+		p, err := h.auth.LoadProvisionerByName(provisionerName)
+		if err != nil {
+			render.Error(w, errs.NotFoundErr(err))
+			return
+		}
+
+		prov, ok := p.(*provisioner.EST)
+		if !ok {
+			render.Error(w, errs.NotFound("provisioner must be of type EST"))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), provisionerKey{}, prov)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+func provisionerFromContext(ctx context.Context) *provisioner.EST {
+	p, _ := ctx.Value(provisionerKey{}).(*provisioner.EST)
+	return p
+}
+
+// CACerts returns the certificate chain of the intermediate and the roots in
+// a certs-only PKCS#7; https://tools.ietf.org/html/rfc7030#section-4.1
+func (h *handler) CACerts(w http.ResponseWriter, r *http.Request) {
+	chain, err := h.auth.GetX509IssuerChain()
+	if err != nil {
+		render.Error(w, errs.InternalServerErr(err))
+		return
+	}
+	roots, err := h.auth.GetRoots()
+	if err != nil {
+		render.Error(w, errs.InternalServerErr(err))
+		return
+	}
+
+	certs := append([]*x509.Certificate{}, chain...)
+	certs = append(certs, roots...)
+	writeCertificates(w, certs)
+}
+
+// SimpleEnroll signs a CSR authenticated with a bootstrap client certificate
+// or with HTTP basic authentication;
+// https://tools.ietf.org/html/rfc7030#section-4.2
+func (h *handler) SimpleEnroll(w http.ResponseWriter, r *http.Request) {
+	p := provisionerFromContext(r.Context())
+
+	if err := authorizeEnroll(p, r); err != nil {
+		if p.Username != "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="est"`)
+		}
+		render.Error(w, err)
+		return
+	}
+
+	csr, err := readCSR(r)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	h.sign(w, r, p, csr)
+}
+
+// SimpleReenroll signs a CSR authenticated with the client certificate issued
+// by the CA. The CSR must have the same subject and subject alternative names
+// as the certificate; https://tools.ietf.org/html/rfc7030#section-4.2.2
+func (h *handler) SimpleReenroll(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	p := provisionerFromContext(ctx)
+
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		render.Error(w, errs.Unauthorized("missing client certificate"))
+		return
+	}
+	cert := r.TLS.PeerCertificates[0]
+	if err := h.authorizeReenroll(ctx, p, cert, r.TLS.PeerCertificates[1:]); err != nil {
+		render.Error(w, err)
+		return
+	}
+
+	csr, err := readCSR(r)
+	if err != nil {
+		render.Error(w, err)
+		return
+	}
+	if !sameNames(csr, cert) {
+		render.Error(w, errs.BadRequest("csr subject and subject alternative names must match the client certificate"))
+		return
+	}
+
+	h.sign(w, r, p, csr)
+}
+
+// CSRAttrs returns the attributes the clients should include in the CSR;
+// https://tools.ietf.org/html/rfc7030#section-4.5
+func (h *handler) CSRAttrs(w http.ResponseWriter, r *http.Request) {
+	p := provisionerFromContext(r.Context())
+
+	oids := p.GetCSRAttributes()
+	if len(oids) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	b, err := asn1.Marshal(oids)
+	if err != nil {
+		render.Error(w, errs.InternalServerErr(err))
+		return
+	}
+
+	writeBase64(w, "application/csrattrs", b)
+}
+
+func (h *handler) sign(w http.ResponseWriter, r *http.Request, p *provisioner.EST, csr *x509.CertificateRequest) {
+	// Template data
+	sans := []string{}
+	sans = append(sans, csr.DNSNames...)
+	sans = append(sans, csr.EmailAddresses...)
+	for _, v := range csr.IPAddresses {
+		sans = append(sans, v.String())
+	}
+	for _, v := range csr.URIs {
+		sans = append(sans, v.String())
+	}
+	if len(sans) == 0 {
+		sans = append(sans, csr.Subject.CommonName)
+	}
+	data := x509util.CreateTemplateData(csr.Subject.CommonName, sans)
+	data.SetCertificateRequest(csr)
+	data.SetSubject(x509util.Subject{
+		Country:            csr.Subject.Country,
+		Organization:       csr.Subject.Organization,
+		OrganizationalUnit: csr.Subject.OrganizationalUnit,
+		Locality:           csr.Subject.Locality,
+		Province:           csr.Subject.Province,
+		StreetAddress:      csr.Subject.StreetAddress,
+		PostalCode:         csr.Subject.PostalCode,
+		SerialNumber:       csr.Subject.SerialNumber,
+		CommonName:         csr.Subject.CommonName,
+	})
+
+	ctx := provisioner.NewContextWithMethod(r.Context(), provisioner.SignMethod)
+	signOps, err := p.AuthorizeSign(ctx, "")
+	if err != nil {
+		render.Error(w, errs.UnauthorizedErr(err))
+		return
+	}
+	templateOptions, err := provisioner.TemplateOptions(p.GetOptions(), data)
+	if err != nil {
+		render.Error(w, errs.InternalServerErr(err))
+		return
+	}
+	signOps = append(signOps, templateOptions)
+
+	certChain, err := h.auth.SignWithContext(ctx, csr, provisioner.SignOptions{}, signOps...)
+	if err != nil {
+		render.Error(w, errs.ForbiddenErr(err, "error signing certificate"))
+		return
+	}
+
+	api.LogCertificate(w, certChain[0])
+	writeCertificates(w, certChain[:1])
+}
+
+// authorizeEnroll authenticates an enrollment with the bootstrap client
+// certificate or with HTTP basic authentication.
+func authorizeEnroll(p *provisioner.EST, r *http.Request) error {
+	err := errs.Unauthorized("missing client certificate or basic authentication")
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		if err = p.AuthorizeBootstrap(r.TLS.PeerCertificates[0], r.TLS.PeerCertificates[1:]); err == nil {
+			return nil
+		}
+	}
+	if username, password, ok := r.BasicAuth(); ok {
+		return p.AuthorizeBasic(username, password)
+	}
+	return err
+}
+
+// authorizeReenroll verifies that the client certificate has been issued by
+// the CA, that it's not revoked, and that it can be renewed by the
+// provisioner.
+func (h *handler) authorizeReenroll(ctx context.Context, p *provisioner.EST, cert *x509.Certificate, intermediates []*x509.Certificate) error {
+	roots, err := h.auth.GetRoots()
+	if err != nil {
+		return errs.InternalServerErr(err)
+	}
+	chain, err := h.auth.GetX509IssuerChain()
+	if err != nil {
+		return errs.InternalServerErr(err)
+	}
+
+	rootPool := x509.NewCertPool()
+	for _, crt := range roots {
+		rootPool.AddCert(crt)
+	}
+	intermediatePool := x509.NewCertPool()
+	for _, crt := range chain {
+		intermediatePool.AddCert(crt)
+	}
+	for _, crt := range intermediates {
+		intermediatePool.AddCert(crt)
+	}
+
+	// The validity of the certificate is checked by the provisioner, that
+	// might allow the renewal of expired certificates.
+	verifyTime := time.Now()
+	if verifyTime.After(cert.NotAfter) {
+		verifyTime = cert.NotAfter
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         rootPool,
+		Intermediates: intermediatePool,
+		CurrentTime:   verifyTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return errs.Wrap(http.StatusUnauthorized, err, "error verifying client certificate")
+	}
+
+	isRevoked, err := h.auth.IsRevoked(cert.SerialNumber.String())
+	if err != nil {
+		return errs.InternalServerErr(err)
+	}
+	if isRevoked {
+		return errs.Unauthorized("client certificate has been revoked")
+	}
+
+	if err := p.AuthorizeRenew(ctx, cert); err != nil {
+		return errs.UnauthorizedErr(err)
+	}
+	return nil
+}
+
+// readCSR reads the base64 encoded PKCS#10 in the body of the request.
+func readCSR(r *http.Request) (*x509.CertificateRequest, error) {
+	defer r.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPayloadSize))
+	if err != nil {
+		return nil, errs.BadRequestErr(err, "error reading request body")
+	}
+	der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(body)), ""))
+	if err != nil {
+		return nil, errs.BadRequestErr(err, "error decoding csr")
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, errs.BadRequestErr(err, "error parsing csr")
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, errs.BadRequestErr(err, "invalid csr")
+	}
+	return csr, nil
+}
+
+// sameNames returns true if the CSR and the certificate have the same common
+// name and subject alternative names.
+func sameNames(csr *x509.CertificateRequest, cert *x509.Certificate) bool {
+	if csr.Subject.CommonName != cert.Subject.CommonName {
+		return false
+	}
+	return equalNames(csrNames(csr), certNames(cert))
+}
+
+func csrNames(csr *x509.CertificateRequest) []string {
+	names := append([]string{}, csr.DNSNames...)
+	names = append(names, csr.EmailAddresses...)
+	for _, ip := range csr.IPAddresses {
+		names = append(names, ip.String())
+	}
+	for _, u := range csr.URIs {
+		names = append(names, u.String())
+	}
+	return names
+}
+
+func certNames(cert *x509.Certificate) []string {
+	names := append([]string{}, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	for _, u := range cert.URIs {
+		names = append(names, u.String())
+	}
+	return names
+}
+
+func equalNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// writeCertificates writes the given certificates in a base64 encoded
+// certs-only PKCS#7.
+func writeCertificates(w http.ResponseWriter, certs []*x509.Certificate) {
+	var raw []byte
+	for _, crt := range certs {
+		raw = append(raw, crt.Raw...)
+	}
+	b, err := pkcs7.DegenerateCertificate(raw)
+	if err != nil {
+		render.Error(w, errs.InternalServerErr(err))
+		return
+	}
+	writeBase64(w, "application/pkcs7-mime; smime-type=certs-only", b)
+}
+
+func writeBase64(w http.ResponseWriter, contentType string, b []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Transfer-Encoding", "base64")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(base64.StdEncoding.EncodeToString(b)))
+}
@@ -0,0 +1,294 @@
+package est
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/smallstep/assert"
+	"go.mozilla.org/pkcs7"
+	"go.step.sm/crypto/minica"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+var (
+	defaultDisableRenewal   = false
+	globalProvisionerClaims = provisioner.Claims{
+		MinTLSDur:      &provisioner.Duration{Duration: 5 * time.Minute},
+		MaxTLSDur:      &provisioner.Duration{Duration: 24 * time.Hour},
+		DefaultTLSDur:  &provisioner.Duration{Duration: 24 * time.Hour},
+		DisableRenewal: &defaultDisableRenewal,
+	}
+)
+
+type mockAuthority struct {
+	MockLoadProvisionerByName func(name string) (provisioner.Interface, error)
+	MockSignWithContext       func(ctx context.Context, cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]*x509.Certificate, error)
+	MockGetRoots              func() ([]*x509.Certificate, error)
+	MockGetX509IssuerChain    func() ([]*x509.Certificate, error)
+	MockIsRevoked             func(sn string) (bool, error)
+}
+
+func (m *mockAuthority) LoadProvisionerByName(name string) (provisioner.Interface, error) {
+	return m.MockLoadProvisionerByName(name)
+}
+
+func (m *mockAuthority) SignWithContext(ctx context.Context, cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]*x509.Certificate, error) {
+	return m.MockSignWithContext(ctx, cr, opts, signOpts...)
+}
+
+func (m *mockAuthority) GetRoots() ([]*x509.Certificate, error) {
+	return m.MockGetRoots()
+}
+
+func (m *mockAuthority) GetX509IssuerChain() ([]*x509.Certificate, error) {
+	return m.MockGetX509IssuerChain()
+}
+
+func (m *mockAuthority) IsRevoked(sn string) (bool, error) {
+	return m.MockIsRevoked(sn)
+}
+
+func newESTProvisioner(t *testing.T, p *provisioner.EST) *provisioner.EST {
+	t.Helper()
+	p.Type = "EST"
+	p.Name = "est"
+	assert.FatalError(t, p.Init(provisioner.Config{Claims: globalProvisionerClaims}))
+	return p
+}
+
+func newCSR(t *testing.T, key crypto.Signer, commonName string, dnsNames ...string) string {
+	t.Helper()
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: commonName},
+		DNSNames: dnsNames,
+	}, key)
+	assert.FatalError(t, err)
+	return base64.StdEncoding.EncodeToString(der)
+}
+
+func newMockAuthority(t *testing.T, ca *minica.CA, p provisioner.Interface) *mockAuthority {
+	t.Helper()
+	return &mockAuthority{
+		MockLoadProvisionerByName: func(name string) (provisioner.Interface, error) {
+			if name != "est" {
+				return nil, errors.New("not found")
+			}
+			return p, nil
+		},
+		MockSignWithContext: func(ctx context.Context, cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]*x509.Certificate, error) {
+			crt, err := ca.Sign(&x509.Certificate{
+				SerialNumber: big.NewInt(time.Now().UnixNano()),
+				Subject:      cr.Subject,
+				DNSNames:     cr.DNSNames,
+				PublicKey:    cr.PublicKey,
+				ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			})
+			if err != nil {
+				return nil, err
+			}
+			return []*x509.Certificate{crt, ca.Intermediate}, nil
+		},
+		MockGetRoots: func() ([]*x509.Certificate, error) {
+			return []*x509.Certificate{ca.Root}, nil
+		},
+		MockGetX509IssuerChain: func() ([]*x509.Certificate, error) {
+			return []*x509.Certificate{ca.Intermediate}, nil
+		},
+		MockIsRevoked: func(sn string) (bool, error) {
+			return false, nil
+		},
+	}
+}
+
+func serve(t *testing.T, auth Authority, method, path string, body string, fn func(*http.Request)) *http.Response {
+	t.Helper()
+	r := chi.NewRouter()
+	New(auth).Route(r)
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if fn != nil {
+		fn(req)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Result()
+}
+
+func readCertificates(t *testing.T, res *http.Response) []*x509.Certificate {
+	t.Helper()
+	body, err := io.ReadAll(res.Body)
+	assert.FatalError(t, err)
+	res.Body.Close()
+	b, err := base64.StdEncoding.DecodeString(string(body))
+	assert.FatalError(t, err)
+	p7, err := pkcs7.Parse(b)
+	assert.FatalError(t, err)
+	return p7.Certificates
+}
+
+func TestHandler_CACerts(t *testing.T) {
+	ca, err := minica.New()
+	assert.FatalError(t, err)
+	p := newESTProvisioner(t, &provisioner.EST{Username: "user", Password: "password"})
+	auth := newMockAuthority(t, ca, p)
+
+	res := serve(t, auth, "GET", "/est/cacerts", "", nil)
+	assert.Equals(t, http.StatusOK, res.StatusCode)
+	assert.Equals(t, "application/pkcs7-mime; smime-type=certs-only", res.Header.Get("Content-Type"))
+	certs := readCertificates(t, res)
+	if assert.Len(t, 2, certs) {
+		assert.Equals(t, ca.Intermediate.Raw, certs[0].Raw)
+		assert.Equals(t, ca.Root.Raw, certs[1].Raw)
+	}
+
+	res = serve(t, auth, "GET", "/missing/cacerts", "", nil)
+	assert.Equals(t, http.StatusNotFound, res.StatusCode)
+
+	auth.MockLoadProvisionerByName = func(name string) (provisioner.Interface, error) {
+		return &provisioner.SCEP{Name: "est"}, nil
+	}
+	res = serve(t, auth, "GET", "/est/cacerts", "", nil)
+	assert.Equals(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestHandler_CSRAttrs(t *testing.T) {
+	ca, err := minica.New()
+	assert.FatalError(t, err)
+
+	p := newESTProvisioner(t, &provisioner.EST{Username: "user", Password: "password"})
+	res := serve(t, newMockAuthority(t, ca, p), "GET", "/est/csrattrs", "", nil)
+	assert.Equals(t, http.StatusNoContent, res.StatusCode)
+
+	oids := []asn1.ObjectIdentifier{{1, 2, 840, 113549, 1, 9, 7}, {2, 5, 4, 5}}
+	p = newESTProvisioner(t, &provisioner.EST{Username: "user", Password: "password", CSRAttributes: oids})
+	res = serve(t, newMockAuthority(t, ca, p), "GET", "/est/csrattrs", "", nil)
+	assert.Equals(t, http.StatusOK, res.StatusCode)
+	assert.Equals(t, "application/csrattrs", res.Header.Get("Content-Type"))
+	body, err := io.ReadAll(res.Body)
+	assert.FatalError(t, err)
+	want, err := asn1.Marshal(oids)
+	assert.FatalError(t, err)
+	assert.Equals(t, base64.StdEncoding.EncodeToString(want), string(body))
+}
+
+func TestHandler_SimpleEnroll(t *testing.T) {
+	ca, err := minica.New()
+	assert.FatalError(t, err)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+	csr := newCSR(t, key, "device-1", "device-1.example.com")
+
+	p := newESTProvisioner(t, &provisioner.EST{Username: "user", Password: "password"})
+	auth := newMockAuthority(t, ca, p)
+
+	tests := []struct {
+		name       string
+		body       string
+		fn         func(*http.Request)
+		statusCode int
+	}{
+		{"ok", csr, func(r *http.Request) { r.SetBasicAuth("user", "password") }, http.StatusOK},
+		{"ok with line breaks", csr[:64] + "\r\n" + csr[64:], func(r *http.Request) { r.SetBasicAuth("user", "password") }, http.StatusOK},
+		{"fail no auth", csr, nil, http.StatusUnauthorized},
+		{"fail bad password", csr, func(r *http.Request) { r.SetBasicAuth("user", "foo") }, http.StatusUnauthorized},
+		{"fail base64", "!?", func(r *http.Request) { r.SetBasicAuth("user", "password") }, http.StatusBadRequest},
+		{"fail csr", base64.StdEncoding.EncodeToString([]byte("foo")), func(r *http.Request) { r.SetBasicAuth("user", "password") }, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := serve(t, auth, "POST", "/est/simpleenroll", tt.body, tt.fn)
+			assert.Equals(t, tt.statusCode, res.StatusCode)
+			switch tt.statusCode {
+			case http.StatusOK:
+				certs := readCertificates(t, res)
+				if assert.Len(t, 1, certs) {
+					assert.Equals(t, "device-1", certs[0].Subject.CommonName)
+				}
+			case http.StatusUnauthorized:
+				assert.Equals(t, `Basic realm="est"`, res.Header.Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestHandler_SimpleReenroll(t *testing.T) {
+	ca, err := minica.New()
+	assert.FatalError(t, err)
+	other, err := minica.New()
+	assert.FatalError(t, err)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+
+	p := newESTProvisioner(t, &provisioner.EST{Username: "user", Password: "password"})
+	auth := newMockAuthority(t, ca, p)
+
+	sign := func(ca *minica.CA) *x509.Certificate {
+		crt, err := ca.Sign(&x509.Certificate{
+			SerialNumber: big.NewInt(1234),
+			Subject:      pkix.Name{CommonName: "device-1"},
+			DNSNames:     []string{"device-1.example.com"},
+			PublicKey:    key.Public(),
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		})
+		assert.FatalError(t, err)
+		return crt
+	}
+	cert := sign(ca)
+	withCert := func(certs ...*x509.Certificate) func(*http.Request) {
+		return func(r *http.Request) {
+			r.TLS = &tls.ConnectionState{PeerCertificates: certs}
+		}
+	}
+
+	newKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.FatalError(t, err)
+
+	tests := []struct {
+		name       string
+		body       string
+		fn         func(*http.Request)
+		isRevoked  bool
+		statusCode int
+	}{
+		{"ok", newCSR(t, newKey, "device-1", "device-1.example.com"), withCert(cert), false, http.StatusOK},
+		{"fail no certificate", newCSR(t, newKey, "device-1", "device-1.example.com"), nil, false, http.StatusUnauthorized},
+		{"fail basic auth", newCSR(t, newKey, "device-1", "device-1.example.com"), func(r *http.Request) { r.SetBasicAuth("user", "password") }, false, http.StatusUnauthorized},
+		{"fail other ca", newCSR(t, newKey, "device-1", "device-1.example.com"), withCert(sign(other), other.Intermediate), false, http.StatusUnauthorized},
+		{"fail revoked", newCSR(t, newKey, "device-1", "device-1.example.com"), withCert(cert), true, http.StatusUnauthorized},
+		{"fail common name", newCSR(t, newKey, "device-2", "device-1.example.com"), withCert(cert), false, http.StatusBadRequest},
+		{"fail sans", newCSR(t, newKey, "device-1", "device-2.example.com"), withCert(cert), false, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth.MockIsRevoked = func(sn string) (bool, error) {
+				assert.Equals(t, "1234", sn)
+				return tt.isRevoked, nil
+			}
+			res := serve(t, auth, "POST", "/est/simplereenroll", tt.body, tt.fn)
+			assert.Equals(t, tt.statusCode, res.StatusCode)
+			if tt.statusCode == http.StatusOK {
+				certs := readCertificates(t, res)
+				if assert.Len(t, 1, certs) {
+					assert.True(t, newKey.PublicKey.Equal(certs[0].PublicKey))
+				}
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package apiv1
 import (
 	"crypto"
 	"crypto/x509"
+	"encoding/json"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -29,6 +30,25 @@ type CertificateManager interface {
 	StoreCertificate(req *StoreCertificateRequest) error
 }
 
+// Attester is the interface implemented by the KMS that can create an
+// attestation proving that a key was generated in the device and cannot be
+// exported.
+type Attester interface {
+	CreateAttestation(req *CreateAttestationRequest) (*CreateAttestationResponse, error)
+}
+
+// KeyLister is the interface implemented by the KMS that can list the keys
+// under a given name, like a directory, a key ring or a vault.
+type KeyLister interface {
+	ListKeys(req *ListKeysRequest) (*ListKeysResponse, error)
+}
+
+// KeyDeleter is the interface implemented by the KMS that can delete or
+// schedule the deletion of a key.
+type KeyDeleter interface {
+	DeleteKey(req *DeleteKeyRequest) error
+}
+
 // ValidateName is an interface that KeyManager can implement to validate a
 // given name or URI.
 type NameValidator interface {
@@ -81,6 +101,10 @@ const (
 	SSHAgentKMS Type = "sshagentkms"
 	// AzureKMS is a KMS implementation using Azure Key Vault.
 	AzureKMS Type = "azurekms"
+	// TPMKMS is a KMS implementation using a TPM 2.0.
+	TPMKMS Type = "tpmkms"
+	// VaultKMS is a KMS implementation using HashiCorp Vault Transit.
+	VaultKMS Type = "vaultkms"
 )
 
 // Options are the KMS options. They represent the kms object in the ca.json.
@@ -95,7 +119,7 @@ type Options struct {
 	// https://tools.ietf.org/html/rfc7512 and represents the configuration used
 	// to connect to the KMS.
 	//
-	// Used by: pkcs11
+	// Used by: pkcs11, tpmkms
 	URI string `json:"uri,omitempty"`
 
 	// Pin used to access the PKCS11 module. It can be defined in the URI using
@@ -116,6 +140,12 @@ type Options struct {
 
 	// Profile to use in AmazonKMS.
 	Profile string `json:"profile,omitempty"`
+
+	// Config is a generic structure used to configure a KMS with options that
+	// cannot be defined in the URI.
+	//
+	// Used by: vaultkms
+	Config json.RawMessage `json:"config,omitempty"`
 }
 
 // Validate checks the fields in Options.
@@ -126,8 +156,8 @@ func (o *Options) Validate() error {
 
 	switch Type(strings.ToLower(o.Type)) {
 	case DefaultKMS, SoftKMS: // Go crypto based kms.
-	case CloudKMS, AmazonKMS, AzureKMS: // Cloud based kms.
-	case YubiKey, PKCS11: // Hardware based kms.
+	case CloudKMS, AmazonKMS, AzureKMS, VaultKMS: // Cloud based kms.
+	case YubiKey, PKCS11, TPMKMS: // Hardware based kms.
 	case SSHAgentKMS: // Others
 	default:
 		return errors.Errorf("unsupported kms type %s", o.Type)
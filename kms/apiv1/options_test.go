@@ -16,6 +16,8 @@ func TestOptions_Validate(t *testing.T) {
 		{"awskms", &Options{Type: "awskms"}, false},
 		{"sshagentkms", &Options{Type: "sshagentkms"}, false},
 		{"pkcs11", &Options{Type: "pkcs11"}, false},
+		{"tpmkms", &Options{Type: "tpmkms"}, false},
+		{"vaultkms", &Options{Type: "vaultkms"}, false},
 		{"unsupported", &Options{Type: "unsupported"}, true},
 	}
 	for _, tt := range tests {
@@ -126,6 +126,14 @@ type CreateKeyResponse struct {
 	PublicKey           crypto.PublicKey
 	PrivateKey          crypto.PrivateKey
 	CreateSignerRequest CreateSignerRequest
+
+	// AttestationCertificate is the certificate that attests that the key was
+	// generated in the device, and AttestationChain is the chain of the
+	// attestation certificate, without the root of the manufacturer.
+	//
+	// Used by: yubikey
+	AttestationCertificate *x509.Certificate
+	AttestationChain       []*x509.Certificate
 }
 
 // CreateSignerRequest is the parameter used in the kms.CreateSigner method.
@@ -147,6 +155,39 @@ type CreateDecrypterRequest struct {
 	Password         []byte
 }
 
+// CreateAttestationRequest is the parameter used in the kms.CreateAttestation
+// method.
+type CreateAttestationRequest struct {
+	Name string
+}
+
+// CreateAttestationResponse is the response value of the kms.CreateAttestation
+// method. The Certificate attests the PublicKey, and the CertificateChain
+// contains the intermediates up to the root of the manufacturer.
+type CreateAttestationResponse struct {
+	Certificate      *x509.Certificate
+	CertificateChain []*x509.Certificate
+	PublicKey        crypto.PublicKey
+}
+
+// ListKeysRequest is the parameter used in the kms.ListKeys method. The Name is
+// the scope where the keys are listed, its format depends on the KMS.
+type ListKeysRequest struct {
+	Name string
+}
+
+// ListKeysResponse is the response value of the kms.ListKeys method. The Keys
+// are names that can be used in the kms.GetPublicKey and kms.CreateSigner
+// methods.
+type ListKeysResponse struct {
+	Keys []string
+}
+
+// DeleteKeyRequest is the parameter used in the kms.DeleteKey method.
+type DeleteKeyRequest struct {
+	Name string
+}
+
 // LoadCertificateRequest is the parameter used in the LoadCertificate method of
 // a CertificateManager.
 type LoadCertificateRequest struct {
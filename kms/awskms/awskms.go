@@ -33,6 +33,9 @@ type KeyManagementClient interface {
 	CreateKeyWithContext(ctx aws.Context, input *kms.CreateKeyInput, opts ...request.Option) (*kms.CreateKeyOutput, error)
 	CreateAliasWithContext(ctx aws.Context, input *kms.CreateAliasInput, opts ...request.Option) (*kms.CreateAliasOutput, error)
 	SignWithContext(ctx aws.Context, input *kms.SignInput, opts ...request.Option) (*kms.SignOutput, error)
+	ListKeysPagesWithContext(ctx aws.Context, input *kms.ListKeysInput, fn func(*kms.ListKeysOutput, bool) bool, opts ...request.Option) error
+	DescribeKeyWithContext(ctx aws.Context, input *kms.DescribeKeyInput, opts ...request.Option) (*kms.DescribeKeyOutput, error)
+	ScheduleKeyDeletionWithContext(ctx aws.Context, input *kms.ScheduleKeyDeletionInput, opts ...request.Option) (*kms.ScheduleKeyDeletionOutput, error)
 }
 
 // customerMasterKeySpecMapping is a mapping between the step signature algorithm,
@@ -221,6 +224,82 @@ func (k *KMS) CreateSigner(req *apiv1.CreateSignerRequest) (crypto.Signer, error
 	return NewSigner(k.service, req.SigningKey)
 }
 
+// ListKeys returns the enabled asymmetric signing keys in the account and region
+// configured in the KMS. The name in the request is not used.
+func (k *KMS) ListKeys(req *apiv1.ListKeysRequest) (*apiv1.ListKeysResponse, error) {
+	ctx, cancel := defaultContext()
+	defer cancel()
+
+	var keyIDs []string
+	if err := k.service.ListKeysPagesWithContext(ctx, &kms.ListKeysInput{}, func(page *kms.ListKeysOutput, lastPage bool) bool {
+		for _, key := range page.Keys {
+			keyIDs = append(keyIDs, aws.StringValue(key.KeyId))
+		}
+		return true
+	}); err != nil {
+		return nil, errors.Wrap(err, "awskms ListKeysPagesWithContext failed")
+	}
+
+	keys := []string{}
+	for _, keyID := range keyIDs {
+		ok, err := k.isSigningKey(keyID)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			keys = append(keys, uri.New("awskms", url.Values{
+				"key-id": []string{keyID},
+			}).String())
+		}
+	}
+
+	return &apiv1.ListKeysResponse{
+		Keys: keys,
+	}, nil
+}
+
+// isSigningKey returns true if the given key is an enabled asymmetric key used
+// for signing.
+func (k *KMS) isSigningKey(keyID string) (bool, error) {
+	ctx, cancel := defaultContext()
+	defer cancel()
+
+	resp, err := k.service.DescribeKeyWithContext(ctx, &kms.DescribeKeyInput{
+		KeyId: &keyID,
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "awskms DescribeKeyWithContext failed")
+	}
+	md := resp.KeyMetadata
+	return md != nil &&
+		aws.StringValue(md.KeyUsage) == kms.KeyUsageTypeSignVerify &&
+		aws.StringValue(md.KeyState) == kms.KeyStateEnabled, nil
+}
+
+// DeleteKey schedules the deletion of the given key. AWS KMS deletes the key
+// after a waiting period of 30 days, and the deletion can be canceled until
+// then.
+func (k *KMS) DeleteKey(req *apiv1.DeleteKeyRequest) error {
+	if req.Name == "" {
+		return errors.New("deleteKeyRequest 'name' cannot be empty")
+	}
+
+	keyID, err := parseKeyID(req.Name)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := defaultContext()
+	defer cancel()
+
+	if _, err := k.service.ScheduleKeyDeletionWithContext(ctx, &kms.ScheduleKeyDeletionInput{
+		KeyId: &keyID,
+	}); err != nil {
+		return errors.Wrap(err, "awskms ScheduleKeyDeletionWithContext failed")
+	}
+	return nil
+}
+
 // Close closes the connection of the KMS client.
 func (k *KMS) Close() error {
 	return nil
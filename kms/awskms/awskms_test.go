@@ -307,6 +307,130 @@ func TestKMS_CreateSigner(t *testing.T) {
 	}
 }
 
+func TestKMS_ListKeys(t *testing.T) {
+	listKeys := func(ids ...string) func(aws.Context, *kms.ListKeysInput, func(*kms.ListKeysOutput, bool) bool, ...request.Option) error {
+		return func(_ aws.Context, _ *kms.ListKeysInput, fn func(*kms.ListKeysOutput, bool) bool, _ ...request.Option) error {
+			// Return one key per page.
+			for i, id := range ids {
+				page := &kms.ListKeysOutput{
+					Keys: []*kms.KeyListEntry{{KeyId: aws.String(id)}},
+				}
+				if !fn(page, i == len(ids)-1) {
+					break
+				}
+			}
+			return nil
+		}
+	}
+	describeKey := func(_ aws.Context, input *kms.DescribeKeyInput, _ ...request.Option) (*kms.DescribeKeyOutput, error) {
+		md := &kms.KeyMetadata{
+			KeyId:    input.KeyId,
+			KeyUsage: aws.String(kms.KeyUsageTypeSignVerify),
+			KeyState: aws.String(kms.KeyStateEnabled),
+		}
+		switch *input.KeyId {
+		case "encrypt":
+			md.KeyUsage = aws.String(kms.KeyUsageTypeEncryptDecrypt)
+		case "disabled":
+			md.KeyState = aws.String(kms.KeyStateDisabled)
+		case "fail":
+			return nil, fmt.Errorf("an error")
+		}
+		return &kms.DescribeKeyOutput{KeyMetadata: md}, nil
+	}
+
+	type fields struct {
+		service KeyManagementClient
+	}
+	type args struct {
+		req *apiv1.ListKeysRequest
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		want    *apiv1.ListKeysResponse
+		wantErr bool
+	}{
+		{"ok", fields{&MockClient{
+			listKeysPagesWithContext: listKeys(keyID, "encrypt", "disabled", "other"),
+			describeKeyWithContext:   describeKey,
+		}}, args{&apiv1.ListKeysRequest{}}, &apiv1.ListKeysResponse{
+			Keys: []string{"awskms:key-id=" + keyID, "awskms:key-id=other"},
+		}, false},
+		{"ok empty", fields{&MockClient{
+			listKeysPagesWithContext: listKeys(),
+			describeKeyWithContext:   describeKey,
+		}}, args{&apiv1.ListKeysRequest{}}, &apiv1.ListKeysResponse{
+			Keys: []string{},
+		}, false},
+		{"fail list", fields{&MockClient{
+			listKeysPagesWithContext: func(aws.Context, *kms.ListKeysInput, func(*kms.ListKeysOutput, bool) bool, ...request.Option) error {
+				return fmt.Errorf("an error")
+			},
+		}}, args{&apiv1.ListKeysRequest{}}, nil, true},
+		{"fail describe", fields{&MockClient{
+			listKeysPagesWithContext: listKeys(keyID, "fail"),
+			describeKeyWithContext:   describeKey,
+		}}, args{&apiv1.ListKeysRequest{}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &KMS{
+				service: tt.fields.service,
+			}
+			got, err := k.ListKeys(tt.args.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("KMS.ListKeys() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("KMS.ListKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKMS_DeleteKey(t *testing.T) {
+	client := &MockClient{
+		scheduleKeyDeletionWithContext: func(_ aws.Context, input *kms.ScheduleKeyDeletionInput, _ ...request.Option) (*kms.ScheduleKeyDeletionOutput, error) {
+			if *input.KeyId != keyID {
+				return nil, fmt.Errorf("an error")
+			}
+			return &kms.ScheduleKeyDeletionOutput{KeyId: input.KeyId}, nil
+		},
+	}
+
+	type fields struct {
+		service KeyManagementClient
+	}
+	type args struct {
+		req *apiv1.DeleteKeyRequest
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		{"ok", fields{client}, args{&apiv1.DeleteKeyRequest{Name: "awskms:key-id=" + keyID}}, false},
+		{"ok without uri", fields{client}, args{&apiv1.DeleteKeyRequest{Name: keyID}}, false},
+		{"fail empty", fields{client}, args{&apiv1.DeleteKeyRequest{}}, true},
+		{"fail parse", fields{client}, args{&apiv1.DeleteKeyRequest{Name: "awskms:key-id="}}, true},
+		{"fail schedule", fields{client}, args{&apiv1.DeleteKeyRequest{Name: "awskms:key-id=missing"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &KMS{
+				service: tt.fields.service,
+			}
+			if err := k.DeleteKey(tt.args.req); (err != nil) != tt.wantErr {
+				t.Errorf("KMS.DeleteKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestKMS_Close(t *testing.T) {
 	type fields struct {
 		session *session.Session
@@ -9,10 +9,13 @@ import (
 )
 
 type MockClient struct {
-	getPublicKeyWithContext func(ctx aws.Context, input *kms.GetPublicKeyInput, opts ...request.Option) (*kms.GetPublicKeyOutput, error)
-	createKeyWithContext    func(ctx aws.Context, input *kms.CreateKeyInput, opts ...request.Option) (*kms.CreateKeyOutput, error)
-	createAliasWithContext  func(ctx aws.Context, input *kms.CreateAliasInput, opts ...request.Option) (*kms.CreateAliasOutput, error)
-	signWithContext         func(ctx aws.Context, input *kms.SignInput, opts ...request.Option) (*kms.SignOutput, error)
+	getPublicKeyWithContext        func(ctx aws.Context, input *kms.GetPublicKeyInput, opts ...request.Option) (*kms.GetPublicKeyOutput, error)
+	createKeyWithContext           func(ctx aws.Context, input *kms.CreateKeyInput, opts ...request.Option) (*kms.CreateKeyOutput, error)
+	createAliasWithContext         func(ctx aws.Context, input *kms.CreateAliasInput, opts ...request.Option) (*kms.CreateAliasOutput, error)
+	signWithContext                func(ctx aws.Context, input *kms.SignInput, opts ...request.Option) (*kms.SignOutput, error)
+	listKeysPagesWithContext       func(ctx aws.Context, input *kms.ListKeysInput, fn func(*kms.ListKeysOutput, bool) bool, opts ...request.Option) error
+	describeKeyWithContext         func(ctx aws.Context, input *kms.DescribeKeyInput, opts ...request.Option) (*kms.DescribeKeyOutput, error)
+	scheduleKeyDeletionWithContext func(ctx aws.Context, input *kms.ScheduleKeyDeletionInput, opts ...request.Option) (*kms.ScheduleKeyDeletionOutput, error)
 }
 
 func (m *MockClient) GetPublicKeyWithContext(ctx aws.Context, input *kms.GetPublicKeyInput, opts ...request.Option) (*kms.GetPublicKeyOutput, error) {
@@ -31,6 +34,18 @@ func (m *MockClient) SignWithContext(ctx aws.Context, input *kms.SignInput, opts
 	return m.signWithContext(ctx, input, opts...)
 }
 
+func (m *MockClient) ListKeysPagesWithContext(ctx aws.Context, input *kms.ListKeysInput, fn func(*kms.ListKeysOutput, bool) bool, opts ...request.Option) error {
+	return m.listKeysPagesWithContext(ctx, input, fn, opts...)
+}
+
+func (m *MockClient) DescribeKeyWithContext(ctx aws.Context, input *kms.DescribeKeyInput, opts ...request.Option) (*kms.DescribeKeyOutput, error) {
+	return m.describeKeyWithContext(ctx, input, opts...)
+}
+
+func (m *MockClient) ScheduleKeyDeletionWithContext(ctx aws.Context, input *kms.ScheduleKeyDeletionInput, opts ...request.Option) (*kms.ScheduleKeyDeletionOutput, error) {
+	return m.scheduleKeyDeletionWithContext(ctx, input, opts...)
+}
+
 const (
 	publicKey = `-----BEGIN PUBLIC KEY-----
 MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAE8XWlIWkOThxNjGbZLYUgRHmsvCrW
@@ -49,6 +49,21 @@ func (mr *KeyVaultClientMockRecorder) CreateKey(arg0, arg1, arg2, arg3 interface
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateKey", reflect.TypeOf((*KeyVaultClient)(nil).CreateKey), arg0, arg1, arg2, arg3)
 }
 
+// DeleteKey mocks base method
+func (m *KeyVaultClient) DeleteKey(arg0 context.Context, arg1, arg2 string) (keyvault.DeletedKeyBundle, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteKey", arg0, arg1, arg2)
+	ret0, _ := ret[0].(keyvault.DeletedKeyBundle)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteKey indicates an expected call of DeleteKey
+func (mr *KeyVaultClientMockRecorder) DeleteKey(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteKey", reflect.TypeOf((*KeyVaultClient)(nil).DeleteKey), arg0, arg1, arg2)
+}
+
 // GetKey mocks base method
 func (m *KeyVaultClient) GetKey(arg0 context.Context, arg1, arg2, arg3 string) (keyvault.KeyBundle, error) {
 	m.ctrl.T.Helper()
@@ -64,6 +79,21 @@ func (mr *KeyVaultClientMockRecorder) GetKey(arg0, arg1, arg2, arg3 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetKey", reflect.TypeOf((*KeyVaultClient)(nil).GetKey), arg0, arg1, arg2, arg3)
 }
 
+// GetKeys mocks base method
+func (m *KeyVaultClient) GetKeys(arg0 context.Context, arg1 string, arg2 *int32) (keyvault.KeyListResultPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetKeys", arg0, arg1, arg2)
+	ret0, _ := ret[0].(keyvault.KeyListResultPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetKeys indicates an expected call of GetKeys
+func (mr *KeyVaultClientMockRecorder) GetKeys(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetKeys", reflect.TypeOf((*KeyVaultClient)(nil).GetKeys), arg0, arg1, arg2)
+}
+
 // Sign mocks base method
 func (m *KeyVaultClient) Sign(arg0 context.Context, arg1, arg2, arg3 string, arg4 keyvault.KeySignParameters) (keyvault.KeyOperationResult, error) {
 	m.ctrl.T.Helper()
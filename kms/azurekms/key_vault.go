@@ -3,6 +3,7 @@ package azurekms
 import (
 	"context"
 	"crypto"
+	"net/url"
 	"regexp"
 	"time"
 
@@ -28,6 +29,10 @@ const Scheme = "azurekms"
 // extract the vault, name and version of the key.
 var keyIDRegexp = regexp.MustCompile(`^https://([0-9a-zA-Z-]+)\.vault\.azure\.net/keys/([0-9a-zA-Z-]+)/([0-9a-zA-Z-]+)$`)
 
+// keyItemIDRegexp is the regular expression that Key Vault uses on the kid of
+// the listed keys. We can extract the vault and name of the key.
+var keyItemIDRegexp = regexp.MustCompile(`^https://([0-9a-zA-Z-]+)\.vault\.azure\.net/keys/([0-9a-zA-Z-]+)$`)
+
 var (
 	valueTrue       = true
 	value2048 int32 = 2048
@@ -107,6 +112,8 @@ type KeyVaultClient interface {
 	GetKey(ctx context.Context, vaultBaseURL string, keyName string, keyVersion string) (keyvault.KeyBundle, error)
 	CreateKey(ctx context.Context, vaultBaseURL string, keyName string, parameters keyvault.KeyCreateParameters) (keyvault.KeyBundle, error)
 	Sign(ctx context.Context, vaultBaseURL string, keyName string, keyVersion string, parameters keyvault.KeySignParameters) (keyvault.KeyOperationResult, error)
+	GetKeys(ctx context.Context, vaultBaseURL string, maxresults *int32) (keyvault.KeyListResultPage, error)
+	DeleteKey(ctx context.Context, vaultBaseURL string, keyName string) (keyvault.DeletedKeyBundle, error)
 }
 
 // KeyVault implements a KMS using Azure Key Vault.
@@ -330,6 +337,86 @@ func (k *KeyVault) CreateSigner(req *apiv1.CreateSignerRequest) (crypto.Signer,
 	return NewSigner(k.baseClient, req.SigningKey, k.defaults)
 }
 
+// ListKeys returns the enabled keys in the vault passed in the request name, or
+// in the default vault if the name is empty. The name is an uri like:
+//
+//   - azurekms:vault=key-vault
+//
+// Keys managed by Key Vault, like the ones backing a certificate, are not
+// returned.
+func (k *KeyVault) ListKeys(req *apiv1.ListKeysRequest) (*apiv1.ListKeysResponse, error) {
+	vault := k.defaults.Vault
+	if req.Name != "" {
+		u, err := uri.ParseWithScheme(Scheme, req.Name)
+		if err != nil {
+			return nil, err
+		}
+		if v := u.Get("vault"); v != "" {
+			vault = v
+		}
+	}
+	if vault == "" {
+		return nil, errors.New("listKeysRequest 'name' cannot be empty")
+	}
+
+	ctx, cancel := defaultContext()
+	defer cancel()
+
+	page, err := k.baseClient.GetKeys(ctx, vaultBaseURL(vault), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "keyVault GetKeys failed")
+	}
+
+	keys := []string{}
+	for page.NotDone() {
+		for _, item := range page.Values() {
+			if item.Kid == nil || (item.Managed != nil && *item.Managed) {
+				continue
+			}
+			if item.Attributes != nil && item.Attributes.Enabled != nil && !*item.Attributes.Enabled {
+				continue
+			}
+			sm := keyItemIDRegexp.FindStringSubmatch(*item.Kid)
+			if len(sm) != 3 {
+				continue
+			}
+			keys = append(keys, uri.New(Scheme, url.Values{
+				"vault": []string{sm[1]},
+				"name":  []string{sm[2]},
+			}).String())
+		}
+		if err := page.NextWithContext(ctx); err != nil {
+			return nil, errors.Wrap(err, "keyVault GetKeys failed")
+		}
+	}
+
+	return &apiv1.ListKeysResponse{
+		Keys: keys,
+	}, nil
+}
+
+// DeleteKey deletes all the versions of a key in Azure Key Vault. If soft
+// delete is enabled in the vault, the key can be recovered during the
+// retention period.
+func (k *KeyVault) DeleteKey(req *apiv1.DeleteKeyRequest) error {
+	if req.Name == "" {
+		return errors.New("deleteKeyRequest 'name' cannot be empty")
+	}
+
+	vault, name, _, _, err := parseKeyName(req.Name, k.defaults)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := defaultContext()
+	defer cancel()
+
+	if _, err := k.baseClient.DeleteKey(ctx, vaultBaseURL(vault), name); err != nil {
+		return errors.Wrap(err, "keyVault DeleteKey failed")
+	}
+	return nil
+}
+
 // Close closes the client connection to the Azure Key Vault. This is a noop.
 func (k *KeyVault) Close() error {
 	return nil
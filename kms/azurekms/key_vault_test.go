@@ -566,6 +566,131 @@ func TestKeyVault_CreateSigner(t *testing.T) {
 	}
 }
 
+func TestKeyVault_ListKeys(t *testing.T) {
+	valueFalse := false
+	kid := func(s string) *string {
+		s = "https://my-vault.vault.azure.net/keys/" + s
+		return &s
+	}
+	firstPage := keyvault.KeyListResult{
+		Value: &[]keyvault.KeyItem{
+			{Kid: kid("my-key")},
+			{Kid: kid("managed-key"), Managed: &valueTrue},
+			{Kid: kid("disabled-key"), Attributes: &keyvault.KeyAttributes{Enabled: &valueFalse}},
+		},
+	}
+	secondPage := keyvault.KeyListResult{
+		Value: &[]keyvault.KeyItem{
+			{Kid: kid("other-key"), Attributes: &keyvault.KeyAttributes{Enabled: &valueTrue}},
+		},
+	}
+	nextPage := func(_ context.Context, cur keyvault.KeyListResult) (keyvault.KeyListResult, error) {
+		if cur.Value == firstPage.Value {
+			return secondPage, nil
+		}
+		return keyvault.KeyListResult{}, nil
+	}
+
+	client := mockClient(t)
+	client.EXPECT().GetKeys(gomock.Any(), "https://my-vault.vault.azure.net/", nil).Return(keyvault.NewKeyListResultPage(firstPage, nextPage), nil).Times(2)
+	client.EXPECT().GetKeys(gomock.Any(), "https://not-found.vault.azure.net/", nil).Return(keyvault.KeyListResultPage{}, errTest)
+	client.EXPECT().GetKeys(gomock.Any(), "https://fail-next.vault.azure.net/", nil).Return(keyvault.NewKeyListResultPage(firstPage, func(context.Context, keyvault.KeyListResult) (keyvault.KeyListResult, error) {
+		return keyvault.KeyListResult{}, errTest
+	}), nil)
+
+	type fields struct {
+		baseClient KeyVaultClient
+		defaults   DefaultOptions
+	}
+	type args struct {
+		req *apiv1.ListKeysRequest
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		want    *apiv1.ListKeysResponse
+		wantErr bool
+	}{
+		{"ok", fields{client, DefaultOptions{}}, args{&apiv1.ListKeysRequest{
+			Name: "azurekms:vault=my-vault",
+		}}, &apiv1.ListKeysResponse{Keys: []string{
+			"azurekms:name=my-key;vault=my-vault",
+			"azurekms:name=other-key;vault=my-vault",
+		}}, false},
+		{"ok default vault", fields{client, DefaultOptions{Vault: "my-vault"}}, args{&apiv1.ListKeysRequest{}}, &apiv1.ListKeysResponse{Keys: []string{
+			"azurekms:name=my-key;vault=my-vault",
+			"azurekms:name=other-key;vault=my-vault",
+		}}, false},
+		{"fail empty", fields{client, DefaultOptions{}}, args{&apiv1.ListKeysRequest{}}, nil, true},
+		{"fail scheme", fields{client, DefaultOptions{}}, args{&apiv1.ListKeysRequest{
+			Name: "awskms:vault=my-vault",
+		}}, nil, true},
+		{"fail GetKeys", fields{client, DefaultOptions{}}, args{&apiv1.ListKeysRequest{
+			Name: "azurekms:vault=not-found",
+		}}, nil, true},
+		{"fail next page", fields{client, DefaultOptions{}}, args{&apiv1.ListKeysRequest{
+			Name: "azurekms:vault=fail-next",
+		}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &KeyVault{
+				baseClient: tt.fields.baseClient,
+				defaults:   tt.fields.defaults,
+			}
+			got, err := k.ListKeys(tt.args.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("KeyVault.ListKeys() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("KeyVault.ListKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyVault_DeleteKey(t *testing.T) {
+	client := mockClient(t)
+	client.EXPECT().DeleteKey(gomock.Any(), "https://my-vault.vault.azure.net/", "my-key").Return(keyvault.DeletedKeyBundle{}, nil)
+	client.EXPECT().DeleteKey(gomock.Any(), "https://my-vault.vault.azure.net/", "not-found").Return(keyvault.DeletedKeyBundle{}, errTest)
+
+	type fields struct {
+		baseClient KeyVaultClient
+	}
+	type args struct {
+		req *apiv1.DeleteKeyRequest
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		{"ok", fields{client}, args{&apiv1.DeleteKeyRequest{
+			Name: "azurekms:vault=my-vault;name=my-key",
+		}}, false},
+		{"fail DeleteKey", fields{client}, args{&apiv1.DeleteKeyRequest{
+			Name: "azurekms:vault=my-vault;name=not-found",
+		}}, true},
+		{"fail empty", fields{client}, args{&apiv1.DeleteKeyRequest{}}, true},
+		{"fail vault", fields{client}, args{&apiv1.DeleteKeyRequest{
+			Name: "azurekms:vault=;name=my-key",
+		}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &KeyVault{
+				baseClient: tt.fields.baseClient,
+			}
+			if err := k.DeleteKey(tt.args.req); (err != nil) != tt.wantErr {
+				t.Errorf("KeyVault.DeleteKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestKeyVault_Close(t *testing.T) {
 	client := mockClient(t)
 	type fields struct {
@@ -17,6 +17,7 @@ import (
 	"github.com/smallstep/certificates/kms/apiv1"
 	"github.com/smallstep/certificates/kms/uri"
 	"go.step.sm/crypto/pemutil"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 	kmspb "google.golang.org/genproto/googleapis/cloud/kms/v1"
 )
@@ -87,12 +88,39 @@ type KeyManagementClient interface {
 	GetKeyRing(context.Context, *kmspb.GetKeyRingRequest, ...gax.CallOption) (*kmspb.KeyRing, error)
 	CreateKeyRing(context.Context, *kmspb.CreateKeyRingRequest, ...gax.CallOption) (*kmspb.KeyRing, error)
 	CreateCryptoKeyVersion(ctx context.Context, req *kmspb.CreateCryptoKeyVersionRequest, opts ...gax.CallOption) (*kmspb.CryptoKeyVersion, error)
+	ListCryptoKeys(context.Context, *kmspb.ListCryptoKeysRequest, ...gax.CallOption) *cloudkms.CryptoKeyIterator
+	ListCryptoKeyVersions(context.Context, *kmspb.ListCryptoKeyVersionsRequest, ...gax.CallOption) *cloudkms.CryptoKeyVersionIterator
+	DestroyCryptoKeyVersion(context.Context, *kmspb.DestroyCryptoKeyVersionRequest, ...gax.CallOption) (*kmspb.CryptoKeyVersion, error)
 }
 
 var newKeyManagementClient = func(ctx context.Context, opts ...option.ClientOption) (KeyManagementClient, error) {
 	return cloudkms.NewKeyManagementClient(ctx, opts...)
 }
 
+// cryptoKeyIterator defines the methods on cloudkms.CryptoKeyIterator that this
+// package will use.
+type cryptoKeyIterator interface {
+	Next() (*kmspb.CryptoKey, error)
+}
+
+// cryptoKeyVersionIterator defines the methods on
+// cloudkms.CryptoKeyVersionIterator that this package will use.
+type cryptoKeyVersionIterator interface {
+	Next() (*kmspb.CryptoKeyVersion, error)
+}
+
+// listCryptoKeys is used for testing purposes, the iterators returned by the
+// client cannot be initialized outside the cloudkms package.
+var listCryptoKeys = func(ctx context.Context, client KeyManagementClient, req *kmspb.ListCryptoKeysRequest) cryptoKeyIterator {
+	return client.ListCryptoKeys(ctx, req)
+}
+
+// listCryptoKeyVersions is used for testing purposes, the iterators returned by
+// the client cannot be initialized outside the cloudkms package.
+var listCryptoKeyVersions = func(ctx context.Context, client KeyManagementClient, req *kmspb.ListCryptoKeyVersionsRequest) cryptoKeyVersionIterator {
+	return client.ListCryptoKeyVersions(ctx, req)
+}
+
 // CloudKMS implements a KMS using Google's Cloud apiv1.
 type CloudKMS struct {
 	client KeyManagementClient
@@ -322,6 +350,81 @@ func (k *CloudKMS) getPublicKeyWithRetries(name string, retries int) (response *
 	return
 }
 
+// ListKeys returns the enabled versions of the asymmetric signing keys in a key
+// ring, or the enabled versions of a crypto key. Names follow the patterns:
+//   projects/([^/]+)/locations/([a-zA-Z0-9_-]{1,63})/keyRings/([a-zA-Z0-9_-]{1,63})
+//   projects/([^/]+)/locations/([a-zA-Z0-9_-]{1,63})/keyRings/([a-zA-Z0-9_-]{1,63})/cryptoKeys/([a-zA-Z0-9_-]{1,63})
+func (k *CloudKMS) ListKeys(req *apiv1.ListKeysRequest) (*apiv1.ListKeysResponse, error) {
+	if req.Name == "" {
+		return nil, errors.New("listKeysRequest 'name' cannot be empty")
+	}
+
+	ctx, cancel := defaultContext()
+	defer cancel()
+
+	var cryptoKeys []string
+	if strings.Contains(req.Name, "/cryptoKeys/") {
+		cryptoKeys = []string{req.Name}
+	} else {
+		it := listCryptoKeys(ctx, k.client, &kmspb.ListCryptoKeysRequest{
+			Parent: req.Name,
+		})
+		for {
+			ck, err := it.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return nil, errors.Wrap(err, "cloudKMS ListCryptoKeys failed")
+			}
+			if ck.Purpose == kmspb.CryptoKey_ASYMMETRIC_SIGN {
+				cryptoKeys = append(cryptoKeys, ck.Name)
+			}
+		}
+	}
+
+	keys := []string{}
+	for _, name := range cryptoKeys {
+		it := listCryptoKeyVersions(ctx, k.client, &kmspb.ListCryptoKeyVersionsRequest{
+			Parent: name,
+			Filter: "state=ENABLED",
+		})
+		for {
+			v, err := it.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return nil, errors.Wrap(err, "cloudKMS ListCryptoKeyVersions failed")
+			}
+			keys = append(keys, v.Name)
+		}
+	}
+
+	return &apiv1.ListKeysResponse{
+		Keys: keys,
+	}, nil
+}
+
+// DeleteKey schedules the destruction of a crypto key version. Cloud KMS
+// destroys the key material after the scheduled destroy duration of the key,
+// 24 hours by default, and it can be restored until then.
+func (k *CloudKMS) DeleteKey(req *apiv1.DeleteKeyRequest) error {
+	if req.Name == "" {
+		return errors.New("deleteKeyRequest 'name' cannot be empty")
+	}
+
+	ctx, cancel := defaultContext()
+	defer cancel()
+
+	if _, err := k.client.DestroyCryptoKeyVersion(ctx, &kmspb.DestroyCryptoKeyVersionRequest{
+		Name: req.Name,
+	}); err != nil {
+		return errors.Wrap(err, "cloudKMS DestroyCryptoKeyVersion failed")
+	}
+	return nil
+}
+
 func defaultContext() (context.Context, context.CancelFunc) {
 	return context.WithTimeout(context.Background(), 15*time.Second)
 }
@@ -462,3 +462,123 @@ func TestCloudKMS_GetPublicKey(t *testing.T) {
 		})
 	}
 }
+
+func TestCloudKMS_ListKeys(t *testing.T) {
+	keyRing := "projects/p/locations/l/keyRings/k"
+	testError := fmt.Errorf("an error")
+
+	tmpKeys, tmpVersions := listCryptoKeys, listCryptoKeyVersions
+	t.Cleanup(func() {
+		listCryptoKeys, listCryptoKeyVersions = tmpKeys, tmpVersions
+	})
+
+	okKeys := func(_ context.Context, _ KeyManagementClient, req *kmspb.ListCryptoKeysRequest) cryptoKeyIterator {
+		return &mockCryptoKeyIterator{items: []*kmspb.CryptoKey{
+			{Name: req.Parent + "/cryptoKeys/sign", Purpose: kmspb.CryptoKey_ASYMMETRIC_SIGN},
+			{Name: req.Parent + "/cryptoKeys/decrypt", Purpose: kmspb.CryptoKey_ASYMMETRIC_DECRYPT},
+		}}
+	}
+	okVersions := func(_ context.Context, _ KeyManagementClient, req *kmspb.ListCryptoKeyVersionsRequest) cryptoKeyVersionIterator {
+		if req.Filter != "state=ENABLED" {
+			return &mockCryptoKeyVersionIterator{err: fmt.Errorf("unexpected filter %q", req.Filter)}
+		}
+		return &mockCryptoKeyVersionIterator{items: []*kmspb.CryptoKeyVersion{
+			{Name: req.Parent + "/cryptoKeyVersions/1"},
+			{Name: req.Parent + "/cryptoKeyVersions/3"},
+		}}
+	}
+
+	type args struct {
+		req *apiv1.ListKeysRequest
+	}
+	tests := []struct {
+		name         string
+		listKeys     func(context.Context, KeyManagementClient, *kmspb.ListCryptoKeysRequest) cryptoKeyIterator
+		listVersions func(context.Context, KeyManagementClient, *kmspb.ListCryptoKeyVersionsRequest) cryptoKeyVersionIterator
+		args         args
+		want         *apiv1.ListKeysResponse
+		wantErr      bool
+	}{
+		{"ok key ring", okKeys, okVersions, args{&apiv1.ListKeysRequest{Name: keyRing}}, &apiv1.ListKeysResponse{
+			Keys: []string{keyRing + "/cryptoKeys/sign/cryptoKeyVersions/1", keyRing + "/cryptoKeys/sign/cryptoKeyVersions/3"},
+		}, false},
+		{"ok crypto key", nil, okVersions, args{&apiv1.ListKeysRequest{Name: keyRing + "/cryptoKeys/other"}}, &apiv1.ListKeysResponse{
+			Keys: []string{keyRing + "/cryptoKeys/other/cryptoKeyVersions/1", keyRing + "/cryptoKeys/other/cryptoKeyVersions/3"},
+		}, false},
+		{"ok empty", func(_ context.Context, _ KeyManagementClient, _ *kmspb.ListCryptoKeysRequest) cryptoKeyIterator {
+			return &mockCryptoKeyIterator{}
+		}, okVersions, args{&apiv1.ListKeysRequest{Name: keyRing}}, &apiv1.ListKeysResponse{
+			Keys: []string{},
+		}, false},
+		{"fail name", okKeys, okVersions, args{&apiv1.ListKeysRequest{}}, nil, true},
+		{"fail list keys", func(_ context.Context, _ KeyManagementClient, _ *kmspb.ListCryptoKeysRequest) cryptoKeyIterator {
+			return &mockCryptoKeyIterator{err: testError}
+		}, okVersions, args{&apiv1.ListKeysRequest{Name: keyRing}}, nil, true},
+		{"fail list versions", okKeys, func(_ context.Context, _ KeyManagementClient, _ *kmspb.ListCryptoKeyVersionsRequest) cryptoKeyVersionIterator {
+			return &mockCryptoKeyVersionIterator{err: testError}
+		}, args{&apiv1.ListKeysRequest{Name: keyRing}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listCryptoKeys, listCryptoKeyVersions = tt.listKeys, tt.listVersions
+			k := &CloudKMS{
+				client: &MockClient{},
+			}
+			got, err := k.ListKeys(tt.args.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CloudKMS.ListKeys() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CloudKMS.ListKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloudKMS_DeleteKey(t *testing.T) {
+	keyName := "projects/p/locations/l/keyRings/k/cryptoKeys/c/cryptoKeyVersions/1"
+	testError := fmt.Errorf("an error")
+
+	type fields struct {
+		client KeyManagementClient
+	}
+	type args struct {
+		req *apiv1.DeleteKeyRequest
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		{"ok", fields{
+			&MockClient{
+				destroyCryptoKeyVersion: func(_ context.Context, req *kmspb.DestroyCryptoKeyVersionRequest, _ ...gax.CallOption) (*kmspb.CryptoKeyVersion, error) {
+					if req.Name != keyName {
+						return nil, fmt.Errorf("unexpected name %q", req.Name)
+					}
+					return &kmspb.CryptoKeyVersion{Name: keyName, State: kmspb.CryptoKeyVersion_DESTROY_SCHEDULED}, nil
+				},
+			}},
+			args{&apiv1.DeleteKeyRequest{Name: keyName}}, false},
+		{"fail name", fields{&MockClient{}}, args{&apiv1.DeleteKeyRequest{}}, true},
+		{"fail destroy", fields{
+			&MockClient{
+				destroyCryptoKeyVersion: func(_ context.Context, _ *kmspb.DestroyCryptoKeyVersionRequest, _ ...gax.CallOption) (*kmspb.CryptoKeyVersion, error) {
+					return nil, testError
+				},
+			}},
+			args{&apiv1.DeleteKeyRequest{Name: keyName}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &CloudKMS{
+				client: tt.fields.client,
+			}
+			if err := k.DeleteKey(tt.args.req); (err != nil) != tt.wantErr {
+				t.Errorf("CloudKMS.DeleteKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -3,18 +3,23 @@ package cloudkms
 import (
 	"context"
 
+	cloudkms "cloud.google.com/go/kms/apiv1"
 	gax "github.com/googleapis/gax-go/v2"
+	"google.golang.org/api/iterator"
 	kmspb "google.golang.org/genproto/googleapis/cloud/kms/v1"
 )
 
 type MockClient struct {
-	close                  func() error
-	getPublicKey           func(context.Context, *kmspb.GetPublicKeyRequest, ...gax.CallOption) (*kmspb.PublicKey, error)
-	asymmetricSign         func(context.Context, *kmspb.AsymmetricSignRequest, ...gax.CallOption) (*kmspb.AsymmetricSignResponse, error)
-	createCryptoKey        func(context.Context, *kmspb.CreateCryptoKeyRequest, ...gax.CallOption) (*kmspb.CryptoKey, error)
-	getKeyRing             func(context.Context, *kmspb.GetKeyRingRequest, ...gax.CallOption) (*kmspb.KeyRing, error)
-	createKeyRing          func(context.Context, *kmspb.CreateKeyRingRequest, ...gax.CallOption) (*kmspb.KeyRing, error)
-	createCryptoKeyVersion func(context.Context, *kmspb.CreateCryptoKeyVersionRequest, ...gax.CallOption) (*kmspb.CryptoKeyVersion, error)
+	close                   func() error
+	getPublicKey            func(context.Context, *kmspb.GetPublicKeyRequest, ...gax.CallOption) (*kmspb.PublicKey, error)
+	asymmetricSign          func(context.Context, *kmspb.AsymmetricSignRequest, ...gax.CallOption) (*kmspb.AsymmetricSignResponse, error)
+	createCryptoKey         func(context.Context, *kmspb.CreateCryptoKeyRequest, ...gax.CallOption) (*kmspb.CryptoKey, error)
+	getKeyRing              func(context.Context, *kmspb.GetKeyRingRequest, ...gax.CallOption) (*kmspb.KeyRing, error)
+	createKeyRing           func(context.Context, *kmspb.CreateKeyRingRequest, ...gax.CallOption) (*kmspb.KeyRing, error)
+	createCryptoKeyVersion  func(context.Context, *kmspb.CreateCryptoKeyVersionRequest, ...gax.CallOption) (*kmspb.CryptoKeyVersion, error)
+	listCryptoKeys          func(context.Context, *kmspb.ListCryptoKeysRequest, ...gax.CallOption) *cloudkms.CryptoKeyIterator
+	listCryptoKeyVersions   func(context.Context, *kmspb.ListCryptoKeyVersionsRequest, ...gax.CallOption) *cloudkms.CryptoKeyVersionIterator
+	destroyCryptoKeyVersion func(context.Context, *kmspb.DestroyCryptoKeyVersionRequest, ...gax.CallOption) (*kmspb.CryptoKeyVersion, error)
 }
 
 func (m *MockClient) Close() error {
@@ -44,3 +49,49 @@ func (m *MockClient) CreateKeyRing(ctx context.Context, req *kmspb.CreateKeyRing
 func (m *MockClient) CreateCryptoKeyVersion(ctx context.Context, req *kmspb.CreateCryptoKeyVersionRequest, opts ...gax.CallOption) (*kmspb.CryptoKeyVersion, error) {
 	return m.createCryptoKeyVersion(ctx, req, opts...)
 }
+
+func (m *MockClient) ListCryptoKeys(ctx context.Context, req *kmspb.ListCryptoKeysRequest, opts ...gax.CallOption) *cloudkms.CryptoKeyIterator {
+	return m.listCryptoKeys(ctx, req, opts...)
+}
+
+func (m *MockClient) ListCryptoKeyVersions(ctx context.Context, req *kmspb.ListCryptoKeyVersionsRequest, opts ...gax.CallOption) *cloudkms.CryptoKeyVersionIterator {
+	return m.listCryptoKeyVersions(ctx, req, opts...)
+}
+
+func (m *MockClient) DestroyCryptoKeyVersion(ctx context.Context, req *kmspb.DestroyCryptoKeyVersionRequest, opts ...gax.CallOption) (*kmspb.CryptoKeyVersion, error) {
+	return m.destroyCryptoKeyVersion(ctx, req, opts...)
+}
+
+type mockCryptoKeyIterator struct {
+	items []*kmspb.CryptoKey
+	err   error
+}
+
+func (m *mockCryptoKeyIterator) Next() (*kmspb.CryptoKey, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if len(m.items) == 0 {
+		return nil, iterator.Done
+	}
+	item := m.items[0]
+	m.items = m.items[1:]
+	return item, nil
+}
+
+type mockCryptoKeyVersionIterator struct {
+	items []*kmspb.CryptoKeyVersion
+	err   error
+}
+
+func (m *mockCryptoKeyVersionIterator) Next() (*kmspb.CryptoKeyVersion, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if len(m.items) == 0 {
+		return nil, iterator.Done
+	}
+	item := m.items[0]
+	m.items = m.items[1:]
+	return item, nil
+}
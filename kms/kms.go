@@ -18,6 +18,16 @@ type KeyManager = apiv1.KeyManager
 // store x509.Certificates.
 type CertificateManager = apiv1.CertificateManager
 
+// Attester is the interface implemented by the KMS that can create an
+// attestation of a key.
+type Attester = apiv1.Attester
+
+// KeyLister is the interface implemented by the KMS that can list keys.
+type KeyLister = apiv1.KeyLister
+
+// KeyDeleter is the interface implemented by the KMS that can delete keys.
+type KeyDeleter = apiv1.KeyDeleter
+
 // Options are the KMS options. They represent the kms object in the ca.json.
 type Options = apiv1.Options
 
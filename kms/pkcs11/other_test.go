@@ -69,6 +69,33 @@ func (s *stubPKCS11) FindKeyPair(id, label []byte) (crypto11.Signer, error) {
 	return nil, nil
 }
 
+func (s *stubPKCS11) FindAllKeyPairs() ([]crypto11.Signer, error) {
+	var signers []crypto11.Signer
+	for _, signer := range s.signers {
+		if signer != nil {
+			signers = append(signers, signer)
+		}
+	}
+	return signers, nil
+}
+
+func (s *stubPKCS11) GetAttributes(key interface{}, attributes []crypto11.AttributeType) (crypto11.AttributeSet, error) {
+	k, ok := key.(*privateKey)
+	if !ok {
+		return nil, errors.New("not a PKCS#11 key")
+	}
+	set := crypto11.AttributeSet{}
+	for _, a := range attributes {
+		switch a {
+		case crypto11.CkaId:
+			set.Set(a, k.id)
+		case crypto11.CkaLabel:
+			set.Set(a, k.label)
+		}
+	}
+	return set, nil
+}
+
 func (s *stubPKCS11) FindCertificate(id, label []byte, serial *big.Int) (*x509.Certificate, error) {
 	if id == nil && label == nil && serial == nil {
 		return nil, errors.New("id, label and serial cannot both be nil")
@@ -146,6 +173,8 @@ func (s *stubPKCS11) GenerateRSAKeyPairWithLabel(id, label []byte, bits int) (cr
 	k := &privateKey{
 		Signer: p,
 		index:  len(s.signers),
+		id:     id,
+		label:  label,
 		stub:   s,
 	}
 	s.signers = append(s.signers, k)
@@ -177,6 +206,8 @@ func (s *stubPKCS11) GenerateECDSAKeyPairWithLabel(id, label []byte, curve ellip
 	k := &privateKey{
 		Signer: p,
 		index:  len(s.signers),
+		id:     id,
+		label:  label,
 		stub:   s,
 	}
 	s.signers = append(s.signers, k)
@@ -193,6 +224,8 @@ func (s *stubPKCS11) Close() error {
 type privateKey struct {
 	crypto.Signer
 	index int
+	id    []byte
+	label []byte
 	stub  *stubPKCS11
 }
 
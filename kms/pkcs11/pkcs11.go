@@ -12,6 +12,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"math/big"
+	"net/url"
 	"strconv"
 	"sync"
 
@@ -32,6 +33,8 @@ const DefaultRSASize = 3072
 // interface will be used for unit testing.
 type P11 interface {
 	FindKeyPair(id, label []byte) (crypto11.Signer, error)
+	FindAllKeyPairs() ([]crypto11.Signer, error)
+	GetAttributes(key interface{}, attributes []crypto11.AttributeType) (crypto11.AttributeSet, error)
 	FindCertificate(id, label []byte, serial *big.Int) (*x509.Certificate, error)
 	ImportCertificateWithAttributes(template crypto11.AttributeSet, certificate *x509.Certificate) error
 	DeleteCertificate(id, label []byte, serial *big.Int) error
@@ -237,9 +240,45 @@ func (k *PKCS11) StoreCertificate(req *apiv1.StoreCertificateRequest) error {
 	return nil
 }
 
-// DeleteKey is a utility function to delete a key given an uri.
-func (k *PKCS11) DeleteKey(u string) error {
-	id, object, err := parseObject(u)
+// ListKeys returns the uris of all the key pairs in the token. The name in the
+// request is not used, the keys are listed in the token configured in the KMS.
+func (k *PKCS11) ListKeys(req *apiv1.ListKeysRequest) (*apiv1.ListKeysResponse, error) {
+	signers, err := k.p11.FindAllKeyPairs()
+	if err != nil {
+		return nil, errors.Wrap(err, "listKeys failed")
+	}
+
+	keys := []string{}
+	for _, signer := range signers {
+		attrs, err := k.p11.GetAttributes(signer, []crypto11.AttributeType{
+			crypto11.CkaId, crypto11.CkaLabel,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "listKeys failed")
+		}
+		values := url.Values{}
+		if v := attrs[crypto11.CkaId]; v != nil && len(v.Value) > 0 {
+			values.Set("id", hex.EncodeToString(v.Value))
+		}
+		if v := attrs[crypto11.CkaLabel]; v != nil && len(v.Value) > 0 {
+			values.Set("object", string(v.Value))
+		}
+		// Keys without id and label cannot be referenced by an uri.
+		if len(values) == 0 {
+			continue
+		}
+		keys = append(keys, uri.New(Scheme, values).String())
+	}
+
+	return &apiv1.ListKeysResponse{
+		Keys: keys,
+	}, nil
+}
+
+// DeleteKey deletes the key pair with the uri passed in the request name. It
+// does not fail if the key does not exist.
+func (k *PKCS11) DeleteKey(req *apiv1.DeleteKeyRequest) error {
+	id, object, err := parseObject(req.Name)
 	if err != nil {
 		return errors.Wrap(err, "deleteKey failed")
 	}
@@ -189,7 +189,7 @@ func TestPKCS11_CreateKey(t *testing.T) {
 	k := setupPKCS11(t)
 
 	// Make sure to delete the created key
-	k.DeleteKey(testObject)
+	k.DeleteKey(&apiv1.DeleteKeyRequest{Name: testObject})
 
 	type args struct {
 		req *apiv1.CreateKeyRequest
@@ -379,7 +379,7 @@ func TestPKCS11_CreateKey(t *testing.T) {
 				t.Errorf("PKCS11.CreateKey() = %v, want %v", got, tt.want)
 			}
 			if got != nil {
-				if err := k.DeleteKey(got.Name); err != nil {
+				if err := k.DeleteKey(&apiv1.DeleteKeyRequest{Name: got.Name}); err != nil {
 					t.Errorf("PKCS11.DeleteKey() error = %v", err)
 				}
 			}
@@ -724,6 +724,24 @@ func TestPKCS11_StoreCertificate(t *testing.T) {
 	}
 }
 
+func TestPKCS11_ListKeys(t *testing.T) {
+	k := setupPKCS11(t)
+
+	got, err := k.ListKeys(&apiv1.ListKeysRequest{})
+	if err != nil {
+		t.Fatalf("PKCS11.ListKeys() error = %v", err)
+	}
+	keys := make(map[string]bool)
+	for _, name := range got.Keys {
+		keys[name] = true
+	}
+	for _, tk := range testKeys {
+		if !keys[tk.Name] {
+			t.Errorf("PKCS11.ListKeys() = %v, want %s", got.Keys, tk.Name)
+		}
+	}
+}
+
 func TestPKCS11_DeleteKey(t *testing.T) {
 	k := setupPKCS11(t)
 
@@ -749,7 +767,7 @@ func TestPKCS11_DeleteKey(t *testing.T) {
 			}); err != nil {
 				t.Fatalf("PKCS1.CreateKey() error = %v", err)
 			}
-			if err := k.DeleteKey(tt.args.uri); (err != nil) != tt.wantErr {
+			if err := k.DeleteKey(&apiv1.DeleteKeyRequest{Name: tt.args.uri}); (err != nil) != tt.wantErr {
 				t.Errorf("PKCS11.DeleteKey() error = %v, wantErr %v", err, tt.wantErr)
 			}
 			if _, err := k.GetPublicKey(&apiv1.GetPublicKeyRequest{
@@ -758,7 +776,7 @@ func TestPKCS11_DeleteKey(t *testing.T) {
 				t.Error("PKCS11.GetPublicKey() public key found and not expected")
 			}
 			// Make sure to delete the created one.
-			if err := k.DeleteKey(testObject); err != nil {
+			if err := k.DeleteKey(&apiv1.DeleteKeyRequest{Name: testObject}); err != nil {
 				t.Errorf("PKCS11.DeleteKey() error = %v", err)
 			}
 		})
@@ -116,7 +116,7 @@ func setup(t TBTesting, k *PKCS11) {
 func teardown(t TBTesting, k *PKCS11) {
 	testObjects := []string{testObject, testObjectByID, testObjectByLabel}
 	for _, name := range testObjects {
-		if err := k.DeleteKey(name); err != nil {
+		if err := k.DeleteKey(&apiv1.DeleteKeyRequest{Name: name}); err != nil {
 			t.Errorf("PKCS11.DeleteKey() error = %v", err)
 		}
 		if err := k.DeleteCertificate(name); err != nil {
@@ -124,7 +124,7 @@ func teardown(t TBTesting, k *PKCS11) {
 		}
 	}
 	for _, tk := range testKeys {
-		if err := k.DeleteKey(tk.Name); err != nil {
+		if err := k.DeleteKey(&apiv1.DeleteKeyRequest{Name: tk.Name}); err != nil {
 			t.Errorf("PKCS11.DeleteKey() error = %v", err)
 		}
 	}
@@ -7,6 +7,10 @@ import (
 	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/smallstep/certificates/kms/apiv1"
@@ -181,3 +185,54 @@ func (k *SoftKMS) CreateDecrypter(req *apiv1.CreateDecrypterRequest) (crypto.Dec
 		return nil, errors.New("failed to load softKMS: please define decryptionKeyPEM or decryptionKey")
 	}
 }
+
+// ListKeys returns the PEM encoded private keys in the directory passed in the
+// request name. Files that are not private keys are ignored.
+func (k *SoftKMS) ListKeys(req *apiv1.ListKeysRequest) (*apiv1.ListKeysResponse, error) {
+	if req.Name == "" {
+		return nil, errors.New("listKeysRequest 'name' cannot be empty")
+	}
+
+	entries, err := os.ReadDir(req.Name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %s", req.Name)
+	}
+
+	keys := []string{}
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		filename := filepath.Join(req.Name, e.Name())
+		if isPrivateKeyFile(filename) {
+			keys = append(keys, filename)
+		}
+	}
+
+	return &apiv1.ListKeysResponse{
+		Keys: keys,
+	}, nil
+}
+
+// DeleteKey removes the private key file passed in the request name. It fails
+// if the file is not a PEM encoded private key.
+func (k *SoftKMS) DeleteKey(req *apiv1.DeleteKeyRequest) error {
+	if req.Name == "" {
+		return errors.New("deleteKeyRequest 'name' cannot be empty")
+	}
+	if !isPrivateKeyFile(req.Name) {
+		return errors.Errorf("%s is not a private key", req.Name)
+	}
+	return errors.Wrapf(os.Remove(req.Name), "error deleting %s", req.Name)
+}
+
+// isPrivateKeyFile returns true if the first PEM block in the given file is a
+// private key.
+func isPrivateKeyFile(filename string) bool {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return false
+	}
+	block, _ := pem.Decode(b)
+	return block != nil && strings.HasSuffix(block.Type, "PRIVATE KEY")
+}
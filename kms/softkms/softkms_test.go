@@ -12,6 +12,7 @@ import (
 	"encoding/pem"
 	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -379,3 +380,71 @@ func TestSoftKMS_CreateDecrypter(t *testing.T) {
 		})
 	}
 }
+
+func TestSoftKMS_ListKeys(t *testing.T) {
+	type args struct {
+		req *apiv1.ListKeysRequest
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *apiv1.ListKeysResponse
+		wantErr bool
+	}{
+		{"ok", args{&apiv1.ListKeysRequest{Name: "testdata"}}, &apiv1.ListKeysResponse{
+			Keys: []string{"testdata/cert.key", "testdata/priv.pem", "testdata/rsa.priv.pem"},
+		}, false},
+		{"fail empty", args{&apiv1.ListKeysRequest{}}, nil, true},
+		{"fail missing", args{&apiv1.ListKeysRequest{Name: "testdata/missing"}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &SoftKMS{}
+			got, err := k.ListKeys(tt.args.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SoftKMS.ListKeys() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SoftKMS.ListKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSoftKMS_DeleteKey(t *testing.T) {
+	dir := t.TempDir()
+	b, err := os.ReadFile("testdata/cert.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "cert.key")
+	if err := os.WriteFile(keyFile, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	type args struct {
+		req *apiv1.DeleteKeyRequest
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{"ok", args{&apiv1.DeleteKeyRequest{Name: keyFile}}, false},
+		{"fail empty", args{&apiv1.DeleteKeyRequest{}}, true},
+		{"fail missing", args{&apiv1.DeleteKeyRequest{Name: keyFile}}, true},
+		{"fail not a key", args{&apiv1.DeleteKeyRequest{Name: "testdata/pub.pem"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &SoftKMS{}
+			if err := k.DeleteKey(tt.args.req); (err != nil) != tt.wantErr {
+				t.Errorf("SoftKMS.DeleteKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	if _, err := os.Stat(keyFile); !os.IsNotExist(err) {
+		t.Errorf("SoftKMS.DeleteKey() did not delete %s", keyFile)
+	}
+}
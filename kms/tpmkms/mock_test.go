@@ -0,0 +1,327 @@
+package tpmkms
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+	"testing"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpm2/transport"
+)
+
+// mockTPM is a minimal implementation of a TPM 2.0 with a resource manager,
+// it supports the commands used by the KMS.
+type mockTPM struct {
+	mu         sync.Mutex
+	nextHandle tpm2.TPMHandle
+	objects    map[tpm2.TPMHandle]*mockObject
+	keys       map[string]crypto.Signer
+}
+
+type mockObject struct {
+	public *tpm2.TPMTPublic
+	key    crypto.Signer
+}
+
+// mockConn is a connection to the mock TPM. The transient objects created by
+// the connection are flushed when the connection is closed.
+type mockConn struct {
+	tpm       *mockTPM
+	transient []tpm2.TPMHandle
+}
+
+func newMockTPM(t *testing.T) *mockTPM {
+	t.Helper()
+	m := &mockTPM{
+		nextHandle: 0x80000000,
+		objects:    make(map[tpm2.TPMHandle]*mockObject),
+		keys:       make(map[string]crypto.Signer),
+	}
+	tmp := openTPM
+	openTPM = func(device string) (transport.TPMCloser, error) {
+		return &mockConn{tpm: m}, nil
+	}
+	t.Cleanup(func() {
+		openTPM = tmp
+	})
+	return m
+}
+
+func (c *mockConn) Close() error {
+	c.tpm.mu.Lock()
+	defer c.tpm.mu.Unlock()
+	for _, h := range c.transient {
+		delete(c.tpm.objects, h)
+	}
+	return nil
+}
+
+// mockReader decodes the parameters of a command.
+type mockReader struct {
+	b []byte
+}
+
+func (r *mockReader) uint16() uint16 {
+	v := binary.BigEndian.Uint16(r.b)
+	r.b = r.b[2:]
+	return v
+}
+
+func (r *mockReader) uint32() uint32 {
+	v := binary.BigEndian.Uint32(r.b)
+	r.b = r.b[4:]
+	return v
+}
+
+func (r *mockReader) sized() []byte {
+	n := int(r.uint16())
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+// mockWriter encodes the parameters of a response.
+type mockWriter struct {
+	bytes.Buffer
+}
+
+func (w *mockWriter) uint16(v uint16) {
+	binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *mockWriter) uint32(v uint32) {
+	binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *mockWriter) sized(b []byte) {
+	w.uint16(uint16(len(b)))
+	w.Write(b)
+}
+
+// emptyCreation writes empty creation data, hash and ticket.
+func (w *mockWriter) emptyCreation() {
+	w.sized(nil) // creationData
+	w.sized(nil) // creationHash
+	w.uint16(uint16(tpm2.TPMSTCreation))
+	w.uint32(uint32(tpm2.TPMRHNull))
+	w.sized(nil)
+}
+
+func (c *mockConn) Send(cmd []byte) ([]byte, error) {
+	c.tpm.mu.Lock()
+	defer c.tpm.mu.Unlock()
+
+	r := &mockReader{b: cmd}
+	tag := tpm2.TPMST(r.uint16())
+	r.uint32() // size
+	cc := tpm2.TPMCC(r.uint32())
+
+	var numHandles int
+	switch cc {
+	case tpm2.TPMCCEvictControl:
+		numHandles = 2
+	case tpm2.TPMCCFlushContext:
+		numHandles = 0
+	default:
+		numHandles = 1
+	}
+	var handles []tpm2.TPMHandle
+	for i := 0; i < numHandles; i++ {
+		handles = append(handles, tpm2.TPMHandle(r.uint32()))
+	}
+	if tag == tpm2.TPMSTSessions {
+		r.b = r.b[r.uint32():]
+	}
+
+	var rc tpm2.TPMRC
+	var outHandles []tpm2.TPMHandle
+	params := new(mockWriter)
+	switch cc {
+	case tpm2.TPMCCCreatePrimary:
+		obj := &mockObject{public: &srkTemplate}
+		outHandles = append(outHandles, c.newHandle(obj))
+		params.Write(tpm2.Marshal(tpm2.New2B(*obj.public)))
+		params.emptyCreation()
+		params.sized(mockName(obj.public))
+	case tpm2.TPMCCCreate:
+		r.sized() // inSensitive
+		template, err := tpm2.Unmarshal[tpm2.TPMTPublic](r.sized())
+		if err != nil {
+			rc = tpm2.TPMRCValue
+			break
+		}
+		obj, err := newMockObject(template)
+		if err != nil {
+			rc = tpm2.TPMRCValue
+			break
+		}
+		id := make([]byte, 16)
+		rand.Read(id)
+		c.tpm.keys[string(id)] = obj.key
+		params.sized(id)
+		params.Write(tpm2.Marshal(tpm2.New2B(*obj.public)))
+		params.emptyCreation()
+	case tpm2.TPMCCLoad:
+		private, public := r.sized(), r.sized()
+		key, ok := c.tpm.keys[string(private)]
+		if !ok {
+			rc = tpm2.TPMRCValue
+			break
+		}
+		pub, err := tpm2.Unmarshal[tpm2.TPMTPublic](public)
+		if err != nil {
+			rc = tpm2.TPMRCValue
+			break
+		}
+		outHandles = append(outHandles, c.newHandle(&mockObject{public: pub, key: key}))
+		params.sized(mockName(pub))
+	case tpm2.TPMCCReadPublic:
+		obj, ok := c.tpm.objects[handles[0]]
+		if !ok {
+			rc = tpm2.TPMRCHandle + 0x100 // handle 1
+			break
+		}
+		params.Write(tpm2.Marshal(tpm2.New2B(*obj.public)))
+		params.sized(mockName(obj.public))
+		params.sized(nil) // qualifiedName
+	case tpm2.TPMCCEvictControl:
+		persistent := tpm2.TPMHandle(r.uint32())
+		if _, ok := c.tpm.objects[persistent]; ok {
+			rc = tpm2.TPMRCNVDefined
+			break
+		}
+		c.tpm.objects[persistent] = c.tpm.objects[handles[1]]
+	case tpm2.TPMCCFlushContext:
+		delete(c.tpm.objects, tpm2.TPMHandle(r.uint32()))
+	case tpm2.TPMCCSign:
+		obj, ok := c.tpm.objects[handles[0]]
+		if !ok || obj.key == nil {
+			rc = tpm2.TPMRCHandle + 0x100 // handle 1
+			break
+		}
+		digest := r.sized()
+		scheme, hashAlg := tpm2.TPMAlgID(r.uint16()), tpm2.TPMAlgID(r.uint16())
+		hash, err := hashAlg.Hash()
+		if err != nil {
+			rc = tpm2.TPMRCHash
+			break
+		}
+		params.uint16(uint16(scheme))
+		params.uint16(uint16(hashAlg))
+		switch scheme {
+		case tpm2.TPMAlgECDSA:
+			rr, ss, err := ecdsa.Sign(rand.Reader, obj.key.(*ecdsa.PrivateKey), digest)
+			if err != nil {
+				rc = tpm2.TPMRCValue
+				break
+			}
+			params.sized(rr.Bytes())
+			params.sized(ss.Bytes())
+		case tpm2.TPMAlgRSASSA:
+			sig, err := rsa.SignPKCS1v15(rand.Reader, obj.key.(*rsa.PrivateKey), hash, digest)
+			if err != nil {
+				rc = tpm2.TPMRCValue
+				break
+			}
+			params.sized(sig)
+		case tpm2.TPMAlgRSAPSS:
+			sig, err := rsa.SignPSS(rand.Reader, obj.key.(*rsa.PrivateKey), hash, digest, &rsa.PSSOptions{
+				SaltLength: rsa.PSSSaltLengthEqualsHash,
+			})
+			if err != nil {
+				rc = tpm2.TPMRCValue
+				break
+			}
+			params.sized(sig)
+		default:
+			rc = tpm2.TPMRCScheme
+		}
+	default:
+		rc = tpm2.TPMRCCommandCode
+	}
+
+	body := new(mockWriter)
+	if rc == tpm2.TPMRCSuccess {
+		for _, h := range outHandles {
+			body.uint32(uint32(h))
+		}
+		if tag == tpm2.TPMSTSessions {
+			body.uint32(uint32(params.Len()))
+			body.Write(params.Bytes())
+			body.sized(nil)   // nonce
+			body.WriteByte(1) // continueSession
+			body.sized(nil)   // hmac
+		} else {
+			body.Write(params.Bytes())
+		}
+	} else {
+		tag = tpm2.TPMSTNoSessions
+	}
+
+	resp := new(mockWriter)
+	resp.uint16(uint16(tag))
+	resp.uint32(uint32(10 + body.Len()))
+	resp.uint32(uint32(rc))
+	resp.Write(body.Bytes())
+	return resp.Bytes(), nil
+}
+
+func (c *mockConn) newHandle(obj *mockObject) tpm2.TPMHandle {
+	h := c.tpm.nextHandle
+	c.tpm.nextHandle++
+	c.tpm.objects[h] = obj
+	c.transient = append(c.transient, h)
+	return h
+}
+
+// mockName returns the name of an object, the hash of its public area.
+func mockName(pub *tpm2.TPMTPublic) []byte {
+	sum := sha256.Sum256(tpm2.Marshal(*pub))
+	return append([]byte{0x00, 0x0B}, sum[:]...)
+}
+
+// newMockObject creates a new key with the given template and returns it with
+// its public area.
+func newMockObject(template *tpm2.TPMTPublic) (*mockObject, error) {
+	pub := *template
+	switch pub.Type {
+	case tpm2.TPMAlgRSA:
+		params, err := pub.Parameters.RSADetail()
+		if err != nil {
+			return nil, err
+		}
+		key, err := rsa.GenerateKey(rand.Reader, int(params.KeyBits))
+		if err != nil {
+			return nil, err
+		}
+		pub.Unique = tpm2.NewTPMUPublicID(tpm2.TPMAlgRSA, &tpm2.TPM2BPublicKeyRSA{
+			Buffer: key.N.Bytes(),
+		})
+		return &mockObject{public: &pub, key: key}, nil
+	default:
+		params, err := pub.Parameters.ECCDetail()
+		if err != nil {
+			return nil, err
+		}
+		curve, err := params.CurveID.Curve()
+		if err != nil {
+			return nil, err
+		}
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		pub.Unique = tpm2.NewTPMUPublicID(tpm2.TPMAlgECC, &tpm2.TPMSECCPoint{
+			X: tpm2.TPM2BECCParameter{Buffer: key.X.FillBytes(make([]byte, size))},
+			Y: tpm2.TPM2BECCParameter{Buffer: key.Y.FillBytes(make([]byte, size))},
+		})
+		return &mockObject{public: &pub, key: key}, nil
+	}
+}
@@ -0,0 +1,94 @@
+package tpmkms
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"io"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpm2/transport"
+	"github.com/pkg/errors"
+)
+
+// Signer implements a crypto.Signer using a key in a TPM 2.0.
+type Signer struct {
+	key       *tpmKey
+	publicKey crypto.PublicKey
+}
+
+// newSigner creates a new signer with the given key. The public key is loaded
+// in advance, to make sure that the key exists.
+func newSigner(key *tpmKey) (*Signer, error) {
+	pub, err := key.readPublic()
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{
+		key:       key,
+		publicKey: pub,
+	}, nil
+}
+
+// Public returns the public key of this signer.
+func (s *Signer) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// Sign signs digest with the key in the TPM. RSA-PSS signatures always use a
+// salt with the length of the hash, the only one supported by the TPM.
+func (s *Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	scheme, err := getSignatureScheme(s.publicKey, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(digest) != opts.HashFunc().Size() {
+		return nil, errors.Errorf("invalid digest length %d for hash function %v", len(digest), opts.HashFunc())
+	}
+
+	var signature []byte
+	if err := s.key.do(func(t transport.TPM, key tpm2.NamedHandle) (err error) {
+		signature, err = sign(t, key, digest, scheme)
+		return
+	}); err != nil {
+		return nil, err
+	}
+	return signature, nil
+}
+
+// getSignatureScheme returns the TPM signature scheme for the given key and
+// options.
+func getSignatureScheme(key crypto.PublicKey, opts crypto.SignerOpts) (tpm2.TPMTSigScheme, error) {
+	var hashAlg tpm2.TPMIAlgHash
+	switch h := opts.HashFunc(); h {
+	case crypto.SHA256:
+		hashAlg = tpm2.TPMAlgSHA256
+	case crypto.SHA384:
+		hashAlg = tpm2.TPMAlgSHA384
+	case crypto.SHA512:
+		hashAlg = tpm2.TPMAlgSHA512
+	default:
+		return tpm2.TPMTSigScheme{}, errors.Errorf("unsupported hash function %v", h)
+	}
+
+	var alg tpm2.TPMAlgID
+	switch key.(type) {
+	case *rsa.PublicKey:
+		alg = tpm2.TPMAlgRSASSA
+		if o, ok := opts.(*rsa.PSSOptions); ok {
+			if o.SaltLength != rsa.PSSSaltLengthAuto && o.SaltLength != rsa.PSSSaltLengthEqualsHash && o.SaltLength != o.Hash.Size() {
+				return tpm2.TPMTSigScheme{}, errors.Errorf("unsupported salt length %d", o.SaltLength)
+			}
+			alg = tpm2.TPMAlgRSAPSS
+		}
+	case *ecdsa.PublicKey:
+		alg = tpm2.TPMAlgECDSA
+	default:
+		return tpm2.TPMTSigScheme{}, errors.Errorf("unsupported key type %T", key)
+	}
+
+	return tpm2.TPMTSigScheme{
+		Scheme:  alg,
+		Details: tpm2.NewTPMUSigScheme(alg, &tpm2.TPMSSchemeHash{HashAlg: hashAlg}),
+	}, nil
+}
@@ -0,0 +1,82 @@
+package tpmkms
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"path/filepath"
+	"testing"
+
+	"github.com/smallstep/certificates/kms/apiv1"
+)
+
+func TestSigner_Sign(t *testing.T) {
+	newMockTPM(t)
+	dir := t.TempDir()
+
+	k := &TPMKMS{device: DefaultDevice}
+	createSigner := func(name string, alg apiv1.SignatureAlgorithm) crypto.Signer {
+		resp, err := k.CreateKey(&apiv1.CreateKeyRequest{Name: name, SignatureAlgorithm: alg})
+		if err != nil {
+			t.Fatal(err)
+		}
+		signer, err := k.CreateSigner(&resp.CreateSignerRequest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signer
+	}
+
+	ecSigner := createSigner("tpmkms:handle=0x81000001", apiv1.ECDSAWithSHA256)
+	blobSigner := createSigner("tpmkms:blob="+filepath.Join(dir, "key.tss"), apiv1.ECDSAWithSHA384)
+	rsaSigner := createSigner("tpmkms:handle=0x81000002", apiv1.SHA256WithRSA)
+
+	sum256 := sha256.Sum256([]byte("message"))
+	sum384 := sha512.Sum384([]byte("message"))
+
+	tests := []struct {
+		name    string
+		signer  crypto.Signer
+		digest  []byte
+		opts    crypto.SignerOpts
+		wantErr bool
+	}{
+		{"ok ec", ecSigner, sum256[:], crypto.SHA256, false},
+		{"ok blob", blobSigner, sum384[:], crypto.SHA384, false},
+		{"ok rsa", rsaSigner, sum256[:], crypto.SHA256, false},
+		{"ok rsa pss", rsaSigner, sum256[:], &rsa.PSSOptions{Hash: crypto.SHA256, SaltLength: rsa.PSSSaltLengthEqualsHash}, false},
+		{"fail hash", ecSigner, sum256[:], crypto.SHA1, true},
+		{"fail digest", ecSigner, sum384[:], crypto.SHA256, true},
+		{"fail salt length", rsaSigner, sum256[:], &rsa.PSSOptions{Hash: crypto.SHA256, SaltLength: 10}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.signer.Sign(rand.Reader, tt.digest, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Signer.Sign() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			switch pub := tt.signer.Public().(type) {
+			case *ecdsa.PublicKey:
+				if !ecdsa.VerifyASN1(pub, tt.digest, got) {
+					t.Error("Signer.Sign() signature is not valid")
+				}
+			case *rsa.PublicKey:
+				if o, ok := tt.opts.(*rsa.PSSOptions); ok {
+					err = rsa.VerifyPSS(pub, o.Hash, tt.digest, got, o)
+				} else {
+					err = rsa.VerifyPKCS1v15(pub, tt.opts.HashFunc(), tt.digest, got)
+				}
+				if err != nil {
+					t.Errorf("Signer.Sign() signature is not valid: %v", err)
+				}
+			}
+		})
+	}
+}
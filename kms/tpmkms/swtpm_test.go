@@ -0,0 +1,115 @@
+//go:build swtpm
+// +build swtpm
+
+package tpmkms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/smallstep/certificates/kms/apiv1"
+)
+
+// mustSWTPM returns a TPM KMS connected to the swtpm simulator. The address
+// defaults to tcp://localhost:2321, and it can be changed with the
+// STEP_TPM_SIMULATOR environment variable. To run these tests, we should run:
+//
+//	swtpm socket --tpm2 --server type=tcp,port=2321 --ctrl type=tcp,port=2322 \
+//	  --tpmstate dir=$(mktemp -d) --flags not-need-init,startup-clear
+//	go test -tags swtpm ./kms/tpmkms
+func mustSWTPM(t *testing.T) *TPMKMS {
+	t.Helper()
+	device := os.Getenv("STEP_TPM_SIMULATOR")
+	if device == "" {
+		device = "tcp://localhost:2321"
+	}
+	k, err := New(context.Background(), apiv1.Options{URI: "tpmkms:device=" + device})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestTPMKMS_swtpm(t *testing.T) {
+	k := mustSWTPM(t)
+	dir := t.TempDir()
+
+	tests := []struct {
+		name string
+		req  *apiv1.CreateKeyRequest
+		opts crypto.SignerOpts
+	}{
+		{"ok P-256", &apiv1.CreateKeyRequest{Name: "tpmkms:blob=" + filepath.Join(dir, "p256.tss")}, crypto.SHA256},
+		{"ok P-384", &apiv1.CreateKeyRequest{Name: "tpmkms:blob=" + filepath.Join(dir, "p384.tss"), SignatureAlgorithm: apiv1.ECDSAWithSHA384}, crypto.SHA384},
+		{"ok RSA", &apiv1.CreateKeyRequest{Name: "tpmkms:blob=" + filepath.Join(dir, "rsa.tss"), SignatureAlgorithm: apiv1.SHA256WithRSA}, crypto.SHA256},
+		{"ok RSA-PSS", &apiv1.CreateKeyRequest{Name: "tpmkms:blob=" + filepath.Join(dir, "rsa-pss.tss"), SignatureAlgorithm: apiv1.SHA256WithRSAPSS}, &rsa.PSSOptions{
+			SaltLength: rsa.PSSSaltLengthEqualsHash,
+			Hash:       crypto.SHA256,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := k.CreateKey(tt.req)
+			if err != nil {
+				t.Fatalf("TPMKMS.CreateKey() error = %v", err)
+			}
+
+			pub, err := k.GetPublicKey(&apiv1.GetPublicKeyRequest{Name: resp.Name})
+			if err != nil {
+				t.Fatalf("TPMKMS.GetPublicKey() error = %v", err)
+			}
+			if !reflect.DeepEqual(pub, resp.PublicKey) {
+				t.Errorf("TPMKMS.GetPublicKey() = %v, want %v", pub, resp.PublicKey)
+			}
+
+			signer, err := k.CreateSigner(&resp.CreateSignerRequest)
+			if err != nil {
+				t.Fatalf("TPMKMS.CreateSigner() error = %v", err)
+			}
+
+			h := tt.opts.HashFunc().New()
+			h.Write([]byte("swtpm"))
+			digest := h.Sum(nil)
+			sig, err := signer.Sign(rand.Reader, digest, tt.opts)
+			if err != nil {
+				t.Fatalf("Signer.Sign() error = %v", err)
+			}
+
+			switch pub := pub.(type) {
+			case *ecdsa.PublicKey:
+				if !ecdsa.VerifyASN1(pub, digest, sig) {
+					t.Error("Signer.Sign() signature is not valid")
+				}
+			case *rsa.PublicKey:
+				if o, ok := tt.opts.(*rsa.PSSOptions); ok {
+					err = rsa.VerifyPSS(pub, o.Hash, digest, sig, o)
+				} else {
+					err = rsa.VerifyPKCS1v15(pub, tt.opts.HashFunc(), digest, sig)
+				}
+				if err != nil {
+					t.Errorf("Signer.Sign() signature is not valid: %v", err)
+				}
+			default:
+				t.Errorf("TPMKMS.GetPublicKey() type %T is not expected", pub)
+			}
+		})
+	}
+}
+
+func TestTPMKMS_swtpm_existing(t *testing.T) {
+	k := mustSWTPM(t)
+	name := "tpmkms:blob=" + filepath.Join(t.TempDir(), "key.tss")
+	if _, err := k.CreateKey(&apiv1.CreateKeyRequest{Name: name}); err != nil {
+		t.Fatalf("TPMKMS.CreateKey() error = %v", err)
+	}
+	if _, err := k.CreateKey(&apiv1.CreateKeyRequest{Name: name}); err == nil {
+		t.Error("TPMKMS.CreateKey() error = nil, want an error for an existing key")
+	}
+}
@@ -0,0 +1,252 @@
+package tpmkms
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"io"
+	"math/big"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpm2/transport"
+	"github.com/pkg/errors"
+)
+
+// srkTemplate is the template of the ECC storage root key used as the parent of
+// the key blobs. It is the same one used by the tpm2-tss-engine and the
+// tpm2-openssl provider, so the keys can be shared with those tools. Unlike the
+// TCG template, the unique field is empty.
+var srkTemplate = tpm2.TPMTPublic{
+	Type:    tpm2.TPMAlgECC,
+	NameAlg: tpm2.TPMAlgSHA256,
+	ObjectAttributes: tpm2.TPMAObject{
+		FixedTPM:            true,
+		FixedParent:         true,
+		SensitiveDataOrigin: true,
+		UserWithAuth:        true,
+		NoDA:                true,
+		Restricted:          true,
+		Decrypt:             true,
+	},
+	Parameters: tpm2.NewTPMUPublicParms(tpm2.TPMAlgECC, &tpm2.TPMSECCParms{
+		Symmetric: tpm2.TPMTSymDefObject{
+			Algorithm: tpm2.TPMAlgAES,
+			KeyBits:   tpm2.NewTPMUSymKeyBits(tpm2.TPMAlgAES, tpm2.TPMKeyBits(128)),
+			Mode:      tpm2.NewTPMUSymMode(tpm2.TPMAlgAES, tpm2.TPMAlgCFB),
+		},
+		CurveID: tpm2.TPMECCNistP256,
+	}),
+	Unique: tpm2.NewTPMUPublicID(tpm2.TPMAlgECC, &tpm2.TPMSECCPoint{}),
+}
+
+// newECCSigningTemplate returns the template of an unrestricted ECC signing
+// key. The scheme is not set, so it can be defined on each signature.
+func newECCSigningTemplate(curve tpm2.TPMECCCurve) tpm2.TPMTPublic {
+	return tpm2.TPMTPublic{
+		Type:             tpm2.TPMAlgECC,
+		NameAlg:          tpm2.TPMAlgSHA256,
+		ObjectAttributes: signingAttributes,
+		Parameters: tpm2.NewTPMUPublicParms(tpm2.TPMAlgECC, &tpm2.TPMSECCParms{
+			CurveID: curve,
+		}),
+		Unique: tpm2.NewTPMUPublicID(tpm2.TPMAlgECC, &tpm2.TPMSECCPoint{}),
+	}
+}
+
+// newRSASigningTemplate returns the template of an unrestricted RSA signing
+// key. The scheme is not set, so it can be defined on each signature.
+func newRSASigningTemplate(bits tpm2.TPMKeyBits) tpm2.TPMTPublic {
+	return tpm2.TPMTPublic{
+		Type:             tpm2.TPMAlgRSA,
+		NameAlg:          tpm2.TPMAlgSHA256,
+		ObjectAttributes: signingAttributes,
+		Parameters: tpm2.NewTPMUPublicParms(tpm2.TPMAlgRSA, &tpm2.TPMSRSAParms{
+			KeyBits: bits,
+		}),
+		Unique: tpm2.NewTPMUPublicID(tpm2.TPMAlgRSA, &tpm2.TPM2BPublicKeyRSA{}),
+	}
+}
+
+// signingAttributes are the attributes of the signing keys.
+var signingAttributes = tpm2.TPMAObject{
+	FixedTPM:            true,
+	FixedParent:         true,
+	SensitiveDataOrigin: true,
+	UserWithAuth:        true,
+	NoDA:                true,
+	SignEncrypt:         true,
+}
+
+// tpmConn is a connection to a TPM device or simulator.
+type tpmConn struct {
+	transport.TPM
+	io.Closer
+}
+
+// newTPMConn returns a transport.TPMCloser that sends the commands using the
+// given connection.
+func newTPMConn(rwc io.ReadWriteCloser) transport.TPMCloser {
+	return &tpmConn{
+		TPM:    transport.FromReadWriter(rwc),
+		Closer: rwc,
+	}
+}
+
+// isHandleError returns true if the error is a TPM_RC_HANDLE error, returned
+// when an object does not exist.
+func isHandleError(err error) bool {
+	return errors.Is(err, tpm2.TPMRCHandle)
+}
+
+// authHandle returns the handle authorized with an empty password.
+func authHandle(h tpm2.NamedHandle) tpm2.AuthHandle {
+	return tpm2.AuthHandle{
+		Handle: h.Handle,
+		Name:   h.Name,
+		Auth:   tpm2.PasswordAuth(nil),
+	}
+}
+
+// createPrimary creates the storage root key in the owner hierarchy.
+func createPrimary(t transport.TPM) (tpm2.NamedHandle, error) {
+	resp, err := tpm2.CreatePrimary{
+		PrimaryHandle: tpm2.AuthHandle{
+			Handle: tpm2.TPMRHOwner,
+			Auth:   tpm2.PasswordAuth(nil),
+		},
+		InPublic: tpm2.New2B(srkTemplate),
+	}.Execute(t)
+	if err != nil {
+		return tpm2.NamedHandle{}, errors.Wrap(err, "tpm CreatePrimary failed")
+	}
+	return tpm2.NamedHandle{
+		Handle: resp.ObjectHandle,
+		Name:   resp.Name,
+	}, nil
+}
+
+// load loads a key under the given parent.
+func load(t transport.TPM, parent tpm2.NamedHandle, private tpm2.TPM2BPrivate, public tpm2.TPM2BPublic) (tpm2.NamedHandle, error) {
+	resp, err := tpm2.Load{
+		ParentHandle: authHandle(parent),
+		InPrivate:    private,
+		InPublic:     public,
+	}.Execute(t)
+	if err != nil {
+		return tpm2.NamedHandle{}, errors.Wrap(err, "tpm Load failed")
+	}
+	return tpm2.NamedHandle{
+		Handle: resp.ObjectHandle,
+		Name:   resp.Name,
+	}, nil
+}
+
+// readPublic returns the public area and the name of a loaded or persistent
+// key.
+func readPublic(t transport.TPM, handle tpm2.TPMHandle) (*tpm2.TPMTPublic, tpm2.TPM2BName, error) {
+	resp, err := tpm2.ReadPublic{
+		ObjectHandle: handle,
+	}.Execute(t)
+	if err != nil {
+		return nil, tpm2.TPM2BName{}, errors.Wrap(err, "tpm ReadPublic failed")
+	}
+	pub, err := resp.OutPublic.Contents()
+	if err != nil {
+		return nil, tpm2.TPM2BName{}, errors.Wrap(err, "tpm ReadPublic failed")
+	}
+	return pub, resp.Name, nil
+}
+
+// flushContext removes a transient object from the TPM.
+func flushContext(t transport.TPM, h tpm2.NamedHandle) error {
+	if _, err := (tpm2.FlushContext{FlushHandle: h.Handle}).Execute(t); err != nil {
+		return errors.Wrap(err, "tpm FlushContext failed")
+	}
+	return nil
+}
+
+// publicKey returns the crypto.PublicKey of a TPMT_PUBLIC structure with an RSA
+// or an ECC key.
+func publicKey(pub *tpm2.TPMTPublic) (crypto.PublicKey, error) {
+	switch pub.Type {
+	case tpm2.TPMAlgRSA:
+		params, err := pub.Parameters.RSADetail()
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing tpm public area")
+		}
+		unique, err := pub.Unique.RSA()
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing tpm public area")
+		}
+		return tpm2.RSAPub(params, unique)
+	case tpm2.TPMAlgECC:
+		params, err := pub.Parameters.ECCDetail()
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing tpm public area")
+		}
+		unique, err := pub.Unique.ECC()
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing tpm public area")
+		}
+		curve, err := params.CurveID.Curve()
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing tpm public area")
+		}
+		key := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(unique.X.Buffer),
+			Y:     new(big.Int).SetBytes(unique.Y.Buffer),
+		}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("tpm key is not on the curve")
+		}
+		return key, nil
+	default:
+		return nil, errors.Errorf("unsupported tpm key type %#04x", pub.Type)
+	}
+}
+
+// sign signs a digest with the given scheme, and returns the signature in the
+// format used by Go.
+func sign(t transport.TPM, key tpm2.NamedHandle, digest []byte, scheme tpm2.TPMTSigScheme) ([]byte, error) {
+	resp, err := tpm2.Sign{
+		KeyHandle: authHandle(key),
+		Digest:    tpm2.TPM2BDigest{Buffer: digest},
+		InScheme:  scheme,
+		// Null ticket, only valid for unrestricted keys.
+		Validation: tpm2.TPMTTKHashCheck{
+			Tag: tpm2.TPMSTHashCheck,
+		},
+	}.Execute(t)
+	if err != nil {
+		return nil, errors.Wrap(err, "tpm Sign failed")
+	}
+
+	switch sig := resp.Signature; sig.SigAlg {
+	case tpm2.TPMAlgRSASSA:
+		rsassa, err := sig.Signature.RSASSA()
+		if err != nil {
+			return nil, errors.Wrap(err, "tpm Sign failed")
+		}
+		return rsassa.Sig.Buffer, nil
+	case tpm2.TPMAlgRSAPSS:
+		rsapss, err := sig.Signature.RSAPSS()
+		if err != nil {
+			return nil, errors.Wrap(err, "tpm Sign failed")
+		}
+		return rsapss.Sig.Buffer, nil
+	case tpm2.TPMAlgECDSA:
+		ecc, err := sig.Signature.ECDSA()
+		if err != nil {
+			return nil, errors.Wrap(err, "tpm Sign failed")
+		}
+		return asn1.Marshal(struct {
+			R, S *big.Int
+		}{
+			new(big.Int).SetBytes(ecc.SignatureR.Buffer),
+			new(big.Int).SetBytes(ecc.SignatureS.Buffer),
+		})
+	default:
+		return nil, errors.Errorf("tpm Sign failed: unsupported signature algorithm %#04x", sig.SigAlg)
+	}
+}
@@ -0,0 +1,437 @@
+package tpmkms
+
+import (
+	"context"
+	"crypto"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/google/go-tpm/tpm2"
+	"github.com/google/go-tpm/tpm2/transport"
+	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/kms/apiv1"
+	"github.com/smallstep/certificates/kms/uri"
+)
+
+func init() {
+	apiv1.Register(apiv1.TPMKMS, func(ctx context.Context, opts apiv1.Options) (apiv1.KeyManager, error) {
+		return New(ctx, opts)
+	})
+}
+
+// Scheme is the scheme used in the TPM KMS uris.
+const Scheme = "tpmkms"
+
+// DefaultDevice is the TPM device used by default, the in-kernel resource
+// manager of Linux.
+const DefaultDevice = "/dev/tpmrm0"
+
+// keyBlobPEMType is the PEM type of the key blobs, the format used by the
+// tpm2-tss-engine and the tpm2-openssl provider.
+const keyBlobPEMType = "TSS2 PRIVATE KEY"
+
+// oidLoadableKey is the type of the key blobs that can be loaded under a
+// parent key.
+var oidLoadableKey = asn1.ObjectIdentifier{2, 23, 133, 10, 1, 3}
+
+// keyBlob is the ASN.1 structure of the key blobs.
+type keyBlob struct {
+	Type       asn1.ObjectIdentifier
+	EmptyAuth  bool `asn1:"optional,explicit,tag:0"`
+	Parent     int64
+	PublicKey  []byte
+	PrivateKey []byte
+}
+
+// TPMKMS implements a KMS using the keys of a TPM 2.0.
+//
+// The keys can be persistent keys in the owner hierarchy, or key blobs created
+// under the storage root key of the TPM, a key that is re-created on every
+// use. The URI format used is the following:
+//
+//   - tpmkms:handle=0x81000100
+//   - tpmkms:blob=/path/to/key.tss
+//   - tpmkms:blob=/path/to/key.tss;device=/dev/tpmrm0
+//
+// The scheme is "tpmkms"; "handle" is the persistent handle of the key; "blob"
+// is the path of a key blob in the TSS2 PRIVATE KEY PEM format; "device" is an
+// optional parameter with the TPM device used, if it is not given the one in
+// the options will be used.
+//
+// The device can be a character device, like /dev/tpmrm0, or the address of a
+// TPM simulator, like tcp://localhost:2321. A new connection is opened for
+// each operation, as the resource manager flushes the objects loaded by a
+// connection when it is closed.
+type TPMKMS struct {
+	device string
+}
+
+// New creates a new TPM KMS. The device can be configured using an URI like
+// "tpmkms:device=/dev/tpmrm0" in the options, by default /dev/tpmrm0 is used.
+func New(ctx context.Context, opts apiv1.Options) (*TPMKMS, error) {
+	device := DefaultDevice
+	if opts.URI != "" {
+		u, err := uri.ParseWithScheme(Scheme, opts.URI)
+		if err != nil {
+			return nil, err
+		}
+		if v := u.Get("device"); v != "" {
+			device = v
+		}
+	}
+
+	return &TPMKMS{
+		device: device,
+	}, nil
+}
+
+// GetPublicKey returns the public key of a persistent key or a key blob.
+func (k *TPMKMS) GetPublicKey(req *apiv1.GetPublicKeyRequest) (crypto.PublicKey, error) {
+	if req.Name == "" {
+		return nil, errors.New("getPublicKeyRequest 'name' cannot be empty")
+	}
+
+	key, err := k.parseKeyName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return key.readPublic()
+}
+
+// CreateKey creates a new signing key in the TPM. If the name is a handle, the
+// key will be made persistent in that handle, if it is a blob, the key blob
+// will be written in the given path. It fails if the handle or the file
+// already exist.
+func (k *TPMKMS) CreateKey(req *apiv1.CreateKeyRequest) (*apiv1.CreateKeyResponse, error) {
+	if req.Name == "" {
+		return nil, errors.New("createKeyRequest 'name' cannot be empty")
+	}
+
+	key, err := k.parseKeyName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	template, err := getSigningTemplate(req.SignatureAlgorithm, req.Bits)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, err := key.create(template)
+	if err != nil {
+		return nil, err
+	}
+
+	return &apiv1.CreateKeyResponse{
+		Name:      req.Name,
+		PublicKey: pub,
+		CreateSignerRequest: apiv1.CreateSignerRequest{
+			SigningKey: req.Name,
+		},
+	}, nil
+}
+
+// CreateSigner returns a crypto.Signer using a persistent key or a key blob.
+func (k *TPMKMS) CreateSigner(req *apiv1.CreateSignerRequest) (crypto.Signer, error) {
+	if req.SigningKey == "" {
+		return nil, errors.New("createSignerRequest 'signingKey' cannot be empty")
+	}
+
+	key, err := k.parseKeyName(req.SigningKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSigner(key)
+}
+
+// Close is a noop, the connections to the TPM are closed after each
+// operation.
+func (k *TPMKMS) Close() error {
+	return nil
+}
+
+// tpmKey represents a persistent key or a key blob in a TPM device.
+type tpmKey struct {
+	device string
+	handle uint32
+	blob   string
+}
+
+// parseKeyName returns the key represented by URIs like:
+//
+//   - tpmkms:handle=0x81000100
+//   - tpmkms:blob=/path/to/key.tss
+//
+// The device of the KMS is used if the URI does not set one.
+func (k *TPMKMS) parseKeyName(rawURI string) (*tpmKey, error) {
+	u, err := uri.ParseTPMKey(Scheme, rawURI)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &tpmKey{
+		device: k.device,
+		handle: u.Handle,
+		blob:   u.Blob,
+	}
+	if u.Device != "" {
+		key.device = u.Device
+	}
+	return key, nil
+}
+
+// openTPM opens a new connection to the TPM in the given device, a character
+// device or the tcp:// address of a simulator. It is a variable so it can be
+// replaced in tests.
+var openTPM = func(device string) (transport.TPMCloser, error) {
+	if strings.HasPrefix(device, "tcp://") {
+		conn, err := net.Dial("tcp", strings.TrimPrefix(device, "tcp://"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "error connecting to %s", device)
+		}
+		return newTPMConn(conn), nil
+	}
+	f, err := os.OpenFile(device, os.O_RDWR, 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening %s", device)
+	}
+	return newTPMConn(f), nil
+}
+
+// do opens a connection to the TPM, loads the key and runs the given function
+// with the handle of the key.
+func (k *tpmKey) do(fn func(t transport.TPM, key tpm2.NamedHandle) error) error {
+	t, err := openTPM(k.device)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+
+	if k.blob == "" {
+		// The name of the key is required to authorize its use.
+		_, name, err := readPublic(t, tpm2.TPMHandle(k.handle))
+		if err != nil {
+			if isHandleError(err) {
+				return errors.Errorf("tpm key %#x does not exist", k.handle)
+			}
+			return err
+		}
+		return fn(t, tpm2.NamedHandle{
+			Handle: tpm2.TPMHandle(k.handle),
+			Name:   name,
+		})
+	}
+
+	blob, err := readKeyBlob(k.blob)
+	if err != nil {
+		return err
+	}
+	srk, err := createPrimary(t)
+	if err != nil {
+		return err
+	}
+	defer flushContext(t, srk)
+
+	key, err := load(t, srk, blob.private, blob.public)
+	if err != nil {
+		return err
+	}
+	defer flushContext(t, key)
+
+	return fn(t, key)
+}
+
+// readPublic returns the public key. The public area of key blobs is read from
+// the file.
+func (k *tpmKey) readPublic() (crypto.PublicKey, error) {
+	if k.blob != "" {
+		blob, err := readKeyBlob(k.blob)
+		if err != nil {
+			return nil, err
+		}
+		pub, err := blob.public.Contents()
+		if err != nil {
+			return nil, errors.Wrapf(err, "error decoding %s", k.blob)
+		}
+		return publicKey(pub)
+	}
+
+	var pub *tpm2.TPMTPublic
+	err := k.do(func(t transport.TPM, key tpm2.NamedHandle) (err error) {
+		pub, _, err = readPublic(t, key.Handle)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return publicKey(pub)
+}
+
+// create creates a new key with the given template and stores it in the
+// persistent handle or in the blob file.
+func (k *tpmKey) create(template tpm2.TPMTPublic) (crypto.PublicKey, error) {
+	if k.blob != "" {
+		if _, err := os.Stat(k.blob); err == nil {
+			return nil, apiv1.ErrAlreadyExists{
+				Message: "tpm key blob " + k.blob + " already exists",
+			}
+		}
+	}
+
+	t, err := openTPM(k.device)
+	if err != nil {
+		return nil, err
+	}
+	defer t.Close()
+
+	if k.blob == "" {
+		_, _, err := readPublic(t, tpm2.TPMHandle(k.handle))
+		switch {
+		case err == nil:
+			return nil, apiv1.ErrAlreadyExists{
+				Message: fmt.Sprintf("tpm key %#x already exists", k.handle),
+			}
+		case !isHandleError(err):
+			return nil, err
+		}
+	}
+
+	srk, err := createPrimary(t)
+	if err != nil {
+		return nil, err
+	}
+	defer flushContext(t, srk)
+
+	resp, err := tpm2.Create{
+		ParentHandle: authHandle(srk),
+		InPublic:     tpm2.New2B(template),
+	}.Execute(t)
+	if err != nil {
+		return nil, errors.Wrap(err, "tpm Create failed")
+	}
+	pub, err := resp.OutPublic.Contents()
+	if err != nil {
+		return nil, errors.Wrap(err, "tpm Create failed")
+	}
+	key, err := publicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	if k.blob != "" {
+		if err := writeKeyBlob(k.blob, resp.OutPrivate, resp.OutPublic); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+
+	handle, err := load(t, srk, resp.OutPrivate, resp.OutPublic)
+	if err != nil {
+		return nil, err
+	}
+	defer flushContext(t, handle)
+
+	if _, err := (tpm2.EvictControl{
+		Auth: tpm2.AuthHandle{
+			Handle: tpm2.TPMRHOwner,
+			Auth:   tpm2.PasswordAuth(nil),
+		},
+		ObjectHandle:     handle,
+		PersistentHandle: tpm2.TPMHandle(k.handle),
+	}).Execute(t); err != nil {
+		return nil, errors.Wrap(err, "tpm EvictControl failed")
+	}
+	return key, nil
+}
+
+// getSigningTemplate returns the template of a signing key for the given
+// signature algorithm and bits.
+func getSigningTemplate(alg apiv1.SignatureAlgorithm, bits int) (tpm2.TPMTPublic, error) {
+	switch alg {
+	case apiv1.UnspecifiedSignAlgorithm, apiv1.ECDSAWithSHA256:
+		return newECCSigningTemplate(tpm2.TPMECCNistP256), nil
+	case apiv1.ECDSAWithSHA384:
+		return newECCSigningTemplate(tpm2.TPMECCNistP384), nil
+	case apiv1.ECDSAWithSHA512:
+		return newECCSigningTemplate(tpm2.TPMECCNistP521), nil
+	case apiv1.SHA256WithRSA, apiv1.SHA384WithRSA, apiv1.SHA512WithRSA,
+		apiv1.SHA256WithRSAPSS, apiv1.SHA384WithRSAPSS, apiv1.SHA512WithRSAPSS:
+		switch bits {
+		case 0, 2048:
+			return newRSASigningTemplate(2048), nil
+		case 3072:
+			return newRSASigningTemplate(3072), nil
+		default:
+			return tpm2.TPMTPublic{}, errors.Errorf("tpmKMS does not support signature algorithm '%s' with '%d' bits", alg, bits)
+		}
+	default:
+		return tpm2.TPMTPublic{}, errors.Errorf("tpmKMS does not support signature algorithm '%s'", alg)
+	}
+}
+
+// tpmKeyBlob contains the decoded public and private areas of a key blob.
+type tpmKeyBlob struct {
+	public  tpm2.TPM2BPublic
+	private tpm2.TPM2BPrivate
+}
+
+// readKeyBlob reads a key blob in the TSS2 PRIVATE KEY format.
+func readKeyBlob(filename string) (*tpmKeyBlob, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading %s", filename)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != keyBlobPEMType {
+		return nil, errors.Errorf("error decoding %s: not a valid tpm key blob", filename)
+	}
+	var blob keyBlob
+	if _, err := asn1.Unmarshal(block.Bytes, &blob); err != nil {
+		return nil, errors.Wrapf(err, "error decoding %s", filename)
+	}
+	if !blob.Type.Equal(oidLoadableKey) {
+		return nil, errors.Errorf("error decoding %s: unsupported key type %s", filename, blob.Type)
+	}
+	if blob.Parent != int64(tpm2.TPMRHOwner) {
+		return nil, errors.Errorf("error decoding %s: unsupported parent %#x", filename, blob.Parent)
+	}
+	public, err := tpm2.Unmarshal[tpm2.TPM2BPublic](blob.PublicKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error decoding %s", filename)
+	}
+	private, err := tpm2.Unmarshal[tpm2.TPM2BPrivate](blob.PrivateKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error decoding %s", filename)
+	}
+	return &tpmKeyBlob{
+		public:  *public,
+		private: *private,
+	}, nil
+}
+
+// writeKeyBlob writes a key blob in the TSS2 PRIVATE KEY format.
+func writeKeyBlob(filename string, private tpm2.TPM2BPrivate, public tpm2.TPM2BPublic) error {
+	b, err := asn1.Marshal(keyBlob{
+		Type:       oidLoadableKey,
+		EmptyAuth:  true,
+		Parent:     int64(tpm2.TPMRHOwner),
+		PublicKey:  tpm2.Marshal(public),
+		PrivateKey: tpm2.Marshal(private),
+	})
+	if err != nil {
+		return errors.Wrap(err, "error encoding tpm key blob")
+	}
+	if err := os.WriteFile(filename, pem.EncodeToMemory(&pem.Block{
+		Type:  keyBlobPEMType,
+		Bytes: b,
+	}), 0600); err != nil {
+		return errors.Wrapf(err, "error writing %s", filename)
+	}
+	return nil
+}
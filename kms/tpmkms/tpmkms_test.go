@@ -0,0 +1,234 @@
+package tpmkms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/smallstep/certificates/kms/apiv1"
+)
+
+func TestRegister(t *testing.T) {
+	fn, ok := apiv1.LoadKeyManagerNewFunc(apiv1.TPMKMS)
+	if !ok {
+		t.Fatal("apiv1.LoadKeyManagerNewFunc() ok = false, want true")
+	}
+	if _, err := fn(context.Background(), apiv1.Options{Type: "tpmkms"}); err != nil {
+		t.Errorf("New() error = %v", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    apiv1.Options
+		want    *TPMKMS
+		wantErr bool
+	}{
+		{"ok", apiv1.Options{}, &TPMKMS{device: "/dev/tpmrm0"}, false},
+		{"ok with device", apiv1.Options{URI: "tpmkms:device=/dev/tpm0"}, &TPMKMS{device: "/dev/tpm0"}, false},
+		{"ok with simulator", apiv1.Options{URI: "tpmkms:device=tcp://localhost:2321"}, &TPMKMS{device: "tcp://localhost:2321"}, false},
+		{"fail uri", apiv1.Options{URI: "pkcs11:device=/dev/tpm0"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(context.Background(), tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("New() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTPMKMS_CreateKey(t *testing.T) {
+	newMockTPM(t)
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.tss")
+	if err := os.WriteFile(existing, []byte("foo"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	k := &TPMKMS{device: DefaultDevice}
+	if _, err := k.CreateKey(&apiv1.CreateKeyRequest{Name: "tpmkms:handle=0x81000002"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		req      *apiv1.CreateKeyRequest
+		wantType interface{}
+		wantErr  bool
+	}{
+		{"ok handle", &apiv1.CreateKeyRequest{Name: "tpmkms:handle=0x81000001"}, &ecdsa.PublicKey{}, false},
+		{"ok blob", &apiv1.CreateKeyRequest{Name: "tpmkms:blob=" + filepath.Join(dir, "ec.tss"), SignatureAlgorithm: apiv1.ECDSAWithSHA384}, &ecdsa.PublicKey{}, false},
+		{"ok rsa", &apiv1.CreateKeyRequest{Name: "tpmkms:blob=" + filepath.Join(dir, "rsa.tss"), SignatureAlgorithm: apiv1.SHA256WithRSA}, &rsa.PublicKey{}, false},
+		{"fail empty", &apiv1.CreateKeyRequest{Name: ""}, nil, true},
+		{"fail name", &apiv1.CreateKeyRequest{Name: "tpmkms:device=/dev/tpmrm0"}, nil, true},
+		{"fail algorithm", &apiv1.CreateKeyRequest{Name: "tpmkms:handle=0x81000003", SignatureAlgorithm: apiv1.PureEd25519}, nil, true},
+		{"fail bits", &apiv1.CreateKeyRequest{Name: "tpmkms:handle=0x81000003", SignatureAlgorithm: apiv1.SHA256WithRSA, Bits: 4096}, nil, true},
+		{"fail handle exists", &apiv1.CreateKeyRequest{Name: "tpmkms:handle=0x81000002"}, nil, true},
+		{"fail blob exists", &apiv1.CreateKeyRequest{Name: "tpmkms:blob=" + existing}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := k.CreateKey(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("TPMKMS.CreateKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if got.Name != tt.req.Name || got.CreateSignerRequest.SigningKey != tt.req.Name {
+				t.Errorf("TPMKMS.CreateKey() name = %v, want %v", got.Name, tt.req.Name)
+			}
+			if reflect.TypeOf(got.PublicKey) != reflect.TypeOf(tt.wantType) {
+				t.Errorf("TPMKMS.CreateKey() publicKey = %T, want %T", got.PublicKey, tt.wantType)
+			}
+			pub, err := k.GetPublicKey(&apiv1.GetPublicKeyRequest{Name: tt.req.Name})
+			if err != nil {
+				t.Errorf("TPMKMS.GetPublicKey() error = %v", err)
+			} else if !reflect.DeepEqual(pub, got.PublicKey) {
+				t.Errorf("TPMKMS.GetPublicKey() = %v, want %v", pub, got.PublicKey)
+			}
+		})
+	}
+
+	if _, ok := interface{}(k).(apiv1.KeyManager); !ok {
+		t.Error("TPMKMS does not implement apiv1.KeyManager")
+	}
+}
+
+func TestTPMKMS_GetPublicKey(t *testing.T) {
+	newMockTPM(t)
+	dir := t.TempDir()
+	blob := filepath.Join(dir, "key.tss")
+	invalid := filepath.Join(dir, "invalid.tss")
+	if err := os.WriteFile(invalid, []byte("-----BEGIN TSS2 PRIVATE KEY-----\nZm9v\n-----END TSS2 PRIVATE KEY-----\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	k := &TPMKMS{device: DefaultDevice}
+	handleKey, err := k.CreateKey(&apiv1.CreateKeyRequest{Name: "tpmkms:handle=0x81000001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	blobKey, err := k.CreateKey(&apiv1.CreateKeyRequest{Name: "tpmkms:blob=" + blob})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		req     *apiv1.GetPublicKeyRequest
+		want    crypto.PublicKey
+		wantErr bool
+	}{
+		{"ok handle", &apiv1.GetPublicKeyRequest{Name: "tpmkms:handle=0x81000001"}, handleKey.PublicKey, false},
+		{"ok handle decimal", &apiv1.GetPublicKeyRequest{Name: "tpmkms:handle=2164260865"}, handleKey.PublicKey, false},
+		{"ok blob", &apiv1.GetPublicKeyRequest{Name: "tpmkms:blob=" + blob}, blobKey.PublicKey, false},
+		{"fail empty", &apiv1.GetPublicKeyRequest{Name: ""}, nil, true},
+		{"fail missing handle", &apiv1.GetPublicKeyRequest{Name: "tpmkms:handle=0x81000002"}, nil, true},
+		{"fail missing blob", &apiv1.GetPublicKeyRequest{Name: "tpmkms:blob=" + filepath.Join(dir, "missing.tss")}, nil, true},
+		{"fail invalid blob", &apiv1.GetPublicKeyRequest{Name: "tpmkms:blob=" + invalid}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := k.GetPublicKey(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("TPMKMS.GetPublicKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TPMKMS.GetPublicKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTPMKMS_CreateSigner(t *testing.T) {
+	m := newMockTPM(t)
+	dir := t.TempDir()
+	blob := filepath.Join(dir, "key.tss")
+
+	k := &TPMKMS{device: DefaultDevice}
+	handleKey, err := k.CreateKey(&apiv1.CreateKeyRequest{Name: "tpmkms:handle=0x81000001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	blobKey, err := k.CreateKey(&apiv1.CreateKeyRequest{Name: "tpmkms:blob=" + blob})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		req     *apiv1.CreateSignerRequest
+		want    crypto.PublicKey
+		wantErr bool
+	}{
+		{"ok handle", &apiv1.CreateSignerRequest{SigningKey: "tpmkms:handle=0x81000001"}, handleKey.PublicKey, false},
+		{"ok blob", &apiv1.CreateSignerRequest{SigningKey: "tpmkms:blob=" + blob}, blobKey.PublicKey, false},
+		{"fail empty", &apiv1.CreateSignerRequest{SigningKey: ""}, nil, true},
+		{"fail missing", &apiv1.CreateSignerRequest{SigningKey: "tpmkms:handle=0x81000002"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := k.CreateSigner(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("TPMKMS.CreateSigner() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && !reflect.DeepEqual(got.Public(), tt.want) {
+				t.Errorf("TPMKMS.CreateSigner() public = %v, want %v", got.Public(), tt.want)
+			}
+		})
+	}
+
+	// The transient objects are flushed.
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.objects) != 1 {
+		t.Errorf("TPMKMS.CreateSigner() objects = %d, want 1", len(m.objects))
+	}
+}
+
+func TestTPMKMS_parseKeyName(t *testing.T) {
+	k := &TPMKMS{device: DefaultDevice}
+	tests := []struct {
+		name    string
+		rawURI  string
+		want    *tpmKey
+		wantErr bool
+	}{
+		{"ok handle", "tpmkms:handle=0x81000001", &tpmKey{device: DefaultDevice, handle: 0x81000001}, false},
+		{"ok blob", "tpmkms:blob=/path/to/key.tss", &tpmKey{device: DefaultDevice, blob: "/path/to/key.tss"}, false},
+		{"ok device", "tpmkms:blob=key.tss;device=tcp://localhost:2321", &tpmKey{device: "tcp://localhost:2321", blob: "key.tss"}, false},
+		{"fail scheme", "pkcs11:handle=0x81000001", nil, true},
+		{"fail missing", "tpmkms:device=/dev/tpmrm0", nil, true},
+		{"fail both", "tpmkms:handle=0x81000001;blob=key.tss", nil, true},
+		{"fail handle", "tpmkms:handle=foo", nil, true},
+		{"fail transient handle", "tpmkms:handle=0x80000001", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := k.parseKeyName(tt.rawURI)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("TPMKMS.parseKeyName() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TPMKMS.parseKeyName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
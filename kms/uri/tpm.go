@@ -0,0 +1,60 @@
+package uri
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// Persistent handles in the owner hierarchy of a TPM 2.0.
+const (
+	minTPMPersistentHandle uint32 = 0x81000000
+	maxTPMPersistentHandle uint32 = 0x81FFFFFF
+)
+
+// TPMKey is a key in a TPM 2.0, a persistent key in the owner hierarchy or a
+// key blob created under the storage root key.
+type TPMKey struct {
+	Device string
+	Handle uint32
+	Blob   string
+}
+
+// ParseTPMKey returns the TPM key represented by URIs with the given scheme,
+// like:
+//
+//   - tpmkms:handle=0x81000100
+//   - tpmkms:blob=/path/to/key.tss
+//   - tpmkms:blob=/path/to/key.tss;device=/dev/tpmrm0
+//
+// Only one of handle or blob can be set, and the handle must be a persistent
+// handle. The device is optional.
+func ParseTPMKey(scheme, rawuri string) (*TPMKey, error) {
+	u, err := ParseWithScheme(scheme, rawuri)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &TPMKey{
+		Device: u.Get("device"),
+		Blob:   u.Get("blob"),
+	}
+
+	handle := u.Get("handle")
+	switch {
+	case handle == "" && key.Blob == "":
+		return nil, errors.Errorf("key uri %s is not valid: handle or blob are required", rawuri)
+	case handle != "" && key.Blob != "":
+		return nil, errors.Errorf("key uri %s is not valid: handle and blob cannot be used together", rawuri)
+	case handle != "":
+		h, err := strconv.ParseUint(handle, 0, 32)
+		if err != nil {
+			return nil, errors.Errorf("key uri %s is not valid: handle is not a number", rawuri)
+		}
+		if key.Handle = uint32(h); key.Handle < minTPMPersistentHandle || key.Handle > maxTPMPersistentHandle {
+			return nil, errors.Errorf("key uri %s is not valid: handle is not a persistent handle", rawuri)
+		}
+	}
+
+	return key, nil
+}
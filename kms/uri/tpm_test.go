@@ -0,0 +1,42 @@
+package uri
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTPMKey(t *testing.T) {
+	type args struct {
+		scheme string
+		rawuri string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    *TPMKey
+		wantErr bool
+	}{
+		{"ok handle", args{"tpmkms", "tpmkms:handle=0x81000001"}, &TPMKey{Handle: 0x81000001}, false},
+		{"ok decimal handle", args{"tpmkms", "tpmkms:handle=2164260865"}, &TPMKey{Handle: 0x81000001}, false},
+		{"ok blob", args{"tpmkms", "tpmkms:blob=/path/to/key.tss"}, &TPMKey{Blob: "/path/to/key.tss"}, false},
+		{"ok device", args{"tpmkms", "tpmkms:blob=key.tss;device=tcp://localhost:2321"}, &TPMKey{Device: "tcp://localhost:2321", Blob: "key.tss"}, false},
+		{"fail scheme", args{"tpmkms", "pkcs11:handle=0x81000001"}, nil, true},
+		{"fail missing", args{"tpmkms", "tpmkms:device=/dev/tpmrm0"}, nil, true},
+		{"fail both", args{"tpmkms", "tpmkms:handle=0x81000001;blob=key.tss"}, nil, true},
+		{"fail handle", args{"tpmkms", "tpmkms:handle=foo"}, nil, true},
+		{"fail transient handle", args{"tpmkms", "tpmkms:handle=0x80000001"}, nil, true},
+		{"fail handle out of range", args{"tpmkms", "tpmkms:handle=0x82000000"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTPMKey(tt.args.scheme, tt.args.rawuri)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseTPMKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTPMKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package vaultkms
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/pkg/errors"
+)
+
+// Signer implements a crypto.Signer using a key in the transit secrets engine.
+type Signer struct {
+	client    *vault.Client
+	mount     string
+	name      string
+	version   int
+	publicKey crypto.PublicKey
+}
+
+// NewSigner creates a new signer using the latest version of a transit key.
+func NewSigner(client *vault.Client, mount, name string) (*Signer, error) {
+	pub, version, err := preloadKey(client, mount, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Signer{
+		client:    client,
+		mount:     mount,
+		name:      name,
+		version:   version,
+		publicKey: pub,
+	}, nil
+}
+
+// Public returns the public key of this signer.
+func (s *Signer) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// Sign signs the digest with the transit key. Ed25519 keys sign the full
+// message, as they do not support prehashed inputs.
+func (s *Signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	path, data, err := getSignRequest(s.publicKey, digest, opts)
+	if err != nil {
+		return nil, err
+	}
+	data["key_version"] = s.version
+
+	secret, err := s.client.Logical().Write(s.mount+"/sign/"+s.name+path, data)
+	if err != nil {
+		return nil, errors.Wrap(err, "vaultKMS sign failed")
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, errors.New("vaultKMS sign failed: response is empty")
+	}
+
+	signature, ok := secret.Data["signature"].(string)
+	if !ok {
+		return nil, errors.New("vaultKMS sign failed: signature is missing")
+	}
+	return decodeCiphertext(signature)
+}
+
+// getSignRequest returns the suffix of the sign path and the parameters used
+// to sign with a key of the given type.
+func getSignRequest(key crypto.PublicKey, digest []byte, opts crypto.SignerOpts) (string, map[string]interface{}, error) {
+	data := map[string]interface{}{
+		"input": base64.StdEncoding.EncodeToString(digest),
+	}
+
+	switch key.(type) {
+	case ed25519.PublicKey:
+		if h := opts.HashFunc(); h != 0 {
+			return "", nil, errors.Errorf("unsupported hash function %v", h)
+		}
+		return "", data, nil
+	case *ecdsa.PublicKey:
+		data["prehashed"] = true
+		data["marshaling_algorithm"] = "asn1"
+	case *rsa.PublicKey:
+		data["prehashed"] = true
+		if o, ok := opts.(*rsa.PSSOptions); ok {
+			data["signature_algorithm"] = "pss"
+			switch o.SaltLength {
+			case rsa.PSSSaltLengthAuto:
+				data["salt_length"] = "auto"
+			case rsa.PSSSaltLengthEqualsHash:
+				data["salt_length"] = "hash"
+			default:
+				data["salt_length"] = o.SaltLength
+			}
+		} else {
+			data["signature_algorithm"] = "pkcs1v15"
+		}
+	default:
+		return "", nil, errors.Errorf("unsupported key type %T", key)
+	}
+
+	switch h := opts.HashFunc(); h {
+	case crypto.SHA256:
+		return "/sha2-256", data, nil
+	case crypto.SHA384:
+		return "/sha2-384", data, nil
+	case crypto.SHA512:
+		return "/sha2-512", data, nil
+	default:
+		return "", nil, errors.Errorf("unsupported hash function %v", h)
+	}
+}
+
+// Decrypter implements a crypto.Decrypter using an RSA key in the transit
+// secrets engine.
+type Decrypter struct {
+	client    *vault.Client
+	mount     string
+	name      string
+	version   int
+	publicKey crypto.PublicKey
+}
+
+// NewDecrypter creates a new decrypter using the latest version of a transit
+// key.
+func NewDecrypter(client *vault.Client, mount, name string) (*Decrypter, error) {
+	pub, version, err := preloadKey(client, mount, name)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := pub.(*rsa.PublicKey); !ok {
+		return nil, errors.Errorf("vaultKMS key %s is not an RSA key", name)
+	}
+
+	return &Decrypter{
+		client:    client,
+		mount:     mount,
+		name:      name,
+		version:   version,
+		publicKey: pub,
+	}, nil
+}
+
+// Public returns the public key of this decrypter.
+func (d *Decrypter) Public() crypto.PublicKey {
+	return d.publicKey
+}
+
+// Decrypt decrypts msg with the transit key. Vault only supports RSA-OAEP with
+// SHA-256 and no label, or PKCS #1 v1.5 in the versions that support the
+// padding_scheme parameter.
+func (d *Decrypter) Decrypt(rand io.Reader, msg []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	data := map[string]interface{}{
+		"ciphertext": fmt.Sprintf("vault:v%d:%s", d.version, base64.StdEncoding.EncodeToString(msg)),
+	}
+
+	switch o := opts.(type) {
+	case nil, *rsa.PKCS1v15DecryptOptions:
+		data["padding_scheme"] = "pkcs1v15"
+	case *rsa.OAEPOptions:
+		if o.Hash != crypto.SHA256 || len(o.Label) > 0 {
+			return nil, errors.New("vaultKMS only supports RSA-OAEP with SHA-256 and no label")
+		}
+	default:
+		return nil, errors.Errorf("unsupported decrypter options %T", opts)
+	}
+
+	secret, err := d.client.Logical().Write(d.mount+"/decrypt/"+d.name, data)
+	if err != nil {
+		return nil, errors.Wrap(err, "vaultKMS decrypt failed")
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, errors.New("vaultKMS decrypt failed: response is empty")
+	}
+
+	plaintext, ok := secret.Data["plaintext"].(string)
+	if !ok {
+		return nil, errors.New("vaultKMS decrypt failed: plaintext is missing")
+	}
+	b, err := base64.StdEncoding.DecodeString(plaintext)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding vaultKMS plaintext")
+	}
+	return b, nil
+}
+
+// preloadKey returns the public key and the latest version of a transit key.
+func preloadKey(client *vault.Client, mount, name string) (crypto.PublicKey, int, error) {
+	key, err := readKey(client, mount, name)
+	if err != nil {
+		return nil, 0, err
+	}
+	if key == nil {
+		return nil, 0, errors.Errorf("vaultKMS key %s does not exist", name)
+	}
+	return key.publicKey()
+}
+
+// decodeCiphertext decodes values with the format "vault:v1:<base64>" used by
+// Vault on signatures and ciphertexts.
+func decodeCiphertext(s string) ([]byte, error) {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) != 3 || parts[0] != "vault" || !strings.HasPrefix(parts[1], "v") {
+		return nil, errors.Errorf("vaultKMS value %q is not valid", s)
+	}
+	b, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Wrap(err, "error decoding vaultKMS value")
+	}
+	return b, nil
+}
@@ -0,0 +1,155 @@
+package vaultkms
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"reflect"
+	"testing"
+
+	"github.com/smallstep/certificates/kms/apiv1"
+)
+
+func TestSigner_Sign(t *testing.T) {
+	k, tr := testKMSHelper(t)
+
+	message := []byte("message")
+	sum256 := sha256.Sum256(message)
+	sum384 := sha512.Sum384(message)
+
+	verify := func(t *testing.T, pub crypto.PublicKey, digest, sig []byte, opts crypto.SignerOpts) {
+		t.Helper()
+		var ok bool
+		switch pub := pub.(type) {
+		case *ecdsa.PublicKey:
+			ok = ecdsa.VerifyASN1(pub, digest, sig)
+		case *rsa.PublicKey:
+			if o, isPSS := opts.(*rsa.PSSOptions); isPSS {
+				ok = rsa.VerifyPSS(pub, o.Hash, digest, sig, o) == nil
+			} else {
+				ok = rsa.VerifyPKCS1v15(pub, opts.HashFunc(), digest, sig) == nil
+			}
+		case ed25519.PublicKey:
+			ok = ed25519.Verify(pub, digest, sig)
+		}
+		if !ok {
+			t.Error("Signer.Sign() signature is not valid")
+		}
+	}
+
+	tests := []struct {
+		name    string
+		key     string
+		digest  []byte
+		opts    crypto.SignerOpts
+		wantErr bool
+	}{
+		{"ok ec", "ec", sum256[:], crypto.SHA256, false},
+		{"ok ec sha384", "ec", sum384[:], crypto.SHA384, false},
+		{"ok rsa", "rsa", sum256[:], crypto.SHA256, false},
+		{"ok rsa pss", "rsa", sum256[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}, false},
+		{"ok ed25519", "ed25519", message, crypto.Hash(0), false},
+		{"fail hash", "ec", sum256[:], crypto.SHA1, true},
+		{"fail ed25519 hash", "ed25519", sum256[:], crypto.SHA256, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := k.CreateSigner(&apiv1.CreateSignerRequest{
+				SigningKey: "vaultkms:name=" + tt.key,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := signer.Sign(rand.Reader, tt.digest, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Signer.Sign() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				verify(t, tr.publicKey(tt.key), tt.digest, got, tt.opts)
+			}
+		})
+	}
+}
+
+func TestDecrypter_Decrypt(t *testing.T) {
+	k, tr := testKMSHelper(t)
+
+	pub := tr.publicKey("rsa").(*rsa.PublicKey)
+	message := []byte("message")
+	oaep, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, message, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs1, err := rsa.EncryptPKCS1v15(rand.Reader, pub, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decrypter, err := k.CreateDecrypter(&apiv1.CreateDecrypterRequest{
+		DecryptionKey: "vaultkms:name=rsa",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		msg     []byte
+		opts    crypto.DecrypterOpts
+		want    []byte
+		wantErr bool
+	}{
+		{"ok oaep", oaep, &rsa.OAEPOptions{Hash: crypto.SHA256}, message, false},
+		{"ok pkcs1v15", pkcs1, nil, message, false},
+		{"ok pkcs1v15 options", pkcs1, &rsa.PKCS1v15DecryptOptions{}, message, false},
+		{"fail oaep hash", oaep, &rsa.OAEPOptions{Hash: crypto.SHA1}, nil, true},
+		{"fail oaep label", oaep, &rsa.OAEPOptions{Hash: crypto.SHA256, Label: []byte("label")}, nil, true},
+		{"fail options", oaep, crypto.SHA256, nil, true},
+		{"fail decrypt", []byte("foo"), nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decrypter.Decrypt(rand.Reader, tt.msg, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Decrypter.Decrypt() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decrypter.Decrypt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_decodeCiphertext(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    []byte
+		wantErr bool
+	}{
+		{"ok", "vault:v1:bWVzc2FnZQ==", []byte("message"), false},
+		{"ok version", "vault:v12:bWVzc2FnZQ==", []byte("message"), false},
+		{"fail prefix", "other:v1:bWVzc2FnZQ==", nil, true},
+		{"fail version", "vault:1:bWVzc2FnZQ==", nil, true},
+		{"fail parts", "vault:v1", nil, true},
+		{"fail base64", "vault:v1:%%%", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCiphertext(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("decodeCiphertext() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeCiphertext() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,381 @@
+package vaultkms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/pkg/errors"
+	"github.com/smallstep/certificates/cas/vaultcas/vaultauth"
+	"github.com/smallstep/certificates/kms/apiv1"
+	"github.com/smallstep/certificates/kms/uri"
+	"go.step.sm/crypto/pemutil"
+)
+
+func init() {
+	apiv1.Register(apiv1.VaultKMS, func(ctx context.Context, opts apiv1.Options) (apiv1.KeyManager, error) {
+		return New(ctx, opts)
+	})
+}
+
+// Scheme is the scheme used in the Vault KMS uris.
+const Scheme = "vaultkms"
+
+// defaultMount is the default path where the transit secrets engine is
+// mounted.
+const defaultMount = "transit"
+
+// keyTypeMapping is a mapping between the step signature algorithm, and bits
+// for RSA keys, with the Vault Transit key types.
+var keyTypeMapping = map[apiv1.SignatureAlgorithm]interface{}{
+	apiv1.UnspecifiedSignAlgorithm: "ecdsa-p256",
+	apiv1.SHA256WithRSA: map[int]string{
+		0:    "rsa-3072",
+		2048: "rsa-2048",
+		3072: "rsa-3072",
+		4096: "rsa-4096",
+	},
+	apiv1.SHA384WithRSA: map[int]string{
+		0:    "rsa-3072",
+		2048: "rsa-2048",
+		3072: "rsa-3072",
+		4096: "rsa-4096",
+	},
+	apiv1.SHA512WithRSA: map[int]string{
+		0:    "rsa-4096",
+		2048: "rsa-2048",
+		3072: "rsa-3072",
+		4096: "rsa-4096",
+	},
+	apiv1.SHA256WithRSAPSS: map[int]string{
+		0:    "rsa-3072",
+		2048: "rsa-2048",
+		3072: "rsa-3072",
+		4096: "rsa-4096",
+	},
+	apiv1.SHA384WithRSAPSS: map[int]string{
+		0:    "rsa-3072",
+		2048: "rsa-2048",
+		3072: "rsa-3072",
+		4096: "rsa-4096",
+	},
+	apiv1.SHA512WithRSAPSS: map[int]string{
+		0:    "rsa-4096",
+		2048: "rsa-2048",
+		3072: "rsa-3072",
+		4096: "rsa-4096",
+	},
+	apiv1.ECDSAWithSHA256: "ecdsa-p256",
+	apiv1.ECDSAWithSHA384: "ecdsa-p384",
+	apiv1.ECDSAWithSHA512: "ecdsa-p521",
+	apiv1.PureEd25519:     "ed25519",
+}
+
+// Options are the options used to configure the Vault KMS. They are set using
+// the apiv1.Options.Config field. The Address defaults to the VAULT_ADDR
+// environment variable and the Mount to "transit".
+type Options struct {
+	Address string `json:"address,omitempty"`
+	Mount   string `json:"mount,omitempty"`
+	vaultauth.Options
+}
+
+// VaultKMS implements a KMS using the transit secrets engine of HashiCorp
+// Vault.
+//
+// The URI format used in the Vault KMS is the following:
+//
+//   - vaultkms:name=key-name
+//   - vaultkms:mount=transit;name=key-name
+//
+// The scheme is "vaultkms"; "name" is the name of the transit key; "mount" is
+// the path where the transit secrets engine is mounted, if it is not given the
+// one in the options will be used.
+type VaultKMS struct {
+	client *vault.Client
+	mount  string
+	auth   *vaultauth.Authenticator
+}
+
+// transitKey is the representation of a key returned by the transit secrets
+// engine.
+type transitKey struct {
+	Type          string `json:"type"`
+	LatestVersion int    `json:"latest_version"`
+	Keys          map[string]struct {
+		PublicKey string `json:"public_key"`
+	} `json:"keys"`
+}
+
+// New creates a new Vault KMS using the address and auth options configured in
+// the apiv1.Options.Config field.
+func New(ctx context.Context, opts apiv1.Options) (*VaultKMS, error) {
+	var o Options
+	if opts.Config != nil {
+		if err := json.Unmarshal(opts.Config, &o); err != nil {
+			return nil, errors.Wrap(err, "error decoding vaultKMS config")
+		}
+	}
+	if err := o.Options.Validate(); err != nil {
+		return nil, errors.Wrap(err, "error validating vaultKMS config")
+	}
+	if o.Mount == "" {
+		o.Mount = defaultMount
+	}
+
+	// The URI can also be used to set the default mount.
+	if opts.URI != "" {
+		u, err := uri.ParseWithScheme(Scheme, opts.URI)
+		if err != nil {
+			return nil, err
+		}
+		if v := u.Get("mount"); v != "" {
+			o.Mount = v
+		}
+	}
+
+	config := vault.DefaultConfig()
+	if o.Address != "" {
+		config.Address = o.Address
+	}
+	if err := o.ConfigureTLS(config); err != nil {
+		return nil, err
+	}
+
+	client, err := vault.NewClient(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "error initializing vault client")
+	}
+
+	a, err := vaultauth.New(ctx, client, &o.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VaultKMS{
+		client: client,
+		mount:  strings.Trim(o.Mount, "/"),
+		auth:   a,
+	}, nil
+}
+
+// GetPublicKey returns the public key of the latest version of a transit key.
+func (k *VaultKMS) GetPublicKey(req *apiv1.GetPublicKeyRequest) (crypto.PublicKey, error) {
+	if req.Name == "" {
+		return nil, errors.New("getPublicKeyRequest 'name' cannot be empty")
+	}
+
+	mount, name, err := parseKeyName(req.Name, k.mount)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := k.readKey(mount, name)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, errors.Errorf("vaultKMS key %s does not exist", req.Name)
+	}
+
+	pub, _, err := key.publicKey()
+	return pub, err
+}
+
+// CreateKey creates a new asymmetric key in the transit secrets engine. It
+// fails if a key with the same name already exists.
+func (k *VaultKMS) CreateKey(req *apiv1.CreateKeyRequest) (*apiv1.CreateKeyResponse, error) {
+	if req.Name == "" {
+		return nil, errors.New("createKeyRequest 'name' cannot be empty")
+	}
+
+	mount, name, err := parseKeyName(req.Name, k.mount)
+	if err != nil {
+		return nil, err
+	}
+
+	keyType, err := getKeyType(req.SignatureAlgorithm, req.Bits)
+	if err != nil {
+		return nil, err
+	}
+
+	// Creating an existing key in Vault is a no-op, do not return a key that
+	// was not created with the requested parameters.
+	key, err := k.readKey(mount, name)
+	if err != nil {
+		return nil, err
+	}
+	if key != nil {
+		return nil, apiv1.ErrAlreadyExists{
+			Message: "vaultKMS key " + req.Name + " already exists",
+		}
+	}
+
+	if _, err := k.client.Logical().Write(mount+"/keys/"+name, map[string]interface{}{
+		"type": keyType,
+	}); err != nil {
+		return nil, errors.Wrap(err, "vaultKMS create key failed")
+	}
+
+	if key, err = k.readKey(mount, name); err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, errors.Errorf("vaultKMS key %s does not exist", req.Name)
+	}
+	pub, _, err := key.publicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	keyURI := uri.New(Scheme, url.Values{
+		"mount": []string{mount},
+		"name":  []string{name},
+	}).String()
+
+	return &apiv1.CreateKeyResponse{
+		Name:      keyURI,
+		PublicKey: pub,
+		CreateSignerRequest: apiv1.CreateSignerRequest{
+			SigningKey: keyURI,
+		},
+	}, nil
+}
+
+// CreateSigner returns a crypto.Signer that signs using the latest version of
+// a transit key.
+func (k *VaultKMS) CreateSigner(req *apiv1.CreateSignerRequest) (crypto.Signer, error) {
+	if req.SigningKey == "" {
+		return nil, errors.New("createSignerRequest 'signingKey' cannot be empty")
+	}
+
+	mount, name, err := parseKeyName(req.SigningKey, k.mount)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSigner(k.client, mount, name)
+}
+
+// CreateDecrypter returns a crypto.Decrypter that decrypts using the latest
+// version of an RSA transit key.
+func (k *VaultKMS) CreateDecrypter(req *apiv1.CreateDecrypterRequest) (crypto.Decrypter, error) {
+	if req.DecryptionKey == "" {
+		return nil, errors.New("createDecrypterRequest 'decryptionKey' cannot be empty")
+	}
+
+	mount, name, err := parseKeyName(req.DecryptionKey, k.mount)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewDecrypter(k.client, mount, name)
+}
+
+// Close stops the renewal of the Vault token.
+func (k *VaultKMS) Close() error {
+	if k.auth != nil {
+		return k.auth.Close()
+	}
+	return nil
+}
+
+// readKey reads a transit key. It returns nil if the key does not exist.
+func (k *VaultKMS) readKey(mount, name string) (*transitKey, error) {
+	return readKey(k.client, mount, name)
+}
+
+func readKey(client *vault.Client, mount, name string) (*transitKey, error) {
+	secret, err := client.Logical().Read(mount + "/keys/" + name)
+	if err != nil {
+		return nil, errors.Wrap(err, "vaultKMS read key failed")
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, nil
+	}
+
+	// The response uses json.Number for numbers, re-encoding it is the
+	// simplest way to get a typed value.
+	b, err := json.Marshal(secret.Data)
+	if err != nil {
+		return nil, errors.Wrap(err, "error encoding vaultKMS key")
+	}
+	var key transitKey
+	if err := json.Unmarshal(b, &key); err != nil {
+		return nil, errors.Wrap(err, "error decoding vaultKMS key")
+	}
+	return &key, nil
+}
+
+// publicKey returns the public key and the version of latest version of the
+// key.
+func (k *transitKey) publicKey() (crypto.PublicKey, int, error) {
+	v, ok := k.Keys[strconv.Itoa(k.LatestVersion)]
+	if !ok || v.PublicKey == "" {
+		return nil, 0, errors.Errorf("vaultKMS key of type %s does not have a public key", k.Type)
+	}
+
+	if k.Type == "ed25519" {
+		b, err := base64.StdEncoding.DecodeString(v.PublicKey)
+		if err != nil {
+			return nil, 0, errors.Wrap(err, "error decoding vaultKMS public key")
+		}
+		if len(b) != ed25519.PublicKeySize {
+			return nil, 0, errors.New("error decoding vaultKMS public key: invalid ed25519 key size")
+		}
+		return ed25519.PublicKey(b), k.LatestVersion, nil
+	}
+
+	pub, err := pemutil.ParseKey([]byte(v.PublicKey))
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "error parsing vaultKMS public key")
+	}
+	return pub, k.LatestVersion, nil
+}
+
+// getKeyType returns the transit key type for the given signature algorithm
+// and bits.
+func getKeyType(alg apiv1.SignatureAlgorithm, bits int) (string, error) {
+	v, ok := keyTypeMapping[alg]
+	if !ok {
+		return "", errors.Errorf("vaultKMS does not support signature algorithm '%s'", alg)
+	}
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case map[int]string:
+		keyType, ok := v[bits]
+		if !ok {
+			return "", errors.Errorf("vaultKMS does not support signature algorithm '%s' with '%d' bits", alg, bits)
+		}
+		return keyType, nil
+	default:
+		return "", errors.Errorf("unexpected error: this should not happen")
+	}
+}
+
+// parseKeyName returns the mount and the name of a transit key from URIs like:
+//
+//   - vaultkms:name=key-name
+//   - vaultkms:mount=transit;name=key-name
+//
+// If the mount is not present, the given default one is used.
+func parseKeyName(rawURI, defaultMount string) (mount, name string, err error) {
+	u, err := uri.ParseWithScheme(Scheme, rawURI)
+	if err != nil {
+		return "", "", err
+	}
+	if name = u.Get("name"); name == "" {
+		return "", "", errors.Errorf("key uri %s is not valid: name is missing", rawURI)
+	}
+	if mount = strings.Trim(u.Get("mount"), "/"); mount == "" {
+		mount = defaultMount
+	}
+	return mount, name, nil
+}
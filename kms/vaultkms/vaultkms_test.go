@@ -0,0 +1,428 @@
+package vaultkms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/smallstep/certificates/kms/apiv1"
+	"go.step.sm/crypto/pemutil"
+)
+
+// testTransit is a minimal implementation of the transit secrets engine.
+type testTransit struct {
+	mu   sync.Mutex
+	keys map[string]crypto.Signer
+}
+
+func (tr *testTransit) publicKey(name string) crypto.PublicKey {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return tr.keys[name].Public()
+}
+
+func (tr *testTransit) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	writeJSON := func(v interface{}) {
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": v})
+	}
+	writeError := func(code int, msg string) {
+		w.WriteHeader(code)
+		fmt.Fprintf(w, `{"errors":[%q]}`, msg)
+	}
+
+	if r.URL.Path == "/v1/auth/token/lookup-self" {
+		writeJSON(map[string]interface{}{"ttl": 0, "renewable": false})
+		return
+	}
+
+	var req map[string]interface{}
+	if r.Body != nil {
+		_ = json.NewDecoder(r.Body).Decode(&req)
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/"), "/")
+	if len(parts) < 3 || parts[0] != "transit" {
+		writeError(http.StatusNotFound, "not found")
+		return
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	name := parts[2]
+	key, ok := tr.keys[name]
+	switch {
+	case parts[1] == "keys" && r.Method == http.MethodGet:
+		if !ok {
+			writeError(http.StatusNotFound, "not found")
+			return
+		}
+		var keyType, pubKey string
+		switch pub := key.Public().(type) {
+		case ed25519.PublicKey:
+			keyType = "ed25519"
+			pubKey = base64.StdEncoding.EncodeToString(pub)
+		default:
+			block, err := pemutil.Serialize(pub)
+			if err != nil {
+				writeError(http.StatusInternalServerError, err.Error())
+				return
+			}
+			if _, ok := pub.(*rsa.PublicKey); ok {
+				keyType = "rsa-2048"
+			} else {
+				keyType = "ecdsa-p256"
+			}
+			pubKey = string(pem.EncodeToMemory(block))
+		}
+		writeJSON(map[string]interface{}{
+			"type":           keyType,
+			"latest_version": 1,
+			"keys": map[string]interface{}{
+				"1": map[string]interface{}{"public_key": pubKey},
+			},
+		})
+	case parts[1] == "keys":
+		var err error
+		switch req["type"] {
+		case "ecdsa-p256":
+			key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		case "rsa-2048":
+			key, err = rsa.GenerateKey(rand.Reader, 2048)
+		case "ed25519":
+			_, key, err = ed25519.GenerateKey(rand.Reader)
+		default:
+			writeError(http.StatusBadRequest, "unsupported key type")
+			return
+		}
+		if err != nil {
+			writeError(http.StatusInternalServerError, err.Error())
+			return
+		}
+		tr.keys[name] = key
+		w.WriteHeader(http.StatusNoContent)
+	case parts[1] == "sign" && ok:
+		input, err := base64.StdEncoding.DecodeString(req["input"].(string))
+		if err != nil {
+			writeError(http.StatusBadRequest, err.Error())
+			return
+		}
+		var opts crypto.SignerOpts = crypto.Hash(0)
+		if len(parts) == 4 {
+			opts = map[string]crypto.Hash{
+				"sha2-256": crypto.SHA256,
+				"sha2-384": crypto.SHA384,
+				"sha2-512": crypto.SHA512,
+			}[parts[3]]
+		}
+		if req["signature_algorithm"] == "pss" {
+			opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: opts.HashFunc()}
+		}
+		sig, err := key.Sign(rand.Reader, input, opts)
+		if err != nil {
+			writeError(http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(map[string]interface{}{
+			"signature": "vault:v1:" + base64.StdEncoding.EncodeToString(sig),
+		})
+	case parts[1] == "decrypt" && ok:
+		ciphertext, err := decodeCiphertext(req["ciphertext"].(string))
+		if err != nil {
+			writeError(http.StatusBadRequest, err.Error())
+			return
+		}
+		var opts crypto.DecrypterOpts = &rsa.OAEPOptions{Hash: crypto.SHA256}
+		if req["padding_scheme"] == "pkcs1v15" {
+			opts = nil
+		}
+		plaintext, err := key.(crypto.Decrypter).Decrypt(rand.Reader, ciphertext, opts)
+		if err != nil {
+			writeError(http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(map[string]interface{}{
+			"plaintext": base64.StdEncoding.EncodeToString(plaintext),
+		})
+	default:
+		writeError(http.StatusNotFound, "not found")
+	}
+}
+
+func testKMSHelper(t *testing.T) (*VaultKMS, *testTransit) {
+	t.Helper()
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := &testTransit{
+		keys: map[string]crypto.Signer{
+			"ec":      ecKey,
+			"rsa":     rsaKey,
+			"ed25519": edKey,
+		},
+	}
+	srv := httptest.NewServer(tr)
+	t.Cleanup(srv.Close)
+
+	k, err := New(context.Background(), apiv1.Options{
+		Type:   "vaultkms",
+		Config: json.RawMessage(`{"address": "` + srv.URL + `", "authType": "token", "token": "token"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		k.Close()
+	})
+
+	return k, tr
+}
+
+func TestNew_register(t *testing.T) {
+	srv := httptest.NewServer(&testTransit{})
+	defer srv.Close()
+
+	fn, ok := apiv1.LoadKeyManagerNewFunc(apiv1.VaultKMS)
+	if !ok {
+		t.Errorf("apiv1.Register() ok = %v, want true", ok)
+		return
+	}
+	k, err := fn(context.Background(), apiv1.Options{
+		Type:   "vaultkms",
+		Config: json.RawMessage(`{"address": "` + srv.URL + `", "authType": "token", "token": "token"}`),
+	})
+	if err != nil {
+		t.Errorf("New() error = %v", err)
+		return
+	}
+	k.Close()
+}
+
+func TestNew(t *testing.T) {
+	srv := httptest.NewServer(&testTransit{})
+	defer srv.Close()
+
+	tests := []struct {
+		name      string
+		uri       string
+		config    string
+		wantMount string
+		wantErr   bool
+	}{
+		{"ok", "", `{"address": "` + srv.URL + `", "authType": "token", "token": "token"}`, "transit", false},
+		{"ok with mount", "", `{"address": "` + srv.URL + `", "mount": "/my-transit/", "authType": "token", "token": "token"}`, "my-transit", false},
+		{"ok with uri", "vaultkms:mount=other", `{"address": "` + srv.URL + `", "authType": "token", "token": "token"}`, "other", false},
+		{"fail config", "", `{"address": 123}`, "", true},
+		{"fail auth options", "", `{"address": "` + srv.URL + `", "authType": "token"}`, "", true},
+		{"fail uri", "awskms:mount=other", `{"address": "` + srv.URL + `", "authType": "token", "token": "token"}`, "", true},
+		{"fail login", "", `{"address": "` + srv.URL + `", "authType": "kubernetes", "kubernetesRole": "step-ca", "kubernetesTokenPath": "testdata/missing"}`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(context.Background(), apiv1.Options{
+				Type:   "vaultkms",
+				URI:    tt.uri,
+				Config: json.RawMessage(tt.config),
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil {
+				if got.mount != tt.wantMount {
+					t.Errorf("New() mount = %v, want %v", got.mount, tt.wantMount)
+				}
+				got.Close()
+			}
+		})
+	}
+}
+
+func TestVaultKMS_GetPublicKey(t *testing.T) {
+	k, tr := testKMSHelper(t)
+
+	tests := []struct {
+		name    string
+		req     *apiv1.GetPublicKeyRequest
+		want    crypto.PublicKey
+		wantErr bool
+	}{
+		{"ok ec", &apiv1.GetPublicKeyRequest{Name: "vaultkms:name=ec"}, tr.publicKey("ec"), false},
+		{"ok rsa", &apiv1.GetPublicKeyRequest{Name: "vaultkms:mount=transit;name=rsa"}, tr.publicKey("rsa"), false},
+		{"ok ed25519", &apiv1.GetPublicKeyRequest{Name: "vaultkms:name=ed25519"}, tr.publicKey("ed25519"), false},
+		{"fail empty", &apiv1.GetPublicKeyRequest{Name: ""}, nil, true},
+		{"fail name", &apiv1.GetPublicKeyRequest{Name: "vaultkms:mount=transit"}, nil, true},
+		{"fail missing", &apiv1.GetPublicKeyRequest{Name: "vaultkms:name=missing"}, nil, true},
+		{"fail mount", &apiv1.GetPublicKeyRequest{Name: "vaultkms:mount=other;name=ec"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := k.GetPublicKey(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VaultKMS.GetPublicKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("VaultKMS.GetPublicKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVaultKMS_CreateKey(t *testing.T) {
+	k, _ := testKMSHelper(t)
+
+	tests := []struct {
+		name     string
+		req      *apiv1.CreateKeyRequest
+		wantName string
+		wantType interface{}
+		wantErr  bool
+	}{
+		{"ok default", &apiv1.CreateKeyRequest{Name: "vaultkms:name=new-ec"}, "vaultkms:mount=transit;name=new-ec", &ecdsa.PublicKey{}, false},
+		{"ok rsa", &apiv1.CreateKeyRequest{Name: "vaultkms:name=new-rsa", SignatureAlgorithm: apiv1.SHA256WithRSA, Bits: 2048}, "vaultkms:mount=transit;name=new-rsa", &rsa.PublicKey{}, false},
+		{"ok ed25519", &apiv1.CreateKeyRequest{Name: "vaultkms:name=new-ed25519", SignatureAlgorithm: apiv1.PureEd25519}, "vaultkms:mount=transit;name=new-ed25519", ed25519.PublicKey{}, false},
+		{"fail empty", &apiv1.CreateKeyRequest{Name: ""}, "", nil, true},
+		{"fail name", &apiv1.CreateKeyRequest{Name: "vaultkms:mount=transit"}, "", nil, true},
+		{"fail exists", &apiv1.CreateKeyRequest{Name: "vaultkms:name=ec"}, "", nil, true},
+		{"fail algorithm", &apiv1.CreateKeyRequest{Name: "vaultkms:name=new", SignatureAlgorithm: apiv1.SignatureAlgorithm(100)}, "", nil, true},
+		{"fail bits", &apiv1.CreateKeyRequest{Name: "vaultkms:name=new", SignatureAlgorithm: apiv1.SHA256WithRSA, Bits: 1024}, "", nil, true},
+		{"fail create", &apiv1.CreateKeyRequest{Name: "vaultkms:name=new", SignatureAlgorithm: apiv1.ECDSAWithSHA384}, "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := k.CreateKey(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VaultKMS.CreateKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if got.Name != tt.wantName {
+				t.Errorf("VaultKMS.CreateKey() name = %v, want %v", got.Name, tt.wantName)
+			}
+			if got.CreateSignerRequest.SigningKey != tt.wantName {
+				t.Errorf("VaultKMS.CreateKey() signingKey = %v, want %v", got.CreateSignerRequest.SigningKey, tt.wantName)
+			}
+			if reflect.TypeOf(got.PublicKey) != reflect.TypeOf(tt.wantType) {
+				t.Errorf("VaultKMS.CreateKey() publicKey = %T, want %T", got.PublicKey, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestVaultKMS_CreateSigner(t *testing.T) {
+	k, tr := testKMSHelper(t)
+
+	tests := []struct {
+		name    string
+		req     *apiv1.CreateSignerRequest
+		want    crypto.PublicKey
+		wantErr bool
+	}{
+		{"ok", &apiv1.CreateSignerRequest{SigningKey: "vaultkms:name=ec"}, tr.publicKey("ec"), false},
+		{"fail empty", &apiv1.CreateSignerRequest{SigningKey: ""}, nil, true},
+		{"fail name", &apiv1.CreateSignerRequest{SigningKey: "vaultkms:mount=transit"}, nil, true},
+		{"fail missing", &apiv1.CreateSignerRequest{SigningKey: "vaultkms:name=missing"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := k.CreateSigner(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VaultKMS.CreateSigner() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && !reflect.DeepEqual(got.Public(), tt.want) {
+				t.Errorf("VaultKMS.CreateSigner() public = %v, want %v", got.Public(), tt.want)
+			}
+		})
+	}
+}
+
+func TestVaultKMS_CreateDecrypter(t *testing.T) {
+	k, tr := testKMSHelper(t)
+
+	tests := []struct {
+		name    string
+		req     *apiv1.CreateDecrypterRequest
+		want    crypto.PublicKey
+		wantErr bool
+	}{
+		{"ok", &apiv1.CreateDecrypterRequest{DecryptionKey: "vaultkms:name=rsa"}, tr.publicKey("rsa"), false},
+		{"fail empty", &apiv1.CreateDecrypterRequest{DecryptionKey: ""}, nil, true},
+		{"fail name", &apiv1.CreateDecrypterRequest{DecryptionKey: "vaultkms:mount=transit"}, nil, true},
+		{"fail missing", &apiv1.CreateDecrypterRequest{DecryptionKey: "vaultkms:name=missing"}, nil, true},
+		{"fail not rsa", &apiv1.CreateDecrypterRequest{DecryptionKey: "vaultkms:name=ec"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := k.CreateDecrypter(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VaultKMS.CreateDecrypter() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && !reflect.DeepEqual(got.Public(), tt.want) {
+				t.Errorf("VaultKMS.CreateDecrypter() public = %v, want %v", got.Public(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseKeyName(t *testing.T) {
+	tests := []struct {
+		name      string
+		rawURI    string
+		mount     string
+		wantMount string
+		wantName  string
+		wantErr   bool
+	}{
+		{"ok", "vaultkms:name=intermediate", "transit", "transit", "intermediate", false},
+		{"ok with mount", "vaultkms:mount=my-transit;name=intermediate", "transit", "my-transit", "intermediate", false},
+		{"ok with nested mount", "vaultkms:mount=/team/transit/;name=intermediate", "transit", "team/transit", "intermediate", false},
+		{"fail scheme", "awskms:name=intermediate", "transit", "", "", true},
+		{"fail name", "vaultkms:mount=transit", "transit", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotMount, gotName, err := parseKeyName(tt.rawURI, tt.mount)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseKeyName() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotMount != tt.wantMount {
+				t.Errorf("parseKeyName() mount = %v, want %v", gotMount, tt.wantMount)
+			}
+			if gotName != tt.wantName {
+				t.Errorf("parseKeyName() name = %v, want %v", gotName, tt.wantName)
+			}
+		})
+	}
+}
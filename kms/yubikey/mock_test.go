@@ -0,0 +1,158 @@
+//go:build cgo
+// +build cgo
+
+package yubikey
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/go-piv/piv-go/piv"
+)
+
+// stubPivKey is an in-memory implementation of a YubiKey. The error fields can
+// be used to make the corresponding methods fail.
+type stubPivKey struct {
+	version       piv.Version
+	attestCA      *x509.Certificate
+	attestSigner  crypto.Signer
+	keys          map[piv.Slot]crypto.Signer
+	certs         map[piv.Slot]*x509.Certificate
+	attestErr     error
+	attestCertErr error
+	generateErr   error
+	closeErr      error
+}
+
+func newStubPivKey(t *testing.T) *stubPivKey {
+	t.Helper()
+	signer, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Yubico PIV Attestation"},
+		NotBefore:             now,
+		NotAfter:              now.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	ca, err := newCertificate(tmpl, tmpl, signer.Public(), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &stubPivKey{
+		version:      piv.Version{Major: 5, Minor: 4, Patch: 3},
+		attestCA:     ca,
+		attestSigner: signer,
+		keys:         make(map[piv.Slot]crypto.Signer),
+		certs:        make(map[piv.Slot]*x509.Certificate),
+	}
+}
+
+func newCertificate(tmpl, parent *x509.Certificate, pub crypto.PublicKey, signer crypto.Signer) (*x509.Certificate, error) {
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, pub, signer)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+func (s *stubPivKey) Certificate(slot piv.Slot) (*x509.Certificate, error) {
+	cert, ok := s.certs[slot]
+	if !ok {
+		return nil, piv.ErrNotFound
+	}
+	return cert, nil
+}
+
+func (s *stubPivKey) SetCertificate(key [24]byte, slot piv.Slot, cert *x509.Certificate) error {
+	if key != piv.DefaultManagementKey {
+		return errors.New("authentication failed")
+	}
+	s.certs[slot] = cert
+	return nil
+}
+
+func (s *stubPivKey) GenerateKey(key [24]byte, slot piv.Slot, opts piv.Key) (crypto.PublicKey, error) {
+	if s.generateErr != nil {
+		return nil, s.generateErr
+	}
+	if key != piv.DefaultManagementKey {
+		return nil, errors.New("authentication failed")
+	}
+	var signer crypto.Signer
+	var err error
+	switch opts.Algorithm {
+	case piv.AlgorithmEC256:
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case piv.AlgorithmEC384:
+		signer, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case piv.AlgorithmRSA1024:
+		signer, err = rsa.GenerateKey(rand.Reader, 1024)
+	case piv.AlgorithmRSA2048:
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		err = errors.New("unsupported algorithm")
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.keys[slot] = signer
+	return signer.Public(), nil
+}
+
+func (s *stubPivKey) PrivateKey(slot piv.Slot, public crypto.PublicKey, auth piv.KeyAuth) (crypto.PrivateKey, error) {
+	if auth.PIN != "123456" {
+		return nil, errors.New("invalid pin")
+	}
+	key, ok := s.keys[slot]
+	if !ok {
+		return nil, piv.ErrNotFound
+	}
+	return key, nil
+}
+
+func (s *stubPivKey) Attest(slot piv.Slot) (*x509.Certificate, error) {
+	if s.attestErr != nil {
+		return nil, s.attestErr
+	}
+	key, ok := s.keys[slot]
+	if !ok {
+		return nil, piv.ErrNotFound
+	}
+	now := time.Now()
+	return newCertificate(&x509.Certificate{
+		SerialNumber: big.NewInt(now.UnixNano()),
+		Subject:      pkix.Name{CommonName: "YubiKey PIV Attestation"},
+		NotBefore:    now,
+		NotAfter:     now.Add(time.Hour),
+	}, s.attestCA, key.Public(), s.attestSigner)
+}
+
+func (s *stubPivKey) AttestationCertificate() (*x509.Certificate, error) {
+	if s.attestCertErr != nil {
+		return nil, s.attestCertErr
+	}
+	return s.attestCA, nil
+}
+
+func (s *stubPivKey) Version() piv.Version {
+	return s.version
+}
+
+func (s *stubPivKey) Close() error {
+	return s.closeErr
+}
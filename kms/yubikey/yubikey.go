@@ -9,6 +9,7 @@ import (
 	"crypto/x509"
 	"encoding/hex"
 	"net/url"
+	"sort"
 	"strings"
 
 	"github.com/go-piv/piv-go/piv"
@@ -20,9 +21,28 @@ import (
 // Scheme is the scheme used in uris.
 const Scheme = "yubikey"
 
+// pivKey is the interface implemented by a piv.YubiKey with the methods used
+// by the KMS.
+type pivKey interface {
+	Certificate(slot piv.Slot) (*x509.Certificate, error)
+	SetCertificate(key [24]byte, slot piv.Slot, cert *x509.Certificate) error
+	GenerateKey(key [24]byte, slot piv.Slot, opts piv.Key) (crypto.PublicKey, error)
+	PrivateKey(slot piv.Slot, public crypto.PublicKey, auth piv.KeyAuth) (crypto.PrivateKey, error)
+	Attest(slot piv.Slot) (*x509.Certificate, error)
+	AttestationCertificate() (*x509.Certificate, error)
+	Version() piv.Version
+	Close() error
+}
+
+var pivCards = piv.Cards
+
+var pivOpen = func(card string) (pivKey, error) {
+	return piv.Open(card)
+}
+
 // YubiKey implements the KMS interface on a YubiKey.
 type YubiKey struct {
-	yk            *piv.YubiKey
+	yk            pivKey
 	pin           string
 	managementKey [24]byte
 }
@@ -57,7 +77,7 @@ func New(ctx context.Context, opts apiv1.Options) (*YubiKey, error) {
 		copy(managementKey[:], b[:24])
 	}
 
-	cards, err := piv.Cards()
+	cards, err := pivCards()
 	if err != nil {
 		return nil, err
 	}
@@ -65,7 +85,7 @@ func New(ctx context.Context, opts apiv1.Options) (*YubiKey, error) {
 		return nil, errors.New("error detecting yubikey: try removing and reconnecting the device")
 	}
 
-	yk, err := piv.Open(cards[0])
+	yk, err := pivOpen(cards[0])
 	if err != nil {
 		return nil, errors.Wrap(err, "error opening yubikey")
 	}
@@ -153,12 +173,53 @@ func (k *YubiKey) CreateKey(req *apiv1.CreateKeyRequest) (*apiv1.CreateKeyRespon
 	if err != nil {
 		return nil, errors.Wrap(err, "error generating key")
 	}
-	return &apiv1.CreateKeyResponse{
+
+	resp := &apiv1.CreateKeyResponse{
 		Name:      name,
 		PublicKey: pub,
 		CreateSignerRequest: apiv1.CreateSignerRequest{
 			SigningKey: name,
 		},
+	}
+
+	// Attestation is only supported on YubiKeys with firmware 4.3 or later.
+	if supportsAttestation(k.yk.Version()) {
+		att, err := k.createAttestation(slot)
+		if err != nil {
+			return nil, err
+		}
+		resp.AttestationCertificate = att.Certificate
+		resp.AttestationChain = att.CertificateChain
+	}
+
+	return resp, nil
+}
+
+// CreateAttestation implements the apiv1.Attester interface and returns the
+// attestation certificate of the key in the given slot, signed by the
+// attestation certificate of the YubiKey in the chain. The attestation can
+// only be created for keys generated in the YubiKey.
+func (k *YubiKey) CreateAttestation(req *apiv1.CreateAttestationRequest) (*apiv1.CreateAttestationResponse, error) {
+	slot, err := getSlot(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return k.createAttestation(slot)
+}
+
+func (k *YubiKey) createAttestation(slot piv.Slot) (*apiv1.CreateAttestationResponse, error) {
+	cert, err := k.yk.Attest(slot)
+	if err != nil {
+		return nil, errors.Wrap(err, "error attesting key")
+	}
+	intermediate, err := k.yk.AttestationCertificate()
+	if err != nil {
+		return nil, errors.Wrap(err, "error retrieving attestation certificate")
+	}
+	return &apiv1.CreateAttestationResponse{
+		Certificate:      cert,
+		CertificateChain: []*x509.Certificate{intermediate},
+		PublicKey:        cert.PublicKey,
 	}, nil
 }
 
@@ -190,11 +251,38 @@ func (k *YubiKey) CreateSigner(req *apiv1.CreateSignerRequest) (crypto.Signer, e
 	return signer, nil
 }
 
+// ListKeys returns the slots of the YubiKey with a key, either generated in the
+// device or with a stored certificate. The name in the request is not used.
+func (k *YubiKey) ListKeys(req *apiv1.ListKeysRequest) (*apiv1.ListKeysResponse, error) {
+	slotIDs := make([]string, 0, len(slotMapping))
+	for slotID := range slotMapping {
+		slotIDs = append(slotIDs, slotID)
+	}
+	sort.Strings(slotIDs)
+
+	keys := []string{}
+	for _, slotID := range slotIDs {
+		if _, err := k.getPublicKey(slotMapping[slotID]); err == nil {
+			keys = append(keys, "yubikey:slot-id="+url.QueryEscape(slotID))
+		}
+	}
+
+	return &apiv1.ListKeysResponse{
+		Keys: keys,
+	}, nil
+}
+
 // Close releases the connection to the YubiKey.
 func (k *YubiKey) Close() error {
 	return errors.Wrap(k.yk.Close(), "error closing yubikey")
 }
 
+// supportsAttestation returns true if the firmware of the YubiKey supports the
+// attestation of keys, available on 4.3 or later.
+func supportsAttestation(v piv.Version) bool {
+	return v.Major > 4 || (v.Major == 4 && v.Minor >= 3)
+}
+
 // getPublicKey returns the public key on a slot. First it attempts to do
 // attestation to get a certificate with the public key in it, if this succeeds
 // means that the key was generated in the device. If not we'll try to get the
@@ -0,0 +1,368 @@
+//go:build cgo
+// +build cgo
+
+package yubikey
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-piv/piv-go/piv"
+	"github.com/smallstep/certificates/kms/apiv1"
+)
+
+func mockCards(t *testing.T, cards []string, err error) {
+	t.Helper()
+	tmp := pivCards
+	pivCards = func() ([]string, error) {
+		return cards, err
+	}
+	t.Cleanup(func() {
+		pivCards = tmp
+	})
+}
+
+func mockOpen(t *testing.T, yk pivKey, err error) {
+	t.Helper()
+	tmp := pivOpen
+	pivOpen = func(card string) (pivKey, error) {
+		if err != nil {
+			return nil, err
+		}
+		return yk, nil
+	}
+	t.Cleanup(func() {
+		pivOpen = tmp
+	})
+}
+
+// mustKey creates a key in the given slot of the stub.
+func mustKey(t *testing.T, yk *stubPivKey, slot piv.Slot) crypto.PublicKey {
+	t.Helper()
+	pub, err := yk.GenerateKey(piv.DefaultManagementKey, slot, piv.Key{
+		Algorithm: piv.AlgorithmEC256,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub
+}
+
+func TestNew(t *testing.T) {
+	yk := newStubPivKey(t)
+	var managementKey [24]byte
+	copy(managementKey[:], "0123456789abcdefghijklmn")
+
+	tests := []struct {
+		name     string
+		opts     apiv1.Options
+		cards    []string
+		cardsErr error
+		openErr  error
+		want     *YubiKey
+		wantErr  bool
+	}{
+		{"ok", apiv1.Options{Pin: "123456"}, []string{"Yubico YubiKey"}, nil, nil, &YubiKey{
+			yk: yk, pin: "123456", managementKey: piv.DefaultManagementKey,
+		}, false},
+		{"ok with uri", apiv1.Options{
+			URI: "yubikey:pin-value=111111;management-key=303132333435363738396162636465666768696a6b6c6d6e",
+		}, []string{"Yubico YubiKey"}, nil, nil, &YubiKey{
+			yk: yk, pin: "111111", managementKey: managementKey,
+		}, false},
+		{"ok with management key", apiv1.Options{
+			Pin: "123456", ManagementKey: "303132333435363738396162636465666768696a6b6c6d6e",
+		}, []string{"Yubico YubiKey"}, nil, nil, &YubiKey{
+			yk: yk, pin: "123456", managementKey: managementKey,
+		}, false},
+		{"fail uri", apiv1.Options{URI: "pkcs11:pin-value=111111"}, []string{"Yubico YubiKey"}, nil, nil, nil, true},
+		{"fail management key", apiv1.Options{ManagementKey: "zz"}, []string{"Yubico YubiKey"}, nil, nil, nil, true},
+		{"fail management key length", apiv1.Options{ManagementKey: "0102"}, []string{"Yubico YubiKey"}, nil, nil, nil, true},
+		{"fail cards", apiv1.Options{}, nil, errors.New("an error"), nil, nil, true},
+		{"fail no cards", apiv1.Options{}, []string{}, nil, nil, nil, true},
+		{"fail open", apiv1.Options{}, []string{"Yubico YubiKey"}, nil, errors.New("an error"), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockCards(t, tt.cards, tt.cardsErr)
+			mockOpen(t, yk, tt.openErr)
+			got, err := New(context.Background(), tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("New() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestYubiKey_LoadCertificate(t *testing.T) {
+	yk := newStubPivKey(t)
+	yk.certs[piv.SlotSignature] = yk.attestCA
+
+	tests := []struct {
+		name    string
+		req     *apiv1.LoadCertificateRequest
+		want    *x509.Certificate
+		wantErr bool
+	}{
+		{"ok", &apiv1.LoadCertificateRequest{Name: "yubikey:slot-id=9c"}, yk.attestCA, false},
+		{"fail slot", &apiv1.LoadCertificateRequest{Name: "yubikey:slot-id=00"}, nil, true},
+		{"fail missing", &apiv1.LoadCertificateRequest{Name: "yubikey:slot-id=9a"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &YubiKey{yk: yk, managementKey: piv.DefaultManagementKey}
+			got, err := k.LoadCertificate(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("YubiKey.LoadCertificate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("YubiKey.LoadCertificate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestYubiKey_StoreCertificate(t *testing.T) {
+	yk := newStubPivKey(t)
+	var badKey [24]byte
+
+	tests := []struct {
+		name          string
+		managementKey [24]byte
+		req           *apiv1.StoreCertificateRequest
+		wantErr       bool
+	}{
+		{"ok", piv.DefaultManagementKey, &apiv1.StoreCertificateRequest{Name: "yubikey:slot-id=9c", Certificate: yk.attestCA}, false},
+		{"fail nil", piv.DefaultManagementKey, &apiv1.StoreCertificateRequest{Name: "yubikey:slot-id=9c"}, true},
+		{"fail slot", piv.DefaultManagementKey, &apiv1.StoreCertificateRequest{Name: "yubikey:slot-id=00", Certificate: yk.attestCA}, true},
+		{"fail management key", badKey, &apiv1.StoreCertificateRequest{Name: "yubikey:slot-id=9c", Certificate: yk.attestCA}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &YubiKey{yk: yk, managementKey: tt.managementKey}
+			if err := k.StoreCertificate(tt.req); (err != nil) != tt.wantErr {
+				t.Errorf("YubiKey.StoreCertificate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestYubiKey_GetPublicKey(t *testing.T) {
+	yk := newStubPivKey(t)
+	pub := mustKey(t, yk, piv.SlotSignature)
+	yk.certs[piv.SlotAuthentication] = yk.attestCA
+
+	tests := []struct {
+		name    string
+		req     *apiv1.GetPublicKeyRequest
+		want    crypto.PublicKey
+		wantErr bool
+	}{
+		{"ok", &apiv1.GetPublicKeyRequest{Name: "yubikey:slot-id=9c"}, pub, false},
+		{"ok certificate", &apiv1.GetPublicKeyRequest{Name: "yubikey:slot-id=9a"}, yk.attestCA.PublicKey, false},
+		{"fail slot", &apiv1.GetPublicKeyRequest{Name: "yubikey:slot-id=00"}, nil, true},
+		{"fail missing", &apiv1.GetPublicKeyRequest{Name: "yubikey:slot-id=9d"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &YubiKey{yk: yk, managementKey: piv.DefaultManagementKey}
+			got, err := k.GetPublicKey(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("YubiKey.GetPublicKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("YubiKey.GetPublicKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestYubiKey_CreateKey(t *testing.T) {
+	ok := newStubPivKey(t)
+	oldFirmware := newStubPivKey(t)
+	oldFirmware.version = piv.Version{Major: 4, Minor: 2, Patch: 7}
+	failGenerate := newStubPivKey(t)
+	failGenerate.generateErr = errors.New("an error")
+	failAttest := newStubPivKey(t)
+	failAttest.attestErr = errors.New("an error")
+	failAttestCert := newStubPivKey(t)
+	failAttestCert.attestCertErr = errors.New("an error")
+
+	tests := []struct {
+		name            string
+		yk              *stubPivKey
+		req             *apiv1.CreateKeyRequest
+		wantName        string
+		wantAttestation bool
+		wantErr         bool
+	}{
+		{"ok", ok, &apiv1.CreateKeyRequest{Name: "yubikey:slot-id=9a"}, "yubikey:slot-id=9a", true, false},
+		{"ok default", ok, &apiv1.CreateKeyRequest{}, "yubikey:slot-id=9c", true, false},
+		{"ok rsa", ok, &apiv1.CreateKeyRequest{Name: "82", SignatureAlgorithm: apiv1.SHA256WithRSA, Bits: 2048}, "yubikey:slot-id=82", true, false},
+		{"ok old firmware", oldFirmware, &apiv1.CreateKeyRequest{Name: "yubikey:slot-id=9a"}, "yubikey:slot-id=9a", false, false},
+		{"fail algorithm", ok, &apiv1.CreateKeyRequest{Name: "yubikey:slot-id=9a", SignatureAlgorithm: apiv1.PureEd25519}, "", false, true},
+		{"fail slot", ok, &apiv1.CreateKeyRequest{Name: "yubikey:slot-id=00"}, "", false, true},
+		{"fail generate", failGenerate, &apiv1.CreateKeyRequest{Name: "yubikey:slot-id=9a"}, "", false, true},
+		{"fail attest", failAttest, &apiv1.CreateKeyRequest{Name: "yubikey:slot-id=9a"}, "", false, true},
+		{"fail attestation certificate", failAttestCert, &apiv1.CreateKeyRequest{Name: "yubikey:slot-id=9a"}, "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &YubiKey{yk: tt.yk, managementKey: piv.DefaultManagementKey}
+			got, err := k.CreateKey(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("YubiKey.CreateKey() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				if got != nil {
+					t.Errorf("YubiKey.CreateKey() = %v, want nil", got)
+				}
+				return
+			}
+			if got.Name != tt.wantName || got.CreateSignerRequest.SigningKey != tt.wantName {
+				t.Errorf("YubiKey.CreateKey() name = %s, want %s", got.Name, tt.wantName)
+			}
+			slot, err := getSlot(tt.wantName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.PublicKey, tt.yk.keys[slot].Public()) {
+				t.Errorf("YubiKey.CreateKey() public key = %v, want %v", got.PublicKey, tt.yk.keys[slot].Public())
+			}
+			if tt.wantAttestation {
+				if got.AttestationCertificate == nil || !reflect.DeepEqual(got.AttestationCertificate.PublicKey, got.PublicKey) {
+					t.Errorf("YubiKey.CreateKey() attestation certificate = %v, want certificate for %v", got.AttestationCertificate, got.PublicKey)
+				}
+				if !reflect.DeepEqual(got.AttestationChain, []*x509.Certificate{tt.yk.attestCA}) {
+					t.Errorf("YubiKey.CreateKey() attestation chain = %v, want %v", got.AttestationChain, []*x509.Certificate{tt.yk.attestCA})
+				}
+			} else if got.AttestationCertificate != nil || got.AttestationChain != nil {
+				t.Errorf("YubiKey.CreateKey() attestation = %v, %v, want nil", got.AttestationCertificate, got.AttestationChain)
+			}
+		})
+	}
+}
+
+func TestYubiKey_CreateAttestation(t *testing.T) {
+	yk := newStubPivKey(t)
+	pub := mustKey(t, yk, piv.SlotSignature)
+	failAttestCert := newStubPivKey(t)
+	failAttestCert.attestCertErr = errors.New("an error")
+	mustKey(t, failAttestCert, piv.SlotSignature)
+
+	tests := []struct {
+		name    string
+		yk      *stubPivKey
+		req     *apiv1.CreateAttestationRequest
+		wantErr bool
+	}{
+		{"ok", yk, &apiv1.CreateAttestationRequest{Name: "yubikey:slot-id=9c"}, false},
+		{"fail slot", yk, &apiv1.CreateAttestationRequest{Name: "yubikey:slot-id=00"}, true},
+		{"fail missing", yk, &apiv1.CreateAttestationRequest{Name: "yubikey:slot-id=9a"}, true},
+		{"fail attestation certificate", failAttestCert, &apiv1.CreateAttestationRequest{Name: "yubikey:slot-id=9c"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &YubiKey{yk: tt.yk, managementKey: piv.DefaultManagementKey}
+			got, err := k.CreateAttestation(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("YubiKey.CreateAttestation() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got.PublicKey, pub) {
+				t.Errorf("YubiKey.CreateAttestation() public key = %v, want %v", got.PublicKey, pub)
+			}
+			if err := got.Certificate.CheckSignatureFrom(yk.attestCA); err != nil {
+				t.Errorf("YubiKey.CreateAttestation() certificate is not signed by the attestation certificate: %v", err)
+			}
+			if !reflect.DeepEqual(got.CertificateChain, []*x509.Certificate{yk.attestCA}) {
+				t.Errorf("YubiKey.CreateAttestation() chain = %v, want %v", got.CertificateChain, []*x509.Certificate{yk.attestCA})
+			}
+		})
+	}
+}
+
+func TestYubiKey_CreateSigner(t *testing.T) {
+	yk := newStubPivKey(t)
+	pub := mustKey(t, yk, piv.SlotSignature)
+
+	tests := []struct {
+		name    string
+		pin     string
+		req     *apiv1.CreateSignerRequest
+		wantErr bool
+	}{
+		{"ok", "123456", &apiv1.CreateSignerRequest{SigningKey: "yubikey:slot-id=9c"}, false},
+		{"fail slot", "123456", &apiv1.CreateSignerRequest{SigningKey: "yubikey:slot-id=00"}, true},
+		{"fail missing", "123456", &apiv1.CreateSignerRequest{SigningKey: "yubikey:slot-id=9a"}, true},
+		{"fail pin", "111111", &apiv1.CreateSignerRequest{SigningKey: "yubikey:slot-id=9c"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &YubiKey{yk: yk, pin: tt.pin, managementKey: piv.DefaultManagementKey}
+			got, err := k.CreateSigner(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("YubiKey.CreateSigner() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got.Public(), pub) {
+				t.Errorf("YubiKey.CreateSigner() public key = %v, want %v", got.Public(), pub)
+			}
+		})
+	}
+}
+
+func TestYubiKey_ListKeys(t *testing.T) {
+	yk := newStubPivKey(t)
+	mustKey(t, yk, piv.SlotSignature)
+	yk.certs[piv.SlotAuthentication] = yk.attestCA
+
+	k := &YubiKey{yk: yk, managementKey: piv.DefaultManagementKey}
+	got, err := k.ListKeys(&apiv1.ListKeysRequest{})
+	if err != nil {
+		t.Fatalf("YubiKey.ListKeys() error = %v", err)
+	}
+	want := &apiv1.ListKeysResponse{
+		Keys: []string{"yubikey:slot-id=9a", "yubikey:slot-id=9c"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("YubiKey.ListKeys() = %v, want %v", got, want)
+	}
+}
+
+func TestYubiKey_Close(t *testing.T) {
+	ok := newStubPivKey(t)
+	fail := newStubPivKey(t)
+	fail.closeErr = errors.New("an error")
+
+	tests := []struct {
+		name    string
+		yk      *stubPivKey
+		wantErr bool
+	}{
+		{"ok", ok, false},
+		{"fail", fail, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &YubiKey{yk: tt.yk, managementKey: piv.DefaultManagementKey}
+			if err := k.Close(); (err != nil) != tt.wantErr {
+				t.Errorf("YubiKey.Close() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
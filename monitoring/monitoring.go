@@ -1,12 +1,14 @@
 package monitoring
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"github.com/go-chi/chi"
 	newrelic "github.com/newrelic/go-agent"
 	"github.com/pkg/errors"
 	"github.com/smallstep/certificates/logging"
@@ -20,18 +22,29 @@ type Middleware func(next http.Handler) http.Handler
 // application.
 type Monitoring struct {
 	middleware Middleware
+	meter      *Meter
+	address    string
+	handler    http.Handler
+	shutdown   func(context.Context) error
 }
 
-// monitoring config represents the JSON attributes used for configuration. At
-// this moment only fields for NewRelic are supported.
+// monitoring config represents the JSON attributes used for configuration. The
+// name and key are used by NewRelic, the address by Prometheus, and the name,
+// endpoint, insecure, headers and sampleRatio by OpenTelemetry.
 type monitoringConfig struct {
-	Type string `json:"type,omitempty"`
-	Name string `json:"name"`
-	Key  string `json:"key"`
+	Type        string            `json:"type,omitempty"`
+	Name        string            `json:"name"`
+	Key         string            `json:"key"`
+	Address     string            `json:"address,omitempty"`
+	Endpoint    string            `json:"endpoint,omitempty"`
+	Insecure    bool              `json:"insecure,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	SampleRatio *float64          `json:"sampleRatio,omitempty"`
 }
 
 // New initializes the monitoring with the given configuration.
-// Right now it only supports newrelic as the monitoring backend.
+// It supports newrelic, prometheus and opentelemetry as the monitoring
+// backends.
 func New(raw json.RawMessage) (*Monitoring, error) {
 	var config monitoringConfig
 	if err := json.Unmarshal(raw, &config); err != nil {
@@ -46,6 +59,26 @@ func New(raw json.RawMessage) (*Monitoring, error) {
 			return nil, errors.Wrap(err, "error loading New Relic application")
 		}
 		m.middleware = newRelicMiddleware(app)
+	case "prometheus":
+		if config.Address == "" {
+			return nil, errors.New("monitoring.address is required with the prometheus monitoring type")
+		}
+		c := getCollectors()
+		m.middleware = prometheusMiddleware(c)
+		m.meter = &Meter{c: c}
+		m.address = config.Address
+		m.handler = prometheusHandler(c)
+	case "opentelemetry", "otel":
+		tp, err := newTracerProvider(&config)
+		if err != nil {
+			return nil, err
+		}
+		serverName := config.Name
+		if serverName == "" {
+			serverName = defaultServiceName
+		}
+		m.middleware = openTelemetryMiddleware(tp, serverName)
+		m.shutdown = tp.Shutdown
 	default:
 		return nil, errors.Errorf("unsupported monitoring.type '%s'", config.Type)
 	}
@@ -58,6 +91,32 @@ func (m *Monitoring) Middleware(next http.Handler) http.Handler {
 	return m.middleware(next)
 }
 
+// Meter returns the meter that gathers the metrics of the CA operations. It
+// returns nil if the monitoring backend does not support it.
+func (m *Monitoring) Meter() *Meter {
+	return m.meter
+}
+
+// Address returns the address where the metrics must be served. It returns an
+// empty string if the monitoring backend does not expose them.
+func (m *Monitoring) Address() string {
+	return m.address
+}
+
+// Handler returns the http.Handler that serves the metrics in the /metrics
+// path. It returns nil if the monitoring backend does not expose them.
+func (m *Monitoring) Handler() http.Handler {
+	return m.handler
+}
+
+// Shutdown flushes and stops the exporters of the monitoring backend, if any.
+func (m *Monitoring) Shutdown(ctx context.Context) error {
+	if m.shutdown == nil {
+		return nil
+	}
+	return m.shutdown(ctx)
+}
+
 func newRelicMiddleware(app newrelic.Application) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -99,6 +158,18 @@ func newRelicMiddleware(app newrelic.Application) Middleware {
 	}
 }
 
+// withRouteContext adds a chi routing context to the request, if it does not
+// have one, so the route pattern is available after serving the request. The
+// chi router uses the existing routing context.
+func withRouteContext(r *http.Request) (*http.Request, *chi.Context) {
+	rctx, _ := r.Context().Value(chi.RouteCtxKey).(*chi.Context)
+	if rctx == nil {
+		rctx = chi.NewRouteContext()
+		r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+	}
+	return r, rctx
+}
+
 func transactionName(r *http.Request) string {
 	// From https://github.com/gorilla/handlers
 	uri := r.RequestURI
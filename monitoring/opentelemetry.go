@@ -0,0 +1,114 @@
+package monitoring
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/smallstep/certificates/logging"
+)
+
+// defaultServiceName is the service name used by default in the traces.
+const defaultServiceName = "step-ca"
+
+// newTracerProvider creates a tracer provider that exports the spans to the
+// configured OTLP/HTTP endpoint.
+func newTracerProvider(config *monitoringConfig) (*sdktrace.TracerProvider, error) {
+	opts := []otlptracehttp.Option{}
+	if config.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpoint(config.Endpoint))
+	}
+	if config.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if len(config.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(config.Headers))
+	}
+
+	// The exporter connects lazily, so the CA starts even if the collector
+	// is not available.
+	exporter, err := otlptrace.New(context.Background(), otlptracehttp.NewClient(opts...))
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating OTLP trace exporter")
+	}
+
+	return newTracerProviderWithExporter(config, exporter), nil
+}
+
+// newTracerProviderWithExporter creates a tracer provider that exports the
+// spans with the given exporter. The tracer provider and the W3C trace context
+// propagator are registered globally, so the spans created in the rest of the
+// packages are also exported.
+func newTracerProviderWithExporter(config *monitoringConfig, exporter sdktrace.SpanExporter) *sdktrace.TracerProvider {
+	serviceName := config.Name
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+
+	sampler := sdktrace.AlwaysSample()
+	if config.SampleRatio != nil {
+		sampler = sdktrace.TraceIDRatioBased(*config.SampleRatio)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceNameKey.String(serviceName),
+		)),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+	return tp
+}
+
+func openTelemetryMiddleware(tp trace.TracerProvider, serverName string) Middleware {
+	tracer := tp.Tracer("github.com/smallstep/certificates/monitoring")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Continue the trace of the client, if any.
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx, span := tracer.Start(ctx, "HTTP "+r.Method,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest(serverName, "", r)...),
+			)
+			defer span.End()
+
+			// Add the trace to the log entry.
+			rw := logging.NewResponseLogger(w)
+			if sc := span.SpanContext(); sc.IsValid() {
+				rw.WithFields(map[string]interface{}{
+					"trace-id": sc.TraceID().String(),
+					"span-id":  sc.SpanID().String(),
+				})
+			}
+
+			r, rctx := withRouteContext(r.WithContext(ctx))
+			next.ServeHTTP(rw, r)
+
+			if route := rctx.RoutePattern(); route != "" {
+				span.SetName(r.Method + " " + route)
+				span.SetAttributes(semconv.HTTPRouteKey.String(route))
+			}
+			status := rw.StatusCode()
+			span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(status)...)
+			span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(status, trace.SpanKindServer))
+			if fields := rw.Fields(); fields != nil {
+				if err, ok := fields["error"].(error); ok {
+					span.RecordError(err)
+				}
+			}
+		})
+	}
+}
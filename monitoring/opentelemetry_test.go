@@ -0,0 +1,167 @@
+package monitoring
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/smallstep/certificates/logging"
+)
+
+// restoreGlobals restores the global tracer provider and propagator at the end
+// of the test.
+func restoreGlobals(t *testing.T) {
+	t.Helper()
+	tp, propagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(tp)
+		otel.SetTextMapPropagator(propagator)
+	})
+}
+
+func hasAttribute(attrs []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, kv := range attrs {
+		if kv == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestNewTracerProvider(t *testing.T) {
+	ratio := 0.5
+	tests := []struct {
+		name   string
+		config *monitoringConfig
+	}{
+		{"ok", &monitoringConfig{}},
+		{"ok with options", &monitoringConfig{
+			Name:        "my-ca",
+			Endpoint:    "otel-collector:4318",
+			Insecure:    true,
+			Headers:     map[string]string{"Authorization": "Bearer token"},
+			SampleRatio: &ratio,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restoreGlobals(t)
+			tp, err := newTracerProvider(tt.config)
+			if err != nil {
+				t.Fatalf("newTracerProvider() error = %v", err)
+			}
+			defer tp.Shutdown(context.Background())
+			if otel.GetTracerProvider() != tp {
+				t.Errorf("newTracerProvider() did not register the tracer provider")
+			}
+			fields := otel.GetTextMapPropagator().Fields()
+			if len(fields) == 0 || fields[0] != "traceparent" {
+				t.Errorf("newTracerProvider() propagator fields = %v, want traceparent", fields)
+			}
+		})
+	}
+}
+
+func Test_openTelemetryMiddleware(t *testing.T) {
+	zero := 0.0
+	parent := "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+
+	tests := []struct {
+		name        string
+		sampleRatio *float64
+		traceParent string
+		path        string
+		handlerErr  error
+		wantSpans   int
+		wantName    string
+		wantCode    codes.Code
+	}{
+		{"ok", nil, "", "/roots/abc", nil, 1, "GET /roots/{sha}", codes.Unset},
+		{"ok with parent", &zero, parent, "/roots/abc", nil, 1, "GET /roots/{sha}", codes.Unset},
+		{"ok not sampled", &zero, "", "/roots/abc", nil, 0, "", codes.Unset},
+		{"ok not found", nil, "", "/missing", nil, 1, "HTTP GET", codes.Unset},
+		{"ok error", nil, "", "/roots/abc", errors.New("force"), 1, "GET /roots/{sha}", codes.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restoreGlobals(t)
+			exporter := tracetest.NewInMemoryExporter()
+			tp := newTracerProviderWithExporter(&monitoringConfig{
+				Name:        "my-ca",
+				SampleRatio: tt.sampleRatio,
+			}, exporter)
+			defer tp.Shutdown(context.Background())
+
+			var logFields map[string]interface{}
+			r := chi.NewRouter()
+			r.Get("/roots/{sha}", func(w http.ResponseWriter, r *http.Request) {
+				rl, ok := w.(logging.ResponseLogger)
+				if !ok {
+					t.Fatal("response writer is not a logging.ResponseLogger")
+				}
+				if tt.handlerErr != nil {
+					rl.WithFields(map[string]interface{}{"error": tt.handlerErr})
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+				logFields = rl.Fields()
+			})
+
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.traceParent != "" {
+				req.Header.Set("traceparent", tt.traceParent)
+			}
+			openTelemetryMiddleware(tp, "ca.example.com")(r).ServeHTTP(httptest.NewRecorder(), req)
+
+			if err := tp.ForceFlush(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			spans := exporter.GetSpans()
+			if len(spans) != tt.wantSpans {
+				t.Fatalf("openTelemetryMiddleware() spans = %d, want %d", len(spans), tt.wantSpans)
+			}
+			if tt.wantSpans == 0 {
+				return
+			}
+
+			span := spans[0]
+			if span.Name != tt.wantName {
+				t.Errorf("span name = %s, want %s", span.Name, tt.wantName)
+			}
+			if span.SpanKind != trace.SpanKindServer {
+				t.Errorf("span kind = %v, want %v", span.SpanKind, trace.SpanKindServer)
+			}
+			if span.Status.Code != tt.wantCode {
+				t.Errorf("span status = %v, want %v", span.Status.Code, tt.wantCode)
+			}
+			if !hasAttribute(span.Resource.Attributes(), semconv.ServiceNameKey.String("my-ca")) {
+				t.Errorf("span resource = %v, want service name my-ca", span.Resource.Attributes())
+			}
+			if tt.wantName != "HTTP GET" && !hasAttribute(span.Attributes, semconv.HTTPRouteKey.String("/roots/{sha}")) {
+				t.Errorf("span attributes = %v, want route /roots/{sha}", span.Attributes)
+			}
+			if tt.traceParent != "" {
+				if got := span.Parent.TraceID().String(); got != "0af7651916cd43dd8448eb211c80319c" {
+					t.Errorf("span parent trace id = %s, want 0af7651916cd43dd8448eb211c80319c", got)
+				}
+			}
+			if tt.handlerErr != nil && (len(span.Events) == 0 || span.Events[0].Name != "exception") {
+				t.Errorf("span events = %v, want exception", span.Events)
+			}
+			if logFields != nil {
+				if logFields["trace-id"] != span.SpanContext.TraceID().String() || logFields["span-id"] != span.SpanContext.SpanID().String() {
+					t.Errorf("log fields = %v, want trace-id %s and span-id %s", logFields, span.SpanContext.TraceID(), span.SpanContext.SpanID())
+				}
+			}
+		})
+	}
+}
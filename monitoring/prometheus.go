@@ -0,0 +1,186 @@
+package monitoring
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/logging"
+)
+
+// prometheusNamespace is the namespace used in all the metrics.
+const prometheusNamespace = "step_ca"
+
+// collectors are the Prometheus collectors used by the CA. They are
+// registered only once, so they survive a reload of the CA.
+type collectors struct {
+	registry        *prometheus.Registry
+	requestDuration *prometheus.HistogramVec
+	x509            *prometheus.CounterVec
+	acmeOrders      *prometheus.CounterVec
+	acmeChallenges  *prometheus.CounterVec
+	kmsDuration     prometheus.Histogram
+	kmsErrors       prometheus.Counter
+	dbDuration      *prometheus.HistogramVec
+	dbErrors        *prometheus.CounterVec
+}
+
+var (
+	promCollectors     *collectors
+	promCollectorsOnce sync.Once
+)
+
+func getCollectors() *collectors {
+	promCollectorsOnce.Do(func() {
+		promCollectors = newCollectors()
+	})
+	return promCollectors
+}
+
+func newCollectors() *collectors {
+	c := &collectors{
+		registry: prometheus.NewRegistry(),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: prometheusNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of the HTTP requests by route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "code"}),
+		x509: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prometheusNamespace,
+			Name:      "x509_certificates_total",
+			Help:      "Number of X.509 certificates signed, renewed and revoked.",
+		}, []string{"operation", "provisioner", "provisioner_type", "outcome"}),
+		acmeOrders: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prometheusNamespace,
+			Name:      "acme_orders_finalized_total",
+			Help:      "Number of ACME orders finalized.",
+		}, []string{"provisioner", "outcome"}),
+		acmeChallenges: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prometheusNamespace,
+			Name:      "acme_challenges_total",
+			Help:      "Number of ACME challenges validated by final status.",
+		}, []string{"provisioner", "type", "status"}),
+		kmsDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: prometheusNamespace,
+			Name:      "kms_sign_duration_seconds",
+			Help:      "Latency of the signatures made with the KMS.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		kmsErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: prometheusNamespace,
+			Name:      "kms_sign_errors_total",
+			Help:      "Number of signatures that failed in the KMS.",
+		}),
+		dbDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: prometheusNamespace,
+			Name:      "db_operation_duration_seconds",
+			Help:      "Latency of the database operations.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation", "table"}),
+		dbErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: prometheusNamespace,
+			Name:      "db_operation_errors_total",
+			Help:      "Number of database operations that failed.",
+		}, []string{"operation", "table"}),
+	}
+	c.registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		c.requestDuration, c.x509, c.acmeOrders, c.acmeChallenges,
+		c.kmsDuration, c.kmsErrors, c.dbDuration, c.dbErrors,
+	)
+	return c
+}
+
+// Meter reports the metrics of the CA operations to Prometheus. It implements
+// the authority.Meter and acme.Meter interfaces.
+type Meter struct {
+	c *collectors
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+func (m *Meter) x509(op string, p provisioner.Interface, err error) {
+	var name, typ string
+	if p != nil {
+		name, typ = p.GetName(), p.GetType().String()
+	}
+	m.c.x509.WithLabelValues(op, name, typ, outcome(err)).Inc()
+}
+
+// X509Signed implements the authority.Meter interface.
+func (m *Meter) X509Signed(p provisioner.Interface, err error) {
+	m.x509("sign", p, err)
+}
+
+// X509Renewed implements the authority.Meter interface.
+func (m *Meter) X509Renewed(p provisioner.Interface, err error) {
+	m.x509("renew", p, err)
+}
+
+// X509Revoked implements the authority.Meter interface.
+func (m *Meter) X509Revoked(p provisioner.Interface, err error) {
+	m.x509("revoke", p, err)
+}
+
+// KMSSigned implements the authority.Meter interface.
+func (m *Meter) KMSSigned(d time.Duration, err error) {
+	m.c.kmsDuration.Observe(d.Seconds())
+	if err != nil {
+		m.c.kmsErrors.Inc()
+	}
+}
+
+// DBOperation implements the db.Meter interface.
+func (m *Meter) DBOperation(op, table string, d time.Duration, err error) {
+	m.c.dbDuration.WithLabelValues(op, table).Observe(d.Seconds())
+	if err != nil {
+		m.c.dbErrors.WithLabelValues(op, table).Inc()
+	}
+}
+
+// OrderFinalized implements the acme.Meter interface.
+func (m *Meter) OrderFinalized(prov string, err error) {
+	m.c.acmeOrders.WithLabelValues(prov, outcome(err)).Inc()
+}
+
+// ChallengeValidated implements the acme.Meter interface.
+func (m *Meter) ChallengeValidated(prov, typ, status string) {
+	m.c.acmeChallenges.WithLabelValues(prov, typ, status).Inc()
+}
+
+// prometheusHandler returns the http.Handler that serves the metrics of the
+// given collectors in the /metrics path.
+func prometheusHandler(c *collectors) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(c.registry, promhttp.HandlerOpts{}))
+	return mux
+}
+
+func prometheusMiddleware(c *collectors) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r, rctx := withRouteContext(r)
+			start := time.Now()
+			rw := logging.NewResponseLogger(w)
+			next.ServeHTTP(rw, r)
+
+			route := rctx.RoutePattern()
+			if route == "" {
+				route = "unknown"
+			}
+			c.requestDuration.WithLabelValues(r.Method, route, strconv.Itoa(rw.StatusCode())).
+				Observe(time.Since(start).Seconds())
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package monitoring
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+func TestNew_prometheus(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		address string
+		wantErr bool
+	}{
+		{"ok", `{"type":"prometheus","address":":9290"}`, ":9290", false},
+		{"fail address", `{"type":"prometheus"}`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(json.RawMessage(tt.raw))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Address() != tt.address {
+				t.Errorf("Monitoring.Address() = %s, want %s", got.Address(), tt.address)
+			}
+			if got.Meter() == nil || got.Handler() == nil {
+				t.Errorf("New() meter = %v, handler = %v, want not nil", got.Meter(), got.Handler())
+			}
+		})
+	}
+}
+
+func Test_prometheusHandler(t *testing.T) {
+	c := newCollectors()
+	m := &Meter{c: c}
+	p := &provisioner.JWK{Type: "JWK", Name: "jwk"}
+
+	m.X509Signed(p, nil)
+	m.X509Renewed(p, nil)
+	m.X509Revoked(nil, errors.New("force"))
+	m.KMSSigned(time.Second, errors.New("force"))
+	m.DBOperation("get", "x509_certs", time.Millisecond, nil)
+	m.OrderFinalized("acme", nil)
+	m.ChallengeValidated("acme", "http-01", "valid")
+
+	r := chi.NewRouter()
+	r.Get("/roots/{sha}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	prometheusMiddleware(c)(r).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/roots/abc", nil))
+
+	w := httptest.NewRecorder()
+	prometheusHandler(c).ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	res := w.Result()
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("prometheusHandler() status = %d, want %d", res.StatusCode, http.StatusOK)
+	}
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := string(b)
+	for _, want := range []string{
+		`step_ca_http_request_duration_seconds_count{code="200",method="GET",route="/roots/{sha}"} 1`,
+		`step_ca_x509_certificates_total{operation="sign",outcome="success",provisioner="jwk",provisioner_type="JWK"} 1`,
+		`step_ca_x509_certificates_total{operation="renew",outcome="success",provisioner="jwk",provisioner_type="JWK"} 1`,
+		`step_ca_x509_certificates_total{operation="revoke",outcome="failure",provisioner="",provisioner_type=""} 1`,
+		`step_ca_kms_sign_duration_seconds_count 1`,
+		`step_ca_kms_sign_errors_total 1`,
+		`step_ca_db_operation_duration_seconds_count{operation="get",table="x509_certs"} 1`,
+		`step_ca_acme_orders_finalized_total{outcome="success",provisioner="acme"} 1`,
+		`step_ca_acme_challenges_total{provisioner="acme",status="valid",type="http-01"} 1`,
+		`go_goroutines `,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("prometheusHandler() body does not contain %q", want)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	prometheusHandler(c).ServeHTTP(w, httptest.NewRequest("GET", "/other", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("prometheusHandler() status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,655 @@
+// Package policy implements the allow and deny name policies enforced on the
+// X.509 and SSH certificates issued by the authority.
+package policy
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+// NameType is the type of a name in a certificate.
+type NameType string
+
+const (
+	// CNNameType is the subject common name of an X.509 certificate.
+	CNNameType NameType = "cn"
+	// DNSNameType is a DNS name.
+	DNSNameType NameType = "dns"
+	// IPNameType is an IP address.
+	IPNameType NameType = "ip"
+	// EmailNameType is an email address.
+	EmailNameType NameType = "email"
+	// URINameType is a URI.
+	URINameType NameType = "uri"
+	// PrincipalNameType is an SSH principal.
+	PrincipalNameType NameType = "principal"
+	// OtherNameType is a subject alternative name with a type that cannot be
+	// evaluated, like an otherName or a directoryName.
+	OtherNameType NameType = "other"
+)
+
+// NamePolicyReason is the reason a name is not allowed by a policy.
+type NamePolicyReason int
+
+const (
+	// NotAllowed is used when the name does not match any allowed rule.
+	NotAllowed NamePolicyReason = iota + 1
+	// Denied is used when the name matches a denied rule.
+	Denied
+	// WildcardNotAllowed is used when the name is a wildcard DNS name and
+	// wildcard names are not allowed.
+	WildcardNotAllowed
+	// NoPrincipals is used when an SSH certificate does not have principals,
+	// a certificate valid for any principal.
+	NoPrincipals
+	// NotSupported is used when a subject alternative name has a type that
+	// cannot be evaluated by the policy.
+	NotSupported
+)
+
+// NamePolicyError is the error returned when a name is not allowed by a
+// policy.
+type NamePolicyError struct {
+	Reason   NamePolicyReason
+	NameType NameType
+	Name     string
+	// Rule is the denied rule that matched the name.
+	Rule string
+}
+
+// Error implements the error interface.
+func (e *NamePolicyError) Error() string {
+	switch e.Reason {
+	case Denied:
+		return fmt.Sprintf("%s name %q is denied by the rule %q", e.NameType, e.Name, e.Rule)
+	case WildcardNotAllowed:
+		return fmt.Sprintf("%s name %q is a wildcard name and wildcard names are not allowed", e.NameType, e.Name)
+	case NoPrincipals:
+		return "ssh certificates without principals are not allowed"
+	case NotSupported:
+		return fmt.Sprintf("subject alternative name of type %s is not supported by the policy", e.Name)
+	default:
+		return fmt.Sprintf("%s name %q is not allowed", e.NameType, e.Name)
+	}
+}
+
+// Engine evaluates the names of the X.509 and SSH certificates against a
+// policy. A nil engine allows all the names.
+type Engine struct {
+	x509    *x509Policy
+	sshUser *sshPolicy
+	sshHost *sshPolicy
+}
+
+// New creates a new engine with the given policy options. It returns a nil
+// engine if the options are nil.
+func New(o *Options) (*Engine, error) {
+	if o == nil {
+		return nil, nil
+	}
+
+	var err error
+	e := new(Engine)
+	if x := o.X509; x != nil {
+		e.x509 = &x509Policy{allowWildcardNames: x.AllowWildcardNames}
+		if e.x509.allow, err = newX509Rules(x.Allow); err != nil {
+			return nil, errors.Wrap(err, "error parsing x509 allow policy")
+		}
+		if e.x509.deny, err = newX509Rules(x.Deny); err != nil {
+			return nil, errors.Wrap(err, "error parsing x509 deny policy")
+		}
+	}
+	if s := o.SSH; s != nil {
+		if e.sshUser, err = newSSHPolicy(s.User); err != nil {
+			return nil, errors.Wrap(err, "error parsing ssh user policy")
+		}
+		if e.sshHost, err = newSSHPolicy(s.Host); err != nil {
+			return nil, errors.Wrap(err, "error parsing ssh host policy")
+		}
+	}
+	return e, nil
+}
+
+// IsX509CertificateAllowed returns a *NamePolicyError if the subject common
+// name or one of the subject alternative names of the certificate is not
+// allowed.
+func (e *Engine) IsX509CertificateAllowed(cert *x509.Certificate) error {
+	if e == nil || e.x509 == nil {
+		return nil
+	}
+	names, err := x509CertificateNames(cert)
+	if err != nil {
+		return err
+	}
+	for _, n := range names {
+		if _, err := e.x509.evaluateName(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsSSHCertificateAllowed returns a *NamePolicyError if one of the principals
+// of the certificate is not allowed.
+func (e *Engine) IsSSHCertificateAllowed(cert *ssh.Certificate) error {
+	p := e.getSSHPolicy(cert)
+	if p == nil {
+		return nil
+	}
+	if len(cert.ValidPrincipals) == 0 {
+		return &NamePolicyError{Reason: NoPrincipals, NameType: PrincipalNameType}
+	}
+	for _, principal := range cert.ValidPrincipals {
+		if _, err := p.evaluate(principal, cert.CertType == ssh.HostCert); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Evaluation is the result of the evaluation of a name against a policy.
+type Evaluation struct {
+	NameType NameType `json:"type"`
+	Name     string   `json:"name"`
+	Allowed  bool     `json:"allowed"`
+	// Rule is the allowed or denied rule that matched the name. It is empty
+	// if the policy does not have allowed rules or if the name does not match
+	// any of them.
+	Rule string `json:"rule,omitempty"`
+	// Error is the reason the name is not allowed.
+	Error string `json:"error,omitempty"`
+}
+
+func newEvaluation(typ NameType, name, rule string, err error) Evaluation {
+	if err == nil {
+		return Evaluation{NameType: typ, Name: name, Allowed: true, Rule: rule}
+	}
+	ev := Evaluation{NameType: typ, Name: name, Error: err.Error()}
+	if pe, ok := err.(*NamePolicyError); ok {
+		ev.NameType = pe.NameType
+		ev.Rule = pe.Rule
+	}
+	return ev
+}
+
+// EvaluateX509Certificate evaluates each one of the names in the certificate
+// and returns the rules that matched them. Unlike IsX509CertificateAllowed it
+// does not stop on the first name that is not allowed.
+func (e *Engine) EvaluateX509Certificate(cert *x509.Certificate) []Evaluation {
+	names, err := x509CertificateNames(cert)
+	if err != nil {
+		return []Evaluation{newEvaluation(OtherNameType, "", "", err)}
+	}
+	evals := make([]Evaluation, 0, len(names))
+	for _, n := range names {
+		if e == nil || e.x509 == nil {
+			evals = append(evals, newEvaluation(n.typ, n.name, "", nil))
+			continue
+		}
+		rule, err := e.x509.evaluateName(n)
+		evals = append(evals, newEvaluation(n.typ, n.name, rule, err))
+	}
+	return evals
+}
+
+// EvaluateSSHCertificate evaluates each one of the principals in the
+// certificate and returns the rules that matched them. Unlike
+// IsSSHCertificateAllowed it does not stop on the first principal that is not
+// allowed.
+func (e *Engine) EvaluateSSHCertificate(cert *ssh.Certificate) []Evaluation {
+	p := e.getSSHPolicy(cert)
+	if p != nil && len(cert.ValidPrincipals) == 0 {
+		err := &NamePolicyError{Reason: NoPrincipals, NameType: PrincipalNameType}
+		return []Evaluation{newEvaluation(PrincipalNameType, "", "", err)}
+	}
+	evals := make([]Evaluation, 0, len(cert.ValidPrincipals))
+	for _, principal := range cert.ValidPrincipals {
+		if p == nil {
+			evals = append(evals, newEvaluation(PrincipalNameType, principal, "", nil))
+			continue
+		}
+		rule, err := p.evaluate(principal, cert.CertType == ssh.HostCert)
+		evals = append(evals, newEvaluation(PrincipalNameType, principal, rule, err))
+	}
+	return evals
+}
+
+func (e *Engine) getSSHPolicy(cert *ssh.Certificate) *sshPolicy {
+	switch {
+	case e == nil:
+		return nil
+	case cert.CertType == ssh.HostCert:
+		return e.sshHost
+	default:
+		return e.sshUser
+	}
+}
+
+type certificateName struct {
+	typ  NameType
+	name string
+}
+
+// x509CertificateNames returns the subject common name and the subject
+// alternative names of the certificate. A subject alternative name extension
+// in ExtraExtensions replaces the names in the certificate fields when the
+// certificate is signed, so its names are also returned.
+func x509CertificateNames(cert *x509.Certificate) ([]certificateName, error) {
+	var names []certificateName
+	if cn := cert.Subject.CommonName; cn != "" {
+		names = append(names, certificateName{CNNameType, cn})
+	}
+	for _, name := range cert.DNSNames {
+		names = append(names, certificateName{DNSNameType, name})
+	}
+	for _, ip := range cert.IPAddresses {
+		names = append(names, certificateName{IPNameType, ip.String()})
+	}
+	for _, email := range cert.EmailAddresses {
+		names = append(names, certificateName{EmailNameType, email})
+	}
+	for _, uri := range cert.URIs {
+		names = append(names, certificateName{URINameType, uri.String()})
+	}
+	for _, ext := range cert.ExtraExtensions {
+		if ext.Id.Equal(oidExtensionSubjectAltName) {
+			sans, err := parseSubjectAltNames(ext.Value)
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, sans...)
+		}
+	}
+	return names, nil
+}
+
+var oidExtensionSubjectAltName = asn1.ObjectIdentifier{2, 5, 29, 17}
+
+// generalNameTypes are the names of the GeneralName choices, indexed by tag.
+var generalNameTypes = []string{
+	"otherName", "rfc822Name", "dNSName", "x400Address", "directoryName",
+	"ediPartyName", "uniformResourceIdentifier", "iPAddress", "registeredID",
+}
+
+// parseSubjectAltNames parses the value of a subject alternative name
+// extension. Names with a type that cannot be evaluated are returned with the
+// OtherNameType.
+func parseSubjectAltNames(der []byte) ([]certificateName, error) {
+	var seq asn1.RawValue
+	if rest, err := asn1.Unmarshal(der, &seq); err != nil {
+		return nil, errors.Wrap(err, "error parsing subject alternative name extension")
+	} else if len(rest) > 0 || !seq.IsCompound || seq.Tag != asn1.TagSequence || seq.Class != asn1.ClassUniversal {
+		return nil, errors.New("error parsing subject alternative name extension: invalid sequence")
+	}
+
+	var names []certificateName
+	for rest := seq.Bytes; len(rest) > 0; {
+		var v asn1.RawValue
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &v); err != nil {
+			return nil, errors.Wrap(err, "error parsing subject alternative name extension")
+		}
+		if v.Class != asn1.ClassContextSpecific {
+			return nil, errors.New("error parsing subject alternative name extension: invalid name")
+		}
+		switch v.Tag {
+		case 1:
+			names = append(names, certificateName{EmailNameType, string(v.Bytes)})
+		case 2:
+			names = append(names, certificateName{DNSNameType, string(v.Bytes)})
+		case 6:
+			u, err := url.Parse(string(v.Bytes))
+			if err != nil {
+				return nil, errors.Wrap(err, "error parsing subject alternative name extension")
+			}
+			names = append(names, certificateName{URINameType, u.String()})
+		case 7:
+			if len(v.Bytes) != net.IPv4len && len(v.Bytes) != net.IPv6len {
+				return nil, errors.New("error parsing subject alternative name extension: invalid ip address")
+			}
+			names = append(names, certificateName{IPNameType, net.IP(v.Bytes).String()})
+		default:
+			typ := fmt.Sprintf("%d", v.Tag)
+			if v.Tag < len(generalNameTypes) {
+				typ = generalNameTypes[v.Tag]
+			}
+			names = append(names, certificateName{OtherNameType, typ})
+		}
+	}
+	return names, nil
+}
+
+type x509Policy struct {
+	allow              *rules
+	deny               *rules
+	allowWildcardNames bool
+}
+
+// evaluate returns the allowed rule that matches the name, or an error if the
+// name is not allowed. The rule is empty if there are no allowed rules.
+func (p *x509Policy) evaluate(typ NameType, name string) (string, error) {
+	if typ == DNSNameType && strings.HasPrefix(name, "*.") && !p.allowWildcardNames {
+		return "", &NamePolicyError{Reason: WildcardNotAllowed, NameType: typ, Name: name}
+	}
+	if rule, ok := p.deny.match(typ, name, true); ok {
+		return "", &NamePolicyError{Reason: Denied, NameType: typ, Name: name, Rule: rule}
+	}
+	if p.allow.isEmpty() {
+		return "", nil
+	}
+	if rule, ok := p.allow.match(typ, name, false); ok {
+		return rule, nil
+	}
+	return "", &NamePolicyError{Reason: NotAllowed, NameType: typ, Name: name}
+}
+
+func (p *x509Policy) evaluateName(n certificateName) (string, error) {
+	switch n.typ {
+	case CNNameType:
+		return p.evaluateCommonName(n.name)
+	case OtherNameType:
+		return "", &NamePolicyError{Reason: NotSupported, NameType: n.typ, Name: n.name}
+	default:
+		return p.evaluate(n.typ, n.name)
+	}
+}
+
+// evaluateCommonName evaluates the common name as one of the common names in
+// the policy, or as an IP address, email address or DNS name.
+func (p *x509Policy) evaluateCommonName(cn string) (string, error) {
+	if rule, ok := p.deny.match(CNNameType, cn, true); ok {
+		return "", &NamePolicyError{Reason: Denied, NameType: CNNameType, Name: cn, Rule: rule}
+	}
+	typ := DNSNameType
+	switch {
+	case net.ParseIP(cn) != nil:
+		typ = IPNameType
+	case strings.Contains(cn, "@"):
+		typ = EmailNameType
+	}
+	rule, err := p.evaluate(typ, cn)
+	if err != nil {
+		if pe, ok := err.(*NamePolicyError); ok && pe.Reason == NotAllowed {
+			if rule, ok := p.allow.match(CNNameType, cn, false); ok {
+				return rule, nil
+			}
+			pe.NameType = CNNameType
+		}
+		return "", err
+	}
+	return rule, nil
+}
+
+type sshPolicy struct {
+	allow *rules
+	deny  *rules
+}
+
+func newSSHPolicy(o *SSHCertificateOptions) (*sshPolicy, error) {
+	if o == nil {
+		return nil, nil
+	}
+	var err error
+	p := new(sshPolicy)
+	if p.allow, err = newSSHRules(o.Allow); err != nil {
+		return nil, errors.Wrap(err, "error parsing allow policy")
+	}
+	if p.deny, err = newSSHRules(o.Deny); err != nil {
+		return nil, errors.Wrap(err, "error parsing deny policy")
+	}
+	return p, nil
+}
+
+// evaluate returns the allowed rule that matches the principal, or an error
+// if the principal is not allowed. Host principals are evaluated as IP
+// addresses or DNS names, user principals as email addresses, and both as
+// principals.
+func (p *sshPolicy) evaluate(principal string, host bool) (string, error) {
+	var types []NameType
+	switch {
+	case host && net.ParseIP(principal) != nil:
+		types = []NameType{IPNameType, PrincipalNameType}
+	case host:
+		types = []NameType{DNSNameType, PrincipalNameType}
+	case strings.Contains(principal, "@"):
+		types = []NameType{EmailNameType, PrincipalNameType}
+	default:
+		types = []NameType{PrincipalNameType}
+	}
+	for _, typ := range types {
+		if rule, ok := p.deny.match(typ, principal, true); ok {
+			return "", &NamePolicyError{Reason: Denied, NameType: typ, Name: principal, Rule: rule}
+		}
+	}
+	if p.allow.isEmpty() {
+		return "", nil
+	}
+	for _, typ := range types {
+		if rule, ok := p.allow.match(typ, principal, false); ok {
+			return rule, nil
+		}
+	}
+	return "", &NamePolicyError{Reason: NotAllowed, NameType: types[0], Name: principal}
+}
+
+type ipRange struct {
+	rule string
+	net  *net.IPNet
+}
+
+type rules struct {
+	commonNames    []string
+	dnsDomains     []string
+	ipRanges       []ipRange
+	emailAddresses []string
+	uriDomains     []string
+	principals     []string
+}
+
+func newX509Rules(o *X509NameOptions) (*rules, error) {
+	if o == nil {
+		return &rules{}, nil
+	}
+	r := &rules{
+		commonNames: o.CommonNames,
+	}
+	if err := r.parse(o.DNSDomains, o.IPRanges, o.EmailAddresses); err != nil {
+		return nil, err
+	}
+	for _, s := range o.URIDomains {
+		d, err := parseDomain(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid uri domain %q", s)
+		}
+		r.uriDomains = append(r.uriDomains, d)
+	}
+	for _, s := range r.commonNames {
+		if s == "" {
+			return nil, errors.New("common name cannot be empty")
+		}
+	}
+	return r, nil
+}
+
+func newSSHRules(o *SSHNameOptions) (*rules, error) {
+	if o == nil {
+		return &rules{}, nil
+	}
+	r := &rules{
+		principals: o.Principals,
+	}
+	if err := r.parse(o.DNSDomains, o.IPRanges, o.EmailAddresses); err != nil {
+		return nil, err
+	}
+	for _, s := range r.principals {
+		if s == "" {
+			return nil, errors.New("principal cannot be empty")
+		}
+	}
+	return r, nil
+}
+
+func (r *rules) parse(dnsDomains, ipRanges, emailAddresses []string) error {
+	for _, s := range dnsDomains {
+		d, err := parseDomain(s)
+		if err != nil {
+			return errors.Wrapf(err, "invalid dns domain %q", s)
+		}
+		r.dnsDomains = append(r.dnsDomains, d)
+	}
+	for _, s := range ipRanges {
+		n, err := parseIPRange(s)
+		if err != nil {
+			return errors.Wrapf(err, "invalid ip range %q", s)
+		}
+		r.ipRanges = append(r.ipRanges, ipRange{rule: s, net: n})
+	}
+	for _, s := range emailAddresses {
+		e, err := parseEmail(s)
+		if err != nil {
+			return errors.Wrapf(err, "invalid email address %q", s)
+		}
+		r.emailAddresses = append(r.emailAddresses, e)
+	}
+	return nil
+}
+
+func (r *rules) isEmpty() bool {
+	return len(r.commonNames) == 0 && len(r.dnsDomains) == 0 && len(r.ipRanges) == 0 &&
+		len(r.emailAddresses) == 0 && len(r.uriDomains) == 0 && len(r.principals) == 0
+}
+
+// match returns the first rule that matches the name of the given type. If
+// overlap is true, wildcard DNS names also match the rules of the names they
+// are valid for, this is used with the denied rules.
+func (r *rules) match(typ NameType, name string, overlap bool) (string, bool) {
+	switch typ {
+	case CNNameType:
+		for _, rule := range r.commonNames {
+			if rule == name {
+				return rule, true
+			}
+		}
+	case DNSNameType:
+		name = normalizeDomain(name)
+		for _, rule := range r.dnsDomains {
+			if matchDomain(rule, name) || (overlap && overlapsDomain(rule, name)) {
+				return rule, true
+			}
+		}
+	case IPNameType:
+		if ip := net.ParseIP(name); ip != nil {
+			for _, rule := range r.ipRanges {
+				if rule.net.Contains(ip) {
+					return rule.rule, true
+				}
+			}
+		}
+	case EmailNameType:
+		i := strings.LastIndex(name, "@")
+		if i <= 0 {
+			return "", false
+		}
+		local, domain := name[:i], normalizeDomain(name[i+1:])
+		for _, rule := range r.emailAddresses {
+			if j := strings.LastIndex(rule, "@"); j >= 0 {
+				if rule[:j] == local && rule[j+1:] == domain {
+					return rule, true
+				}
+			} else if matchDomain(rule, domain) {
+				return rule, true
+			}
+		}
+	case URINameType:
+		u, err := url.Parse(name)
+		if err != nil || u.Hostname() == "" {
+			return "", false
+		}
+		host := normalizeDomain(u.Hostname())
+		for _, rule := range r.uriDomains {
+			if matchDomain(rule, host) {
+				return rule, true
+			}
+		}
+	case PrincipalNameType:
+		for _, rule := range r.principals {
+			if rule == "*" || rule == name {
+				return rule, true
+			}
+		}
+	}
+	return "", false
+}
+
+func normalizeDomain(s string) string {
+	return strings.ToLower(strings.TrimSuffix(s, "."))
+}
+
+func parseDomain(s string) (string, error) {
+	d := normalizeDomain(s)
+	base := strings.TrimPrefix(d, "*.")
+	switch {
+	case base == "":
+		return "", errors.New("domain cannot be empty")
+	case strings.ContainsAny(base, "*@/: \t"):
+		return "", errors.New("domain contains invalid characters")
+	case strings.HasPrefix(base, ".") || strings.Contains(base, ".."):
+		return "", errors.New("domain contains empty labels")
+	}
+	return d, nil
+}
+
+func parseIPRange(s string) (*net.IPNet, error) {
+	if _, n, err := net.ParseCIDR(s); err == nil {
+		return n, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, errors.New("ip range must be an IP address or a CIDR")
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+func parseEmail(s string) (string, error) {
+	i := strings.LastIndex(s, "@")
+	if i < 0 {
+		return parseDomain(s)
+	}
+	domain, err := parseDomain(s[i+1:])
+	if err != nil {
+		return "", err
+	}
+	if i == 0 {
+		return domain, nil
+	}
+	return s[:i] + "@" + domain, nil
+}
+
+// matchDomain returns true if the name matches the rule. A rule starting with
+// "*." matches all the subdomains of the domain.
+func matchDomain(rule, name string) bool {
+	if strings.HasPrefix(rule, "*.") {
+		suffix := rule[1:]
+		return len(name) > len(suffix) && strings.HasSuffix(name, suffix)
+	}
+	return rule == name
+}
+
+// overlapsDomain returns true if the name is a wildcard name valid for one of
+// the names matched by the rule, e.g. *.example.com and www.example.com.
+func overlapsDomain(rule, name string) bool {
+	if !strings.HasPrefix(name, "*.") {
+		return false
+	}
+	return strings.HasSuffix(strings.TrimPrefix(rule, "*"), name[1:])
+}
@@ -0,0 +1,393 @@
+package policy
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"net"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func mustURL(t *testing.T, s string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    *Options
+		wantNil bool
+		wantErr bool
+	}{
+		{"ok nil", nil, true, false},
+		{"ok empty", &Options{}, false, false},
+		{"ok x509", &Options{X509: &X509Options{
+			Allow: &X509NameOptions{
+				CommonNames:    []string{"Jane Doe"},
+				DNSDomains:     []string{"*.example.com", "example.com."},
+				IPRanges:       []string{"10.0.0.0/8", "192.168.1.1", "2001:db8::/32"},
+				EmailAddresses: []string{"example.com", "@example.org", "jane@example.net", "*.example.com"},
+				URIDomains:     []string{"*.example.com"},
+			},
+			Deny: &X509NameOptions{
+				DNSDomains: []string{"secret.example.com"},
+			},
+		}}, false, false},
+		{"ok ssh", &Options{SSH: &SSHOptions{
+			User: &SSHCertificateOptions{Allow: &SSHNameOptions{EmailAddresses: []string{"example.com"}, Principals: []string{"jane"}}},
+			Host: &SSHCertificateOptions{Deny: &SSHNameOptions{DNSDomains: []string{"*.internal"}, IPRanges: []string{"10.0.0.0/8"}}},
+		}}, false, false},
+		{"fail dns", &Options{X509: &X509Options{Allow: &X509NameOptions{DNSDomains: []string{"*.*.example.com"}}}}, false, true},
+		{"fail dns empty", &Options{X509: &X509Options{Deny: &X509NameOptions{DNSDomains: []string{"*."}}}}, false, true},
+		{"fail dns labels", &Options{X509: &X509Options{Deny: &X509NameOptions{DNSDomains: []string{"foo..example.com"}}}}, false, true},
+		{"fail ip", &Options{X509: &X509Options{Allow: &X509NameOptions{IPRanges: []string{"10.0.0.0/33"}}}}, false, true},
+		{"fail email", &Options{X509: &X509Options{Allow: &X509NameOptions{EmailAddresses: []string{"jane@"}}}}, false, true},
+		{"fail uri", &Options{X509: &X509Options{Allow: &X509NameOptions{URIDomains: []string{"https://example.com"}}}}, false, true},
+		{"fail cn", &Options{X509: &X509Options{Allow: &X509NameOptions{CommonNames: []string{""}}}}, false, true},
+		{"fail ssh user", &Options{SSH: &SSHOptions{User: &SSHCertificateOptions{Allow: &SSHNameOptions{Principals: []string{""}}}}}, false, true},
+		{"fail ssh host", &Options{SSH: &SSHOptions{Host: &SSHCertificateOptions{Deny: &SSHNameOptions{IPRanges: []string{"foo"}}}}}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if (got == nil) != (tt.wantNil || tt.wantErr) {
+				t.Errorf("New() = %v, wantNil %v", got, tt.wantNil)
+			}
+			if err := tt.opts.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Options.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// mustSANExtension returns a subject alternative name extension with the given
+// general names.
+func mustSANExtension(t *testing.T, names ...asn1.RawValue) pkix.Extension {
+	t.Helper()
+	b, err := asn1.Marshal(names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkix.Extension{Id: asn1.ObjectIdentifier{2, 5, 29, 17}, Value: b}
+}
+
+func generalName(tag int, b []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: tag, Bytes: b}
+}
+
+func TestEngine_IsX509CertificateAllowed(t *testing.T) {
+	allow := &Options{X509: &X509Options{
+		Allow: &X509NameOptions{
+			CommonNames:    []string{"Jane Doe"},
+			DNSDomains:     []string{"*.teamx.internal", "teamx.internal"},
+			IPRanges:       []string{"10.0.0.0/8", "2001:db8::1"},
+			EmailAddresses: []string{"teamx.internal", "jane@example.com"},
+			URIDomains:     []string{"*.teamx.internal"},
+		},
+		Deny: &X509NameOptions{
+			CommonNames:    []string{"John Doe"},
+			DNSDomains:     []string{"secret.teamx.internal"},
+			IPRanges:       []string{"10.10.0.0/16"},
+			EmailAddresses: []string{"root@teamx.internal"},
+			URIDomains:     []string{"secret.teamx.internal"},
+		},
+	}}
+	wildcard := &Options{X509: &X509Options{
+		Allow:              &X509NameOptions{DNSDomains: []string{"*.teamx.internal"}},
+		Deny:               &X509NameOptions{DNSDomains: []string{"secret.teamx.internal"}},
+		AllowWildcardNames: true,
+	}}
+	deny := &Options{X509: &X509Options{
+		Deny: &X509NameOptions{DNSDomains: []string{"*.example.com"}},
+	}}
+
+	tests := []struct {
+		name string
+		opts *Options
+		cert *x509.Certificate
+		want error
+	}{
+		{"ok nil", nil, &x509.Certificate{DNSNames: []string{"example.com"}}, nil},
+		{"ok no x509", &Options{SSH: &SSHOptions{}}, &x509.Certificate{DNSNames: []string{"example.com"}}, nil},
+		{"ok", allow, &x509.Certificate{
+			Subject:        pkix.Name{CommonName: "foo.teamx.internal"},
+			DNSNames:       []string{"foo.teamx.internal", "bar.foo.teamx.internal", "TEAMX.internal."},
+			IPAddresses:    []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("2001:db8::1")},
+			EmailAddresses: []string{"john@teamx.internal", "jane@example.com"},
+			URIs:           []*url.URL{mustURL(t, "spiffe://foo.teamx.internal/service")},
+		}, nil},
+		{"ok common name", allow, &x509.Certificate{Subject: pkix.Name{CommonName: "Jane Doe"}}, nil},
+		{"ok common name ip", allow, &x509.Certificate{Subject: pkix.Name{CommonName: "10.0.0.1"}}, nil},
+		{"ok common name email", allow, &x509.Certificate{Subject: pkix.Name{CommonName: "jane@example.com"}}, nil},
+		{"ok wildcard", wildcard, &x509.Certificate{DNSNames: []string{"*.foo.teamx.internal", "www.teamx.internal"}}, nil},
+		{"ok deny only", deny, &x509.Certificate{DNSNames: []string{"example.com", "example.org"}}, nil},
+		{"fail dns", allow, &x509.Certificate{DNSNames: []string{"foo.teamy.internal"}},
+			&NamePolicyError{Reason: NotAllowed, NameType: DNSNameType, Name: "foo.teamy.internal"}},
+		{"fail dns suffix", allow, &x509.Certificate{DNSNames: []string{"footeamx.internal"}},
+			&NamePolicyError{Reason: NotAllowed, NameType: DNSNameType, Name: "footeamx.internal"}},
+		{"fail dns denied", allow, &x509.Certificate{DNSNames: []string{"Secret.teamx.internal"}},
+			&NamePolicyError{Reason: Denied, NameType: DNSNameType, Name: "Secret.teamx.internal", Rule: "secret.teamx.internal"}},
+		{"fail wildcard", allow, &x509.Certificate{DNSNames: []string{"*.teamx.internal"}},
+			&NamePolicyError{Reason: WildcardNotAllowed, NameType: DNSNameType, Name: "*.teamx.internal"}},
+		{"fail wildcard denied", wildcard, &x509.Certificate{DNSNames: []string{"*.teamx.internal"}},
+			&NamePolicyError{Reason: Denied, NameType: DNSNameType, Name: "*.teamx.internal", Rule: "secret.teamx.internal"}},
+		{"fail wildcard other", wildcard, &x509.Certificate{DNSNames: []string{"*.teamy.internal"}},
+			&NamePolicyError{Reason: NotAllowed, NameType: DNSNameType, Name: "*.teamy.internal"}},
+		{"fail ip", allow, &x509.Certificate{IPAddresses: []net.IP{net.ParseIP("192.168.1.1")}},
+			&NamePolicyError{Reason: NotAllowed, NameType: IPNameType, Name: "192.168.1.1"}},
+		{"fail ip denied", allow, &x509.Certificate{IPAddresses: []net.IP{net.ParseIP("10.10.1.1")}},
+			&NamePolicyError{Reason: Denied, NameType: IPNameType, Name: "10.10.1.1", Rule: "10.10.0.0/16"}},
+		{"fail email", allow, &x509.Certificate{EmailAddresses: []string{"john@example.com"}},
+			&NamePolicyError{Reason: NotAllowed, NameType: EmailNameType, Name: "john@example.com"}},
+		{"fail email denied", allow, &x509.Certificate{EmailAddresses: []string{"root@teamx.internal"}},
+			&NamePolicyError{Reason: Denied, NameType: EmailNameType, Name: "root@teamx.internal", Rule: "root@teamx.internal"}},
+		{"fail uri", allow, &x509.Certificate{URIs: []*url.URL{mustURL(t, "https://example.com")}},
+			&NamePolicyError{Reason: NotAllowed, NameType: URINameType, Name: "https://example.com"}},
+		{"fail uri no host", allow, &x509.Certificate{URIs: []*url.URL{mustURL(t, "urn:uuid:f81d4fae-7dec-11d0-a765-00a0c91e6bf6")}},
+			&NamePolicyError{Reason: NotAllowed, NameType: URINameType, Name: "urn:uuid:f81d4fae-7dec-11d0-a765-00a0c91e6bf6"}},
+		{"fail uri denied", allow, &x509.Certificate{URIs: []*url.URL{mustURL(t, "https://secret.teamx.internal:443/path")}},
+			&NamePolicyError{Reason: Denied, NameType: URINameType, Name: "https://secret.teamx.internal:443/path", Rule: "secret.teamx.internal"}},
+		{"fail common name", allow, &x509.Certificate{Subject: pkix.Name{CommonName: "Mallory"}},
+			&NamePolicyError{Reason: NotAllowed, NameType: CNNameType, Name: "Mallory"}},
+		{"fail common name denied", allow, &x509.Certificate{Subject: pkix.Name{CommonName: "John Doe"}},
+			&NamePolicyError{Reason: Denied, NameType: CNNameType, Name: "John Doe", Rule: "John Doe"}},
+		{"fail common name dns denied", allow, &x509.Certificate{Subject: pkix.Name{CommonName: "secret.teamx.internal"}},
+			&NamePolicyError{Reason: Denied, NameType: DNSNameType, Name: "secret.teamx.internal", Rule: "secret.teamx.internal"}},
+		{"fail deny only", deny, &x509.Certificate{DNSNames: []string{"example.org", "www.example.com"}},
+			&NamePolicyError{Reason: Denied, NameType: DNSNameType, Name: "www.example.com", Rule: "*.example.com"}},
+		{"ok san extension", allow, &x509.Certificate{ExtraExtensions: []pkix.Extension{mustSANExtension(t,
+			generalName(2, []byte("foo.teamx.internal")),
+			generalName(1, []byte("jane@example.com")),
+			generalName(6, []byte("spiffe://foo.teamx.internal/service")),
+			generalName(7, []byte{10, 0, 0, 1}),
+		)}}, nil},
+		{"ok san extension no x509", &Options{SSH: &SSHOptions{}}, &x509.Certificate{ExtraExtensions: []pkix.Extension{mustSANExtension(t,
+			generalName(0, []byte{0x06, 0x01, 0x2a}),
+		)}}, nil},
+		{"fail san extension dns", allow, &x509.Certificate{
+			DNSNames:        []string{"foo.teamx.internal"},
+			ExtraExtensions: []pkix.Extension{mustSANExtension(t, generalName(2, []byte("foo.teamy.internal")))},
+		}, &NamePolicyError{Reason: NotAllowed, NameType: DNSNameType, Name: "foo.teamy.internal"}},
+		{"fail san extension ip denied", allow, &x509.Certificate{
+			ExtraExtensions: []pkix.Extension{mustSANExtension(t, generalName(7, []byte{10, 10, 0, 1}))},
+		}, &NamePolicyError{Reason: Denied, NameType: IPNameType, Name: "10.10.0.1", Rule: "10.10.0.0/16"}},
+		{"fail san extension other name", deny, &x509.Certificate{
+			ExtraExtensions: []pkix.Extension{mustSANExtension(t, generalName(0, []byte{0x06, 0x01, 0x2a}))},
+		}, &NamePolicyError{Reason: NotSupported, NameType: OtherNameType, Name: "otherName"}},
+		{"fail san extension directory name", deny, &x509.Certificate{
+			ExtraExtensions: []pkix.Extension{mustSANExtension(t, generalName(4, []byte{0x30, 0x00}))},
+		}, &NamePolicyError{Reason: NotSupported, NameType: OtherNameType, Name: "directoryName"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := New(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = e.IsX509CertificateAllowed(tt.cert)
+			if !reflect.DeepEqual(err, tt.want) {
+				t.Errorf("Engine.IsX509CertificateAllowed() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("fail san extension malformed", func(t *testing.T) {
+		e, err := New(deny)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert := &x509.Certificate{ExtraExtensions: []pkix.Extension{
+			{Id: asn1.ObjectIdentifier{2, 5, 29, 17}, Value: []byte{0x30, 0x05, 0x82}},
+		}}
+		if err := e.IsX509CertificateAllowed(cert); err == nil {
+			t.Error("Engine.IsX509CertificateAllowed() error = nil, want error")
+		}
+	})
+}
+
+func TestEngine_IsSSHCertificateAllowed(t *testing.T) {
+	opts := &Options{SSH: &SSHOptions{
+		User: &SSHCertificateOptions{
+			Allow: &SSHNameOptions{EmailAddresses: []string{"teamx.internal"}, Principals: []string{"jane", "john"}},
+			Deny:  &SSHNameOptions{EmailAddresses: []string{"root@teamx.internal"}, Principals: []string{"root"}},
+		},
+		Host: &SSHCertificateOptions{
+			Allow: &SSHNameOptions{DNSDomains: []string{"*.teamx.internal"}, IPRanges: []string{"10.0.0.0/8"}, Principals: []string{"bastion"}},
+			Deny:  &SSHNameOptions{DNSDomains: []string{"db.teamx.internal"}},
+		},
+	}}
+	hostOnly := &Options{SSH: &SSHOptions{
+		Host: &SSHCertificateOptions{Allow: &SSHNameOptions{Principals: []string{"*"}}},
+	}}
+
+	user := func(principals ...string) *ssh.Certificate {
+		return &ssh.Certificate{CertType: ssh.UserCert, ValidPrincipals: principals}
+	}
+	host := func(principals ...string) *ssh.Certificate {
+		return &ssh.Certificate{CertType: ssh.HostCert, ValidPrincipals: principals}
+	}
+
+	tests := []struct {
+		name string
+		opts *Options
+		cert *ssh.Certificate
+		want error
+	}{
+		{"ok nil", nil, user("root"), nil},
+		{"ok user", opts, user("jane", "john", "jane@teamx.internal"), nil},
+		{"ok host", opts, host("foo.teamx.internal", "10.1.2.3", "bastion"), nil},
+		{"ok user not configured", hostOnly, user("root"), nil},
+		{"ok host any", hostOnly, host("foo.example.com"), nil},
+		{"fail user", opts, user("jane", "mallory"),
+			&NamePolicyError{Reason: NotAllowed, NameType: PrincipalNameType, Name: "mallory"}},
+		{"fail user email", opts, user("mallory@example.com"),
+			&NamePolicyError{Reason: NotAllowed, NameType: EmailNameType, Name: "mallory@example.com"}},
+		{"fail user denied", opts, user("root"),
+			&NamePolicyError{Reason: Denied, NameType: PrincipalNameType, Name: "root", Rule: "root"}},
+		{"fail user email denied", opts, user("root@teamx.internal"),
+			&NamePolicyError{Reason: Denied, NameType: EmailNameType, Name: "root@teamx.internal", Rule: "root@teamx.internal"}},
+		{"fail user no principals", opts, user(),
+			&NamePolicyError{Reason: NoPrincipals, NameType: PrincipalNameType}},
+		{"fail host", opts, host("foo.example.com"),
+			&NamePolicyError{Reason: NotAllowed, NameType: DNSNameType, Name: "foo.example.com"}},
+		{"fail host ip", opts, host("192.168.1.1"),
+			&NamePolicyError{Reason: NotAllowed, NameType: IPNameType, Name: "192.168.1.1"}},
+		{"fail host denied", opts, host("db.teamx.internal"),
+			&NamePolicyError{Reason: Denied, NameType: DNSNameType, Name: "db.teamx.internal", Rule: "db.teamx.internal"}},
+		{"fail host no principals", hostOnly, host(),
+			&NamePolicyError{Reason: NoPrincipals, NameType: PrincipalNameType}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := New(tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = e.IsSSHCertificateAllowed(tt.cert)
+			if !reflect.DeepEqual(err, tt.want) {
+				t.Errorf("Engine.IsSSHCertificateAllowed() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamePolicyError_Error(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *NamePolicyError
+		want string
+	}{
+		{"not allowed", &NamePolicyError{Reason: NotAllowed, NameType: DNSNameType, Name: "example.com"}, `dns name "example.com" is not allowed`},
+		{"denied", &NamePolicyError{Reason: Denied, NameType: IPNameType, Name: "10.0.0.1", Rule: "10.0.0.0/8"}, `ip name "10.0.0.1" is denied by the rule "10.0.0.0/8"`},
+		{"wildcard", &NamePolicyError{Reason: WildcardNotAllowed, NameType: DNSNameType, Name: "*.example.com"}, `dns name "*.example.com" is a wildcard name and wildcard names are not allowed`},
+		{"no principals", &NamePolicyError{Reason: NoPrincipals, NameType: PrincipalNameType}, "ssh certificates without principals are not allowed"},
+		{"not supported", &NamePolicyError{Reason: NotSupported, NameType: OtherNameType, Name: "otherName"}, "subject alternative name of type otherName is not supported by the policy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("NamePolicyError.Error() = %v, want %v", got, tt.want)
+			}
+			var pe *NamePolicyError
+			if !errors.As(error(tt.err), &pe) {
+				t.Errorf("errors.As() = false, want true")
+			}
+		})
+	}
+}
+
+func TestEngine_EvaluateX509Certificate(t *testing.T) {
+	e, err := New(&Options{X509: &X509Options{
+		Allow: &X509NameOptions{DNSDomains: []string{"*.teamx.internal"}, IPRanges: []string{"10.0.0.0/8"}},
+		Deny:  &X509NameOptions{DNSDomains: []string{"secret.teamx.internal"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "foo.teamx.internal"},
+		DNSNames:    []string{"foo.teamx.internal", "secret.teamx.internal", "foo.teamy.internal"},
+		IPAddresses: []net.IP{net.ParseIP("10.0.0.1")},
+	}
+
+	tests := []struct {
+		name   string
+		engine *Engine
+		want   []Evaluation
+	}{
+		{"ok", e, []Evaluation{
+			{NameType: CNNameType, Name: "foo.teamx.internal", Allowed: true, Rule: "*.teamx.internal"},
+			{NameType: DNSNameType, Name: "foo.teamx.internal", Allowed: true, Rule: "*.teamx.internal"},
+			{NameType: DNSNameType, Name: "secret.teamx.internal", Rule: "secret.teamx.internal", Error: `dns name "secret.teamx.internal" is denied by the rule "secret.teamx.internal"`},
+			{NameType: DNSNameType, Name: "foo.teamy.internal", Error: `dns name "foo.teamy.internal" is not allowed`},
+			{NameType: IPNameType, Name: "10.0.0.1", Allowed: true, Rule: "10.0.0.0/8"},
+		}},
+		{"ok nil", nil, []Evaluation{
+			{NameType: CNNameType, Name: "foo.teamx.internal", Allowed: true},
+			{NameType: DNSNameType, Name: "foo.teamx.internal", Allowed: true},
+			{NameType: DNSNameType, Name: "secret.teamx.internal", Allowed: true},
+			{NameType: DNSNameType, Name: "foo.teamy.internal", Allowed: true},
+			{NameType: IPNameType, Name: "10.0.0.1", Allowed: true},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.engine.EvaluateX509Certificate(cert); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Engine.EvaluateX509Certificate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngine_EvaluateSSHCertificate(t *testing.T) {
+	e, err := New(&Options{SSH: &SSHOptions{
+		User: &SSHCertificateOptions{
+			Allow: &SSHNameOptions{Principals: []string{"jane"}},
+			Deny:  &SSHNameOptions{Principals: []string{"root"}},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		engine *Engine
+		cert   *ssh.Certificate
+		want   []Evaluation
+	}{
+		{"ok", e, &ssh.Certificate{CertType: ssh.UserCert, ValidPrincipals: []string{"jane", "root", "john"}}, []Evaluation{
+			{NameType: PrincipalNameType, Name: "jane", Allowed: true, Rule: "jane"},
+			{NameType: PrincipalNameType, Name: "root", Rule: "root", Error: `principal name "root" is denied by the rule "root"`},
+			{NameType: PrincipalNameType, Name: "john", Error: `principal name "john" is not allowed`},
+		}},
+		{"ok host", e, &ssh.Certificate{CertType: ssh.HostCert, ValidPrincipals: []string{"root"}}, []Evaluation{
+			{NameType: PrincipalNameType, Name: "root", Allowed: true},
+		}},
+		{"ok nil", nil, &ssh.Certificate{CertType: ssh.UserCert}, []Evaluation{}},
+		{"fail no principals", e, &ssh.Certificate{CertType: ssh.UserCert}, []Evaluation{
+			{NameType: PrincipalNameType, Error: "ssh certificates without principals are not allowed"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.engine.EvaluateSSHCertificate(tt.cert); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Engine.EvaluateSSHCertificate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package policy
+
+// Options contains the name policies of the X.509 and SSH certificates. They
+// can be configured in the authority and in each provisioner; a name must be
+// allowed by both policies to be included in a certificate.
+type Options struct {
+	X509 *X509Options `json:"x509,omitempty"`
+	SSH  *SSHOptions  `json:"ssh,omitempty"`
+}
+
+// GetX509Options returns the X.509 policy options.
+func (o *Options) GetX509Options() *X509Options {
+	if o == nil {
+		return nil
+	}
+	return o.X509
+}
+
+// GetSSHOptions returns the SSH policy options.
+func (o *Options) GetSSHOptions() *SSHOptions {
+	if o == nil {
+		return nil
+	}
+	return o.SSH
+}
+
+// Validate returns an error if the policy options are not valid.
+func (o *Options) Validate() error {
+	_, err := New(o)
+	return err
+}
+
+// X509Options contains the allowed and denied names of the X.509
+// certificates.
+type X509Options struct {
+	// Allow contains the names that are allowed. If at least one name is
+	// configured, all the names not explicitly allowed are denied.
+	Allow *X509NameOptions `json:"allow,omitempty"`
+
+	// Deny contains the names that are denied. Denied names take precedence
+	// over the allowed ones.
+	Deny *X509NameOptions `json:"deny,omitempty"`
+
+	// AllowWildcardNames allows wildcard DNS names, e.g. *.example.com, in
+	// the certificates. Wildcard names are denied by default.
+	AllowWildcardNames bool `json:"allowWildcardNames,omitempty"`
+}
+
+// X509NameOptions contains the X.509 name rules.
+type X509NameOptions struct {
+	// CommonNames is the list of exact subject common names.
+	CommonNames []string `json:"cn,omitempty"`
+
+	// DNSDomains is the list of DNS names. A name starting with "*." matches
+	// all the subdomains of the domain, e.g. *.example.com matches
+	// www.example.com but not example.com.
+	DNSDomains []string `json:"dns,omitempty"`
+
+	// IPRanges is the list of IP addresses or CIDR ranges.
+	IPRanges []string `json:"ip,omitempty"`
+
+	// EmailAddresses is the list of email addresses or email domains, e.g.
+	// jane@example.com, example.com or *.example.com.
+	EmailAddresses []string `json:"email,omitempty"`
+
+	// URIDomains is the list of hosts of the URIs, e.g. example.com or
+	// *.example.com.
+	URIDomains []string `json:"uri,omitempty"`
+}
+
+// SSHOptions contains the name policies of the SSH user and host
+// certificates.
+type SSHOptions struct {
+	User *SSHCertificateOptions `json:"user,omitempty"`
+	Host *SSHCertificateOptions `json:"host,omitempty"`
+}
+
+// SSHCertificateOptions contains the allowed and denied principals of a type
+// of SSH certificate.
+type SSHCertificateOptions struct {
+	// Allow contains the principals that are allowed. If at least one is
+	// configured, all the principals not explicitly allowed are denied.
+	Allow *SSHNameOptions `json:"allow,omitempty"`
+
+	// Deny contains the principals that are denied. Denied principals take
+	// precedence over the allowed ones.
+	Deny *SSHNameOptions `json:"deny,omitempty"`
+}
+
+// SSHNameOptions contains the SSH principal rules. Host principals are matched
+// as IP addresses, DNS names or principals, and user principals as email
+// addresses or principals.
+type SSHNameOptions struct {
+	DNSDomains     []string `json:"dns,omitempty"`
+	IPRanges       []string `json:"ip,omitempty"`
+	EmailAddresses []string `json:"email,omitempty"`
+
+	// Principals is the list of exact principals, "*" matches all of them.
+	Principals []string `json:"principal,omitempty"`
+}
@@ -284,16 +284,38 @@ func (h *handler) PKIOperation(ctx context.Context, req request) (response, erro
 		return response{}, err
 	}
 
+	switch msg.MessageType {
+	case microscep.GetCert:
+		return h.createResponse(ctx, msg, h.auth.GetCert)
+	case microscep.GetCRL:
+		return h.createResponse(ctx, msg, h.auth.GetCRL)
+	case microscep.CertPoll:
+		return h.createResponse(ctx, msg, h.auth.PollCSR)
+	}
+
 	// NOTE: at this point we have sufficient information for returning nicely signed CertReps
 	csr := msg.CSRReqMessage.CSR
 
-	// NOTE: we're blocking the RenewalReq if the challenge does not match, because otherwise we don't have any authentication.
-	// The macOS SCEP client performs renewals using PKCSreq. The CertNanny SCEP client will use PKCSreq with challenge too, it seems,
-	// even if using the renewal flow as described in the README.md. MicroMDM SCEP client also only does PKCSreq by default, unless
-	// a certificate exists; then it will use RenewalReq. Adding the challenge check here may be a small breaking change for clients.
-	// We'll have to see how it works out.
-	if msg.MessageType == microscep.PKCSReq || msg.MessageType == microscep.RenewalReq {
-		challengeMatches, err := h.auth.MatchChallengePassword(ctx, msg.CSRReqMessage.ChallengePassword)
+	// A RenewalReq signed with a valid certificate issued by the CA does not
+	// require the challenge. Otherwise, the challenge is required as in a
+	// PKCSReq. The macOS SCEP client performs renewals using PKCSReq. The
+	// CertNanny SCEP client will use PKCSreq with challenge too, it seems, even
+	// if using the renewal flow as described in the README.md. MicroMDM SCEP
+	// client also only does PKCSreq by default, unless a certificate exists;
+	// then it will use RenewalReq.
+	requireChallenge := msg.MessageType == microscep.PKCSReq || msg.MessageType == microscep.RenewalReq
+	if msg.MessageType == microscep.RenewalReq {
+		err := h.auth.AuthorizeRenewal(ctx, msg)
+		switch {
+		case err == nil:
+			requireChallenge = false
+		case msg.CSRReqMessage.ChallengePassword == "":
+			return h.createFailureResponse(ctx, csr, msg, microscep.BadRequest, fmt.Errorf("error authorizing renewal: %w", err))
+		}
+	}
+
+	if requireChallenge {
+		challengeMatches, err := h.auth.MatchChallengePassword(ctx, csr, msg.CSRReqMessage.ChallengePassword)
 		if err != nil {
 			return h.createFailureResponse(ctx, csr, msg, microscep.BadRequest, errors.New("error when checking password"))
 		}
@@ -303,13 +325,18 @@ func (h *handler) PKIOperation(ctx context.Context, req request) (response, erro
 		}
 	}
 
-	// TODO: authorize renewal: we can authorize renewals with the challenge password (if reusable secrets are used).
-	// Renewals OPTIONALLY include the challenge if the existing cert is used as authentication, but client SHOULD omit the challenge.
-	// This means that for renewal requests we should check the certificate provided to be signed before by the CA. We could
-	// enforce use of the challenge if we want too. That way we could be more flexible in terms of authentication scheme (i.e. reusing
-	// tokens from other provisioners, calling a webhook, storing multiple secrets, allowing them to be multi-use, etc).
-	// Authentication by the (self-signed) certificate with an optional challenge is required; supporting renewals incl. verification
-	// of the client cert is not.
+	// Requests authorized with a challenge are queued until an admin approves
+	// them if the provisioner requires it.
+	if requireChallenge && h.auth.RequiresApproval(ctx) {
+		certRep, err := h.auth.QueueCSR(ctx, csr, msg)
+		if err != nil {
+			return h.createFailureResponse(ctx, csr, msg, microscep.BadRequest, fmt.Errorf("error when queueing request: %w", err))
+		}
+		return response{
+			Operation: opnPKIOperation,
+			Data:      certRep.Raw,
+		}, nil
+	}
 
 	certRep, err := h.auth.SignCSR(ctx, csr, msg)
 	if err != nil {
@@ -362,6 +389,20 @@ func (h *handler) createFailureResponse(ctx context.Context, csr *x509.Certifica
 	}, nil
 }
 
+// createResponse creates the response of a PKI operation that does not use a
+// CSR. Errors are returned to the client as a failure response.
+func (h *handler) createResponse(ctx context.Context, msg *scep.PKIMessage, fn func(context.Context, *scep.PKIMessage) (*scep.PKIMessage, error)) (response, error) {
+	certRep, err := fn(ctx, msg)
+	if err != nil {
+		return h.createFailureResponse(ctx, nil, msg, microscep.BadRequest, err)
+	}
+	return response{
+		Operation:   opnPKIOperation,
+		Data:        certRep.Raw,
+		Certificate: certRep.Certificate,
+	}, nil
+}
+
 func contentHeader(r response) string {
 	switch r.Operation {
 	default:
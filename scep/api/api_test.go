@@ -0,0 +1,245 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	microscep "github.com/micromdm/scep/v2/scep"
+	"go.mozilla.org/pkcs7"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+	"github.com/smallstep/certificates/scep"
+)
+
+// SCEP OIDs of the signed attributes of a request.
+var (
+	oidSCEPmessageType   = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 2}
+	oidSCEPsenderNonce   = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 5}
+	oidSCEPtransactionID = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 7}
+)
+
+type mockSignAuthority struct {
+	prov    provisioner.Interface
+	roots   []*x509.Certificate
+	issuer  *x509.Certificate
+	signer  crypto.Signer
+	revoked map[string]bool
+}
+
+func (m *mockSignAuthority) Sign(cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]*x509.Certificate, error) {
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(now.UnixNano()),
+		Subject:      cr.Subject,
+		NotBefore:    now,
+		NotAfter:     now.Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, m.issuer, cr.PublicKey, m.signer)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return []*x509.Certificate{cert, m.issuer}, nil
+}
+
+func (m *mockSignAuthority) LoadProvisionerByName(name string) (provisioner.Interface, error) {
+	if m.prov == nil || m.prov.GetName() != name {
+		return nil, errors.New("provisioner not found")
+	}
+	return m.prov, nil
+}
+
+func (m *mockSignAuthority) GetRoots() ([]*x509.Certificate, error) {
+	return m.roots, nil
+}
+
+func (m *mockSignAuthority) IsRevoked(sn string) (bool, error) {
+	return m.revoked[sn], nil
+}
+
+func mustRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func mustCertificate(t *testing.T, tmpl, parent *x509.Certificate, pub crypto.PublicKey, signer crypto.Signer) *x509.Certificate {
+	t.Helper()
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, pub, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// newRenewalRequest creates a RenewalReq with a CSR for the given common name,
+// encrypted for the CA and signed by the client certificate.
+func newRenewalRequest(t *testing.T, caCert, cert *x509.Certificate, key crypto.Signer, cn string) []byte {
+	t.Helper()
+	csrDER, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: cn},
+	}, mustRSAKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e7, err := pkcs7.Encrypt(csrDER, []*x509.Certificate{caCert})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd, err := pkcs7.NewSignedData(e7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sd.AddSigner(cert, key, pkcs7.SignerInfoConfig{
+		ExtraSignedAttributes: []pkcs7.Attribute{
+			{Type: oidSCEPtransactionID, Value: microscep.TransactionID("transaction-id")},
+			{Type: oidSCEPmessageType, Value: microscep.RenewalReq},
+			{Type: oidSCEPsenderNonce, Value: microscep.SenderNonce([]byte("sender-nonce"))},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := sd.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestHandler_PKIOperation_renewal(t *testing.T) {
+	// Root and intermediate, the intermediate is also the SCEP decrypter.
+	rootKey, intKey := mustRSAKey(t), mustRSAKey(t)
+	notBefore := time.Now().Add(-time.Hour)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	root := mustCertificate(t, caTmpl, caTmpl, rootKey.Public(), rootKey)
+	caTmpl.SerialNumber = big.NewInt(2)
+	caTmpl.Subject = pkix.Name{CommonName: "Test Intermediate CA"}
+	intermediate := mustCertificate(t, caTmpl, root, intKey.Public(), rootKey)
+
+	prov := &provisioner.SCEP{Type: "SCEP", Name: "scep"}
+	disableRenewal, allowRenewalAfterExpiry := false, false
+	if err := prov.Init(provisioner.Config{Claims: provisioner.Claims{
+		MinTLSDur:               &provisioner.Duration{Duration: 5 * time.Minute},
+		MaxTLSDur:               &provisioner.Duration{Duration: 24 * time.Hour},
+		DefaultTLSDur:           &provisioner.Duration{Duration: 24 * time.Hour},
+		DisableRenewal:          &disableRenewal,
+		AllowRenewalAfterExpiry: &allowRenewalAfterExpiry,
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	svc, err := scep.NewService(context.Background(), scep.Options{
+		CertificateChain: []*x509.Certificate{intermediate, root},
+		Signer:           intKey,
+		Decrypter:        intKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := scep.New(&mockSignAuthority{
+		prov:   prov,
+		roots:  []*x509.Certificate{root},
+		issuer: intermediate,
+		signer: intKey,
+	}, scep.AuthorityOptions{Service: svc})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// newClientCertificate creates a certificate issued by the CA using the
+	// given provisioner.
+	newClientCertificate := func(t *testing.T, typ provisioner.Type, name string) (*x509.Certificate, crypto.Signer) {
+		key := mustRSAKey(t)
+		ext, err := (&provisioner.Extension{Type: typ, Name: name}).ToExtension()
+		if err != nil {
+			t.Fatal(err)
+		}
+		now := time.Now()
+		return mustCertificate(t, &x509.Certificate{
+			SerialNumber:    big.NewInt(now.UnixNano()),
+			Subject:         pkix.Name{CommonName: "foo"},
+			NotBefore:       now.Add(-time.Minute),
+			NotAfter:        now.Add(time.Hour),
+			KeyUsage:        x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+			ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			ExtraExtensions: []pkix.Extension{ext},
+		}, intermediate, key.Public(), intKey), key
+	}
+
+	tests := []struct {
+		name     string
+		typ      provisioner.Type
+		provName string
+		cn       string
+		want     microscep.PKIStatus
+	}{
+		{"ok", provisioner.TypeSCEP, "scep", "foo", microscep.SUCCESS},
+		{"fail wrong provisioner type", provisioner.TypeJWK, "scep", "foo", microscep.FAILURE},
+		{"fail wrong provisioner name", provisioner.TypeSCEP, "other", "foo", microscep.FAILURE},
+		{"fail common name mismatch", provisioner.TypeSCEP, "scep", "bar", microscep.FAILURE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert, key := newClientCertificate(t, tt.typ, tt.provName)
+			body := newRenewalRequest(t, intermediate, cert, key, tt.cn)
+
+			r := chi.NewRouter()
+			New(auth).Route(r)
+			req := httptest.NewRequest(http.MethodPost, "/scep?operation=PKIOperation", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			res := w.Result()
+			defer res.Body.Close()
+			if res.StatusCode != http.StatusOK {
+				t.Fatalf("PKIOperation() status = %d, want %d", res.StatusCode, http.StatusOK)
+			}
+			b, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg, err := microscep.ParsePKIMessage(b)
+			if err != nil {
+				t.Fatalf("error parsing response: %v", err)
+			}
+			if msg.MessageType != microscep.CertRep {
+				t.Fatalf("PKIOperation() message type = %v, want %v", msg.MessageType, microscep.CertRep)
+			}
+			if msg.CertRepMessage.PKIStatus != tt.want {
+				t.Errorf("PKIOperation() status = %v, want %v", msg.CertRepMessage.PKIStatus, tt.want)
+			}
+		})
+	}
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"crypto/subtle"
 	"crypto/x509"
+	"encoding/asn1"
 	"errors"
 	"fmt"
 	"net/url"
+	"sort"
+	"time"
 
 	microx509util "github.com/micromdm/scep/v2/cryptoutil/x509util"
 	microscep "github.com/micromdm/scep/v2/scep"
@@ -40,6 +43,19 @@ type AuthorityOptions struct {
 	Prefix string
 }
 
+// ChallengeStore is the interface implemented by the signing authority to
+// consume the one-time challenges of a provisioner.
+type ChallengeStore interface {
+	UseSCEPChallenge(ctx context.Context, provisionerName, challenge string, csr *x509.CertificateRequest) error
+}
+
+// RenewalAuthority is the interface implemented by the signing authority to
+// verify the certificates used to authenticate renewal requests.
+type RenewalAuthority interface {
+	GetRoots() ([]*x509.Certificate, error)
+	IsRevoked(sn string) (bool, error)
+}
+
 // SignAuthority is the interface for a signing authority
 type SignAuthority interface {
 	Sign(cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]*x509.Certificate, error)
@@ -200,8 +216,16 @@ func (a *Authority) DecryptPKIEnvelope(ctx context.Context, msg *PKIMessage) err
 			ChallengePassword: cp,
 		}
 		return nil
-	case microscep.GetCRL, microscep.GetCert, microscep.CertPoll:
-		return errors.New("not implemented")
+	case microscep.GetCRL, microscep.GetCert:
+		var ias issuerAndSerial
+		if _, err := asn1.Unmarshal(msg.pkiEnvelope, &ias); err != nil {
+			return fmt.Errorf("parse issuer and serial number from pkiEnvelope: %w", err)
+		}
+		msg.issuerAndSerial = &ias
+		return nil
+	case microscep.CertPoll:
+		// The request is identified by the transaction id.
+		return nil
 	}
 
 	return nil
@@ -211,11 +235,6 @@ func (a *Authority) DecryptPKIEnvelope(ctx context.Context, msg *PKIMessage) err
 // returns a new PKIMessage with CertRep data
 func (a *Authority) SignCSR(ctx context.Context, csr *x509.CertificateRequest, msg *PKIMessage) (*PKIMessage, error) {
 
-	// TODO: intermediate storage of the request? In SCEP it's possible to request a csr/certificate
-	// to be signed, which can be performed asynchronously / out-of-band. In that case a client can
-	// poll for the status. It seems to be similar as what can happen in ACME, so might want to model
-	// the implementation after the one in the ACME authority. Requires storage, etc.
-
 	p, err := provisionerFromContext(ctx)
 	if err != nil {
 		return nil, err
@@ -229,6 +248,23 @@ func (a *Authority) SignCSR(ctx context.Context, csr *x509.CertificateRequest, m
 		csr = msg.CSRReqMessage.CSR
 	}
 
+	cert, err := a.signCSR(ctx, p, csr)
+	if err != nil {
+		return nil, err
+	}
+
+	// and create a degenerate cert structure
+	deg, err := microscep.DegenerateCertificates([]*x509.Certificate{cert})
+	if err != nil {
+		return nil, err
+	}
+
+	return a.createSuccessResponse(p, msg, deg, cert)
+}
+
+// signCSR signs the CSR using the provisioner authorizations and template.
+func (a *Authority) signCSR(ctx context.Context, p Provisioner, csr *x509.CertificateRequest) (*x509.Certificate, error) {
+
 	// Template data
 	sans := []string{}
 	sans = append(sans, csr.DNSNames...)
@@ -276,13 +312,13 @@ func (a *Authority) SignCSR(ctx context.Context, csr *x509.CertificateRequest, m
 	}
 
 	// take the issued certificate (only); https://tools.ietf.org/html/rfc8894#section-3.3.2
-	cert := certChain[0]
+	return certChain[0], nil
+}
 
-	// and create a degenerate cert structure
-	deg, err := microscep.DegenerateCertificates([]*x509.Certificate{cert})
-	if err != nil {
-		return nil, err
-	}
+// createSuccessResponse creates a signed CertRep with the SUCCESS status and
+// the given degenerate certificates-only structure, encrypted for the
+// requester. If a certificate is given, it's added to the signed data.
+func (a *Authority) createSuccessResponse(p Provisioner, msg *PKIMessage, deg []byte, cert *x509.Certificate) (*PKIMessage, error) {
 
 	// apparently the pkcs7 library uses a global default setting for the content encryption
 	// algorithm to use when en- or decrypting data. We need to restore the current setting after
@@ -330,7 +366,9 @@ func (a *Authority) SignCSR(ctx context.Context, csr *x509.CertificateRequest, m
 	// add the certificate into the signed data type
 	// this cert must be added before the signedData because the recipient will expect it
 	// as the first certificate in the array
-	signedData.AddCertificate(cert)
+	if cert != nil {
+		signedData.AddCertificate(cert)
+	}
 
 	authCert := a.intermediateCertificate
 
@@ -430,25 +468,159 @@ func (a *Authority) CreateFailureResponse(ctx context.Context, csr *x509.Certifi
 	return crepMsg, nil
 }
 
-// MatchChallengePassword verifies a SCEP challenge password
-func (a *Authority) MatchChallengePassword(ctx context.Context, password string) (bool, error) {
+// MatchChallengePassword verifies a SCEP challenge password. Depending on the
+// challenge type of the provisioner, the password is compared with the static
+// challenge, consumed as a one-time challenge, or sent with the CSR to a
+// webhook.
+func (a *Authority) MatchChallengePassword(ctx context.Context, csr *x509.CertificateRequest, password string) (bool, error) {
 
 	p, err := provisionerFromContext(ctx)
 	if err != nil {
 		return false, err
 	}
 
-	if subtle.ConstantTimeCompare([]byte(p.GetChallengePassword()), []byte(password)) == 1 {
+	switch p.GetChallengeType() {
+	case provisioner.SCEPChallengeOneTime:
+		store, ok := a.signAuth.(ChallengeStore)
+		if !ok {
+			return false, errors.New("one-time challenges are not supported by the authority")
+		}
+		if err := store.UseSCEPChallenge(ctx, p.GetName(), password, csr); err != nil {
+			return false, nil
+		}
 		return true, nil
+	case provisioner.SCEPChallengeWebhook:
+		return p.ValidateChallengeWebhook(ctx, csr, password)
 	}
 
-	// TODO: support dynamic challenges, i.e. a list of challenges instead of one?
-	// That's probably a bit harder to configure, though; likely requires some data store
-	// that can be interacted with more easily, via some internal API, for example.
+	if subtle.ConstantTimeCompare([]byte(p.GetChallengePassword()), []byte(password)) == 1 {
+		return true, nil
+	}
 
 	return false, nil
 }
 
+// AuthorizeRenewal verifies that a RenewalReq is signed with a certificate
+// issued by the CA, that the certificate is not revoked, and that it can be
+// renewed by the provisioner. The certificate must have the same subject and
+// subject alternative names as the CSR.
+func (a *Authority) AuthorizeRenewal(ctx context.Context, msg *PKIMessage) error {
+
+	p, err := provisionerFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	ra, ok := a.signAuth.(RenewalAuthority)
+	if !ok {
+		return errors.New("renewal requests are not supported by the authority")
+	}
+
+	if msg.P7 == nil || msg.CSRReqMessage == nil || msg.CSRReqMessage.CSR == nil {
+		return errors.New("renewal request has not been decrypted")
+	}
+	cert := msg.P7.GetOnlySigner()
+	if cert == nil {
+		return errors.New("renewal request must have one signer")
+	}
+	if err := msg.P7.Verify(); err != nil {
+		return fmt.Errorf("error verifying renewal request signature: %w", err)
+	}
+
+	roots, err := ra.GetRoots()
+	if err != nil {
+		return fmt.Errorf("error getting roots: %w", err)
+	}
+	rootPool := x509.NewCertPool()
+	for _, crt := range roots {
+		rootPool.AddCert(crt)
+	}
+	intermediatePool := x509.NewCertPool()
+	for _, crt := range a.caCerts {
+		intermediatePool.AddCert(crt)
+	}
+	for _, crt := range msg.P7.Certificates {
+		if crt != cert {
+			intermediatePool.AddCert(crt)
+		}
+	}
+
+	// The validity of the certificate is checked by the provisioner, that
+	// might allow the renewal of expired certificates.
+	verifyTime := time.Now()
+	if verifyTime.After(cert.NotAfter) {
+		verifyTime = cert.NotAfter
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         rootPool,
+		Intermediates: intermediatePool,
+		CurrentTime:   verifyTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return fmt.Errorf("error verifying renewal request certificate: %w", err)
+	}
+
+	isRevoked, err := ra.IsRevoked(cert.SerialNumber.String())
+	if err != nil {
+		return fmt.Errorf("error checking renewal request certificate: %w", err)
+	}
+	if isRevoked {
+		return errors.New("renewal request certificate has been revoked")
+	}
+
+	if err := p.AuthorizeRenew(ctx, cert); err != nil {
+		return fmt.Errorf("error authorizing renewal: %w", err)
+	}
+
+	csr := msg.CSRReqMessage.CSR
+	if csr.Subject.String() != cert.Subject.String() {
+		return errors.New("renewal request subject does not match the certificate")
+	}
+	if !equalNames(csrNames(csr), certNames(cert)) {
+		return errors.New("renewal request subject alternative names do not match the certificate")
+	}
+
+	return nil
+}
+
+func csrNames(csr *x509.CertificateRequest) []string {
+	names := append([]string{}, csr.DNSNames...)
+	names = append(names, csr.EmailAddresses...)
+	for _, ip := range csr.IPAddresses {
+		names = append(names, ip.String())
+	}
+	for _, u := range csr.URIs {
+		names = append(names, u.String())
+	}
+	return names
+}
+
+func certNames(cert *x509.Certificate) []string {
+	names := append([]string{}, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	for _, u := range cert.URIs {
+		names = append(names, u.String())
+	}
+	return names
+}
+
+func equalNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
 // GetCACaps returns the CA capabilities
 func (a *Authority) GetCACaps(ctx context.Context) []string {
 
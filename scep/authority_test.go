@@ -0,0 +1,338 @@
+package scep
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	microscep "github.com/micromdm/scep/v2/scep"
+	"go.mozilla.org/pkcs7"
+
+	"github.com/smallstep/certificates/authority/provisioner"
+)
+
+type mockSignAuthority struct {
+	MockSign                  func(cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]*x509.Certificate, error)
+	MockLoadProvisionerByName func(name string) (provisioner.Interface, error)
+	MockGetRoots              func() ([]*x509.Certificate, error)
+	MockIsRevoked             func(sn string) (bool, error)
+}
+
+func (m *mockSignAuthority) Sign(cr *x509.CertificateRequest, opts provisioner.SignOptions, signOpts ...provisioner.SignOption) ([]*x509.Certificate, error) {
+	if m.MockSign != nil {
+		return m.MockSign(cr, opts, signOpts...)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockSignAuthority) LoadProvisionerByName(name string) (provisioner.Interface, error) {
+	if m.MockLoadProvisionerByName != nil {
+		return m.MockLoadProvisionerByName(name)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockSignAuthority) GetRoots() ([]*x509.Certificate, error) {
+	if m.MockGetRoots != nil {
+		return m.MockGetRoots()
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockSignAuthority) IsRevoked(sn string) (bool, error) {
+	if m.MockIsRevoked != nil {
+		return m.MockIsRevoked(sn)
+	}
+	return false, nil
+}
+
+type testCA struct {
+	root         *x509.Certificate
+	intermediate *x509.Certificate
+	signer       crypto.Signer
+}
+
+// newTestCA creates a root and an intermediate valid since the previous day,
+// so expired certificates can still be verified.
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notBefore := time.Now().Add(-24 * time.Hour)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(72 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	root := createCertificate(t, tmpl, tmpl, rootKey.Public(), rootKey)
+	tmpl.SerialNumber = big.NewInt(2)
+	tmpl.Subject = pkix.Name{CommonName: "Test Intermediate CA"}
+	intermediate := createCertificate(t, tmpl, root, intKey.Public(), rootKey)
+	return &testCA{
+		root:         root,
+		intermediate: intermediate,
+		signer:       intKey,
+	}
+}
+
+// newCertificate creates a client certificate issued by the given
+// provisioner.
+func (ca *testCA) newCertificate(t *testing.T, cn string, typ provisioner.Type, provisionerName string, notBefore, notAfter time.Time) (*x509.Certificate, crypto.Signer) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ext, err := (&provisioner.Extension{Type: typ, Name: provisionerName}).ToExtension()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:    big.NewInt(time.Now().UnixNano()),
+		Subject:         pkix.Name{CommonName: cn},
+		NotBefore:       notBefore,
+		NotAfter:        notAfter,
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		ExtraExtensions: []pkix.Extension{ext},
+	}
+	return createCertificate(t, tmpl, ca.intermediate, key.Public(), ca.signer), key
+}
+
+func createCertificate(t *testing.T, tmpl, parent *x509.Certificate, pub crypto.PublicKey, signer crypto.Signer) *x509.Certificate {
+	t.Helper()
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, pub, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// newRenewalMessage returns a decrypted RenewalReq signed by the given
+// certificate. The signing time attribute is not added, so the signature of
+// expired certificates can be verified.
+func newRenewalMessage(t *testing.T, cert *x509.Certificate, key crypto.Signer, cn string) *PKIMessage {
+	t.Helper()
+	sd, err := pkcs7.NewSignedData([]byte("renewal request"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sd.SignWithoutAttr(cert, key, pkcs7.SignerInfoConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	der, err := sd.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p7, err := pkcs7.Parse(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &PKIMessage{
+		MessageType: microscep.RenewalReq,
+		P7:          p7,
+		CSRReqMessage: &microscep.CSRReqMessage{
+			CSR: &x509.CertificateRequest{
+				Subject: pkix.Name{CommonName: cn},
+			},
+		},
+	}
+}
+
+func newSCEPProvisioner(t *testing.T, renewalWindow time.Duration) *provisioner.SCEP {
+	t.Helper()
+	p := &provisioner.SCEP{
+		Type: "SCEP",
+		Name: "scep",
+	}
+	if renewalWindow > 0 {
+		p.RenewalWindow = &provisioner.Duration{Duration: renewalWindow}
+	}
+	disableRenewal, allowRenewalAfterExpiry := false, false
+	if err := p.Init(provisioner.Config{Claims: provisioner.Claims{
+		MinTLSDur:               &provisioner.Duration{Duration: 5 * time.Minute},
+		MaxTLSDur:               &provisioner.Duration{Duration: 24 * time.Hour},
+		DefaultTLSDur:           &provisioner.Duration{Duration: 24 * time.Hour},
+		DisableRenewal:          &disableRenewal,
+		AllowRenewalAfterExpiry: &allowRenewalAfterExpiry,
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestAuthority_AuthorizeRenewal(t *testing.T) {
+	ca := newTestCA(t)
+	otherCA := newTestCA(t)
+	now := time.Now()
+
+	signAuth := &mockSignAuthority{
+		MockGetRoots: func() ([]*x509.Certificate, error) {
+			return []*x509.Certificate{ca.root}, nil
+		},
+	}
+
+	type test struct {
+		auth *Authority
+		prov Provisioner
+		msg  *PKIMessage
+		err  string
+	}
+	tests := map[string]func(t *testing.T) test{
+		"fail/no-provisioner": func(t *testing.T) test {
+			cert, key := ca.newCertificate(t, "foo", provisioner.TypeSCEP, "scep", now, now.Add(time.Hour))
+			return test{
+				auth: &Authority{signAuth: signAuth, caCerts: []*x509.Certificate{ca.intermediate}},
+				msg:  newRenewalMessage(t, cert, key, "foo"),
+				err:  "provisioner expected in request context",
+			}
+		},
+		"fail/untrusted": func(t *testing.T) test {
+			cert, key := otherCA.newCertificate(t, "foo", provisioner.TypeSCEP, "scep", now, now.Add(time.Hour))
+			return test{
+				auth: &Authority{signAuth: signAuth, caCerts: []*x509.Certificate{ca.intermediate}},
+				prov: newSCEPProvisioner(t, 0),
+				msg:  newRenewalMessage(t, cert, key, "foo"),
+				err:  "error verifying renewal request certificate",
+			}
+		},
+		"fail/revoked": func(t *testing.T) test {
+			cert, key := ca.newCertificate(t, "foo", provisioner.TypeSCEP, "scep", now, now.Add(time.Hour))
+			return test{
+				auth: &Authority{signAuth: &mockSignAuthority{
+					MockGetRoots: signAuth.MockGetRoots,
+					MockIsRevoked: func(sn string) (bool, error) {
+						return sn == cert.SerialNumber.String(), nil
+					},
+				}, caCerts: []*x509.Certificate{ca.intermediate}},
+				prov: newSCEPProvisioner(t, 0),
+				msg:  newRenewalMessage(t, cert, key, "foo"),
+				err:  "renewal request certificate has been revoked",
+			}
+		},
+		"fail/expired": func(t *testing.T) test {
+			cert, key := ca.newCertificate(t, "foo", provisioner.TypeSCEP, "scep", now.Add(-2*time.Hour), now.Add(-time.Hour))
+			return test{
+				auth: &Authority{signAuth: signAuth, caCerts: []*x509.Certificate{ca.intermediate}},
+				prov: newSCEPProvisioner(t, 0),
+				msg:  newRenewalMessage(t, cert, key, "foo"),
+				err:  "error authorizing renewal: certificate has expired",
+			}
+		},
+		"fail/wrong-provisioner-type": func(t *testing.T) test {
+			cert, key := ca.newCertificate(t, "foo", provisioner.TypeJWK, "scep", now, now.Add(time.Hour))
+			return test{
+				auth: &Authority{signAuth: signAuth, caCerts: []*x509.Certificate{ca.intermediate}},
+				prov: newSCEPProvisioner(t, 0),
+				msg:  newRenewalMessage(t, cert, key, "foo"),
+				err:  "error authorizing renewal: certificate was not issued by provisioner 'scep'",
+			}
+		},
+		"fail/wrong-provisioner-name": func(t *testing.T) test {
+			cert, key := ca.newCertificate(t, "foo", provisioner.TypeSCEP, "other", now, now.Add(time.Hour))
+			return test{
+				auth: &Authority{signAuth: signAuth, caCerts: []*x509.Certificate{ca.intermediate}},
+				prov: newSCEPProvisioner(t, 0),
+				msg:  newRenewalMessage(t, cert, key, "foo"),
+				err:  "error authorizing renewal: certificate was not issued by provisioner 'scep'",
+			}
+		},
+		"fail/outside-renewal-window": func(t *testing.T) test {
+			cert, key := ca.newCertificate(t, "foo", provisioner.TypeSCEP, "scep", now, now.Add(12*time.Hour))
+			return test{
+				auth: &Authority{signAuth: signAuth, caCerts: []*x509.Certificate{ca.intermediate}},
+				prov: newSCEPProvisioner(t, time.Hour),
+				msg:  newRenewalMessage(t, cert, key, "foo"),
+				err:  "error authorizing renewal: certificate is not in the renewal window",
+			}
+		},
+		"fail/cn-mismatch": func(t *testing.T) test {
+			cert, key := ca.newCertificate(t, "foo", provisioner.TypeSCEP, "scep", now, now.Add(time.Hour))
+			return test{
+				auth: &Authority{signAuth: signAuth, caCerts: []*x509.Certificate{ca.intermediate}},
+				prov: newSCEPProvisioner(t, 0),
+				msg:  newRenewalMessage(t, cert, key, "bar"),
+				err:  "renewal request subject does not match the certificate",
+			}
+		},
+		"fail/subject-mismatch": func(t *testing.T) test {
+			cert, key := ca.newCertificate(t, "foo", provisioner.TypeSCEP, "scep", now, now.Add(time.Hour))
+			msg := newRenewalMessage(t, cert, key, "foo")
+			msg.CSRReqMessage.CSR.Subject.Organization = []string{"Smallstep"}
+			return test{
+				auth: &Authority{signAuth: signAuth, caCerts: []*x509.Certificate{ca.intermediate}},
+				prov: newSCEPProvisioner(t, 0),
+				msg:  msg,
+				err:  "renewal request subject does not match the certificate",
+			}
+		},
+		"fail/extra-san": func(t *testing.T) test {
+			cert, key := ca.newCertificate(t, "foo", provisioner.TypeSCEP, "scep", now, now.Add(time.Hour))
+			msg := newRenewalMessage(t, cert, key, "foo")
+			msg.CSRReqMessage.CSR.DNSNames = []string{"foo.example.com"}
+			return test{
+				auth: &Authority{signAuth: signAuth, caCerts: []*x509.Certificate{ca.intermediate}},
+				prov: newSCEPProvisioner(t, 0),
+				msg:  msg,
+				err:  "renewal request subject alternative names do not match the certificate",
+			}
+		},
+		"ok": func(t *testing.T) test {
+			cert, key := ca.newCertificate(t, "foo", provisioner.TypeSCEP, "scep", now, now.Add(time.Hour))
+			return test{
+				auth: &Authority{signAuth: signAuth, caCerts: []*x509.Certificate{ca.intermediate}},
+				prov: newSCEPProvisioner(t, 0),
+				msg:  newRenewalMessage(t, cert, key, "foo"),
+			}
+		},
+		"ok/renewal-window": func(t *testing.T) test {
+			cert, key := ca.newCertificate(t, "foo", provisioner.TypeSCEP, "scep", now.Add(-time.Hour), now.Add(30*time.Minute))
+			return test{
+				auth: &Authority{signAuth: signAuth, caCerts: []*x509.Certificate{ca.intermediate}},
+				prov: newSCEPProvisioner(t, time.Hour),
+				msg:  newRenewalMessage(t, cert, key, "foo"),
+			}
+		},
+	}
+	for name, run := range tests {
+		t.Run(name, func(t *testing.T) {
+			tc := run(t)
+			ctx := context.Background()
+			if tc.prov != nil {
+				ctx = context.WithValue(ctx, ProvisionerContextKey, tc.prov)
+			}
+			err := tc.auth.AuthorizeRenewal(ctx, tc.msg)
+			switch {
+			case tc.err == "" && err != nil:
+				t.Errorf("Authority.AuthorizeRenewal() error = %v", err)
+			case tc.err != "" && err == nil:
+				t.Errorf("Authority.AuthorizeRenewal() error = nil, want %q", tc.err)
+			case tc.err != "" && !strings.HasPrefix(err.Error(), tc.err):
+				t.Errorf("Authority.AuthorizeRenewal() error = %v, want %q", err, tc.err)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package scep
 
 import (
 	"context"
+	"crypto/x509"
 	"time"
 
 	"github.com/smallstep/certificates/authority/provisioner"
@@ -11,11 +12,15 @@ import (
 // only those methods required by the SCEP api/authority.
 type Provisioner interface {
 	AuthorizeSign(ctx context.Context, token string) ([]provisioner.SignOption, error)
+	AuthorizeRenew(ctx context.Context, cert *x509.Certificate) error
 	GetName() string
 	DefaultTLSCertDuration() time.Duration
 	GetOptions() *provisioner.Options
 	GetChallengePassword() string
+	GetChallengeType() string
+	ValidateChallengeWebhook(ctx context.Context, csr *x509.CertificateRequest, challenge string) (bool, error)
 	GetCapabilities() []string
 	ShouldIncludeRootInChain() bool
+	ShouldRequireApproval() bool
 	GetContentEncryptionAlgorithm() int
 }
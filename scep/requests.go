@@ -0,0 +1,344 @@
+package scep
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	microscep "github.com/micromdm/scep/v2/scep"
+	"go.mozilla.org/pkcs7"
+
+	"github.com/smallstep/certificates/db"
+)
+
+var (
+	oidData       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+)
+
+// RequestStore is the interface implemented by the signing authority to queue
+// the requests of the provisioners that require approval.
+type RequestStore interface {
+	CreateSCEPRequest(ctx context.Context, req *db.SCEPRequest) error
+	GetSCEPRequest(ctx context.Context, provisionerName, id string) (*db.SCEPRequest, error)
+	UpdateSCEPRequest(ctx context.Context, req *db.SCEPRequest, fromStatus string) error
+}
+
+// CertificateStore is the interface implemented by the signing authority to
+// serve the GetCert and GetCRL messages.
+type CertificateStore interface {
+	GetCertificate(serialNumber string) (*x509.Certificate, error)
+	GetCertificateRevocationList() ([]byte, error)
+}
+
+// issuerAndSerial is the IssuerAndSerialNumber structure sent in GetCert and
+// GetCRL messages; https://tools.ietf.org/html/rfc8894#section-3.3.4
+type issuerAndSerial struct {
+	IssuerName   asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type degenerateSignedData struct {
+	Version                    int
+	DigestAlgorithmIdentifiers []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo                contentInfo
+	CRLs                       []asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos                []asn1.RawValue `asn1:"set"`
+}
+
+// degenerateCRL creates a degenerate certificates-only structure with the
+// given DER CRL; https://tools.ietf.org/html/rfc8894#section-3.4
+func degenerateCRL(crl []byte) ([]byte, error) {
+	b, err := asn1.Marshal(degenerateSignedData{
+		Version:                    1,
+		DigestAlgorithmIdentifiers: []pkix.AlgorithmIdentifier{},
+		ContentInfo:                contentInfo{ContentType: oidData},
+		CRLs:                       []asn1.RawValue{{FullBytes: crl}},
+		SignerInfos:                []asn1.RawValue{},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: b},
+	})
+}
+
+// RequiresApproval indicates if the requests of the provisioner must be
+// approved by an admin before the certificate is issued.
+func (a *Authority) RequiresApproval(ctx context.Context) bool {
+	p, err := provisionerFromContext(ctx)
+	if err != nil {
+		return false
+	}
+	return p.ShouldRequireApproval()
+}
+
+// QueueCSR stores the CSR for approval and returns a PENDING response. The
+// client will poll for the certificate using the transaction id of the
+// request. If the client sends the same request again, the response is the
+// same as for a CertPoll.
+func (a *Authority) QueueCSR(ctx context.Context, csr *x509.CertificateRequest, msg *PKIMessage) (*PKIMessage, error) {
+
+	p, err := provisionerFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	store, ok := a.signAuth.(RequestStore)
+	if !ok {
+		return nil, errors.New("requests that require approval are not supported by the authority")
+	}
+
+	now := time.Now().UTC()
+	req := &db.SCEPRequest{
+		ID:          string(msg.TransactionID),
+		Provisioner: p.GetName(),
+		Subject:     csr.Subject.CommonName,
+		CSR:         csr.Raw,
+		Status:      db.SCEPRequestPending,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	err = store.CreateSCEPRequest(ctx, req)
+	switch {
+	case err == nil:
+		return a.createPendingResponse(msg)
+	case !errors.Is(err, db.ErrAlreadyExists):
+		return nil, fmt.Errorf("error storing request: %w", err)
+	}
+
+	req, err = store.GetSCEPRequest(ctx, p.GetName(), string(msg.TransactionID))
+	if err != nil {
+		return nil, fmt.Errorf("error loading request: %w", err)
+	}
+	if !bytes.Equal(req.CSR, csr.Raw) {
+		return nil, errors.New("transaction id has already been used with a different request")
+	}
+
+	return a.collectRequest(ctx, p, store, req, msg)
+}
+
+// PollCSR returns the status of a request queued for approval, identified by
+// the transaction id of the CertPoll message. The message must be signed with
+// the key of the original request.
+func (a *Authority) PollCSR(ctx context.Context, msg *PKIMessage) (*PKIMessage, error) {
+
+	p, err := provisionerFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	store, ok := a.signAuth.(RequestStore)
+	if !ok {
+		return nil, errors.New("requests that require approval are not supported by the authority")
+	}
+
+	signer := msg.P7.GetOnlySigner()
+	if signer == nil {
+		return nil, errors.New("poll request must have one signer")
+	}
+	if err := msg.P7.Verify(); err != nil {
+		return nil, fmt.Errorf("error verifying poll request signature: %w", err)
+	}
+
+	req, err := store.GetSCEPRequest(ctx, p.GetName(), string(msg.TransactionID))
+	if err != nil {
+		return nil, fmt.Errorf("error loading request: %w", err)
+	}
+	csr, err := x509.ParseCertificateRequest(req.CSR)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing request: %w", err)
+	}
+	if pub, ok := csr.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); !ok || !pub.Equal(signer.PublicKey) {
+		return nil, errors.New("poll request is not signed with the key of the request")
+	}
+
+	return a.collectRequest(ctx, p, store, req, msg)
+}
+
+// collectRequest creates the response for a request queued for approval. An
+// approved request is signed the first time it's collected. Before signing, the
+// request is moved to the issuing state, so concurrent collections of the same
+// request get a pending response instead of a second certificate.
+func (a *Authority) collectRequest(ctx context.Context, p Provisioner, store RequestStore, req *db.SCEPRequest, msg *PKIMessage) (*PKIMessage, error) {
+
+	var cert *x509.Certificate
+	switch req.Status {
+	case db.SCEPRequestPending, db.SCEPRequestIssuing:
+		return a.createPendingResponse(msg)
+	case db.SCEPRequestRejected:
+		return a.CreateFailureResponse(ctx, nil, msg, FailInfoName(microscep.BadRequest), "request has been rejected")
+	case db.SCEPRequestApproved:
+		csr, err := x509.ParseCertificateRequest(req.CSR)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing request: %w", err)
+		}
+		req.Status = db.SCEPRequestIssuing
+		req.UpdatedAt = time.Now().UTC()
+		switch err := store.UpdateSCEPRequest(ctx, req, db.SCEPRequestApproved); {
+		case errors.Is(err, db.ErrSCEPRequestNotFound):
+			// Another collection of the request is signing it.
+			return a.createPendingResponse(msg)
+		case err != nil:
+			return nil, fmt.Errorf("error updating request: %w", err)
+		}
+		if cert, err = a.signCSR(ctx, p, csr); err != nil {
+			// Allow the request to be collected again.
+			req.Status = db.SCEPRequestApproved
+			req.UpdatedAt = time.Now().UTC()
+			_ = store.UpdateSCEPRequest(ctx, req, db.SCEPRequestIssuing)
+			return nil, err
+		}
+		req.Status = db.SCEPRequestIssued
+		req.SerialNumber = cert.SerialNumber.String()
+		req.UpdatedAt = time.Now().UTC()
+		if err := store.UpdateSCEPRequest(ctx, req, db.SCEPRequestIssuing); err != nil {
+			return nil, fmt.Errorf("error updating request: %w", err)
+		}
+	case db.SCEPRequestIssued:
+		cs, ok := a.signAuth.(CertificateStore)
+		if !ok {
+			return nil, errors.New("certificate lookups are not supported by the authority")
+		}
+		var err error
+		if cert, err = cs.GetCertificate(req.SerialNumber); err != nil {
+			return nil, fmt.Errorf("error loading certificate: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unexpected request status %q", req.Status)
+	}
+
+	deg, err := microscep.DegenerateCertificates([]*x509.Certificate{cert})
+	if err != nil {
+		return nil, err
+	}
+
+	return a.createSuccessResponse(p, msg, deg, cert)
+}
+
+// GetCert returns the certificate issued by the CA with the issuer and serial
+// number of a GetCert message.
+func (a *Authority) GetCert(ctx context.Context, msg *PKIMessage) (*PKIMessage, error) {
+
+	p, err := provisionerFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cs, ok := a.signAuth.(CertificateStore)
+	if !ok {
+		return nil, errors.New("certificate lookups are not supported by the authority")
+	}
+
+	if msg.issuerAndSerial == nil {
+		return nil, errors.New("get cert request has not been decrypted")
+	}
+
+	cert, err := cs.GetCertificate(msg.issuerAndSerial.SerialNumber.String())
+	if err != nil || !bytes.Equal(cert.RawIssuer, msg.issuerAndSerial.IssuerName.FullBytes) {
+		return a.CreateFailureResponse(ctx, nil, msg, FailInfoName(microscep.BadCertID), "certificate not found")
+	}
+
+	deg, err := microscep.DegenerateCertificates([]*x509.Certificate{cert})
+	if err != nil {
+		return nil, err
+	}
+
+	return a.createSuccessResponse(p, msg, deg, cert)
+}
+
+// GetCRL returns the current CRL of the CA.
+func (a *Authority) GetCRL(ctx context.Context, msg *PKIMessage) (*PKIMessage, error) {
+
+	p, err := provisionerFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cs, ok := a.signAuth.(CertificateStore)
+	if !ok {
+		return nil, errors.New("certificate revocation lists are not supported by the authority")
+	}
+
+	crl, err := cs.GetCertificateRevocationList()
+	if err != nil {
+		return nil, fmt.Errorf("error getting certificate revocation list: %w", err)
+	}
+
+	deg, err := degenerateCRL(crl)
+	if err != nil {
+		return nil, fmt.Errorf("error creating degenerate certificate revocation list: %w", err)
+	}
+
+	return a.createSuccessResponse(p, msg, deg, nil)
+}
+
+// createPendingResponse creates a signed CertRep with the PENDING status.
+func (a *Authority) createPendingResponse(msg *PKIMessage) (*PKIMessage, error) {
+
+	config := pkcs7.SignerInfoConfig{
+		ExtraSignedAttributes: []pkcs7.Attribute{
+			{
+				Type:  oidSCEPtransactionID,
+				Value: msg.TransactionID,
+			},
+			{
+				Type:  oidSCEPpkiStatus,
+				Value: microscep.PENDING,
+			},
+			{
+				Type:  oidSCEPmessageType,
+				Value: microscep.CertRep,
+			},
+			{
+				Type:  oidSCEPsenderNonce,
+				Value: msg.SenderNonce,
+			},
+			{
+				Type:  oidSCEPrecipientNonce,
+				Value: msg.SenderNonce,
+			},
+		},
+	}
+
+	signedData, err := pkcs7.NewSignedData(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// sign the attributes
+	if err := signedData.AddSigner(a.intermediateCertificate, a.service.signer, config); err != nil {
+		return nil, err
+	}
+
+	certRepBytes, err := signedData.Finish()
+	if err != nil {
+		return nil, err
+	}
+
+	cr := &CertRepMessage{
+		PKIStatus:      microscep.PENDING,
+		RecipientNonce: microscep.RecipientNonce(msg.SenderNonce),
+	}
+
+	return &PKIMessage{
+		Raw:            certRepBytes,
+		TransactionID:  msg.TransactionID,
+		MessageType:    microscep.CertRep,
+		CertRepMessage: cr,
+	}, nil
+}
@@ -51,6 +51,9 @@ type PKIMessage struct {
 	// decrypted enveloped content
 	pkiEnvelope []byte
 
+	// issuer and serial number of GetCert and GetCRL messages
+	issuerAndSerial *issuerAndSerial
+
 	// Used to sign message
 	Recipients []*x509.Certificate
 }
@@ -227,12 +227,19 @@ func (t *Template) Output(data interface{}) (Output, error) {
 	}, nil
 }
 
+// legacySSHDConfigTemplate is the default sshd_config.tpl used before the
+// revoked keys were added to it.
+const legacySSHDConfigTemplate = `Match all
+	TrustedUserCAKeys /etc/ssh/ca.pub
+	HostCertificate /etc/ssh/{{.User.Certificate}}
+	HostKey /etc/ssh/{{.User.Key}}`
+
 // backfill updates old templates with the required data.
 func (t *Template) backfill(b []byte) {
 	if strings.EqualFold(t.Name, "sshd_config.tpl") && len(t.RequiredData) == 0 {
 		a := bytes.TrimSpace(b)
-		b := bytes.TrimSpace([]byte(DefaultSSHTemplateData[t.Name]))
-		if bytes.Equal(a, b) {
+		if bytes.Equal(a, bytes.TrimSpace([]byte(DefaultSSHTemplateData[t.Name]))) ||
+			bytes.Equal(a, []byte(legacySSHDConfigTemplate)) {
 			t.RequiredData = []string{"Certificate", "Key"}
 		}
 	}
@@ -217,6 +217,29 @@ func TestTemplate_Load(t *testing.T) {
 	}
 }
 
+func TestTemplate_backfill(t *testing.T) {
+	tests := []struct {
+		name    string
+		tplName string
+		content string
+		want    []string
+	}{
+		{"ok", "sshd_config.tpl", DefaultSSHTemplateData["sshd_config.tpl"], []string{"Certificate", "Key"}},
+		{"ok legacy", "sshd_config.tpl", legacySSHDConfigTemplate + "\n", []string{"Certificate", "Key"}},
+		{"ok custom", "sshd_config.tpl", "Match all\n\tTrustedUserCAKeys /etc/ssh/ca.pub", nil},
+		{"ok other", "ca.tpl", DefaultSSHTemplateData["sshd_config.tpl"], nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl := &Template{Name: tt.tplName}
+			tmpl.backfill([]byte(tt.content))
+			if !reflect.DeepEqual(tmpl.RequiredData, tt.want) {
+				t.Errorf("Template.backfill() RequiredData = %v, want %v", tmpl.RequiredData, tt.want)
+			}
+		})
+	}
+}
+
 func TestTemplate_Render(t *testing.T) {
 	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	assert.FatalError(t, err)
@@ -19,6 +19,9 @@ type StepSSH struct {
 	UserKey           ssh.PublicKey
 	HostFederatedKeys []ssh.PublicKey
 	UserFederatedKeys []ssh.PublicKey
+	// KRL is the OpenSSH key revocation list with the revoked certificates,
+	// it's only available in the host templates.
+	KRL []byte
 }
 
 // DefaultSSHTemplates contains the configuration of default templates used on ssh.
@@ -70,6 +73,13 @@ var DefaultSSHTemplates = SSHTemplates{
 			Path:         "/etc/ssh/ca.pub",
 			Comment:      "#",
 		},
+		{
+			Name:         "revoked_keys.tpl",
+			Type:         File,
+			TemplatePath: "templates/ssh/revoked_keys.tpl",
+			Path:         "/etc/ssh/revoked_keys",
+			Comment:      "#",
+		},
 	},
 }
 
@@ -126,7 +136,10 @@ var DefaultSSHTemplateData = map[string]string{
 	"sshd_config.tpl": `Match all
 	TrustedUserCAKeys /etc/ssh/ca.pub
 	HostCertificate /etc/ssh/{{.User.Certificate}}
-	HostKey /etc/ssh/{{.User.Key}}`,
+	HostKey /etc/ssh/{{.User.Key}}
+{{- if .Step.SSH.KRL }}
+	RevokedKeys /etc/ssh/revoked_keys
+{{- end }}`,
 
 	// ca.tpl contains the public key used to authorized clients
 	"ca.tpl": `{{.Step.SSH.UserKey.Type}} {{.Step.SSH.UserKey.Marshal | toString | b64enc}}
@@ -134,6 +147,9 @@ var DefaultSSHTemplateData = map[string]string{
 {{.Type}} {{.Marshal | toString | b64enc}}
 {{- end }}
 `,
+
+	// revoked_keys.tpl contains the KRL with the revoked certificates
+	"revoked_keys.tpl": `{{.Step.SSH.KRL | toString}}`,
 }
 
 // DefaultTemplates returns the default templates.